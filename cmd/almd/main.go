@@ -1,28 +1,220 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"time"
 
+	"github.com/fatih/color"
 	"github.com/urfave/cli/v2"
 
 	"github.com/nightconcept/almandine/internal/cli/add"
+	cachecmd "github.com/nightconcept/almandine/internal/cli/cache"
+	"github.com/nightconcept/almandine/internal/cli/completion"
+	"github.com/nightconcept/almandine/internal/cli/docs"
+	"github.com/nightconcept/almandine/internal/cli/format"
+	"github.com/nightconcept/almandine/internal/cli/gitconfig"
+	"github.com/nightconcept/almandine/internal/cli/help"
+	"github.com/nightconcept/almandine/internal/cli/ide"
 	initcmd "github.com/nightconcept/almandine/internal/cli/init"
 	"github.com/nightconcept/almandine/internal/cli/install"
 	"github.com/nightconcept/almandine/internal/cli/list"
+	"github.com/nightconcept/almandine/internal/cli/lock"
+	"github.com/nightconcept/almandine/internal/cli/meta"
+	pathscmd "github.com/nightconcept/almandine/internal/cli/paths"
+	"github.com/nightconcept/almandine/internal/cli/recursive"
 	"github.com/nightconcept/almandine/internal/cli/remove"
+	"github.com/nightconcept/almandine/internal/cli/run"
 	"github.com/nightconcept/almandine/internal/cli/self"
+	"github.com/nightconcept/almandine/internal/cli/selftest"
+	"github.com/nightconcept/almandine/internal/cli/setup"
+	"github.com/nightconcept/almandine/internal/cli/token"
+	"github.com/nightconcept/almandine/internal/cli/verify"
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/globalconfig"
+	"github.com/nightconcept/almandine/internal/core/httpclient"
+	"github.com/nightconcept/almandine/internal/core/logger"
+	"github.com/nightconcept/almandine/internal/core/paths"
+	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/nightconcept/almandine/internal/core/staging"
+	"github.com/nightconcept/almandine/internal/core/theme"
+	"github.com/nightconcept/almandine/internal/core/warnings"
 )
 
 // version is the application version, set at build time.
 var version = "dev" // Default to "dev" if not set by ldflags
 
+// applyGlobalConfig applies settings from the global config file that affect every command and
+// shows the first-run hint to users who have not run 'almd setup' yet. It returns the loaded
+// config, which is empty when the file could not be read.
+func applyGlobalConfig(c *cli.Context) *globalconfig.Config {
+	cfg, err := globalconfig.Load()
+	if err != nil {
+		warnings.Printf("ignoring global config: %v", err)
+		return &globalconfig.Config{}
+	}
+	switch cfg.Color {
+	case globalconfig.ColorAlways:
+		color.NoColor = false
+	case globalconfig.ColorNever:
+		color.NoColor = true
+	}
+	_ = theme.Use(cfg.Theme, cfg.Colors) // Already validated by Load
+	httpclient.SetPins(cfg.TLSPins)
+
+	switch c.Args().First() {
+	case "", "setup", "help", "h", "_selftest":
+	default:
+		setup.ShowFirstRunHint(os.Stderr)
+	}
+	return cfg
+}
+
+// startAPIMemo shares GitHub API responses within the run and, for --api-cache-minutes (or
+// api_cache_minutes in config.toml), with later runs.
+func startAPIMemo(c *cli.Context, cfg *globalconfig.Config) error {
+	minutes := cfg.APICacheMinutes
+	if c.IsSet("api-cache-minutes") {
+		minutes = c.Int("api-cache-minutes")
+	}
+	if minutes < 0 {
+		return cli.Exit("Error: --api-cache-minutes must not be negative", 1)
+	}
+	source.StartAPIMemo(time.Duration(minutes) * time.Minute)
+	return nil
+}
+
+// httpCaptureCacheDir is a throwaway cache used while recording or replaying, so every download
+// actually goes through the recorded HTTP layer instead of being served from the user's cache.
+var httpCaptureCacheDir string
+
+// startHTTPCapture sets up --trace-http, --record and --replay.
+func startHTTPCapture(c *cli.Context) error {
+	if c.Bool("trace-http") {
+		httpclient.StartTrace(os.Stderr)
+	}
+	record, replay := c.String("record"), c.String("replay")
+	if record == "" && replay == "" {
+		return nil
+	}
+	if record != "" && replay != "" {
+		return cli.Exit("Error: --record and --replay cannot be used together", 1)
+	}
+
+	var err error
+	if record != "" {
+		err = httpclient.StartRecording(record)
+	} else {
+		err = httpclient.StartReplay(replay)
+	}
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+
+	if !c.IsSet("cache-dir") {
+		if httpCaptureCacheDir, err = os.MkdirTemp("", "almd-capture-cache-"); err != nil {
+			return cli.Exit(fmt.Sprintf("Error creating temporary cache: %v", err), 1)
+		}
+		paths.SetOverride(paths.Cache, httpCaptureCacheDir)
+	}
+	return nil
+}
+
+// stopHTTPCapture ends tracing, a recording or a replay and removes its temporary cache.
+func stopHTTPCapture(c *cli.Context) {
+	httpclient.StopTrace()
+	httpclient.StopRecordingOrReplay()
+	if httpCaptureCacheDir != "" {
+		_ = os.RemoveAll(httpCaptureCacheDir)
+	}
+	if dir := c.String("record"); dir != "" {
+		_, _ = fmt.Fprintf(os.Stderr, "Recorded HTTP interactions to %s. Replay them with 'almd --replay %s ...'.\n", dir, dir)
+	}
+}
+
+// finish runs after every command, including one that failed: it ends HTTP capture and applies
+// --warnings-as-errors. A failed command's own error is reported with it.
+func finish(c *cli.Context) error {
+	if err := source.StopAPIMemo(); err != nil {
+		warnings.Printf("not keeping GitHub API responses: %v", err)
+	}
+	stopHTTPCapture(c)
+	if err := warnings.Err(); err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	return nil
+}
+
+// cleansStaging makes cmd remove the temporary files interrupted runs left in the project it
+// writes to (see staging.CleanProject) before it runs. Wrapped with recursive.Wrap, every
+// project it visits is cleaned.
+func cleansStaging(cmd *cli.Command) *cli.Command {
+	action := cmd.Action
+	cmd.Action = func(c *cli.Context) error {
+		if removed := staging.CleanProject("."); removed > 0 {
+			logger.Debugf("removed %d temporary file(s) left by an interrupted run", removed)
+		}
+		return action(c)
+	}
+	return cmd
+}
+
+// verboseAliases lets the --verbose flag that add, install and other commands still accept on
+// their own turn on verbose output for the whole run, like the global flag does.
+func verboseAliases(commands []*cli.Command) {
+	for _, cmd := range commands {
+		verboseAliases(cmd.Subcommands)
+		before := cmd.Before
+		cmd.Before = func(c *cli.Context) error {
+			if c.Bool("verbose") {
+				logger.SetVerbose(true)
+			}
+			if before != nil {
+				return before(c)
+			}
+			return nil
+		}
+	}
+}
+
 // The main function, where the program execution begins.
 func main() {
 	app := &cli.App{
 		Name:    "almd",
 		Usage:   "Lua package manager for single-file dependencies",
 		Version: version,
+		// Shell completion scripts call almd with --generate-bash-completion; see 'almd completion'.
+		EnableBashCompletion: true,
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "cache-dir", Usage: "Directory for cached downloads (overrides $" + paths.CacheDirEnv + " and XDG defaults)"},
+			&cli.StringFlag{Name: "config-dir", Usage: "Directory for user configuration (overrides $" + paths.ConfigDirEnv + " and XDG defaults)"},
+			&cli.StringFlag{Name: "state-dir", Usage: "Directory for persistent state (overrides $" + paths.StateDirEnv + " and XDG defaults)"},
+			&cli.StringFlag{Name: "manifest", EnvVars: []string{config.ManifestEnv}, Usage: "Use `FILE` as the project manifest instead of almd.toml or project.toml"},
+			&cli.StringFlag{Name: "record", Usage: "Record all HTTP interactions to `DIR` for a reproducible bug report"},
+			&cli.StringFlag{Name: "replay", Usage: "Answer all HTTP requests from a recording in `DIR` instead of the network"},
+			&cli.IntFlag{Name: "api-cache-minutes", EnvVars: []string{"ALMD_API_CACHE_MINUTES"}, Usage: "Reuse GitHub API responses (resolved refs, tag lists) from earlier runs for `N` minutes"},
+			&cli.BoolFlag{Name: "verbose", Usage: "Print debug detail (ref resolution, downloads, lockfile reads and writes) to stderr"},
+			&cli.BoolFlag{Name: "trace-http", Usage: "Log every HTTP request (method, URL, status, timing) and download cache hit or miss to stderr"},
+			&cli.BoolFlag{Name: recursive.FlagName, Aliases: []string{"r"}, Usage: "Run list, outdated, install or verify in every project (project.toml or almd.toml) below the current directory"},
+			&cli.BoolFlag{Name: "warnings-as-errors", Usage: "Exit with an error if the command reported any warning"},
+			&cli.BoolFlag{Name: "plain", Usage: "Print simple line-oriented text without color, glyphs or rules (for screen readers and dumb terminals)"},
+		},
+		Before: func(c *cli.Context) error {
+			paths.SetOverride(paths.Cache, c.String("cache-dir"))
+			paths.SetOverride(paths.Config, c.String("config-dir"))
+			paths.SetOverride(paths.State, c.String("state-dir"))
+			config.SetManifest(c.String("manifest"))
+			cfg := applyGlobalConfig(c)
+			theme.SetPlain(c.Bool("plain"))
+			logger.SetVerbose(c.Bool("verbose"))
+			warnings.SetAsErrors(c.Bool("warnings-as-errors"))
+			if err := startHTTPCapture(c); err != nil {
+				return err
+			}
+			return startAPIMemo(c, cfg)
+		},
+		After: finish,
 		Action: func(c *cli.Context) error {
 			// Default action if no command is specified
 			_ = cli.ShowAppHelp(c)
@@ -30,15 +222,37 @@ func main() {
 		},
 		Commands: []*cli.Command{
 			initcmd.InitCmd(),
-			add.AddCmd(),
+			cleansStaging(add.AddCmd()),
 			remove.RemoveCmd(),
-			install.InstallCmd(),
-			list.ListCmd(),
+			recursive.Wrap(cleansStaging(install.InstallCmd())),
+			recursive.Wrap(install.FetchCmd()),
+			cleansStaging(install.ExplainCmd()),
+			cleansStaging(install.UpdateCmd()),
+			recursive.Wrap(list.ListCmd()),
+			recursive.Wrap(list.OutdatedCmd()),
+			lock.LockCmd(),
+			meta.MetaCmd(),
+			format.FmtCmd(),
+			run.RunCmd(),
+			pathscmd.PathsCmd(),
+			ide.IdeCmd(),
+			gitconfig.GitconfigCmd(),
+			docs.DocsCmd(),
+			cachecmd.CacheCmd(),
+			setup.SetupCmd(),
+			token.TokenCmd(),
 			self.SelfCmd(),
+			recursive.Wrap(verify.VerifyCmd()),
+			selftest.SelftestCmd(),
+			completion.CompletionCmd(),
+			help.HelpCmd(),
 		},
 	}
 
+	verboseAliases(app.Commands)
+
 	if err := app.Run(os.Args); err != nil {
+		cli.HandleExitCoder(err) // Exits with the error's code, e.g. from --warnings-as-errors
 		log.Fatal(err)
 	}
 }
@@ -12,20 +12,32 @@ import (
 	"strings"
 	"time"
 
-	"github.com/fatih/color"
+	"github.com/nightconcept/almandine/internal/core/advisory"
+	"github.com/nightconcept/almandine/internal/core/budget"
+	"github.com/nightconcept/almandine/internal/core/cache"
 	"github.com/nightconcept/almandine/internal/core/config"
 	"github.com/nightconcept/almandine/internal/core/downloader"
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+	"github.com/nightconcept/almandine/internal/core/filemode"
+	"github.com/nightconcept/almandine/internal/core/globalconfig"
 	"github.com/nightconcept/almandine/internal/core/hasher"
+	"github.com/nightconcept/almandine/internal/core/inventory"
 	"github.com/nightconcept/almandine/internal/core/lockfile"
+	"github.com/nightconcept/almandine/internal/core/logger"
 	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/safepath"
 	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/nightconcept/almandine/internal/core/theme"
+	"github.com/nightconcept/almandine/internal/core/transform"
+	"github.com/nightconcept/almandine/internal/core/vendorheader"
+	"github.com/nightconcept/almandine/internal/core/warnings"
 	"github.com/urfave/cli/v2"
 )
 
-// isGitHubSourceWithSufficientInfo checks if the parsed source information
-// points to a GitHub source with all necessary details for advanced integrity checks.
-func isGitHubSourceWithSufficientInfo(p *source.ParsedSourceInfo) bool {
-	return p.Provider == "github" &&
+// isRepoSourceWithSufficientInfo checks if the parsed source information
+// points to a git-hosted source with all necessary details for advanced integrity checks.
+func isRepoSourceWithSufficientInfo(p *source.ParsedSourceInfo) bool {
+	return source.HasCommits(p.Provider) &&
 		p.Owner != "" &&
 		p.Repo != "" &&
 		p.PathInRepo != "" &&
@@ -33,10 +45,10 @@ func isGitHubSourceWithSufficientInfo(p *source.ParsedSourceInfo) bool {
 		!strings.HasPrefix(p.Ref, "error:")
 }
 
-// determineGitHubIntegrity attempts to determine a commit-based integrity string for GitHub sources.
+// determineCommitIntegrity attempts to determine a commit-based integrity string for git-hosted sources.
 // If the ref is already a commit SHA, it's used. Otherwise, it attempts to fetch the latest commit SHA.
 // If fetching fails or is not applicable, it returns the fallbackHashSHA256.
-func determineGitHubIntegrity(parsedInfo *source.ParsedSourceInfo, fallbackHashSHA256 string) string {
+func determineCommitIntegrity(parsedInfo *source.ParsedSourceInfo, fallbackHashSHA256 string) string {
 	// isLikelyCommitSHA checks if the ref string looks like a 40-char hex string (e.g., a full commit SHA).
 	isLikelyCommitSHA := func(ref string) bool {
 		if len(ref) != 40 {
@@ -46,12 +58,13 @@ func determineGitHubIntegrity(parsedInfo *source.ParsedSourceInfo, fallbackHashS
 		return err == nil
 	}
 
-	if isLikelyCommitSHA(parsedInfo.Ref) {
+	// A ref explicitly qualified as a commit is pinned as-is; tag and branch qualifiers always need resolving.
+	if parsedInfo.RefType == source.RefTypeCommit || (parsedInfo.RefType == "" && isLikelyCommitSHA(parsedInfo.Ref)) {
 		return fmt.Sprintf("commit:%s", parsedInfo.Ref)
 	}
 
 	// Attempt to get the specific commit SHA for the file at the given ref.
-	commitSHA, err := source.GetLatestCommitSHAForFile(parsedInfo.Owner, parsedInfo.Repo, parsedInfo.PathInRepo, parsedInfo.Ref)
+	commitSHA, err := source.ResolveRef(parsedInfo)
 	if err != nil {
 		// If fetching the specific commit SHA fails, fallback to the provided SHA256 hash.
 		// Consider logging err here if verbose mode is enabled or for debugging.
@@ -60,115 +73,270 @@ func determineGitHubIntegrity(parsedInfo *source.ParsedSourceInfo, fallbackHashS
 	return fmt.Sprintf("commit:%s", commitSHA)
 }
 
+// isPinnedToCommit reports whether a parsed git-hosted source refers to a fixed commit, making its raw URL immutable.
+func isPinnedToCommit(p *source.ParsedSourceInfo) bool {
+	if !source.HasCommits(p.Provider) {
+		return false
+	}
+	return p.RefType == source.RefTypeCommit || (p.RefType == "" && len(p.Ref) == 40 && isHexString(p.Ref))
+}
+
+func isHexString(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
 func parseAddArgs(cCtx *cli.Context) (sourceURLInput, targetDir, customName string, verbose bool, err error) {
 	if cCtx.NArg() > 0 {
 		sourceURLInput = cCtx.Args().Get(0)
 	} else {
 		return "", "", "", false, fmt.Errorf("<source_url> argument is required")
 	}
-	targetDir = cCtx.String("directory")
+	targetDir = resolveTargetDir(cCtx)
 	customName = cCtx.String("name")
-	verbose = cCtx.Bool("verbose")
+	verbose = cCtx.Bool("verbose") || logger.Verbose()
 	return
 }
 
+// resolveTargetDir returns the directory to save dependencies in: -d, else [vendor] lib_dir in
+// project.toml, else lib_dir in the global config (see 'almd setup'), else the flag's default.
+func resolveTargetDir(cCtx *cli.Context) string {
+	targetDir := cCtx.String("directory")
+	if !cCtx.IsSet("directory") {
+		if proj, projErr := config.LoadProjectToml("."); projErr == nil && proj.VendorLibDir() != "" {
+			targetDir = proj.VendorLibDir()
+		} else if globalCfg, cfgErr := globalconfig.Load(); cfgErr == nil && globalCfg.LibDir != "" {
+			targetDir = globalCfg.LibDir
+		}
+	}
+	return targetDir
+}
+
+// localFileSource turns the path of an existing local file, given without a "file:" prefix,
+// into a file: source. Anything else is returned unchanged.
+func localFileSource(input string) string {
+	if strings.Contains(input, ":") && !filepath.IsAbs(input) {
+		return input
+	}
+	if info, err := os.Stat(input); err != nil || info.IsDir() {
+		return input
+	}
+	return "file:" + filepath.ToSlash(input)
+}
+
 func processSourceURL(sourceURLInput string) (*source.ParsedSourceInfo, error) {
+	sourceURLInput = localFileSource(sourceURLInput)
+	// The project's [defaults] may supply the owner or ref a shorthand source leaves out.
+	if proj, projErr := config.LoadProjectToml("."); projErr == nil {
+		expanded, err := proj.Defaults.Apply(sourceURLInput)
+		if err != nil {
+			return nil, err
+		}
+		sourceURLInput = expanded
+	}
 	parsedInfo, err := source.ParseSourceURL(sourceURLInput)
 	if err != nil {
 		return nil, fmt.Errorf("parsing source URL '%s': %w", sourceURLInput, err)
 	}
+	parsedInfo, err = source.ResolveGistFile(parsedInfo)
+	if err != nil {
+		return nil, fmt.Errorf("resolving gist file in '%s': %w", sourceURLInput, err)
+	}
+	parsedInfo, err = source.DisambiguateRef(parsedInfo, sourceURLInput)
+	if err != nil {
+		return nil, fmt.Errorf("resolving ref in '%s': %w", sourceURLInput, err)
+	}
+	if parsedInfo.IsTagPattern() {
+		parsedInfo, err = source.ResolveTagPattern(parsedInfo)
+		if err != nil {
+			return nil, fmt.Errorf("resolving tag pattern in '%s': %w", sourceURLInput, err)
+		}
+	}
 	return parsedInfo, nil
 }
 
-func downloadDependency(rawURL string) ([]byte, error) {
-	fileContent, err := downloader.DownloadFile(rawURL)
+// downloadDependency fetches rawURL through the global cache. Immutable URLs (pinned to a
+// commit) are served from the cache when present; everything else is always downloaded.
+func downloadDependency(rawURL string, immutable bool) ([]byte, error) {
+	var fileContent []byte
+	var err error
+	if store, openErr := cache.Open(); openErr == nil {
+		fileContent, _, err = store.Fetch(rawURL, immutable, downloader.DownloadFile)
+	} else {
+		fileContent, err = downloader.DownloadFile(rawURL)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("downloading file from '%s': %w", rawURL, err)
 	}
 	return fileContent, nil
 }
 
-func determineFileNames(parsedInfo *source.ParsedSourceInfo, customName string) (dependencyNameInManifest, fileNameOnDisk string, err error) {
-	suggestedBaseName := strings.TrimSuffix(parsedInfo.SuggestedFilename, filepath.Ext(parsedInfo.SuggestedFilename))
-	suggestedExtension := filepath.Ext(parsedInfo.SuggestedFilename)
+// determineFileNames picks the dependency's manifest name and file name. A name given with -n is
+// lowercased and must be valid; a name inferred from the URL is turned into a valid one. Existing
+// manifest keys are matched in the same normalized form (see checkExistingDependency).
+func determineFileNames(parsedInfo *source.ParsedSourceInfo, customName, defaultExt string) (dependencyNameInManifest, fileNameOnDisk string, err error) {
+	suggestedBaseName, suggestedExtension := splitExtension(parsedInfo.SuggestedFilename)
+	if suggestedExtension == "" {
+		suggestedExtension = defaultExt
+	}
 
 	if customName != "" {
-		dependencyNameInManifest = customName
-		fileNameOnDisk = customName + suggestedExtension
+		dependencyNameInManifest = project.NormalizeDependencyName(customName)
+		if nameErr := project.ValidateDependencyName(dependencyNameInManifest); nameErr != nil {
+			if suggestion := project.SuggestDependencyName(customName); suggestion != "" {
+				return "", "", fmt.Errorf("%w. Try -n %s", nameErr, suggestion)
+			}
+			return "", "", nameErr
+		}
+		fileNameOnDisk = dependencyNameInManifest + suggestedExtension
 	} else {
 		if suggestedBaseName == "" || suggestedBaseName == "." || suggestedBaseName == "/" {
 			return "", "", fmt.Errorf("could not infer a valid base filename from URL's suggested filename: '%s'. Use -n to specify a name", parsedInfo.SuggestedFilename)
 		}
-		dependencyNameInManifest = suggestedBaseName
-		fileNameOnDisk = parsedInfo.SuggestedFilename
+		dependencyNameInManifest = project.SuggestDependencyName(suggestedBaseName)
+		if dependencyNameInManifest == "" {
+			return "", "", fmt.Errorf("could not infer a valid dependency name from '%s'. Use -n to specify a name", parsedInfo.SuggestedFilename)
+		}
+		if dependencyNameInManifest != suggestedBaseName {
+			_, _ = fmt.Fprintf(os.Stdout, "Using dependency name '%s' for '%s' (use -n to choose another).\n", dependencyNameInManifest, parsedInfo.SuggestedFilename)
+		}
+		fileNameOnDisk = suggestedBaseName + suggestedExtension
 	}
 
 	if fileNameOnDisk == "" || fileNameOnDisk == "." || fileNameOnDisk == "/" {
 		return "", "", fmt.Errorf("could not determine a valid final filename for saving. Inferred name was empty or invalid")
 	}
+	if nameErr := safepath.ValidateFileName(fileNameOnDisk); nameErr != nil {
+		return "", "", fmt.Errorf("%w. Use -n to choose a different name", nameErr)
+	}
 	return dependencyNameInManifest, fileNameOnDisk, nil
 }
 
-func saveDependencyFile(projectRoot, targetDir, fileNameOnDisk string, fileContent []byte) (fullPath, relativeDestPath string, err error) {
+// splitExtension splits a file name into its base and extension. Only a final ".<letters and
+// digits>" that contains a letter counts as an extension, so a dotfile (".luacheckrc"), a
+// trailing dot ("configure.") or a version ("tool-1.2") is not mistaken for one; a trailing dot
+// is dropped.
+func splitExtension(name string) (base, ext string) {
+	name = strings.TrimRight(name, ".")
+	dot := strings.LastIndex(name, ".")
+	if dot <= 0 {
+		return name, ""
+	}
+	candidate := name[dot+1:]
+	hasLetter := false
+	for _, r := range candidate {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			hasLetter = true
+		case r >= '0' && r <= '9':
+		default:
+			return name, ""
+		}
+	}
+	if !hasLetter {
+		return name, ""
+	}
+	return name[:dot], name[dot:]
+}
+
+// resolveDefaultExt returns the extension to give a file whose source path has none: --ext,
+// else [vendor] default_ext in project.toml, else default_ext in the global config. "none" (or
+// nothing configured) keeps the upstream name as it is.
+func resolveDefaultExt(cCtx *cli.Context) (string, error) {
+	ext := cCtx.String("ext")
+	if !cCtx.IsSet("ext") {
+		if proj, projErr := config.LoadProjectToml("."); projErr == nil && proj.VendorDefaultExt() != "" {
+			ext = proj.VendorDefaultExt()
+		} else if globalCfg, cfgErr := globalconfig.Load(); cfgErr == nil {
+			ext = globalCfg.DefaultExt
+		}
+	}
+	return project.NormalizeExtension(ext)
+}
+
+func saveDependencyFile(projectRoot, targetDir, fileNameOnDisk, mode string, fileContent []byte, settings filemode.Settings) (fullPath, relativeDestPath string, err error) {
 	fullPath = filepath.Join(projectRoot, targetDir, fileNameOnDisk)
 	relativeDestPath = filepath.ToSlash(filepath.Join(targetDir, fileNameOnDisk))
+	if pathErr := safepath.ValidateRelPath(relativeDestPath); pathErr != nil {
+		return "", "", pathErr
+	}
 
 	dirToCreate := filepath.Dir(fullPath)
-	if mkdirErr := os.MkdirAll(dirToCreate, 0755); mkdirErr != nil {
+	if mkdirErr := os.MkdirAll(safepath.LongPath(dirToCreate), 0755); mkdirErr != nil {
 		return "", "", fmt.Errorf("creating directory '%s': %w", dirToCreate, mkdirErr)
 	}
 
-	if writeErr := os.WriteFile(fullPath, fileContent, 0644); writeErr != nil {
+	if writeErr := filemode.WriteFile(safepath.LongPath(fullPath), fileContent, mode, settings); writeErr != nil {
 		return fullPath, "", fmt.Errorf("writing file '%s': %w", fullPath, writeErr) // Return fullPath for potential cleanup
 	}
 	return fullPath, relativeDestPath, nil
 }
 
-func calculateIntegrityHash(parsedInfo *source.ParsedSourceInfo, fileContent []byte) (string, error) {
+func calculateIntegrityHash(parsedInfo *source.ParsedSourceInfo, fileContent []byte, contentHashOnly bool) (string, error) {
 	fileHashSHA256, hashErr := hasher.CalculateSHA256(fileContent)
 	if hashErr != nil {
 		return "", fmt.Errorf("calculating SHA256 hash: %w", hashErr)
 	}
 
-	if isGitHubSourceWithSufficientInfo(parsedInfo) {
-		return determineGitHubIntegrity(parsedInfo, fileHashSHA256), nil
+	if !contentHashOnly && isRepoSourceWithSufficientInfo(parsedInfo) {
+		return determineCommitIntegrity(parsedInfo, fileHashSHA256), nil
 	}
 
 	return fileHashSHA256, nil
 }
 
-func updateProjectManifest(projectRoot, dependencyNameInManifest, canonicalURL, relativeDestPath string) error {
+// calculateTransformedHash returns the hash of the upstream content after transformName.
+func calculateTransformedHash(transformName, filename string, fileContent []byte) (string, error) {
+	transformed, err := transform.Apply(transformName, filename, fileContent)
+	if err != nil {
+		return "", err
+	}
+	return hasher.CalculateSHA256(transformed)
+}
+
+func updateProjectManifest(projectRoot, dependencyNameInManifest string, dep project.Dependency) error {
 	proj, loadTomlErr := config.LoadProjectToml(projectRoot)
 	if loadTomlErr != nil {
 		if os.IsNotExist(loadTomlErr) {
-			expectedProjectTomlPath := filepath.Join(projectRoot, config.ProjectTomlName)
-			return fmt.Errorf("project.toml not found at '%s' (no such file or directory): %w", expectedProjectTomlPath, loadTomlErr)
+			expectedProjectTomlPath := config.ManifestPath(projectRoot)
+			return fmt.Errorf("%s not found at '%s' (no such file or directory): %w", filepath.Base(expectedProjectTomlPath), expectedProjectTomlPath, loadTomlErr)
 		}
-		return fmt.Errorf("loading %s: %w", config.ProjectTomlName, loadTomlErr)
+		return fmt.Errorf("loading %s: %w", config.ManifestName(), loadTomlErr)
 	}
 
 	if proj.Dependencies == nil {
 		proj.Dependencies = make(map[string]project.Dependency)
 	}
-	proj.Dependencies[dependencyNameInManifest] = project.Dependency{
-		Source: canonicalURL,
-		Path:   relativeDestPath,
+	if key, _, ok := project.FindDependency(proj.Dependencies, dependencyNameInManifest); ok {
+		delete(proj.Dependencies, key) // A replaced entry declared in another case takes the new name
 	}
+	proj.Dependencies[dependencyNameInManifest] = dep
 
 	if writeTomlErr := config.WriteProjectToml(projectRoot, proj); writeTomlErr != nil {
-		return fmt.Errorf("writing %s: %w", config.ProjectTomlName, writeTomlErr)
+		return fmt.Errorf("writing %s: %w", config.ManifestName(), writeTomlErr)
 	}
 	return nil
 }
 
-func updateLockfile(projectRoot, dependencyNameInManifest, rawURL, relativeDestPath, integrityHash string) error {
+func updateLockfile(projectRoot, dependencyNameInManifest string, entry lockfile.PackageEntry) error {
 	lf, loadLockErr := lockfile.Load(projectRoot)
 	if loadLockErr != nil {
 		// If lockfile doesn't exist, Load creates a new one, so this error is likely a real issue.
 		return fmt.Errorf("loading/initializing %s: %w", lockfile.LockfileName, loadLockErr)
 	}
 
-	lf.AddOrUpdatePackage(dependencyNameInManifest, rawURL, relativeDestPath, integrityHash)
+	for name := range lf.Package {
+		if name != dependencyNameInManifest && project.NormalizeDependencyName(name) == dependencyNameInManifest {
+			delete(lf.Package, name) // Replaced entry declared in another case; see updateProjectManifest
+		}
+	}
+	lf.AddOrUpdatePackage(dependencyNameInManifest, entry.Source, entry.Path, entry.Hash)
+	if entry.Transform != "" || len(entry.Files) > 0 {
+		locked := lf.Package[dependencyNameInManifest]
+		locked.Transform, locked.TransformedHash = entry.Transform, entry.TransformedHash
+		locked.Files, locked.Extract = entry.Files, entry.Extract
+		lf.Package[dependencyNameInManifest] = locked
+	}
 
 	if saveLockErr := lockfile.Save(projectRoot, lf); saveLockErr != nil {
 		return fmt.Errorf("saving %s: %w", lockfile.LockfileName, saveLockErr)
@@ -176,6 +344,98 @@ func updateLockfile(projectRoot, dependencyNameInManifest, rawURL, relativeDestP
 	return nil
 }
 
+// checkExistingDependency decides what to do when the manifest already declares name.
+// By default an existing dependency is an error; with force it is replaced (its previous entry is
+// returned so the old file can be cleaned up) and with ifMissing the add becomes a no-op.
+func checkExistingDependency(projectRoot, name string, force, ifMissing bool) (previous *project.Dependency, skip bool, err error) {
+	if force && ifMissing {
+		return nil, false, cli.Exit("Error: --force and --if-missing cannot be used together", 1)
+	}
+
+	proj, loadErr := config.LoadProjectToml(projectRoot)
+	if loadErr != nil {
+		// Missing or unreadable manifests are reported when the manifest is updated.
+		return nil, false, nil
+	}
+	key, existing, ok := project.FindDependency(proj.Dependencies, name)
+	if !ok {
+		return nil, false, nil
+	}
+
+	switch {
+	case ifMissing:
+		_, _ = fmt.Fprintf(os.Stdout, "Dependency '%s' already exists in %s. Nothing to do.\n", key, config.ManifestName())
+		return nil, true, nil
+	case force:
+		return &existing, false, nil
+	default:
+		return nil, false, cli.Exit(fmt.Sprintf("Error: dependency '%s' already exists in %s (source: %s). Use --force to replace it or -n to add it under another name.", key, config.ManifestName(), existing.Source), 1)
+	}
+}
+
+// removeReplacedFile deletes the files of a dependency replaced with --force that the new
+// version did not save to the same paths, so the old copies are not left behind. Files almd did
+// not install (see the inventory package) are kept.
+func removeReplacedFile(projectRoot string, previous *project.Dependency, newRelativePaths ...string) {
+	if previous == nil || previous.Path == "" {
+		return
+	}
+	kept := make(map[string]bool, len(newRelativePaths))
+	for _, p := range newRelativePaths {
+		kept[filepath.Clean(p)] = true
+	}
+	inv, invErr := inventory.Load(projectRoot)
+	for _, oldRelPath := range previous.FilePaths() {
+		if kept[filepath.Clean(oldRelPath)] {
+			continue
+		}
+		oldPath := filepath.Join(projectRoot, filepath.FromSlash(oldRelPath))
+		if invErr == nil && !inv.Manages(oldRelPath) {
+			warnings.Printf("Kept previous file '%s': almd did not install it.", oldPath)
+			continue
+		}
+		if err := filemode.Remove(oldPath); err != nil && !os.IsNotExist(err) {
+			warnings.Printf("Failed to remove previous file '%s': %v", oldPath, err)
+			continue
+		}
+		if err := inventory.Release(projectRoot, oldRelPath); err != nil {
+			warnings.Printf("Could not update %s: %v", inventory.Path, err)
+		}
+	}
+}
+
+// checkOverwrite refuses to save a dependency over files of the project's own: files that exist
+// at relPaths but that almd did not install and no dependency in project.toml declares, as when a
+// typo in --directory points at src/main.lua. allowOverwrite (--allow-overwrite) lifts the check.
+func checkOverwrite(projectRoot string, allowOverwrite bool, relPaths ...string) error {
+	if allowOverwrite {
+		return nil
+	}
+	var dependencyPaths []string
+	if proj, err := config.LoadProjectToml(projectRoot); err == nil {
+		dependencyPaths = proj.DependencyPaths()
+	}
+	inv, err := inventory.Load(projectRoot)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error reading %s: %v", inventory.Path, err), 1)
+	}
+	if unmanaged := inv.Unmanaged(dependencyPaths, relPaths...); len(unmanaged) > 0 {
+		return cli.Exit(fmt.Sprintf("Error: refusing to overwrite '%s': almd did not install it. Check --directory and --name, or pass --allow-overwrite to replace it.", strings.Join(unmanaged, "', '")), exitcode.Usage)
+	}
+	return nil
+}
+
+// recordInstalled adds files almd wrote into the project to its inventory.
+func recordInstalled(projectRoot string, relPaths ...string) {
+	var dependencyPaths []string
+	if proj, err := config.LoadProjectToml(projectRoot); err == nil {
+		dependencyPaths = proj.DependencyPaths()
+	}
+	if err := inventory.Record(projectRoot, dependencyPaths, relPaths...); err != nil {
+		warnings.Printf("Could not update %s: %v", inventory.Path, err)
+	}
+}
+
 // determineDisplayVersion determines the version string to display for a dependency.
 // It prioritizes the Ref field, then tries to parse from CanonicalURL, and defaults to "latest".
 func determineDisplayVersion(parsedInfo *source.ParsedSourceInfo) string {
@@ -195,32 +455,58 @@ func determineDisplayVersion(parsedInfo *source.ParsedSourceInfo) string {
 // then attempts to clean up the file, warning if the cleanup fails.
 func performCleanupOnPotentialError(actionErr error, fileWasWritten bool, filePathToDelete string, cCtx *cli.Context) {
 	if actionErr != nil && fileWasWritten {
-		cleanupErr := os.Remove(filePathToDelete)
+		cleanupErr := filemode.Remove(filePathToDelete)
 		if cleanupErr != nil {
 			var errWriter io.Writer = os.Stderr // Default to os.Stderr
 			if cCtx.App != nil && cCtx.App.ErrWriter != nil {
 				errWriter = cCtx.App.ErrWriter
 			}
-			_, _ = fmt.Fprintf(errWriter, "Warning: Failed to clean up downloaded file '%s' during error handling: %v\n", filePathToDelete, cleanupErr)
+			warnings.Fprintf(errWriter, "Failed to clean up downloaded file '%s' during error handling: %v", filePathToDelete, cleanupErr)
 		}
 	}
 }
 
+// requireWritableProject fails an add that would change project.toml when the project is
+// read-only, before anything is downloaded.
+func requireWritableProject(projectRoot string) error {
+	if err := config.RequireWritable(projectRoot); err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), exitcode.Usage)
+	}
+	return nil
+}
+
 // AddCmd provides the CLI command definition for 'add'.
 func AddCmd() *cli.Command {
 	return &cli.Command{
 		Name:      "add",
 		Usage:     "Downloads a dependency and adds it to the project",
-		ArgsUsage: "<source_url>",
+		ArgsUsage: "<source_url> | --from-lock [dependency_name...] | --from-file <file>",
 		Flags: []cli.Flag{
 			&cli.StringFlag{Name: "directory", Aliases: []string{"d"}, Usage: "Specify the target directory for the dependency", Value: "src/lib/"},
 			&cli.StringFlag{Name: "name", Aliases: []string{"n"}, Usage: "Specify the name for the dependency (defaults to filename from URL)"},
 			&cli.BoolFlag{Name: "verbose", Usage: "Enable verbose output"},
+			&cli.BoolFlag{Name: "force", Aliases: []string{"f"}, Usage: "Replace the dependency if one with the same name already exists"},
+			&cli.BoolFlag{Name: "if-missing", Usage: "Do nothing if a dependency with the same name already exists"},
+			&cli.StringFlag{Name: "mode", Usage: "File mode for the dependency, recorded in project.toml (e.g. 0755 for executable scripts)"},
+			&cli.StringFlag{Name: "transform", Usage: "Rewrite the file on download, recorded in project.toml (e.g. strip-comments to drop Lua comments and blank lines)"},
+			&cli.BoolFlag{Name: "no-save", Usage: "Download the file without updating project.toml or the lockfile"},
+			&cli.BoolFlag{Name: "lock-only", Usage: "Update project.toml and the lockfile from the file already at the target path, without downloading"},
+			&cli.BoolFlag{Name: "no-download", Usage: "Register the file already at the target path by its content hash, without any network access"},
+			&cli.BoolFlag{Name: "from-lock", Usage: "Restore dependencies recorded in the lockfile but missing from project.toml"},
+			&cli.StringFlag{Name: "from-file", Usage: "Add every dependency listed in `FILE` (one \"<source> [name] [directory]\" per line, or TOML/JSON), writing project.toml and the lockfile once"},
+			&cli.StringSliceFlag{Name: "label", Usage: "Label the dependency, recorded in project.toml (repeat for several)"},
+			&cli.StringSliceFlag{Name: "extract", Usage: "Extract only `SRC[=DEST]` from an archive source: a file pattern or a directory ending in /, placed at DEST in the dependency's directory (repeat for several)"},
+			&cli.BoolFlag{Name: "allow-overwrite", Usage: "Save the dependency even over an existing file that almd did not install"},
+			&cli.StringFlag{Name: "ext", Usage: "Extension for a file whose source path has none, e.g. .lua (\"none\" keeps the upstream name; default from [vendor] default_ext)"},
 		},
 		Action: func(cCtx *cli.Context) (err error) { // Named return 'err' for defer to access
 			startTime := time.Now()
 			projectRoot := "." // Assuming current directory is project root
 
+			if batch := batchAdd(cCtx); batch != nil {
+				return batch(cCtx, projectRoot)
+			}
+
 			sourceURLInput, targetDir, customName, verbose, parseErr := parseAddArgs(cCtx)
 			if parseErr != nil {
 				err = cli.Exit(fmt.Sprintf("Error parsing 'add' arguments: %v", parseErr), 1)
@@ -228,67 +514,274 @@ func AddCmd() *cli.Command {
 			}
 			_ = verbose // Placeholder for future verbose logging
 
+			noSave, localFlag, flagsErr := parseSaveFlags(cCtx)
+			if flagsErr != nil {
+				return flagsErr
+			}
+			mode, transformName, optionsErr := parseFileOptions(cCtx, localFlag)
+			if optionsErr != nil {
+				return optionsErr
+			}
+
+			defaultExt, extErr := resolveDefaultExt(cCtx)
+			if extErr != nil {
+				return cli.Exit(fmt.Sprintf("Error: %v", extErr), 1)
+			}
+
 			parsedInfo, processURLErr := processSourceURL(sourceURLInput)
 			if processURLErr != nil {
-				err = cli.Exit(fmt.Sprintf("Error processing source URL '%s': %v", sourceURLInput, processURLErr), 1)
+				err = cli.Exit(fmt.Sprintf("Error processing source URL '%s': %v", sourceURLInput, processURLErr), exitcode.Resolution)
 				return
 			}
 
-			fileContent, downloadErr := downloadDependency(parsedInfo.RawURL)
-			if downloadErr != nil {
-				err = cli.Exit(fmt.Sprintf("Error downloading from '%s': %v", parsedInfo.RawURL, downloadErr), 1)
-				return
+			if addFiles := multiFileAdd(cCtx, parsedInfo); addFiles != nil {
+				return addFiles(cCtx, projectRoot, targetDir, customName, mode, transformName, localFlag, noSave, parsedInfo, startTime)
 			}
 
-			dependencyNameInManifest, fileNameOnDisk, determineNamesErr := determineFileNames(parsedInfo, customName)
+			dependencyNameInManifest, fileNameOnDisk, determineNamesErr := determineFileNames(parsedInfo, customName, defaultExt)
 			if determineNamesErr != nil {
 				err = cli.Exit(fmt.Sprintf("Error determining file names: %v", determineNamesErr), 1)
 				return
 			}
+			labels := cCtx.StringSlice("label")
+			if labelErr := project.ValidateLabels(dependencyNameInManifest, labels); labelErr != nil {
+				return cli.Exit(fmt.Sprintf("Error: %v", labelErr), 1)
+			}
+
+			previous, skip, existingErr := checkExistingDependency(projectRoot, dependencyNameInManifest, cCtx.Bool("force"), cCtx.Bool("if-missing"))
+			if existingErr != nil || skip {
+				return existingErr
+			}
 
-			fullPath, relativeDestPath, saveFileErr := saveDependencyFile(projectRoot, targetDir, fileNameOnDisk, fileContent)
-			fileWritten := saveFileErr == nil || (saveFileErr != nil && fullPath != "")
+			fileContent, fullPath, relativeDestPath, fileWritten, acquireErr := acquireDependencyFile(projectRoot, targetDir, fileNameOnDisk, mode, transformName, parsedInfo, localFlag, cCtx.Bool("allow-overwrite"))
 
 			defer func() {
 				performCleanupOnPotentialError(err, fileWritten, fullPath, cCtx)
 			}()
 
-			if saveFileErr != nil {
-				err = cli.Exit(fmt.Sprintf("Error saving dependency file to '%s': %v. Attempting to clean up.", fullPath, saveFileErr), 1)
+			if acquireErr != nil {
+				err = acquireErr
 				return
 			}
 
-			integrityHash, integrityHashErr := calculateIntegrityHash(parsedInfo, fileContent)
-			if integrityHashErr != nil {
-				err = cli.Exit(fmt.Sprintf("Error calculating integrity hash: %v. File '%s' was saved but is now being cleaned up.", integrityHashErr, fullPath), 1)
-				return
+			if !noSave {
+				if err = saveAddedDependency(projectRoot, dependencyNameInManifest, relativeDestPath, fullPath, mode, transformName, labels, previous, parsedInfo, fileContent, localFlag); err != nil {
+					return
+				}
 			}
 
-			manifestErr := updateProjectManifest(projectRoot, dependencyNameInManifest, parsedInfo.CanonicalURL, relativeDestPath)
-			if manifestErr != nil {
-				err = cli.Exit(fmt.Sprintf("Error updating project manifest: %v. File '%s' was saved but is now being cleaned up. %s may be in an inconsistent state.", manifestErr, fullPath, config.ProjectTomlName), 1)
-				return
-			}
+			printAddSummary(dependencyNameInManifest, parsedInfo, noSave, localFlag, startTime)
+			return nil // Explicitly return nil on success
+		},
+	}
+}
 
-			lockfileErr := updateLockfile(projectRoot, dependencyNameInManifest, parsedInfo.RawURL, relativeDestPath, integrityHash)
-			if lockfileErr != nil {
-				err = cli.Exit(fmt.Sprintf("Error updating lockfile: %v. File '%s' saved and %s updated, but lockfile operation failed. %s and %s may be inconsistent. Downloaded file '%s' is being cleaned up.", lockfileErr, fullPath, config.ProjectTomlName, config.ProjectTomlName, lockfile.LockfileName, fullPath), 1)
-				return
-			}
+// batchAdd returns the action for --from-file or --from-lock, which add several dependencies
+// at once, or nil for an add of a single source.
+func batchAdd(cCtx *cli.Context) func(*cli.Context, string) error {
+	switch {
+	case cCtx.IsSet("from-file"):
+		return addFromFile
+	case cCtx.Bool("from-lock"):
+		return addFromLockfile
+	default:
+		return nil
+	}
+}
 
-			// Success: print output
-			_, _ = color.New(color.FgWhite).Println("Packages: +1")
-			_, _ = color.New(color.FgGreen).Println("++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++")
-			fmt.Println("Progress: resolved 1, downloaded 1, added 1, done")
-			fmt.Println()
-			_, _ = color.New(color.FgWhite, color.Bold).Println("dependencies:")
-			dependencyVersionStr := determineDisplayVersion(parsedInfo)
-			_, _ = color.New(color.FgGreen).Printf("+ %s %s\n", dependencyNameInManifest, dependencyVersionStr)
-			fmt.Println()
-			duration := time.Since(startTime)
-			fmt.Printf("Done in %.1fs\n", duration.Seconds())
+// saveAddedDependency checks the added file against the project's budget and records it in
+// project.toml and the lockfile. When it replaces previous, the labels carry over unless new ones
+// are given, and the replaced file is removed. localFlag is as returned by parseSaveFlags; only a
+// downloaded file is added to the inventory of files almd installed.
+func saveAddedDependency(projectRoot, name, relativeDestPath, fullPath, mode, transformName string, labels []string, previous *project.Dependency, parsedInfo *source.ParsedSourceInfo, fileContent []byte, localFlag string) error {
+	if err := enforceBudget(projectRoot, name, project.Dependency{Path: relativeDestPath}); err != nil {
+		return err
+	}
+	if len(labels) == 0 && previous != nil {
+		labels = previous.Labels
+	}
+	if err := recordDependency(projectRoot, name, relativeDestPath, fullPath, mode, transformName, labels, parsedInfo, fileContent, localFlag == "--no-download"); err != nil {
+		return err
+	}
+	removeReplacedFile(projectRoot, previous, relativeDestPath)
+	if localFlag == "" { // A file adopted with --lock-only or --no-download stays the user's
+		recordInstalled(projectRoot, relativeDestPath)
+	}
+	return nil
+}
 
-			return nil // Explicitly return nil on success
-		},
+// parseSaveFlags validates --no-save, --lock-only and --no-download. localFlag names the flag
+// that makes add use the file already on disk instead of downloading it, or is "".
+func parseSaveFlags(cCtx *cli.Context) (noSave bool, localFlag string, err error) {
+	noSave = cCtx.Bool("no-save")
+	for _, name := range []string{"lock-only", "no-download"} {
+		if !cCtx.Bool(name) {
+			continue
+		}
+		if noSave {
+			return false, "", cli.Exit(fmt.Sprintf("Error: --no-save and --%s cannot be used together", name), 1)
+		}
+		localFlag = "--" + name // --no-download wins: it also rules out the commit lookup
+	}
+	if noSave {
+		return true, "", nil // --no-save leaves the manifest alone
+	}
+	return false, localFlag, requireWritableProject(".")
+}
+
+// parseFileOptions validates the --mode and --transform flags. A transform needs the upstream
+// content, so it cannot be combined with localFlag.
+func parseFileOptions(cCtx *cli.Context, localFlag string) (mode, transformName string, err error) {
+	mode, transformName = cCtx.String("mode"), cCtx.String("transform")
+	if mode != "" {
+		if _, modeErr := filemode.Parse(mode); modeErr != nil {
+			return "", "", cli.Exit(fmt.Sprintf("Error: %v", modeErr), 1)
+		}
+	}
+	if transformErr := transform.Validate(transformName); transformErr != nil {
+		return "", "", cli.Exit(fmt.Sprintf("Error: %v", transformErr), 1)
+	}
+	if transformName != "" && localFlag != "" {
+		return "", "", cli.Exit(fmt.Sprintf("Error: --transform cannot be used with %s, which has no upstream content to transform", localFlag), 1)
+	}
+	return mode, transformName, nil
+}
+
+// acquireDependencyFile downloads the dependency and saves it into the project, or with localFlag
+// reads the copy that already exists at the target path. The returned content is always the
+// upstream content; the saved file has transformName and the vendor header applied. written
+// reports whether a file was created that must be cleaned up if a later step fails. A file of the
+// project's own at the target path is only replaced with allowOverwrite; see checkOverwrite.
+func acquireDependencyFile(projectRoot, targetDir, fileNameOnDisk, mode, transformName string, parsedInfo *source.ParsedSourceInfo, localFlag string, allowOverwrite bool) (content []byte, fullPath, relativeDestPath string, written bool, err error) {
+	if localFlag != "" {
+		fullPath = filepath.Join(projectRoot, targetDir, fileNameOnDisk)
+		relativeDestPath = filepath.ToSlash(filepath.Join(targetDir, fileNameOnDisk))
+		content, readErr := os.ReadFile(fullPath)
+		if readErr != nil {
+			return nil, fullPath, relativeDestPath, false, cli.Exit(fmt.Sprintf("Error: %s requires the dependency file to already exist at '%s': %v", localFlag, fullPath, readErr), 1)
+		}
+		return vendorheader.Strip(content), fullPath, relativeDestPath, false, nil
+	}
+	if overwriteErr := checkOverwrite(projectRoot, allowOverwrite, filepath.ToSlash(filepath.Join(targetDir, fileNameOnDisk))); overwriteErr != nil {
+		return nil, "", "", false, overwriteErr
+	}
+
+	content, downloadErr := downloadDependency(parsedInfo.RawURL, isPinnedToCommit(parsedInfo))
+	if downloadErr != nil {
+		return nil, "", "", false, cli.Exit(fmt.Sprintf("Error downloading from '%s': %v", parsedInfo.RawURL, downloadErr), exitcode.Download)
+	}
+
+	onDisk, transformErr := transform.Apply(transformName, fileNameOnDisk, content)
+	if transformErr != nil {
+		return nil, "", "", false, cli.Exit(fmt.Sprintf("Error transforming '%s': %v", fileNameOnDisk, transformErr), 1)
+	}
+	fullPath, relativeDestPath, saveFileErr := saveDependencyFile(projectRoot, targetDir, fileNameOnDisk, mode, withVendorHeader(projectRoot, fileNameOnDisk, parsedInfo, onDisk), filemode.GlobalSettings())
+	written = saveFileErr == nil || fullPath != ""
+	if saveFileErr != nil {
+		return nil, fullPath, relativeDestPath, written, cli.Exit(fmt.Sprintf("Error saving dependency file to '%s': %v. Attempting to clean up.", fullPath, saveFileErr), 1)
+	}
+	return content, fullPath, relativeDestPath, written, nil
+}
+
+// withVendorHeader returns the content to write to disk: the downloaded content, with a
+// provenance header when project.toml enables [vendor] header. The upstream content is still
+// what gets hashed.
+func withVendorHeader(projectRoot, fileNameOnDisk string, parsedInfo *source.ParsedSourceInfo, content []byte) []byte {
+	proj, err := config.LoadProjectToml(projectRoot)
+	if err != nil || !proj.VendorHeaderEnabled() {
+		return content
+	}
+	commit := ""
+	if isPinnedToCommit(parsedInfo) {
+		commit = parsedInfo.Ref
+	} else if sha, resolveErr := source.ResolveRef(parsedInfo); resolveErr == nil {
+		commit = sha
+	}
+	return vendorheader.Apply(content, fileNameOnDisk, vendorheader.Describe(parsedInfo, parsedInfo.CanonicalURL, commit))
+}
+
+// enforceBudget checks the project's [budget] as it will be once the dependency is recorded. An
+// exceeded budget fails the add, and the file is cleaned up, unless warn_only is set.
+func enforceBudget(projectRoot, dependencyNameInManifest string, added project.Dependency) error {
+	proj, err := config.LoadProjectToml(projectRoot)
+	if err != nil || proj.Budget == nil {
+		return nil // A missing or broken project.toml is reported when the dependency is recorded
+	}
+	deps := make(map[string]project.Dependency, len(proj.Dependencies)+1)
+	for name, dep := range proj.Dependencies {
+		deps[name] = dep
+	}
+	deps[dependencyNameInManifest] = added
+	if err := budget.Enforce(projectRoot, proj.Budget, deps); err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	return nil
+}
+
+// recordDependency computes the integrity hash and writes the dependency to project.toml and almd-lock.toml.
+// With contentHashOnly the file is locked by its sha256 hash even when its commit could be looked up.
+func recordDependency(projectRoot, dependencyNameInManifest, relativeDestPath, fullPath, mode, transformName string, labels []string, parsedInfo *source.ParsedSourceInfo, fileContent []byte, contentHashOnly bool) error {
+	integrityHash, integrityHashErr := calculateIntegrityHash(parsedInfo, fileContent, contentHashOnly)
+	if integrityHashErr != nil {
+		return cli.Exit(fmt.Sprintf("Error calculating integrity hash: %v. File '%s' was saved but is now being cleaned up.", integrityHashErr, fullPath), 1)
+	}
+	warnAdvisory(dependencyNameInManifest, parsedInfo.CanonicalURL, integrityHash)
+	entry := lockfile.PackageEntry{Source: parsedInfo.RawURL, Path: relativeDestPath, Hash: integrityHash}
+	if transformName != "" {
+		transformedHash, transformedHashErr := calculateTransformedHash(transformName, relativeDestPath, fileContent)
+		if transformedHashErr != nil {
+			return cli.Exit(fmt.Sprintf("Error calculating transformed hash: %v. File '%s' was saved but is now being cleaned up.", transformedHashErr, fullPath), 1)
+		}
+		entry.Transform, entry.TransformedHash = transformName, transformedHash
+	}
+
+	dep := project.Dependency{Source: parsedInfo.CanonicalURL, Path: relativeDestPath, Mode: mode, Transform: transformName, Labels: labels}
+	manifestErr := updateProjectManifest(projectRoot, dependencyNameInManifest, dep)
+	if manifestErr != nil {
+		return cli.Exit(fmt.Sprintf("Error updating project manifest: %v. File '%s' was saved but is now being cleaned up. %s may be in an inconsistent state.", manifestErr, fullPath, config.ManifestName()), 1)
+	}
+
+	lockfileErr := updateLockfile(projectRoot, dependencyNameInManifest, entry)
+	if lockfileErr != nil {
+		return cli.Exit(fmt.Sprintf("Error updating lockfile: %v. File '%s' saved and %s updated, but lockfile operation failed. %s and %s may be inconsistent. Downloaded file '%s' is being cleaned up.", lockfileErr, fullPath, config.ManifestName(), config.ManifestName(), lockfile.LockfileName, fullPath), 1)
+	}
+	return nil
+}
+
+// warnAdvisory warns when a registry's advisories mark the added source deprecated or yanked at
+// the version locked as integrityHash.
+func warnAdvisory(name, sourceURL, integrityHash string) {
+	advisories, err := advisory.Load()
+	if err != nil {
+		warnings.Printf("Could not check whether '%s' is deprecated or yanked: %v", name, err)
+	}
+	if a := advisory.Match(advisories, sourceURL, strings.TrimPrefix(integrityHash, "commit:")); a != nil {
+		warnings.Printf("%s", a.Describe(name))
+	}
+}
+
+// printAddSummary prints the pnpm-style result of an add, noting which artifacts were left untouched.
+func printAddSummary(dependencyNameInManifest string, parsedInfo *source.ParsedSourceInfo, noSave bool, localFlag string, startTime time.Time) {
+	downloaded := 1
+	if localFlag != "" {
+		downloaded = 0
+	}
+	_, _ = theme.New(theme.Summary).Println("Packages: +1")
+	if !theme.Plain() {
+		_, _ = theme.New(theme.Added).Println("++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++")
+	}
+	fmt.Printf("Progress: resolved 1, downloaded %d, added 1, done\n", downloaded)
+	fmt.Println()
+	_, _ = theme.New(theme.Section).Println("dependencies:")
+	dependencyVersionStr := determineDisplayVersion(parsedInfo)
+	_, _ = theme.New(theme.Added).Printf("+ %s %s\n", dependencyNameInManifest, dependencyVersionStr)
+	fmt.Println()
+	if noSave {
+		fmt.Printf("Not saved: %s and %s were left unchanged (--no-save).\n", config.ManifestName(), lockfile.LockfileName)
+	}
+	if localFlag != "" {
+		fmt.Printf("Used the existing local file; nothing was downloaded (%s).\n", localFlag)
 	}
+	duration := time.Since(startTime)
+	fmt.Printf("Done in %.1fs\n", duration.Seconds())
 }
@@ -6,19 +6,28 @@
 package add
 
 import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 
 	"github.com/BurntSushi/toml"
 	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+	"github.com/nightconcept/almandine/internal/core/globalconfig"
+	"github.com/nightconcept/almandine/internal/core/hasher"
 	"github.com/nightconcept/almandine/internal/core/lockfile"
+	"github.com/nightconcept/almandine/internal/core/paths"
 	"github.com/nightconcept/almandine/internal/core/project"
 	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/nightconcept/almandine/internal/core/warnings"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/urfave/cli/v2"
@@ -38,6 +47,9 @@ func init() {
 func setupAddTestEnvironment(t *testing.T, initialProjectTomlContent string) (tempDir string) {
 	t.Helper()
 	tempDir = t.TempDir()
+	// Keep downloads and settings out of the real user directories.
+	t.Setenv(paths.CacheDirEnv, filepath.Join(t.TempDir(), "cache"))
+	t.Setenv(paths.ConfigDirEnv, filepath.Join(t.TempDir(), "config"))
 
 	if initialProjectTomlContent != "" {
 		projectTomlPath := filepath.Join(tempDir, config.ProjectTomlName)
@@ -546,3 +558,711 @@ version = "0.1.0"
 	_, err = os.ReadFile(lockFilePath)
 	require.Error(t, err, "Attempting to read %s (which is a dir) as a file should fail", lockfile.LockfileName)
 }
+
+// TestAddCommand_FromLock_RestoresMissingManifestEntries verifies that 'add --from-lock' downloads the
+// pinned content of lockfile entries missing from project.toml and regenerates their manifest entries,
+// while leaving dependencies already in the manifest alone.
+func TestAddCommand_FromLock_RestoresMissingManifestEntries(t *testing.T) {
+	pinnedSHA := "0123456789abcdef0123456789abcdef01234567"
+	plainContent := "return 'plain'"
+	plainHash := "sha256:" + fmt.Sprintf("%x", sha256.Sum256([]byte(plainContent)))
+
+	initialTomlContent := `
+[package]
+name = "from-lock-project"
+version = "0.1.0"
+
+[dependencies.kept]
+source = "github:owner/repo/kept.lua@main"
+path = "src/lib/kept.lua"
+`
+	tempDir := setupAddTestEnvironment(t, initialTomlContent)
+
+	mockServer := startMockServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		"/owner/repo/" + pinnedSHA + "/lib/pinned.lua": {Body: "return 'pinned'", Code: http.StatusOK},
+		"/owner/repo/v1.0.0/lib/plain.lua":             {Body: plainContent, Code: http.StatusOK},
+	})
+
+	lockContent := fmt.Sprintf(`api_version = "1"
+
+[package.kept]
+source = "%[1]s/owner/repo/main/kept.lua"
+path = "src/lib/kept.lua"
+hash = "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+[package.pinned]
+source = "%[1]s/owner/repo/%[2]s/lib/pinned.lua"
+path = "src/lib/pinned.lua"
+hash = "commit:%[2]s"
+
+[package.plain]
+source = "%[1]s/owner/repo/v1.0.0/lib/plain.lua"
+path = "vendor/plain.lua"
+hash = "%[3]s"
+`, mockServer.URL, pinnedSHA, plainHash)
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, lockfile.LockfileName), []byte(lockContent), 0644))
+
+	err := runAddCommand(t, tempDir, "--from-lock")
+	require.NoError(t, err)
+
+	projCfg := readProjectToml(t, filepath.Join(tempDir, config.ProjectTomlName))
+	require.Len(t, projCfg.Dependencies, 3)
+	assert.Equal(t, "github:owner/repo/kept.lua@main", projCfg.Dependencies["kept"].Source)
+	assert.Equal(t, "github:owner/repo/lib/pinned.lua@"+pinnedSHA, projCfg.Dependencies["pinned"].Source)
+	assert.Equal(t, "src/lib/pinned.lua", projCfg.Dependencies["pinned"].Path)
+	assert.Equal(t, "github:owner/repo/lib/plain.lua@v1.0.0", projCfg.Dependencies["plain"].Source)
+	assert.Equal(t, "vendor/plain.lua", projCfg.Dependencies["plain"].Path)
+
+	pinned, readErr := os.ReadFile(filepath.Join(tempDir, "src", "lib", "pinned.lua"))
+	require.NoError(t, readErr)
+	assert.Equal(t, "return 'pinned'", string(pinned))
+	plain, readErr := os.ReadFile(filepath.Join(tempDir, "vendor", "plain.lua"))
+	require.NoError(t, readErr)
+	assert.Equal(t, plainContent, string(plain))
+
+	_, statErr := os.Stat(filepath.Join(tempDir, "src", "lib", "kept.lua"))
+	assert.True(t, os.IsNotExist(statErr), "dependencies already in the manifest should not be downloaded")
+}
+
+// TestAddCommand_FromLock_HashMismatch verifies that content not matching the locked sha256 hash is
+// rejected, cleaned up, and leaves project.toml untouched.
+func TestAddCommand_FromLock_HashMismatch(t *testing.T) {
+	initialTomlContent := `
+[package]
+name = "from-lock-mismatch"
+version = "0.1.0"
+`
+	tempDir := setupAddTestEnvironment(t, initialTomlContent)
+
+	mockServer := startMockServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		"/owner/repo/v1.0.0/lib/tampered.lua": {Body: "return 'tampered'", Code: http.StatusOK},
+	})
+
+	lockContent := fmt.Sprintf(`api_version = "1"
+
+[package.tampered]
+source = "%s/owner/repo/v1.0.0/lib/tampered.lua"
+path = "src/lib/tampered.lua"
+hash = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+`, mockServer.URL)
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, lockfile.LockfileName), []byte(lockContent), 0644))
+
+	err := runAddCommand(t, tempDir, "--from-lock", "tampered")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match locked hash")
+
+	_, statErr := os.Stat(filepath.Join(tempDir, "src", "lib", "tampered.lua"))
+	assert.True(t, os.IsNotExist(statErr), "file with mismatched hash should be cleaned up")
+
+	projCfg := readProjectToml(t, filepath.Join(tempDir, config.ProjectTomlName))
+	assert.Empty(t, projCfg.Dependencies)
+}
+
+// TestAddCommand_FromLock_UnknownName verifies that requesting a name absent from the lockfile fails.
+func TestAddCommand_FromLock_UnknownName(t *testing.T) {
+	tempDir := setupAddTestEnvironment(t, `
+[package]
+name = "from-lock-unknown"
+version = "0.1.0"
+`)
+	lockContent := `api_version = "1"
+
+[package.present]
+source = "https://example.com/owner/repo/main/present.lua"
+path = "src/lib/present.lua"
+hash = "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+`
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, lockfile.LockfileName), []byte(lockContent), 0644))
+
+	err := runAddCommand(t, tempDir, "--from-lock", "absent")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "dependency 'absent' not found in "+lockfile.LockfileName)
+}
+
+// TestAddCommand_ExistingDependency verifies that adding a name already in project.toml is refused
+// by default, replaced with --force (removing the previous file when the path changes), and
+// skipped with --if-missing.
+func TestAddCommand_ExistingDependency(t *testing.T) {
+	pinnedSHA := "89abcdef0123456789abcdef0123456789abcdef"
+	initialTomlContent := `
+[package]
+name = "existing-dep"
+version = "0.1.0"
+
+[dependencies.mylib]
+source = "github:owner/repo/mylib.lua@v1.0.0"
+path = "old/mylib.lua"
+`
+	mockServer := startMockServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		"/owner/repo/" + pinnedSHA + "/mylib.lua": {Body: "return 'v2'", Code: http.StatusOK},
+	})
+	newSourceURL := mockServer.URL + "/owner/repo/" + pinnedSHA + "/mylib.lua"
+
+	t.Run("refuses by default", func(t *testing.T) {
+		tempDir := setupAddTestEnvironment(t, initialTomlContent)
+		err := runAddCommand(t, tempDir, newSourceURL)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "dependency 'mylib' already exists")
+		assert.Contains(t, err.Error(), "--force")
+
+		projCfg := readProjectToml(t, filepath.Join(tempDir, config.ProjectTomlName))
+		assert.Equal(t, "github:owner/repo/mylib.lua@v1.0.0", projCfg.Dependencies["mylib"].Source)
+		assert.NoFileExists(t, filepath.Join(tempDir, "src", "lib", "mylib.lua"))
+	})
+
+	t.Run("if-missing is a no-op", func(t *testing.T) {
+		tempDir := setupAddTestEnvironment(t, initialTomlContent)
+		err := runAddCommand(t, tempDir, "--if-missing", newSourceURL)
+		require.NoError(t, err)
+
+		projCfg := readProjectToml(t, filepath.Join(tempDir, config.ProjectTomlName))
+		assert.Equal(t, "github:owner/repo/mylib.lua@v1.0.0", projCfg.Dependencies["mylib"].Source)
+		assert.NoFileExists(t, filepath.Join(tempDir, "src", "lib", "mylib.lua"))
+	})
+
+	t.Run("force replaces", func(t *testing.T) {
+		tempDir := setupAddTestEnvironment(t, initialTomlContent)
+		oldFile := filepath.Join(tempDir, "old", "mylib.lua")
+		require.NoError(t, os.MkdirAll(filepath.Dir(oldFile), 0755))
+		require.NoError(t, os.WriteFile(oldFile, []byte("return 'v1'"), 0644))
+
+		err := runAddCommand(t, tempDir, "--force", newSourceURL)
+		require.NoError(t, err)
+
+		projCfg := readProjectToml(t, filepath.Join(tempDir, config.ProjectTomlName))
+		assert.Equal(t, "github:owner/repo/mylib.lua@"+pinnedSHA, projCfg.Dependencies["mylib"].Source)
+		assert.Equal(t, "src/lib/mylib.lua", projCfg.Dependencies["mylib"].Path)
+		assert.NoFileExists(t, oldFile, "the replaced dependency's old file should be removed")
+
+		content, readErr := os.ReadFile(filepath.Join(tempDir, "src", "lib", "mylib.lua"))
+		require.NoError(t, readErr)
+		assert.Equal(t, "return 'v2'", string(content))
+	})
+
+	t.Run("force and if-missing conflict", func(t *testing.T) {
+		tempDir := setupAddTestEnvironment(t, initialTomlContent)
+		err := runAddCommand(t, tempDir, "--force", "--if-missing", newSourceURL)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot be used together")
+	})
+}
+
+func TestAddCommand_NoSaveAndLockOnly(t *testing.T) {
+	pinnedSHA := "0123456789abcdef0123456789abcdef01234567"
+	initialTomlContent := `
+[package]
+name = "save-modes"
+version = "0.1.0"
+`
+	mockServer := startMockServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		"/owner/repo/" + pinnedSHA + "/mylib.lua": {Body: "return 'remote'", Code: http.StatusOK},
+	})
+	sourceURL := mockServer.URL + "/owner/repo/" + pinnedSHA + "/mylib.lua"
+
+	t.Run("no-save writes only the file", func(t *testing.T) {
+		tempDir := setupAddTestEnvironment(t, initialTomlContent)
+		err := runAddCommand(t, tempDir, "--no-save", sourceURL)
+		require.NoError(t, err)
+
+		content, readErr := os.ReadFile(filepath.Join(tempDir, "src", "lib", "mylib.lua"))
+		require.NoError(t, readErr)
+		assert.Equal(t, "return 'remote'", string(content))
+
+		projCfg := readProjectToml(t, filepath.Join(tempDir, config.ProjectTomlName))
+		assert.Empty(t, projCfg.Dependencies)
+		assert.NoFileExists(t, filepath.Join(tempDir, lockfile.LockfileName))
+	})
+
+	t.Run("lock-only records the existing file", func(t *testing.T) {
+		tempDir := setupAddTestEnvironment(t, initialTomlContent)
+		localFile := filepath.Join(tempDir, "src", "lib", "mylib.lua")
+		require.NoError(t, os.MkdirAll(filepath.Dir(localFile), 0755))
+		require.NoError(t, os.WriteFile(localFile, []byte("return 'local'"), 0644))
+
+		err := runAddCommand(t, tempDir, "--lock-only", sourceURL)
+		require.NoError(t, err)
+
+		content, readErr := os.ReadFile(localFile)
+		require.NoError(t, readErr)
+		assert.Equal(t, "return 'local'", string(content), "--lock-only must not overwrite the local file")
+
+		projCfg := readProjectToml(t, filepath.Join(tempDir, config.ProjectTomlName))
+		assert.Equal(t, "src/lib/mylib.lua", projCfg.Dependencies["mylib"].Path)
+
+		lockCfg := readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName))
+		require.Contains(t, lockCfg.Package, "mylib")
+		assert.Equal(t, "commit:"+pinnedSHA, lockCfg.Package["mylib"].Hash)
+	})
+
+	t.Run("no-download records the content hash", func(t *testing.T) {
+		tempDir := setupAddTestEnvironment(t, initialTomlContent)
+		localFile := filepath.Join(tempDir, "src", "lib", "mylib.lua")
+		require.NoError(t, os.MkdirAll(filepath.Dir(localFile), 0755))
+		require.NoError(t, os.WriteFile(localFile, []byte("return 'local'"), 0644))
+
+		err := runAddCommand(t, tempDir, "--no-download", sourceURL)
+		require.NoError(t, err)
+
+		content, readErr := os.ReadFile(localFile)
+		require.NoError(t, readErr)
+		assert.Equal(t, "return 'local'", string(content), "--no-download must not overwrite the local file")
+
+		lockCfg := readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName))
+		require.Contains(t, lockCfg.Package, "mylib")
+		expectedHash, hashErr := hasher.CalculateSHA256([]byte("return 'local'"))
+		require.NoError(t, hashErr)
+		assert.Equal(t, expectedHash, lockCfg.Package["mylib"].Hash)
+	})
+
+	t.Run("lock-only requires the file", func(t *testing.T) {
+		tempDir := setupAddTestEnvironment(t, initialTomlContent)
+		err := runAddCommand(t, tempDir, "--lock-only", sourceURL)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "--lock-only requires the dependency file to already exist")
+
+		projCfg := readProjectToml(t, filepath.Join(tempDir, config.ProjectTomlName))
+		assert.Empty(t, projCfg.Dependencies)
+	})
+
+	t.Run("no-save and lock-only conflict", func(t *testing.T) {
+		tempDir := setupAddTestEnvironment(t, initialTomlContent)
+		err := runAddCommand(t, tempDir, "--no-save", "--lock-only", sourceURL)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot be used together")
+	})
+}
+
+func TestAddCommand_GlobalLibDir(t *testing.T) {
+	pinnedSHA := "fedcba9876543210fedcba9876543210fedcba98"
+	mockServer := startMockServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		"/owner/repo/" + pinnedSHA + "/mylib.lua": {Body: "return {}", Code: http.StatusOK},
+	})
+	sourceURL := mockServer.URL + "/owner/repo/" + pinnedSHA + "/mylib.lua"
+
+	tempDir := setupAddTestEnvironment(t, "[package]\nname = \"libdir\"\nversion = \"0.1.0\"\n")
+	require.NoError(t, globalconfig.Save(&globalconfig.Config{LibDir: "vendor/lua"}))
+
+	require.NoError(t, runAddCommand(t, tempDir, sourceURL))
+	assert.FileExists(t, filepath.Join(tempDir, "vendor", "lua", "mylib.lua"))
+
+	require.NoError(t, runAddCommand(t, tempDir, "-d", "explicit", "-n", "other", sourceURL))
+	assert.FileExists(t, filepath.Join(tempDir, "explicit", "other.lua"), "an explicit --directory wins over the global config")
+}
+
+func TestAddCommand_RejectsReservedFileName(t *testing.T) {
+	pinnedSHA := "aaaabbbbccccddddeeeeffff0000111122223333"
+	mockServer := startMockServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		"/owner/repo/" + pinnedSHA + "/con.lua": {Body: "return {}", Code: http.StatusOK},
+	})
+	sourceURL := mockServer.URL + "/owner/repo/" + pinnedSHA + "/con.lua"
+	tempDir := setupAddTestEnvironment(t, "[package]\nname = \"reserved\"\nversion = \"0.1.0\"\n")
+
+	err := runAddCommand(t, tempDir, sourceURL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "reserved Windows device name 'CON'")
+	assert.Contains(t, err.Error(), "Use -n")
+
+	require.NoError(t, runAddCommand(t, tempDir, "-n", "console", sourceURL))
+	assert.FileExists(t, filepath.Join(tempDir, "src", "lib", "console.lua"))
+}
+
+func TestAddCommand_NormalizesDependencyNames(t *testing.T) {
+	pinnedSHA := "aaaabbbbccccddddeeeeffff0000111122224444"
+	mockServer := startMockServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		"/owner/repo/" + pinnedSHA + "/JSON.min.lua": {Body: "return {}", Code: http.StatusOK},
+	})
+	sourceURL := mockServer.URL + "/owner/repo/" + pinnedSHA + "/JSON.min.lua"
+	tempDir := setupAddTestEnvironment(t, "[package]\nname = \"names\"\nversion = \"0.1.0\"\n")
+
+	require.NoError(t, runAddCommand(t, tempDir, sourceURL))
+	proj, err := config.LoadProjectToml(tempDir)
+	require.NoError(t, err)
+	assert.Contains(t, proj.Dependencies, "json-min", "inferred names are made valid")
+	assert.Equal(t, "src/lib/JSON.min.lua", proj.Dependencies["json-min"].Path, "the file keeps its upstream name")
+
+	require.NoError(t, runAddCommand(t, tempDir, "-n", "MyJSON", sourceURL))
+	proj, err = config.LoadProjectToml(tempDir)
+	require.NoError(t, err)
+	assert.Contains(t, proj.Dependencies, "myjson", "names given with -n are lowercased")
+	assert.FileExists(t, filepath.Join(tempDir, "src", "lib", "myjson.lua"))
+
+	err = runAddCommand(t, tempDir, "-n", "my json", sourceURL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "may only contain lowercase letters")
+	assert.Contains(t, err.Error(), "Try -n my-json")
+}
+
+func TestAddCommand_MatchesExistingNamesCaseInsensitively(t *testing.T) {
+	pinnedSHA := "aaaabbbbccccddddeeeeffff0000111122225555"
+	mockServer := startMockServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		"/owner/repo/" + pinnedSHA + "/lib.lua": {Body: "return {}", Code: http.StatusOK},
+	})
+	sourceURL := mockServer.URL + "/owner/repo/" + pinnedSHA + "/lib.lua"
+	tempDir := setupAddTestEnvironment(t, "[package]\nname = \"legacy\"\nversion = \"0.1.0\"\n\n[dependencies]\nMyLib = { source = \"s\", path = \"src/lib/MyLib.lua\" }\n")
+
+	err := runAddCommand(t, tempDir, "-n", "MyLib", sourceURL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "dependency 'MyLib' already exists")
+
+	require.NoError(t, runAddCommand(t, tempDir, "--force", "-n", "mylib", sourceURL))
+	proj, err := config.LoadProjectToml(tempDir)
+	require.NoError(t, err)
+	assert.Contains(t, proj.Dependencies, "mylib")
+	assert.NotContains(t, proj.Dependencies, "MyLib", "the replaced entry does not stay behind under its old case")
+}
+
+func TestAddCommand_ExtensionlessSource(t *testing.T) {
+	pinnedSHA := "abcdefabcdefabcdefabcdefabcdefabcdef0123"
+	mockServer := startMockServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		"/owner/repo/" + pinnedSHA + "/bin/configure": {Body: "#!/bin/sh", Code: http.StatusOK},
+		"/owner/repo/" + pinnedSHA + "/tool-1.2":      {Body: "return {}", Code: http.StatusOK},
+	})
+	configureURL := mockServer.URL + "/owner/repo/" + pinnedSHA + "/bin/configure"
+	toolURL := mockServer.URL + "/owner/repo/" + pinnedSHA + "/tool-1.2"
+	tempDir := setupAddTestEnvironment(t, "[package]\nname = \"noext\"\nversion = \"0.1.0\"\n")
+
+	require.NoError(t, runAddCommand(t, tempDir, configureURL))
+	assert.FileExists(t, filepath.Join(tempDir, "src", "lib", "configure"), "without a default extension the name is kept")
+	require.NoError(t, runAddCommand(t, tempDir, "-n", "conf", configureURL))
+	assert.FileExists(t, filepath.Join(tempDir, "src", "lib", "conf"))
+
+	require.NoError(t, runAddCommand(t, tempDir, "--ext", "lua", toolURL))
+	projCfg := readProjectToml(t, filepath.Join(tempDir, config.ProjectTomlName))
+	assert.Equal(t, "src/lib/tool-1.2.lua", projCfg.Dependencies["tool-1-2"].Path, "a version suffix is not an extension")
+
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, config.ProjectTomlName),
+		[]byte("[package]\nname = \"noext\"\nversion = \"0.1.0\"\n\n[vendor]\ndefault_ext = \".lua\"\n"), 0644))
+	require.NoError(t, runAddCommand(t, tempDir, "-n", "setup", configureURL))
+	assert.FileExists(t, filepath.Join(tempDir, "src", "lib", "setup.lua"), "[vendor] default_ext applies")
+	require.NoError(t, runAddCommand(t, tempDir, "--ext", "none", "-n", "plain", configureURL))
+	assert.FileExists(t, filepath.Join(tempDir, "src", "lib", "plain"), "--ext none overrides default_ext")
+
+	err := runAddCommand(t, tempDir, "--ext", "l/ua", configureURL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid default extension")
+}
+
+func TestAddCommand_Mode(t *testing.T) {
+	pinnedSHA := "abcdefabcdefabcdefabcdefabcdefabcdefabcd"
+	mockServer := startMockServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		"/owner/repo/" + pinnedSHA + "/run.lua": {Body: "#!/usr/bin/env lua", Code: http.StatusOK},
+	})
+	sourceURL := mockServer.URL + "/owner/repo/" + pinnedSHA + "/run.lua"
+	tempDir := setupAddTestEnvironment(t, "[package]\nname = \"modes\"\nversion = \"0.1.0\"\n")
+
+	err := runAddCommand(t, tempDir, "--mode", "rwx", sourceURL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid file mode")
+
+	require.NoError(t, runAddCommand(t, tempDir, "--mode", "0755", sourceURL))
+	projCfg := readProjectToml(t, filepath.Join(tempDir, config.ProjectTomlName))
+	assert.Equal(t, "0755", projCfg.Dependencies["run"].Mode)
+
+	if runtime.GOOS != "windows" {
+		info, statErr := os.Stat(filepath.Join(tempDir, "src", "lib", "run.lua"))
+		require.NoError(t, statErr)
+		assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	}
+}
+
+func TestAddCommand_Transform(t *testing.T) {
+	pinnedSHA := "abcdefabcdefabcdefabcdefabcdefabcdefabcd"
+	upstream := "-- A small library\nlocal M = {} -- module table\n\nreturn M\n"
+	mockServer := startMockServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		"/owner/repo/" + pinnedSHA + "/small.lua": {Body: upstream, Code: http.StatusOK},
+	})
+	sourceURL := mockServer.URL + "/owner/repo/" + pinnedSHA + "/small.lua"
+	tempDir := setupAddTestEnvironment(t, "[package]\nname = \"transforms\"\nversion = \"0.1.0\"\n")
+
+	err := runAddCommand(t, tempDir, "--transform", "minify", sourceURL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown transform 'minify'")
+
+	err = runAddCommand(t, tempDir, "--transform", "strip-comments", "--lock-only", sourceURL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--transform cannot be used with --lock-only")
+
+	require.NoError(t, runAddCommand(t, tempDir, "--transform", "strip-comments", sourceURL))
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "src", "lib", "small.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, "local M = {}\nreturn M\n", string(content))
+
+	projCfg := readProjectToml(t, filepath.Join(tempDir, config.ProjectTomlName))
+	assert.Equal(t, "strip-comments", projCfg.Dependencies["small"].Transform)
+
+	lf, err := lockfile.Load(tempDir)
+	require.NoError(t, err)
+	entry := lf.Package["small"]
+	assert.Equal(t, "strip-comments", entry.Transform)
+	assert.Equal(t, fmt.Sprintf("sha256:%x", sha256.Sum256(content)), entry.TransformedHash)
+	assert.Equal(t, "commit:"+pinnedSHA, entry.Hash, "the hash still identifies the upstream content")
+}
+
+func TestAddCommand_Budget(t *testing.T) {
+	pinnedSHA := "0123456789abcdef0123456789abcdef01234567"
+	mockServer := startMockServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		"/owner/repo/" + pinnedSHA + "/mylib.lua": {Body: "return 'remote'", Code: http.StatusOK},
+	})
+	sourceURL := mockServer.URL + "/owner/repo/" + pinnedSHA + "/mylib.lua"
+
+	t.Run("an exceeded budget fails and cleans up", func(t *testing.T) {
+		tempDir := setupAddTestEnvironment(t, "[package]\nname = \"tiny\"\nversion = \"0.1.0\"\n\n[budget]\nmax_file_size = \"10B\"\n")
+		err := runAddCommand(t, tempDir, sourceURL)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "'mylib' is 15 B, over max_file_size 10B")
+
+		assert.NoFileExists(t, filepath.Join(tempDir, "src", "lib", "mylib.lua"))
+		projCfg := readProjectToml(t, filepath.Join(tempDir, config.ProjectTomlName))
+		assert.Empty(t, projCfg.Dependencies)
+	})
+
+	t.Run("warn_only adds the dependency with a warning", func(t *testing.T) {
+		t.Cleanup(warnings.Reset)
+		tempDir := setupAddTestEnvironment(t, "[package]\nname = \"tiny\"\nversion = \"0.1.0\"\n\n[budget]\nmax_total_size = \"10B\"\nwarn_only = true\n")
+		require.NoError(t, runAddCommand(t, tempDir, sourceURL))
+
+		projCfg := readProjectToml(t, filepath.Join(tempDir, config.ProjectTomlName))
+		assert.Contains(t, projCfg.Dependencies, "mylib")
+		assert.Equal(t, []string{"over budget: dependency files total 15 B, over max_total_size 10B"}, warnings.Reported())
+	})
+}
+
+// TestAddCommand_FromFile verifies that every dependency of a list is added with a single write of
+// project.toml and the lockfile, and that a failing entry leaves both untouched.
+func TestAddCommand_FromFile(t *testing.T) {
+	initialTomlContent := `
+[package]
+name = "from-file-project"
+version = "0.1.0"
+`
+	tempDir := setupAddTestEnvironment(t, initialTomlContent)
+	mockServer := startMockServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		"/owner/repo/main/json.lua": {Body: "return 'json'", Code: http.StatusOK},
+		"/owner/repo/v1/class.lua":  {Body: "return 'class'", Code: http.StatusOK},
+	})
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	list := fmt.Sprintf("# libraries from the README\n%[1]s/owner/repo/main/json.lua\n\n%[1]s/owner/repo/v1/class.lua middleclass vendor\n", mockServer.URL)
+	listPath := filepath.Join(t.TempDir(), "deps.txt")
+	require.NoError(t, os.WriteFile(listPath, []byte(list), 0644))
+
+	require.NoError(t, runAddCommand(t, tempDir, "--from-file", listPath))
+
+	projCfg := readProjectToml(t, filepath.Join(tempDir, config.ProjectTomlName))
+	require.Len(t, projCfg.Dependencies, 2)
+	assert.Equal(t, "src/lib/json.lua", projCfg.Dependencies["json"].Path)
+	assert.Equal(t, "github:owner/repo/class.lua@v1", projCfg.Dependencies["middleclass"].Source)
+	assert.Equal(t, "vendor/middleclass.lua", projCfg.Dependencies["middleclass"].Path)
+	lockCfg := readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName))
+	assert.Len(t, lockCfg.Package, 2)
+	assert.FileExists(t, filepath.Join(tempDir, "vendor", "middleclass.lua"))
+
+	jsonList := fmt.Sprintf(`[{"source": "%[1]s/owner/repo/v1/class.lua", "name": "class"}, {"source": "%[1]s/owner/repo/main/missing.lua"}]`, mockServer.URL)
+	jsonPath := filepath.Join(t.TempDir(), "deps.json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(jsonList), 0644))
+	before, err := os.ReadFile(filepath.Join(tempDir, config.ProjectTomlName))
+	require.NoError(t, err)
+
+	require.Error(t, runAddCommand(t, tempDir, "--from-file", jsonPath))
+	after, err := os.ReadFile(filepath.Join(tempDir, config.ProjectTomlName))
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after), "a failed list must not change project.toml")
+	assert.NoFileExists(t, filepath.Join(tempDir, "src", "lib", "class.lua"), "files saved before the failure are removed")
+}
+
+func TestAddCommand_LocalFile(t *testing.T) {
+	tempDir := setupAddTestEnvironment(t, "[package]\nname = \"local\"\nversion = \"0.1.0\"\n")
+	siblingDir := filepath.Join(tempDir, "..", "shared-"+filepath.Base(tempDir))
+	require.NoError(t, os.MkdirAll(siblingDir, 0755))
+	t.Cleanup(func() { _ = os.RemoveAll(siblingDir) })
+	require.NoError(t, os.WriteFile(filepath.Join(siblingDir, "util.lua"), []byte("return 'util'"), 0644))
+	relPath := "../" + filepath.Base(siblingDir) + "/util.lua"
+
+	require.NoError(t, runAddCommand(t, tempDir, relPath))
+	content, err := os.ReadFile(filepath.Join(tempDir, "src", "lib", "util.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, "return 'util'", string(content))
+
+	projCfg := readProjectToml(t, filepath.Join(tempDir, config.ProjectTomlName))
+	assert.Equal(t, "file:"+relPath, projCfg.Dependencies["util"].Source)
+	lockCfg := readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName))
+	assert.Equal(t, "file:"+relPath, lockCfg.Package["util"].Source)
+	assert.True(t, strings.HasPrefix(lockCfg.Package["util"].Hash, "sha256:"), "local files are locked by content hash")
+}
+
+func TestAddCommand_Directory(t *testing.T) {
+	tempDir := setupAddTestEnvironment(t, "[package]\nname = \"dir\"\nversion = \"0.1.0\"\n")
+	sha := "0123456789abcdef0123456789abcdef01234567"
+	mockServer := startMockServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		"/repos/ghowner/ghrepo/contents/lib?ref=" + sha: {
+			Body: `[{"type": "file", "path": "lib/init.lua"}, {"type": "dir", "path": "lib/util"}, {"type": "symlink", "path": "lib/link.lua"}]`,
+			Code: http.StatusOK,
+		},
+		"/repos/ghowner/ghrepo/contents/lib/util?ref=" + sha: {Body: `[{"type": "file", "path": "lib/util/str.lua"}]`, Code: http.StatusOK},
+		"/ghowner/ghrepo/" + sha + "/lib/init.lua":           {Body: "return require('lib.util.str')\n", Code: http.StatusOK},
+		"/ghowner/ghrepo/" + sha + "/lib/util/str.lua":       {Body: "return {}\n", Code: http.StatusOK},
+	})
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	err := runAddCommand(t, tempDir, "--transform", "strip-comments", "github:ghowner/ghrepo/lib/@"+sha)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--transform cannot be used with a directory source")
+
+	require.NoError(t, runAddCommand(t, tempDir, "-d", "vendor", "github:ghowner/ghrepo/lib/@"+sha))
+	content, err := os.ReadFile(filepath.Join(tempDir, "vendor", "lib", "util", "str.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, "return {}\n", string(content))
+	assert.FileExists(t, filepath.Join(tempDir, "vendor", "lib", "init.lua"))
+
+	projCfg := readProjectToml(t, filepath.Join(tempDir, config.ProjectTomlName))
+	dep := projCfg.Dependencies["lib"]
+	assert.Equal(t, "github:ghowner/ghrepo/lib/@"+sha, dep.Source)
+	assert.Equal(t, "vendor/lib", dep.Path)
+	assert.Equal(t, []string{"init.lua", "util/str.lua"}, dep.Files, "symbolic links are skipped")
+
+	lf, err := lockfile.Load(tempDir)
+	require.NoError(t, err)
+	entry := lf.Package["lib"]
+	assert.Equal(t, "commit:"+sha, entry.Hash)
+	assert.Equal(t, "vendor/lib", entry.Path)
+	strHash, err := hasher.CalculateSHA256([]byte("return {}\n"))
+	require.NoError(t, err)
+	assert.Equal(t, strHash, entry.Files["util/str.lua"])
+	assert.Len(t, entry.Files, 2)
+}
+
+// TestAddCommand_Archive checks that a zip archive is added as one dependency of the files its
+// extract specs select, locked by the hash of the archive.
+func TestAddCommand_Archive(t *testing.T) {
+	tempDir := setupAddTestEnvironment(t, "[package]\nname = \"archive\"\nversion = \"0.1.0\"\n")
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range map[string]string{
+		"pkg-1.0/src/pkg.lua":  "return {}\n",
+		"pkg-1.0/src/util.lua": "return 'util'\n",
+		"pkg-1.0/README.md":    "# pkg\n",
+		"pkg-1.0/LICENSE":      "MIT\n",
+	} {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "pkg.zip"), buf.Bytes(), 0644))
+
+	err := runAddCommand(t, tempDir, "--transform", "strip-comments", "file:pkg.zip")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--transform cannot be used with an archive source")
+	err = runAddCommand(t, tempDir, "--extract", "lib/", "file:pkg-1.0/src/pkg.lua")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--extract needs an archive source")
+	err = runAddCommand(t, tempDir, "--extract", "pkg-1.0/*.txt", "file:pkg.zip")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no file in the archive matches 'pkg-1.0/*.txt'")
+
+	require.NoError(t, runAddCommand(t, tempDir, "-d", "vendor", "--extract", "pkg-1.0/src/", "--extract", "pkg-1.0/LICENSE=doc", "file:pkg.zip"))
+	content, err := os.ReadFile(filepath.Join(tempDir, "vendor", "pkg", "util.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, "return 'util'\n", string(content))
+	assert.FileExists(t, filepath.Join(tempDir, "vendor", "pkg", "doc", "LICENSE"))
+	assert.NoFileExists(t, filepath.Join(tempDir, "vendor", "pkg", "README.md"))
+
+	projCfg := readProjectToml(t, filepath.Join(tempDir, config.ProjectTomlName))
+	dep := projCfg.Dependencies["pkg"]
+	assert.Equal(t, "vendor/pkg", dep.Path)
+	assert.Equal(t, []string{"doc/LICENSE", "pkg.lua", "util.lua"}, dep.Files)
+	assert.Equal(t, []string{"pkg-1.0/src/", "pkg-1.0/LICENSE=doc"}, dep.Extract)
+
+	lf, err := lockfile.Load(tempDir)
+	require.NoError(t, err)
+	entry := lf.Package["pkg"]
+	assert.Equal(t, fmt.Sprintf("sha256:%x", sha256.Sum256(buf.Bytes())), entry.Hash)
+	assert.Equal(t, fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("return 'util'\n"))), entry.Files["util.lua"])
+	assert.Len(t, entry.Files, 3)
+	assert.Equal(t, dep.Extract, entry.Extract)
+}
+
+// TestAddCommand_RefusesToOverwriteProjectFiles checks that add never saves a dependency over a
+// file almd did not install unless --allow-overwrite is given.
+func TestAddCommand_RefusesToOverwriteProjectFiles(t *testing.T) {
+	tempDir := setupAddTestEnvironment(t, "[package]\nname = \"overwrite\"\nversion = \"0.1.0\"\n")
+	mainPath := filepath.Join(tempDir, "src", "main.lua")
+	require.NoError(t, os.MkdirAll(filepath.Dir(mainPath), 0755))
+	require.NoError(t, os.WriteFile(mainPath, []byte("print('my game')\n"), 0644))
+
+	sha := "0123456789abcdef0123456789abcdef01234567"
+	mockServer := startMockServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		"/ghowner/ghrepo/" + sha + "/main.lua": {Body: "return {}\n", Code: http.StatusOK},
+	})
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	err := runAddCommand(t, tempDir, "-d", "src", "github:ghowner/ghrepo/main.lua@"+sha)
+	require.Error(t, err)
+	assert.Equal(t, exitcode.Usage, err.(cli.ExitCoder).ExitCode())
+	assert.Contains(t, err.Error(), "refusing to overwrite 'src/main.lua'")
+	content, readErr := os.ReadFile(mainPath)
+	require.NoError(t, readErr)
+	assert.Equal(t, "print('my game')\n", string(content), "the project's file is left alone")
+	assert.Empty(t, readProjectToml(t, filepath.Join(tempDir, config.ProjectTomlName)).Dependencies)
+
+	require.NoError(t, runAddCommand(t, tempDir, "-d", "src", "--allow-overwrite", "github:ghowner/ghrepo/main.lua@"+sha))
+	content, readErr = os.ReadFile(mainPath)
+	require.NoError(t, readErr)
+	assert.Contains(t, string(content), "return {}")
+
+	// Once almd installed the file, replacing the dependency needs no flag.
+	require.NoError(t, runAddCommand(t, tempDir, "-d", "src", "--force", "github:ghowner/ghrepo/main.lua@"+sha))
+}
@@ -0,0 +1,100 @@
+package add
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/nightconcept/almandine/internal/core/archive"
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+	"github.com/nightconcept/almandine/internal/core/hasher"
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/urfave/cli/v2"
+)
+
+// addArchive adds a .tar.gz or .zip source as one dependency: the files the --extract specs
+// select, or every file below the archive's top-level directory, are extracted into
+// <targetDir>/<name>. project.toml records the specs and the extracted files; the lockfile
+// records the hash of the archive and of each extracted file.
+func addArchive(cCtx *cli.Context, projectRoot, targetDir, customName, mode, transformName, localFlag string, noSave bool, parsedInfo *source.ParsedSourceInfo, startTime time.Time) (err error) {
+	if flagErr := checkMultiFileFlags(cCtx, "an archive", transformName, localFlag); flagErr != nil {
+		return flagErr
+	}
+	specs := cCtx.StringSlice("extract")
+	if _, specErr := archive.ParseSpecs(specs); specErr != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", specErr), 1)
+	}
+	named := *parsedInfo
+	named.SuggestedFilename = archive.TrimExt(parsedInfo.SuggestedFilename)
+	name, relDir, labels, targetErr := multiFileTarget(cCtx, &named, targetDir, customName)
+	if targetErr != nil {
+		return targetErr
+	}
+
+	previous, skip, existingErr := checkExistingDependency(projectRoot, name, cCtx.Bool("force"), cCtx.Bool("if-missing"))
+	if existingErr != nil || skip {
+		return existingErr
+	}
+
+	content, downloadErr := downloadDependency(parsedInfo.RawURL, false)
+	if downloadErr != nil {
+		return cli.Exit(fmt.Sprintf("Error downloading from '%s': %v", parsedInfo.RawURL, downloadErr), exitcode.Download)
+	}
+	extracted, files, hashes, extractErr := extractArchive(parsedInfo, content, specs)
+	if extractErr != nil {
+		return cli.Exit(fmt.Sprintf("Error extracting '%s': %v", parsedInfo.RawURL, extractErr), exitcode.Usage)
+	}
+	if overwriteErr := checkOverwrite(projectRoot, cCtx.Bool("allow-overwrite"), project.Dependency{Path: relDir, Files: files}.FilePaths()...); overwriteErr != nil {
+		return overwriteErr
+	}
+
+	written, writeErr := writeDirectoryFiles(projectRoot, relDir, mode, files, extracted)
+	defer func() {
+		for _, fullPath := range written {
+			performCleanupOnPotentialError(err, true, fullPath, cCtx)
+		}
+	}()
+	if writeErr != nil {
+		return cli.Exit(fmt.Sprintf("Error saving archive files to '%s': %v. Attempting to clean up.", relDir, writeErr), 1)
+	}
+
+	if !noSave {
+		archiveHash, hashErr := hasher.CalculateSHA256(content)
+		if hashErr != nil {
+			return cli.Exit(fmt.Sprintf("Error hashing '%s': %v", parsedInfo.RawURL, hashErr), exitcode.Usage)
+		}
+		dep := project.Dependency{Source: parsedInfo.CanonicalURL, Path: relDir, Mode: mode, Labels: labels, Files: files, Extract: specs}
+		entry := lockfile.PackageEntry{Source: parsedInfo.RawURL, Path: relDir, Hash: archiveHash, Files: hashes, Extract: specs}
+		if err = saveAddedDirectory(projectRoot, name, dep, entry, previous); err != nil {
+			return err
+		}
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "Extracted %d files to '%s'.\n", len(files), relDir)
+	printAddSummary(name, parsedInfo, noSave, "", startTime)
+	return nil
+}
+
+// extractArchive extracts the files specs select from an archive source's content and returns
+// them by the path they are extracted to, with those paths sorted and the hash of each file.
+func extractArchive(parsedInfo *source.ParsedSourceInfo, content []byte, specs []string) (map[string][]byte, []string, map[string]string, error) {
+	extracted, err := archive.Extract(parsedInfo.ArchiveFormat(), content, specs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	files := make([]string, 0, len(extracted))
+	hashes := make(map[string]string, len(extracted))
+	for file, data := range extracted {
+		hash, hashErr := hasher.CalculateSHA256(data)
+		if hashErr != nil {
+			return nil, nil, nil, fmt.Errorf("hashing '%s': %w", file, hashErr)
+		}
+		files = append(files, file)
+		hashes[file] = hash
+	}
+	slices.Sort(files)
+	return extracted, files, hashes, nil
+}
@@ -0,0 +1,202 @@
+package add
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+	"github.com/nightconcept/almandine/internal/core/filemode"
+	"github.com/nightconcept/almandine/internal/core/hasher"
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/safepath"
+	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/urfave/cli/v2"
+)
+
+// addDirectory adds a source that names a directory, such as a GitHub tree URL, as one
+// dependency: every file below it is downloaded at a single commit into <targetDir>/<name>, and
+// project.toml and the lockfile list the files so that install and remove treat them as a set.
+func addDirectory(cCtx *cli.Context, projectRoot, targetDir, customName, mode, transformName, localFlag string, noSave bool, parsedInfo *source.ParsedSourceInfo, startTime time.Time) (err error) {
+	if flagErr := checkMultiFileFlags(cCtx, "a directory", transformName, localFlag); flagErr != nil {
+		return flagErr
+	}
+	name, relDir, labels, targetErr := multiFileTarget(cCtx, parsedInfo, targetDir, customName)
+	if targetErr != nil {
+		return targetErr
+	}
+
+	previous, skip, existingErr := checkExistingDependency(projectRoot, name, cCtx.Bool("force"), cCtx.Bool("if-missing"))
+	if existingErr != nil || skip {
+		return existingErr
+	}
+
+	pinned, files, listErr := listDirectoryAtCommit(parsedInfo)
+	if listErr != nil {
+		return cli.Exit(fmt.Sprintf("Error listing directory '%s': %v", parsedInfo.CanonicalURL, listErr), exitcode.Resolution)
+	}
+	if overwriteErr := checkOverwrite(projectRoot, cCtx.Bool("allow-overwrite"), project.Dependency{Path: relDir, Files: files}.FilePaths()...); overwriteErr != nil {
+		return overwriteErr
+	}
+	contents, hashes, downloadErr := downloadDirectory(pinned, files)
+	if downloadErr != nil {
+		return cli.Exit(fmt.Sprintf("Error downloading directory '%s': %v", parsedInfo.CanonicalURL, downloadErr), exitcode.Download)
+	}
+
+	written, writeErr := writeDirectoryFiles(projectRoot, relDir, mode, files, contents)
+	defer func() {
+		for _, fullPath := range written {
+			performCleanupOnPotentialError(err, true, fullPath, cCtx)
+		}
+	}()
+	if writeErr != nil {
+		return cli.Exit(fmt.Sprintf("Error saving directory '%s': %v. Attempting to clean up.", relDir, writeErr), 1)
+	}
+
+	if !noSave {
+		dep := project.Dependency{Source: parsedInfo.CanonicalURL, Path: relDir, Mode: mode, Labels: labels, Files: files}
+		entry := lockfile.PackageEntry{Source: parsedInfo.RawURL, Path: relDir, Hash: "commit:" + pinned.Ref, Files: hashes}
+		if err = saveAddedDirectory(projectRoot, name, dep, entry, previous); err != nil {
+			return err
+		}
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "Saved %d files to '%s' at commit %s.\n", len(files), relDir, pinned.Ref)
+	printAddSummary(name, parsedInfo, noSave, "", startTime)
+	return nil
+}
+
+// addFilesAction adds a source whose files are saved as one dependency below targetDir.
+type addFilesAction func(cCtx *cli.Context, projectRoot, targetDir, customName, mode, transformName, localFlag string, noSave bool, parsedInfo *source.ParsedSourceInfo, startTime time.Time) error
+
+// multiFileAdd returns the action for a source whose files are added as one dependency, a
+// directory or an archive, or nil for a source of a single file.
+func multiFileAdd(cCtx *cli.Context, parsedInfo *source.ParsedSourceInfo) addFilesAction {
+	switch {
+	case parsedInfo.ArchiveFormat() != "":
+		return addArchive
+	case cCtx.IsSet("extract"):
+		return rejectExtract
+	case parsedInfo.Dir:
+		return addDirectory
+	}
+	return nil
+}
+
+// rejectExtract fails the add of a source other than an archive given --extract.
+func rejectExtract(*cli.Context, string, string, string, string, string, string, bool, *source.ParsedSourceInfo, time.Time) error {
+	return cli.Exit("Error: --extract needs an archive source: a URL or local file ending in .tar.gz, .tgz or .zip", 1)
+}
+
+// checkMultiFileFlags rejects the flags that have no meaning for a directory or an archive (kind),
+// whose files are always downloaded and copied as they are.
+func checkMultiFileFlags(cCtx *cli.Context, kind, transformName, localFlag string) error {
+	switch {
+	case transformName != "":
+		return cli.Exit(fmt.Sprintf("Error: --transform cannot be used with %s source", kind), 1)
+	case localFlag != "":
+		return cli.Exit(fmt.Sprintf("Error: %s cannot be used with %s source, which is always downloaded", localFlag, kind), 1)
+	case cCtx.IsSet("ext"):
+		return cli.Exit(fmt.Sprintf("Error: --ext cannot be used with %s source, whose files keep their names", kind), 1)
+	}
+	return nil
+}
+
+// multiFileTarget determines the name and labels of a dependency of several files and the
+// directory below targetDir it is saved to.
+func multiFileTarget(cCtx *cli.Context, parsedInfo *source.ParsedSourceInfo, targetDir, customName string) (name, relDir string, labels []string, err error) {
+	name, dirOnDisk, namesErr := determineFileNames(parsedInfo, customName, "")
+	if namesErr != nil {
+		return "", "", nil, cli.Exit(fmt.Sprintf("Error determining directory name: %v", namesErr), 1)
+	}
+	labels = cCtx.StringSlice("label")
+	if labelErr := project.ValidateLabels(name, labels); labelErr != nil {
+		return "", "", nil, cli.Exit(fmt.Sprintf("Error: %v", labelErr), 1)
+	}
+	relDir = filepath.ToSlash(filepath.Join(targetDir, dirOnDisk))
+	if pathErr := safepath.ValidateRelPath(relDir); pathErr != nil {
+		return "", "", nil, cli.Exit(fmt.Sprintf("Error: %v", pathErr), 1)
+	}
+	return name, relDir, labels, nil
+}
+
+// listDirectoryAtCommit resolves the directory source's ref to a commit and lists the directory at
+// that commit, so that every file is taken from the same revision.
+func listDirectoryAtCommit(parsedInfo *source.ParsedSourceInfo) (*source.ParsedSourceInfo, []string, error) {
+	commit := parsedInfo.Ref
+	if !isPinnedToCommit(parsedInfo) {
+		resolved, err := source.ResolveRef(parsedInfo)
+		if err != nil {
+			return nil, nil, fmt.Errorf("resolving ref '%s': %w", parsedInfo.Ref, err)
+		}
+		commit = resolved
+	}
+	pinned, err := parsedInfo.AtCommit(commit)
+	if err != nil {
+		return nil, nil, err
+	}
+	files, err := source.ListDirectory(pinned)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pinned, files, nil
+}
+
+// downloadDirectory downloads every file of the directory pinned names before anything is
+// written, and returns their contents with the hashes the lockfile records for them.
+func downloadDirectory(pinned *source.ParsedSourceInfo, files []string) (map[string][]byte, map[string]string, error) {
+	contents := make(map[string][]byte, len(files))
+	hashes := make(map[string]string, len(files))
+	for _, file := range files {
+		content, err := downloadDependency(pinned.FileRawURL(file), true)
+		if err != nil {
+			return nil, nil, err
+		}
+		hash, err := hasher.CalculateSHA256(content)
+		if err != nil {
+			return nil, nil, fmt.Errorf("hashing '%s': %w", file, err)
+		}
+		contents[file], hashes[file] = content, hash
+	}
+	return contents, hashes, nil
+}
+
+// writeDirectoryFiles writes each file below relDir and returns the full paths it wrote, which
+// are cleaned up if the add fails.
+func writeDirectoryFiles(projectRoot, relDir, mode string, files []string, contents map[string][]byte) ([]string, error) {
+	var written []string
+	for _, file := range files {
+		fullPath, _, err := saveDependencyFile(projectRoot, relDir, filepath.FromSlash(file), mode, contents[file], filemode.GlobalSettings())
+		if fullPath != "" {
+			written = append(written, fullPath)
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// saveAddedDirectory records a directory dependency in project.toml and the lockfile, as
+// saveAddedDependency does for a single file.
+func saveAddedDirectory(projectRoot, name string, dep project.Dependency, entry lockfile.PackageEntry, previous *project.Dependency) error {
+	paths := dep.FilePaths()
+	if err := enforceBudget(projectRoot, name, dep); err != nil {
+		return err
+	}
+	if len(dep.Labels) == 0 && previous != nil {
+		dep.Labels = previous.Labels
+	}
+	warnAdvisory(name, dep.Source, entry.Hash)
+	if err := updateProjectManifest(projectRoot, name, dep); err != nil {
+		return cli.Exit(fmt.Sprintf("Error updating project manifest: %v. The files in '%s' are being cleaned up.", err, dep.Path), 1)
+	}
+	if err := updateLockfile(projectRoot, name, entry); err != nil {
+		return cli.Exit(fmt.Sprintf("Error updating lockfile: %v. The files in '%s' are being cleaned up.", err, dep.Path), 1)
+	}
+	removeReplacedFile(projectRoot, previous, paths...)
+	recordInstalled(projectRoot, paths...)
+	return nil
+}
@@ -0,0 +1,331 @@
+package add
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/budget"
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+	"github.com/nightconcept/almandine/internal/core/filemode"
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+	"github.com/nightconcept/almandine/internal/core/logger"
+	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/nightconcept/almandine/internal/core/warnings"
+)
+
+// fileEntry is one dependency listed in an --from-file list.
+type fileEntry struct {
+	Source    string `toml:"source" json:"source"`
+	Name      string `toml:"name,omitempty" json:"name,omitempty"`
+	Directory string `toml:"directory,omitempty" json:"directory,omitempty"`
+}
+
+// readFileEntries reads the dependencies listed in path. A ".toml" file holds [[dependency]]
+// tables and a ".json" file an array of objects, both with source, name and directory keys. Any
+// other file has one "<source> [name] [directory]" per line, where "-" keeps the default name,
+// and blank lines and lines starting with "#" are skipped.
+func readFileEntries(path string) ([]fileEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []fileEntry
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		var list struct {
+			Dependency []fileEntry `toml:"dependency"`
+		}
+		if _, err := toml.Decode(string(data), &list); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+		entries = list.Dependency
+	case ".json":
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+	default:
+		entries, err = parseTextEntries(data)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+	}
+	for i, entry := range entries {
+		if entry.Source == "" {
+			return nil, fmt.Errorf("%s: entry %d has no source", path, i+1)
+		}
+	}
+	return entries, nil
+}
+
+// parseTextEntries parses the line-based --from-file format.
+func parseTextEntries(data []byte) ([]fileEntry, error) {
+	var entries []fileEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) > 3 {
+			return nil, fmt.Errorf("line %d: expected '<source> [name] [directory]', got %d fields", lineNo, len(fields))
+		}
+		entry := fileEntry{Source: fields[0]}
+		if len(fields) > 1 && fields[1] != "-" {
+			entry.Name = fields[1]
+		}
+		if len(fields) > 2 {
+			entry.Directory = fields[2]
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// fileAddOptions are the add flags that apply to every dependency of an --from-file list.
+type fileAddOptions struct {
+	targetDir, defaultExt, mode, transformName string
+	labels                                     []string
+	force, ifMissing, allowOverwrite           bool
+}
+
+// addFromFile adds every dependency listed in the --from-file list like separate adds would, but
+// writes project.toml and the lockfile once, after all of them were downloaded. When one fails,
+// the files already saved are removed and neither file is changed.
+func addFromFile(cCtx *cli.Context, projectRoot string) (err error) {
+	startTime := time.Now()
+	opts, err := parseFromFileFlags(cCtx)
+	if err != nil {
+		return err
+	}
+	entries, readErr := readFileEntries(cCtx.String("from-file"))
+	if readErr != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", readErr), 1)
+	}
+	proj, lf, loadErr := loadManifestForFileAdd(projectRoot)
+	if loadErr != nil {
+		return loadErr
+	}
+
+	var writtenFiles []string
+	replaced := make(map[string]project.Dependency)
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, path := range writtenFiles {
+			if removeErr := filemode.Remove(path); removeErr != nil {
+				warnings.Printf("Failed to clean up downloaded file '%s' during error handling: %v", path, removeErr)
+			}
+		}
+	}()
+
+	var added []string
+	for _, entry := range entries {
+		name, fullPath, previous, addErr := addFileEntry(projectRoot, entry, opts, proj, lf)
+		if fullPath != "" {
+			writtenFiles = append(writtenFiles, fullPath)
+		}
+		if addErr != nil {
+			return addErr
+		}
+		if name == "" {
+			continue // Skipped with --if-missing
+		}
+		if previous != nil {
+			replaced[name] = *previous
+		}
+		added = append(added, name)
+	}
+	if len(added) == 0 {
+		fmt.Println("Nothing to add.")
+		return nil
+	}
+
+	if saveErr := saveFileAdd(projectRoot, proj, lf); saveErr != nil {
+		return saveErr
+	}
+	finishFileAdd(projectRoot, proj, added, replaced)
+
+	printFromLockSummary(added, lf, startTime)
+	return nil
+}
+
+// saveFileAdd checks the project's [budget] with the listed dependencies and writes project.toml
+// and the lockfile.
+func saveFileAdd(projectRoot string, proj *project.Project, lf *lockfile.Lockfile) error {
+	if proj.Budget != nil {
+		if err := budget.Enforce(projectRoot, proj.Budget, proj.Dependencies); err != nil {
+			return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+		}
+	}
+	if err := config.WriteProjectToml(projectRoot, proj); err != nil {
+		return cli.Exit(fmt.Sprintf("Error writing %s: %v", config.ManifestName(), err), 1)
+	}
+	if err := lockfile.Save(projectRoot, lf); err != nil {
+		return cli.Exit(fmt.Sprintf("Error saving %s: %v. %s was already updated.", lockfile.LockfileName, err, config.ManifestName()), 1)
+	}
+	return nil
+}
+
+// finishFileAdd removes the files of the dependencies the list replaced and records the added
+// ones in the inventory of files almd installed.
+func finishFileAdd(projectRoot string, proj *project.Project, added []string, replaced map[string]project.Dependency) {
+	installed := make([]string, 0, len(added))
+	for _, name := range added {
+		if previous, ok := replaced[name]; ok {
+			removeReplacedFile(projectRoot, &previous, proj.Dependencies[name].Path)
+		}
+		installed = append(installed, proj.Dependencies[name].Path)
+	}
+	recordInstalled(projectRoot, installed...)
+}
+
+// parseFromFileFlags collects the flags that apply to each listed dependency and rejects those
+// that only make sense for a single one.
+func parseFromFileFlags(cCtx *cli.Context) (fileAddOptions, error) {
+	if cCtx.NArg() > 0 {
+		return fileAddOptions{}, cli.Exit("Error: --from-file cannot be combined with a <source_url> argument", 1)
+	}
+	for _, name := range []string{"name", "no-save", "lock-only", "no-download"} {
+		if cCtx.IsSet(name) {
+			return fileAddOptions{}, cli.Exit(fmt.Sprintf("Error: --%s cannot be combined with --from-file", name), 1)
+		}
+	}
+	if cCtx.Bool("force") && cCtx.Bool("if-missing") {
+		return fileAddOptions{}, cli.Exit("Error: --force and --if-missing cannot be used together", 1)
+	}
+	if cCtx.Bool("from-lock") {
+		return fileAddOptions{}, cli.Exit("Error: --from-file and --from-lock cannot be used together", 1)
+	}
+	mode, transformName, err := parseFileOptions(cCtx, "")
+	if err != nil {
+		return fileAddOptions{}, err
+	}
+	defaultExt, err := resolveDefaultExt(cCtx)
+	if err != nil {
+		return fileAddOptions{}, cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	labels := cCtx.StringSlice("label")
+	if err := project.ValidateLabels("--from-file", labels); err != nil {
+		return fileAddOptions{}, cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	return fileAddOptions{
+		targetDir: resolveTargetDir(cCtx), defaultExt: defaultExt, mode: mode, transformName: transformName,
+		labels: labels, force: cCtx.Bool("force"), ifMissing: cCtx.Bool("if-missing"), allowOverwrite: cCtx.Bool("allow-overwrite"),
+	}, nil
+}
+
+// loadManifestForFileAdd loads project.toml, which must exist, and the lockfile, which may not.
+func loadManifestForFileAdd(projectRoot string) (*project.Project, *lockfile.Lockfile, error) {
+	proj, err := config.LoadProjectToml(projectRoot)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, cli.Exit(fmt.Sprintf("Error: %s not found in the current directory.", config.ManifestName()), 1)
+		}
+		return nil, nil, cli.Exit(fmt.Sprintf("Error loading %s: %v", config.ManifestName(), err), 1)
+	}
+	if proj.Dependencies == nil {
+		proj.Dependencies = make(map[string]project.Dependency)
+	}
+	lf, err := lockfile.Load(projectRoot)
+	if err != nil {
+		return nil, nil, cli.Exit(fmt.Sprintf("Error loading %s: %v", lockfile.LockfileName, err), 1)
+	}
+	if err := requireWritableProject(projectRoot); err != nil {
+		return nil, nil, err
+	}
+	return proj, lf, nil
+}
+
+// addFileEntry downloads one listed dependency into the project and records it in proj and lf.
+// name is empty when the dependency already exists and --if-missing is set; previous is the
+// entry it replaces with --force.
+func addFileEntry(projectRoot string, entry fileEntry, opts fileAddOptions, proj *project.Project, lf *lockfile.Lockfile) (name, fullPath string, previous *project.Dependency, err error) {
+	parsedInfo, err := processSourceURL(entry.Source)
+	if err != nil {
+		return "", "", nil, cli.Exit(fmt.Sprintf("Error processing source URL '%s': %v", entry.Source, err), exitcode.Resolution)
+	}
+	name, fileNameOnDisk, err := determineFileNames(parsedInfo, entry.Name, opts.defaultExt)
+	if err != nil {
+		return "", "", nil, cli.Exit(fmt.Sprintf("Error determining file names for '%s': %v", entry.Source, err), 1)
+	}
+	previous, skip, err := replaceListedDependency(proj, name, opts)
+	if err != nil || skip {
+		return "", "", nil, err
+	}
+	targetDir := opts.targetDir
+	if entry.Directory != "" {
+		targetDir = entry.Directory
+	}
+	logger.Debugf("adding '%s' from %s to %s", name, entry.Source, targetDir)
+
+	content, fullPath, relativeDestPath, _, err := acquireDependencyFile(projectRoot, targetDir, fileNameOnDisk, opts.mode, opts.transformName, parsedInfo, "", opts.allowOverwrite)
+	if err != nil {
+		return "", fullPath, nil, err
+	}
+	locked, err := lockedFileEntry(parsedInfo, relativeDestPath, opts.transformName, content)
+	if err != nil {
+		return "", fullPath, nil, cli.Exit(fmt.Sprintf("Error locking '%s': %v", name, err), 1)
+	}
+
+	labels := opts.labels
+	if len(labels) == 0 && previous != nil {
+		labels = previous.Labels
+	}
+	proj.Dependencies[name] = project.Dependency{Source: parsedInfo.CanonicalURL, Path: relativeDestPath, Mode: opts.mode, Transform: opts.transformName, Labels: labels}
+	for lockedName := range lf.Package {
+		if lockedName != name && project.NormalizeDependencyName(lockedName) == name {
+			delete(lf.Package, lockedName) // See updateLockfile
+		}
+	}
+	if lf.Package == nil {
+		lf.Package = make(map[string]lockfile.PackageEntry)
+	}
+	lf.Package[name] = locked
+	return name, fullPath, previous, nil
+}
+
+// replaceListedDependency handles a listed dependency whose name proj already declares, possibly
+// from earlier in the list: it is skipped with --if-missing, removed from proj and returned as
+// previous with --force, and an error otherwise.
+func replaceListedDependency(proj *project.Project, name string, opts fileAddOptions) (previous *project.Dependency, skip bool, err error) {
+	key, existing, ok := project.FindDependency(proj.Dependencies, name)
+	switch {
+	case !ok:
+		return nil, false, nil
+	case opts.ifMissing:
+		fmt.Printf("Dependency '%s' already exists in %s. Skipping.\n", key, config.ManifestName())
+		return nil, true, nil
+	case !opts.force:
+		return nil, false, cli.Exit(fmt.Sprintf("Error: dependency '%s' already exists in %s (source: %s). Use --force to replace it or give it another name in the list.", key, config.ManifestName(), existing.Source), 1)
+	}
+	delete(proj.Dependencies, key)
+	return &existing, false, nil
+}
+
+// lockedFileEntry returns the lockfile entry of a listed dependency saved at relativeDestPath.
+func lockedFileEntry(parsedInfo *source.ParsedSourceInfo, relativeDestPath, transformName string, content []byte) (lockfile.PackageEntry, error) {
+	integrityHash, err := calculateIntegrityHash(parsedInfo, content, false)
+	if err != nil {
+		return lockfile.PackageEntry{}, err
+	}
+	locked := lockfile.PackageEntry{Source: parsedInfo.RawURL, Path: relativeDestPath, Hash: integrityHash}
+	if transformName != "" {
+		locked.Transform = transformName
+		if locked.TransformedHash, err = calculateTransformedHash(transformName, relativeDestPath, content); err != nil {
+			return lockfile.PackageEntry{}, err
+		}
+	}
+	return locked, nil
+}
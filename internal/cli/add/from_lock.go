@@ -0,0 +1,242 @@
+package add
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/filemode"
+	"github.com/nightconcept/almandine/internal/core/hasher"
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+	"github.com/nightconcept/almandine/internal/core/logger"
+	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/safepath"
+	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/nightconcept/almandine/internal/core/theme"
+	"github.com/nightconcept/almandine/internal/core/transform"
+	"github.com/nightconcept/almandine/internal/core/vendorheader"
+	"github.com/nightconcept/almandine/internal/core/warnings"
+	"github.com/urfave/cli/v2"
+)
+
+// selectLockEntriesToMaterialize picks the lockfile entries to restore into the manifest.
+// Explicitly requested names must exist in the lockfile; without names, every locked
+// package that is missing from project.toml is selected.
+func selectLockEntriesToMaterialize(proj *project.Project, lf *lockfile.Lockfile, names []string, verbose bool) ([]string, error) {
+	if len(names) > 0 {
+		var selected []string
+		for _, name := range names {
+			if _, ok := lf.Package[name]; !ok {
+				return nil, fmt.Errorf("dependency '%s' not found in %s", name, lockfile.LockfileName)
+			}
+			if _, ok := proj.Dependencies[name]; ok {
+				_, _ = fmt.Fprintf(os.Stdout, "Dependency '%s' is already in %s. Skipping.\n", name, config.ManifestName())
+				continue
+			}
+			selected = append(selected, name)
+		}
+		return selected, nil
+	}
+
+	var selected []string
+	for name := range lf.Package {
+		if _, ok := proj.Dependencies[name]; ok {
+			if verbose {
+				_, _ = fmt.Fprintf(os.Stdout, "Dependency '%s' is already in %s. Skipping.\n", name, config.ManifestName())
+			}
+			continue
+		}
+		selected = append(selected, name)
+	}
+	sort.Strings(selected)
+	return selected, nil
+}
+
+// materializeLockEntry downloads the pinned content of a lockfile entry, verifies it against a
+// recorded sha256 hash when there is one, writes it to the locked path (with a provenance header
+// when vendorHeader is set) and returns the manifest dependency that describes it.
+func materializeLockEntry(projectRoot, name string, entry lockfile.PackageEntry, vendorHeader bool, settings filemode.Settings) (dep project.Dependency, fullPath string, err error) {
+	if entry.Source == "" || entry.Path == "" {
+		return project.Dependency{}, "", fmt.Errorf("lockfile entry for '%s' is missing its source or path", name)
+	}
+
+	parsedInfo, err := source.ParseSourceURL(entry.Source)
+	if err != nil {
+		return project.Dependency{}, "", fmt.Errorf("parsing locked source '%s': %w", entry.Source, err)
+	}
+
+	fileContent, err := downloadDependency(entry.Source, isPinnedToCommit(parsedInfo))
+	if err != nil {
+		return project.Dependency{}, "", err
+	}
+
+	if strings.HasPrefix(entry.Hash, "sha256:") {
+		actualHash, hashErr := hasher.CalculateSHA256(fileContent)
+		if hashErr != nil {
+			return project.Dependency{}, "", fmt.Errorf("calculating SHA256 hash: %w", hashErr)
+		}
+		if actualHash != entry.Hash {
+			return project.Dependency{}, "", fmt.Errorf("downloaded content hash %s does not match locked hash %s", actualHash, entry.Hash)
+		}
+	}
+
+	fileContent, err = transformLockedContent(entry, fileContent)
+	if err != nil {
+		return project.Dependency{}, "", err
+	}
+
+	if pathErr := safepath.ValidateRelPath(entry.Path); pathErr != nil {
+		return project.Dependency{}, "", pathErr
+	}
+	fullPath = filepath.Join(projectRoot, filepath.FromSlash(entry.Path))
+	if mkdirErr := os.MkdirAll(safepath.LongPath(filepath.Dir(fullPath)), 0755); mkdirErr != nil {
+		return project.Dependency{}, "", fmt.Errorf("creating directory '%s': %w", filepath.Dir(fullPath), mkdirErr)
+	}
+	if vendorHeader {
+		commit, isCommit := strings.CutPrefix(entry.Hash, "commit:")
+		if !isCommit {
+			commit = ""
+		}
+		fileContent = vendorheader.Apply(fileContent, fullPath, vendorheader.Describe(parsedInfo, parsedInfo.CanonicalURL, commit))
+	}
+	if writeErr := filemode.WriteFile(safepath.LongPath(fullPath), fileContent, "", settings); writeErr != nil {
+		return project.Dependency{}, fullPath, fmt.Errorf("writing file '%s': %w", fullPath, writeErr)
+	}
+
+	return project.Dependency{Source: parsedInfo.CanonicalURL, Path: entry.Path, Transform: entry.Transform}, fullPath, nil
+}
+
+// transformLockedContent applies the entry's transform, if any, and checks the result against
+// the recorded transformed hash.
+func transformLockedContent(entry lockfile.PackageEntry, fileContent []byte) ([]byte, error) {
+	if entry.Transform == "" {
+		return fileContent, nil
+	}
+	transformed, err := transform.Apply(entry.Transform, entry.Path, fileContent)
+	if err != nil {
+		return nil, err
+	}
+	if entry.TransformedHash == "" {
+		return transformed, nil
+	}
+	actualHash, err := hasher.CalculateSHA256(transformed)
+	if err != nil {
+		return nil, fmt.Errorf("calculating SHA256 hash: %w", err)
+	}
+	if actualHash != entry.TransformedHash {
+		return nil, fmt.Errorf("transformed content hash %s does not match locked hash %s", actualHash, entry.TransformedHash)
+	}
+	return transformed, nil
+}
+
+// loadManifestAndLockfile loads project.toml and a non-empty almd-lock.toml for --from-lock.
+func loadManifestAndLockfile(projectRoot string) (*project.Project, *lockfile.Lockfile, error) {
+	proj, loadErr := config.LoadProjectToml(projectRoot)
+	if loadErr != nil {
+		if os.IsNotExist(loadErr) {
+			return nil, nil, cli.Exit(fmt.Sprintf("Error: %s not found in the current directory.", config.ManifestName()), 1)
+		}
+		return nil, nil, cli.Exit(fmt.Sprintf("Error loading %s: %v", config.ManifestName(), loadErr), 1)
+	}
+	if proj.Dependencies == nil {
+		proj.Dependencies = make(map[string]project.Dependency)
+	}
+
+	lf, loadLockErr := lockfile.Load(projectRoot)
+	if loadLockErr != nil {
+		return nil, nil, cli.Exit(fmt.Sprintf("Error loading %s: %v", lockfile.LockfileName, loadLockErr), 1)
+	}
+	if len(lf.Package) == 0 {
+		return nil, nil, cli.Exit(fmt.Sprintf("Error: %s has no package entries to add.", lockfile.LockfileName), 1)
+	}
+	if err := requireWritableProject(projectRoot); err != nil {
+		return nil, nil, err
+	}
+	return proj, lf, nil
+}
+
+// addFromLockfile regenerates project.toml entries from almd-lock.toml and downloads the pinned
+// content for each of them. The lockfile itself is left untouched. Because the lockfile only
+// records resolved sources, restored manifest entries point at the locked commit rather than the
+// branch or tag they were originally added from.
+func addFromLockfile(cCtx *cli.Context, projectRoot string) (err error) {
+	startTime := time.Now()
+	verbose := cCtx.Bool("verbose") || logger.Verbose()
+
+	if cCtx.IsSet("name") {
+		return cli.Exit("Error: --name cannot be combined with --from-lock; dependency names come from the lockfile", 1)
+	}
+
+	proj, lf, loadErr := loadManifestAndLockfile(projectRoot)
+	if loadErr != nil {
+		return loadErr
+	}
+
+	names, selectErr := selectLockEntriesToMaterialize(proj, lf, cCtx.Args().Slice(), verbose)
+	if selectErr != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", selectErr), 1)
+	}
+	if len(names) == 0 {
+		_, _ = fmt.Fprintf(os.Stdout, "All locked dependencies are already in %s.\n", config.ManifestName())
+		return nil
+	}
+
+	settings := filemode.GlobalSettings()
+	var writtenFiles []string
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, path := range writtenFiles {
+			if removeErr := filemode.Remove(path); removeErr != nil {
+				warnings.Printf("Failed to clean up downloaded file '%s' during error handling: %v", path, removeErr)
+			}
+		}
+	}()
+
+	for _, name := range names {
+		if verbose {
+			_, _ = fmt.Fprintf(os.Stdout, "Restoring '%s' from %s (Source: %s)\n", name, lockfile.LockfileName, lf.Package[name].Source)
+		}
+		dep, fullPath, materializeErr := materializeLockEntry(projectRoot, name, lf.Package[name], proj.VendorHeaderEnabled(), settings)
+		if fullPath != "" {
+			writtenFiles = append(writtenFiles, fullPath)
+		}
+		if materializeErr != nil {
+			return cli.Exit(fmt.Sprintf("Error restoring '%s' from %s: %v", name, lockfile.LockfileName, materializeErr), 1)
+		}
+		proj.Dependencies[name] = dep
+	}
+
+	if writeErr := config.WriteProjectToml(projectRoot, proj); writeErr != nil {
+		return cli.Exit(fmt.Sprintf("Error writing %s: %v", config.ManifestName(), writeErr), 1)
+	}
+	restored := make([]string, 0, len(names))
+	for _, name := range names {
+		restored = append(restored, proj.Dependencies[name].Path)
+	}
+	recordInstalled(projectRoot, restored...)
+
+	printFromLockSummary(names, lf, startTime)
+	return nil
+}
+
+// printFromLockSummary prints the pnpm-style summary for dependencies restored from the lockfile.
+func printFromLockSummary(names []string, lf *lockfile.Lockfile, startTime time.Time) {
+	_, _ = theme.New(theme.Summary).Printf("Packages: +%d\n", len(names))
+	if !theme.Plain() {
+		_, _ = theme.New(theme.Added).Println(strings.Repeat("+", len(names)))
+	}
+	fmt.Printf("Progress: resolved %d, downloaded %d, added %d, done\n", len(names), len(names), len(names))
+	fmt.Println()
+	_, _ = theme.New(theme.Section).Println("dependencies:")
+	for _, name := range names {
+		_, _ = theme.New(theme.Added).Printf("+ %s %s\n", name, lf.Package[name].Hash)
+	}
+	fmt.Println()
+	fmt.Printf("Done in %.1fs\n", time.Since(startTime).Seconds())
+}
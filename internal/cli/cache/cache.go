@@ -0,0 +1,241 @@
+// Package cache implements the 'cache' command group for inspecting and maintaining
+// almd's global download cache.
+package cache
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/bytesize"
+	corecache "github.com/nightconcept/almandine/internal/core/cache"
+	"github.com/nightconcept/almandine/internal/core/theme"
+)
+
+// defaultCleanAge is how long an entry may go unused before 'cache clean' removes it
+// when no other criteria are given.
+const defaultCleanAge = 30 * 24 * time.Hour
+
+// CacheCmd returns the 'cache' command with its ls, info, clean and verify subcommands.
+func CacheCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "cache",
+		Usage: "Inspect and maintain the global download cache",
+		Subcommands: []*cli.Command{
+			{
+				Name:    "ls",
+				Aliases: []string{"list"},
+				Usage:   "List cached files with their sizes and last-used times",
+				Action:  lsAction,
+			},
+			{
+				Name:   "info",
+				Usage:  "Show the cache location and totals",
+				Action: infoAction,
+			},
+			{
+				Name:  "clean",
+				Usage: "Remove cached files that are old or exceed a size budget",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "older-than", Usage: "Remove entries unused for this long (e.g. 30d, 12h); defaults to 30d when no other option is given"},
+					&cli.StringFlag{Name: "max-size", Usage: "Evict least recently used entries until the cache fits (e.g. 200MB, 1GiB)"},
+					&cli.BoolFlag{Name: "all", Usage: "Remove every cached file"},
+					&cli.BoolFlag{Name: "dry-run", Usage: "Show what would be removed without deleting anything"},
+				},
+				Action: cleanAction,
+			},
+			{
+				Name:  "verify",
+				Usage: "Re-hash cached files and report corrupted entries",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "fix", Usage: "Remove corrupted entries"},
+				},
+				Action: verifyAction,
+			},
+		},
+	}
+}
+
+func openStore() (*corecache.Store, error) {
+	store, err := corecache.Open()
+	if err != nil {
+		return nil, cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	return store, nil
+}
+
+func lsAction(c *cli.Context) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	entries, err := store.List()
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	if len(entries) == 0 {
+		fmt.Println("Cache is empty.")
+		return nil
+	}
+
+	hashColor := theme.SprintFunc(theme.DepHash)
+	sizeColor := theme.SprintFunc(theme.Text)
+	ageColor := theme.SprintFunc(theme.Muted)
+	now := time.Now()
+	for _, e := range entries {
+		source := "-"
+		if len(e.Sources) > 0 {
+			source = e.Sources[0]
+			if len(e.Sources) > 1 {
+				source = fmt.Sprintf("%s (+%d more)", source, len(e.Sources)-1)
+			}
+		}
+		fmt.Printf("%s %9s %s %s\n", hashColor(shortHash(e.Hash)), sizeColor(bytesize.Format(e.Size)), ageColor(formatAge(now.Sub(e.LastUsed))), source)
+	}
+	return nil
+}
+
+func infoAction(c *cli.Context) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	entries, err := store.List()
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+	fmt.Printf("Location: %s\n", store.Root())
+	fmt.Printf("Entries:  %d\n", len(entries))
+	fmt.Printf("Size:     %s\n", bytesize.Format(total))
+	if len(entries) > 0 {
+		now := time.Now()
+		fmt.Printf("Newest:   used %s\n", formatAge(now.Sub(entries[0].LastUsed)))
+		fmt.Printf("Oldest:   used %s\n", formatAge(now.Sub(entries[len(entries)-1].LastUsed)))
+	}
+	return nil
+}
+
+// cleanOptionsFromFlags builds the clean criteria, applying the default age when none are given.
+func cleanOptionsFromFlags(c *cli.Context) (corecache.CleanOptions, error) {
+	opts := corecache.CleanOptions{All: c.Bool("all"), DryRun: c.Bool("dry-run")}
+	if raw := c.String("older-than"); raw != "" {
+		age, err := parseAge(raw)
+		if err != nil {
+			return opts, err
+		}
+		opts.OlderThan = age
+	}
+	if raw := c.String("max-size"); raw != "" {
+		size, err := bytesize.Parse(raw)
+		if err != nil {
+			return opts, err
+		}
+		opts.MaxSize = size
+	}
+	if !opts.All && opts.OlderThan == 0 && opts.MaxSize == 0 {
+		opts.OlderThan = defaultCleanAge
+	}
+	return opts, nil
+}
+
+func cleanAction(c *cli.Context) error {
+	opts, err := cleanOptionsFromFlags(c)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+
+	removed, freed, err := store.Clean(opts)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error cleaning cache: %v", err), 1)
+	}
+
+	verb := "Removed"
+	if opts.DryRun {
+		verb = "Would remove"
+		for _, e := range removed {
+			fmt.Printf("  %s %s\n", shortHash(e.Hash), bytesize.Format(e.Size))
+		}
+	}
+	fmt.Printf("%s %d cached file(s), %s.\n", verb, len(removed), bytesize.Format(freed))
+	return nil
+}
+
+func verifyAction(c *cli.Context) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	fix := c.Bool("fix")
+	corrupt, err := store.Verify(fix)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error verifying cache: %v", err), 1)
+	}
+	if len(corrupt) == 0 {
+		fmt.Println("All cached files are intact.")
+		return nil
+	}
+
+	for _, e := range corrupt {
+		_, _ = fmt.Fprintf(os.Stderr, "Corrupted: %s\n", e.Hash)
+	}
+	if fix {
+		fmt.Printf("Removed %d corrupted cache entr(ies).\n", len(corrupt))
+		return nil
+	}
+	return cli.Exit(fmt.Sprintf("Found %d corrupted cache entr(ies). Run 'almd cache verify --fix' to remove them.", len(corrupt)), 1)
+}
+
+// shortHash abbreviates a "sha256:<hex>" hash for display.
+func shortHash(hash string) string {
+	digest := strings.TrimPrefix(hash, "sha256:")
+	if len(digest) > 12 {
+		digest = digest[:12]
+	}
+	return digest
+}
+
+// parseAge parses durations like "30d", "2w" or anything time.ParseDuration accepts.
+func parseAge(raw string) (time.Duration, error) {
+	units := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if n := len(raw); n > 1 {
+		if unit, ok := units[raw[n-1]]; ok {
+			count, err := strconv.Atoi(raw[:n-1])
+			if err != nil || count < 0 {
+				return 0, fmt.Errorf("invalid age '%s'", raw)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	age, err := time.ParseDuration(raw)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid age '%s' (use e.g. 30d, 2w or 12h)", raw)
+	}
+	return age, nil
+}
+
+// formatAge renders how long ago something happened in the largest sensible unit.
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+
+	corecache "github.com/nightconcept/almandine/internal/core/cache"
+	"github.com/nightconcept/almandine/internal/core/paths"
+)
+
+func runCacheCommand(t *testing.T, args ...string) error {
+	t.Helper()
+	app := &cli.App{
+		Name:           "almd-test-cache",
+		Commands:       []*cli.Command{CacheCmd()},
+		ExitErrHandler: func(_ *cli.Context, _ error) {},
+	}
+	return app.Run(append([]string{"almd-test-cache", "cache"}, args...))
+}
+
+func TestParseAge(t *testing.T) {
+	cases := map[string]time.Duration{
+		"30d": 30 * 24 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+		"90m": 90 * time.Minute,
+	}
+	for raw, want := range cases {
+		got, err := parseAge(raw)
+		require.NoError(t, err, raw)
+		assert.Equal(t, want, got, raw)
+	}
+	for _, bad := range []string{"", "d", "-3d", "soon"} {
+		_, err := parseAge(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestCacheCommands(t *testing.T) {
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	t.Setenv(paths.CacheDirEnv, cacheDir)
+
+	store := corecache.New(cacheDir)
+	_, err := store.Put("https://example.com/fresh.lua", []byte("fresh"), true)
+	require.NoError(t, err)
+	staleHash, err := store.Put("https://example.com/stale.lua", []byte("stale"), true)
+	require.NoError(t, err)
+
+	entries, err := store.List()
+	require.NoError(t, err)
+	past := time.Now().Add(-45 * 24 * time.Hour)
+	for _, e := range entries {
+		if e.Hash == staleHash {
+			blob := filepath.Join(store.Root(), "objects", e.Hash[7:9], e.Hash[7:])
+			require.NoError(t, os.Chtimes(blob, past, past))
+		}
+	}
+
+	require.NoError(t, runCacheCommand(t, "ls"))
+	require.NoError(t, runCacheCommand(t, "info"))
+	require.NoError(t, runCacheCommand(t, "verify"))
+
+	require.NoError(t, runCacheCommand(t, "clean"), "default clean removes entries unused for 30 days")
+	entries, err = store.List()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.NotEqual(t, staleHash, entries[0].Hash)
+
+	err = runCacheCommand(t, "clean", "--older-than", "yesterday")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid age")
+
+	require.NoError(t, runCacheCommand(t, "clean", "--all"))
+	entries, err = store.List()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
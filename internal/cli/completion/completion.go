@@ -0,0 +1,221 @@
+// Package completion implements 'almd completion', which prints shell completion scripts, and
+// the argument completers commands use to suggest dependency names from project.toml.
+//
+// Completion runs almd once per keypress, so dependency names are read with a minimal TOML
+// decode and remembered in the cache directory until project.toml changes.
+package completion
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/paths"
+)
+
+// cacheFile holds the dependency names of recently completed projects, inside the cache directory.
+const cacheFile = "completion.json"
+
+// maxCachedProjects bounds cacheFile; the least recently modified projects are dropped first.
+const maxCachedProjects = 50
+
+// Scripts are adapted from urfave/cli's autocomplete directory. They call almd with
+// --generate-bash-completion appended to the words typed so far.
+var scripts = map[string]string{
+	"bash": `_almd_bash_autocomplete() {
+  local cur words
+  COMPREPLY=()
+  cur="${COMP_WORDS[COMP_CWORD]}"
+  words=("${COMP_WORDS[@]:0:$COMP_CWORD}")
+  if [[ "$cur" == "-"* ]]; then
+    opts=$("${words[@]}" "$cur" --generate-bash-completion 2>/dev/null)
+  else
+    opts=$("${words[@]}" --generate-bash-completion 2>/dev/null)
+  fi
+  COMPREPLY=($(compgen -W "${opts}" -- "${cur}"))
+  return 0
+}
+
+complete -o bashdefault -o default -F _almd_bash_autocomplete almd
+`,
+	"zsh": `#compdef almd
+
+_almd_zsh_autocomplete() {
+  local -a opts
+  local cur
+  cur=${words[-1]}
+  if [[ "$cur" == "-"* ]]; then
+    opts=("${(@f)$(${words[@]:0:#words[@]-1} ${cur} --generate-bash-completion 2>/dev/null)}")
+  else
+    opts=("${(@f)$(${words[@]:0:#words[@]-1} --generate-bash-completion 2>/dev/null)}")
+  fi
+
+  if [[ "${opts[1]}" != "" ]]; then
+    _describe 'values' opts
+  else
+    _files
+  fi
+}
+
+compdef _almd_zsh_autocomplete almd
+`,
+}
+
+// CompletionCmd returns the 'completion' command, which prints a shell's completion script.
+func CompletionCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "completion",
+		Usage:     "Print the shell completion script for bash or zsh",
+		ArgsUsage: "bash|zsh",
+		Description: "Load the script in your shell's startup file, for example:\n" +
+			"  bash: source <(almd completion bash)\n" +
+			"  zsh:  source <(almd completion zsh)",
+		BashComplete: func(c *cli.Context) {
+			if c.NArg() == 0 {
+				for _, shell := range shells() {
+					_, _ = fmt.Fprintln(c.App.Writer, shell)
+				}
+			}
+		},
+		Action: func(c *cli.Context) error {
+			script, ok := scripts[c.Args().First()]
+			if c.NArg() != 1 || !ok {
+				return cli.Exit(fmt.Sprintf("Error: expected one of %s", strings.Join(shells(), ", ")), 1)
+			}
+			_, _ = fmt.Fprint(c.App.Writer, script)
+			return nil
+		},
+	}
+}
+
+func shells() []string {
+	names := make([]string, 0, len(scripts))
+	for name := range scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Dependencies is a BashComplete handler that suggests the dependencies in the current
+// project.toml that are not on the command line yet. While a flag is being typed it suggests the
+// command's flags instead, like urfave/cli's default completer.
+func Dependencies(c *cli.Context) {
+	if len(os.Args) > 2 && strings.HasPrefix(os.Args[len(os.Args)-2], "-") {
+		cli.DefaultCompleteWithFlags(c.Command)(c)
+		return
+	}
+	names, err := DependencyNames(".")
+	if err != nil {
+		return // Completion stays silent; the command itself reports a broken project.toml
+	}
+	typed := make(map[string]bool, c.NArg())
+	for _, arg := range c.Args().Slice() {
+		typed[arg] = true
+	}
+	for _, name := range names {
+		if !typed[name] {
+			_, _ = fmt.Fprintln(c.App.Writer, name)
+		}
+	}
+}
+
+// cachedProject is the cacheFile entry of one project.toml.
+type cachedProject struct {
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+	Names   []string  `json:"names"`
+}
+
+// DependencyNames returns the sorted dependency names declared in projectRoot's project.toml.
+// The names are served from the completion cache while the file's size and modification time
+// are unchanged.
+func DependencyNames(projectRoot string) ([]string, error) {
+	path, err := filepath.Abs(config.ManifestPath(projectRoot))
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cachePath, cacheErr := completionCachePath()
+	cache := map[string]cachedProject{}
+	if cacheErr == nil {
+		if data, err := os.ReadFile(cachePath); err == nil {
+			_ = json.Unmarshal(data, &cache) // A damaged cache is rebuilt
+		}
+	}
+	if entry, ok := cache[path]; ok && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) {
+		return entry.Names, nil
+	}
+
+	names, err := readDependencyNames(path)
+	if err != nil {
+		return nil, err
+	}
+	if cacheErr == nil {
+		cache[path] = cachedProject{ModTime: info.ModTime(), Size: info.Size(), Names: names}
+		_ = writeCache(cachePath, cache) // The cache only saves time
+	}
+	return names, nil
+}
+
+// readDependencyNames decodes just the keys of the [dependencies] table; the dependency
+// definitions themselves are neither decoded nor validated.
+func readDependencyNames(path string) ([]string, error) {
+	var doc struct {
+		Dependencies map[string]toml.Primitive `toml:"dependencies"`
+	}
+	if _, err := toml.DecodeFile(path, &doc); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(doc.Dependencies))
+	for name := range doc.Dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func completionCachePath() (string, error) {
+	dir, err := paths.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, cacheFile), nil
+}
+
+func writeCache(cachePath string, cache map[string]cachedProject) error {
+	if len(cache) > maxCachedProjects {
+		projects := make([]string, 0, len(cache))
+		for project := range cache {
+			projects = append(projects, project)
+		}
+		sort.Slice(projects, func(i, j int) bool { return cache[projects[i]].ModTime.After(cache[projects[j]].ModTime) })
+		for _, project := range projects[maxCachedProjects:] {
+			delete(cache, project)
+		}
+	}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return err
+	}
+	tmp := cachePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, cachePath)
+}
@@ -0,0 +1,100 @@
+package completion_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/cli/completion"
+	"github.com/nightconcept/almandine/internal/cli/install"
+	"github.com/nightconcept/almandine/internal/cli/remove"
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/paths"
+)
+
+const projectToml = `
+[package]
+name = "test"
+
+[dependencies]
+json = { source = "github:owner/json/json.lua@main", path = "libs/json.lua" }
+inspect = { source = "github:owner/inspect/inspect.lua@main", path = "libs/inspect.lua" }
+`
+
+// complete runs almd with args and --generate-bash-completion in dir and returns the suggestions.
+func complete(t *testing.T, dir string, args ...string) []string {
+	t.Helper()
+	originalWD, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer func() { _ = os.Chdir(originalWD) }()
+
+	argv := append(append([]string{"almd"}, args...), "--generate-bash-completion")
+	originalArgs := os.Args
+	os.Args = argv // Completers look at os.Args like urfave/cli's default completer
+	defer func() { os.Args = originalArgs }()
+
+	var out bytes.Buffer
+	app := &cli.App{
+		EnableBashCompletion: true,
+		Writer:               &out,
+		Commands:             []*cli.Command{remove.RemoveCmd(), install.InstallCmd(), completion.CompletionCmd()},
+	}
+	require.NoError(t, app.Run(argv))
+	return strings.Fields(out.String())
+}
+
+func setupProject(t *testing.T) string {
+	t.Helper()
+	t.Setenv(paths.CacheDirEnv, t.TempDir())
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.ProjectTomlName), []byte(projectToml), 0644))
+	return dir
+}
+
+func TestDependencies(t *testing.T) {
+	dir := setupProject(t)
+
+	assert.Equal(t, []string{"inspect", "json"}, complete(t, dir, "remove"))
+	assert.Equal(t, []string{"inspect"}, complete(t, dir, "install", "json"), "names already given are not suggested again")
+	assert.Contains(t, complete(t, dir, "remove", "--k"), "--keep-files")
+	assert.Equal(t, []string{"bash", "zsh"}, complete(t, dir, "completion"))
+
+	// Outside a project there is nothing to suggest.
+	assert.Empty(t, complete(t, t.TempDir(), "remove"))
+}
+
+func TestDependencyNames_Cache(t *testing.T) {
+	dir := setupProject(t)
+	path := filepath.Join(dir, config.ProjectTomlName)
+
+	names, err := completion.DependencyNames(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"inspect", "json"}, names)
+	cacheDir, err := paths.CacheDir()
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(cacheDir, "completion.json"))
+
+	// A changed project.toml is read again.
+	require.NoError(t, os.WriteFile(path, []byte(projectToml+"extra = { source = \"github:o/r/x.lua@v1\", path = \"x.lua\" }\n"), 0644))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, later, later))
+	names, err = completion.DependencyNames(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"extra", "inspect", "json"}, names)
+}
+
+func TestCompletionCmd(t *testing.T) {
+	var out bytes.Buffer
+	app := &cli.App{Writer: &out, Commands: []*cli.Command{completion.CompletionCmd()}, ExitErrHandler: func(_ *cli.Context, _ error) {}}
+	require.NoError(t, app.Run([]string{"almd", "completion", "bash"}))
+	assert.Contains(t, out.String(), "complete -o bashdefault -o default -F _almd_bash_autocomplete almd")
+	assert.Error(t, app.Run([]string{"almd", "completion", "fish"}))
+}
@@ -0,0 +1,170 @@
+// Package docs implements the 'docs' command, which shows usage documentation for a dependency
+// from its vendored file's header comment or its upstream README.
+package docs
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/cli/completion"
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/downloader"
+	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/nightconcept/almandine/internal/core/vendorheader"
+)
+
+// readmeNames are the README file names tried, in order, when fetching upstream docs.
+var readmeNames = []string{"README.md", "readme.md", "README", "README.txt"}
+
+// DocsCmd returns the 'docs' command.
+func DocsCmd() *cli.Command {
+	return &cli.Command{
+		Name:         "docs",
+		Usage:        "Show a dependency's header comment or upstream README",
+		ArgsUsage:    "<dependency>",
+		BashComplete: completion.Dependencies,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "readme", Aliases: []string{"r"}, Usage: "Fetch the upstream README even if the vendored file has a header comment"},
+		},
+		Action: docsAction,
+	}
+}
+
+func docsAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return cli.Exit("Error: expected exactly one dependency name", 1)
+	}
+	name := c.Args().First()
+
+	proj, err := config.LoadProjectToml(".")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cli.Exit(fmt.Sprintf("Error: %s not found in the current directory.", config.ManifestName()), 1)
+		}
+		return cli.Exit(fmt.Sprintf("Error loading %s: %v", config.ManifestName(), err), 1)
+	}
+	dep, ok := proj.Dependencies[name]
+	if !ok {
+		return cli.Exit(fmt.Sprintf("Error: dependency '%s' not found in %s", name, config.ManifestName()), 1)
+	}
+
+	if !c.Bool("readme") {
+		if header := localDocHeader(dep); header != "" {
+			_, _ = fmt.Fprintln(os.Stdout, header)
+			return nil
+		}
+	}
+
+	readme, readmeURL, err := fetchReadme(dep)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: no documentation found for '%s': %v", name, err), 1)
+	}
+	_, _ = fmt.Fprintf(os.Stdout, "# Source: %s\n\n%s\n", readmeURL, strings.TrimRight(readme, "\n"))
+	return nil
+}
+
+// localDocHeader returns the header comment of the vendored file, or "" if the file is missing
+// or has no leading comment.
+func localDocHeader(dep project.Dependency) string {
+	content, err := os.ReadFile(filepath.FromSlash(dep.Path))
+	if err != nil {
+		return ""
+	}
+	return ExtractDocHeader(string(vendorheader.Strip(content)))
+}
+
+// fetchReadme downloads the upstream README for a dependency, looking first next to the file in
+// the repository and then at the repository root.
+func fetchReadme(dep project.Dependency) (content, readmeURL string, err error) {
+	parsed, err := source.ParseSourceURL(dep.Source)
+	if err != nil {
+		return "", "", fmt.Errorf("parsing source '%s': %w", dep.Source, err)
+	}
+	if parsed.IsTagPattern() {
+		if parsed, err = source.ResolveTagPattern(parsed); err != nil {
+			return "", "", err
+		}
+	}
+	if parsed.RepoFileRawURL("README.md") == "" {
+		return "", "", fmt.Errorf("source '%s' does not identify a repository", dep.Source)
+	}
+
+	var dirs []string
+	if dir := path.Dir(parsed.PathInRepo); dir != "." && dir != "/" {
+		dirs = append(dirs, dir)
+	}
+	dirs = append(dirs, "")
+
+	var lastErr error
+	for _, dir := range dirs {
+		for _, readmeName := range readmeNames {
+			candidate := parsed.RepoFileRawURL(path.Join(dir, readmeName))
+			body, downloadErr := downloader.DownloadFile(candidate)
+			if downloadErr == nil {
+				return string(body), candidate, nil
+			}
+			lastErr = downloadErr
+		}
+	}
+	return "", "", fmt.Errorf("no README found in %s/%s: %w", parsed.Owner, parsed.Repo, lastErr)
+}
+
+// ExtractDocHeader returns the leading comment of a Lua source file with comment markers
+// removed. Both runs of "--" line comments and a "--[[ ... ]]" (or "--[==[ ... ]==]") block
+// comment are recognized; a shebang line is skipped. It returns "" when the file does not
+// start with a comment.
+func ExtractDocHeader(content string) string {
+	content = strings.TrimPrefix(content, "\ufeff") // UTF-8 byte order mark
+	if strings.HasPrefix(content, "#!") {
+		if i := strings.IndexByte(content, '\n'); i >= 0 {
+			content = content[i+1:]
+		} else {
+			return ""
+		}
+	}
+	content = strings.TrimLeft(content, " \t\r\n")
+
+	if body, ok := blockComment(content); ok {
+		return strings.TrimSpace(body)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "--") {
+			break
+		}
+		text := strings.TrimPrefix(trimmed, "--")
+		text = strings.TrimLeft(text, "-") // Decorative "----" rulers
+		lines = append(lines, strings.TrimPrefix(text, " "))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// blockComment extracts the body of a leading Lua long comment such as --[[ ... ]] or --[=[ ... ]=].
+func blockComment(content string) (string, bool) {
+	if !strings.HasPrefix(content, "--[") {
+		return "", false
+	}
+	rest := content[3:]
+	level := 0
+	for level < len(rest) && rest[level] == '=' {
+		level++
+	}
+	if level >= len(rest) || rest[level] != '[' {
+		return "", false
+	}
+	closing := "]" + strings.Repeat("=", level) + "]"
+	body := rest[level+1:]
+	end := strings.Index(body, closing)
+	if end < 0 {
+		return "", false
+	}
+	return body[:end], true
+}
@@ -0,0 +1,153 @@
+package docs
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+func TestExtractDocHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "line comments",
+			content:  "-- inspect.lua\n-- Human-readable representations of tables\n\nlocal inspect = {}\n",
+			expected: "inspect.lua\nHuman-readable representations of tables",
+		},
+		{
+			name:     "block comment",
+			content:  "--[[\n  json.lua\n  A lightweight JSON library\n]]\nlocal json = {}\n",
+			expected: "json.lua\n  A lightweight JSON library",
+		},
+		{
+			name:     "leveled block comment",
+			content:  "--[==[ uses ]] inside ]==]\nreturn {}\n",
+			expected: "uses ]] inside",
+		},
+		{
+			name:     "shebang and rulers",
+			content:  "#!/usr/bin/env lua\n------------\n-- tool\n------------\nprint(1)\n",
+			expected: "tool",
+		},
+		{
+			name:     "no header",
+			content:  "local M = {}\n-- not a header\nreturn M\n",
+			expected: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ExtractDocHeader(tt.content))
+		})
+	}
+}
+
+// runDocsCommand runs 'docs' in dir with stdout captured.
+func runDocsCommand(t *testing.T, dir string, args ...string) (string, error) {
+	t.Helper()
+	originalWD, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer func() { _ = os.Chdir(originalWD) }()
+
+	originalStdout := os.Stdout
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	os.Stdout = w
+
+	app := &cli.App{
+		Commands:       []*cli.Command{DocsCmd()},
+		ExitErrHandler: func(_ *cli.Context, _ error) {},
+	}
+	runErr := app.Run(append([]string{"almd", "docs"}, args...))
+
+	_ = w.Close()
+	os.Stdout = originalStdout
+	var out bytes.Buffer
+	_, _ = out.ReadFrom(r)
+	_ = r.Close()
+	return out.String(), runErr
+}
+
+func TestDocsCommand(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/owner/repo/main/README.md" {
+			_, _ = w.Write([]byte("# mylib\n\nUsage: require('mylib')\n"))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer mockServer.Close()
+
+	source.SetTestModeBypassHostValidation(true)
+	defer source.SetTestModeBypassHostValidation(false)
+	source.GithubAPIBaseURLMutex.Lock()
+	originalBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	source.GithubAPIBaseURLMutex.Unlock()
+	defer func() {
+		source.GithubAPIBaseURLMutex.Lock()
+		source.GithubAPIBaseURL = originalBaseURL
+		source.GithubAPIBaseURLMutex.Unlock()
+	}()
+
+	setup := func(t *testing.T, fileContent string) string {
+		dir := t.TempDir()
+		toml := `
+[package]
+name = "docs-test"
+version = "0.1.0"
+
+[dependencies.mylib]
+source = "github:owner/repo/src/mylib.lua@main"
+path = "libs/mylib.lua"
+`
+		require.NoError(t, os.WriteFile(filepath.Join(dir, config.ProjectTomlName), []byte(toml), 0644))
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "libs"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "libs", "mylib.lua"), []byte(fileContent), 0644))
+		return dir
+	}
+
+	t.Run("prints the local header", func(t *testing.T) {
+		dir := setup(t, "-- mylib: does things\nreturn {}\n")
+		out, err := runDocsCommand(t, dir, "mylib")
+		require.NoError(t, err)
+		assert.Equal(t, "mylib: does things\n", out)
+	})
+
+	t.Run("falls back to the upstream README", func(t *testing.T) {
+		dir := setup(t, "return {}\n")
+		out, err := runDocsCommand(t, dir, "mylib")
+		require.NoError(t, err)
+		assert.Contains(t, out, "# Source: "+mockServer.URL+"/owner/repo/main/README.md")
+		assert.Contains(t, out, "Usage: require('mylib')")
+	})
+
+	t.Run("readme flag skips the header", func(t *testing.T) {
+		dir := setup(t, "-- mylib: does things\nreturn {}\n")
+		out, err := runDocsCommand(t, dir, "--readme", "mylib")
+		require.NoError(t, err)
+		assert.Contains(t, out, "Usage: require('mylib')")
+		assert.NotContains(t, out, "does things")
+	})
+
+	t.Run("unknown dependency", func(t *testing.T) {
+		dir := setup(t, "return {}\n")
+		_, err := runDocsCommand(t, dir, "missing")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "dependency 'missing' not found")
+	})
+}
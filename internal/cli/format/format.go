@@ -0,0 +1,137 @@
+// Package format implements the 'fmt' command, which rewrites the project manifest into its
+// canonical form: dependency sources as provider shorthand, paths with forward slashes and the
+// [dependencies.<name>] tables sorted by name. The manifest text is edited in place, so comments
+// and layout are kept. With --check nothing is written and an unformatted manifest fails the
+// command, for pre-commit hooks and CI.
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/nightconcept/almandine/internal/core/tomledit"
+	"github.com/nightconcept/almandine/internal/core/warnings"
+)
+
+// FmtCmd returns the 'fmt' command.
+func FmtCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "fmt",
+		Usage: "Rewrite dependency sources and paths into canonical form and sort dependency tables, keeping comments",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "check", Usage: "Only report what would change, failing if the manifest is not formatted"},
+		},
+		Action: fmtAction,
+	}
+}
+
+func fmtAction(c *cli.Context) error {
+	manifestPath := config.ManifestPath(".")
+	info, err := os.Stat(manifestPath)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	proj, err := config.LoadProjectToml(".")
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error loading %s: %v", config.ManifestName(), err), 1)
+	}
+
+	formatted, changes := formatManifest(data, proj)
+	if len(changes) == 0 {
+		fmt.Printf("%s is already formatted.\n", config.ManifestName())
+		return nil
+	}
+	if c.Bool("check") {
+		for _, change := range changes {
+			fmt.Printf("  %s\n", change)
+		}
+		return cli.Exit(fmt.Sprintf("%s is not formatted; run 'almd fmt'", config.ManifestName()), 1)
+	}
+	if err := config.RequireWritable("."); err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), exitcode.Usage)
+	}
+	if err := os.WriteFile(manifestPath, formatted, info.Mode().Perm()); err != nil {
+		return cli.Exit(fmt.Sprintf("Error writing %s: %v", config.ManifestName(), err), 1)
+	}
+	fmt.Printf("Formatted %s:\n", config.ManifestName())
+	for _, change := range changes {
+		fmt.Printf("  %s\n", change)
+	}
+	return nil
+}
+
+// formatManifest returns the manifest text doc, which proj was loaded from, in canonical form
+// and a description of each change. Values that cannot be edited in place, such as those of
+// inline tables, are reported as warnings and left alone.
+func formatManifest(doc []byte, proj *project.Project) ([]byte, []string) {
+	var changes []string
+	for _, name := range slices.Sorted(maps.Keys(proj.Dependencies)) {
+		dep := proj.Dependencies[name]
+		for _, field := range []struct{ key, from, to string }{
+			{"source", dep.Source, canonicalSource(dep)},
+			{"path", dep.Path, canonicalPath(dep.Path)},
+		} {
+			if field.from == field.to {
+				continue
+			}
+			updated, err := tomledit.SetString(doc, "dependencies."+name, field.key, field.to)
+			if err != nil {
+				warnings.Printf("%s: not formatting the %s of '%s': %v", config.ManifestName(), field.key, name, err)
+				continue
+			}
+			doc = updated
+			changes = append(changes, fmt.Sprintf("%s: %s %q -> %q", name, field.key, field.from, field.to))
+		}
+	}
+
+	sorted, err := tomledit.SortTables(doc, "dependencies")
+	switch {
+	case err != nil:
+		warnings.Printf("%s: %v", config.ManifestName(), err)
+	case !bytes.Equal(sorted, doc):
+		doc = sorted
+		changes = append(changes, "sorted the [dependencies.<name>] tables by name")
+	}
+	return doc, changes
+}
+
+// canonicalSource returns the provider shorthand of a dependency's source. Sources written with
+// ${VAR} references or [defaults] shorthand, sources no provider recognizes and URLs carrying a
+// query (such as the token of a private raw link) are kept as written.
+func canonicalSource(dep project.Dependency) string {
+	if dep.SourceTemplate != "" {
+		return dep.Source
+	}
+	if u, err := url.Parse(dep.Source); err != nil || u.RawQuery != "" {
+		return dep.Source
+	}
+	info, err := source.ParseSourceURL(dep.Source)
+	if err != nil || info.CanonicalURL == "" {
+		return dep.Source
+	}
+	return info.CanonicalURL
+}
+
+// canonicalPath returns a dependency path with forward slashes and without redundant elements.
+func canonicalPath(p string) string {
+	if p == "" {
+		return p
+	}
+	return path.Clean(strings.ReplaceAll(p, `\`, "/"))
+}
@@ -0,0 +1,103 @@
+package format
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+const unformatted = `[package]
+name = "game"
+version = "0.1.0"
+
+# Serialization
+[dependencies.json]
+source = "https://raw.githubusercontent.com/rxi/json.lua/master/json.lua" # upstream
+path = "src\\lib\\json.lua"
+
+[dependencies.class]
+source = "github:kikito/middleclass/middleclass.lua@v4.1.1"
+path = "src/lib/./middleclass.lua"
+`
+
+const formatted = `[package]
+name = "game"
+version = "0.1.0"
+
+[dependencies.class]
+source = "github:kikito/middleclass/middleclass.lua@v4.1.1"
+path = "src/lib/middleclass.lua"
+
+# Serialization
+[dependencies.json]
+source = "github:rxi/json.lua/json.lua@master" # upstream
+path = "src/lib/json.lua"
+`
+
+// runFmtCommand runs 'fmt' in dir and returns captured stdout.
+func runFmtCommand(t *testing.T, dir string, args ...string) (string, error) {
+	t.Helper()
+	t.Chdir(dir)
+	stdoutR, stdoutW, err := os.Pipe()
+	require.NoError(t, err)
+	oldStdout := os.Stdout
+	os.Stdout = stdoutW
+	defer func() { os.Stdout = oldStdout }()
+
+	app := &cli.App{
+		Commands:       []*cli.Command{FmtCmd()},
+		ExitErrHandler: func(_ *cli.Context, _ error) {},
+	}
+	runErr := app.Run(append([]string{"almd", "fmt"}, args...))
+	_ = stdoutW.Close()
+	var out bytes.Buffer
+	_, _ = out.ReadFrom(stdoutR)
+	_ = stdoutR.Close()
+	return out.String(), runErr
+}
+
+func TestFmt(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "project.toml")
+	require.NoError(t, os.WriteFile(manifest, []byte(unformatted), 0644))
+
+	out, err := runFmtCommand(t, dir, "--check")
+	assert.ErrorContains(t, err, "project.toml is not formatted")
+	assert.Contains(t, out, `json: source "https://raw.githubusercontent.com/rxi/json.lua/master/json.lua" -> "github:rxi/json.lua/json.lua@master"`)
+	unchanged, err := os.ReadFile(manifest)
+	require.NoError(t, err)
+	assert.Equal(t, unformatted, string(unchanged), "--check must not write")
+
+	_, err = runFmtCommand(t, dir)
+	require.NoError(t, err)
+	written, err := os.ReadFile(manifest)
+	require.NoError(t, err)
+	assert.Equal(t, formatted, string(written))
+
+	out, err = runFmtCommand(t, dir, "--check")
+	require.NoError(t, err)
+	assert.Equal(t, "project.toml is already formatted.\n", out)
+}
+
+func TestFmt_KeepsTemplatesAndTokens(t *testing.T) {
+	t.Setenv("ALMD_TEST_OWNER", "rxi")
+	dir := t.TempDir()
+	content := `[dependencies.json]
+source = "https://raw.githubusercontent.com/${ALMD_TEST_OWNER}/json.lua/master/json.lua"
+path = "src/lib/json.lua"
+
+[dependencies.private]
+source = "https://raw.githubusercontent.com/me/secret/main/lib.lua?token=abc"
+path = "src/lib/lib.lua"
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "project.toml"), []byte(content), 0644))
+
+	out, err := runFmtCommand(t, dir, "--check")
+	require.NoError(t, err)
+	assert.Equal(t, "project.toml is already formatted.\n", out)
+}
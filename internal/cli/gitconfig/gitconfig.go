@@ -0,0 +1,102 @@
+// Package gitconfig implements the 'gitconfig' command group. 'gitconfig install' registers
+// almd with git as the merge driver and diff textconv for almd-lock.toml, so that merges
+// combine lockfile entries package by package and diffs list the packages that changed.
+package gitconfig
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+)
+
+// driverName names the merge driver and diff settings in git's configuration.
+const driverName = "almd-lock"
+
+// attributesLine routes almd-lock.toml to the driver in .gitattributes.
+var attributesLine = fmt.Sprintf("%s merge=%s diff=%s", lockfile.LockfileName, driverName, driverName)
+
+// GitconfigCmd returns the 'gitconfig' command with its install subcommand.
+func GitconfigCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "gitconfig",
+		Usage: "Integrate almd-lock.toml with git",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "install",
+				Usage: "Register almd as the git merge driver and diff textconv for almd-lock.toml",
+				Description: "Sets merge." + driverName + ".driver to 'almd lock merge' and diff." + driverName + ".textconv\n" +
+					"to 'almd lock show' in the repository's git config, and adds a line for " + lockfile.LockfileName + "\n" +
+					"to .gitattributes. Commit .gitattributes; everyone who clones the repository runs\n" +
+					"'almd gitconfig install' once, as git never reads drivers from the repository itself.",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "global", Usage: "Write the driver settings to the global git config instead of the repository's"},
+				},
+				Action: installAction,
+			},
+		},
+	}
+}
+
+func installAction(c *cli.Context) error {
+	settings := [][2]string{
+		{"merge." + driverName + ".name", "almd lockfile merge"},
+		{"merge." + driverName + ".driver", "almd lock merge %O %A %B"},
+		{"diff." + driverName + ".textconv", "almd lock show"},
+	}
+	for _, kv := range settings {
+		if err := setGitConfig(c.Bool("global"), kv[0], kv[1]); err != nil {
+			return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+		}
+	}
+
+	added, err := ensureAttributes(".")
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error updating .gitattributes: %v", err), 1)
+	}
+	_, _ = fmt.Fprintf(os.Stdout, "Registered the %s merge driver and diff textconv.\n", driverName)
+	if added {
+		_, _ = fmt.Fprintln(os.Stdout, "Added "+lockfile.LockfileName+" to .gitattributes; commit it so merges use the driver.")
+	}
+	return nil
+}
+
+func setGitConfig(global bool, key, value string) error {
+	args := []string{"config"}
+	if global {
+		args = append(args, "--global")
+	}
+	out, err := exec.Command("git", append(args, key, value)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git config %s: %v: %s", key, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// ensureAttributes appends attributesLine to dir/.gitattributes unless the file already assigns
+// attributes to almd-lock.toml, and reports whether it changed the file.
+func ensureAttributes(dir string) (bool, error) {
+	path := filepath.Join(dir, ".gitattributes")
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == lockfile.LockfileName {
+			return false, nil
+		}
+	}
+
+	var b strings.Builder
+	b.Write(content)
+	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+		b.WriteString("\n")
+	}
+	b.WriteString(attributesLine + "\n")
+	return true, os.WriteFile(path, []byte(b.String()), 0644)
+}
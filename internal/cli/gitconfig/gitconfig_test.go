@@ -0,0 +1,53 @@
+package gitconfig
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestEnsureAttributes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".gitattributes")
+	require.NoError(t, os.WriteFile(path, []byte("*.lua text eol=lf"), 0644))
+
+	added, err := ensureAttributes(dir)
+	require.NoError(t, err)
+	assert.True(t, added)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "*.lua text eol=lf\nalmd-lock.toml merge=almd-lock diff=almd-lock\n", string(content))
+
+	added, err = ensureAttributes(dir)
+	require.NoError(t, err)
+	assert.False(t, added, "an existing almd-lock.toml line is left alone")
+}
+
+func TestGitconfigInstall(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	require.NoError(t, exec.Command("git", "init", "-q", dir).Run())
+	originalWD, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer func() { _ = os.Chdir(originalWD) }()
+
+	app := &cli.App{Commands: []*cli.Command{GitconfigCmd()}}
+	require.NoError(t, app.Run([]string{"almd", "gitconfig", "install"}))
+
+	out, err := exec.Command("git", "config", "merge.almd-lock.driver").Output()
+	require.NoError(t, err)
+	assert.Equal(t, "almd lock merge %O %A %B", strings.TrimSpace(string(out)))
+	out, err = exec.Command("git", "check-attr", "merge", "diff", "--", "almd-lock.toml").Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "merge: almd-lock")
+	assert.Contains(t, string(out), "diff: almd-lock")
+}
@@ -0,0 +1,189 @@
+// Package help implements the 'help' command. Besides the usual help for the app and its
+// commands, it shows long-form topics on subsystems (sources, lockfile, hashing, config) whose
+// flag examples are taken from the commands' own flag definitions, so they cannot go stale.
+package help
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// flagRef names a flag of a command; command is a space-separated path such as "lock refresh",
+// empty for a global flag.
+type flagRef struct {
+	command string
+	flag    string
+}
+
+// topic is one 'almd help <topic>' page.
+type topic struct {
+	name    string
+	summary string
+	text    string    // Body, wrapped at 100 columns
+	flags   []flagRef // Flags shown as examples after the body
+}
+
+// HelpCmd returns the 'help' command, which replaces urfave/cli's built-in one.
+func HelpCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "help",
+		Aliases:   []string{"h"},
+		Usage:     "Show help for a command or a topic (" + strings.Join(topicNames(), ", ") + ")",
+		ArgsUsage: "[command | topic]",
+		BashComplete: func(c *cli.Context) {
+			for _, name := range topicNames() {
+				_, _ = fmt.Fprintln(c.App.Writer, name)
+			}
+			for _, cmd := range c.App.VisibleCommands() {
+				_, _ = fmt.Fprintln(c.App.Writer, cmd.Name)
+			}
+		},
+		Action: helpAction,
+	}
+}
+
+func helpAction(c *cli.Context) error {
+	name := c.Args().First()
+	if name == "" {
+		if err := cli.ShowAppHelp(c); err != nil {
+			return err
+		}
+		writeTopicList(c.App.Writer)
+		return nil
+	}
+	if t, ok := findTopic(name); ok {
+		return writeTopic(c.App.Writer, c.App, t)
+	}
+	if c.App.Command(name) != nil {
+		// Look the command up from the app's context, as the built-in help command does.
+		return cli.ShowCommandHelp(c.Lineage()[1], name)
+	}
+	return cli.Exit(fmt.Sprintf("Error: no command or help topic named '%s' (topics: %s)", name, strings.Join(topicNames(), ", ")), 1)
+}
+
+func topicNames() []string {
+	names := make([]string, 0, len(topics))
+	for _, t := range topics {
+		names = append(names, t.name)
+	}
+	return names
+}
+
+func findTopic(name string) (topic, bool) {
+	for _, t := range topics {
+		if t.name == name {
+			return t, true
+		}
+	}
+	return topic{}, false
+}
+
+func writeTopicList(w io.Writer) {
+	_, _ = fmt.Fprintln(w, "\nHELP TOPICS:")
+	for _, t := range topics {
+		_, _ = fmt.Fprintf(w, "   %-10s %s\n", t.name, t.summary)
+	}
+	_, _ = fmt.Fprintln(w, "\nRun 'almd help <topic>' to read one.")
+}
+
+// writeTopic renders t, looking its example flags up in app.
+func writeTopic(w io.Writer, app *cli.App, t topic) error {
+	_, _ = fmt.Fprintf(w, "almd help %s - %s\n\n", t.name, t.summary)
+	for _, line := range strings.Split(strings.TrimSpace(t.text), "\n") {
+		if line == "" {
+			_, _ = fmt.Fprintln(w)
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "   %s\n", line)
+	}
+	if len(t.flags) == 0 {
+		return nil
+	}
+	_, _ = fmt.Fprintln(w, "\nEXAMPLES:")
+	for _, ref := range t.flags {
+		f, err := lookupFlag(app, ref)
+		if err != nil {
+			return err
+		}
+		example, usage := flagExample(ref.command, f)
+		_, _ = fmt.Fprintf(w, "   %s\n      %s\n", example, usage)
+	}
+	return nil
+}
+
+// lookupFlag returns the definition of the flag ref names.
+func lookupFlag(app *cli.App, ref flagRef) (cli.Flag, error) {
+	flags := app.Flags
+	if ref.command != "" {
+		commands := app.Commands
+		var cmd *cli.Command
+		for _, name := range strings.Fields(ref.command) {
+			cmd = nil
+			for _, candidate := range commands {
+				if candidate.HasName(name) {
+					cmd = candidate
+					break
+				}
+			}
+			if cmd == nil {
+				return nil, fmt.Errorf("help topic refers to unknown command 'almd %s'", ref.command)
+			}
+			commands = cmd.Subcommands
+		}
+		flags = cmd.Flags
+	}
+	for _, f := range flags {
+		for _, name := range f.Names() {
+			if name == ref.flag {
+				return f, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("help topic refers to unknown flag 'almd %s --%s'", ref.command, ref.flag)
+}
+
+// placeholderPattern matches the `NAME` a flag's usage gives its value, as urfave/cli does.
+var placeholderPattern = regexp.MustCompile("`([^`]+)`")
+
+// flagExample returns an example invocation of command with f and the flag's usage, including
+// its environment variables and default value.
+func flagExample(command string, f cli.Flag) (example, usage string) {
+	parts := []string{"almd"}
+	if command != "" {
+		parts = append(parts, command)
+	}
+	parts = append(parts, "--"+f.Names()[0])
+
+	doc, ok := f.(cli.DocGenerationFlag)
+	if !ok {
+		return strings.Join(parts, " "), ""
+	}
+	usage = doc.GetUsage()
+	if doc.TakesValue() {
+		placeholder := strings.ToUpper(f.Names()[0])
+		if m := placeholderPattern.FindStringSubmatch(usage); m != nil {
+			placeholder = m[1]
+		}
+		parts = append(parts, placeholder)
+	}
+	if command == "" {
+		parts = append(parts, "<command>")
+	}
+	usage = placeholderPattern.ReplaceAllString(usage, "$1")
+
+	var notes []string
+	for _, env := range doc.GetEnvVars() {
+		notes = append(notes, "$"+env)
+	}
+	if doc.TakesValue() && doc.GetValue() != "" {
+		notes = append(notes, "default: "+doc.GetValue())
+	}
+	if len(notes) > 0 {
+		usage += " (" + strings.Join(notes, ", ") + ")"
+	}
+	return strings.Join(parts, " "), usage
+}
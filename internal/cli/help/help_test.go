@@ -0,0 +1,73 @@
+package help_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/cli/add"
+	"github.com/nightconcept/almandine/internal/cli/help"
+	"github.com/nightconcept/almandine/internal/cli/install"
+	"github.com/nightconcept/almandine/internal/cli/lock"
+	"github.com/nightconcept/almandine/internal/cli/verify"
+)
+
+// runHelp runs almd with args against the commands the topics refer to and returns its output.
+func runHelp(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	app := &cli.App{
+		Name:   "almd",
+		Writer: &out,
+		// Stand-ins for the global flags defined in main.
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "manifest", EnvVars: []string{"ALMD_MANIFEST"}, Usage: "Use `FILE` as the project manifest"},
+			&cli.StringFlag{Name: "cache-dir", Usage: "Directory for cached downloads"},
+			&cli.StringFlag{Name: "config-dir", Usage: "Directory for user configuration"},
+			&cli.StringFlag{Name: "state-dir", Usage: "Directory for persistent state"},
+			&cli.IntFlag{Name: "api-cache-minutes", Usage: "Reuse GitHub API responses for `N` minutes"},
+		},
+		Commands:       []*cli.Command{add.AddCmd(), install.InstallCmd(), lock.LockCmd(), verify.VerifyCmd(), help.HelpCmd()},
+		ExitErrHandler: func(*cli.Context, error) {},
+	}
+	err := app.Run(append([]string{"almd"}, args...))
+	return out.String(), err
+}
+
+func TestHelpTopics(t *testing.T) {
+	for _, topic := range []string{"sources", "lockfile", "hashing", "config"} {
+		t.Run(topic, func(t *testing.T) {
+			out, err := runHelp(t, "help", topic)
+			require.NoError(t, err, "every flag a topic refers to must exist")
+			assert.Contains(t, out, "almd help "+topic+" - ")
+			assert.Contains(t, out, "EXAMPLES:")
+		})
+	}
+
+	out, err := runHelp(t, "help", "config")
+	require.NoError(t, err)
+	assert.Contains(t, out, "almd --manifest FILE <command>\n      Use FILE as the project manifest ($ALMD_MANIFEST)")
+	assert.Contains(t, out, "almd add --directory DIRECTORY\n      Specify the target directory for the dependency (default: src/lib/)")
+
+	out, err = runHelp(t, "help", "lockfile")
+	require.NoError(t, err)
+	assert.Contains(t, out, "almd lock refresh --resolve\n")
+}
+
+func TestHelpCommand(t *testing.T) {
+	out, err := runHelp(t, "help")
+	require.NoError(t, err)
+	assert.Contains(t, out, "COMMANDS:")
+	assert.Contains(t, out, "HELP TOPICS:\n   sources ")
+
+	out, err = runHelp(t, "help", "verify")
+	require.NoError(t, err)
+	assert.Contains(t, out, "almd verify - Check vendored dependency files against the lockfile")
+
+	_, err = runHelp(t, "help", "nonexistent")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no command or help topic named 'nonexistent'")
+}
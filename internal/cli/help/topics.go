@@ -0,0 +1,117 @@
+package help
+
+// topics are the pages of 'almd help <topic>', in the order 'almd help' lists them.
+var topics = []topic{
+	{
+		name:    "sources",
+		summary: "Source syntaxes accepted by 'almd add' and project.toml",
+		text: `
+A dependency's source names one file in a repository, or a plain URL. Hosted repositories are
+written as <provider>:<owner>/<repo>/<path>@<ref> or as the URL of the file on the host:
+
+  github:owner/repo/lib/file.lua@v1.2.0        https://github.com/owner/repo/blob/v1.2.0/lib/file.lua
+  gitlab:group/project/file.lua@main           https://gitlab.com/group/project/-/blob/main/file.lua
+  bitbucket:workspace/repo/file.lua@main       https://bitbucket.org/workspace/repo/src/main/file.lua
+  codeberg:owner/repo/file.lua@main            https://codeberg.org/owner/repo/src/branch/main/file.lua
+  git+https://git.example.com/owner/repo.git#path=lib/util.lua&ref=v1.2.0
+  https://gist.github.com/user/<id>            file:../shared/util.lua
+
+The ref is a branch, tag or commit. Prefix it with branch:, tag: or commit: when a name is
+ambiguous. A glob (@v1.*) or a semantic version range (@^1.2, @~1.2.3, @>=1.0 <2) picks the
+newest matching tag. Refs resolve to the commit that last changed the file, which the lockfile
+pins. Sources without commits (plain URLs and file: paths) are pinned by content hash instead.
+
+A GitHub directory, github:owner/repo/lib/pl/@v1.2.0 or its /tree/ URL, vendors every file below
+it as one dependency, listed under files in project.toml and locked to a single commit. A
+plain URL or file: path ending in .tar.gz, .tgz or .zip is an archive whose files are extracted
+the same way, all of them or those --extract selects, and locked by the archive's content hash.
+
+Self-hosted Gitea instances and GitHub Enterprise Server hosts are configured in project.toml
+([providers.gitea] and [github_enterprise.<alias>]) and then take the same forms.`,
+		flags: []flagRef{
+			{"add", "name"},
+			{"add", "directory"},
+			{"add", "ext"},
+			{"add", "extract"},
+			{"add", "from-file"},
+			{"install", "tag-fallback"},
+		},
+	},
+	{
+		name:    "lockfile",
+		summary: "What almd-lock.toml records and the commands that maintain it",
+		text: `
+almd-lock.toml pins every dependency so that 'almd install' reproduces the same files. Each
+[package.<name>] entry records:
+
+  source            The source as written in project.toml
+  path              Where the file is vendored
+  hash              commit:<sha> for sources with commits, sha256:<hex> otherwise
+  transform         The transform applied on download, if any
+  transformed_hash  sha256:<hex> of the file as written when it differs from upstream
+  install           The install mode (link or generate) when the file is not copied
+  files             sha256:<hex> of each file of a directory or archive dependency
+  extract           The extract specs an archive dependency's files were extracted with
+
+'almd install' updates entries whose source changed and leaves the others alone; a frozen
+install fails instead of modifying the lockfile. 'almd lock refresh' rebuilds the lockfile
+from the files on disk, and 'almd lock sign' stamps it with a checksum of its content so hand
+edits are detected. An entry whose hash lacks a known prefix is malformed: install treats it as
+unlocked and rebuilds it, and 'almd lock repair' rebuilds it in place. 'almd gitconfig install' registers 'almd lock merge' as the git merge
+driver for lockfile conflicts.`,
+		flags: []flagRef{
+			{"install", "frozen"},
+			{"lock refresh", "resolve"},
+			{"lock refresh", "dry-run"},
+			{"lock sign", "remove"},
+			{"lock repair", "dry-run"},
+		},
+	},
+	{
+		name:    "hashing",
+		summary: "How vendored files are hashed and verified",
+		text: `
+Every vendored file is checked against the hash in its lock entry:
+
+  commit:<sha>     The file must match the content of its path at that commit upstream.
+  sha256:<hex>     The SHA-256 of the file must match; used for plain URLs, file: sources and
+                   files registered without a download.
+
+A transform or a generate script changes what is written, so the lockfile also records the
+transformed_hash of the written file, which is what 'almd verify' compares. The header almd
+adds to vendored files is not part of the hash. 'almd verify' skips files whose size and
+modification time are unchanged since they were last hashed unless asked to re-read them.`,
+		flags: []flagRef{
+			{"add", "no-download"},
+			{"add", "lock-only"},
+			{"add", "transform"},
+			{"verify", "full"},
+		},
+	},
+	{
+		name:    "config",
+		summary: "Where settings come from and which one wins",
+		text: `
+A setting is taken from the first of these that sets it:
+
+  1. A command-line flag
+  2. Its environment variable (ALMD_*, GITHUB_TOKEN)
+  3. The project: [vendor] and other tables in project.toml (or almd.toml)
+  4. The global config.toml in the config directory (see --config-dir)
+  5. The built-in default
+
+For example, 'almd add' saves files to --directory, else [vendor] lib_dir in project.toml, else
+lib_dir in config.toml, else the flag's default. The GitHub token comes from $GITHUB_TOKEN, then
+the git credential helper when credential_helper is set, then github_token in config.toml.
+The cache, config and state directories follow $XDG_CACHE_HOME, $XDG_CONFIG_HOME and
+$XDG_STATE_HOME unless overridden.`,
+		flags: []flagRef{
+			{"", "manifest"},
+			{"", "cache-dir"},
+			{"", "config-dir"},
+			{"", "state-dir"},
+			{"", "api-cache-minutes"},
+			{"add", "directory"},
+		},
+	},
+}
@@ -0,0 +1,143 @@
+// Package ide implements the 'ide' command, which adds the directories dependencies are
+// vendored into to the Lua language server's workspace.library, so editors resolve require()
+// of vendored modules without further setup.
+package ide
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+)
+
+// target is an editor settings file and the key of the library list in it.
+type target struct {
+	file string
+	key  string
+}
+
+// targets are the settings files 'ide' can write, by --target name.
+var targets = map[string]target{
+	"luarc":  {file: ".luarc.json", key: "workspace.library"},
+	"vscode": {file: filepath.Join(".vscode", "settings.json"), key: "Lua.workspace.library"},
+}
+
+// IdeCmd returns the 'ide' command.
+func IdeCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "ide",
+		Usage: "Add the vendored directories to the Lua language server's workspace.library",
+		Description: "Creates or updates .luarc.json (--target luarc) or .vscode/settings.json (--target vscode)\n" +
+			"so the language server finds vendored modules. Other settings, and library entries added by\n" +
+			"hand, are kept.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "target", Aliases: []string{"t"}, Value: "luarc", Usage: "Settings file to write: " + targetNames()},
+		},
+		Action: ideAction,
+	}
+}
+
+func ideAction(c *cli.Context) error {
+	t, ok := targets[c.String("target")]
+	if !ok {
+		return cli.Exit(fmt.Sprintf("Error: unknown --target '%s' (use %s)", c.String("target"), targetNames()), exitcode.Usage)
+	}
+	proj, err := config.LoadProjectToml(".")
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error loading %s: %v", config.ManifestName(), err), exitcode.Usage)
+	}
+	dirs := proj.DependencyDirs()
+	if len(dirs) == 0 {
+		_, _ = fmt.Fprintf(os.Stdout, "No dependencies in %s; %s was not changed.\n", config.ManifestName(), filepath.ToSlash(t.file))
+		return nil
+	}
+
+	added, err := updateLibrary(t.file, t.key, dirs)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error updating %s: %v", filepath.ToSlash(t.file), err), exitcode.Usage)
+	}
+	if len(added) == 0 {
+		_, _ = fmt.Fprintf(os.Stdout, "%s already lists every vendored directory.\n", filepath.ToSlash(t.file))
+		return nil
+	}
+	_, _ = fmt.Fprintf(os.Stdout, "Added %s to %s in %s.\n", strings.Join(added, ", "), t.key, filepath.ToSlash(t.file))
+	return nil
+}
+
+// updateLibrary adds the directories of dirs that are missing from the list at key in the JSON
+// settings file at file, creating the file if needed, and returns those it added. The rest of
+// the file is kept, though its keys are written back in sorted order.
+func updateLibrary(file, key string, dirs []string) ([]string, error) {
+	settings := map[string]any{}
+	data, err := os.ReadFile(file)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, &settings); err != nil {
+			return nil, fmt.Errorf("not valid JSON (comments are not supported): %w", err)
+		}
+	}
+
+	container, leaf := libraryContainer(settings, key)
+	var library []any
+	if existing, ok := container[leaf]; ok {
+		if library, ok = existing.([]any); !ok {
+			return nil, fmt.Errorf("%s is not a list", key)
+		}
+	}
+	var added []string
+	for _, dir := range dirs {
+		if !slices.Contains(library, any(dir)) {
+			library = append(library, dir)
+			added = append(added, dir)
+		}
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+	container[leaf] = library
+
+	out, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if dir := filepath.Dir(file); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return added, os.WriteFile(file, append(out, '\n'), 0o644)
+}
+
+// libraryContainer finds the object holding the dotted key. Settings files may spell
+// "workspace.library" as one key or nest it as {"workspace": {"library": ...}}; an existing
+// nested object is followed, otherwise the rest of the key is used as is.
+func libraryContainer(settings map[string]any, key string) (map[string]any, string) {
+	if prefix, rest, ok := strings.Cut(key, "."); ok {
+		if nested, isObject := settings[prefix].(map[string]any); isObject {
+			return libraryContainer(nested, rest)
+		}
+	}
+	return settings, key
+}
+
+func targetNames() string {
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, " or ")
+}
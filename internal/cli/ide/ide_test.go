@@ -0,0 +1,84 @@
+package ide
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+const ideProjectToml = `[package]
+name = "game"
+version = "0.1.0"
+
+[dependencies]
+json = { source = "https://example.com/json.lua", path = "src/lib/json.lua" }
+inspect = { source = "https://example.com/inspect.lua", path = "vendor/inspect.lua" }
+`
+
+// runIdeCommand runs 'ide' with args in dir.
+func runIdeCommand(t *testing.T, dir string, args ...string) error {
+	t.Helper()
+	t.Chdir(dir)
+	var exitErr error
+	app := &cli.App{Commands: []*cli.Command{IdeCmd()}, ExitErrHandler: func(_ *cli.Context, err error) { exitErr = err }}
+	if err := app.Run(append([]string{"almd", "ide"}, args...)); err != nil {
+		return err
+	}
+	return exitErr
+}
+
+func readJSON(t *testing.T, path string) map[string]any {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var settings map[string]any
+	require.NoError(t, json.Unmarshal(data, &settings))
+	return settings
+}
+
+func TestIdeCommand_Luarc(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "project.toml"), []byte(ideProjectToml), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".luarc.json"),
+		[]byte(`{"runtime": {"version": "LuaJIT"}, "workspace": {"library": ["/usr/share/love"]}}`), 0644))
+
+	require.NoError(t, runIdeCommand(t, dir))
+	settings := readJSON(t, filepath.Join(dir, ".luarc.json"))
+	assert.Equal(t, map[string]any{"version": "LuaJIT"}, settings["runtime"])
+	assert.Equal(t, map[string]any{"library": []any{"/usr/share/love", "src/lib", "vendor"}}, settings["workspace"])
+
+	// A second run leaves the file as it is.
+	before, err := os.ReadFile(filepath.Join(dir, ".luarc.json"))
+	require.NoError(t, err)
+	require.NoError(t, runIdeCommand(t, dir, "--target", "luarc"))
+	after, err := os.ReadFile(filepath.Join(dir, ".luarc.json"))
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
+}
+
+func TestIdeCommand_VSCode(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "project.toml"), []byte(ideProjectToml), 0644))
+
+	require.NoError(t, runIdeCommand(t, dir, "--target", "vscode"))
+	settings := readJSON(t, filepath.Join(dir, ".vscode", "settings.json"))
+	assert.Equal(t, []any{"src/lib", "vendor"}, settings["Lua.workspace.library"])
+}
+
+func TestIdeCommand_Errors(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "project.toml"), []byte(ideProjectToml), 0644))
+
+	assert.ErrorContains(t, runIdeCommand(t, dir, "--target", "vim"), "unknown --target 'vim'")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".luarc.json"), []byte("{\n  // comment\n}"), 0644))
+	assert.ErrorContains(t, runIdeCommand(t, dir), "not valid JSON")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".luarc.json"), []byte(`{"workspace.library": "lib"}`), 0644))
+	assert.ErrorContains(t, runIdeCommand(t, dir), "workspace.library is not a list")
+}
@@ -0,0 +1,121 @@
+package init
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// defaultLibDir mirrors the default of 'add --directory'.
+const defaultLibDir = "src/lib"
+
+// layout is what init proposes for an existing directory, and the files that suggested it.
+type layout struct {
+	Name     string
+	Version  string
+	Scripts  map[string]string
+	LibDir   string
+	Evidence []string
+}
+
+// detectLayout looks for the files of common Lua project layouts in dir: a rockspec, a LÖVE
+// game (main.lua next to conf.lua), src/main.lua or a bare main.lua, vendored libraries and
+// busted specs. Without any of them it proposes the defaults of a new project.
+func detectLayout(dir string) layout {
+	l := layout{
+		Name:    "my-almandine-project",
+		Version: "0.1.0",
+		Scripts: map[string]string{"run": "lua src/main.lua"},
+		LibDir:  defaultLibDir,
+	}
+	exists := func(rel string) bool {
+		_, err := os.Stat(filepath.Join(dir, rel))
+		return err == nil
+	}
+
+	name, version, rockspec, hasRockspec := findRockspec(dir)
+	if hasRockspec {
+		l.Evidence = append(l.Evidence, rockspec)
+		if version != "" {
+			l.Version = version
+		}
+	}
+
+	switch {
+	case exists("main.lua") && exists("conf.lua"):
+		l.Scripts["run"] = "love ."
+		l.LibDir = "lib"
+		l.Evidence = append(l.Evidence, "main.lua", "conf.lua")
+	case exists("src/main.lua"):
+		l.Evidence = append(l.Evidence, "src/main.lua")
+	case exists("main.lua"):
+		l.Scripts["run"] = "lua main.lua"
+		l.LibDir = "lib"
+		l.Evidence = append(l.Evidence, "main.lua")
+	case hasLuaFiles(dir):
+		l.Evidence = append(l.Evidence, "*.lua")
+	}
+
+	if libDir := findLibDir(dir); libDir != "" {
+		l.LibDir = libDir
+		l.Evidence = append(l.Evidence, libDir+"/")
+	}
+	if exists("spec") || exists(".busted") {
+		l.Scripts["test"] = "busted"
+		l.Evidence = append(l.Evidence, "spec/")
+	}
+
+	// A rockspec names the package; otherwise an existing project is named after its directory.
+	if hasRockspec {
+		l.Name = name
+	} else if abs, err := filepath.Abs(dir); err == nil && len(l.Evidence) > 0 && filepath.Dir(abs) != abs {
+		l.Name = filepath.Base(abs)
+	}
+	return l
+}
+
+// findRockspec returns the package name and version of the first rockspec in dir. Rockspec
+// files are named <package>-<version>-<revision>.rockspec; "scm" and "dev" versions are ignored.
+func findRockspec(dir string) (name, version, file string, ok bool) {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.rockspec"))
+	if len(matches) == 0 {
+		return "", "", "", false
+	}
+	sort.Strings(matches)
+	file = filepath.Base(matches[0])
+	parts := strings.Split(strings.TrimSuffix(file, ".rockspec"), "-")
+	if len(parts) < 3 {
+		return strings.Join(parts, "-"), "", file, true
+	}
+	name = strings.Join(parts[:len(parts)-2], "-")
+	version = parts[len(parts)-2]
+	if version == "scm" || version == "dev" {
+		version = ""
+	}
+	return name, version, file, true
+}
+
+// findLibDir returns the first conventional library directory in dir that holds Lua files.
+func findLibDir(dir string) string {
+	for _, candidate := range []string{"src/lib", "lib", "libs", "vendor"} {
+		if hasLuaFiles(filepath.Join(dir, candidate)) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// hasLuaFiles reports whether dir directly contains a .lua file.
+func hasLuaFiles(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".lua") {
+			return true
+		}
+	}
+	return false
+}
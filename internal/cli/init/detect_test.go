@@ -0,0 +1,67 @@
+package init
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, dir string, files ...string) {
+	t.Helper()
+	for _, rel := range files {
+		path := filepath.Join(dir, rel)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte("-- "+rel), 0644))
+	}
+}
+
+func TestDetectLayout(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       []string
+		wantScripts map[string]string
+		wantLibDir  string
+	}{
+		{name: "empty", wantScripts: map[string]string{"run": "lua src/main.lua"}, wantLibDir: "src/lib"},
+		{name: "love", files: []string{"main.lua", "conf.lua"}, wantScripts: map[string]string{"run": "love ."}, wantLibDir: "lib"},
+		{name: "src", files: []string{"src/main.lua", "src/lib/json.lua", "spec/main_spec.lua"},
+			wantScripts: map[string]string{"run": "lua src/main.lua", "test": "busted"}, wantLibDir: "src/lib"},
+		{name: "flat", files: []string{"main.lua", "vendor/inspect.lua"}, wantScripts: map[string]string{"run": "lua main.lua"}, wantLibDir: "vendor"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "game")
+			require.NoError(t, os.Mkdir(dir, 0755))
+			writeFiles(t, dir, tt.files...)
+
+			detected := detectLayout(dir)
+			assert.Equal(t, tt.wantScripts, detected.Scripts)
+			assert.Equal(t, tt.wantLibDir, detected.LibDir)
+			if len(tt.files) == 0 {
+				assert.Empty(t, detected.Evidence)
+				assert.Equal(t, "my-almandine-project", detected.Name)
+			} else {
+				assert.Equal(t, "game", detected.Name, "existing projects are named after their directory")
+			}
+		})
+	}
+}
+
+func TestDetectLayout_Rockspec(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "lua-cjson-2.1.0-1.rockspec", "src/main.lua")
+
+	detected := detectLayout(dir)
+	assert.Equal(t, "lua-cjson", detected.Name)
+	assert.Equal(t, "2.1.0", detected.Version)
+	assert.Equal(t, []string{"lua-cjson-2.1.0-1.rockspec", "src/main.lua"}, detected.Evidence)
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "lua-cjson-2.1.0-1.rockspec")))
+	writeFiles(t, dir, "mylib-scm-1.rockspec")
+	detected = detectLayout(dir)
+	assert.Equal(t, "mylib", detected.Name)
+	assert.Equal(t, "0.1.0", detected.Version, "scm versions fall back to the default")
+}
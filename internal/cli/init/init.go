@@ -6,11 +6,29 @@ import (
 	"os"
 	"strings"
 
+	"github.com/nightconcept/almandine/internal/cli/install"
 	"github.com/nightconcept/almandine/internal/core/config"
 	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/theme"
 	"github.com/urfave/cli/v2"
 )
 
+// printCollectedMetadata echoes the answers given to the prompts, without rules in plain mode.
+func printCollectedMetadata(packageName, version, license, description string) {
+	if theme.Plain() {
+		fmt.Println("\nCollected Metadata:")
+	} else {
+		fmt.Println("\n--- Collected Metadata ---")
+	}
+	fmt.Printf("Package Name: %s\n", packageName)
+	fmt.Printf("Version:      %s\n", version)
+	fmt.Printf("License:      %s\n", license)
+	fmt.Printf("Description:  %s\n", description)
+	if !theme.Plain() {
+		fmt.Println("--------------------------")
+	}
+}
+
 // promptWithDefault asks the user for input and returns the entered value or a default if input is empty.
 // Returns an error if reading input fails.
 func promptWithDefault(reader *bufio.Reader, promptText string, defaultValue string) (string, error) {
@@ -36,64 +54,137 @@ func InitCmd() *cli.Command {
 	return &cli.Command{
 		Name:  "init",
 		Usage: "Initialize a new Almandine project (creates project.toml)",
-		Action: func(c *cli.Context) error {
-			fmt.Println("Starting project initialization...")
-
-			reader := bufio.NewReader(os.Stdin)
-
-			var packageName, version, license, description string
-			var err error
-
-			packageName, err = promptWithDefault(reader, "Package name", "my-almandine-project")
-			if err != nil {
-				return cli.Exit(err.Error(), 1)
-			}
-
-			version, err = promptWithDefault(reader, "Version", "0.1.0")
-			if err != nil {
-				return cli.Exit(err.Error(), 1)
-			}
-
-			license, err = promptWithDefault(reader, "License", "MIT")
-			if err != nil {
-				return cli.Exit(err.Error(), 1)
-			}
-
-			description, err = promptWithDefault(reader, "Description (optional)", "")
-			if err != nil {
-				return cli.Exit(err.Error(), 1)
-			}
-
-			fmt.Println("\n--- Collected Metadata ---")
-			fmt.Printf("Package Name: %s\n", packageName)
-			fmt.Printf("Version:      %s\n", version)
-			fmt.Printf("License:      %s\n", license)
-			fmt.Printf("Description:  %s\n", description)
-			fmt.Println("--------------------------")
-
-			scripts := make(map[string]string)
-
-			if _, exists := scripts["run"]; !exists {
-				scripts["run"] = "lua src/main.lua"
-			}
-
-			projectData := project.Project{
-				Package: &project.PackageInfo{
-					Name:        packageName,
-					Version:     version,
-					License:     license,
-					Description: description,
-				},
-				Scripts: scripts,
-			}
-
-			err = config.WriteProjectToml(".", &projectData)
-			if err != nil {
-				return cli.Exit(fmt.Sprintf("Error writing project.toml: %v", err), 1)
-			}
-
-			fmt.Println("\nSuccessfully initialized project and wrote project.toml.")
-			return nil
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "force", Aliases: []string{"f"}, Usage: "Overwrite an existing project.toml"},
+			&cli.StringFlag{Name: "from", Usage: "Start from a template project.toml, e.g. github:owner/repo@ref or github:owner/repo/dir@ref"},
+			&cli.StringFlag{Name: "name", Usage: "Package name (skips the prompt)"},
+			&cli.StringFlag{Name: "version", Usage: "Package version (skips the prompt)"},
+			&cli.BoolFlag{Name: "install", Usage: "Install the dependencies of the new project.toml"},
 		},
+		Action: initAction,
+	}
+}
+
+func initAction(c *cli.Context) error {
+	if _, err := os.Stat(config.ManifestPath(".")); err == nil && !c.Bool("force") {
+		return cli.Exit("Error: project.toml already exists in the current directory. Use --force to overwrite it.", 1)
+	}
+
+	fmt.Println("Starting project initialization...")
+	detected := detectLayout(".")
+	if len(detected.Evidence) > 0 {
+		fmt.Printf("Detected an existing project (%s); defaults below are based on it.\n", strings.Join(detected.Evidence, ", "))
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	var projectData *project.Project
+	var err error
+	if from := c.String("from"); from != "" {
+		projectData, err = projectFromTemplate(c, reader, from, detected)
+	} else {
+		projectData, err = promptProject(c, reader, detected)
+	}
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	err = config.WriteProjectToml(".", projectData)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error writing project.toml: %v", err), 1)
+	}
+
+	fmt.Println("\nSuccessfully initialized project and wrote project.toml.")
+	if c.Bool("install") && len(projectData.Dependencies) > 0 {
+		fmt.Println("\nInstalling dependencies...")
+		return runInstall()
+	}
+	return nil
+}
+
+// runInstall runs 'almd install' in-process for the project just written.
+func runInstall() error {
+	cmd := install.InstallCmd()
+	app := &cli.App{
+		Name:           "almd",
+		Commands:       []*cli.Command{cmd},
+		ExitErrHandler: func(_ *cli.Context, _ error) {}, // Leave the exit to the outer app
+	}
+	return app.Run([]string{"almd", cmd.Name})
+}
+
+// projectFromTemplate fetches the template project.toml and replaces its package name and
+// version, taken from --name/--version or asked for. Everything else (scripts, profiles,
+// vendor settings and dependencies) is kept as the template defines it.
+func projectFromTemplate(c *cli.Context, reader *bufio.Reader, from string, detected layout) (*project.Project, error) {
+	fmt.Printf("Fetching template %s...\n", from)
+	template, err := fetchTemplate(from)
+	if err != nil {
+		return nil, err
+	}
+
+	if template.Package.Name, err = flagOrPrompt(c, reader, "name", "Package name", detected.Name); err != nil {
+		return nil, err
+	}
+	defaultVersion := detected.Version
+	if template.Package.Version != "" {
+		defaultVersion = template.Package.Version
+	}
+	if template.Package.Version, err = flagOrPrompt(c, reader, "version", "Version", defaultVersion); err != nil {
+		return nil, err
+	}
+
+	printCollectedMetadata(template.Package.Name, template.Package.Version, template.Package.License, template.Package.Description)
+	return template, nil
+}
+
+// flagOrPrompt returns the value of the named flag when it is set, and otherwise prompts for it.
+func flagOrPrompt(c *cli.Context, reader *bufio.Reader, flagName, promptText, defaultValue string) (string, error) {
+	if c.IsSet(flagName) {
+		return c.String(flagName), nil
+	}
+	return promptWithDefault(reader, promptText, defaultValue)
+}
+
+// promptProject asks for the package metadata and library directory, offering the detected
+// layout as defaults, and builds the project to write.
+func promptProject(c *cli.Context, reader *bufio.Reader, detected layout) (*project.Project, error) {
+	packageName, err := flagOrPrompt(c, reader, "name", "Package name", detected.Name)
+	if err != nil {
+		return nil, err
+	}
+	version, err := flagOrPrompt(c, reader, "version", "Version", detected.Version)
+	if err != nil {
+		return nil, err
+	}
+	license, err := promptWithDefault(reader, "License", "MIT")
+	if err != nil {
+		return nil, err
+	}
+	description, err := promptWithDefault(reader, "Description (optional)", "")
+	if err != nil {
+		return nil, err
+	}
+	libDir, err := promptWithDefault(reader, "Library directory for 'add'", detected.LibDir)
+	if err != nil {
+		return nil, err
+	}
+
+	printCollectedMetadata(packageName, version, license, description)
+
+	projectData := &project.Project{
+		Package: &project.PackageInfo{
+			Name:        packageName,
+			Version:     version,
+			License:     license,
+			Description: description,
+		},
+		Scripts: make(map[string]project.Script, len(detected.Scripts)),
+	}
+	for name, cmd := range detected.Scripts {
+		projectData.Scripts[name] = project.Script{Cmd: cmd}
+	}
+	if libDir = strings.TrimSuffix(libDir, "/"); libDir != defaultLibDir {
+		projectData.Vendor = &project.VendorSettings{LibDir: libDir}
 	}
+	return projectData, nil
 }
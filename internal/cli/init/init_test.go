@@ -108,8 +108,8 @@ func TestInitCommand(t *testing.T) {
 	assert.Equal(t, "Apache-2.0", generatedConfig.Package.License, "License mismatch")
 	assert.Equal(t, "A test project", generatedConfig.Package.Description, "Description mismatch")
 
-	expectedScripts := map[string]string{
-		"run": "lua src/main.lua",
+	expectedScripts := map[string]project.Script{
+		"run": {Cmd: "lua src/main.lua"},
 	}
 	assert.Equal(t, expectedScripts, generatedConfig.Scripts, "Scripts mismatch")
 }
@@ -172,10 +172,47 @@ func TestInitCommand_DefaultsAndEmpty(t *testing.T) {
 	assert.Equal(t, "MIT", generatedConfig.Package.License, "License mismatch (default expected)")
 	assert.Equal(t, "", generatedConfig.Package.Description, "Description should be empty")
 
-	expectedScripts := map[string]string{
-		"run": "lua src/main.lua",
+	expectedScripts := map[string]project.Script{
+		"run": {Cmd: "lua src/main.lua"},
 	}
 	assert.Equal(t, expectedScripts, generatedConfig.Scripts, "Scripts mismatch (only default expected)")
 
 	assert.Nil(t, generatedConfig.Dependencies, "Dependencies should be nil/omitted")
 }
+
+// TestInitCommand_ExistingProject verifies that init proposes defaults from the files it finds
+// and refuses to overwrite project.toml unless --force is given.
+func TestInitCommand_ExistingProject(t *testing.T) {
+	tempDir := t.TempDir()
+	originalWd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(tempDir))
+	defer func() { _ = os.Chdir(originalWd) }()
+	writeFiles(t, tempDir, "main.lua", "conf.lua")
+
+	run := func(args ...string) error {
+		oldStdin, oldStdout := os.Stdin, os.Stdout
+		rStdin, _, err := simulateInput([]string{"", "", "", "", ""}) // Accept every default
+		require.NoError(t, err)
+		devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		require.NoError(t, err)
+		os.Stdin, os.Stdout = rStdin, devNull
+		defer func() { os.Stdin, os.Stdout = oldStdin, oldStdout; _ = rStdin.Close(); _ = devNull.Close() }()
+
+		app := &cli.App{Name: "almandine-test", Commands: []*cli.Command{InitCmd()}, ExitErrHandler: func(*cli.Context, error) {}}
+		return app.Run(append([]string{"almandine-test", "init"}, args...))
+	}
+
+	require.NoError(t, run())
+	var generatedConfig project.Project
+	_, err = toml.DecodeFile(filepath.Join(tempDir, "project.toml"), &generatedConfig)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Base(tempDir), generatedConfig.Package.Name)
+	assert.Equal(t, map[string]project.Script{"run": {Cmd: "love ."}}, generatedConfig.Scripts)
+	assert.Equal(t, "lib", generatedConfig.VendorLibDir())
+
+	err = run()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "project.toml already exists")
+	require.NoError(t, run("--force"))
+}
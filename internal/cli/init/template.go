@@ -0,0 +1,57 @@
+package init
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/downloader"
+	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+// templateSourceURL expands the --from argument to the source of a project.toml. A GitHub
+// shorthand may name a repository or a directory in it ("github:owner/repo@v1",
+// "github:owner/templates/lua-game@main"); project.toml is appended unless the path already
+// names a .toml file, and a missing ref selects the default branch. Other URLs are used as is.
+func templateSourceURL(from string) string {
+	if !strings.HasPrefix(from, "github:") {
+		return from
+	}
+	location, ref, found := strings.Cut(strings.TrimPrefix(from, "github:"), "@")
+	if !found || ref == "" {
+		ref = "HEAD"
+	}
+	location = strings.TrimSuffix(location, "/")
+	if !strings.HasSuffix(location, ".toml") {
+		location += "/" + config.ProjectTomlName
+	}
+	return "github:" + location + "@" + ref
+}
+
+// fetchTemplate downloads and parses the project.toml named by from.
+func fetchTemplate(from string) (*project.Project, error) {
+	parsed, err := source.ParseSourceURL(templateSourceURL(from))
+	if err != nil {
+		return nil, fmt.Errorf("invalid template '%s': %w", from, err)
+	}
+	content, err := downloader.DownloadFile(parsed.RawURL)
+	if err != nil {
+		return nil, fmt.Errorf("downloading template from '%s': %w", parsed.RawURL, err)
+	}
+
+	var template project.Project
+	if _, err := toml.NewDecoder(bytes.NewReader(content)).Decode(&template); err != nil {
+		return nil, fmt.Errorf("template '%s' is not a valid project.toml: %w", from, err)
+	}
+	if err := project.ValidateDependencyNames(template.Dependencies); err != nil {
+		return nil, fmt.Errorf("template '%s': %w", from, err)
+	}
+	if template.Package == nil {
+		template.Package = &project.PackageInfo{}
+	}
+	return &template, nil
+}
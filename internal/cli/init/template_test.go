@@ -0,0 +1,91 @@
+package init
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/paths"
+	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+func TestTemplateSourceURL(t *testing.T) {
+	for from, want := range map[string]string{
+		"github:acme/template@v1":                 "github:acme/template/project.toml@v1",
+		"github:acme/templates/lua-game/@main":    "github:acme/templates/lua-game/project.toml@main",
+		"github:acme/template":                    "github:acme/template/project.toml@HEAD",
+		"github:acme/template/base.toml@tag:v2":   "github:acme/template/base.toml@tag:v2",
+		"https://example.com/templates/base.toml": "https://example.com/templates/base.toml",
+	} {
+		assert.Equal(t, want, templateSourceURL(from), from)
+	}
+}
+
+func TestInitCommand_FromTemplate(t *testing.T) {
+	depSHA := strings.Repeat("d", 40)
+	templateToml := `
+[package]
+name = "template"
+version = "1.0.0"
+license = "Apache-2.0"
+
+[scripts]
+test = "busted"
+
+[dependencies]
+inspect = { source = "github:kikito/inspect.lua/inspect.lua@` + depSHA + `", path = "lib/inspect.lua" }
+`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/acme/template/v1/project.toml":
+			_, _ = w.Write([]byte(templateToml))
+		case "/kikito/inspect.lua/" + depSHA + "/inspect.lua":
+			_, _ = w.Write([]byte("return {}"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	originalBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = server.URL
+	source.SetTestModeBypassHostValidation(true)
+	defer func() {
+		source.GithubAPIBaseURL = originalBaseURL
+		source.SetTestModeBypassHostValidation(false)
+	}()
+	t.Setenv(paths.CacheDirEnv, t.TempDir())
+
+	tempDir := t.TempDir()
+	originalWd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(tempDir))
+	defer func() { _ = os.Chdir(originalWd) }()
+
+	app := &cli.App{Name: "almandine-test", Commands: []*cli.Command{InitCmd()}, ExitErrHandler: func(*cli.Context, error) {}}
+	err = app.Run([]string{"almandine-test", "init", "--from", "github:acme/template@v1", "--name", "my-game", "--version", "0.2.0", "--install"})
+	require.NoError(t, err)
+
+	var generatedConfig project.Project
+	_, err = toml.DecodeFile(filepath.Join(tempDir, "project.toml"), &generatedConfig)
+	require.NoError(t, err)
+	assert.Equal(t, "my-game", generatedConfig.Package.Name)
+	assert.Equal(t, "0.2.0", generatedConfig.Package.Version)
+	assert.Equal(t, "Apache-2.0", generatedConfig.Package.License, "other metadata comes from the template")
+	assert.Equal(t, map[string]project.Script{"test": {Cmd: "busted"}}, generatedConfig.Scripts)
+	assert.Contains(t, generatedConfig.Dependencies, "inspect")
+	assert.FileExists(t, filepath.Join(tempDir, "lib", "inspect.lua"), "--install installs the template's dependencies")
+	assert.FileExists(t, filepath.Join(tempDir, "almd-lock.toml"))
+
+	err = app.Run([]string{"almandine-test", "init", "--force", "--from", "github:acme/missing@v1", "--name", "x", "--version", "1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "downloading template")
+}
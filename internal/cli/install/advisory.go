@@ -0,0 +1,60 @@
+package install
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nightconcept/almandine/internal/core/advisory"
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+	"github.com/nightconcept/almandine/internal/core/warnings"
+)
+
+// guardAdvisories warns about targeted dependencies that a registry's advisories mark deprecated
+// or yanked, and returns the dependencies that may be installed. With --strict a yanked version
+// is not installed: it is recorded as a failure in out and marked Refused in installStates.
+func guardAdvisories(installStates, dependenciesThatNeedAction []dependencyInstallState, opts installOptions, out *outcome) []dependencyInstallState {
+	advisories, err := advisory.Load()
+	if err != nil {
+		warnings.Printf("Could not check for deprecated or yanked dependencies: %v", err)
+	}
+	if len(advisories) == 0 {
+		return dependenciesThatNeedAction
+	}
+
+	pending := make(map[string]bool, len(dependenciesThatNeedAction))
+	for _, dep := range dependenciesThatNeedAction {
+		pending[dep.Name] = true
+	}
+	refused := make(map[string]bool)
+	for _, dep := range installStates {
+		a := advisory.Match(advisories, dep.ProjectTomlSource, advisoryVersion(dep))
+		switch {
+		case a == nil:
+		case a.Yanked() && opts.Strict && pending[dep.Name]:
+			_, _ = fmt.Fprintf(os.Stderr, "Error: Refusing to install %s (--strict)\n", a.Describe(dep.Name))
+			out.fail(dep.Name, exitcode.Integrity)
+			markRefused(installStates, dep.Name, a.Status+" by its registry")
+			refused[dep.Name] = true
+		default:
+			warnings.Printf("%s", a.Describe(dep.Name))
+		}
+	}
+
+	var allowed []dependencyInstallState
+	for _, dep := range dependenciesThatNeedAction {
+		if !refused[dep.Name] {
+			allowed = append(allowed, dep)
+		}
+	}
+	return allowed
+}
+
+// advisoryVersion is the version of dep that advisories are matched against: the commit it
+// resolved to, or else its locked hash with a "commit:" prefix removed.
+func advisoryVersion(dep dependencyInstallState) string {
+	if isCommitSHARegex.MatchString(dep.TargetCommitHash) {
+		return dep.TargetCommitHash
+	}
+	return strings.TrimPrefix(dep.LockedCommitHash, "commit:")
+}
@@ -0,0 +1,90 @@
+package install
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/nightconcept/almandine/internal/core/archive"
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+	"github.com/nightconcept/almandine/internal/core/filemode"
+	"github.com/nightconcept/almandine/internal/core/hasher"
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+	"github.com/nightconcept/almandine/internal/core/logger"
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+// archiveFormat returns the format of the archive the dependency's files are extracted from, or
+// "" when it is not an archive dependency.
+func (s dependencyInstallState) archiveFormat() archive.Format {
+	if len(s.Files) == 0 {
+		return ""
+	}
+	return (&source.ParsedSourceInfo{Provider: s.Provider, PathInRepo: s.PathInRepo}).ArchiveFormat()
+}
+
+// checkExtractChanged reports an archive dependency whose extract specs differ from those its
+// files were extracted with, even when the same files are listed: they may hold other content.
+func checkExtractChanged(state dependencyInstallState, verbose bool) (needsAction bool, reason string) {
+	if state.LockedCommitHash == "" || state.archiveFormat() == "" || slices.Equal(state.Extract, state.LockedExtract) {
+		return false, ""
+	}
+	if verbose {
+		logger.Progressf("  - %s: Needs install/update (extract specs changed).", state.Name)
+	}
+	return true, fmt.Sprintf("Extract specs changed from %q to %q.", state.LockedExtract, state.Extract)
+}
+
+// installArchive installs the files of an archive dependency: the archive is fetched like a
+// single file, and the files its extract specs select are written below its path. Every file
+// project.toml lists must be extracted. The lockfile entry locks the archive by the hash of its
+// content and records the hash of each file.
+func installArchive(dep *dependencyInstallState, downloads *runDownloads, settings filemode.Settings, verbose bool) (*lockfile.PackageEntry, int) {
+	content, code := downloads.fetch(*dep, verbose)
+	if code != exitcode.OK {
+		return nil, code
+	}
+	archiveHash, hashErr := hasher.CalculateSHA256(content)
+	if hashErr != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: Failed to calculate SHA256 hash for dependency '%s': %v\n", dep.Name, hashErr)
+		return nil, exitcode.Usage
+	}
+	extracted, extractErr := archive.Extract(dep.archiveFormat(), content, dep.Extract)
+	if extractErr != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: cannot extract '%s' from %s: %v\n", dep.Name, dep.TargetRawURL, extractErr)
+		return nil, exitcode.Usage
+	}
+
+	fileStates := make([]dependencyInstallState, len(dep.Files))
+	contents := make([][]byte, len(dep.Files))
+	hashes := make(map[string]string, len(dep.Files))
+	for i, file := range dep.Files {
+		data, ok := extracted[file]
+		if !ok {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: '%s' is not extracted from %s for dependency '%s'; check its files and extract specs in project.toml.\n", file, dep.TargetRawURL, dep.Name)
+			return nil, exitcode.Usage
+		}
+		hash, fileHashErr := hasher.CalculateSHA256(data)
+		if fileHashErr != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: Failed to calculate SHA256 hash for '%s' of dependency '%s': %v\n", file, dep.Name, fileHashErr)
+			return nil, exitcode.Usage
+		}
+		fileStates[i] = memberFileState(*dep, file)
+		contents[i], hashes[file] = data, hash
+	}
+
+	if code := writeMemberFiles(dep, fileStates, contents, settings); code != exitcode.OK {
+		return nil, code
+	}
+	dep.FileHashes = hashes
+	if verbose {
+		logger.Progressf("    Successfully extracted %d files of %s to %s", len(dep.Files), dep.Name, dep.ProjectTomlPath)
+	}
+	return &lockfile.PackageEntry{
+		Source:  dep.TargetRawURL,
+		Path:    dep.ProjectTomlPath,
+		Hash:    archiveHash,
+		Files:   hashes,
+		Extract: dep.Extract,
+	}, exitcode.OK
+}
@@ -0,0 +1,142 @@
+package install
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+	"github.com/nightconcept/almandine/internal/core/filemode"
+	"github.com/nightconcept/almandine/internal/core/hasher"
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+	"github.com/nightconcept/almandine/internal/core/logger"
+	"github.com/nightconcept/almandine/internal/core/safepath"
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+// filePaths returns the paths of the files the dependency installs: its path, or for a
+// directory dependency the path of each of its files.
+func (s dependencyInstallState) filePaths() []string {
+	if len(s.Files) == 0 {
+		return []string{s.ProjectTomlPath}
+	}
+	paths := make([]string, 0, len(s.Files))
+	for _, file := range s.Files {
+		paths = append(paths, path.Join(s.ProjectTomlPath, file))
+	}
+	return paths
+}
+
+// staleFilePaths returns the paths of the files the lockfile records for a directory dependency
+// that project.toml no longer lists, which installing it removes.
+func (s dependencyInstallState) staleFilePaths() []string {
+	var stale []string
+	for file := range s.LockedFiles {
+		if !slices.Contains(s.Files, file) {
+			stale = append(stale, path.Join(s.ProjectTomlPath, file))
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+func checkFileListChanged(state dependencyInstallState, verbose bool) (needsAction bool, reason string) {
+	if state.LockedCommitHash == "" || (len(state.Files) == len(state.LockedFiles) && len(state.staleFilePaths()) == 0) {
+		return false, ""
+	}
+	if verbose {
+		logger.Progressf("  - %s: Needs install/update (file list changed).", state.Name)
+	}
+	return true, fmt.Sprintf("Files changed from %d locked to %d listed.", len(state.LockedFiles), len(state.Files))
+}
+
+// installDirectory installs every file of a directory dependency from the commit its ref
+// resolved to. All files are fetched before any is written, so a failed download leaves the
+// directory as it was; files the lockfile recorded that are no longer listed are removed. The
+// lockfile entry locks the directory to the commit and records the hash of each file.
+func installDirectory(dep *dependencyInstallState, downloads *runDownloads, settings filemode.Settings, verbose bool) (*lockfile.PackageEntry, int) {
+	if len(dep.TargetCommitHash) != 40 || !isCommitSHARegex.MatchString(dep.TargetCommitHash) {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: cannot install directory '%s': its ref did not resolve to a commit.\n", dep.Name)
+		return nil, exitcode.Resolution
+	}
+	parsed, err := source.ParseSourceURL(dep.ProjectTomlSource)
+	if err == nil {
+		parsed, err = parsed.AtCommit(dep.TargetCommitHash)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: cannot install directory '%s': %v\n", dep.Name, err)
+		return nil, exitcode.Resolution
+	}
+
+	fileStates := make([]dependencyInstallState, len(dep.Files))
+	contents := make([][]byte, len(dep.Files))
+	hashes := make(map[string]string, len(dep.Files))
+	for i, file := range dep.Files {
+		fileStates[i] = directoryFileState(*dep, parsed, file)
+		content, code := downloads.fetch(fileStates[i], verbose)
+		if code != exitcode.OK {
+			return nil, code
+		}
+		hash, hashErr := hasher.CalculateSHA256(content)
+		if hashErr != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: Failed to calculate SHA256 hash for '%s' of dependency '%s': %v\n", file, dep.Name, hashErr)
+			return nil, exitcode.Usage
+		}
+		contents[i], hashes[file] = content, hash
+	}
+
+	if code := writeMemberFiles(dep, fileStates, contents, settings); code != exitcode.OK {
+		return nil, code
+	}
+	dep.FileHashes = hashes
+	if verbose {
+		logger.Progressf("    Successfully saved %d files of %s to %s", len(dep.Files), dep.Name, dep.ProjectTomlPath)
+	}
+	return &lockfile.PackageEntry{
+		Source: dep.TargetRawURL,
+		Path:   dep.ProjectTomlPath,
+		Hash:   "commit:" + dep.TargetCommitHash,
+		Files:  hashes,
+	}, exitcode.OK
+}
+
+// writeMemberFiles writes the files of a directory or archive dependency, whose contents were
+// all fetched first, then removes the files the lockfile recorded that are no longer listed.
+func writeMemberFiles(dep *dependencyInstallState, fileStates []dependencyInstallState, contents [][]byte, settings filemode.Settings) int {
+	for i := range fileStates {
+		if _, writeErr := writeDependencyFile(fileStates[i], contents[i], settings); writeErr != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", writeErr)
+			return exitcode.Usage
+		}
+	}
+	for _, stale := range dep.staleFilePaths() {
+		if removeErr := filemode.Remove(safepath.LongPath(stale)); removeErr != nil && !errors.Is(removeErr, fs.ErrNotExist) {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: Failed to remove '%s', no longer listed for dependency '%s': %v\n", stale, dep.Name, removeErr)
+			return exitcode.Usage
+		}
+	}
+	return exitcode.OK
+}
+
+// memberFileState returns the state of one file of a directory or archive dependency, so that it
+// is written like a dependency of its own.
+func memberFileState(dep dependencyInstallState, file string) dependencyInstallState {
+	dep.Name = dep.Name + "/" + file
+	dep.ProjectTomlPath = path.Join(dep.ProjectTomlPath, file)
+	dep.Files, dep.LockedFiles, dep.Extract = nil, nil, nil
+	return dep
+}
+
+// directoryFileState returns the state of one file of a directory dependency, so that it is
+// fetched and written like a dependency of its own.
+func directoryFileState(dep dependencyInstallState, pinned *source.ParsedSourceInfo, file string) dependencyInstallState {
+	dep = memberFileState(dep, file)
+	dep.PathInRepo = strings.TrimSuffix(pinned.PathInRepo, "/") + "/" + file
+	dep.TargetRawURL = pinned.FileRawURL(file)
+	return dep
+}
@@ -0,0 +1,98 @@
+package install
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+	"github.com/nightconcept/almandine/internal/core/journal"
+	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/nightconcept/almandine/internal/core/warnings"
+)
+
+// downgrade describes a dependency about to move from its locked commit to an older one.
+type downgrade struct {
+	LockedSHA, TargetSHA string
+	LockedAt, TargetedAt time.Time
+}
+
+// detectDowngrade reports whether installing dep would replace its locked commit with an older
+// one, e.g. after a branch was force-pushed or the ref in project.toml was changed. Only
+// dependencies locked to a commit and resolved to a different commit are checked.
+func detectDowngrade(dep dependencyInstallState) (*downgrade, error) {
+	lockedSHA, locked := strings.CutPrefix(dep.LockedCommitHash, "commit:")
+	if !locked || !isCommitSHARegex.MatchString(lockedSHA) || !isCommitSHARegex.MatchString(dep.TargetCommitHash) ||
+		strings.HasPrefix(dep.TargetCommitHash, lockedSHA) || strings.HasPrefix(lockedSHA, dep.TargetCommitHash) {
+		return nil, nil
+	}
+
+	info := &source.ParsedSourceInfo{Provider: dep.Provider, Owner: dep.Owner, Repo: dep.Repo, PathInRepo: dep.PathInRepo}
+	lockedAt, err := source.CommitDate(info, lockedSHA)
+	if err != nil {
+		return nil, err
+	}
+	targetedAt, err := source.CommitDate(info, dep.TargetCommitHash)
+	if err != nil {
+		return nil, err
+	}
+	if !targetedAt.Before(lockedAt) {
+		return nil, nil
+	}
+	return &downgrade{LockedSHA: lockedSHA, TargetSHA: dep.TargetCommitHash, LockedAt: lockedAt, TargetedAt: targetedAt}, nil
+}
+
+// guardDowngrades returns the dependencies that may be installed. Downgrades are refused,
+// recorded in out and marked Refused in installStates unless --allow-downgrade is set; either
+// way they are logged in the journal, except with --dry-run. Dependencies whose commits cannot
+// be dated are installed with a warning.
+func guardDowngrades(installStates, dependenciesThatNeedAction []dependencyInstallState, opts installOptions, out *outcome) []dependencyInstallState {
+	var allowed []dependencyInstallState
+	for _, dep := range dependenciesThatNeedAction {
+		d, err := detectDowngrade(dep)
+		if err != nil {
+			warnings.Printf("Could not check whether '%s' would be downgraded: %v", dep.Name, err)
+		}
+		if d == nil {
+			allowed = append(allowed, dep)
+			continue
+		}
+
+		detail := fmt.Sprintf("%s (%s) -> %s (%s)", shortSHA(d.LockedSHA), d.LockedAt.Format(time.DateOnly), shortSHA(d.TargetSHA), d.TargetedAt.Format(time.DateOnly))
+		entry := journal.Entry{Event: journal.DowngradeBlocked, Dependency: dep.Name, From: d.LockedSHA, To: d.TargetSHA, Detail: detail}
+		if opts.AllowDowngrade {
+			entry.Event = journal.DowngradeAllowed
+			warnings.Printf("Downgrading '%s' to an older commit: %s (--allow-downgrade).", dep.Name, detail)
+			allowed = append(allowed, dep)
+		} else {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: Refusing to downgrade '%s' to an older commit: %s. The branch may have been force-pushed; pass --allow-downgrade if this is intended.\n", dep.Name, detail)
+			out.fail(dep.Name, exitcode.Integrity)
+			markRefused(installStates, dep.Name, "downgrade to an older commit: "+detail+" (pass --allow-downgrade to install it)")
+		}
+		if opts.DryRun {
+			continue
+		}
+		if err := journal.Record(entry); err != nil {
+			warnings.Printf("Could not record the downgrade of '%s' in the journal: %v", dep.Name, err)
+		}
+	}
+	return allowed
+}
+
+// markRefused sets the Refused reason of the state named name.
+func markRefused(installStates []dependencyInstallState, name, reason string) {
+	for i := range installStates {
+		if installStates[i].Name == name {
+			installStates[i].Refused = reason
+		}
+	}
+}
+
+// shortSHA abbreviates a commit SHA for messages.
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
@@ -0,0 +1,126 @@
+package install
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/cache"
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+	"github.com/nightconcept/almandine/internal/core/filestate"
+	"github.com/nightconcept/almandine/internal/core/hasher"
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+	"github.com/nightconcept/almandine/internal/core/logger"
+	"github.com/nightconcept/almandine/internal/core/warnings"
+)
+
+// warnLocalModifications warns about up-to-date dependencies whose files were edited after they
+// were installed. Such files are only replaced with --force, as before; the warning makes the
+// drift visible without running 'almd verify'. Files are hashed through the filestate cache, so
+// unchanged files are not read again, and the expected hash comes from the lockfile or the
+// download cache, so no request is made. Dependencies whose locked content is not known
+// locally are not checked.
+func warnLocalModifications(installStates, actions []dependencyInstallState, lf *lockfile.Lockfile) {
+	pending := make(map[string]bool, len(actions))
+	for _, dep := range actions {
+		pending[dep.Name] = true
+	}
+	var states *filestate.Cache
+	for _, state := range installStates {
+		if pending[state.Name] {
+			continue
+		}
+		entry, _ := lockedEntry(lf, state.Name)
+		expected := lockedFileHashes(state, entry)
+		if len(expected) == 0 {
+			continue
+		}
+		if states == nil {
+			states = filestate.Load(".")
+		}
+		for _, filePath := range slices.Sorted(maps.Keys(expected)) {
+			hash := expected[filePath]
+			actual, _, err := states.HashFile(filepath.FromSlash(filePath))
+			if errors.Is(err, fs.ErrNotExist) {
+				continue // Reported by checkLocalFileStatus
+			}
+			if err != nil {
+				logger.Debugf("could not hash %s: %v", filePath, err)
+				continue
+			}
+			if actual != hash {
+				warnings.Printf("'%s' (%s) differs from the locked content; run 'almd install --force %s' to restore it.", state.Name, filePath, state.Name)
+			}
+		}
+	}
+	if states != nil {
+		if err := states.Save(); err != nil {
+			logger.Debugf("could not save file state: %v", err)
+		}
+	}
+}
+
+// lockedFileHashes maps the path of each of the dependency's files to the hash it should have,
+// for the files whose hash is known locally: every file of a directory dependency, whose lock
+// entry records them, or the file of another dependency as locallyKnownHash finds it.
+func lockedFileHashes(state dependencyInstallState, entry lockfile.PackageEntry) map[string]string {
+	if len(entry.Files) > 0 {
+		hashes := make(map[string]string, len(entry.Files))
+		for file, hash := range entry.Files {
+			hashes[path.Join(state.ProjectTomlPath, file)] = hash
+		}
+		return hashes
+	}
+	expected, ok := locallyKnownHash(entry)
+	if !ok {
+		return nil
+	}
+	return map[string]string{state.ProjectTomlPath: expected}
+}
+
+// locallyKnownHash returns the "sha256:<hex>" hash an installed file should have when it can be
+// told without the network: from the lockfile for content-hashed, transformed and generated
+// entries, or from the download cache for entries locked to a commit.
+func locallyKnownHash(entry lockfile.PackageEntry) (string, bool) {
+	switch {
+	case entry.WrittenDiffers():
+		return entry.TransformedHash, strings.HasPrefix(entry.TransformedHash, "sha256:")
+	case strings.HasPrefix(entry.Hash, "sha256:"):
+		return entry.Hash, true
+	case strings.HasPrefix(entry.Hash, "commit:") && entry.Source != "":
+		store, err := cache.Open()
+		if err != nil {
+			return "", false
+		}
+		content, ok := store.LookupURL(entry.Source)
+		if !ok {
+			return "", false
+		}
+		hash, err := hasher.CalculateSHA256(content)
+		return hash, err == nil
+	default:
+		return "", false
+	}
+}
+
+// checkLockfileChecksum compares a signed lockfile with its checksum stamp. A lockfile edited
+// since it was signed fails a frozen install; other installs warn, and the lockfile they write
+// is signed again.
+func checkLockfileChecksum(lf *lockfile.Lockfile, frozen bool) error {
+	err := lf.VerifyChecksum()
+	if err == nil {
+		return nil
+	}
+	if frozen {
+		return cli.Exit(fmt.Sprintf("Error: %s: %v", lockfile.LockfileName, err), exitcode.Integrity)
+	}
+	warnings.Printf("%s: %v", lockfile.LockfileName, err)
+	return nil
+}
@@ -0,0 +1,245 @@
+package install
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/cli/completion"
+	"github.com/nightconcept/almandine/internal/core/cache"
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+	"github.com/nightconcept/almandine/internal/core/hasher"
+	"github.com/nightconcept/almandine/internal/core/httpclient"
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+	"github.com/nightconcept/almandine/internal/core/logger"
+	coreproject "github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+// errNotFetched reports that an offline install found a dependency's content missing from the cache.
+var errNotFetched = errors.New("not in the cache")
+
+// resolveFromLockfile resolves a dependency to the version almd-lock.toml records without asking
+// the provider. It fails the dependency when it is not locked or its declared source no longer
+// points at the locked file; the ref itself cannot be checked offline.
+func resolveFromLockfile(depToProcess dependencyToProcess, parsedSourceInfo *source.ParsedSourceInfo, lf *lockfile.Lockfile, out *outcome) (commitHash, rawURL string, ok bool) {
+	locked, isLocked := lockedEntry(lf, depToProcess.Name)
+	if !isLocked {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: '%s' is not in %s, so its version is unknown offline. Run 'almd install %s' with network access to lock it.\n",
+			depToProcess.Name, lockfile.LockfileName, depToProcess.Name)
+		out.fail(depToProcess.Name, exitcode.Resolution)
+		return "", "", false
+	}
+
+	commitHash, rawURL = lockedTarget(parsedSourceInfo, locked)
+	if rawURL != locked.Source {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: the source of '%s' in %s no longer matches %s. Run 'almd install %s' with network access to lock it.\n",
+			depToProcess.Name, config.ManifestName(), lockfile.LockfileName, depToProcess.Name)
+		out.fail(depToProcess.Name, exitcode.Resolution)
+		return "", "", false
+	}
+	return commitHash, rawURL, true
+}
+
+// lockedTarget returns the commit and raw URL the parsed source has at its locked version. The
+// raw URL differs from the entry's source when the source was changed since it was locked.
+func lockedTarget(parsedSourceInfo *source.ParsedSourceInfo, locked lockfile.PackageEntry) (commitHash, rawURL string) {
+	commitHash, rawURL = parsedSourceInfo.Ref, parsedSourceInfo.RawURL
+	if sha, isCommit := strings.CutPrefix(locked.Hash, "commit:"); isCommit {
+		if pinned, err := parsedSourceInfo.AtCommit(sha); err == nil {
+			commitHash, rawURL = sha, pinned.RawURL
+		}
+	}
+	return commitHash, rawURL
+}
+
+// cachedDependencyContent returns a dependency's locked content from the cache: by URL when the
+// URL is pinned to a commit, otherwise by the content hash in the lockfile.
+func cachedDependencyContent(store *cache.Store, dep dependencyInstallState) ([]byte, error) {
+	if isImmutableTarget(dep) {
+		content, ok := store.LookupURL(dep.TargetRawURL)
+		httpclient.TraceCache(dep.TargetRawURL, ok)
+		if ok {
+			return content, nil
+		}
+	} else if strings.HasPrefix(dep.LockedCommitHash, "sha256:") {
+		if content, ok := store.Get(dep.LockedCommitHash); ok {
+			return content, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", errNotFetched, dep.TargetRawURL)
+}
+
+// runDownloads remembers the content fetched during one run by raw URL and headers, so
+// dependencies that vendor the same upstream file into several paths download it once.
+type runDownloads struct {
+	content   map[string][]byte
+	fetchedBy map[string]string // Name of the dependency whose fetch got the content
+	stats     *runStats         // Times the downloads for --stats; may be nil
+}
+
+func newRunDownloads(stats *runStats) *runDownloads {
+	return &runDownloads{content: make(map[string][]byte), fetchedBy: make(map[string]string), stats: stats}
+}
+
+// downloadKey identifies what a dependency downloads: its raw URL and the headers sent with it.
+func downloadKey(dep dependencyInstallState) string {
+	key := dep.TargetRawURL
+	for _, name := range slices.Sorted(maps.Keys(dep.Headers)) {
+		key += "\x00" + name + ":" + dep.Headers[name]
+	}
+	return key
+}
+
+// fetch returns dep's upstream content like fetchStage, reusing the content another dependency
+// of the run already fetched from the same URL.
+func (d *runDownloads) fetch(dep dependencyInstallState, verbose bool) ([]byte, int) {
+	key := downloadKey(dep)
+	if content, ok := d.content[key]; ok {
+		if verbose {
+			logger.Progressf("    Reusing %s already fetched for '%s' (%d bytes)", dep.TargetRawURL, d.fetchedBy[key], len(content))
+		}
+		return content, exitcode.OK
+	}
+	stop := d.stats.time(phaseDownload)
+	content, code := fetchStage(dep, verbose)
+	stop()
+	if code == exitcode.OK {
+		d.content[key], d.fetchedBy[key] = content, dep.Name
+	}
+	return content, code
+}
+
+// FetchCmd returns the 'fetch' command, the download-only stage of install.
+func FetchCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "fetch",
+		Usage:     "Download the locked versions of dependencies into the cache without installing them",
+		ArgsUsage: "[dependency_names...]",
+		Description: "Fills the cache so that 'almd install --offline' can install later without network access,\n" +
+			"for example in a sealed CI build step. Every download is checked against almd-lock.toml;\n" +
+			"project files and the lockfile are left untouched.",
+		BashComplete: completion.Dependencies,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "verbose",
+				Usage: "Enable verbose output",
+			},
+			&cli.BoolFlag{
+				Name:  "strict",
+				Usage: "Treat warnings (skipped dependencies) as failures",
+			},
+			&cli.StringSliceFlag{
+				Name:  "label",
+				Usage: "Only fetch dependencies with this label (repeat for any of several)",
+			},
+		},
+		Action: runFetch,
+	}
+}
+
+func runFetch(c *cli.Context) error {
+	verbose := c.Bool("verbose") || logger.Verbose()
+
+	projCfg, err := config.LoadProjectToml(".")
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cli.Exit(fmt.Sprintf("Error: %s not found in the current directory. Please run 'almd init' first.", config.ManifestName()), 1)
+		}
+		return cli.Exit(fmt.Sprintf("Error loading %s: %v", config.ManifestName(), err), 1)
+	}
+	dependencyNames, err := coreproject.SelectByLabels(projCfg.Dependencies, c.Args().Slice(), c.StringSlice("label"))
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	if _, err := cache.Open(); err != nil {
+		return cli.Exit(fmt.Sprintf("Error: the cache is unavailable: %v", err), 1)
+	}
+	lf, err := loadOrInitLockfile(verbose)
+	if err != nil {
+		return err
+	}
+
+	out := &outcome{}
+	dependenciesToProcessList, err := collectDependenciesToProcess(projCfg, dependencyNames, out, os.Stdout, verbose)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error collecting dependencies to process: %v", err), 1)
+	}
+	for i := range dependenciesToProcessList {
+		dependenciesToProcessList[i].Offline = true // Fetch exactly what 'install --offline' will look for
+	}
+	installStates, err := resolveInstallStates(dependenciesToProcessList, lf, out, verbose)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error resolving dependency states: %v", err), 1)
+	}
+
+	fetched := 0
+	downloads := newRunDownloads(nil)
+	for _, dep := range installStates {
+		dep.Offline = false
+		if verbose {
+			logger.Progressf("  Fetching '%s' from %s", dep.Name, dep.TargetRawURL)
+		}
+		if code := fetchAndVerify(downloads, dep, verbose); code != exitcode.OK {
+			out.fail(dep.Name, code)
+			continue
+		}
+		fetched++
+	}
+	if fetched > 0 {
+		_, _ = fmt.Fprintf(os.Stdout, "Fetched %d dependenc(ies) into the cache.\n", fetched)
+	}
+	return out.exitError(countTargeted(projCfg, dependencyNames), c.Bool("strict"))
+}
+
+// fetchAndVerify fetches dep's locked content into the cache and checks it against the
+// lockfile. A directory dependency fetches each of its files at the locked commit; an archive
+// dependency fetches its archive.
+func fetchAndVerify(downloads *runDownloads, dep dependencyInstallState, verbose bool) int {
+	if len(dep.Files) == 0 || dep.archiveFormat() != "" {
+		content, code := downloads.fetch(dep, verbose)
+		if code == exitcode.OK {
+			code = verifyFetched(dep, content)
+		}
+		return code
+	}
+	parsed, err := source.ParseSourceURL(dep.ProjectTomlSource)
+	if err == nil {
+		parsed, err = parsed.AtCommit(dep.TargetCommitHash)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: cannot fetch directory '%s': %v\n", dep.Name, err)
+		return exitcode.Resolution
+	}
+	for _, file := range dep.Files {
+		if _, code := downloads.fetch(directoryFileState(dep, parsed, file), verbose); code != exitcode.OK {
+			return code
+		}
+	}
+	return exitcode.OK
+}
+
+// verifyFetched checks fetched content against the content hash almd-lock.toml records. Content
+// locked by commit was fetched from a URL pinned to that commit and needs no further check.
+func verifyFetched(dep dependencyInstallState, content []byte) int {
+	if !strings.HasPrefix(dep.LockedCommitHash, "sha256:") {
+		return exitcode.OK
+	}
+	contentHash, err := hasher.CalculateSHA256(content)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: Failed to calculate SHA256 hash for dependency '%s': %v\n", dep.Name, err)
+		return exitcode.Usage
+	}
+	if contentHash != dep.LockedCommitHash {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: '%s' downloaded from %s has hash %s, but %s records %s.\n",
+			dep.Name, dep.TargetRawURL, contentHash, lockfile.LockfileName, dep.LockedCommitHash)
+		return exitcode.Integrity
+	}
+	return exitcode.OK
+}
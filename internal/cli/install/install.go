@@ -4,19 +4,36 @@ package install
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/urfave/cli/v2"
 
+	"github.com/nightconcept/almandine/internal/cli/completion"
+	"github.com/nightconcept/almandine/internal/core/attestation"
+	"github.com/nightconcept/almandine/internal/core/budget"
+	"github.com/nightconcept/almandine/internal/core/cache"
 	"github.com/nightconcept/almandine/internal/core/config"
 	"github.com/nightconcept/almandine/internal/core/downloader"
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+	"github.com/nightconcept/almandine/internal/core/filemode"
 	"github.com/nightconcept/almandine/internal/core/hasher"
+	"github.com/nightconcept/almandine/internal/core/inventory"
 	"github.com/nightconcept/almandine/internal/core/lockfile"
+	"github.com/nightconcept/almandine/internal/core/logger"
 	coreproject "github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/safepath"
 	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/nightconcept/almandine/internal/core/transform"
+	"github.com/nightconcept/almandine/internal/core/vendorheader"
+	"github.com/nightconcept/almandine/internal/core/warnings"
 )
 
 // isCommitSHARegex matches valid Git commit SHAs of varying lengths (7-40 chars).
@@ -26,9 +43,24 @@ var isCommitSHARegex = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
 // dependencyToProcess tracks the source configuration for each dependency
 // that needs to be processed during the install/update operation.
 type dependencyToProcess struct {
-	Name   string
-	Source string
-	Path   string
+	Name         string
+	Source       string
+	Path         string
+	Mode         string
+	Transform    string
+	VendorHeader bool
+	Headers      map[string]string // Extra HTTP headers for the dependency's downloads
+	Install      string            // Install mode from project.toml; empty for copy
+	GenerateCmd  string            // Command of the script that generates the file, for install = "generate"
+	Files        []string          // Files of a directory dependency, relative to Path
+	Extract      []string          // Extract specs of an archive dependency
+	// TagFallback allows resolving a missing tag to an existing tag of the same version.
+	TagFallback bool
+	// Offline resolves the dependency to its locked version instead of asking the provider,
+	// and takes its content from the cache (--offline).
+	Offline bool
+	// Resumed resolves the dependency to the version an interrupted run installed; see resumeProgress.
+	Resumed bool
 }
 
 // dependencyInstallState tracks both the target state (from project.toml) and
@@ -37,57 +69,104 @@ type dependencyInstallState struct {
 	Name              string
 	ProjectTomlSource string
 	ProjectTomlPath   string
+	ProjectTomlMode   string
+	Install           string // Install mode; see installFile
+	GenerateCmd       string
+	Files             []string // Files of a directory dependency, relative to ProjectTomlPath; see installDirectory
+	Extract           []string // Extract specs of an archive dependency; see installArchive
+	Transform         string
+	VendorHeader      bool
+	Headers           map[string]string
 	TargetRawURL      string
 	TargetCommitHash  string
 	LockedRawURL      string
 	LockedCommitHash  string
+	LockedTransform   string
+	LockedInstall     string
+	LockedFiles       map[string]string
+	LockedExtract     []string
 	Provider          string
 	Owner             string
 	Repo              string
 	PathInRepo        string
 	NeedsAction       bool
 	ActionReason      string
+	// Refused says why a needed install is refused, e.g. a downgrade; see guardDowngrades.
+	Refused string
+	// UpstreamSHA256 and FileSHA256 are the hex SHA-256 digests of the content as fetched and of
+	// the file as written, set once the dependency is installed; see writeAttestation.
+	UpstreamSHA256 string
+	FileSHA256     string
+	// FileHashes holds the "sha256:<hex>" digest of the content of each of the Files of a
+	// directory or archive dependency as installed, for its attestation.
+	FileHashes map[string]string
+	// Offline restricts fetching to the cache; nothing is downloaded.
+	Offline bool
+	// RecheckedContent is the upstream content of a source locked by its content hash, downloaded
+	// while resolving to see whether it changed; see recheckContent.
+	RecheckedContent []byte
 }
 
 // loadInstallConfigAndArgs loads necessary configurations and parses CLI arguments.
-func loadInstallConfigAndArgs(c *cli.Context) (projCfg *coreproject.Project, lf *lockfile.Lockfile, dependencyNames []string, force bool, verbose bool, err error) {
-	verbose = c.Bool("verbose")
-	force = c.Bool("force")
+// The returned options already reflect the selected --profile, if any.
+func loadInstallConfigAndArgs(c *cli.Context) (projCfg *coreproject.Project, lf *lockfile.Lockfile, dependencyNames []string, opts installOptions, err error) {
+	verbose := c.Bool("verbose") || logger.Verbose()
 
 	if verbose {
-		_, _ = fmt.Fprintln(os.Stdout, "Executing 'install' command...")
-		if force {
-			_, _ = fmt.Fprintln(os.Stdout, "Force install/update enabled.")
-		}
+		logger.Progressf("Executing 'install' command...")
 	}
 
 	dependencyNames = c.Args().Slice()
 	if verbose {
 		if len(dependencyNames) > 0 {
-			_, _ = fmt.Fprintf(os.Stdout, "Targeted dependencies for install/update: %v\n", dependencyNames)
+			logger.Progressf("Targeted dependencies for install/update: %v", dependencyNames)
 		} else {
-			_, _ = fmt.Fprintln(os.Stdout, "Targeting all dependencies for install/update.")
+			logger.Progressf("Targeting all dependencies for install/update.")
 		}
 	}
 
 	projCfg, err = config.LoadProjectToml(".")
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return nil, nil, nil, false, verbose, cli.Exit("Error: project.toml not found in the current directory. Please run 'almd init' first.", 1)
+			return nil, nil, nil, opts, cli.Exit(fmt.Sprintf("Error: %s not found in the current directory. Please run 'almd init' first.", config.ManifestName()), 1)
 		}
-		return nil, nil, nil, false, verbose, cli.Exit(fmt.Sprintf("Error loading project.toml: %v", err), 1)
+		return nil, nil, nil, opts, cli.Exit(fmt.Sprintf("Error loading %s: %v", config.ManifestName(), err), 1)
 	}
 	if verbose {
-		_, _ = fmt.Fprintf(os.Stdout, "Successfully loaded project.toml (Package: %s)\n", projCfg.Package.Name)
+		logger.Progressf("Successfully loaded project.toml (Package: %s)", projCfg.Package.Name)
+	}
+	if dependencyNames, err = coreproject.SelectByLabels(projCfg.Dependencies, dependencyNames, c.StringSlice("label")); err != nil {
+		return nil, nil, nil, opts, cli.Exit(fmt.Sprintf("Error: %v", err), 1)
 	}
 
-	lf, err = lockfile.Load(".")
+	opts, err = resolveInstallOptions(c, projCfg)
+	if err != nil {
+		return nil, nil, nil, opts, cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	verbose = opts.Verbose
+	if verbose {
+		logInstallOptions(c, opts)
+	}
+
+	lf, err = loadOrInitLockfile(verbose)
+	if err != nil {
+		return nil, nil, nil, opts, err
+	}
+	if err = checkLockfileChecksum(lf, opts.Frozen); err != nil {
+		return nil, nil, nil, opts, err
+	}
+	return projCfg, lf, dependencyNames, opts, nil
+}
+
+// loadOrInitLockfile loads almd-lock.toml, starting from an empty lockfile when none exists yet.
+func loadOrInitLockfile(verbose bool) (*lockfile.Lockfile, error) {
+	lf, err := lockfile.Load(".")
 	if err != nil {
 		// If lockfile doesn't exist, we initialize a new one instead of erroring out.
 		// The install process will populate it.
 		if errors.Is(err, os.ErrNotExist) {
 			if verbose {
-				_, _ = fmt.Fprintln(os.Stdout, "almd-lock.toml not found, will create a new one.")
+				logger.Progressf("almd-lock.toml not found, will create a new one.")
 			}
 			lf = &lockfile.Lockfile{
 				ApiVersion: lockfile.APIVersion,
@@ -95,12 +174,12 @@ func loadInstallConfigAndArgs(c *cli.Context) (projCfg *coreproject.Project, lf
 			}
 			err = nil // Clear the error as we've handled it by creating a new lockfile struct
 		} else {
-			return nil, nil, nil, false, verbose, cli.Exit(fmt.Sprintf("Error loading almd-lock.toml: %v", err), 1)
+			return nil, cli.Exit(fmt.Sprintf("Error loading almd-lock.toml: %v", err), 1)
 		}
 	}
 
 	if verbose && err == nil { // err == nil means lockfile was loaded or initialized successfully
-		_, _ = fmt.Fprintln(os.Stdout, "Successfully loaded or initialized almd-lock.toml.")
+		logger.Progressf("Successfully loaded or initialized almd-lock.toml.")
 	}
 
 	if lf.Package == nil {
@@ -109,164 +188,359 @@ func loadInstallConfigAndArgs(c *cli.Context) (projCfg *coreproject.Project, lf
 	if lf.ApiVersion == "" {
 		lf.ApiVersion = lockfile.APIVersion
 	}
-	return projCfg, lf, dependencyNames, force, verbose, nil
+	for _, name := range lf.MalformedNames() {
+		warnings.Printf("The %s entry of '%s' is malformed (%v); it is treated as unlocked and rebuilt when '%s' is installed. 'almd lock repair' rebuilds it from the files on disk.",
+			lockfile.LockfileName, name, lf.Malformed[name], name)
+	}
+	return lf, nil
+}
+
+// lockedEntry returns the lockfile entry of a dependency. A malformed entry counts as missing, so
+// that its garbage hashes are never compared or trusted and installing the dependency rebuilds it.
+func lockedEntry(lf *lockfile.Lockfile, name string) (lockfile.PackageEntry, bool) {
+	entry, ok := lf.Package[name]
+	if !ok || lf.Malformed[name] != nil {
+		return lockfile.PackageEntry{}, false
+	}
+	return entry, true
 }
 
 // collectDependenciesToProcess determines which dependencies to process based on arguments or all from project.toml.
-func collectDependenciesToProcess(projCfg *coreproject.Project, dependencyNames []string, verbose bool) ([]dependencyToProcess, error) {
+// Names that project.toml does not declare are skipped with a warning recorded in out; a run
+// with nothing to do says so on messages.
+func collectDependenciesToProcess(projCfg *coreproject.Project, dependencyNames []string, out *outcome, messages io.Writer, verbose bool) ([]dependencyToProcess, error) {
 	var dependenciesToProcessList []dependencyToProcess
 
 	if len(dependencyNames) == 0 {
 		if len(projCfg.Dependencies) == 0 {
-			_, _ = fmt.Fprintln(os.Stdout, "No dependencies found in project.toml to install/update.")
+			_, _ = fmt.Fprintln(messages, "No dependencies found in project.toml to install/update.")
 			return nil, nil // Return nil, nil to indicate no error but no work
 		}
 		if verbose {
-			_, _ = fmt.Fprintf(os.Stdout, "Processing all %d dependencies from project.toml...\n", len(projCfg.Dependencies))
+			logger.Progressf("Processing all %d dependencies from project.toml...", len(projCfg.Dependencies))
 		}
 		for name, depDetails := range projCfg.Dependencies {
 			dependenciesToProcessList = append(dependenciesToProcessList, dependencyToProcess{
-				Name:   name,
-				Source: depDetails.Source,
-				Path:   depDetails.Path,
+				Name:         name,
+				Source:       depDetails.Source,
+				Path:         depDetails.Path,
+				Mode:         depDetails.Mode,
+				Transform:    depDetails.Transform,
+				VendorHeader: projCfg.VendorHeaderEnabled(),
+				Headers:      depDetails.Headers,
+				Install:      depDetails.Install,
+				GenerateCmd:  projCfg.Scripts[depDetails.Generate].Cmd,
+				Files:        depDetails.Files,
+				Extract:      depDetails.Extract,
 			})
 			if verbose {
-				_, _ = fmt.Fprintf(os.Stdout, "  Targeting: %s (Source: %s, Path: %s)\n", name, depDetails.Source, depDetails.Path)
+				logger.Progressf("  Targeting: %s (Source: %s, Path: %s)", name, depDetails.Source, depDetails.Path)
 			}
 		}
+		// Install in a stable order so --fail-fast stops at the same dependency every run.
+		sort.Slice(dependenciesToProcessList, func(i, j int) bool {
+			return dependenciesToProcessList[i].Name < dependenciesToProcessList[j].Name
+		})
 	} else {
 		if verbose {
-			_, _ = fmt.Fprintf(os.Stdout, "Processing %d specified dependencies...\n", len(dependencyNames))
+			logger.Progressf("Processing %d specified dependencies...", len(dependencyNames))
 		}
 		for _, name := range dependencyNames {
 			depDetails, ok := projCfg.Dependencies[name]
 			if !ok {
-				_, _ = fmt.Fprintf(os.Stderr, "Warning: Dependency '%s' specified for install/update not found in project.toml. Skipping.\n", name)
+				warnings.Printf("Dependency '%s' specified for install/update not found in project.toml. Skipping.", name)
+				out.warn(name, exitcode.Usage)
 				continue
 			}
 			dependenciesToProcessList = append(dependenciesToProcessList, dependencyToProcess{
-				Name:   name,
-				Source: depDetails.Source,
-				Path:   depDetails.Path,
+				Name:         name,
+				Source:       depDetails.Source,
+				Path:         depDetails.Path,
+				Mode:         depDetails.Mode,
+				Transform:    depDetails.Transform,
+				VendorHeader: projCfg.VendorHeaderEnabled(),
+				Headers:      depDetails.Headers,
+				Install:      depDetails.Install,
+				GenerateCmd:  projCfg.Scripts[depDetails.Generate].Cmd,
+				Files:        depDetails.Files,
+				Extract:      depDetails.Extract,
 			})
 			if verbose {
-				_, _ = fmt.Fprintf(os.Stdout, "  Targeting: %s (Source: %s, Path: %s)\n", name, depDetails.Source, depDetails.Path)
+				logger.Progressf("  Targeting: %s (Source: %s, Path: %s)", name, depDetails.Source, depDetails.Path)
 			}
 		}
 		if len(dependenciesToProcessList) == 0 {
-			_, _ = fmt.Fprintln(os.Stdout, "No specified dependencies were found in project.toml to install/update.")
+			_, _ = fmt.Fprintln(messages, "No specified dependencies were found in project.toml to install/update.")
 			return nil, nil // Return nil, nil to indicate no error but no work
 		}
 	}
 
 	if verbose {
-		_, _ = fmt.Fprintf(os.Stdout, "Total dependencies to process: %d\n", len(dependenciesToProcessList))
+		logger.Progressf("Total dependencies to process: %d", len(dependenciesToProcessList))
 	}
 	return dependenciesToProcessList, nil
 }
 
-// resolveGitHubCommitRef attempts to resolve a Git ref (branch/tag) to a specific commit SHA for GitHub sources.
-// If the ref is already a SHA, or resolution fails, it returns the original ref and URL.
-func resolveGitHubCommitRef(parsedSourceInfo *source.ParsedSourceInfo, depName string, verbose bool) (resolvedCommitHash string, finalTargetRawURL string) {
+// needsCommitResolution reports whether a ref must be resolved to a commit SHA through the provider API.
+// Refs qualified as tags or branches are always resolved, even if they look like a SHA; refs qualified
+// as commits never are.
+func needsCommitResolution(parsedSourceInfo *source.ParsedSourceInfo) bool {
+	switch parsedSourceInfo.RefType {
+	case source.RefTypeCommit:
+		return false
+	case source.RefTypeTag, source.RefTypeBranch:
+		return true
+	default:
+		return !isCommitSHARegex.MatchString(parsedSourceInfo.Ref)
+	}
+}
+
+// resolveCommitRef attempts to resolve a Git ref (branch/tag) to a specific commit SHA for git-hosted sources.
+// If the ref is already a SHA, or resolution fails, it returns the original ref and URL; a failed
+// resolution is recorded as a warning in out.
+func resolveCommitRef(parsedSourceInfo *source.ParsedSourceInfo, depName string, tagFallback bool, out *outcome, verbose bool) (resolvedCommitHash string, finalTargetRawURL string) {
 	resolvedCommitHash = parsedSourceInfo.Ref
 	finalTargetRawURL = parsedSourceInfo.RawURL
 
-	if parsedSourceInfo.Provider == "github" && !isCommitSHARegex.MatchString(parsedSourceInfo.Ref) {
+	if source.HasCommits(parsedSourceInfo.Provider) && needsCommitResolution(parsedSourceInfo) {
 		if verbose {
-			_, _ = fmt.Fprintf(os.Stdout, "  Ref '%s' for '%s' is not a full commit SHA. Attempting to resolve latest commit for path '%s'...\n", parsedSourceInfo.Ref, depName, parsedSourceInfo.PathInRepo)
+			logger.Progressf("  Ref '%s' for '%s' is not a full commit SHA. Attempting to resolve latest commit for path '%s'...", parsedSourceInfo.QualifiedRef(), depName, parsedSourceInfo.PathInRepo)
 		}
-		latestSHA, err := source.GetLatestCommitSHAForFile(parsedSourceInfo.Owner, parsedSourceInfo.Repo, parsedSourceInfo.PathInRepo, parsedSourceInfo.Ref)
-		if err != nil {
-			_, _ = fmt.Fprintf(os.Stderr, "  Warning: Could not resolve ref '%s' to a specific commit for '%s': %v. Proceeding with ref as is.\n", parsedSourceInfo.Ref, depName, err)
-		} else {
+		latestSHA, err := source.ResolveRef(parsedSourceInfo)
+		if err != nil && !errors.Is(err, source.ErrRateLimited) {
+			latestSHA, err = retryMissingTag(parsedSourceInfo, depName, tagFallback, err)
+		}
+		var pinned *source.ParsedSourceInfo
+		if err == nil {
+			pinned, err = parsedSourceInfo.AtCommit(latestSHA)
+		}
+		switch {
+		case errors.Is(err, source.ErrRateLimited):
+			out.rateLimit(depName)
+		case err != nil:
+			warnings.Printf("Could not resolve ref '%s' to a specific commit for '%s': %v. Proceeding with ref as is.", parsedSourceInfo.QualifiedRef(), depName, err)
+			out.warn(depName, exitcode.Resolution)
+		default:
 			if verbose {
-				_, _ = fmt.Fprintf(os.Stdout, "  Resolved ref '%s' to commit SHA: %s for '%s'\n", parsedSourceInfo.Ref, latestSHA, depName)
+				logger.Progressf("  Resolved ref '%s' to commit SHA: %s for '%s'", parsedSourceInfo.QualifiedRef(), latestSHA, depName)
 			}
 			resolvedCommitHash = latestSHA
-			finalTargetRawURL = strings.Replace(parsedSourceInfo.RawURL, "/"+parsedSourceInfo.Ref+"/", "/"+latestSHA+"/", 1)
+			finalTargetRawURL = pinned.RawURL
 		}
-	} else if verbose && parsedSourceInfo.Provider == "github" {
-		_, _ = fmt.Fprintf(os.Stdout, "  Ref '%s' for '%s' appears to be a commit SHA. Using it directly.\n", parsedSourceInfo.Ref, depName)
+	} else if verbose && source.HasCommits(parsedSourceInfo.Provider) {
+		logger.Progressf("  Ref '%s' for '%s' appears to be a commit SHA. Using it directly.", parsedSourceInfo.Ref, depName)
 	}
 	return resolvedCommitHash, finalTargetRawURL
 }
 
+// retryMissingTag handles a ref that failed to resolve with resolveErr. When the ref is a tag
+// that no longer exists upstream, the returned error names the nearest existing tags; with
+// fallback, a remaining tag of the same version (e.g. "1.2.0" for "v1.2.0") is resolved instead.
+func retryMissingTag(parsedSourceInfo *source.ParsedSourceInfo, depName string, fallback bool, resolveErr error) (string, error) {
+	isTag := parsedSourceInfo.RefType == source.RefTypeTag || (parsedSourceInfo.RefType == "" && source.IsVersionTag(parsedSourceInfo.Ref))
+	if !isTag {
+		return "", resolveErr
+	}
+	same, nearest, missing, err := source.TagAlternatives(parsedSourceInfo)
+	if err != nil || !missing {
+		return "", resolveErr
+	}
+	if fallback && same != "" {
+		retargeted := *parsedSourceInfo
+		retargeted.Ref, retargeted.RefType = same, source.RefTypeTag
+		sha, err := source.ResolveRef(&retargeted)
+		if err == nil {
+			warnings.Printf("Tag '%s' of '%s' no longer exists upstream; using tag '%s' of the same version. Update its source in %s.", parsedSourceInfo.Ref, depName, same, config.ManifestName())
+			return sha, nil
+		}
+	}
+
+	hint := "the repository has no tags"
+	switch {
+	case same != "" && !fallback:
+		hint = fmt.Sprintf("tag '%s' has the same version; pass --tag-fallback to use it", same)
+	case len(nearest) > 0:
+		hint = "nearest tags: " + strings.Join(nearest, ", ")
+	}
+	return "", fmt.Errorf("tag '%s' no longer exists upstream (deleted or renamed; %s): %w", parsedSourceInfo.Ref, hint, resolveErr)
+}
+
 // resolveSingleDependencyState resolves the target and locked state for a single dependency.
-func resolveSingleDependencyState(depToProcess dependencyToProcess, lf *lockfile.Lockfile, verbose bool) (*dependencyInstallState, error) {
+// Dependencies whose source cannot be parsed or resolved are skipped with a warning recorded in out.
+func resolveSingleDependencyState(depToProcess dependencyToProcess, lf *lockfile.Lockfile, out *outcome, verbose bool) (*dependencyInstallState, error) {
 	if verbose {
-		_, _ = fmt.Fprintf(os.Stdout, "Processing dependency: %s (Source: %s)\n", depToProcess.Name, depToProcess.Source)
+		logger.Progressf("Processing dependency: %s (Source: %s)", depToProcess.Name, depToProcess.Source)
 	}
 
 	parsedSourceInfo, err := source.ParseSourceURL(depToProcess.Source)
 	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "Warning: Could not parse source URL for dependency '%s' (%s): %v. Skipping.\n", depToProcess.Name, depToProcess.Source, err)
+		warnings.Printf("Could not parse source URL for dependency '%s' (%s): %v. Skipping.", depToProcess.Name, depToProcess.Source, err)
+		out.warn(depToProcess.Name, exitcode.Resolution)
 		return nil, nil // Return nil, nil to indicate skipping this dependency
 	}
 
-	resolvedCommitHash, finalTargetRawURL := resolveGitHubCommitRef(parsedSourceInfo, depToProcess.Name, verbose)
+	fromLockfile := depToProcess.Offline || depToProcess.Resumed
+	if parsedSourceInfo.IsTagPattern() && !fromLockfile {
+		pattern := parsedSourceInfo.Ref
+		parsedSourceInfo, err = source.ResolveTagPattern(parsedSourceInfo)
+		if errors.Is(err, source.ErrRateLimited) {
+			out.rateLimit(depToProcess.Name)
+			return nil, nil
+		}
+		if err != nil {
+			warnings.Printf("Could not resolve tag pattern for dependency '%s' (%s): %v. Skipping.", depToProcess.Name, depToProcess.Source, err)
+			out.warn(depToProcess.Name, exitcode.Resolution)
+			return nil, nil
+		}
+		if verbose {
+			logger.Progressf("  Tag pattern '%s' for '%s' matched tag '%s'", pattern, depToProcess.Name, parsedSourceInfo.Ref)
+		}
+	}
+
+	var resolvedCommitHash, finalTargetRawURL string
+	if fromLockfile {
+		var ok bool
+		if resolvedCommitHash, finalTargetRawURL, ok = resolveFromLockfile(depToProcess, parsedSourceInfo, lf, out); !ok {
+			return nil, nil
+		}
+	} else {
+		resolvedCommitHash, finalTargetRawURL = resolveCommitRef(parsedSourceInfo, depToProcess.Name, depToProcess.TagFallback, out, verbose)
+	}
 
 	currentState := dependencyInstallState{
 		Name:              depToProcess.Name,
 		ProjectTomlSource: depToProcess.Source,
 		ProjectTomlPath:   depToProcess.Path,
+		ProjectTomlMode:   depToProcess.Mode,
+		Install:           depToProcess.Install,
+		GenerateCmd:       depToProcess.GenerateCmd,
+		Files:             depToProcess.Files,
+		Extract:           depToProcess.Extract,
+		Transform:         depToProcess.Transform,
+		VendorHeader:      depToProcess.VendorHeader,
+		Headers:           depToProcess.Headers,
 		TargetRawURL:      finalTargetRawURL,
 		TargetCommitHash:  resolvedCommitHash,
 		Provider:          parsedSourceInfo.Provider,
 		Owner:             parsedSourceInfo.Owner,
 		Repo:              parsedSourceInfo.Repo,
 		PathInRepo:        parsedSourceInfo.PathInRepo,
+		Offline:           depToProcess.Offline,
 	}
 
-	if lockDetails, ok := lf.Package[depToProcess.Name]; ok {
+	if lockDetails, ok := lockedEntry(lf, depToProcess.Name); ok {
 		currentState.LockedRawURL = lockDetails.Source
 		currentState.LockedCommitHash = lockDetails.Hash
+		currentState.LockedTransform = lockDetails.Transform
+		currentState.LockedInstall = lockDetails.Install
+		currentState.LockedFiles = lockDetails.Files
+		currentState.LockedExtract = lockDetails.Extract
 		if verbose {
-			_, _ = fmt.Fprintf(os.Stdout, "  Found in lockfile: Name: %s, Locked Source: %s, Locked Hash: %s\n", depToProcess.Name, lockDetails.Source, lockDetails.Hash)
+			logger.Progressf("  Found in lockfile: Name: %s, Locked Source: %s, Locked Hash: %s", depToProcess.Name, lockDetails.Source, lockDetails.Hash)
 		}
 	} else {
 		if verbose {
-			_, _ = fmt.Fprintf(os.Stdout, "  Dependency '%s' not found in lockfile.\n", depToProcess.Name)
+			logger.Progressf("  Dependency '%s' not found in lockfile.", depToProcess.Name)
 		}
 	}
+	if !fromLockfile {
+		recheckContent(&currentState, out, verbose)
+	}
 	return &currentState, nil
 }
 
-// resolveInstallStates resolves the target and locked states for each dependency.
-func resolveInstallStates(dependenciesToProcessList []dependencyToProcess, lf *lockfile.Lockfile, verbose bool) ([]dependencyInstallState, error) {
-	var installStates []dependencyInstallState
+// recheckContent downloads the content of a dependency that cannot be pinned to a commit and is
+// locked by its content hash, since only the content tells whether it changed upstream. A
+// failed download is recorded as a warning in out and leaves the dependency as locked.
+func recheckContent(state *dependencyInstallState, out *outcome, verbose bool) {
+	if source.HasCommits(state.Provider) || !strings.HasPrefix(state.LockedCommitHash, "sha256:") {
+		return
+	}
+	if verbose {
+		logger.Progressf("  Re-downloading %s to compare with the locked content hash", state.TargetRawURL)
+	}
+	content, _, err := fetchDependencyContent(*state)
+	if err != nil {
+		warnings.Printf("Could not re-check the content of '%s' at %s: %v. Keeping the locked version.", state.Name, state.TargetRawURL, err)
+		out.warn(state.Name, exitcode.Download)
+		return
+	}
+	state.RecheckedContent = content
+}
+
+// resolveWorkers bounds how many dependencies are resolved at once. Their GitHub API calls
+// share one rate limiter in the source package, however many workers run.
+const resolveWorkers = 8
 
+// resolveInstallStates resolves the target and locked states for each dependency, several at
+// a time. Verbose runs resolve one dependency at a time so their logs do not interleave.
+func resolveInstallStates(dependenciesToProcessList []dependencyToProcess, lf *lockfile.Lockfile, out *outcome, verbose bool) ([]dependencyInstallState, error) {
 	if verbose && len(dependenciesToProcessList) > 0 {
-		_, _ = fmt.Fprintln(os.Stdout, "\nResolving target versions and current lock states...")
+		logger.Progressf("\nResolving target versions and current lock states...")
 	}
 
-	for _, depToProcess := range dependenciesToProcessList {
-		state, err := resolveSingleDependencyState(depToProcess, lf, verbose)
-		if err != nil {
-			// This error case is not currently hit by resolveSingleDependencyState as it returns nil, nil for skippable errors.
-			// However, keeping it for future robustness if resolveSingleDependencyState changes to return actual errors.
-			_, _ = fmt.Fprintf(os.Stderr, "Error resolving state for dependency '%s': %v. Skipping.\n", depToProcess.Name, err)
-			continue
-		}
+	workers := min(resolveWorkers, len(dependenciesToProcessList))
+	if verbose {
+		workers = 1
+	}
+	states := make([]*dependencyInstallState, len(dependenciesToProcessList))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				states[i] = resolveDependencyOrReport(dependenciesToProcessList[i], lf, out, verbose)
+			}
+		}()
+	}
+	for i := range dependenciesToProcessList {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	var installStates []dependencyInstallState
+	for _, state := range states {
 		if state != nil {
 			installStates = append(installStates, *state)
 		}
 	}
+	if len(out.rateLimited) > 0 {
+		sort.Strings(out.rateLimited)
+		warnings.Printf("%v; could not resolve the refs of %s. Proceeding with the refs as is. "+
+			"Run 'almd token set' to raise the limit.", source.ErrRateLimited, strings.Join(out.rateLimited, ", "))
+	}
 
 	if verbose && len(installStates) > 0 {
-		_, _ = fmt.Fprintln(os.Stdout, "\nFinished resolving versions. States to compare:")
+		logger.Progressf("\nFinished resolving versions. States to compare:")
 		for _, s := range installStates {
-			_, _ = fmt.Fprintf(os.Stdout, "  - Name: %s, TargetCommit: %s, TargetURL: %s, LockedHash: %s, LockedURL: %s\n", s.Name, s.TargetCommitHash, s.TargetRawURL, s.LockedCommitHash, s.LockedRawURL)
+			logger.Progressf("  - Name: %s, TargetCommit: %s, TargetURL: %s, LockedHash: %s, LockedURL: %s", s.Name, s.TargetCommitHash, s.TargetRawURL, s.LockedCommitHash, s.LockedRawURL)
 		}
 	}
 	return installStates, nil
 }
 
+// resolveDependencyOrReport resolves one dependency, printing an error and returning nil when
+// it cannot be.
+func resolveDependencyOrReport(depToProcess dependencyToProcess, lf *lockfile.Lockfile, out *outcome, verbose bool) *dependencyInstallState {
+	state, err := resolveSingleDependencyState(depToProcess, lf, out, verbose)
+	if err != nil {
+		// This error case is not currently hit by resolveSingleDependencyState as it returns nil, nil for skippable errors.
+		// However, keeping it for future robustness if resolveSingleDependencyState changes to return actual errors.
+		_, _ = fmt.Fprintf(os.Stderr, "Error resolving state for dependency '%s': %v. Skipping.\n", depToProcess.Name, err)
+		return nil
+	}
+	return state
+}
+
 // filterDependenciesRequiringAction identifies which dependencies actually need an install/update.
 
 func checkForceInstall(state dependencyInstallState, force bool, verbose bool) (needsAction bool, reason string) {
 	if force {
 		if verbose {
-			_, _ = fmt.Fprintf(os.Stdout, "  - %s: Needs install/update (forced).\n", state.Name)
+			logger.Progressf("  - %s: Needs install/update (forced).", state.Name)
 		}
 		return true, "Install/Update forced by user (--force)."
 	}
@@ -276,7 +550,7 @@ func checkForceInstall(state dependencyInstallState, force bool, verbose bool) (
 func checkMissingFromLockfile(state dependencyInstallState, verbose bool) (needsAction bool, reason string) {
 	if state.LockedCommitHash == "" {
 		if verbose {
-			_, _ = fmt.Fprintf(os.Stdout, "  - %s: Needs install/update (not in lockfile).\n", state.Name)
+			logger.Progressf("  - %s: Needs install/update (not in lockfile).", state.Name)
 		}
 		return true, "Dependency present in project.toml but not in almd-lock.toml."
 	}
@@ -284,14 +558,16 @@ func checkMissingFromLockfile(state dependencyInstallState, verbose bool) (needs
 }
 
 func checkLocalFileStatus(state dependencyInstallState, verbose bool) (needsAction bool, reason string) {
-	if _, err := os.Stat(state.ProjectTomlPath); errors.Is(err, os.ErrNotExist) {
-		if verbose {
-			_, _ = fmt.Fprintf(os.Stdout, "  - %s: Needs install/update (file missing at %s).\n", state.Name, state.ProjectTomlPath)
+	for _, filePath := range state.filePaths() {
+		if _, err := os.Stat(filePath); errors.Is(err, os.ErrNotExist) {
+			if verbose {
+				logger.Progressf("  - %s: Needs install/update (file missing at %s).", state.Name, filePath)
+			}
+			return true, fmt.Sprintf("Local file missing at path: %s.", filePath)
+		} else if err != nil {
+			warnings.Printf("Could not stat file for dependency '%s' at '%s': %v. Assuming install/update check is needed.", state.Name, filePath, err)
+			return true, fmt.Sprintf("Error checking local file status at %s: %v.", filePath, err)
 		}
-		return true, fmt.Sprintf("Local file missing at path: %s.", state.ProjectTomlPath)
-	} else if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "Warning: Could not stat file for dependency '%s' at '%s': %v. Assuming install/update check is needed.\n", state.Name, state.ProjectTomlPath, err)
-		return true, fmt.Sprintf("Error checking local file status at %s: %v.", state.ProjectTomlPath, err)
 	}
 	return false, ""
 }
@@ -307,7 +583,7 @@ func checkCommitHashMismatch(state dependencyInstallState, verbose bool) (needsA
 
 	if lockedSHA != "" && state.TargetCommitHash != lockedSHA {
 		if verbose {
-			_, _ = fmt.Fprintf(os.Stdout, "  - %s: Needs install/update (target commit %s != locked commit %s).\n", state.Name, state.TargetCommitHash, lockedSHA)
+			logger.Progressf("  - %s: Needs install/update (target commit %s != locked commit %s).", state.Name, state.TargetCommitHash, lockedSHA)
 		}
 		return true, fmt.Sprintf("Target commit hash (%s) differs from locked commit hash (%s).", state.TargetCommitHash, lockedSHA)
 	}
@@ -325,18 +601,53 @@ func checkHashTypeConflict(state dependencyInstallState, verbose bool) (needsAct
 
 	if lockedSHA == "" && strings.HasPrefix(state.LockedCommitHash, "sha256:") && isCommitSHARegex.MatchString(state.TargetCommitHash) {
 		if verbose {
-			_, _ = fmt.Fprintf(os.Stdout, "  - %s: Needs install/update (target is specific commit %s, lockfile has content hash %s).\n", state.Name, state.TargetCommitHash, state.LockedCommitHash)
+			logger.Progressf("  - %s: Needs install/update (target is specific commit %s, lockfile has content hash %s).", state.Name, state.TargetCommitHash, state.LockedCommitHash)
 		}
 		return true, fmt.Sprintf("Target is now a specific commit (%s), but lockfile has a content hash (%s).", state.TargetCommitHash, state.LockedCommitHash)
 	}
 	return false, ""
 }
 
+func checkContentChanged(state dependencyInstallState, verbose bool) (needsAction bool, reason string) {
+	if state.RecheckedContent == nil {
+		return false, ""
+	}
+	contentHash, err := hasher.CalculateSHA256(state.RecheckedContent)
+	if err != nil || contentHash == state.LockedCommitHash {
+		return false, ""
+	}
+	if verbose {
+		logger.Progressf("  - %s: Needs install/update (upstream content %s != locked %s).", state.Name, contentHash, state.LockedCommitHash)
+	}
+	return true, fmt.Sprintf("Upstream content changed (%s, locked %s).", contentHash, state.LockedCommitHash)
+}
+
+func checkTransformChanged(state dependencyInstallState, verbose bool) (needsAction bool, reason string) {
+	if state.LockedCommitHash == "" || state.Transform == state.LockedTransform {
+		return false, ""
+	}
+	if verbose {
+		logger.Progressf("  - %s: Needs install/update (transform changed from '%s' to '%s').", state.Name, state.LockedTransform, state.Transform)
+	}
+	return true, fmt.Sprintf("Transform changed from '%s' to '%s'.", state.LockedTransform, state.Transform)
+}
+
+func checkInstallModeChanged(state dependencyInstallState, verbose bool) (needsAction bool, reason string) {
+	from, to := installMode(state.LockedInstall), installMode(state.Install)
+	if state.LockedCommitHash == "" || from == to {
+		return false, ""
+	}
+	if verbose {
+		logger.Progressf("  - %s: Needs install/update (install mode changed from '%s' to '%s').", state.Name, from, to)
+	}
+	return true, fmt.Sprintf("Install mode changed from '%s' to '%s'.", from, to)
+}
+
 func filterDependenciesRequiringAction(installStates []dependencyInstallState, force bool, verbose bool) []dependencyInstallState {
 	var dependenciesThatNeedAction []dependencyInstallState
 
 	if verbose && len(installStates) > 0 {
-		_, _ = fmt.Fprintln(os.Stdout, "\nDetermining which dependencies need install/update...")
+		logger.Progressf("\nDetermining which dependencies need install/update...")
 	}
 
 	for _, state := range installStates {
@@ -351,6 +662,16 @@ func filterDependenciesRequiringAction(installStates []dependencyInstallState, f
 			// Already determined action
 		} else if needsAction, reason = checkCommitHashMismatch(state, verbose); needsAction {
 			// Already determined action
+		} else if needsAction, reason = checkContentChanged(state, verbose); needsAction {
+			// Already determined action
+		} else if needsAction, reason = checkTransformChanged(state, verbose); needsAction {
+			// Already determined action
+		} else if needsAction, reason = checkInstallModeChanged(state, verbose); needsAction {
+			// Already determined action
+		} else if needsAction, reason = checkFileListChanged(state, verbose); needsAction {
+			// Already determined action
+		} else if needsAction, reason = checkExtractChanged(state, verbose); needsAction {
+			// Already determined action
 		} else {
 			// If none of the previous conditions were met, check the last one.
 			// The assignment happens regardless, but we only enter the 'if needsAction' block below if one of the checks returned true.
@@ -363,57 +684,192 @@ func filterDependenciesRequiringAction(installStates []dependencyInstallState, f
 			actionableState.ActionReason = reason
 			dependenciesThatNeedAction = append(dependenciesThatNeedAction, actionableState)
 		} else if verbose {
-			_, _ = fmt.Fprintf(os.Stdout, "  - %s: Already up-to-date.\n", state.Name)
+			logger.Progressf("  - %s: Already up-to-date.", state.Name)
 		}
 	}
 	return dependenciesThatNeedAction
 }
 
-// executeSingleInstallOperation handles the installation process for a single dependency.
-// It returns the new lockfile entry and a boolean indicating success.
-func executeSingleInstallOperation(dep dependencyInstallState, verbose bool) (*lockfile.PackageEntry, bool) {
-	if verbose {
-		_, _ = fmt.Fprintf(os.Stdout, "  Installing/Updating '%s' from %s\n", dep.Name, dep.TargetRawURL)
+// fetchDependencyContent downloads a dependency through the global cache. Raw URLs pinned to a
+// full commit SHA never change, so those are served from the cache when already present.
+// If the cache directory is unavailable the file is downloaded directly.
+func fetchDependencyContent(dep dependencyInstallState) ([]byte, bool, error) {
+	store, err := cache.Open()
+	if dep.Offline {
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: %v", errNotFetched, err)
+		}
+		content, err := cachedDependencyContent(store, dep)
+		return content, err == nil, err
 	}
+	if err != nil {
+		content, downloadErr := downloader.WithHeaders(dep.Headers)(dep.TargetRawURL)
+		return content, false, downloadErr
+	}
+	return store.Fetch(dep.TargetRawURL, isImmutableTarget(dep), downloader.WithHeaders(dep.Headers))
+}
 
-	fileContent, downloadErr := downloader.DownloadFile(dep.TargetRawURL)
-	if downloadErr != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "Error: Failed to download dependency '%s' from '%s': %v\n", dep.Name, dep.TargetRawURL, downloadErr)
-		return nil, false
+// lockedCommitGone reports whether err is a 404 for the very commit the lockfile records, which
+// was downloaded before and so has been removed upstream.
+func lockedCommitGone(dep dependencyInstallState, err error) bool {
+	return err != nil && isImmutableTarget(dep) && dep.LockedCommitHash == "commit:"+dep.TargetCommitHash &&
+		downloader.IsNotFound(err)
+}
+
+// isImmutableTarget reports whether the dependency's raw URL is pinned to a full commit SHA,
+// either in its path or, for git+ sources, as the ref of its fragment.
+func isImmutableTarget(dep dependencyInstallState) bool {
+	return source.HasCommits(dep.Provider) && len(dep.TargetCommitHash) == 40 &&
+		isCommitSHARegex.MatchString(dep.TargetCommitHash) &&
+		(strings.Contains(dep.TargetRawURL, "/"+dep.TargetCommitHash+"/") || strings.HasSuffix(dep.TargetRawURL, "&ref="+dep.TargetCommitHash))
+}
+
+// writeDependencyFile validates the dependency's project path and puts its content there as its
+// install mode says (see installMode), creating parent directories as needed. It returns the
+// content of the file as installed, vendor header included.
+func writeDependencyFile(dep dependencyInstallState, fileContent []byte, settings filemode.Settings) ([]byte, error) {
+	if pathErr := safepath.ValidateRelPath(dep.ProjectTomlPath); pathErr != nil {
+		return nil, fmt.Errorf("cannot install dependency '%s': %w", dep.Name, pathErr)
 	}
-	if verbose {
-		_, _ = fmt.Fprintf(os.Stdout, "    Successfully downloaded %s (%d bytes)\n", dep.Name, len(fileContent))
+	targetDir := filepath.Dir(dep.ProjectTomlPath)
+	if mkdirErr := os.MkdirAll(safepath.LongPath(targetDir), os.ModePerm); mkdirErr != nil {
+		return nil, fmt.Errorf("failed to create directory '%s' for dependency '%s': %w", targetDir, dep.Name, mkdirErr)
+	}
+	if installMode(dep.Install) == coreproject.InstallGenerate {
+		return generateDependencyFile(dep, fileContent)
+	}
+	if dep.VendorHeader {
+		fileContent = vendorheader.Apply(fileContent, dep.ProjectTomlPath, describeDependency(dep))
 	}
+	if installMode(dep.Install) == coreproject.InstallLink {
+		return linkDependencyFile(dep, fileContent)
+	}
+	if linkErr := removeLink(safepath.LongPath(dep.ProjectTomlPath)); linkErr != nil {
+		return nil, fmt.Errorf("failed to replace the link at '%s' for dependency '%s': %w", dep.ProjectTomlPath, dep.Name, linkErr)
+	}
+	if writeErr := filemode.WriteFile(safepath.LongPath(dep.ProjectTomlPath), fileContent, dep.ProjectTomlMode, settings); writeErr != nil {
+		return nil, fmt.Errorf("failed to write file '%s' for dependency '%s': %w", dep.ProjectTomlPath, dep.Name, writeErr)
+	}
+	return fileContent, nil
+}
 
-	var integrityHash string
-	if dep.Provider == "github" && isCommitSHARegex.MatchString(dep.TargetCommitHash) {
-		integrityHash = "commit:" + dep.TargetCommitHash
-		if verbose {
-			_, _ = fmt.Fprintf(os.Stdout, "    Using commit hash for integrity: %s\n", integrityHash)
-		}
-	} else {
-		contentHash, hashErr := hasher.CalculateSHA256(fileContent)
-		if hashErr != nil {
-			_, _ = fmt.Fprintf(os.Stderr, "Error: Failed to calculate SHA256 hash for dependency '%s': %v\n", dep.Name, hashErr)
-			return nil, false
-		}
-		integrityHash = contentHash
+// describeDependency gathers the provenance recorded in a dependency's vendor header.
+func describeDependency(dep dependencyInstallState) vendorheader.Info {
+	commit := ""
+	if isCommitSHARegex.MatchString(dep.TargetCommitHash) {
+		commit = dep.TargetCommitHash
+	}
+	parsed := &source.ParsedSourceInfo{Provider: dep.Provider, Owner: dep.Owner, Repo: dep.Repo}
+	return vendorheader.Describe(parsed, dep.ProjectTomlSource, commit)
+}
+
+// integrityHashFor returns the lockfile hash of a dependency's upstream content: its commit for
+// git-hosted sources resolved to one, otherwise the SHA256 of the content.
+func integrityHashFor(dep dependencyInstallState, fileContent []byte, verbose bool) (string, error) {
+	if source.HasCommits(dep.Provider) && isCommitSHARegex.MatchString(dep.TargetCommitHash) {
+		integrityHash := "commit:" + dep.TargetCommitHash
 		if verbose {
-			_, _ = fmt.Fprintf(os.Stdout, "    Calculated content hash for integrity: %s\n", integrityHash)
+			logger.Progressf("    Using commit hash for integrity: %s", integrityHash)
 		}
+		return integrityHash, nil
+	}
+	contentHash, err := hasher.CalculateSHA256(fileContent)
+	if err != nil {
+		return "", err
+	}
+	if verbose {
+		logger.Progressf("    Calculated content hash for integrity: %s", contentHash)
 	}
+	return contentHash, nil
+}
 
-	targetDir := filepath.Dir(dep.ProjectTomlPath)
-	if mkdirErr := os.MkdirAll(targetDir, os.ModePerm); mkdirErr != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "Error: Failed to create directory '%s' for dependency '%s': %v\n", targetDir, dep.Name, mkdirErr)
-		return nil, false
+// applyTransform runs the dependency's transform, if any, on the downloaded content and records
+// it with the hash of the result in entry.
+func applyTransform(dep dependencyInstallState, fileContent []byte, entry *lockfile.PackageEntry, verbose bool) ([]byte, error) {
+	if dep.Transform == "" {
+		return fileContent, nil
+	}
+	transformed, err := transform.Apply(dep.Transform, dep.ProjectTomlPath, fileContent)
+	if err != nil {
+		return nil, err
 	}
-	if writeErr := os.WriteFile(dep.ProjectTomlPath, fileContent, 0644); writeErr != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "Error: Failed to write file '%s' for dependency '%s': %v\n", dep.ProjectTomlPath, dep.Name, writeErr)
-		return nil, false
+	transformedHash, err := hasher.CalculateSHA256(transformed)
+	if err != nil {
+		return nil, err
 	}
 	if verbose {
-		_, _ = fmt.Fprintf(os.Stdout, "    Successfully saved %s to %s\n", dep.Name, dep.ProjectTomlPath)
+		logger.Progressf("    Applied transform '%s' (%d -> %d bytes)", dep.Transform, len(fileContent), len(transformed))
+	}
+	entry.Transform, entry.TransformedHash = dep.Transform, transformedHash
+	return transformed, nil
+}
+
+// executeSingleInstallOperation handles the installation process for a single dependency: the
+// fetch stage gets its content, through downloads so each URL is fetched once per run, and the
+// apply stage writes it and records its digests in dep. It returns the new lockfile entry, or
+// the exit code describing why the install failed.
+func executeSingleInstallOperation(dep *dependencyInstallState, downloads *runDownloads, settings filemode.Settings, verbose bool) (*lockfile.PackageEntry, int) {
+	if verbose {
+		logger.Progressf("  Installing/Updating '%s' from %s", dep.Name, dep.TargetRawURL)
+	}
+	if dep.archiveFormat() != "" {
+		return installArchive(dep, downloads, settings, verbose)
+	}
+	if len(dep.Files) > 0 {
+		return installDirectory(dep, downloads, settings, verbose)
+	}
+	fileContent, code := downloads.fetch(*dep, verbose)
+	if code != exitcode.OK {
+		return nil, code
+	}
+	return applyStage(dep, fileContent, settings, verbose)
+}
+
+// fetchStage gets a dependency's upstream content, from the cache when possible, and reports
+// why it could not. It writes nothing in the project.
+func fetchStage(dep dependencyInstallState, verbose bool) ([]byte, int) {
+	if dep.RecheckedContent != nil {
+		return dep.RecheckedContent, exitcode.OK
+	}
+	fileContent, fromCache, downloadErr := fetchDependencyContent(dep)
+	if lockedCommitGone(dep, downloadErr) {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v: locked commit %s of '%s' no longer exists upstream (%s).\n"+
+			"  The repository was probably force-pushed, or the commit, file or repository deleted. Change the ref in\n"+
+			"  %s to a commit that still exists, then run 'almd install %s' again.\n",
+			source.ErrUpstreamRewritten, shortSHA(dep.TargetCommitHash), dep.Name, dep.TargetRawURL, config.ManifestName(), dep.Name)
+		return nil, exitcode.Resolution
+	}
+	if errors.Is(downloadErr, errNotFetched) {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: cannot install '%s' offline: %v. Run 'almd fetch' with network access first.\n", dep.Name, downloadErr)
+		return nil, exitcode.Download
+	}
+	if downloadErr != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: Failed to download dependency '%s' from '%s': %v\n", dep.Name, dep.TargetRawURL, downloadErr)
+		return nil, exitcode.Download
+	}
+	if verbose {
+		if fromCache {
+			logger.Progressf("    Using cached copy of %s (%d bytes)", dep.Name, len(fileContent))
+		} else {
+			logger.Progressf("    Successfully downloaded %s (%d bytes)", dep.Name, len(fileContent))
+		}
+	}
+	return fileContent, exitcode.OK
+}
+
+// applyStage hashes and transforms fetched content and writes it to the dependency's path with
+// the run's file settings, recording the digests of the fetched and the written content in dep.
+// It returns the new lockfile entry, or the exit code describing why it failed.
+func applyStage(dep *dependencyInstallState, fileContent []byte, settings filemode.Settings, verbose bool) (*lockfile.PackageEntry, int) {
+	upstreamHash, hashErr := hasher.CalculateSHA256(fileContent)
+	if hashErr != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: Failed to calculate SHA256 hash for dependency '%s': %v\n", dep.Name, hashErr)
+		return nil, exitcode.Usage
+	}
+	integrityHash, hashErr := integrityHashFor(*dep, fileContent, verbose)
+	if hashErr != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: Failed to calculate SHA256 hash for dependency '%s': %v\n", dep.Name, hashErr)
+		return nil, exitcode.Usage
 	}
 
 	newEntry := lockfile.PackageEntry{
@@ -421,109 +877,590 @@ func executeSingleInstallOperation(dep dependencyInstallState, verbose bool) (*l
 		Path:   dep.ProjectTomlPath,
 		Hash:   integrityHash,
 	}
+	fileContent, transformErr := applyTransform(*dep, fileContent, &newEntry, verbose)
+	if transformErr != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: Failed to transform dependency '%s': %v\n", dep.Name, transformErr)
+		return nil, exitcode.Usage
+	}
+
+	written, writeErr := writeDependencyFile(*dep, fileContent, settings)
+	if writeErr != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", writeErr)
+		return nil, exitcode.Usage
+	}
+	fileHash, hashErr := hasher.CalculateSHA256(written)
+	if hashErr != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: Failed to calculate SHA256 hash for dependency '%s': %v\n", dep.Name, hashErr)
+		return nil, exitcode.Usage
+	}
+	if mode := installMode(dep.Install); mode != coreproject.InstallCopy {
+		newEntry.Install = mode
+		if mode == coreproject.InstallGenerate {
+			newEntry.TransformedHash = fileHash
+		}
+	}
+	dep.UpstreamSHA256 = strings.TrimPrefix(upstreamHash, "sha256:")
+	dep.FileSHA256 = strings.TrimPrefix(fileHash, "sha256:")
 	if verbose {
-		_, _ = fmt.Fprintf(os.Stdout, "    Prepared lockfile entry for %s: Path=%s, Hash=%s, SourceURL=%s\n", dep.Name, newEntry.Path, newEntry.Hash, newEntry.Source)
+		logger.Progressf("    Successfully saved %s to %s", dep.Name, dep.ProjectTomlPath)
+		logger.Progressf("    Prepared lockfile entry for %s: Path=%s, Hash=%s, SourceURL=%s", dep.Name, newEntry.Path, newEntry.Hash, newEntry.Source)
 	}
-	return &newEntry, true
+	return &newEntry, exitcode.OK
 }
 
-// executeInstallOperations performs the download, hashing, file saving, and lockfile data updates.
-func executeInstallOperations(dependenciesThatNeedAction []dependencyInstallState, lf *lockfile.Lockfile, verbose bool) (successfulActions int, err error) {
+// executeInstallOperations performs the download, hashing and file saving, recording lockfile
+// updates in tx and progress, and failures in out. With a journal every file is backed up before
+// it is written; with failFast the run stops at the first failure, otherwise every dependency is
+// attempted. An interrupted run stops before the next dependency.
+func executeInstallOperations(dependenciesThatNeedAction []dependencyInstallState, tx *lockfile.Tx, out *outcome, journal *rollback, failFast bool, settings filemode.Settings, progress *runProgress, stats *runStats, verbose bool) (installed []dependencyInstallState, err error) {
 	if verbose && len(dependenciesThatNeedAction) > 0 {
-		_, _ = fmt.Fprintln(os.Stdout, "\nPerforming install/update for identified dependencies...")
+		logger.Progressf("\nPerforming install/update for identified dependencies...")
 	}
 
+	downloads := newRunDownloads(stats)
 	for _, dep := range dependenciesThatNeedAction {
-		newLockEntry, success := executeSingleInstallOperation(dep, verbose)
-		if success && newLockEntry != nil {
-			lf.Package[dep.Name] = *newLockEntry
-			if verbose {
-				_, _ = fmt.Fprintf(os.Stdout, "    Updated lockfile for %s.\n", dep.Name)
+		if progress.stopped() {
+			break
+		}
+		if journal != nil {
+			if snapErr := journal.snapshotDependency(dep); snapErr != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", snapErr)
+				out.fail(dep.Name, exitcode.Usage)
+				if failFast {
+					break
+				}
+				continue
 			}
-			successfulActions++
-		} else {
-			// Error message already printed by executeSingleInstallOperation
+		}
+		newLockEntry, code := executeSingleInstallOperation(&dep, downloads, settings, verbose)
+		if code == exitcode.OK && newLockEntry != nil {
+			tx.Set(dep.Name, *newLockEntry)
+			progress.record(&dep, *newLockEntry)
 			if verbose {
-				_, _ = fmt.Fprintf(os.Stdout, "    Failed to process %s.\n", dep.Name)
+				logger.Progressf("    Updated lockfile for %s.", dep.Name)
 			}
+			installed = append(installed, dep)
+			continue
+		}
+		// Error message already printed by executeSingleInstallOperation
+		out.fail(dep.Name, code)
+		if verbose {
+			logger.Progressf("    Failed to process %s.", dep.Name)
+		}
+		if failFast {
+			break
+		}
+	}
+	return installed, nil
+}
+
+// lockfileDriftChecks are the checks of filterDependenciesRequiringAction whose reasons mean the
+// dependency's lockfile entry would change, rather than only its files on disk.
+var lockfileDriftChecks = []func(state dependencyInstallState, verbose bool) (bool, string){
+	checkMissingFromLockfile,
+	checkCommitHashMismatch,
+	checkContentChanged,
+	checkHashTypeConflict,
+	checkTransformChanged,
+	checkInstallModeChanged,
+	checkFileListChanged,
+	checkExtractChanged,
+}
+
+// lockfileDriftReason reports why installing a dependency would change its lockfile entry,
+// or an empty string if the locked entry would be kept as is.
+func lockfileDriftReason(state dependencyInstallState) string {
+	for _, check := range lockfileDriftChecks {
+		if needsAction, reason := check(state, false); needsAction {
+			return reason
+		}
+	}
+	return ""
+}
+
+// checkFrozenLockfile fails when a --frozen install would have to modify almd-lock.toml,
+// either by changing a dependency's entry or by pruning stale ones. It does nothing for
+// installs that are not frozen.
+func checkFrozenLockfile(projCfg *coreproject.Project, lf *lockfile.Lockfile, dependencyNames []string, opts installOptions, dependenciesThatNeedAction []dependencyInstallState) error {
+	if !opts.Frozen {
+		return nil
+	}
+	var problems []string
+	for _, dep := range dependenciesThatNeedAction {
+		if reason := lockfileDriftReason(dep); reason != "" {
+			problems = append(problems, fmt.Sprintf("%s: %s", dep.Name, reason))
+		}
+	}
+	if len(dependencyNames) == 0 && !opts.NoPrune {
+		for _, name := range staleLockEntries(projCfg, lf) {
+			problems = append(problems, fmt.Sprintf("%s: Locked but no longer in project.toml.", name))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("almd-lock.toml is out of date and --frozen is set:\n  %s", strings.Join(problems, "\n  "))
+}
+
+// staleLockEntries returns the sorted names of locked dependencies that project.toml no longer declares.
+func staleLockEntries(projCfg *coreproject.Project, lf *lockfile.Lockfile) []string {
+	var stale []string
+	for name := range lf.Package {
+		if _, ok := projCfg.Dependencies[name]; !ok {
+			stale = append(stale, name)
 		}
 	}
-	return successfulActions, nil
+	sort.Strings(stale)
+	return stale
+}
+
+// prunesLockfile reports whether an install run prunes stale lockfile entries.
+func prunesLockfile(dependencyNames []string, opts installOptions) bool {
+	return len(dependencyNames) == 0 && !opts.NoPrune && !opts.Frozen
+}
+
+// pruneStaleLockEntries records the removal of lockfile entries whose dependencies are no longer
+// declared in project.toml, so that a full install leaves
+// almd-lock.toml mirroring the manifest. Only a full install knows the complete set of
+// dependencies, so targeted installs never prune; frozen installs report stale entries
+// through checkFrozenLockfile instead.
+func pruneStaleLockEntries(projCfg *coreproject.Project, tx *lockfile.Tx, dependencyNames []string, opts installOptions) {
+	if !prunesLockfile(dependencyNames, opts) {
+		return
+	}
+	pruned := tx.Prune(func(name string) bool {
+		_, ok := projCfg.Dependencies[name]
+		return ok
+	})
+	if len(pruned) == 0 && opts.Verbose {
+		logger.Progressf("No stale lockfile entries to prune.")
+	}
+	for _, name := range pruned {
+		_, _ = fmt.Fprintf(os.Stdout, "Pruned stale lockfile entry '%s' (not in project.toml).\n", name)
+	}
+}
+
+// saveInstallResults writes the lockfile changes collected during a run and reports the outcome.
+// Failed installs are reported through the run's outcome.
+func saveInstallResults(tx *lockfile.Tx, successfulActions, attemptedActions int, verbose bool) error {
+	if err := commitLockfile(tx); err != nil {
+		return err
+	}
+	if successfulActions > 0 {
+		if verbose {
+			logger.Progressf("\nSuccessfully saved almd-lock.toml with %d action(s).", successfulActions)
+		}
+		_, _ = fmt.Fprintf(os.Stdout, "Successfully installed/updated %d dependenc(ies).\n", successfulActions)
+	} else {
+		if attemptedActions > 0 { // Implies all actions failed
+			_, _ = fmt.Fprintln(os.Stderr, "No dependencies were successfully installed/updated due to errors.")
+		}
+		// If no actions were attempted, this path shouldn't be reached due to the earlier up-to-date check.
+	}
+	return nil
+}
+
+// commitLockfile writes almd-lock.toml once with every change collected in tx, if there are any.
+// The lockfile then holds everything the run journal recorded, so the journal is removed.
+func commitLockfile(tx *lockfile.Tx) error {
+	if !tx.Changed() {
+		clearProgress()
+		return nil
+	}
+	conflicts, err := tx.Commit(".")
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: Failed to save updated almd-lock.toml: %v", err), 1)
+	}
+	clearProgress()
+	for _, name := range conflicts {
+		warnings.Printf("Conflicting lockfile updates for '%s'; kept the first entry in sorted order.", name)
+	}
+	return nil
+}
+
+func installFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "force",
+			Aliases: []string{"f"},
+			Usage:   "Force install/update even if versions appear to match",
+		},
+		&cli.BoolFlag{
+			Name:  "verbose",
+			Usage: "Enable verbose output",
+		},
+		&cli.BoolFlag{
+			Name:  "no-prune",
+			Usage: "Keep lockfile entries for dependencies no longer in project.toml",
+		},
+		&cli.BoolFlag{
+			Name:  "frozen",
+			Usage: "Fail instead of modifying almd-lock.toml",
+		},
+		&cli.BoolFlag{
+			Name:  "strict",
+			Usage: "Treat warnings (skipped dependencies, unparsable sources, unresolved refs) as failures",
+		},
+		&cli.BoolFlag{
+			Name:  "keep-going",
+			Usage: "Attempt every dependency and report all failures at the end (default)",
+		},
+		&cli.BoolFlag{
+			Name:  "fail-fast",
+			Usage: "Stop at the first failure and roll back the files and lockfile changes of this run",
+		},
+		&cli.BoolFlag{
+			Name:  "allow-downgrade",
+			Usage: "Install dependencies even if their new commit is older than the locked one",
+		},
+		&cli.BoolFlag{
+			Name:  "allow-overwrite",
+			Usage: "Install dependencies even over existing files that almd did not install",
+		},
+		&cli.BoolFlag{
+			Name:  "tag-fallback",
+			Usage: "If a tag was deleted or renamed upstream, use a remaining tag of the same version (e.g. 1.2.0 for v1.2.0)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "label",
+			Usage: "Only install dependencies with this label (repeat for any of several)",
+		},
+		&cli.StringFlag{
+			Name:  "profile",
+			Usage: "Apply a named settings profile (built-in: dev, ci, release; or [profiles.<name>] in project.toml)",
+		},
+		&cli.BoolFlag{
+			Name:  "offline",
+			Usage: "Install the locked versions from the cache without network access (fill the cache with 'almd fetch')",
+		},
+		&cli.BoolFlag{
+			Name:  "apply",
+			Usage: "Apply the plan without asking for confirmation (with --plan or 'explain')",
+		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Print the plan and exit without changing anything",
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "Print the plan as JSON (with --dry-run)",
+		},
+		&cli.BoolFlag{
+			Name:    "stats",
+			Usage:   "Print requests, bytes downloaded, cache hit ratio and the time of each phase when done",
+			EnvVars: []string{"ALMD_STATS"},
+		},
+	}
 }
 
 // InstallCmd creates a new install command that handles dependency management.
 func InstallCmd() *cli.Command {
 	return &cli.Command{
-		Name:      "install",
-		Usage:     "Installs or updates project dependencies based on project.toml",
-		ArgsUsage: "[dependency_names...]",
-		Flags: []cli.Flag{
-			&cli.BoolFlag{
-				Name:    "force",
-				Aliases: []string{"f"},
-				Usage:   "Force install/update even if versions appear to match",
-			},
-			&cli.BoolFlag{
-				Name:  "verbose",
-				Usage: "Enable verbose output",
-			},
-		},
+		Name:         "install",
+		Usage:        "Installs or updates project dependencies based on project.toml",
+		ArgsUsage:    "[dependency_names...]",
+		BashComplete: completion.Dependencies,
+		Flags: append(installFlags(), &cli.BoolFlag{
+			Name:  "plan",
+			Usage: "Print the full plan and ask for confirmation before changing anything",
+		}),
 		Action: func(c *cli.Context) error {
-			projCfg, lf, dependencyNames, force, verbose, err := loadInstallConfigAndArgs(c)
-			if err != nil {
-				return err // Error is already a cli.Exit
-			}
+			return runInstall(c, c.Bool("plan"))
+		},
+	}
+}
 
-			dependenciesToProcessList, err := collectDependenciesToProcess(projCfg, dependencyNames, verbose)
-			if err != nil {
-				return cli.Exit(fmt.Sprintf("Error collecting dependencies to process: %v", err), 1)
-			}
-			if dependenciesToProcessList == nil { // Indicates no work to do, message already printed
-				return nil
-			}
+// resolveDependencyActions resolves every targeted dependency, picks those that need an
+// install/update, refuses downgrades and applies the --frozen check, so a plan already shows
+// what the run would refuse. States are nil when nothing is targeted.
+func resolveDependencyActions(projCfg *coreproject.Project, lf *lockfile.Lockfile, dependencyNames []string, opts installOptions, out *outcome) (installStates, dependenciesThatNeedAction []dependencyInstallState, err error) {
+	dependenciesToProcessList, err := collectDependenciesToProcess(projCfg, dependencyNames, out, opts.messages(), opts.Verbose)
+	if err != nil {
+		return nil, nil, cli.Exit(fmt.Sprintf("Error collecting dependencies to process: %v", err), 1)
+	}
+	if dependenciesToProcessList != nil { // nil indicates no work to do, message already printed
+		for i := range dependenciesToProcessList {
+			dependenciesToProcessList[i].TagFallback = opts.TagFallback
+			dependenciesToProcessList[i].Offline = opts.Offline
+			dependenciesToProcessList[i].Resumed = opts.resumed[dependenciesToProcessList[i].Name]
+		}
+		installStates, err = resolveInstallStates(dependenciesToProcessList, lf, out, opts.Verbose)
+		if err != nil {
+			return nil, nil, cli.Exit(fmt.Sprintf("Error resolving dependency states: %v", err), 1)
+		}
+		if installStates == nil {
+			installStates = []dependencyInstallState{} // Targeted, but every dependency was skipped
+		}
+		dependenciesThatNeedAction = filterDependenciesRequiringAction(installStates, opts.Force, opts.Verbose)
+		warnLocalModifications(installStates, dependenciesThatNeedAction, lf)
+		dependenciesThatNeedAction = guardDowngrades(installStates, dependenciesThatNeedAction, opts, out)
+		dependenciesThatNeedAction = guardOverwrites(installStates, dependenciesThatNeedAction, lf, opts, out)
+		if !opts.Offline {
+			dependenciesThatNeedAction = guardAdvisories(installStates, dependenciesThatNeedAction, opts, out)
+		}
+	}
 
-			installStates, err := resolveInstallStates(dependenciesToProcessList, lf, verbose)
-			if err != nil {
-				return cli.Exit(fmt.Sprintf("Error resolving dependency states: %v", err), 1)
-			}
+	if err := checkFrozenLockfile(projCfg, lf, dependencyNames, opts, dependenciesThatNeedAction); err != nil {
+		return nil, nil, cli.Exit(fmt.Sprintf("Error: %v", err), exitcode.Integrity)
+	}
+	return installStates, dependenciesThatNeedAction, nil
+}
 
-			dependenciesThatNeedAction := filterDependenciesRequiringAction(installStates, force, verbose)
+// runPlanGate prints the plan and, once it is confirmed, prunes the stale lockfile entries the
+// plan listed. It reports whether the run should go on to install, which it never does with
+// --dry-run.
+func runPlanGate(c *cli.Context, projCfg *coreproject.Project, tx *lockfile.Tx, dependencyNames []string, opts installOptions, installStates, dependenciesThatNeedAction []dependencyInstallState, stale []string) (bool, error) {
+	if c.Bool("json") {
+		if err := printInstallPlanJSON(os.Stdout, installStates, dependenciesThatNeedAction, stale); err != nil {
+			return false, cli.Exit(fmt.Sprintf("Error printing plan: %v", err), 1)
+		}
+		return false, nil
+	}
+	printInstallPlan(os.Stdout, installStates, dependenciesThatNeedAction, stale, opts.FileSettings)
+	if c.Bool("dry-run") {
+		return false, nil
+	}
+	if len(dependenciesThatNeedAction) == 0 && len(stale) == 0 {
+		_, _ = fmt.Fprintln(os.Stdout, "\nNo changes.")
+		return false, nil
+	}
+	if !confirmPlan(c.Bool("apply")) {
+		return false, nil
+	}
+	pruneStaleLockEntries(projCfg, tx, dependencyNames, opts)
+	return true, nil
+}
 
-			if len(dependenciesThatNeedAction) == 0 {
-				_, _ = fmt.Fprintln(os.Stdout, "All targeted dependencies are already up-to-date.")
-				return nil
-			}
+// runInstall performs an install/update run. With showPlan set, nothing is changed until the
+// printed plan has been confirmed.
+func runInstall(c *cli.Context, showPlan bool) error {
+	if c.Bool("json") && !c.Bool("dry-run") {
+		return cli.Exit("Error: --json prints the plan and needs --dry-run", exitcode.Usage)
+	}
+	showPlan = showPlan || c.Bool("dry-run")
+	projCfg, lf, dependencyNames, opts, err := loadInstallConfigAndArgs(c)
+	if err != nil {
+		return err // Error is already a cli.Exit
+	}
+	defer opts.stats.print(os.Stderr)
 
-			if verbose {
-				_, _ = fmt.Fprintf(os.Stdout, "\nDependencies to be installed/updated (%d):\n", len(dependenciesThatNeedAction))
-				for _, dep := range dependenciesThatNeedAction {
-					_, _ = fmt.Fprintf(os.Stdout, "  - %s (Reason: %s)\n", dep.Name, dep.ActionReason)
-				}
-			}
+	tx, stale := beginLockfileTx(projCfg, lf, dependencyNames, opts, showPlan)
+	opts.resumed = resumeProgress(projCfg, lf, tx, opts)
 
-			successfulActions, err := executeInstallOperations(dependenciesThatNeedAction, lf, verbose)
-			if err != nil {
-				// This error isn't currently returned by executeInstallOperations but good for future proofing
-				return cli.Exit(fmt.Sprintf("Critical error during install operations: %v", err), 1)
-			}
+	out := &outcome{}
+	stopResolve := opts.stats.time(phaseResolve)
+	installStates, dependenciesThatNeedAction, err := resolveDependencyActions(projCfg, lf, dependencyNames, opts, out)
+	stopResolve()
+	if err != nil {
+		return err
+	}
+	targeted := countTargeted(projCfg, dependencyNames)
+	if opts.FailFast && out.failed(opts.Strict) {
+		out.aborted = true // Nothing has been written yet
+		return out.exitError(targeted, opts.Strict)
+	}
 
-			if successfulActions > 0 {
-				lf.ApiVersion = lockfile.APIVersion // Ensure API version is set
-				if err := lockfile.Save(".", lf); err != nil {
-					return cli.Exit(fmt.Sprintf("Error: Failed to save updated almd-lock.toml: %v", err), 1)
-				}
-				if verbose {
-					_, _ = fmt.Fprintf(os.Stdout, "\nSuccessfully saved almd-lock.toml with %d action(s).\n", successfulActions)
-				}
-				_, _ = fmt.Fprintf(os.Stdout, "Successfully installed/updated %d dependenc(ies).\n", successfulActions)
-			} else {
-				if len(dependenciesThatNeedAction) > 0 { // Implies all actions failed
-					_, _ = fmt.Fprintln(os.Stderr, "No dependencies were successfully installed/updated due to errors.")
-					return cli.Exit("Install/Update process completed with errors for all targeted dependencies.", 1)
-				}
-				// If dependenciesThatNeedAction was empty, this path shouldn't be reached due to earlier check.
-			}
-			return nil
-		},
+	if showPlan {
+		proceed, err := runPlanGate(c, projCfg, tx, dependencyNames, opts, installStates, dependenciesThatNeedAction, stale)
+		if err != nil || !proceed {
+			return err
+		}
+	}
+
+	if installStates == nil || len(dependenciesThatNeedAction) == 0 {
+		err = finishWithoutChanges(projCfg, tx, installStates != nil, out)
+	} else {
+		err = performInstall(projCfg, dependenciesThatNeedAction, tx, out, opts)
+	}
+	if err != nil {
+		return err
+	}
+	return out.exitError(targeted, opts.Strict)
+}
+
+// beginLockfileTx starts the run's lockfile transaction and returns the lock entries it prunes.
+// With a plan to show, they are only listed; pruning waits until the plan is confirmed.
+func beginLockfileTx(projCfg *coreproject.Project, lf *lockfile.Lockfile, dependencyNames []string, opts installOptions, showPlan bool) (*lockfile.Tx, []string) {
+	var stale []string
+	if prunesLockfile(dependencyNames, opts) {
+		stale = staleLockEntries(projCfg, lf)
+	}
+	tx := lf.Begin()
+	if !showPlan {
+		pruneStaleLockEntries(projCfg, tx, dependencyNames, opts)
+	}
+	return tx, stale
+}
+
+// finishWithoutChanges ends a run that has nothing to install: the budget is still enforced and
+// lock entries pruned along the way are saved. upToDate reports that dependencies were checked.
+func finishWithoutChanges(projCfg *coreproject.Project, tx *lockfile.Tx, upToDate bool, out *outcome) error {
+	if upToDate {
+		_, _ = fmt.Fprintln(os.Stdout, "All targeted dependencies are already up-to-date.")
+	}
+	if err := enforceBudget(projCfg); err != nil {
+		return err
+	}
+	if err := commitLockfile(tx); err != nil {
+		return err
+	}
+	if upToDate {
+		writeJobSummary(nil, out)
+	}
+	return nil
+}
+
+// enforceBudget checks the dependency files against the project's [budget]. An exceeded budget
+// fails the run unless warn_only is set. performInstall checks the files it wrote before the
+// lockfile is saved, and rolls them back when they do not fit.
+func enforceBudget(projCfg *coreproject.Project) error {
+	if err := budget.Enforce(".", projCfg.Budget, projCfg.Dependencies); err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), exitcode.Usage)
+	}
+	return nil
+}
+
+// countTargeted returns the number of dependencies a run targets: the named ones, or all.
+func countTargeted(projCfg *coreproject.Project, dependencyNames []string) int {
+	if len(dependencyNames) > 0 {
+		return len(dependencyNames)
+	}
+	return len(projCfg.Dependencies)
+}
+
+// performInstall installs the dependencies that need action and writes the lockfile once.
+// With --fail-fast a failure undoes the files written so far and leaves almd-lock.toml untouched;
+// so does an exceeded [budget], which is checked before the lockfile is written.
+func performInstall(projCfg *coreproject.Project, dependenciesThatNeedAction []dependencyInstallState, tx *lockfile.Tx, out *outcome, opts installOptions) error {
+	verbose := opts.Verbose
+	if verbose {
+		logger.Progressf("\nDependencies to be installed/updated (%d):", len(dependenciesThatNeedAction))
+		for _, dep := range dependenciesThatNeedAction {
+			logger.Progressf("  - %s (Reason: %s)", dep.Name, dep.ActionReason)
+		}
+	}
+
+	started := time.Now()
+	attemptedActions := len(dependenciesThatNeedAction)
+	var journal *rollback
+	if opts.FailFast || budgetEnforced(projCfg.Budget) {
+		journal = &rollback{}
+	}
+	progress := startProgress()
+	defer progress.stop()
+	stopInstall := opts.stats.time(phaseInstall)
+	installed, err := executeInstallOperations(dependenciesThatNeedAction, tx, out, journal, opts.FailFast, opts.FileSettings, progress, opts.stats, verbose)
+	stopInstall()
+	if err != nil {
+		// This error isn't currently returned by executeInstallOperations but good for future proofing
+		return cli.Exit(fmt.Sprintf("Critical error during install operations: %v", err), 1)
+	}
+	if opts.FailFast && len(out.failures) > 0 {
+		return rollbackRun(journal, out)
+	}
+	if err := enforceBudget(projCfg); err != nil {
+		return rollbackOverBudget(journal, err)
+	}
+	if err := saveInstallResults(tx, len(installed), attemptedActions, verbose); err != nil {
+		return err
+	}
+	writeAttestation(installed, opts.ToolVersion, started)
+	recordInventory(projCfg, installed)
+	writeJobSummary(installed, out)
+	if progress.stopped() {
+		return cli.Exit(fmt.Sprintf("Interrupted after installing %d of %d dependencies; run 'almd install' again to install the rest.", len(installed), attemptedActions), exitcode.Interrupted)
+	}
+	return nil
+}
+
+// writeAttestation records the provenance of the files a run wrote in .almd/attestations.
+// Failing to do so does not undo the install and is reported as a warning.
+func writeAttestation(installed []dependencyInstallState, toolVersion string, started time.Time) {
+	if len(installed) == 0 {
+		return
+	}
+	run := attestation.Run{ToolVersion: toolVersion, StartedOn: started, FinishedOn: time.Now()}
+	for _, dep := range installed {
+		run.Materials = append(run.Materials, dependencyMaterials(dep)...)
+	}
+	path, err := attestation.Write(".", run)
+	if err != nil {
+		warnings.Printf("could not write provenance attestation: %v", err)
+		return
+	}
+	_, _ = fmt.Fprintf(os.Stdout, "Recorded provenance in %s.\n", path)
+}
+
+// dependencyMaterials returns the attestation materials of an installed dependency: one for its
+// file, or one for each file of a directory or archive dependency, whose path is a directory.
+func dependencyMaterials(dep dependencyInstallState) []attestation.Material {
+	commit := ""
+	if isCommitSHARegex.MatchString(dep.TargetCommitHash) {
+		commit = dep.TargetCommitHash
+	}
+	material := attestation.Material{Name: dep.Name, Source: dep.TargetRawURL, Commit: commit}
+	if len(dep.Files) == 0 {
+		material.Path, material.UpstreamSHA256, material.FileSHA256 = dep.ProjectTomlPath, dep.UpstreamSHA256, dep.FileSHA256
+		return []attestation.Material{material}
+	}
+	materials := make([]attestation.Material, 0, len(dep.Files))
+	for _, file := range dep.Files {
+		material.Path = path.Join(dep.ProjectTomlPath, file)
+		material.UpstreamSHA256 = strings.TrimPrefix(dep.FileHashes[file], "sha256:")
+		materials = append(materials, material)
+	}
+	return materials
+}
+
+// recordInventory adds the files a run wrote to the project's inventory of files almd
+// installed. Failing to do so does not undo the install and is reported as a warning.
+func recordInventory(projCfg *coreproject.Project, installed []dependencyInstallState) {
+	if len(installed) == 0 {
+		return
+	}
+	written := make([]string, 0, len(installed))
+	for _, dep := range installed {
+		written = append(written, dep.filePaths()...)
+	}
+	if err := inventory.Record(".", projCfg.DependencyPaths(), written...); err != nil {
+		warnings.Printf("could not update %s: %v", inventory.Path, err)
+	}
+}
+
+// budgetEnforced reports whether b has limits whose violation fails the run.
+func budgetEnforced(b *coreproject.Budget) bool {
+	if b == nil || b.WarnOnly {
+		return false
+	}
+	maxTotal, maxFile, err := b.Limits()
+	return err == nil && (maxTotal > 0 || maxFile > 0)
+}
+
+// rollbackOverBudget restores the files a run wrote before its [budget] check failed and returns
+// that failure. The lockfile changes are discarded by not committing them.
+func rollbackOverBudget(journal *rollback, budgetErr error) error {
+	restored, failed := journal.restore()
+	clearProgress() // The run's files are undone, so there is nothing to resume
+	if restored > 0 {
+		_, _ = fmt.Fprintf(os.Stderr, "Rolled back %d file(s) that did not fit the budget.\n", restored)
+	}
+	if len(failed) > 0 {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: could not roll back:\n  %s\n", strings.Join(failed, "\n  "))
+	}
+	return budgetErr
+}
+
+// rollbackRun restores the files a --fail-fast run wrote before it failed. The lockfile changes
+// collected for the run are discarded by not committing them.
+func rollbackRun(journal *rollback, out *outcome) error {
+	out.aborted = true
+	restored, failed := journal.restore()
+	clearProgress() // The run's files are undone, so there is nothing to resume
+	if restored > 0 {
+		_, _ = fmt.Fprintf(os.Stderr, "Rolled back %d file(s) written before the failure.\n", restored)
+	}
+	if len(failed) > 0 {
+		return cli.Exit(fmt.Sprintf("Error: --fail-fast could not roll back:\n  %s", strings.Join(failed, "\n  ")), 1)
 	}
+	return nil
 }
@@ -4,19 +4,38 @@
 package install_test
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	installcmd "github.com/nightconcept/almandine/internal/cli/install"
+	"github.com/nightconcept/almandine/internal/core/attestation"
 	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+	"github.com/nightconcept/almandine/internal/core/hasher"
+	"github.com/nightconcept/almandine/internal/core/jobsummary"
+	"github.com/nightconcept/almandine/internal/core/journal"
 	"github.com/nightconcept/almandine/internal/core/lockfile"
+	"github.com/nightconcept/almandine/internal/core/paths"
 	"github.com/nightconcept/almandine/internal/core/project"
 	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/nightconcept/almandine/internal/core/warnings"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/urfave/cli/v2"
@@ -62,6 +81,10 @@ func startMockHTTPServer(t *testing.T, pathResponses map[string]struct {
 func setupInstallTestEnvironment(t *testing.T, initialProjectTomlContent string, initialLockfileContent string, mockDepFiles map[string]string) (tempDir string) {
 	t.Helper()
 	tempDir = t.TempDir()
+	// Keep downloads and file state out of the real user directories.
+	t.Setenv(paths.CacheDirEnv, filepath.Join(t.TempDir(), "cache"))
+	t.Setenv(paths.StateDirEnv, filepath.Join(t.TempDir(), "state"))
+	t.Setenv(jobsummary.Env, "") // Keep CI runs of the tests out of the real job summary
 
 	if initialProjectTomlContent != "" {
 		projectTomlPath := filepath.Join(tempDir, config.ProjectTomlName)
@@ -118,6 +141,27 @@ func runInstallCommand(t *testing.T, workDir string, installCmdArgs ...string) e
 	return app.Run(cliArgs)
 }
 
+// runFetchCommand executes the 'fetch' command in workDir, restoring the working directory afterwards.
+func runFetchCommand(t *testing.T, workDir string, fetchCmdArgs ...string) error {
+	t.Helper()
+
+	originalWd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(workDir))
+	defer func() {
+		require.NoError(t, os.Chdir(originalWd))
+	}()
+
+	app := &cli.App{
+		Name:           "almd-test-fetch",
+		Commands:       []*cli.Command{installcmd.FetchCmd()},
+		Writer:         os.Stderr,
+		ErrWriter:      os.Stderr,
+		ExitErrHandler: func(context *cli.Context, err error) {},
+	}
+	return app.Run(append([]string{"almd-test-fetch", "fetch"}, fetchCmdArgs...))
+}
+
 // readProjectToml reads and unmarshals the project.toml file into a Project struct.
 // It ensures the file exists and is valid TOML.
 func readProjectToml(t *testing.T, tomlPath string) project.Project {
@@ -222,6 +266,19 @@ hash = "commit:commit1_sha_abcdef1234567890"
 	depAProjEntry, ok := currentProjCfg.Dependencies[depAName]
 	require.True(t, ok, "depA entry not found in project.toml")
 	assert.Equal(t, fmt.Sprintf("github:testowner/testrepo/%s@main", depAPath), depAProjEntry.Source, "project.toml source for depA should not change")
+
+	statements, globErr := filepath.Glob(filepath.Join(tempDir, filepath.FromSlash(attestation.Dir), "*.intoto.json"))
+	require.NoError(t, globErr)
+	require.Len(t, statements, 1, "the run should record one provenance attestation")
+	data, readErr := os.ReadFile(statements[0])
+	require.NoError(t, readErr)
+	var statement attestation.Statement
+	require.NoError(t, json.Unmarshal(data, &statement))
+	require.Len(t, statement.Subject, 1)
+	assert.Equal(t, depAPath, statement.Subject[0].Name)
+	require.Len(t, statement.Predicate.BuildDefinition.ResolvedDependencies, 1)
+	assert.Equal(t, expectedLockSourceURL, statement.Predicate.BuildDefinition.ResolvedDependencies[0].URI)
+	assert.Equal(t, commit2SHA, statement.Predicate.BuildDefinition.ResolvedDependencies[0].Digest["gitCommit"])
 }
 
 // TestInstallCommand_SpecificDepInstall_OneNeedsUpdate verifies that installing a specific
@@ -412,6 +469,7 @@ hash = "commit:%s"
 	errUnmarshalProj := toml.Unmarshal([]byte(initialProjectToml), &originalProjCfg)
 	require.NoError(t, errUnmarshalProj, "Failed to unmarshal original project.toml content for comparison")
 	assert.Equal(t, originalProjCfg, currentProjCfg, "project.toml should be unchanged")
+	assert.NoDirExists(t, filepath.Join(tempDir, filepath.FromSlash(attestation.Dir)), "a run that writes nothing records no attestation")
 }
 
 // TestInstallCommand_DepInProjectToml_MissingFromLockfile verifies that dependencies
@@ -861,3 +919,2041 @@ func TestInstallCommand_ProjectTomlNotFound(t *testing.T) {
 	assert.Contains(t, err.Error(), config.ProjectTomlName, "Error message should mention project.toml")
 	assert.Contains(t, err.Error(), "not found in the current directory", "Error message should indicate file not found in current directory")
 }
+
+// TestInstallCommand_QualifiedRefs verifies that tag-qualified refs are resolved through their full
+// ref name (so a same-named branch cannot shadow the tag) and that commit-qualified refs are used
+// directly without consulting the GitHub API.
+func TestInstallCommand_QualifiedRefs(t *testing.T) {
+	tagDepPath := "libs/tagged.lua"
+	commitDepPath := "libs/pinned.lua"
+	tagCommitSHA := "1111111111222222222233333333334444444444"
+	pinnedSHA := "abcdef1"
+
+	initialProjectToml := fmt.Sprintf(`
+[package]
+name = "test-qualified-refs"
+version = "0.1.0"
+
+[dependencies.tagged]
+source = "github:testowner/testrepo/%s@tag:v1.0.0"
+path = "%s"
+
+[dependencies.pinned]
+source = "github:testowner/testrepo/%s@commit:%s"
+path = "%s"
+`, tagDepPath, tagDepPath, commitDepPath, pinnedSHA, commitDepPath)
+
+	tempDir := setupInstallTestEnvironment(t, initialProjectToml, "", nil)
+
+	pathResps := map[string]struct {
+		Body string
+		Code int
+	}{
+		fmt.Sprintf("/repos/testowner/testrepo/commits?path=%s&sha=refs/tags/v1.0.0&per_page=1", tagDepPath): {Body: fmt.Sprintf(`[{"sha": "%s"}]`, tagCommitSHA), Code: http.StatusOK},
+		fmt.Sprintf("/testowner/testrepo/%s/%s", tagCommitSHA, tagDepPath):                                   {Body: "return 'tagged'", Code: http.StatusOK},
+		fmt.Sprintf("/testowner/testrepo/%s/%s", pinnedSHA, commitDepPath):                                   {Body: "return 'pinned'", Code: http.StatusOK},
+	}
+	mockServer := startMockHTTPServer(t, pathResps)
+
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	err := runInstallCommand(t, tempDir)
+	require.NoError(t, err, "almd install command failed")
+
+	lockCfg := readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName))
+	assert.Equal(t, "commit:"+tagCommitSHA, lockCfg.Package["tagged"].Hash, "tag-qualified ref should lock to the resolved commit")
+	assert.Equal(t, "commit:"+pinnedSHA, lockCfg.Package["pinned"].Hash, "commit-qualified ref should lock to itself")
+
+	content, readErr := os.ReadFile(filepath.Join(tempDir, tagDepPath))
+	require.NoError(t, readErr)
+	assert.Equal(t, "return 'tagged'", string(content))
+}
+
+// TestInstallCommand_TagPattern verifies that a tag glob is resolved against the repository's tags,
+// that the highest matching tag wins, and that the lockfile records the tag's commit while the
+// manifest keeps the pattern.
+func TestInstallCommand_TagPattern(t *testing.T) {
+	depPath := "libs/globbed.lua"
+	tagCommitSHA := "5555555555666666666677777777778888888888"
+	depSource := "github:testowner/testrepo/" + depPath + "@v1.*"
+
+	initialProjectToml := fmt.Sprintf(`
+[package]
+name = "test-tag-pattern"
+version = "0.1.0"
+
+[dependencies.globbed]
+source = "%s"
+path = "%s"
+`, depSource, depPath)
+
+	tempDir := setupInstallTestEnvironment(t, initialProjectToml, "", nil)
+
+	pathResps := map[string]struct {
+		Body string
+		Code int
+	}{
+		"/repos/testowner/testrepo/tags?per_page=100&page=1":                                               {Body: `[{"name": "v1.2.0"}, {"name": "v1.10.1"}, {"name": "v2.0.0"}]`, Code: http.StatusOK},
+		fmt.Sprintf("/repos/testowner/testrepo/commits?path=%s&sha=refs/tags/v1.10.1&per_page=1", depPath): {Body: fmt.Sprintf(`[{"sha": "%s"}]`, tagCommitSHA), Code: http.StatusOK},
+		fmt.Sprintf("/testowner/testrepo/%s/%s", tagCommitSHA, depPath):                                    {Body: "return 'v1.10.1'", Code: http.StatusOK},
+	}
+	mockServer := startMockHTTPServer(t, pathResps)
+
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	err := runInstallCommand(t, tempDir)
+	require.NoError(t, err, "almd install command failed")
+
+	lockCfg := readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName))
+	assert.Equal(t, "commit:"+tagCommitSHA, lockCfg.Package["globbed"].Hash)
+
+	content, readErr := os.ReadFile(filepath.Join(tempDir, depPath))
+	require.NoError(t, readErr)
+	assert.Equal(t, "return 'v1.10.1'", string(content))
+}
+
+// TestInstallCommand_PrunesStaleLockEntries verifies that a full install removes lockfile entries for
+// dependencies that are no longer in project.toml, that --no-prune keeps them, and that a targeted
+// install never prunes.
+func TestInstallCommand_PrunesStaleLockEntries(t *testing.T) {
+	depPath := "libs/kept.lua"
+	depSHA := "abcabcabcabcabcabcabcabcabcabcabcabcabca"
+
+	initialProjectToml := fmt.Sprintf(`
+[package]
+name = "test-prune"
+version = "0.1.0"
+
+[dependencies.kept]
+source = "github:testowner/testrepo/%s@%s"
+path = "%s"
+`, depPath, depSHA, depPath)
+
+	initialLockfile := fmt.Sprintf(`
+api_version = "1"
+
+[package.kept]
+source = "https://raw.githubusercontent.com/testowner/testrepo/%s/%s"
+path = "%s"
+hash = "commit:%s"
+
+[package.removed]
+source = "https://raw.githubusercontent.com/testowner/testrepo/main/libs/removed.lua"
+path = "libs/removed.lua"
+hash = "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+`, depSHA, depPath, depPath, depSHA)
+
+	tests := []struct {
+		name        string
+		args        []string
+		expectStale bool
+	}{
+		{name: "full install prunes", args: nil, expectStale: false},
+		{name: "no-prune keeps stale entries", args: []string{"--no-prune"}, expectStale: true},
+		{name: "targeted install does not prune", args: []string{"kept"}, expectStale: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupInstallTestEnvironment(t, initialProjectToml, initialLockfile, map[string]string{depPath: "return 'kept'"})
+
+			err := runInstallCommand(t, tempDir, tt.args...)
+			require.NoError(t, err, "almd install command failed")
+
+			lockCfg := readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName))
+			assert.Contains(t, lockCfg.Package, "kept")
+			if tt.expectStale {
+				assert.Contains(t, lockCfg.Package, "removed")
+			} else {
+				assert.NotContains(t, lockCfg.Package, "removed")
+			}
+		})
+	}
+}
+
+// TestInstallCommand_FrozenContentChanged checks that a frozen install fails when the content of
+// a source locked by its content hash changed upstream, instead of rewriting the hash.
+func TestInstallCommand_FrozenContentChanged(t *testing.T) {
+	projectToml := `
+[package]
+name = "frozen-content"
+version = "0.1.0"
+
+[dependencies]
+lib = { source = "file:upstream/lib.lua", path = "libs/lib.lua" }
+`
+	tempDir := setupInstallTestEnvironment(t, projectToml, "", map[string]string{"upstream/lib.lua": "return 1\n"})
+	require.NoError(t, runInstallCommand(t, tempDir))
+	locked := readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName)).Package["lib"].Hash
+
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "upstream", "lib.lua"), []byte("return 2\n"), 0644))
+	err := runInstallCommand(t, tempDir, "--frozen")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--frozen is set")
+	assert.Contains(t, err.Error(), "lib: Upstream content changed")
+	assert.Equal(t, locked, readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName)).Package["lib"].Hash)
+	content, readErr := os.ReadFile(filepath.Join(tempDir, "libs", "lib.lua"))
+	require.NoError(t, readErr)
+	assert.Equal(t, "return 1\n", string(content))
+}
+
+// TestInstallCommand_FrozenInstallModeChanged checks that a frozen install fails when a
+// dependency's install mode changed, since the lock entry records it.
+func TestInstallCommand_FrozenInstallModeChanged(t *testing.T) {
+	projectToml := func(install string) string {
+		return fmt.Sprintf(`
+[package]
+name = "frozen-mode"
+version = "0.1.0"
+
+[dependencies]
+lib = { source = "file:upstream/lib.lua", path = "libs/lib.lua"%s }
+`, install)
+	}
+	tempDir := setupInstallTestEnvironment(t, projectToml(""), "", map[string]string{"upstream/lib.lua": "return 1\n"})
+	require.NoError(t, runInstallCommand(t, tempDir))
+
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, config.ProjectTomlName), []byte(projectToml(`, install = "link"`)), 0644))
+	err := runInstallCommand(t, tempDir, "--frozen")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--frozen is set")
+	assert.Contains(t, err.Error(), "lib: Install mode changed from 'copy' to 'link'")
+	assert.Empty(t, readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName)).Package["lib"].Install)
+}
+
+// TestInstallCommand_Profiles verifies that --profile applies project-defined and built-in settings,
+// that explicit flags override the profile, and that frozen installs refuse to change the lockfile.
+func TestInstallCommand_Profiles(t *testing.T) {
+	depPath := "libs/newdep.lua"
+	depSHA := "1234512345123451234512345123451234512345"
+
+	initialProjectToml := fmt.Sprintf(`
+[package]
+name = "test-profiles"
+version = "0.1.0"
+
+[profiles.strict]
+frozen = true
+
+[dependencies.newdep]
+source = "github:testowner/testrepo/%s@%s"
+path = "%s"
+`, depPath, depSHA, depPath)
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "project profile freezes lockfile", args: []string{"--profile", "strict"}, wantErr: "--frozen is set"},
+		{name: "built-in ci profile freezes lockfile", args: []string{"--profile", "ci"}, wantErr: "not in almd-lock.toml"},
+		{name: "explicit flag overrides profile", args: []string{"--profile", "strict", "--frozen=false"}},
+		{name: "dev profile installs normally", args: []string{"--profile", "dev"}},
+		{name: "unknown profile", args: []string{"--profile", "nope"}, wantErr: "unknown profile 'nope' (available: ci, dev, release, strict)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := setupInstallTestEnvironment(t, initialProjectToml, "", nil)
+
+			mockServer := startMockHTTPServer(t, map[string]struct {
+				Body string
+				Code int
+			}{
+				fmt.Sprintf("/testowner/testrepo/%s/%s", depSHA, depPath): {Body: "return 'new'", Code: http.StatusOK},
+			})
+			originalGHAPIBaseURL := source.GithubAPIBaseURL
+			source.GithubAPIBaseURL = mockServer.URL
+			defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+			err := runInstallCommand(t, tempDir, tt.args...)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				_, statErr := os.Stat(filepath.Join(tempDir, depPath))
+				assert.True(t, os.IsNotExist(statErr), "nothing should be downloaded when the install is rejected")
+				return
+			}
+			require.NoError(t, err)
+			lockCfg := readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName))
+			assert.Equal(t, "commit:"+depSHA, lockCfg.Package["newdep"].Hash)
+		})
+	}
+}
+
+// TestInstallCommand_RejectsUnportablePath verifies that install refuses to write a dependency to a
+// path that cannot exist on Windows, instead of failing with an OS-specific error.
+func TestInstallCommand_RejectsUnportablePath(t *testing.T) {
+	depPath := "libs/aux.lua"
+	depSHA := "9999999999888888888877777777776666666666"
+
+	initialProjectToml := fmt.Sprintf(`
+[package]
+name = "test-unportable"
+version = "0.1.0"
+
+[dependencies.aux]
+source = "github:testowner/testrepo/lib.lua@%s"
+path = "%s"
+`, depSHA, depPath)
+
+	tempDir := setupInstallTestEnvironment(t, initialProjectToml, "", nil)
+	mockServer := startMockHTTPServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		fmt.Sprintf("/testowner/testrepo/%s/lib.lua", depSHA): {Body: "return {}", Code: http.StatusOK},
+	})
+
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	_ = runInstallCommand(t, tempDir)
+
+	assert.NoFileExists(t, filepath.Join(tempDir, depPath))
+	if _, statErr := os.Stat(filepath.Join(tempDir, lockfile.LockfileName)); statErr == nil {
+		lockCfg := readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName))
+		assert.NotContains(t, lockCfg.Package, "aux")
+	}
+}
+
+// TestInstallCommand_FileModes verifies that a per-dependency mode is applied and that an existing
+// executable file keeps its mode when it is reinstalled.
+func TestInstallCommand_FileModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no Unix permission bits")
+	}
+	toolSHA := "1111111111222222222233333333334444444444"
+	scriptSHA := "5555555555666666666677777777778888888888"
+
+	initialProjectToml := fmt.Sprintf(`
+[package]
+name = "test-modes"
+version = "0.1.0"
+
+[dependencies.tool]
+source = "github:testowner/testrepo/tool.lua@%s"
+path = "bin/tool.lua"
+mode = "0755"
+
+[dependencies.script]
+source = "github:testowner/testrepo/script.lua@%s"
+path = "bin/script.lua"
+`, toolSHA, scriptSHA)
+
+	tempDir := setupInstallTestEnvironment(t, initialProjectToml, "", map[string]string{"bin/script.lua": "old"})
+	require.NoError(t, os.Chmod(filepath.Join(tempDir, "bin", "script.lua"), 0750))
+
+	mockServer := startMockHTTPServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		fmt.Sprintf("/testowner/testrepo/%s/tool.lua", toolSHA):     {Body: "#!/usr/bin/env lua", Code: http.StatusOK},
+		fmt.Sprintf("/testowner/testrepo/%s/script.lua", scriptSHA): {Body: "#!/usr/bin/env lua", Code: http.StatusOK},
+	})
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	require.NoError(t, runInstallCommand(t, tempDir, "--force"))
+
+	info, err := os.Stat(filepath.Join(tempDir, "bin", "tool.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	info, err = os.Stat(filepath.Join(tempDir, "bin", "script.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0750), info.Mode().Perm(), "the executable bit of a reinstalled file is preserved")
+	content, err := os.ReadFile(filepath.Join(tempDir, "bin", "script.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, "#!/usr/bin/env lua", string(content))
+}
+
+func TestInstallCommand_PlanWithApply(t *testing.T) {
+	commitSHA := "abcdef1234567890abcdef1234567890abcdef12"
+	initialProjectToml := fmt.Sprintf(`
+[package]
+name = "test-plan"
+version = "0.1.0"
+
+[dependencies.lib]
+source = "github:testowner/testrepo/lib.lua@%s"
+path = "libs/lib.lua"
+`, commitSHA)
+	initialLockfile := `
+api_version = "1"
+
+[package.gone]
+source = "https://example.com/gone.lua"
+path = "libs/gone.lua"
+hash = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+`
+	tempDir := setupInstallTestEnvironment(t, initialProjectToml, initialLockfile, nil)
+
+	mockServer := startMockHTTPServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		fmt.Sprintf("/testowner/testrepo/%s/lib.lua", commitSHA): {Body: "return {}", Code: http.StatusOK},
+	})
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	require.NoError(t, runInstallCommand(t, tempDir, "--plan", "--apply"))
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "libs", "lib.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, "return {}", string(content))
+	lf := readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName))
+	assert.Contains(t, lf.Package, "lib")
+	assert.NotContains(t, lf.Package, "gone", "the stale entry listed in the plan is pruned once applied")
+}
+
+func TestInstallCommand_DryRunJSON(t *testing.T) {
+	commitSHA := "abcdef1234567890abcdef1234567890abcdef12"
+	initialProjectToml := fmt.Sprintf(`
+[package]
+name = "test-dry-run"
+version = "0.1.0"
+
+[dependencies.lib]
+source = "github:testowner/testrepo/lib.lua@%s"
+path = "libs/lib.lua"
+`, commitSHA)
+	initialLockfile := `
+api_version = "1"
+
+[package.gone]
+source = "https://example.com/gone.lua"
+path = "libs/gone.lua"
+hash = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+`
+	tempDir := setupInstallTestEnvironment(t, initialProjectToml, initialLockfile, map[string]string{"libs/lib.lua": "return 1"})
+	lockBefore, err := os.ReadFile(filepath.Join(tempDir, lockfile.LockfileName))
+	require.NoError(t, err)
+
+	mockServer := startMockHTTPServer(t, map[string]struct {
+		Body string
+		Code int
+	}{})
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	err = runInstallCommand(t, tempDir, "--json")
+	require.Error(t, err)
+	assert.Equal(t, exitcode.Usage, err.(cli.ExitCoder).ExitCode())
+
+	originalStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	err = runInstallCommand(t, tempDir, "--dry-run", "--json", "--verbose") // Verbose progress goes to stderr
+	os.Stdout = originalStdout
+	_ = w.Close()
+	stdout, _ := io.ReadAll(r)
+	require.NoError(t, err)
+
+	var plan struct {
+		Version       int   `json:"version"`
+		DownloadBytes int64 `json:"download_bytes"`
+		Dependencies  []struct {
+			Dependency string `json:"dependency"`
+			Action     string `json:"action"`
+			Path       string `json:"path"`
+			Reason     string `json:"reason"`
+			Current    struct {
+				FilePresent bool `json:"file_present"`
+			} `json:"current"`
+			Target struct {
+				Commit string `json:"commit"`
+				URL    string `json:"url"`
+			} `json:"target"`
+			DownloadBytes *int64 `json:"download_bytes"`
+		} `json:"dependencies"`
+	}
+	require.NoError(t, json.Unmarshal(stdout, &plan), "stdout must be only the JSON plan:\n%s", stdout)
+	assert.Equal(t, 1, plan.Version)
+	require.Len(t, plan.Dependencies, 2)
+
+	lib := plan.Dependencies[0]
+	assert.Equal(t, "lib", lib.Dependency)
+	assert.Equal(t, "install", lib.Action)
+	assert.Equal(t, "libs/lib.lua", lib.Path)
+	assert.NotEmpty(t, lib.Reason)
+	assert.True(t, lib.Current.FilePresent)
+	assert.Equal(t, commitSHA, lib.Target.Commit)
+	assert.Equal(t, mockServer.URL+"/testowner/testrepo/"+commitSHA+"/lib.lua", lib.Target.URL)
+	require.NotNil(t, lib.DownloadBytes)
+	assert.Equal(t, int64(len("return 1")), *lib.DownloadBytes, "estimated from the file being replaced")
+	assert.Equal(t, int64(len("return 1")), plan.DownloadBytes)
+
+	assert.Equal(t, "gone", plan.Dependencies[1].Dependency)
+	assert.Equal(t, "prune", plan.Dependencies[1].Action)
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "libs", "lib.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, "return 1", string(content), "a dry run changes no files")
+	lockAfter, err := os.ReadFile(filepath.Join(tempDir, lockfile.LockfileName))
+	require.NoError(t, err)
+	assert.Equal(t, string(lockBefore), string(lockAfter), "a dry run leaves the lockfile alone")
+}
+
+func TestInstallCommand_DryRunJSONKeepsStdoutPure(t *testing.T) {
+	tempDir := setupInstallTestEnvironment(t, "[package]\nname = \"empty\"\nversion = \"0.1.0\"\n", "api_version = \"1\"\n", nil)
+
+	originalStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	err := runInstallCommand(t, tempDir, "--dry-run", "--json", "--profile", "ci")
+	os.Stdout = originalStdout
+	_ = w.Close()
+	stdout, _ := io.ReadAll(r)
+	require.NoError(t, err)
+
+	var plan struct {
+		Version      int               `json:"version"`
+		Dependencies []json.RawMessage `json:"dependencies"`
+	}
+	require.NoError(t, json.Unmarshal(stdout, &plan), "stdout must be only the JSON plan:\n%s", stdout)
+	assert.Equal(t, 1, plan.Version)
+	assert.Empty(t, plan.Dependencies)
+}
+
+func TestInstallCommand_VendorHeader(t *testing.T) {
+	commitSHA := "abcdef1234567890abcdef1234567890abcdef12"
+	initialProjectToml := fmt.Sprintf(`
+[package]
+name = "test-vendor-header"
+version = "0.1.0"
+
+[vendor]
+header = true
+
+[dependencies.lib]
+source = "github:testowner/testrepo/lib.lua@%s"
+path = "libs/lib.lua"
+`, commitSHA)
+	tempDir := setupInstallTestEnvironment(t, initialProjectToml, "", nil)
+
+	mockServer := startMockHTTPServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		fmt.Sprintf("/testowner/testrepo/%s/lib.lua", commitSHA): {Body: "return {}\n", Code: http.StatusOK},
+		"/repos/testowner/testrepo":                              {Body: `{"license":{"spdx_id":"MIT"}}`, Code: http.StatusOK},
+	})
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	require.NoError(t, runInstallCommand(t, tempDir))
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "libs", "lib.lua"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(content), "-- almd:vendor-header begin\n"))
+	assert.Contains(t, string(content), "-- source: github:testowner/testrepo/lib.lua@"+commitSHA+"\n")
+	assert.Contains(t, string(content), "-- commit: "+commitSHA+"\n")
+	assert.Contains(t, string(content), "-- license: MIT\n")
+	assert.True(t, strings.HasSuffix(string(content), "-- almd:vendor-header end\nreturn {}\n"))
+
+	lf := readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName))
+	assert.Equal(t, "commit:"+commitSHA, lf.Package["lib"].Hash, "the header does not change the locked hash")
+
+	// The attestation records the digest of the upstream content and of the file with its header.
+	statements, err := filepath.Glob(filepath.Join(tempDir, filepath.FromSlash(attestation.Dir), "*.intoto.json"))
+	require.NoError(t, err)
+	require.Len(t, statements, 1)
+	data, err := os.ReadFile(statements[0])
+	require.NoError(t, err)
+	var statement attestation.Statement
+	require.NoError(t, json.Unmarshal(data, &statement))
+	upstreamSum := sha256.Sum256([]byte("return {}\n"))
+	fileSum := sha256.Sum256(content)
+	require.Len(t, statement.Subject, 1)
+	assert.Equal(t, hex.EncodeToString(fileSum[:]), statement.Subject[0].Digest["sha256"])
+	resolved := statement.Predicate.BuildDefinition.ResolvedDependencies
+	require.Len(t, resolved, 1)
+	assert.Equal(t, hex.EncodeToString(upstreamSum[:]), resolved[0].Digest["sha256"])
+	assert.Equal(t, commitSHA, resolved[0].Digest["gitCommit"])
+}
+
+func TestInstallCommand_TransformChange(t *testing.T) {
+	commitSHA := "abcdef1234567890abcdef1234567890abcdef12"
+	projectToml := func(transformLine string) string {
+		return fmt.Sprintf(`
+[package]
+name = "test-transform"
+version = "0.1.0"
+
+[dependencies.lib]
+source = "github:testowner/testrepo/lib.lua@%s"
+path = "libs/lib.lua"
+%s
+`, commitSHA, transformLine)
+	}
+	tempDir := setupInstallTestEnvironment(t, projectToml(""), "", nil)
+
+	mockServer := startMockHTTPServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		fmt.Sprintf("/testowner/testrepo/%s/lib.lua", commitSHA): {Body: "-- doc\nreturn {}\n", Code: http.StatusOK},
+	})
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	require.NoError(t, runInstallCommand(t, tempDir))
+	content, err := os.ReadFile(filepath.Join(tempDir, "libs", "lib.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, "-- doc\nreturn {}\n", string(content))
+
+	// Opting into a transform reinstalls the dependency even though its commit is unchanged.
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, config.ProjectTomlName), []byte(projectToml(`transform = "strip-comments"`)), 0644))
+	require.NoError(t, runInstallCommand(t, tempDir))
+
+	content, err = os.ReadFile(filepath.Join(tempDir, "libs", "lib.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, "return {}\n", string(content))
+	lf, err := lockfile.Load(tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, "commit:"+commitSHA, lf.Package["lib"].Hash)
+	assert.Equal(t, "strip-comments", lf.Package["lib"].Transform)
+	assert.Equal(t, fmt.Sprintf("sha256:%x", sha256.Sum256(content)), lf.Package["lib"].TransformedHash)
+}
+
+// TestInstallCommand_Directory checks that a directory dependency installs its listed files at
+// its commit, and that a file dropped from the list is removed on the next install.
+func TestInstallCommand_Directory(t *testing.T) {
+	commitSHA := "abcdef1234567890abcdef1234567890abcdef12"
+	projectToml := func(files string) string {
+		return fmt.Sprintf(`
+[package]
+name = "test-directory"
+version = "0.1.0"
+
+[dependencies.lib]
+source = "github:testowner/testrepo/lib/@%s"
+path = "libs/lib"
+files = [%s]
+`, commitSHA, files)
+	}
+	tempDir := setupInstallTestEnvironment(t, projectToml(`"init.lua", "util/str.lua"`), "", nil)
+
+	mockServer := startMockHTTPServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		fmt.Sprintf("/testowner/testrepo/%s/lib/init.lua", commitSHA):     {Body: "return {}\n", Code: http.StatusOK},
+		fmt.Sprintf("/testowner/testrepo/%s/lib/util/str.lua", commitSHA): {Body: "return 'str'\n", Code: http.StatusOK},
+	})
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	require.NoError(t, runInstallCommand(t, tempDir))
+	content, err := os.ReadFile(filepath.Join(tempDir, "libs", "lib", "util", "str.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, "return 'str'\n", string(content))
+	lf, err := lockfile.Load(tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, "commit:"+commitSHA, lf.Package["lib"].Hash)
+	assert.Equal(t, fmt.Sprintf("sha256:%x", sha256.Sum256(content)), lf.Package["lib"].Files["util/str.lua"])
+
+	// The attestation has a subject for each file of the directory.
+	statements, err := filepath.Glob(filepath.Join(tempDir, filepath.FromSlash(attestation.Dir), "*.intoto.json"))
+	require.NoError(t, err)
+	require.Len(t, statements, 1)
+	data, err := os.ReadFile(statements[0])
+	require.NoError(t, err)
+	var statement attestation.Statement
+	require.NoError(t, json.Unmarshal(data, &statement))
+	require.Len(t, statement.Subject, 2)
+	assert.Equal(t, "libs/lib/init.lua", statement.Subject[0].Name)
+	assert.Equal(t, "libs/lib/util/str.lua", statement.Subject[1].Name)
+
+	// A missing file reinstalls the directory.
+	require.NoError(t, os.Remove(filepath.Join(tempDir, "libs", "lib", "init.lua")))
+	require.NoError(t, runInstallCommand(t, tempDir))
+	assert.FileExists(t, filepath.Join(tempDir, "libs", "lib", "init.lua"))
+
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, config.ProjectTomlName), []byte(projectToml(`"init.lua"`)), 0644))
+	require.NoError(t, runInstallCommand(t, tempDir))
+	assert.NoFileExists(t, filepath.Join(tempDir, "libs", "lib", "util", "str.lua"), "files no longer listed are removed")
+	lf, err = lockfile.Load(tempDir)
+	require.NoError(t, err)
+	assert.Len(t, lf.Package["lib"].Files, 1)
+}
+
+// TestInstallCommand_Archive checks that the files of an archive dependency are extracted from
+// the tarball as its extract specs select, and locked with the archive's hash.
+func TestInstallCommand_Archive(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, file := range []struct{ name, body string }{
+		{"pkg-1.0/src/pkg.lua", "return {}\n"},
+		{"pkg-1.0/src/util.lua", "return 'util'\n"},
+		{"pkg-1.0/lib/pkg.lua", "return 'lib'\n"},
+		{"pkg-1.0/lib/util.lua", "return 'lib util'\n"},
+		{"pkg-1.0/README.md", "# pkg\n"},
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.body)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(file.body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	projectToml := func(files, extract string) string {
+		return fmt.Sprintf(`
+[package]
+name = "test-archive"
+version = "0.1.0"
+
+[dependencies.pkg]
+source = "file:downloads/pkg-1.0.tar.gz"
+path = "libs/pkg"
+files = [%s]
+extract = ["%s"]
+`, files, extract)
+	}
+	tempDir := setupInstallTestEnvironment(t, projectToml(`"pkg.lua", "util.lua"`, "pkg-1.0/src/"), "", map[string]string{
+		"downloads/pkg-1.0.tar.gz": buf.String(),
+	})
+
+	require.NoError(t, runInstallCommand(t, tempDir))
+	content, err := os.ReadFile(filepath.Join(tempDir, "libs", "pkg", "util.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, "return 'util'\n", string(content))
+	assert.NoFileExists(t, filepath.Join(tempDir, "libs", "pkg", "README.md"))
+	lf, err := lockfile.Load(tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("sha256:%x", sha256.Sum256(buf.Bytes())), lf.Package["pkg"].Hash)
+	assert.Equal(t, fmt.Sprintf("sha256:%x", sha256.Sum256(content)), lf.Package["pkg"].Files["util.lua"])
+	assert.Equal(t, []string{"pkg-1.0/src/"}, lf.Package["pkg"].Extract)
+
+	// Other extract specs for the same files fail a frozen install and otherwise reinstall them.
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, config.ProjectTomlName), []byte(projectToml(`"pkg.lua", "util.lua"`, "pkg-1.0/lib/")), 0644))
+	err = runInstallCommand(t, tempDir, "--frozen")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "pkg: Extract specs changed")
+	require.NoError(t, runInstallCommand(t, tempDir))
+	content, err = os.ReadFile(filepath.Join(tempDir, "libs", "pkg", "pkg.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, "return 'lib'\n", string(content))
+
+	// A missing file reinstalls the archive's files.
+	require.NoError(t, os.Remove(filepath.Join(tempDir, "libs", "pkg", "pkg.lua")))
+	require.NoError(t, runInstallCommand(t, tempDir))
+	assert.FileExists(t, filepath.Join(tempDir, "libs", "pkg", "pkg.lua"))
+
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, config.ProjectTomlName), []byte(projectToml(`"pkg.lua", "missing.lua"`, "pkg-1.0/lib/")), 0644))
+	err = runInstallCommand(t, tempDir)
+	require.Error(t, err)
+	assert.Equal(t, exitcode.Usage, err.(cli.ExitCoder).ExitCode(), "a listed file the archive does not hold fails the install")
+	assert.FileExists(t, filepath.Join(tempDir, "libs", "pkg", "util.lua"), "nothing is written or removed")
+}
+
+// TestInstallCommand_MalformedLockEntry checks that a lock entry with a malformed hash is treated
+// as unlocked: the dependency is installed again and its entry rebuilt.
+func TestInstallCommand_MalformedLockEntry(t *testing.T) {
+	commitSHA := strings.Repeat("7", 40)
+	projectToml := fmt.Sprintf(`
+[package]
+name = "malformed"
+version = "0.1.0"
+
+[dependencies]
+lib = { source = "github:owner/repo/lib.lua@%s", path = "libs/lib.lua" }
+`, commitSHA)
+	lockToml := fmt.Sprintf(`
+api_version = "1"
+
+[package.lib]
+source = "https://raw.githubusercontent.com/owner/repo/%s/lib.lua"
+path = "libs/lib.lua"
+hash = "%s"
+`, commitSHA, commitSHA)
+	mockServer := startMockHTTPServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		fmt.Sprintf("/owner/repo/%s/lib.lua", commitSHA): {Body: "return 1\n", Code: http.StatusOK},
+	})
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	tempDir := setupInstallTestEnvironment(t, projectToml, lockToml, map[string]string{"libs/lib.lua": "garbage"})
+	require.NoError(t, runInstallCommand(t, tempDir))
+	content, err := os.ReadFile(filepath.Join(tempDir, "libs", "lib.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, "return 1\n", string(content))
+	lf, err := lockfile.Load(tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, "commit:"+commitSHA, lf.Package["lib"].Hash)
+	assert.Empty(t, lf.Malformed)
+}
+
+// TestInstallCommand_RefusesToOverwriteProjectFiles checks that a dependency whose path points at
+// a file almd did not install is refused unless --allow-overwrite is given.
+func TestInstallCommand_RefusesToOverwriteProjectFiles(t *testing.T) {
+	commitSHA := strings.Repeat("5", 40)
+	projectToml := fmt.Sprintf(`
+[package]
+name = "overwrite"
+version = "0.1.0"
+
+[dependencies]
+lib = { source = "github:owner/repo/lib.lua@%s", path = "src/main.lua" }
+`, commitSHA)
+	mockServer := startMockHTTPServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		fmt.Sprintf("/owner/repo/%s/lib.lua", commitSHA): {Body: "return 1\n", Code: http.StatusOK},
+	})
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	tempDir := setupInstallTestEnvironment(t, projectToml, "", map[string]string{
+		"src/main.lua":           "print('my game')\n",
+		".almd/state/files.json": `{"files": []}`,
+	})
+	err := runInstallCommand(t, tempDir)
+	require.Error(t, err)
+	assert.Equal(t, exitcode.Usage, err.(cli.ExitCoder).ExitCode())
+	content, readErr := os.ReadFile(filepath.Join(tempDir, "src", "main.lua"))
+	require.NoError(t, readErr)
+	assert.Equal(t, "print('my game')\n", string(content), "the project's file is left alone")
+
+	require.NoError(t, runInstallCommand(t, tempDir, "--allow-overwrite"))
+	content, readErr = os.ReadFile(filepath.Join(tempDir, "src", "main.lua"))
+	require.NoError(t, readErr)
+	assert.Equal(t, "return 1\n", string(content))
+}
+
+// TestInstallCommand_Stats checks that --stats prints the run's network usage and phase
+// timing on stderr.
+func TestInstallCommand_Stats(t *testing.T) {
+	commitSHA := strings.Repeat("4", 40)
+	projectToml := fmt.Sprintf(`
+[package]
+name = "stats"
+version = "0.1.0"
+
+[dependencies]
+lib = { source = "github:owner/repo/lib.lua@%s", path = "libs/lib.lua" }
+`, commitSHA)
+	mockServer := startMockHTTPServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		fmt.Sprintf("/owner/repo/%s/lib.lua", commitSHA): {Body: "return 1\n", Code: http.StatusOK},
+	})
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	tempDir := setupInstallTestEnvironment(t, projectToml, "", nil)
+	originalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	err := runInstallCommand(t, tempDir, "--stats")
+	os.Stderr = originalStderr
+	_ = w.Close()
+	stderr, _ := io.ReadAll(r)
+
+	require.NoError(t, err)
+	assert.Contains(t, string(stderr), "Run statistics:")
+	assert.Regexp(t, `Requests:\s+[1-9]`, string(stderr))
+	assert.Regexp(t, `Downloaded:\s+\d+ B`, string(stderr))
+	for _, phase := range []string{"Cache hit ratio:", "Resolve:", "Download:", "Write:", "Total:"} {
+		assert.Contains(t, string(stderr), phase)
+	}
+}
+
+// TestInstallCommand_InstallModes checks that dependencies are linked into the cache or
+// generated by a script as their install mode says, and reinstalled when the mode changes.
+func TestInstallCommand_InstallModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("links and the generating script need a Unix shell and symbolic links")
+	}
+	commitSHA := "abcdef1234567890abcdef1234567890abcdef12"
+	projectToml := func(libInstall string) string {
+		return fmt.Sprintf(`
+[package]
+name = "test-modes"
+version = "0.1.0"
+
+[scripts]
+upper = "tr a-z A-Z < \"$ALMD_INPUT\" > \"$ALMD_OUTPUT\""
+
+[dependencies.lib]
+source = "github:testowner/testrepo/lib.lua@%[1]s"
+path = "libs/lib.lua"
+%[2]s
+
+[dependencies.gen]
+source = "github:testowner/testrepo/gen.lua@%[1]s"
+path = "libs/gen.lua"
+install = "generate"
+generate = "upper"
+`, commitSHA, libInstall)
+	}
+	tempDir := setupInstallTestEnvironment(t, projectToml(`install = "link"`), "", nil)
+
+	mockServer := startMockHTTPServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		fmt.Sprintf("/testowner/testrepo/%s/lib.lua", commitSHA): {Body: "return 'lib'\n", Code: http.StatusOK},
+		fmt.Sprintf("/testowner/testrepo/%s/gen.lua", commitSHA): {Body: "return 'gen'\n", Code: http.StatusOK},
+	})
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	require.NoError(t, runInstallCommand(t, tempDir))
+	libPath := filepath.Join(tempDir, "libs", "lib.lua")
+	info, err := os.Lstat(libPath)
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&os.ModeSymlink, "a linked dependency is a symbolic link")
+	target, err := os.Readlink(libPath)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(target, os.Getenv(paths.CacheDirEnv)), "the link points into the cache, got %s", target)
+	content, err := os.ReadFile(libPath)
+	require.NoError(t, err)
+	assert.Equal(t, "return 'lib'\n", string(content))
+
+	generated, err := os.ReadFile(filepath.Join(tempDir, "libs", "gen.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, "RETURN 'GEN'\n", string(generated))
+
+	lf, err := lockfile.Load(tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, "link", lf.Package["lib"].Install)
+	assert.Equal(t, "generate", lf.Package["gen"].Install)
+	assert.Equal(t, "commit:"+commitSHA, lf.Package["gen"].Hash)
+	assert.Equal(t, fmt.Sprintf("sha256:%x", sha256.Sum256(generated)), lf.Package["gen"].TransformedHash)
+
+	// Switching back to copy replaces the link with a regular file and leaves the cache intact.
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, config.ProjectTomlName), []byte(projectToml("")), 0644))
+	require.NoError(t, runInstallCommand(t, tempDir))
+	info, err = os.Lstat(libPath)
+	require.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+	cached, err := os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "return 'lib'\n", string(cached))
+	lf, err = lockfile.Load(tempDir)
+	require.NoError(t, err)
+	assert.Empty(t, lf.Package["lib"].Install)
+}
+
+// TestInstallCommand_ExitCodes checks that a partially failed install exits with the partial
+// success code and that --strict turns a skipped dependency into a failure.
+func TestInstallCommand_ExitCodes(t *testing.T) {
+	goodSHA := strings.Repeat("a", 40)
+	badSHA := strings.Repeat("b", 40)
+	projectToml := fmt.Sprintf(`
+[package]
+name = "exit-codes"
+version = "0.1.0"
+
+[dependencies]
+good = { source = "github:owner/repo/good.lua@%s", path = "libs/good.lua" }
+bad = { source = "github:owner/repo/bad.lua@%s", path = "libs/bad.lua" }
+`, goodSHA, badSHA)
+
+	mockServer := startMockHTTPServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		"/owner/repo/" + goodSHA + "/good.lua": {Body: "return 'good'", Code: http.StatusOK},
+		"/owner/repo/" + badSHA + "/bad.lua":   {Body: "Simulated server error", Code: http.StatusInternalServerError},
+	})
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	tempDir := setupInstallTestEnvironment(t, projectToml, "", nil)
+	err := runInstallCommand(t, tempDir)
+	require.Error(t, err)
+	assert.Equal(t, exitcode.Partial, err.(cli.ExitCoder).ExitCode())
+	assert.Contains(t, err.Error(), "1 of 2 dependenc(ies) failed: bad")
+
+	err = runInstallCommand(t, tempDir, "bad")
+	require.Error(t, err)
+	assert.Equal(t, exitcode.Download, err.(cli.ExitCoder).ExitCode())
+
+	require.NoError(t, runInstallCommand(t, tempDir, "good", "missing"), "skipped dependencies are only warnings")
+	err = runInstallCommand(t, tempDir, "--strict", "good", "missing")
+	require.Error(t, err)
+	assert.Equal(t, exitcode.Partial, err.(cli.ExitCoder).ExitCode())
+	assert.Contains(t, err.Error(), "had warnings and --strict is set: missing")
+}
+
+func TestInstallCommand_FailFastRollsBack(t *testing.T) {
+	goodSHA := strings.Repeat("a", 40)
+	freshSHA := strings.Repeat("c", 40)
+	badSHA := strings.Repeat("b", 40)
+	projectToml := fmt.Sprintf(`
+[package]
+name = "fail-fast"
+version = "0.1.0"
+
+[dependencies]
+good = { source = "github:owner/repo/good.lua@%s", path = "libs/good.lua" }
+fresh = { source = "github:owner/repo/fresh.lua@%s", path = "vendor/new/fresh.lua" }
+bad = { source = "github:owner/repo/bad.lua@%s", path = "libs/bad.lua" }
+`, goodSHA, freshSHA, badSHA)
+	lockToml := `
+api_version = "1"
+
+[package.good]
+source = "https://raw.githubusercontent.com/owner/repo/0000000/good.lua"
+path = "libs/good.lua"
+hash = "commit:0000000"
+`
+
+	mockServer := startMockHTTPServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		"/owner/repo/" + goodSHA + "/good.lua":   {Body: "return 'new good'", Code: http.StatusOK},
+		"/owner/repo/" + freshSHA + "/fresh.lua": {Body: "return 'fresh'", Code: http.StatusOK},
+		"/owner/repo/" + badSHA + "/bad.lua":     {Body: "Simulated server error", Code: http.StatusInternalServerError},
+	})
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	tempDir := setupInstallTestEnvironment(t, projectToml, lockToml, map[string]string{"libs/good.lua": "return 'old good'"})
+
+	err := runInstallCommand(t, tempDir, "--keep-going", "--fail-fast")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--keep-going and --fail-fast cannot be used together")
+
+	err = runInstallCommand(t, tempDir, "--fail-fast", "good", "fresh", "bad")
+	require.Error(t, err)
+	assert.Equal(t, exitcode.Download, err.(cli.ExitCoder).ExitCode(), "a rolled back run is not a partial success")
+	assert.Contains(t, err.Error(), "no changes were kept: bad")
+
+	content, readErr := os.ReadFile(filepath.Join(tempDir, "libs", "good.lua"))
+	require.NoError(t, readErr)
+	assert.Equal(t, "return 'old good'", string(content), "overwritten files are restored")
+	assert.NoDirExists(t, filepath.Join(tempDir, "vendor"), "new files and the directories created for them are removed")
+	lockContent, readErr := os.ReadFile(filepath.Join(tempDir, lockfile.LockfileName))
+	require.NoError(t, readErr)
+	assert.Equal(t, lockToml, string(lockContent), "almd-lock.toml is not written")
+
+	err = runInstallCommand(t, tempDir, "--keep-going", "good", "fresh", "bad")
+	require.Error(t, err)
+	assert.Equal(t, exitcode.Partial, err.(cli.ExitCoder).ExitCode())
+	assert.Contains(t, err.Error(), "1 of 3 dependenc(ies) failed: bad")
+	assert.FileExists(t, filepath.Join(tempDir, "vendor", "new", "fresh.lua"))
+}
+
+func TestInstallCommand_DowngradeProtection(t *testing.T) {
+	olderSHA := strings.Repeat("1", 40)
+	lockedSHA := strings.Repeat("2", 40)
+	projectToml := fmt.Sprintf(`
+[package]
+name = "downgrade"
+version = "0.1.0"
+
+[dependencies]
+lib = { source = "github:owner/repo/lib.lua@%s", path = "libs/lib.lua" }
+`, olderSHA)
+	lockToml := fmt.Sprintf(`
+api_version = "1"
+
+[package.lib]
+source = "https://raw.githubusercontent.com/owner/repo/%s/lib.lua"
+path = "libs/lib.lua"
+hash = "commit:%s"
+`, lockedSHA, lockedSHA)
+
+	mockServer := startMockHTTPServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		"/repos/owner/repo/commits/" + olderSHA:  {Body: `{"sha":"` + olderSHA + `","commit":{"committer":{"date":"2024-01-01T00:00:00Z"}}}`, Code: http.StatusOK},
+		"/repos/owner/repo/commits/" + lockedSHA: {Body: `{"sha":"` + lockedSHA + `","commit":{"committer":{"date":"2024-06-01T00:00:00Z"}}}`, Code: http.StatusOK},
+		"/owner/repo/" + olderSHA + "/lib.lua":   {Body: "return 'older'", Code: http.StatusOK},
+	})
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+	t.Setenv(paths.StateDirEnv, t.TempDir())
+
+	tempDir := setupInstallTestEnvironment(t, projectToml, lockToml, map[string]string{"libs/lib.lua": "return 'locked'"})
+
+	// The plan already shows the refusal, and a dry run records nothing in the journal.
+	originalStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	err := runInstallCommand(t, tempDir, "--dry-run", "--json")
+	os.Stdout = originalStdout
+	_ = w.Close()
+	stdout, _ := io.ReadAll(r)
+	require.NoError(t, err)
+	var plan struct {
+		Dependencies []struct {
+			Dependency string `json:"dependency"`
+			Action     string `json:"action"`
+			Reason     string `json:"reason"`
+		} `json:"dependencies"`
+	}
+	require.NoError(t, json.Unmarshal(stdout, &plan), "stdout must be only the JSON plan:\n%s", stdout)
+	require.Len(t, plan.Dependencies, 1)
+	assert.Equal(t, "refused", plan.Dependencies[0].Action)
+	assert.Contains(t, plan.Dependencies[0].Reason, "downgrade to an older commit")
+
+	err = runInstallCommand(t, tempDir)
+	require.Error(t, err)
+	assert.Equal(t, exitcode.Integrity, err.(cli.ExitCoder).ExitCode())
+	content, readErr := os.ReadFile(filepath.Join(tempDir, "libs", "lib.lua"))
+	require.NoError(t, readErr)
+	assert.Equal(t, "return 'locked'", string(content), "a downgrade is not installed without --allow-downgrade")
+
+	require.NoError(t, runInstallCommand(t, tempDir, "--allow-downgrade"))
+	content, readErr = os.ReadFile(filepath.Join(tempDir, "libs", "lib.lua"))
+	require.NoError(t, readErr)
+	assert.Equal(t, "return 'older'", string(content))
+
+	entries, err := journal.Read()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, journal.DowngradeBlocked, entries[0].Event)
+	assert.Equal(t, journal.DowngradeAllowed, entries[1].Event)
+	assert.Equal(t, "lib", entries[1].Dependency)
+	assert.Equal(t, lockedSHA, entries[1].From)
+	assert.Equal(t, olderSHA, entries[1].To)
+	assert.Equal(t, "222222222222 (2024-06-01) -> 111111111111 (2024-01-01)", entries[1].Detail)
+}
+
+func TestInstallCommand_LockedCommitGone(t *testing.T) {
+	lockedSHA := strings.Repeat("3", 40)
+	projectToml := fmt.Sprintf(`
+[package]
+name = "gone"
+version = "0.1.0"
+
+[dependencies]
+lib = { source = "github:owner/repo/lib.lua@%s", path = "libs/lib.lua" }
+`, lockedSHA)
+	lockToml := fmt.Sprintf(`
+api_version = "1"
+
+[package.lib]
+source = "https://raw.githubusercontent.com/owner/repo/%s/lib.lua"
+path = "libs/lib.lua"
+hash = "commit:%s"
+`, lockedSHA, lockedSHA)
+
+	mockServer := startMockHTTPServer(t, map[string]struct {
+		Body string
+		Code int
+	}{})
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	tempDir := setupInstallTestEnvironment(t, projectToml, lockToml, nil)
+	originalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	err := runInstallCommand(t, tempDir)
+	os.Stderr = originalStderr
+	_ = w.Close()
+	stderr, _ := io.ReadAll(r)
+
+	require.Error(t, err)
+	assert.Equal(t, exitcode.Resolution, err.(cli.ExitCoder).ExitCode())
+	assert.Contains(t, string(stderr), "upstream history rewritten or deleted")
+	assert.Contains(t, string(stderr), "locked commit 333333333333 of 'lib' no longer exists upstream")
+}
+
+func TestInstallCommand_RateLimitedReportsOnce(t *testing.T) {
+	source.ResetRateLimit()
+	defer source.ResetRateLimit()
+
+	var deps strings.Builder
+	for _, name := range []string{"a", "b", "c", "d"} {
+		fmt.Fprintf(&deps, "%s = { source = \"github:owner/repo/%s.lua@main\", path = \"libs/%s.lua\" }\n", name, name, name)
+	}
+	projectToml := "[package]\nname = \"limited\"\nversion = \"0.1.0\"\n\n[dependencies]\n" + deps.String()
+
+	reset := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", reset)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = server.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	tempDir := setupInstallTestEnvironment(t, projectToml, "", nil)
+	originalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	_ = runInstallCommand(t, tempDir)
+	os.Stderr = originalStderr
+	_ = w.Close()
+	stderr, _ := io.ReadAll(r)
+
+	assert.Equal(t, 1, strings.Count(string(stderr), "GitHub API rate limit exceeded"), "expected one consolidated warning, got:\n%s", stderr)
+	assert.Contains(t, string(stderr), "could not resolve the refs of a, b, c, d")
+}
+
+// TestInstallCommand_MissingTag verifies that a tag deleted upstream is reported with the nearest
+// remaining tags, and that --tag-fallback installs a remaining tag of the same version.
+func TestInstallCommand_MissingTag(t *testing.T) {
+	depPath := "libs/moved.lua"
+	fallbackCommitSHA := "1212121212343434343456565656567878787878"
+	initialProjectToml := fmt.Sprintf(`
+[package]
+name = "test-missing-tag"
+version = "0.1.0"
+
+[dependencies.moved]
+source = "github:testowner/testrepo/%s@v1.2.0"
+path = "%s"
+`, depPath, depPath)
+
+	pathResps := map[string]struct {
+		Body string
+		Code int
+	}{
+		fmt.Sprintf("/repos/testowner/testrepo/commits?path=%s&sha=v1.2.0&per_page=1", depPath):          {Body: `{"message": "No commit found for SHA: v1.2.0"}`, Code: http.StatusNotFound},
+		"/repos/testowner/testrepo/tags?per_page=100&page=1":                                             {Body: `[{"name": "v1.1.0"}, {"name": "1.2.0"}, {"name": "v1.3.0"}]`, Code: http.StatusOK},
+		fmt.Sprintf("/repos/testowner/testrepo/commits?path=%s&sha=refs/tags/1.2.0&per_page=1", depPath): {Body: fmt.Sprintf(`[{"sha": "%s"}]`, fallbackCommitSHA), Code: http.StatusOK},
+		fmt.Sprintf("/testowner/testrepo/%s/%s", fallbackCommitSHA, depPath):                             {Body: "return '1.2.0'", Code: http.StatusOK},
+	}
+	mockServer := startMockHTTPServer(t, pathResps)
+
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	t.Run("reports the nearest tags", func(t *testing.T) {
+		warnings.Reset()
+		tempDir := setupInstallTestEnvironment(t, initialProjectToml, "", nil)
+		err := runInstallCommand(t, tempDir)
+		require.Error(t, err)
+		assert.NoFileExists(t, filepath.Join(tempDir, depPath))
+		reported := strings.Join(warnings.Reported(), "\n")
+		assert.Contains(t, reported, "tag 'v1.2.0' no longer exists upstream")
+		assert.Contains(t, reported, "tag '1.2.0' has the same version; pass --tag-fallback to use it")
+	})
+
+	t.Run("falls back to the same version", func(t *testing.T) {
+		warnings.Reset()
+		tempDir := setupInstallTestEnvironment(t, initialProjectToml, "", nil)
+		err := runInstallCommand(t, tempDir, "--tag-fallback")
+		require.NoError(t, err)
+
+		content, readErr := os.ReadFile(filepath.Join(tempDir, depPath))
+		require.NoError(t, readErr)
+		assert.Equal(t, "return '1.2.0'", string(content))
+		lockCfg := readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName))
+		assert.Equal(t, "commit:"+fallbackCommitSHA, lockCfg.Package["moved"].Hash)
+		assert.Contains(t, strings.Join(warnings.Reported(), "\n"), "using tag '1.2.0' of the same version")
+	})
+}
+
+func TestInstallCommand_Budget(t *testing.T) {
+	commitSHA := "5656565656565656565656565656565656565656"
+	pathResps := map[string]struct {
+		Body string
+		Code int
+	}{
+		fmt.Sprintf("/testowner/testrepo/%s/big.lua", commitSHA): {Body: strings.Repeat("-", 2048), Code: http.StatusOK},
+	}
+	mockServer := startMockHTTPServer(t, pathResps)
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	projectToml := func(budget string) string {
+		return fmt.Sprintf(`
+[package]
+name = "test-budget"
+version = "0.1.0"
+
+[budget]
+%s
+
+[dependencies.big]
+source = "github:testowner/testrepo/big.lua@%s"
+path = "libs/big.lua"
+`, budget, commitSHA)
+	}
+
+	t.Run("fails when the budget is exceeded", func(t *testing.T) {
+		tempDir := setupInstallTestEnvironment(t, projectToml(`max_total_size = "1KiB"`), "", nil)
+		err := runInstallCommand(t, tempDir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "dependency files total 2.0 KiB, over max_total_size 1KiB")
+		assert.NoFileExists(t, filepath.Join(tempDir, "libs", "big.lua"), "files over budget are rolled back")
+		assert.NoFileExists(t, filepath.Join(tempDir, lockfile.LockfileName), "the lockfile is not written when the budget fails")
+	})
+
+	t.Run("restores the previous file when an update is over budget", func(t *testing.T) {
+		tempDir := setupInstallTestEnvironment(t, projectToml(`max_file_size = "1KiB"`), "", map[string]string{"libs/big.lua": "return 'old'"})
+		err := runInstallCommand(t, tempDir, "--force")
+		require.Error(t, err)
+		content, readErr := os.ReadFile(filepath.Join(tempDir, "libs", "big.lua"))
+		require.NoError(t, readErr)
+		assert.Equal(t, "return 'old'", string(content))
+	})
+
+	t.Run("warns with warn_only", func(t *testing.T) {
+		warnings.Reset()
+		tempDir := setupInstallTestEnvironment(t, projectToml("max_file_size = \"1KiB\"\nwarn_only = true"), "", nil)
+		require.NoError(t, runInstallCommand(t, tempDir))
+		assert.Equal(t, []string{"over budget: 'big' is 2.0 KiB, over max_file_size 1KiB"}, warnings.Reported())
+	})
+}
+
+// TestFetchThenInstallOffline verifies that 'fetch' fills the cache without touching the project
+// and that 'install --offline' then installs the locked versions without network access.
+func TestFetchThenInstallOffline(t *testing.T) {
+	commitSHA := "7878787878787878787878787878787878787878"
+	lockedContent := "return 'locked'"
+	pathResps := map[string]struct {
+		Body string
+		Code int
+	}{
+		fmt.Sprintf("/testowner/testrepo/%s/libs/a.lua", commitSHA): {Body: lockedContent, Code: http.StatusOK},
+	}
+	mockServer := startMockHTTPServer(t, pathResps)
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	projectToml := `
+[package]
+name = "test-offline"
+version = "0.1.0"
+
+[dependencies.a]
+source = "github:testowner/testrepo/libs/a.lua@main"
+path = "libs/a.lua"
+`
+	lockToml := fmt.Sprintf(`
+api_version = "1"
+
+[package.a]
+source = "%s/testowner/testrepo/%s/libs/a.lua"
+path = "libs/a.lua"
+hash = "commit:%s"
+`, mockServer.URL, commitSHA, commitSHA)
+	tempDir := setupInstallTestEnvironment(t, projectToml, lockToml, nil)
+
+	require.NoError(t, runFetchCommand(t, tempDir))
+	assert.NoFileExists(t, filepath.Join(tempDir, "libs", "a.lua"), "fetch does not install")
+	lockBytes, err := os.ReadFile(filepath.Join(tempDir, lockfile.LockfileName))
+	require.NoError(t, err)
+	assert.Equal(t, lockToml, string(lockBytes), "fetch does not change the lockfile")
+
+	mockServer.Close() // The offline install must not need the server
+	require.NoError(t, runInstallCommand(t, tempDir, "--offline"))
+	content, err := os.ReadFile(filepath.Join(tempDir, "libs", "a.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, lockedContent, string(content))
+
+	t.Run("fails for content that was not fetched", func(t *testing.T) {
+		emptyCacheDir := setupInstallTestEnvironment(t, projectToml, lockToml, nil)
+		err := runInstallCommand(t, emptyCacheDir, "--offline")
+		require.Error(t, err)
+		assert.Equal(t, exitcode.Download, err.(cli.ExitCoder).ExitCode())
+		assert.NoFileExists(t, filepath.Join(emptyCacheDir, "libs", "a.lua"))
+	})
+
+	t.Run("fails for dependencies that are not locked", func(t *testing.T) {
+		unlockedDir := setupInstallTestEnvironment(t, projectToml, "", nil)
+		err := runInstallCommand(t, unlockedDir, "--offline")
+		require.Error(t, err)
+		assert.Equal(t, exitcode.Resolution, err.(cli.ExitCoder).ExitCode())
+	})
+}
+
+// TestInstallCommand_WarnsAboutLocalModifications verifies that install warns about an up-to-date
+// dependency whose file was edited, using the cached locked content, and leaves the file alone.
+func TestInstallCommand_WarnsAboutLocalModifications(t *testing.T) {
+	commitSHA := "7979797979797979797979797979797979797979"
+	pathResps := map[string]struct {
+		Body string
+		Code int
+	}{
+		fmt.Sprintf("/testowner/testrepo/%s/libs/a.lua", commitSHA): {Body: "return 'locked'", Code: http.StatusOK},
+	}
+	mockServer := startMockHTTPServer(t, pathResps)
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+	projectToml := `
+[package]
+name = "test-drift"
+version = "0.1.0"
+
+[dependencies.a]
+source = "github:testowner/testrepo/libs/a.lua@main"
+path = "libs/a.lua"
+`
+	lockToml := fmt.Sprintf(`
+api_version = "1"
+
+[package.a]
+source = "%s/testowner/testrepo/%s/libs/a.lua"
+path = "libs/a.lua"
+hash = "commit:%s"
+`, mockServer.URL, commitSHA, commitSHA)
+	tempDir := setupInstallTestEnvironment(t, projectToml, lockToml, nil)
+	require.NoError(t, runFetchCommand(t, tempDir))
+	require.NoError(t, runInstallCommand(t, tempDir, "--offline"))
+
+	warnings.Reset()
+	require.NoError(t, runInstallCommand(t, tempDir, "--offline"))
+	assert.Empty(t, warnings.Reported(), "an unmodified file is not reported")
+
+	localPath := filepath.Join(tempDir, "libs", "a.lua")
+	require.NoError(t, os.WriteFile(localPath, []byte("return 'edited'"), 0644))
+	warnings.Reset()
+	require.NoError(t, runInstallCommand(t, tempDir, "--offline"))
+	assert.Equal(t, []string{"'a' (libs/a.lua) differs from the locked content; run 'almd install --force a' to restore it."}, warnings.Reported())
+	content, err := os.ReadFile(localPath)
+	require.NoError(t, err)
+	assert.Equal(t, "return 'edited'", string(content), "install does not overwrite edits without --force")
+}
+
+// TestFetch_VerifiesContentHash verifies that 'fetch' rejects content that does not match the
+// content hash in almd-lock.toml.
+func TestFetch_VerifiesContentHash(t *testing.T) {
+	pathResps := map[string]struct {
+		Body string
+		Code int
+	}{
+		"/testowner/testrepo/main/libs/b.lua": {Body: "return 'tampered'", Code: http.StatusOK},
+	}
+	mockServer := startMockHTTPServer(t, pathResps)
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	tempDir := setupInstallTestEnvironment(t, `
+[package]
+name = "test-fetch-verify"
+version = "0.1.0"
+
+[dependencies.b]
+source = "github:testowner/testrepo/libs/b.lua@main"
+path = "libs/b.lua"
+`, fmt.Sprintf(`
+api_version = "1"
+
+[package.b]
+source = "%s/testowner/testrepo/main/libs/b.lua"
+path = "libs/b.lua"
+hash = "sha256:%x"
+`, mockServer.URL, sha256.Sum256([]byte("return 'original'"))), nil)
+
+	err := runFetchCommand(t, tempDir)
+	require.Error(t, err)
+	assert.Equal(t, exitcode.Integrity, err.(cli.ExitCoder).ExitCode())
+}
+
+// TestInstallCommand_DependencyHeaders verifies that a dependency's headers, expanded from the
+// environment, are sent with its download and with no other dependency's.
+func TestInstallCommand_DependencyHeaders(t *testing.T) {
+	t.Setenv("ALMD_TEST_API_KEY", "s3cret")
+	commitSHA := "9090909090909090909090909090909090909090"
+	var privateKey, publicKey string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case fmt.Sprintf("/testowner/testrepo/%s/private.lua", commitSHA):
+			privateKey = r.Header.Get("X-Api-Key")
+			_, _ = w.Write([]byte("return 'private'"))
+		case fmt.Sprintf("/testowner/testrepo/%s/public.lua", commitSHA):
+			publicKey = r.Header.Get("X-Api-Key")
+			_, _ = w.Write([]byte("return 'public'"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer mockServer.Close()
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	tempDir := setupInstallTestEnvironment(t, fmt.Sprintf(`
+[package]
+name = "test-headers"
+version = "0.1.0"
+
+[dependencies.private]
+source = "github:testowner/testrepo/private.lua@%s"
+path = "libs/private.lua"
+headers = { "X-Api-Key" = "${ALMD_TEST_API_KEY}" }
+
+[dependencies.public]
+source = "github:testowner/testrepo/public.lua@%s"
+path = "libs/public.lua"
+`, commitSHA, commitSHA), "", nil)
+
+	require.NoError(t, runInstallCommand(t, tempDir))
+	assert.Equal(t, "s3cret", privateKey)
+	assert.Empty(t, publicKey, "headers only apply to their own dependency")
+}
+
+func TestInstallCommand_ResumesInterruptedRun(t *testing.T) {
+	doneSHA := strings.Repeat("d", 40)
+	restSHA := strings.Repeat("e", 40)
+	projectToml := `
+[package]
+name = "resume"
+version = "0.1.0"
+
+[dependencies]
+done = { source = "github:owner/repo/done.lua@main", path = "libs/done.lua" }
+rest = { source = "github:owner/repo/rest.lua@main", path = "libs/rest.lua" }
+`
+	var requested []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.RequestURI())
+		switch r.URL.Path {
+		case "/repos/owner/repo/commits":
+			_, _ = fmt.Fprintf(w, `[{"sha": "%s"}]`, restSHA)
+		case "/owner/repo/" + restSHA + "/rest.lua":
+			_, _ = w.Write([]byte("return 'rest'"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer mockServer.Close()
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	// An earlier run installed 'done' and was killed before it saved almd-lock.toml.
+	tempDir := setupInstallTestEnvironment(t, projectToml, "", map[string]string{"libs/done.lua": "return 'done'"})
+	digest := sha256.Sum256([]byte("return 'done'"))
+	doneEntry := lockfile.PackageEntry{Source: mockServer.URL + "/owner/repo/" + doneSHA + "/done.lua", Path: "libs/done.lua", Hash: "commit:" + doneSHA}
+	line, err := json.Marshal(map[string]any{"name": "done", "source": "github:owner/repo/done.lua@main", "file_sha256": hex.EncodeToString(digest[:]), "entry": doneEntry})
+	require.NoError(t, err)
+	journalPath := filepath.Join(tempDir, ".almd", "tmp", "install-progress.jsonl")
+	require.NoError(t, os.MkdirAll(filepath.Dir(journalPath), 0755))
+	require.NoError(t, os.WriteFile(journalPath, append(line, '\n'), 0644))
+
+	require.NoError(t, runInstallCommand(t, tempDir))
+	for _, path := range requested {
+		assert.NotContains(t, path, "done", "the finished dependency is neither resolved nor downloaded again")
+	}
+	lock := readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName))
+	assert.Equal(t, doneEntry, lock.Package["done"])
+	assert.Equal(t, "commit:"+restSHA, lock.Package["rest"].Hash)
+	assert.NoFileExists(t, journalPath, "the journal is removed once the lockfile is saved")
+}
+
+func TestInstallCommand_DownloadsSharedURLOnce(t *testing.T) {
+	commitSHA := "abcdef0123456789abcdef0123456789"
+	downloads := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/repos/testowner/testrepo/commits":
+			_, _ = fmt.Fprintf(w, `[{"sha": "%s"}]`, commitSHA)
+		case r.URL.Path == fmt.Sprintf("/testowner/testrepo/%s/util.lua", commitSHA):
+			downloads++
+			_, _ = w.Write([]byte("return 'util'"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer mockServer.Close()
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	tempDir := setupInstallTestEnvironment(t, `
+[package]
+name = "test-shared-url"
+version = "0.1.0"
+
+[dependencies.client_util]
+source = "github:testowner/testrepo/util.lua@main"
+path = "client/util.lua"
+
+[dependencies.server_util]
+source = "github:testowner/testrepo/util.lua@main"
+path = "server/util.lua"
+`, "", nil)
+
+	require.NoError(t, runInstallCommand(t, tempDir))
+	assert.Equal(t, 1, downloads, "the shared file is downloaded once")
+	for _, path := range []string{"client/util.lua", "server/util.lua"} {
+		content, err := os.ReadFile(filepath.Join(tempDir, path))
+		require.NoError(t, err)
+		assert.Equal(t, "return 'util'", string(content))
+	}
+	lf := readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName))
+	assert.Equal(t, lf.Package["client_util"].Source, lf.Package["server_util"].Source)
+}
+
+func TestInstallCommand_GitLabSource(t *testing.T) {
+	commitSHA := "1234567890abcdef1234567890abcdef12345678"
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/group%2Frepo/repository/commits":
+			_, _ = fmt.Fprintf(w, `[{"id": "%s"}]`, commitSHA)
+		case fmt.Sprintf("/group/repo/-/raw/%s/src/lib.lua", commitSHA):
+			_, _ = w.Write([]byte("return 'gitlab'"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer mockServer.Close()
+	originalGitLabBaseURL := source.GitLabBaseURL
+	source.GitLabBaseURL = mockServer.URL
+	defer func() { source.GitLabBaseURL = originalGitLabBaseURL }()
+
+	tempDir := setupInstallTestEnvironment(t, `
+[package]
+name = "test-gitlab"
+version = "0.1.0"
+
+[dependencies.lib]
+source = "gitlab:group/repo/src/lib.lua@main"
+path = "libs/lib.lua"
+`, "", nil)
+
+	require.NoError(t, runInstallCommand(t, tempDir))
+	content, err := os.ReadFile(filepath.Join(tempDir, "libs/lib.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, "return 'gitlab'", string(content))
+	lf := readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName))
+	assert.Equal(t, "commit:"+commitSHA, lf.Package["lib"].Hash)
+	assert.Equal(t, fmt.Sprintf("%s/group/repo/-/raw/%s/src/lib.lua", mockServer.URL, commitSHA), lf.Package["lib"].Source)
+}
+
+func TestInstallCommand_BitbucketSource(t *testing.T) {
+	commitSHA := "abcdef1234567890abcdef1234567890abcdef12"
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/2.0/repositories/owner/repo/commits/main":
+			_, _ = fmt.Fprintf(w, `{"values": [{"hash": "%s"}]}`, commitSHA)
+		case fmt.Sprintf("/owner/repo/raw/%s/src/lib.lua", commitSHA):
+			_, _ = w.Write([]byte("return 'bitbucket'"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer mockServer.Close()
+	originalBaseURL, originalAPIBaseURL := source.BitbucketBaseURL, source.BitbucketAPIBaseURL
+	source.BitbucketBaseURL, source.BitbucketAPIBaseURL = mockServer.URL, mockServer.URL+"/2.0"
+	defer func() { source.BitbucketBaseURL, source.BitbucketAPIBaseURL = originalBaseURL, originalAPIBaseURL }()
+
+	tempDir := setupInstallTestEnvironment(t, fmt.Sprintf(`
+[package]
+name = "test-bitbucket"
+version = "0.1.0"
+
+[dependencies.lib]
+source = "%s/owner/repo/src/main/src/lib.lua"
+path = "libs/lib.lua"
+`, mockServer.URL), "", nil)
+
+	require.NoError(t, runInstallCommand(t, tempDir))
+	content, err := os.ReadFile(filepath.Join(tempDir, "libs/lib.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, "return 'bitbucket'", string(content))
+	lf := readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName))
+	assert.Equal(t, "commit:"+commitSHA, lf.Package["lib"].Hash)
+	assert.Equal(t, fmt.Sprintf("%s/owner/repo/raw/%s/src/lib.lua", mockServer.URL, commitSHA), lf.Package["lib"].Source)
+}
+
+func TestInstallCommand_GiteaSource(t *testing.T) {
+	commitSHA := "fedcba0987654321fedcba0987654321fedcba09"
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/repos/owner/repo/commits":
+			_, _ = fmt.Fprintf(w, `[{"sha": "%s"}]`, commitSHA)
+		case fmt.Sprintf("/owner/repo/raw/commit/%s/src/lib.lua", commitSHA):
+			_, _ = w.Write([]byte("return 'gitea'"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer mockServer.Close()
+	t.Cleanup(func() { source.SetProviderSettings(nil) })
+
+	tempDir := setupInstallTestEnvironment(t, fmt.Sprintf(`
+[package]
+name = "test-gitea"
+version = "0.1.0"
+
+[providers.gitea]
+base_url = "%s"
+
+[dependencies.lib]
+source = "gitea:owner/repo/src/lib.lua@main"
+path = "libs/lib.lua"
+`, mockServer.URL), "", nil)
+
+	require.NoError(t, runInstallCommand(t, tempDir))
+	content, err := os.ReadFile(filepath.Join(tempDir, "libs/lib.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, "return 'gitea'", string(content))
+	lf := readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName))
+	assert.Equal(t, "commit:"+commitSHA, lf.Package["lib"].Hash)
+	assert.Equal(t, fmt.Sprintf("%s/owner/repo/raw/commit/%s/src/lib.lua", mockServer.URL, commitSHA), lf.Package["lib"].Source)
+}
+
+// TestInstallCommand_PlainURLContentChanged verifies that a plain URL dependency, locked by its
+// content hash, is downloaded again and updated when the content upstream changes.
+func TestInstallCommand_PlainURLContentChanged(t *testing.T) {
+	source.SetTestModeBypassHostValidation(false)
+	defer source.SetTestModeBypassHostValidation(true)
+
+	body := "return 'v1'"
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/any/file.lua" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer mockServer.Close()
+	oldHash, err := hasher.CalculateSHA256([]byte(body))
+	require.NoError(t, err)
+
+	sourceURL := mockServer.URL + "/any/file.lua"
+	tempDir := setupInstallTestEnvironment(t, fmt.Sprintf(`
+[package]
+name = "test-plain-url"
+version = "0.1.0"
+
+[dependencies.file]
+source = "%s"
+path = "libs/file.lua"
+`, sourceURL), fmt.Sprintf(`
+api_version = "1"
+
+[package.file]
+source = "%s"
+path = "libs/file.lua"
+hash = "%s"
+`, sourceURL, oldHash), map[string]string{"libs/file.lua": body})
+
+	require.NoError(t, runInstallCommand(t, tempDir))
+	lf := readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName))
+	assert.Equal(t, oldHash, lf.Package["file"].Hash, "unchanged content keeps its lock entry")
+
+	body = "return 'v2'"
+	newHash, err := hasher.CalculateSHA256([]byte(body))
+	require.NoError(t, err)
+	require.NoError(t, runInstallCommand(t, tempDir))
+	content, err := os.ReadFile(filepath.Join(tempDir, "libs/file.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, "return 'v2'", string(content))
+	lf = readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName))
+	assert.Equal(t, newHash, lf.Package["file"].Hash)
+	assert.Equal(t, sourceURL, lf.Package["file"].Source)
+}
+
+// TestInstallCommand_LocalFileChanged verifies that a file: dependency is copied again when the
+// file it comes from changes.
+func TestInstallCommand_LocalFileChanged(t *testing.T) {
+	tempDir := setupInstallTestEnvironment(t, `
+[package]
+name = "test-local-file"
+version = "0.1.0"
+
+[dependencies.util]
+source = "file:shared/util.lua"
+path = "libs/util.lua"
+`, "", map[string]string{"shared/util.lua": "return 'v1'"})
+
+	require.NoError(t, runInstallCommand(t, tempDir))
+	content, err := os.ReadFile(filepath.Join(tempDir, "libs/util.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, "return 'v1'", string(content))
+
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "shared/util.lua"), []byte("return 'v2'"), 0644))
+	require.NoError(t, runInstallCommand(t, tempDir))
+	content, err = os.ReadFile(filepath.Join(tempDir, "libs/util.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, "return 'v2'", string(content))
+	newHash, err := hasher.CalculateSHA256([]byte("return 'v2'"))
+	require.NoError(t, err)
+	lf := readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName))
+	assert.Equal(t, newHash, lf.Package["util"].Hash)
+	assert.Equal(t, "file:shared/util.lua", lf.Package["util"].Source)
+}
+
+// TestInstallCommand_GitSource verifies that a git+ source is fetched with git and locked to
+// the commit its ref points to.
+func TestInstallCommand_GitSource(t *testing.T) {
+	repoDir := t.TempDir()
+	git := func(args ...string) string {
+		out, err := exec.Command("git", append([]string{"-C", repoDir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "--quiet", "--initial-branch=main")
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, "util.lua"), []byte("return 'git'"), 0644))
+	git("add", "-A")
+	git("commit", "--quiet", "-m", "first")
+	commitSHA := git("rev-parse", "HEAD")
+	repoURL := "git+file://" + filepath.ToSlash(repoDir)
+
+	tempDir := setupInstallTestEnvironment(t, fmt.Sprintf(`
+[package]
+name = "test-git"
+version = "0.1.0"
+
+[dependencies.util]
+source = "%s#path=util.lua&ref=main"
+path = "libs/util.lua"
+`, repoURL), "", nil)
+
+	require.NoError(t, runInstallCommand(t, tempDir))
+	content, err := os.ReadFile(filepath.Join(tempDir, "libs/util.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, "return 'git'", string(content))
+	lf := readAlmdLockToml(t, filepath.Join(tempDir, lockfile.LockfileName))
+	assert.Equal(t, "commit:"+commitSHA, lf.Package["util"].Hash)
+	assert.Equal(t, repoURL+"#path=util.lua&ref="+commitSHA, lf.Package["util"].Source)
+}
+
+// TestInstallCommand_JobSummary verifies that in GitHub Actions install lists what it
+// installed in the job summary, and notes when everything was already up to date.
+func TestInstallCommand_JobSummary(t *testing.T) {
+	tempDir := setupInstallTestEnvironment(t, `
+[package]
+name = "test-summary"
+version = "0.1.0"
+
+[dependencies.util]
+source = "file:shared/util.lua"
+path = "libs/util.lua"
+`, "", map[string]string{"shared/util.lua": "return 'util'"})
+	summaryPath := filepath.Join(t.TempDir(), "summary.md")
+	t.Setenv(jobsummary.Env, summaryPath)
+
+	require.NoError(t, runInstallCommand(t, tempDir))
+	require.NoError(t, runInstallCommand(t, tempDir))
+	summary, err := os.ReadFile(summaryPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(summary), "### almd install")
+	assert.Contains(t, string(summary), "| util | installed | sha256:")
+	assert.Contains(t, string(summary), "1 installed, 0 failed, 0 skipped.")
+	assert.Contains(t, string(summary), "All targeted dependencies are already up-to-date.")
+}
+
+// TestUpdateCommand_BranchPushAndPR verifies that 'update --branch --push --pr' commits the
+// updated files to a branch, pushes it and opens a pull request describing the change.
+func TestUpdateCommand_BranchPushAndPR(t *testing.T) {
+	depPath := "libs/depA.lua"
+	tempDir := setupInstallTestEnvironment(t, `
+[package]
+name = "test-update"
+
+[dependencies.depA]
+source = "github:testowner/testrepo/libs/depA.lua@main"
+path = "libs/depA.lua"
+`, `
+api_version = "1"
+
+[package.depA]
+source = "https://raw.githubusercontent.com/testowner/testrepo/1111111111111111111111111111111111111111/libs/depA.lua"
+path = "libs/depA.lua"
+hash = "commit:1111111111111111111111111111111111111111"
+`, map[string]string{depPath: "return 1"})
+
+	for _, name := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(name, "test")
+	}
+	for _, name := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(name, "test@example.com")
+	}
+	t.Setenv("GITHUB_TOKEN", "test-token")
+	remoteDir := t.TempDir()
+	git := func(dir string, args ...string) string {
+		out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+	git(remoteDir, "init", "--quiet", "--bare")
+	git(tempDir, "init", "--quiet", "--initial-branch=main")
+	git(tempDir, "add", "-A")
+	git(tempDir, "commit", "--quiet", "-m", "initial")
+	git(tempDir, "remote", "add", "origin", "https://github.com/testowner/game.git")
+	git(tempDir, "config", "url."+remoteDir+".pushInsteadOf", "https://github.com/testowner/game.git")
+
+	newSHA := "2222222222222222222222222222222222222222"
+	var pr source.NewPullRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/testowner/game/pulls":
+			assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&pr))
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"html_url": "https://github.com/testowner/game/pull/7"}`))
+		case r.URL.Path == "/repos/testowner/testrepo/commits":
+			_, _ = fmt.Fprintf(w, `[{"sha": "%s"}]`, newSHA)
+		case r.URL.Path == "/testowner/testrepo/"+newSHA+"/"+depPath:
+			_, _ = w.Write([]byte("return 2"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = server.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	t.Chdir(tempDir)
+	app := &cli.App{Commands: []*cli.Command{installcmd.UpdateCmd()}, ExitErrHandler: func(*cli.Context, error) {}}
+	require.NoError(t, app.Run([]string{"almd", "update", "--branch", "deps/update", "--push", "--pr"}))
+
+	assert.Equal(t, "deps/update", git(tempDir, "rev-parse", "--abbrev-ref", "HEAD"))
+	assert.Equal(t, "Update depA from 111111111111 to 222222222222", git(remoteDir, "log", "-1", "--format=%s", "deps/update"))
+	changed := git(remoteDir, "show", "--name-only", "--format=", "deps/update")
+	assert.ElementsMatch(t, []string{lockfile.LockfileName, depPath}, strings.Fields(changed))
+
+	assert.Equal(t, "Update depA from 111111111111 to 222222222222", pr.Title)
+	assert.Equal(t, "deps/update", pr.Head)
+	assert.Equal(t, "main", pr.Base)
+	assert.Contains(t, pr.Body, "| depA | 111111111111 | 222222222222 | libs/depA.lua |")
+
+	require.Error(t, app.Run([]string{"almd", "update", "--pr"}), "--pr needs --push")
+}
+
+// TestUpdateCommand_BranchStagesRemovedFiles verifies that 'update --branch' commits the deletion
+// of a removed dependency's file, and that it asks for --base when HEAD is detached.
+func TestUpdateCommand_BranchStagesRemovedFiles(t *testing.T) {
+	commitSHA := "1111111111111111111111111111111111111111"
+	tempDir := setupInstallTestEnvironment(t, fmt.Sprintf(`
+[package]
+name = "test-update"
+
+[dependencies.depA]
+source = "github:testowner/testrepo/libs/depA.lua@%[1]s"
+path = "libs/depA.lua"
+
+[dependencies.depB]
+source = "github:testowner/testrepo/libs/depB.lua@%[1]s"
+path = "libs/depB.lua"
+`, commitSHA), fmt.Sprintf(`
+api_version = "1"
+
+[package.depA]
+source = "https://raw.githubusercontent.com/testowner/testrepo/%[1]s/libs/depA.lua"
+path = "libs/depA.lua"
+hash = "commit:%[1]s"
+
+[package.depB]
+source = "https://raw.githubusercontent.com/testowner/testrepo/%[1]s/libs/depB.lua"
+path = "libs/depB.lua"
+hash = "commit:%[1]s"
+`, commitSHA), map[string]string{"libs/depA.lua": "return 'a'", "libs/depB.lua": "return 'b'"})
+
+	for _, name := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(name, "test")
+	}
+	for _, name := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(name, "test@example.com")
+	}
+	git := func(args ...string) string {
+		out, err := exec.Command("git", append([]string{"-C", tempDir}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "--quiet", "--initial-branch=main")
+	git("add", "-A")
+	git("commit", "--quiet", "-m", "initial")
+
+	// depB is removed from the manifest along with its file.
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, config.ProjectTomlName), []byte(fmt.Sprintf(`
+[package]
+name = "test-update"
+
+[dependencies.depA]
+source = "github:testowner/testrepo/libs/depA.lua@%s"
+path = "libs/depA.lua"
+`, commitSHA)), 0644))
+	require.NoError(t, os.Remove(filepath.Join(tempDir, "libs", "depB.lua")))
+
+	t.Chdir(tempDir)
+	app := &cli.App{Commands: []*cli.Command{installcmd.UpdateCmd()}, ExitErrHandler: func(*cli.Context, error) {}}
+	require.NoError(t, app.Run([]string{"almd", "update", "--branch", "deps/update"}))
+	assert.Contains(t, strings.Split(git("show", "--name-status", "--format=", "deps/update"), "\n"), "D\tlibs/depB.lua")
+	assert.Empty(t, git("status", "--porcelain"))
+
+	git("switch", "--quiet", "--detach", "main")
+	err := app.Run([]string{"almd", "update", "--branch", "deps/update"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--base")
+}
+
+// TestInstallCommand_YankedAdvisory verifies that a version yanked in a registry's advisory index
+// is installed with a warning, and refused with --strict.
+func TestInstallCommand_YankedAdvisory(t *testing.T) {
+	depPath := "libs/json.lua"
+	yankedSHA := "3333333333333333333333333333333333333333"
+	indexPath := filepath.Join(t.TempDir(), "advisories.toml")
+	require.NoError(t, os.WriteFile(indexPath, []byte(`
+[[advisory]]
+source = "github:testowner/testrepo/libs/json.lua"
+versions = ["`+yankedSHA[:12]+`"]
+status = "yanked"
+message = "Decodes numbers incorrectly"
+`), 0644))
+	projectToml := fmt.Sprintf(`
+[package]
+name = "test-advisory"
+
+[registries.notices]
+advisories = "file://%s"
+
+[dependencies.json]
+source = "github:testowner/testrepo/%s@main"
+path = "%s"
+`, filepath.ToSlash(indexPath), depPath, depPath)
+
+	mockServer := startMockHTTPServer(t, map[string]struct {
+		Body string
+		Code int
+	}{
+		fmt.Sprintf("/repos/testowner/testrepo/commits?path=%s&sha=main&per_page=1", depPath): {Body: fmt.Sprintf(`[{"sha": "%s"}]`, yankedSHA), Code: http.StatusOK},
+		fmt.Sprintf("/testowner/testrepo/%s/%s", yankedSHA, depPath):                          {Body: "return {}", Code: http.StatusOK},
+	})
+	originalGHAPIBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	defer func() { source.GithubAPIBaseURL = originalGHAPIBaseURL }()
+
+	tempDir := setupInstallTestEnvironment(t, projectToml, "", nil)
+	warnings.Reset()
+	err := runInstallCommand(t, tempDir, "--strict")
+	require.Error(t, err)
+	assert.Equal(t, exitcode.Integrity, err.(cli.ExitCoder).ExitCode())
+	assert.NoFileExists(t, filepath.Join(tempDir, depPath), "a yanked version is not installed with --strict")
+
+	warnings.Reset()
+	require.NoError(t, runInstallCommand(t, tempDir))
+	assert.FileExists(t, filepath.Join(tempDir, depPath))
+	assert.Equal(t, []string{"'json' is yanked: Decodes numbers incorrectly."}, warnings.Reported())
+}
@@ -0,0 +1,97 @@
+package install
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/nightconcept/almandine/internal/core/cache"
+	"github.com/nightconcept/almandine/internal/core/filemode"
+	coreproject "github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/safepath"
+	"github.com/nightconcept/almandine/internal/core/shell"
+)
+
+// installMode returns the install mode a dependency's install field or lock entry names, with
+// the default (copy) for an empty one.
+func installMode(install string) string {
+	return coreproject.Dependency{Install: install}.InstallMode()
+}
+
+// linkDependencyFile stores content in the global cache and makes the dependency's path a
+// symbolic link to it, so projects vendoring the same file share one copy. It returns content.
+func linkDependencyFile(dep dependencyInstallState, content []byte) ([]byte, error) {
+	store, err := cache.Open()
+	if err != nil {
+		return nil, fmt.Errorf("cannot link dependency '%s': the cache is unavailable: %w", dep.Name, err)
+	}
+	hash, err := store.Put(dep.TargetRawURL, content, false)
+	if err != nil {
+		return nil, fmt.Errorf("cannot link dependency '%s': %w", dep.Name, err)
+	}
+	blob, err := store.BlobPath(hash)
+	if err == nil {
+		blob, err = filepath.Abs(blob)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot link dependency '%s': %w", dep.Name, err)
+	}
+	path := safepath.LongPath(dep.ProjectTomlPath)
+	if err := filemode.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to replace '%s' for dependency '%s': %w", dep.ProjectTomlPath, dep.Name, err)
+	}
+	if err := os.Symlink(blob, path); err != nil {
+		return nil, fmt.Errorf("failed to link '%s' for dependency '%s' to %s: %w (use install = \"%s\" where symbolic links are not available)",
+			dep.ProjectTomlPath, dep.Name, blob, err, coreproject.InstallCopy)
+	}
+	return content, nil
+}
+
+// generateDependencyFile runs the script that generates a dependency, which reads the upstream
+// content from the file named by $ALMD_INPUT and writes the dependency's file to $ALMD_OUTPUT.
+// It returns the content the script wrote.
+func generateDependencyFile(dep dependencyInstallState, content []byte) ([]byte, error) {
+	input, err := os.CreateTemp("", "almd-generate-*")
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate dependency '%s': %w", dep.Name, err)
+	}
+	defer func() { _ = os.Remove(input.Name()) }()
+	_, err = input.Write(content)
+	if closeErr := input.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate dependency '%s': %w", dep.Name, err)
+	}
+	output, err := filepath.Abs(dep.ProjectTomlPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate dependency '%s': %w", dep.Name, err)
+	}
+	if err := removeLink(output); err != nil {
+		return nil, fmt.Errorf("failed to replace the link at '%s' for dependency '%s': %w", dep.ProjectTomlPath, dep.Name, err)
+	}
+
+	cmd := shell.Command(dep.GenerateCmd)
+	cmd.Env = append(os.Environ(), "ALMD_DEPENDENCY="+dep.Name, "ALMD_INPUT="+input.Name(), "ALMD_OUTPUT="+output)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("the script generating dependency '%s' failed: %w", dep.Name, err)
+	}
+	generated, err := os.ReadFile(safepath.LongPath(output))
+	if err != nil {
+		return nil, fmt.Errorf("the script generating dependency '%s' did not write %s: %w", dep.Name, dep.ProjectTomlPath, err)
+	}
+	return generated, nil
+}
+
+// removeLink removes path if it is a symbolic link, so that writing the file does not write
+// through a link made in link mode into the cache.
+func removeLink(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		return nil
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,99 @@
+package install
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+)
+
+// problem is a dependency that failed or was skipped, with the exit code describing why.
+type problem struct {
+	Name string
+	Code int
+}
+
+// outcome collects the problems of an install run and derives its exit code. Failures always
+// count; warnings (skipped dependencies, unparsable sources, unresolved refs) only count with
+// --strict.
+// fail, warn and rateLimit may be called by concurrent resolution workers.
+type outcome struct {
+	mu          sync.Mutex
+	failures    []problem
+	warnings    []problem
+	rateLimited []string // Dependencies whose refs could not be resolved because of the API rate limit
+	aborted     bool     // --fail-fast stopped the run and none of its changes were kept
+}
+
+// fail records that name could not be installed.
+func (o *outcome) fail(name string, code int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.failures = append(o.failures, problem{Name: name, Code: code})
+}
+
+// warn records that name was skipped or installed despite a problem.
+func (o *outcome) warn(name string, code int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.warnings = append(o.warnings, problem{Name: name, Code: code})
+}
+
+// rateLimit records a warning for name whose ref could not be resolved because GitHub's rate
+// limit was exhausted. They are reported together once resolution has finished.
+func (o *outcome) rateLimit(name string) {
+	o.warn(name, exitcode.Resolution)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.rateLimited = append(o.rateLimited, name)
+}
+
+// failed reports whether the run has problems that make it fail.
+func (o *outcome) failed(strict bool) bool {
+	return len(o.failures) > 0 || (strict && len(o.warnings) > 0)
+}
+
+// exitError returns nil when the run succeeded, and otherwise a cli.Exit error whose code is
+// exitcode.Partial when some of the targeted dependencies succeeded, or the most specific code
+// among the problems (see exitcode.MostSpecific) when none did. An aborted run never counts as
+// a partial success.
+func (o *outcome) exitError(targeted int, strict bool) error {
+	problems := o.failures
+	if strict {
+		problems = append(problems[:len(problems):len(problems)], o.warnings...)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	failed := make(map[string]bool)
+	code := exitcode.Usage
+	for _, p := range problems {
+		failed[p.Name] = true
+		code = exitcode.MostSpecific(code, p.Code)
+	}
+	names := make([]string, 0, len(failed))
+	for name := range failed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	reason := "failed"
+	if len(o.failures) == 0 {
+		reason = "had warnings and --strict is set"
+	}
+	if o.aborted {
+		return cli.Exit(fmt.Sprintf("Error: stopped at the first problem (--fail-fast); no changes were kept: %s", strings.Join(names, ", ")), code)
+	}
+	if len(failed) < targeted {
+		return cli.Exit(fmt.Sprintf("Error: %d of %d dependenc(ies) %s: %s", len(failed), targeted, reason, strings.Join(names, ", ")), exitcode.Partial)
+	}
+	if len(o.failures) == 0 {
+		return cli.Exit(fmt.Sprintf("Error: every targeted dependency %s: %s", reason, strings.Join(names, ", ")), code)
+	}
+	return cli.Exit("Install/Update process completed with errors for all targeted dependencies.", code)
+}
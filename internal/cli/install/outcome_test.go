@@ -0,0 +1,47 @@
+package install
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+)
+
+func exitCodeOf(t *testing.T, err error) int {
+	t.Helper()
+	if err == nil {
+		return exitcode.OK
+	}
+	exitErr, ok := err.(cli.ExitCoder)
+	require.True(t, ok, "expected a cli.ExitCoder, got %T", err)
+	return exitErr.ExitCode()
+}
+
+func TestOutcome_ExitCodes(t *testing.T) {
+	assert.Equal(t, exitcode.OK, exitCodeOf(t, (&outcome{}).exitError(3, true)))
+
+	warned := &outcome{}
+	warned.warn("skipped", exitcode.Resolution)
+	assert.Equal(t, exitcode.OK, exitCodeOf(t, warned.exitError(2, false)), "warnings only fail with --strict")
+	assert.Equal(t, exitcode.Partial, exitCodeOf(t, warned.exitError(2, true)))
+	err := warned.exitError(1, true)
+	assert.Equal(t, exitcode.Resolution, exitCodeOf(t, err))
+	assert.Contains(t, err.Error(), "had warnings and --strict is set: skipped")
+
+	failed := &outcome{}
+	failed.fail("a", exitcode.Download)
+	failed.fail("b", exitcode.Download)
+	assert.Equal(t, exitcode.Download, exitCodeOf(t, failed.exitError(2, false)))
+	err = failed.exitError(3, false)
+	assert.Equal(t, exitcode.Partial, exitCodeOf(t, err))
+	assert.Equal(t, "Error: 2 of 3 dependenc(ies) failed: a, b", err.Error())
+
+	failed.warn("a", exitcode.Resolution)
+	assert.Equal(t, exitcode.Download, exitCodeOf(t, failed.exitError(2, true)), "the most specific code wins when nothing succeeded")
+
+	failed.fail("b", exitcode.Usage)
+	assert.Equal(t, exitcode.Download, exitCodeOf(t, failed.exitError(2, false)), "a failed download is more specific than a general error")
+}
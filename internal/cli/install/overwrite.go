@@ -0,0 +1,49 @@
+package install
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+	"github.com/nightconcept/almandine/internal/core/inventory"
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+	"github.com/nightconcept/almandine/internal/core/warnings"
+)
+
+// guardOverwrites returns the dependencies that may be installed. A dependency whose path in
+// project.toml points at a file of the project's own, one that almd did not install and the
+// lockfile does not record, is refused unless --allow-overwrite is set: it is recorded in out and
+// marked Refused in installStates, so that e.g. a mistyped path never clobbers src/main.lua.
+// Projects without an inventory are not checked, since which files almd installed is not known.
+func guardOverwrites(installStates, dependenciesThatNeedAction []dependencyInstallState, lf *lockfile.Lockfile, opts installOptions, out *outcome) []dependencyInstallState {
+	if opts.AllowOverwrite {
+		return dependenciesThatNeedAction
+	}
+	inv, err := inventory.Load(".")
+	if err != nil {
+		warnings.Printf("Could not check for files almd did not install: %v", err)
+		return dependenciesThatNeedAction
+	}
+	if !inv.Recorded() {
+		return dependenciesThatNeedAction
+	}
+	var lockedPaths []string
+	for _, entry := range lf.Package {
+		lockedPaths = append(lockedPaths, entry.FilePaths()...)
+	}
+
+	var allowed []dependencyInstallState
+	for _, dep := range dependenciesThatNeedAction {
+		unmanaged := inv.Unmanaged(lockedPaths, dep.filePaths()...)
+		if len(unmanaged) == 0 {
+			allowed = append(allowed, dep)
+			continue
+		}
+		files := strings.Join(unmanaged, "', '")
+		_, _ = fmt.Fprintf(os.Stderr, "Error: Refusing to install '%s' over '%s': almd did not install it. Check the path in project.toml, or pass --allow-overwrite to replace it.\n", dep.Name, files)
+		out.fail(dep.Name, exitcode.Usage)
+		markRefused(installStates, dep.Name, fmt.Sprintf("would overwrite '%s', which almd did not install (pass --allow-overwrite to install it)", files))
+	}
+	return allowed
+}
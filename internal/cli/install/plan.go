@@ -0,0 +1,264 @@
+package install
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/cli/completion"
+	"github.com/nightconcept/almandine/internal/core/cache"
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/filemode"
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+	coreproject "github.com/nightconcept/almandine/internal/core/project"
+)
+
+// planInput is where plan confirmations are read from. Tests replace it.
+var planInput io.Reader = os.Stdin
+
+// ExplainCmd returns the 'explain' command, which prints what 'install' would do and only
+// applies it after confirmation or with --apply. It is equivalent to 'almd install --plan'.
+func ExplainCmd() *cli.Command {
+	return &cli.Command{
+		Name:         "explain",
+		Usage:        "Show the install/update plan before changing anything",
+		ArgsUsage:    "[dependency_names...]",
+		BashComplete: completion.Dependencies,
+		Flags:        installFlags(),
+		Action: func(c *cli.Context) error {
+			return runInstall(c, true)
+		},
+	}
+}
+
+// printInstallPlan describes, per dependency, the current and target state, why it would
+// change, the downloads involved and the files that would be written.
+func printInstallPlan(w io.Writer, states, actions []dependencyInstallState, stale []string, settings filemode.Settings) {
+	pending := make(map[string]dependencyInstallState, len(actions))
+	for _, dep := range actions {
+		pending[dep.Name] = dep
+	}
+	sorted := append([]dependencyInstallState(nil), states...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	refused := countRefused(states)
+	_, _ = fmt.Fprintf(w, "Plan: %d to install/update, %d up to date, %d lockfile entr(ies) to prune",
+		len(actions), len(states)-len(actions)-refused, len(stale))
+	if refused > 0 {
+		_, _ = fmt.Fprintf(w, ", %d refused", refused)
+	}
+	_, _ = fmt.Fprintln(w, ".")
+	_, _ = fmt.Fprintln(w, "Refs were resolved against their hosts while planning; no files have been changed yet.")
+
+	for _, state := range sorted {
+		dep, ok := pending[state.Name]
+		if state.Refused != "" {
+			_, _ = fmt.Fprintf(w, "\n! %s (refused)\n", state.Name)
+			_, _ = fmt.Fprintf(w, "    current:  %s\n", describeCurrentState(state))
+			_, _ = fmt.Fprintf(w, "    target:   %s\n", describeTargetState(state))
+			_, _ = fmt.Fprintf(w, "    reason:   %s\n", state.Refused)
+			continue
+		}
+		if !ok {
+			_, _ = fmt.Fprintf(w, "\n= %s (up to date)\n", state.Name)
+			_, _ = fmt.Fprintf(w, "    current:  %s\n", describeCurrentState(state))
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "\n~ %s\n", dep.Name)
+		_, _ = fmt.Fprintf(w, "    current:  %s\n", describeCurrentState(dep))
+		_, _ = fmt.Fprintf(w, "    target:   %s\n", describeTargetState(dep))
+		_, _ = fmt.Fprintf(w, "    reason:   %s\n", dep.ActionReason)
+		_, _ = fmt.Fprintf(w, "    network:  %s\n", describeDownload(dep))
+		_, _ = fmt.Fprintf(w, "    writes:   %s, %s\n", describeWrite(dep, settings), lockfile.LockfileName)
+	}
+	for _, name := range stale {
+		_, _ = fmt.Fprintf(w, "\n- %s (locked but no longer in %s)\n", name, config.ManifestName())
+		_, _ = fmt.Fprintf(w, "    writes:   %s\n", lockfile.LockfileName)
+	}
+}
+
+// countRefused returns the number of states whose install is refused.
+func countRefused(states []dependencyInstallState) int {
+	refused := 0
+	for _, state := range states {
+		if state.Refused != "" {
+			refused++
+		}
+	}
+	return refused
+}
+
+func describeCurrentState(state dependencyInstallState) string {
+	locked := "not locked"
+	if state.LockedCommitHash != "" {
+		locked = "locked at " + state.LockedCommitHash
+	}
+	file := "file present"
+	if _, err := os.Stat(state.ProjectTomlPath); err != nil {
+		file = "file missing"
+	}
+	return fmt.Sprintf("%s, %s (%s)", locked, file, state.ProjectTomlPath)
+}
+
+func describeTargetState(dep dependencyInstallState) string {
+	if isImmutableTarget(dep) {
+		return "commit " + dep.TargetCommitHash
+	}
+	if dep.TargetCommitHash != "" {
+		return fmt.Sprintf("ref %s (content hash recorded after download)", dep.TargetCommitHash)
+	}
+	return "content hash recorded after download"
+}
+
+// describeDownload reports whether installing dep needs a download or can be served from the
+// global cache.
+func describeDownload(dep dependencyInstallState) string {
+	if targetCached(dep) {
+		return "none (cached: " + dep.TargetRawURL + ")"
+	}
+	return "GET " + dep.TargetRawURL
+}
+
+// targetCached reports whether the global cache already holds dep's target content.
+func targetCached(dep dependencyInstallState) bool {
+	if !isImmutableTarget(dep) {
+		return false
+	}
+	store, err := cache.Open()
+	if err != nil {
+		return false
+	}
+	_, ok := store.LookupURL(dep.TargetRawURL)
+	return ok
+}
+
+func describeWrite(dep dependencyInstallState, settings filemode.Settings) string {
+	switch installMode(dep.Install) {
+	case coreproject.InstallLink:
+		return dep.ProjectTomlPath + " (link to the cache)"
+	case coreproject.InstallGenerate:
+		return dep.ProjectTomlPath + " (generated by a script)"
+	}
+	mode, err := filemode.Resolve(dep.ProjectTomlMode, settings.FileMode, dep.ProjectTomlPath)
+	if err != nil {
+		return fmt.Sprintf("%s (%v)", dep.ProjectTomlPath, err)
+	}
+	if settings.ReadOnly {
+		return fmt.Sprintf("%s (mode %s, read-only)", dep.ProjectTomlPath, filemode.Format(mode&^0222))
+	}
+	return fmt.Sprintf("%s (mode %s)", dep.ProjectTomlPath, filemode.Format(mode))
+}
+
+// confirmPlan asks whether to apply the printed plan unless --apply was given. Without an
+// answer (for example when stdin is not a terminal) the plan is not applied.
+func confirmPlan(apply bool) bool {
+	if apply {
+		return true
+	}
+	_, _ = fmt.Fprint(os.Stdout, "\nApply this plan? (y/N): ")
+	input, err := bufio.NewReader(planInput).ReadString('\n')
+	answer := strings.ToLower(strings.TrimSpace(input))
+	if err != nil && answer == "" {
+		_, _ = fmt.Fprintln(os.Stdout)
+	}
+	if answer == "y" || answer == "yes" {
+		return true
+	}
+	_, _ = fmt.Fprintln(os.Stdout, "Plan not applied. Re-run with --apply to make these changes.")
+	return false
+}
+
+// planJSONVersion is bumped whenever the JSON plan format changes incompatibly.
+const planJSONVersion = 1
+
+// jsonPlan is the plan printed by 'install --dry-run --json', for bots that comment on pull
+// requests with the vendored files a change would touch.
+type jsonPlan struct {
+	Version      int             `json:"version"`
+	Dependencies []jsonPlanEntry `json:"dependencies"`
+	// DownloadBytes adds up the known estimates; entries whose size is unknown are left out.
+	DownloadBytes int64 `json:"download_bytes"`
+}
+
+// jsonPlanEntry describes one dependency. Action is "install" (not locked yet), "update",
+// "none" (up to date), "refused" (e.g. a downgrade without --allow-downgrade) or "prune" (locked
+// but no longer declared).
+type jsonPlanEntry struct {
+	Dependency string           `json:"dependency"`
+	Action     string           `json:"action"`
+	Path       string           `json:"path,omitempty"`
+	Current    *jsonPlanCurrent `json:"current,omitempty"`
+	Target     *jsonPlanTarget  `json:"target,omitempty"`
+	Reason     string           `json:"reason,omitempty"`
+	// DownloadBytes estimates the download: 0 when the target is cached, otherwise the size of
+	// the file it replaces. It is null when there is nothing to go by.
+	DownloadBytes *int64 `json:"download_bytes,omitempty"`
+}
+
+type jsonPlanCurrent struct {
+	Commit      string `json:"commit,omitempty"`
+	FilePresent bool   `json:"file_present"`
+}
+
+type jsonPlanTarget struct {
+	Commit string `json:"commit,omitempty"`
+	URL    string `json:"url"`
+	Cached bool   `json:"cached"`
+}
+
+// printInstallPlanJSON writes the same plan as printInstallPlan as indented JSON.
+func printInstallPlanJSON(w io.Writer, states, actions []dependencyInstallState, stale []string) error {
+	pending := make(map[string]dependencyInstallState, len(actions))
+	for _, dep := range actions {
+		pending[dep.Name] = dep
+	}
+	sorted := append([]dependencyInstallState(nil), states...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	plan := jsonPlan{Version: planJSONVersion, Dependencies: []jsonPlanEntry{}}
+	for _, state := range sorted {
+		entry := jsonPlanEntry{Dependency: state.Name, Action: "none", Path: state.ProjectTomlPath}
+		info, statErr := os.Stat(state.ProjectTomlPath)
+		entry.Current = &jsonPlanCurrent{Commit: state.LockedCommitHash, FilePresent: statErr == nil}
+		if state.Refused != "" {
+			entry.Action, entry.Reason = "refused", state.Refused
+			entry.Target = &jsonPlanTarget{Commit: state.TargetCommitHash, URL: state.TargetRawURL}
+		}
+		if dep, ok := pending[state.Name]; ok {
+			entry.Action = "update"
+			if dep.LockedCommitHash == "" {
+				entry.Action = "install"
+			}
+			entry.Reason = dep.ActionReason
+			cached := targetCached(dep)
+			entry.Target = &jsonPlanTarget{Commit: dep.TargetCommitHash, URL: dep.TargetRawURL, Cached: cached}
+			switch {
+			case cached:
+				entry.DownloadBytes = new(int64)
+			case statErr == nil:
+				size := info.Size()
+				entry.DownloadBytes = &size
+			}
+			if entry.DownloadBytes != nil {
+				plan.DownloadBytes += *entry.DownloadBytes
+			}
+		}
+		plan.Dependencies = append(plan.Dependencies, entry)
+	}
+	for _, name := range stale {
+		plan.Dependencies = append(plan.Dependencies, jsonPlanEntry{Dependency: name, Action: "prune", Reason: "locked but no longer in " + config.ManifestName()})
+	}
+
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
@@ -0,0 +1,58 @@
+package install
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nightconcept/almandine/internal/core/filemode"
+	"github.com/nightconcept/almandine/internal/core/paths"
+)
+
+func TestPrintInstallPlan(t *testing.T) {
+	t.Setenv(paths.CacheDirEnv, t.TempDir())
+	t.Setenv(paths.ConfigDirEnv, t.TempDir())
+	dir := t.TempDir()
+	present := filepath.Join(dir, "present.lua")
+	assert.NoError(t, os.WriteFile(present, []byte("x"), 0644))
+
+	upToDate := dependencyInstallState{Name: "stable", ProjectTomlPath: present, LockedCommitHash: "sha256:abc"}
+	update := dependencyInstallState{
+		Name:             "lib",
+		ProjectTomlPath:  filepath.Join(dir, "lib.lua"),
+		Provider:         "github",
+		TargetCommitHash: "abcdef1234567890abcdef1234567890abcdef12",
+		TargetRawURL:     "https://example.com/o/r/abcdef1234567890abcdef1234567890abcdef12/lib.lua",
+		ActionReason:     "Dependency present in project.toml but not in almd-lock.toml.",
+	}
+
+	var out bytes.Buffer
+	printInstallPlan(&out, []dependencyInstallState{update, upToDate}, []dependencyInstallState{update}, []string{"gone"}, filemode.Settings{})
+	plan := out.String()
+
+	assert.Contains(t, plan, "Plan: 1 to install/update, 1 up to date, 1 lockfile entr(ies) to prune.")
+	assert.Contains(t, plan, "~ lib\n    current:  not locked, file missing")
+	assert.Contains(t, plan, "target:   commit abcdef1234567890abcdef1234567890abcdef12")
+	assert.Contains(t, plan, "reason:   Dependency present in project.toml but not in almd-lock.toml.")
+	assert.Contains(t, plan, "network:  GET "+update.TargetRawURL)
+	assert.Contains(t, plan, "lib.lua (mode 0644), almd-lock.toml")
+	assert.Contains(t, plan, "= stable (up to date)\n    current:  locked at sha256:abc, file present")
+	assert.Contains(t, plan, "- gone (locked but no longer in project.toml)")
+	assert.Less(t, strings.Index(plan, "~ lib"), strings.Index(plan, "= stable"), "dependencies are listed by name")
+}
+
+func TestConfirmPlan(t *testing.T) {
+	original := planInput
+	defer func() { planInput = original }()
+
+	assert.True(t, confirmPlan(true), "--apply skips the prompt")
+
+	for input, want := range map[string]bool{"y\n": true, "YES\n": true, "n\n": false, "\n": false, "": false} {
+		planInput = strings.NewReader(input)
+		assert.Equal(t, want, confirmPlan(false), "input %q", input)
+	}
+}
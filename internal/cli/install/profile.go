@@ -0,0 +1,153 @@
+package install
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/filemode"
+	"github.com/nightconcept/almandine/internal/core/logger"
+	coreproject "github.com/nightconcept/almandine/internal/core/project"
+)
+
+// installOptions holds the effective settings for a single install run once the selected
+// profile and any explicitly passed flags have been combined.
+type installOptions struct {
+	Force   bool
+	Verbose bool
+	NoPrune bool
+	Frozen  bool
+	Strict  bool
+	// FailFast stops at the first failure and rolls back the run; otherwise every dependency
+	// is attempted (--keep-going).
+	FailFast bool
+	// AllowDowngrade permits moving a dependency to an older commit. It is deliberately not
+	// available in profiles, so every downgrade is asked for explicitly.
+	AllowDowngrade bool
+	// AllowOverwrite permits installing over files almd did not install; see guardOverwrites.
+	AllowOverwrite bool
+	// DryRun only prints the plan; nothing is written, not even the journal.
+	DryRun bool
+	// JSON prints the plan as JSON on stdout; see messages.
+	JSON bool
+	// TagFallback resolves a tag that disappeared upstream to a remaining tag of the same version.
+	TagFallback bool
+	// Offline installs the locked versions from the cache and never touches the network.
+	Offline bool
+	// ToolVersion is the almd version recorded in provenance attestations.
+	ToolVersion string
+	// FileSettings are the global file mode settings, loaded once for the whole run.
+	FileSettings filemode.Settings
+
+	// resumed names the dependencies taken from an interrupted run; see resumeProgress.
+	resumed map[string]bool
+	// stats collects the summary printed with --stats; nil without it.
+	stats *runStats
+}
+
+// builtinProfiles are available in every project. A [profiles.<name>] table in project.toml
+// with the same name replaces the built-in definition entirely.
+var builtinProfiles = map[string]coreproject.Profile{
+	"dev":     {},
+	"ci":      {Frozen: true, Verbose: true, Strict: true},
+	"release": {Frozen: true},
+}
+
+// lookupProfile finds a profile by name, preferring project-defined profiles over built-ins.
+func lookupProfile(projCfg *coreproject.Project, name string) (coreproject.Profile, error) {
+	if profile, ok := projCfg.Profiles[name]; ok {
+		return profile, nil
+	}
+	if profile, ok := builtinProfiles[name]; ok {
+		return profile, nil
+	}
+
+	available := make(map[string]struct{}, len(builtinProfiles)+len(projCfg.Profiles))
+	for n := range builtinProfiles {
+		available[n] = struct{}{}
+	}
+	for n := range projCfg.Profiles {
+		available[n] = struct{}{}
+	}
+	names := make([]string, 0, len(available))
+	for n := range available {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return coreproject.Profile{}, fmt.Errorf("unknown profile '%s' (available: %s)", name, strings.Join(names, ", "))
+}
+
+// resolveInstallOptions combines the --profile selection with the command's flags.
+// Flags given explicitly on the command line always win over the profile's values.
+func resolveInstallOptions(c *cli.Context, projCfg *coreproject.Project) (installOptions, error) {
+	var profile coreproject.Profile
+	if name := c.String("profile"); name != "" {
+		var err error
+		profile, err = lookupProfile(projCfg, name)
+		if err != nil {
+			return installOptions{}, err
+		}
+	}
+
+	if c.Bool("keep-going") && c.Bool("fail-fast") {
+		return installOptions{}, fmt.Errorf("--keep-going and --fail-fast cannot be used together")
+	}
+
+	pick := func(flagName string, profileValue bool) bool {
+		if c.IsSet(flagName) {
+			return c.Bool(flagName)
+		}
+		return profileValue
+	}
+	var stats *runStats
+	if c.Bool("stats") {
+		stats = newRunStats()
+	}
+	return installOptions{
+		Force:   pick("force", profile.Force),
+		Verbose: pick("verbose", profile.Verbose) || logger.Verbose(),
+		NoPrune: pick("no-prune", profile.NoPrune),
+		Frozen:  pick("frozen", profile.Frozen),
+		Strict:  pick("strict", profile.Strict),
+		// --keep-going is the inverse of --fail-fast; either one overrides the profile.
+		FailFast:       pick("fail-fast", profile.FailFast) && !c.Bool("keep-going"),
+		AllowDowngrade: c.Bool("allow-downgrade"),
+		AllowOverwrite: c.Bool("allow-overwrite"),
+		DryRun:         c.Bool("dry-run"),
+		JSON:           c.Bool("json"),
+		TagFallback:    c.Bool("tag-fallback"),
+		Offline:        c.Bool("offline"),
+		ToolVersion:    c.App.Version,
+		FileSettings:   filemode.GlobalSettings(),
+		stats:          stats,
+	}, nil
+}
+
+// messages returns where human-readable messages go: stdout, or stderr with --json, so stdout
+// carries nothing but the plan.
+func (o installOptions) messages() io.Writer {
+	if o.JSON {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// logInstallOptions prints the effective settings of an install run in verbose mode.
+func logInstallOptions(c *cli.Context, opts installOptions) {
+	if profile := c.String("profile"); profile != "" {
+		logger.Progressf("Using install profile '%s'.", profile)
+	}
+	if opts.Force {
+		logger.Progressf("Force install/update enabled.")
+	}
+	if opts.Frozen {
+		logger.Progressf("Frozen lockfile enabled; almd-lock.toml will not be modified.")
+	}
+	if opts.FailFast {
+		logger.Progressf("Fail-fast enabled; the run stops and rolls back at the first failure.")
+	}
+}
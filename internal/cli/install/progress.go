@@ -0,0 +1,192 @@
+package install
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+	"github.com/nightconcept/almandine/internal/core/logger"
+	coreproject "github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/safepath"
+	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/nightconcept/almandine/internal/core/staging"
+)
+
+// progressFileName is the run journal, kept in the project's staging directory. almd-lock.toml is
+// only written at the end of a run, so each dependency a run finishes is appended to the journal
+// right away. If the run is killed before the lockfile is saved, the next install takes those
+// dependencies from the journal instead of resolving and downloading them again.
+const progressFileName = "install-progress.jsonl"
+
+// progressEntry is one finished dependency in the run journal.
+type progressEntry struct {
+	Name       string                `json:"name"`
+	Source     string                `json:"source"`      // The project.toml source it was installed from
+	FileSHA256 string                `json:"file_sha256"` // Hex digest of the file as written
+	Entry      lockfile.PackageEntry `json:"entry"`
+}
+
+// runProgress appends finished dependencies to the run journal and notices interrupts, so an
+// interrupted run stops between dependencies and still saves what it finished. Its methods do
+// nothing on a nil *runProgress.
+type runProgress struct {
+	file        *os.File
+	signals     chan os.Signal
+	interrupted atomic.Bool
+}
+
+// startProgress opens the run journal and starts watching for Ctrl-C and SIGTERM. The first
+// signal stops the run after the dependency being installed; a second one ends almd right away,
+// which the journal survives. A journal that cannot be opened only costs the ability to resume.
+func startProgress() *runProgress {
+	p := &runProgress{signals: make(chan os.Signal, 1)}
+	if dir, err := staging.Mkdir("."); err != nil {
+		logger.Debugf("cannot keep an install journal: %v", err)
+	} else if p.file, err = os.OpenFile(filepath.Join(dir, progressFileName), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644); err != nil {
+		logger.Debugf("cannot keep an install journal: %v", err)
+	}
+	signal.Notify(p.signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if _, ok := <-p.signals; ok {
+			signal.Stop(p.signals) // A second signal gets the default behavior
+			p.interrupted.Store(true)
+			_, _ = fmt.Fprintln(os.Stderr, "Interrupted; stopping after the current dependency. Interrupt again to quit now.")
+		}
+	}()
+	return p
+}
+
+// record appends a finished dependency to the journal and flushes it to disk.
+func (p *runProgress) record(dep *dependencyInstallState, entry lockfile.PackageEntry) {
+	if p == nil || p.file == nil {
+		return
+	}
+	line, err := json.Marshal(progressEntry{Name: dep.Name, Source: dep.ProjectTomlSource, FileSHA256: dep.FileSHA256, Entry: entry})
+	if err == nil {
+		_, err = p.file.Write(append(line, '\n'))
+	}
+	if err == nil {
+		err = p.file.Sync()
+	}
+	if err != nil {
+		logger.Debugf("could not record %s in the install journal: %v", dep.Name, err)
+	}
+}
+
+// stopped reports whether the run was interrupted.
+func (p *runProgress) stopped() bool {
+	return p != nil && p.interrupted.Load()
+}
+
+// stop closes the journal and restores the default signal handling.
+func (p *runProgress) stop() {
+	if p == nil {
+		return
+	}
+	signal.Stop(p.signals)
+	close(p.signals)
+	if p.file != nil {
+		_ = p.file.Close()
+	}
+}
+
+// clearProgress removes the run journal once almd-lock.toml holds everything it recorded.
+func clearProgress() {
+	err := os.Remove(filepath.Join(staging.Dir, progressFileName))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logger.Debugf("could not remove the install journal: %v", err)
+	}
+}
+
+// resumeProgress takes the dependencies an interrupted run finished from the run journal. Each
+// one whose source is unchanged and whose file still has the content the run wrote is locked in
+// lf and recorded in tx, so the lockfile saved at the end of this run includes it; it is then
+// resolved from that entry instead of asking the provider again. Frozen installs never resume,
+// since they must not change the lockfile, and neither do forced ones, which reinstall anyway.
+// It returns the names taken.
+func resumeProgress(projCfg *coreproject.Project, lf *lockfile.Lockfile, tx *lockfile.Tx, opts installOptions) map[string]bool {
+	if opts.Frozen || opts.Force {
+		return nil
+	}
+	entries, err := readProgress(filepath.Join(staging.Dir, progressFileName))
+	if err != nil {
+		logger.Debugf("could not read the install journal: %v", err)
+		return nil
+	}
+	resumed := map[string]bool{}
+	for _, e := range entries {
+		if !resumable(projCfg, e) {
+			continue
+		}
+		if lf.Package == nil {
+			lf.Package = make(map[string]lockfile.PackageEntry)
+		}
+		lf.Package[e.Name] = e.Entry
+		tx.Set(e.Name, e.Entry)
+		resumed[e.Name] = true
+	}
+	if len(resumed) > 0 {
+		_, _ = fmt.Fprintf(opts.messages(), "Resuming an interrupted install: %d dependenc(ies) were already installed.\n", len(resumed))
+	}
+	return resumed
+}
+
+// readProgress returns the journal's entries, the latest one per dependency. A missing journal
+// has none; lines that cannot be parsed are skipped.
+func readProgress(path string) (map[string]progressEntry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	entries := map[string]progressEntry{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e progressEntry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil && e.Name != "" {
+			entries[e.Name] = e
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// resumable reports whether a journal entry still describes the dependency: the manifest has
+// the same source, path and transform, the locked version resolves to the same URL, and the file
+// on disk is the one the interrupted run wrote.
+func resumable(projCfg *coreproject.Project, e progressEntry) bool {
+	dep, ok := projCfg.Dependencies[e.Name]
+	if !ok || dep.Source != e.Source || dep.Path != e.Entry.Path || dep.Transform != e.Entry.Transform || e.FileSHA256 == "" {
+		return false
+	}
+	parsed, err := source.ParseSourceURL(dep.Source)
+	if err != nil {
+		return false
+	}
+	if _, rawURL := lockedTarget(parsed, e.Entry); rawURL != e.Entry.Source {
+		return false
+	}
+	f, err := os.Open(safepath.LongPath(filepath.FromSlash(dep.Path)))
+	if err != nil {
+		return false
+	}
+	defer func() { _ = f.Close() }()
+	digest := sha256.New()
+	if _, err := io.Copy(digest, f); err != nil {
+		return false
+	}
+	return hex.EncodeToString(digest.Sum(nil)) == e.FileSHA256
+}
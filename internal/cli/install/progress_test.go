@@ -0,0 +1,40 @@
+package install
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/filemode"
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+)
+
+func TestExecuteInstallOperations_StopsWhenInterrupted(t *testing.T) {
+	progress := &runProgress{}
+	progress.interrupted.Store(true)
+	tx := lockfile.New().Begin()
+
+	installed, err := executeInstallOperations([]dependencyInstallState{{Name: "lib", ProjectTomlPath: "lib.lua"}}, tx, &outcome{}, nil, false, filemode.Settings{}, progress, nil, false)
+	require.NoError(t, err)
+	assert.Empty(t, installed, "no dependency is started after an interrupt")
+	assert.False(t, tx.Changed())
+}
+
+func TestReadProgress(t *testing.T) {
+	path := filepath.Join(t.TempDir(), progressFileName)
+	entries, err := readProgress(path)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "a missing journal has no entries")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"name":"lib","source":"s","file_sha256":"old","entry":{"Path":"lib.lua"}}
+not json
+{"name":"lib","source":"s","file_sha256":"new","entry":{"Path":"lib.lua"}}
+`), 0644))
+	entries, err = readProgress(path)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "new", entries["lib"].FileSHA256, "the latest entry per dependency wins")
+}
@@ -0,0 +1,106 @@
+package install
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/nightconcept/almandine/internal/core/filemode"
+	"github.com/nightconcept/almandine/internal/core/safepath"
+)
+
+// fileSnapshot is the state of a dependency file before the current run touched it.
+type fileSnapshot struct {
+	path        string
+	existed     bool
+	content     []byte
+	mode        os.FileMode
+	link        string   // Target of the symbolic link at path, for a dependency installed in link mode
+	createdDirs []string // Parent directories the run may create, deepest first
+}
+
+// rollback records dependency files before they are overwritten so a --fail-fast run can put
+// the project back the way it found it.
+type rollback struct {
+	snapshots []fileSnapshot
+}
+
+// snapshot saves the current state of path. Call it before the file is written.
+func (r *rollback) snapshot(path string) error {
+	snap := fileSnapshot{path: path}
+	if info, err := os.Lstat(safepath.LongPath(path)); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		if snap.link, err = os.Readlink(safepath.LongPath(path)); err != nil {
+			return fmt.Errorf("cannot back up '%s': %w", path, err)
+		}
+		snap.existed = true
+		r.snapshots = append(r.snapshots, snap)
+		return nil
+	}
+	info, err := os.Stat(safepath.LongPath(path))
+	switch {
+	case err == nil:
+		content, readErr := os.ReadFile(safepath.LongPath(path))
+		if readErr != nil {
+			return fmt.Errorf("cannot back up '%s': %w", path, readErr)
+		}
+		snap.existed, snap.content, snap.mode = true, content, info.Mode().Perm()
+	case errors.Is(err, fs.ErrNotExist):
+		for dir := filepath.Dir(path); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+			if _, statErr := os.Stat(safepath.LongPath(dir)); statErr == nil {
+				break
+			}
+			snap.createdDirs = append(snap.createdDirs, dir)
+		}
+	default:
+		return fmt.Errorf("cannot back up '%s': %w", path, err)
+	}
+	r.snapshots = append(r.snapshots, snap)
+	return nil
+}
+
+// snapshotDependency saves the current state of every file installing dep may write or remove.
+func (r *rollback) snapshotDependency(dep dependencyInstallState) error {
+	for _, path := range append(dep.filePaths(), dep.staleFilePaths()...) {
+		if err := r.snapshot(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restore undoes every recorded write, newest first. It returns the number of files restored
+// and a description of each one that could not be.
+func (r *rollback) restore() (restored int, failed []string) {
+	for i := len(r.snapshots) - 1; i >= 0; i-- {
+		snap := r.snapshots[i]
+		var err error
+		if snap.link != "" {
+			err = restoreLink(safepath.LongPath(snap.path), snap.link)
+		} else if snap.existed {
+			_ = removeLink(safepath.LongPath(snap.path))
+			err = filemode.Restore(safepath.LongPath(snap.path), snap.content, snap.mode)
+		} else if err = filemode.Remove(safepath.LongPath(snap.path)); errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s (%v)", snap.path, err))
+			continue
+		}
+		for _, dir := range snap.createdDirs {
+			_ = os.Remove(safepath.LongPath(dir)) // Only succeeds while the directory is empty
+		}
+		restored++
+	}
+	r.snapshots = nil
+	return restored, failed
+}
+
+// restoreLink puts back the symbolic link at path to target, replacing whatever the run wrote.
+func restoreLink(path, target string) error {
+	if err := filemode.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.Symlink(target, path)
+}
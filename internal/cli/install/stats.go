@@ -0,0 +1,69 @@
+package install
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nightconcept/almandine/internal/core/bytesize"
+	"github.com/nightconcept/almandine/internal/core/httpclient"
+)
+
+// Phases of an install run timed for --stats. The write phase printed is the install phase
+// less its downloads: transforms, writing the files and checking them.
+const (
+	phaseResolve  = "resolve"
+	phaseDownload = "download"
+	phaseInstall  = "install"
+)
+
+// runStats collects the network usage and phase timing of one install run for --stats. A nil
+// *runStats records nothing, so callers time their phases without checking for the flag.
+type runStats struct {
+	started time.Time
+	before  httpclient.Usage
+	phases  map[string]time.Duration
+}
+
+func newRunStats() *runStats {
+	httpclient.StartCounting()
+	return &runStats{started: time.Now(), before: httpclient.CurrentUsage(), phases: make(map[string]time.Duration)}
+}
+
+// time starts timing a phase and returns the function that stops it. Repeated timings of a
+// phase add up.
+func (s *runStats) time(phase string) func() {
+	if s == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		s.phases[phase] += time.Since(start)
+	}
+}
+
+// print writes the summary of the run to w.
+func (s *runStats) print(w io.Writer) {
+	if s == nil {
+		return
+	}
+	used := httpclient.CurrentUsage().Sub(s.before)
+	ratio := "n/a"
+	if lookups := used.CacheHits + used.CacheMisses; lookups > 0 {
+		ratio = fmt.Sprintf("%.0f%% (%d of %d)", 100*float64(used.CacheHits)/float64(lookups), used.CacheHits, lookups)
+	}
+	download := s.phases[phaseDownload]
+	write := max(s.phases[phaseInstall]-download, 0)
+	_, _ = fmt.Fprintf(w, "\nRun statistics:\n")
+	_, _ = fmt.Fprintf(w, "  Requests:         %d\n", used.Requests)
+	_, _ = fmt.Fprintf(w, "  Downloaded:       %s\n", bytesize.Format(used.Bytes))
+	_, _ = fmt.Fprintf(w, "  Cache hit ratio:  %s\n", ratio)
+	_, _ = fmt.Fprintf(w, "  Resolve:          %s\n", formatPhase(s.phases[phaseResolve]))
+	_, _ = fmt.Fprintf(w, "  Download:         %s\n", formatPhase(download))
+	_, _ = fmt.Fprintf(w, "  Write:            %s\n", formatPhase(write))
+	_, _ = fmt.Fprintf(w, "  Total:            %s\n", formatPhase(time.Since(s.started)))
+}
+
+func formatPhase(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
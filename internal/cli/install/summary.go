@@ -0,0 +1,45 @@
+package install
+
+import (
+	"fmt"
+
+	"github.com/nightconcept/almandine/internal/core/jobsummary"
+	"github.com/nightconcept/almandine/internal/core/warnings"
+)
+
+// writeJobSummary adds the dependencies a run installed, and those that failed or were skipped,
+// to the GitHub Actions job summary. Failing to write it is reported as a warning.
+func writeJobSummary(installed []dependencyInstallState, out *outcome) {
+	if !jobsummary.Enabled() {
+		return
+	}
+	table := jobsummary.Table{
+		Title:  "almd install",
+		Header: []string{"Dependency", "Status", "Version", "Path", "Reason"},
+	}
+	for _, dep := range installed {
+		table.Rows = append(table.Rows, []string{dep.Name, "installed", summaryVersion(dep), dep.ProjectTomlPath, dep.ActionReason})
+	}
+	for _, p := range out.failures {
+		table.Rows = append(table.Rows, []string{p.Name, "failed", "", "", fmt.Sprintf("exit code %d", p.Code)})
+	}
+	for _, p := range out.warnings {
+		table.Rows = append(table.Rows, []string{p.Name, "skipped", "", "", fmt.Sprintf("exit code %d", p.Code)})
+	}
+	table.Note = fmt.Sprintf("%d installed, %d failed, %d skipped.", len(installed), len(out.failures), len(out.warnings))
+	if len(table.Rows) == 0 {
+		table.Note = "All targeted dependencies are already up-to-date."
+	}
+	if err := jobsummary.Write(table); err != nil {
+		warnings.Printf("could not write the job summary: %v", err)
+	}
+}
+
+// summaryVersion names what was installed: the short commit, or the content hash for sources
+// without commits.
+func summaryVersion(dep dependencyInstallState) string {
+	if isCommitSHARegex.MatchString(dep.TargetCommitHash) {
+		return shortSHA(dep.TargetCommitHash)
+	}
+	return "sha256:" + shortSHA(dep.UpstreamSHA256)
+}
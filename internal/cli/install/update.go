@@ -0,0 +1,265 @@
+package install
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/cli/completion"
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+// UpdateCmd returns the 'update' command: an install that can put what it changed on a branch,
+// push it and open a pull request, so a scheduled CI job keeps a project's dependencies current.
+func UpdateCmd() *cli.Command {
+	return &cli.Command{
+		Name:         "update",
+		Usage:        "Install dependency updates and optionally commit them to a branch and open a pull request",
+		ArgsUsage:    "[dependency_names...]",
+		BashComplete: completion.Dependencies,
+		Flags: append(installFlags(),
+			&cli.StringFlag{Name: "branch", Usage: "Commit the changes to `BRANCH`, created (or reset) from the current commit"},
+			&cli.BoolFlag{Name: "push", Usage: "Push the branch to the remote (with --branch)"},
+			&cli.BoolFlag{Name: "pr", Usage: "Open a GitHub pull request for the pushed branch (with --push; needs a GitHub token)"},
+			&cli.StringFlag{Name: "remote", Value: "origin", Usage: "Remote to push to and open the pull request in"},
+			&cli.StringFlag{Name: "base", Usage: "Branch the pull request merges into (default: the branch checked out before the update)"},
+		),
+		Action: runUpdate,
+	}
+}
+
+// lockChange is one dependency whose lock entry an update added, changed or removed. From is
+// empty for an added dependency and To for a removed one.
+type lockChange struct {
+	Name, Path, Source string
+	From, To           string
+}
+
+func checkUpdateFlags(c *cli.Context) error {
+	switch {
+	case c.Bool("push") && c.String("branch") == "":
+		return cli.Exit("Error: --push needs --branch", exitcode.Usage)
+	case c.Bool("pr") && !c.Bool("push"):
+		return cli.Exit("Error: --pr needs --push", exitcode.Usage)
+	case c.String("branch") != "" && c.Bool("dry-run"):
+		return cli.Exit("Error: --branch cannot be used with --dry-run", exitcode.Usage)
+	}
+	return nil
+}
+
+func runUpdate(c *cli.Context) error {
+	if err := checkUpdateFlags(c); err != nil {
+		return err
+	}
+	branch := c.String("branch")
+	before, err := lockfile.Load(".")
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error loading lockfile: %v", err), 1)
+	}
+	base := c.String("base")
+	if branch != "" && base == "" {
+		if base, err = gitOutput("rev-parse", "--abbrev-ref", "HEAD"); err != nil {
+			return cli.Exit(fmt.Sprintf("Error: --branch needs a git repository: %v", err), 1)
+		}
+		if base == "HEAD" {
+			return cli.Exit("Error: HEAD is detached, so the base branch is unknown; pass it with --base.", 1)
+		}
+	}
+
+	if err := runInstall(c, false); err != nil || branch == "" {
+		return err
+	}
+	after, err := lockfile.Load(".")
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error loading lockfile: %v", err), 1)
+	}
+	changes := lockChanges(before, after)
+	if len(changes) == 0 {
+		fmt.Println("No dependency changed; no branch was created.")
+		return nil
+	}
+	if err := commitUpdate(branch, changes); err != nil {
+		return cli.Exit(fmt.Sprintf("Error committing the update: %v", err), 1)
+	}
+	if !c.Bool("push") {
+		return nil
+	}
+	return pushAndOpenPR(c.String("remote"), branch, base, changes, c.Bool("pr"))
+}
+
+// lockChanges compares the lockfile before and after an update, in name order.
+func lockChanges(before, after *lockfile.Lockfile) []lockChange {
+	var changes []lockChange
+	for name, entry := range after.Package {
+		old, had := before.Package[name]
+		if had && old.Hash == entry.Hash && old.Path == entry.Path {
+			continue
+		}
+		change := lockChange{Name: name, Path: entry.Path, Source: entry.Source, To: lockVersion(entry.Hash)}
+		if had {
+			change.From = lockVersion(old.Hash)
+		}
+		changes = append(changes, change)
+	}
+	for name, old := range before.Package {
+		if _, kept := after.Package[name]; !kept {
+			changes = append(changes, lockChange{Name: name, Path: old.Path, Source: old.Source, From: lockVersion(old.Hash)})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+// lockVersion shortens a lock hash ("commit:<sha>" or "sha256:<hex>") for messages.
+func lockVersion(hash string) string {
+	if sha, ok := strings.CutPrefix(hash, "commit:"); ok {
+		return shortSHA(sha)
+	}
+	if sum, ok := strings.CutPrefix(hash, "sha256:"); ok {
+		return "sha256:" + shortSHA(sum)
+	}
+	return hash
+}
+
+// describeChange is one line about a change, e.g. "Update json from 0a1b2c3d4e5f to 9f8e7d6c5b4a".
+func describeChange(change lockChange) string {
+	switch {
+	case change.From == "":
+		return fmt.Sprintf("Add %s at %s", change.Name, change.To)
+	case change.To == "":
+		return fmt.Sprintf("Remove %s", change.Name)
+	default:
+		return fmt.Sprintf("Update %s from %s to %s", change.Name, change.From, change.To)
+	}
+}
+
+// updateTitle is the commit subject and pull request title for changes.
+func updateTitle(changes []lockChange) string {
+	if len(changes) == 1 {
+		return describeChange(changes[0])
+	}
+	return fmt.Sprintf("Update %d dependencies", len(changes))
+}
+
+// commitUpdate switches to branch, created or reset at the current commit with the working
+// tree kept, and commits the manifest, the lockfile and the files of changes, including the
+// deletion of files removed along with their dependencies.
+func commitUpdate(branch string, changes []lockChange) error {
+	if _, err := gitOutput("switch", "-C", branch); err != nil {
+		return err
+	}
+	files := []string{config.ManifestPath("."), lockfile.LockfileName}
+	for _, change := range changes {
+		if _, err := os.Stat(change.Path); err == nil || isTracked(change.Path) {
+			files = append(files, change.Path)
+		}
+	}
+	if _, err := gitOutput(append([]string{"add", "-A", "--"}, files...)...); err != nil {
+		return err
+	}
+	var message strings.Builder
+	message.WriteString(updateTitle(changes) + "\n\n")
+	for _, change := range changes {
+		message.WriteString("- " + describeChange(change) + "\n")
+	}
+	if _, err := gitOutput("commit", "-m", message.String()); err != nil {
+		return err
+	}
+	fmt.Printf("Committed %d dependency change(s) to branch '%s'.\n", len(changes), branch)
+	return nil
+}
+
+// isTracked reports whether git tracks path, so that its deletion can be staged; an untracked
+// path that does not exist would fail "git add".
+func isTracked(path string) bool {
+	out, err := gitOutput("ls-files", "--", path)
+	return err == nil && out != ""
+}
+
+// pushAndOpenPR pushes branch to remote, replacing an earlier update on it, and with openPR
+// opens a pull request against base.
+func pushAndOpenPR(remote, branch, base string, changes []lockChange, openPR bool) error {
+	if _, err := gitOutput("push", "--force-with-lease", "--set-upstream", remote, branch); err != nil {
+		return cli.Exit(fmt.Sprintf("Error pushing branch '%s': %v", branch, err), 1)
+	}
+	fmt.Printf("Pushed branch '%s' to %s.\n", branch, remote)
+	if !openPR {
+		return nil
+	}
+	remoteURL, err := gitOutput("remote", "get-url", remote)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error reading the URL of remote '%s': %v", remote, err), 1)
+	}
+	owner, repo, err := githubRepoFromRemote(remoteURL)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), exitcode.Usage)
+	}
+	prURL, err := source.CreatePullRequest(owner, repo, source.NewPullRequest{
+		Title: updateTitle(changes),
+		Head:  branch,
+		Base:  base,
+		Body:  pullRequestBody(changes),
+	})
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error opening a pull request: %v", err), 1)
+	}
+	fmt.Printf("Opened pull request %s\n", prURL)
+	return nil
+}
+
+// pullRequestBody describes changes as a Markdown table.
+func pullRequestBody(changes []lockChange) string {
+	cell := strings.NewReplacer("|", `\|`, "\n", " ").Replace
+	var b strings.Builder
+	b.WriteString("This pull request was opened by `almd update`.\n\n")
+	b.WriteString("| Dependency | From | To | Path | Source |\n|---|---|---|---|---|\n")
+	for _, change := range changes {
+		from, to := change.From, change.To
+		if from == "" {
+			from = "(new)"
+		}
+		if to == "" {
+			to = "(removed)"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n", cell(change.Name), from, to, cell(change.Path), cell(change.Source))
+	}
+	return b.String()
+}
+
+// githubRepoFromRemote returns the owner and repository of a GitHub remote URL, given over
+// HTTPS (https://github.com/owner/repo.git) or SSH (git@github.com:owner/repo.git).
+func githubRepoFromRemote(remoteURL string) (owner, repo string, err error) {
+	repoPath := ""
+	if u, parseErr := url.Parse(remoteURL); parseErr == nil && u.Host != "" {
+		repoPath = u.Path
+	} else if _, after, ok := strings.Cut(remoteURL, ":"); ok {
+		repoPath = after
+	}
+	parts := strings.Split(strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git"), "/")
+	if len(parts) < 2 || parts[len(parts)-2] == "" || parts[len(parts)-1] == "" {
+		return "", "", fmt.Errorf("cannot tell the GitHub repository of remote URL '%s'", remoteURL)
+	}
+	return parts[len(parts)-2], parts[len(parts)-1], nil
+}
+
+// gitOutput runs git in the working directory and returns its trimmed standard output.
+func gitOutput(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,90 @@
+package list
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+
+	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/nightconcept/almandine/internal/core/theme"
+)
+
+// groupModes maps each --group-by mode to the function returning the groups a dependency
+// belongs to. A dependency with several labels is listed under each of them.
+var groupModes = map[string]func(dependencyDisplayInfo) []string{
+	"dir":      func(dep dependencyDisplayInfo) []string { return []string{path.Dir(dep.ProjectPath)} },
+	"group":    labelGroups,
+	"provider": func(dep dependencyDisplayInfo) []string { return []string{providerGroup(dep.ProjectSource)} },
+}
+
+// noLabelGroup collects the dependencies without labels under --group-by group.
+const noLabelGroup = "(no label)"
+
+// validateGroupBy checks a --group-by value and the flags it cannot be combined with.
+func validateGroupBy(mode string, porcelain, tree bool) error {
+	if mode == "" {
+		return nil
+	}
+	if _, ok := groupModes[mode]; !ok {
+		return fmt.Errorf("unknown --group-by '%s' (use dir, group or provider)", mode)
+	}
+	if porcelain || tree {
+		return fmt.Errorf("--group-by cannot be combined with --porcelain or --tree")
+	}
+	return nil
+}
+
+func labelGroups(dep dependencyDisplayInfo) []string {
+	if len(dep.Labels) == 0 {
+		return []string{noLabelGroup}
+	}
+	return dep.Labels
+}
+
+// providerGroup names the provider of a source, or its host when it is a plain URL or no
+// provider recognizes it.
+func providerGroup(rawSource string) string {
+	if info, err := source.ParseSourceURL(rawSource); err == nil && info.Provider != source.ProviderURL {
+		return info.Provider
+	}
+	if u, err := url.Parse(rawSource); err == nil && u.Hostname() != "" {
+		return u.Hostname()
+	}
+	return "(unknown)"
+}
+
+// groupDependencies sorts displayDeps into the groups of mode, returning the group names in
+// order and the dependencies of each, kept in name order.
+func groupDependencies(displayDeps []dependencyDisplayInfo, mode string) ([]string, map[string][]dependencyDisplayInfo) {
+	groupsOf := groupModes[mode]
+	groups := make(map[string][]dependencyDisplayInfo)
+	for _, dep := range displayDeps {
+		for _, group := range groupsOf(dep) {
+			groups[group] = append(groups[group], dep)
+		}
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, groups
+}
+
+// printGroupedDependencies prints displayDeps under a header per group of mode, each with its
+// subtotal.
+func printGroupedDependencies(displayDeps []dependencyDisplayInfo, mode string, outdated bool) {
+	groupHeaderColor := theme.SprintFunc(theme.Header)
+	names, groups := groupDependencies(displayDeps, mode)
+	for i, name := range names {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s (%d)\n", groupHeaderColor(name), len(groups[name]))
+		for _, dep := range groups[name] {
+			fmt.Print("  ")
+			printDependencyLine(dep, outdated)
+		}
+	}
+}
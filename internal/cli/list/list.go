@@ -4,13 +4,18 @@ package list
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
-	"github.com/fatih/color"
 	"github.com/urfave/cli/v2"
 
 	"github.com/nightconcept/almandine/internal/core/config"
 	"github.com/nightconcept/almandine/internal/core/lockfile"
 	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/theme"
+	"github.com/nightconcept/almandine/internal/core/warnings"
 )
 
 // dependencyDisplayInfo aggregates dependency information for display formatting.
@@ -22,7 +27,12 @@ type dependencyDisplayInfo struct {
 	LockedHash     string // From lockfile
 	FileExists     bool
 	IsLocked       bool
-	FileStatusInfo string // Human-readable status
+	FileStatusInfo string    // Human-readable status
+	Labels         []string  // From project.toml, for --group-by group
+	Freshness      freshness // Remote freshness, only filled in with --outdated
+	ReviewDue      string    // The review_after date from project.toml once it has passed
+	Install        string    // Install mode from project.toml; empty for copy
+	FileCount      int       // Number of files of a directory dependency; 0 for a single file
 }
 
 // ListCmd returns a cli.Command that displays all project dependencies and their status.
@@ -31,36 +41,132 @@ func ListCmd() *cli.Command {
 		Name:    "list",
 		Aliases: []string{"ls"},
 		Usage:   "Displays project dependencies and their status.",
+		Flags: append([]cli.Flag{
+			&cli.BoolFlag{Name: "outdated", Usage: "Check whether each dependency is behind or ahead of its upstream (results are cached for an hour)"},
+		}, listFlags()...),
 		Action: func(c *cli.Context) error {
-			proj, lf, err := loadListCmdData(".")
-			if err != nil {
-				return cli.Exit(err.Error(), 1)
-			}
+			return runList(c, c.Bool("outdated") || c.IsSet("snapshot") || c.IsSet("from-snapshot"))
+		},
+	}
+}
 
-			displayDeps, err := collectDependencyDisplayInfo(proj, lf)
-			if err != nil {
-				// Errors from collectDependencyDisplayInfo are warnings, print to stderr and continue
-				fmt.Fprintf(os.Stderr, "Warning during dependency collection: %v\n", err)
-			}
+// OutdatedCmd returns the 'outdated' command, shorthand for 'list --outdated'.
+func OutdatedCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "outdated",
+		Usage: "Displays dependencies that are behind or ahead of their upstream (same as 'list --outdated').",
+		Flags: listFlags(),
+		Action: func(c *cli.Context) error {
+			return runList(c, true)
+		},
+	}
+}
 
-			wd, err := os.Getwd()
-			if err != nil {
-				wd = "." // Fallback to current directory if Getwd fails
-			}
+// listFlags are the flags shared by 'list' and 'outdated'.
+func listFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{Name: "porcelain", Usage: "Print stable, tab-separated output for scripts"},
+		&cli.BoolFlag{Name: "refresh", Usage: "With --outdated, ignore cached results and ask the providers again"},
+		&cli.StringFlag{Name: "snapshot", Usage: "With --outdated, ask the providers and save their answers to `FILE` for --from-snapshot"},
+		&cli.StringFlag{Name: "from-snapshot", Usage: "With --outdated, answer from a snapshot in `FILE` instead of the providers (no network)"},
+		&cli.BoolFlag{Name: "tree", Usage: "Show each dependency with its files and their status as a tree"},
+		&cli.StringSliceFlag{Name: "label", Usage: "Only list dependencies with this label (repeat for any of several)"},
+		&cli.StringFlag{Name: "group-by", Usage: "Group dependencies by `MODE`: dir (install directory), group (label) or provider, with a count per group"},
+	}
+}
 
-			return printDefaultOutput(proj, displayDeps, wd)
-		},
+// runList lists the project's dependencies, checking their upstream freshness when outdated is set.
+func runList(c *cli.Context, outdated bool) error {
+	if c.Bool("tree") && c.Bool("porcelain") {
+		return cli.Exit("Error: --tree cannot be combined with --porcelain", 1)
+	}
+	if err := validateGroupBy(c.String("group-by"), c.Bool("porcelain"), c.Bool("tree")); err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	proj, lf, err := loadListCmdData(".")
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+	if err := filterByLabels(proj, c.StringSlice("label")); err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+
+	displayDeps := collectDependencyDisplayInfo(proj, lf)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		wd = "." // Fallback to current directory if Getwd fails
+	}
+	for i := range displayDeps {
+		displayDeps[i].ProjectPath = projectRelativePath(wd, displayDeps[i].ProjectPath)
+	}
+	if outdated {
+		if err := checkOutdated(c, displayDeps); err != nil {
+			return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+		}
+	}
+
+	if c.Bool("porcelain") {
+		printPorcelainOutput(displayDeps, outdated)
+		return nil
+	}
+	if c.Bool("tree") {
+		printTreeOutput(os.Stdout, proj, displayDeps, wd, outdated)
+		return nil
+	}
+	return printDefaultOutput(proj, displayDeps, wd, outdated, c.String("group-by"))
+}
+
+// checkOutdated fills in the freshness of displayDeps for --outdated: from the providers
+// (through the freshness cache), while recording a --snapshot, or from a --from-snapshot file.
+// Except with --from-snapshot, which stays offline, it also warns about deprecated and yanked
+// dependencies.
+func checkOutdated(c *cli.Context, displayDeps []dependencyDisplayInfo) error {
+	switch {
+	case c.IsSet("snapshot") && c.IsSet("from-snapshot"):
+		return fmt.Errorf("--snapshot and --from-snapshot cannot be used together")
+	case c.IsSet("from-snapshot"):
+		snap, err := loadSnapshot(c.String("from-snapshot"))
+		if err != nil {
+			return err
+		}
+		annotateFreshness(displayDeps, snapshotUpstream{snap}, nil, false)
+		return nil
+	case c.IsSet("snapshot"):
+		// Every dependency is checked so the snapshot covers all of them.
+		snap := newSnapshot()
+		annotateFreshness(displayDeps, recordingUpstream{snap}, nil, true)
+		warnAdvisories(displayDeps)
+		return snap.save(c.String("snapshot"))
+	default:
+		annotateFreshness(displayDeps, liveUpstream{}, loadFreshnessCache(), c.Bool("refresh"))
+		warnAdvisories(displayDeps)
+		return nil
 	}
 }
 
+// filterByLabels drops the dependencies of proj that carry none of labels.
+func filterByLabels(proj *project.Project, labels []string) error {
+	names, err := project.SelectByLabels(proj.Dependencies, nil, labels)
+	if err != nil || len(labels) == 0 {
+		return err
+	}
+	selected := make(map[string]project.Dependency, len(names))
+	for _, name := range names {
+		selected[name] = proj.Dependencies[name]
+	}
+	proj.Dependencies = selected
+	return nil
+}
+
 // loadListCmdData loads the project.toml and almd-lock.toml files.
 func loadListCmdData(projectDir string) (*project.Project, *lockfile.Lockfile, error) {
 	proj, err := config.LoadProjectToml(projectDir)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, nil, fmt.Errorf("%s not found in %s, no project configuration loaded", config.ProjectTomlName, projectDir)
+			return nil, nil, fmt.Errorf("%s not found in %s, no project configuration loaded", filepath.Base(config.ManifestPath(projectDir)), projectDir)
 		}
-		return nil, nil, fmt.Errorf("loading %s from %s: %w", config.ProjectTomlName, projectDir, err)
+		return nil, nil, fmt.Errorf("loading %s from %s: %w", config.ManifestName(), projectDir, err)
 	}
 
 	lf, err := lockfile.Load(projectDir)
@@ -78,16 +184,22 @@ func loadListCmdData(projectDir string) (*project.Project, *lockfile.Lockfile, e
 	return proj, lf, nil
 }
 
-// collectDependencyDisplayInfo gathers information for each dependency.
-func collectDependencyDisplayInfo(proj *project.Project, lf *lockfile.Lockfile) ([]dependencyDisplayInfo, error) {
+// collectDependencyDisplayInfo gathers information for each dependency. Files whose status
+// cannot be checked are reported as warnings.
+func collectDependencyDisplayInfo(proj *project.Project, lf *lockfile.Lockfile) []dependencyDisplayInfo {
 	var displayDeps []dependencyDisplayInfo
-	var collectionErrors error // To accumulate non-fatal errors
 
 	for name, depDetails := range proj.Dependencies {
 		info := dependencyDisplayInfo{
 			Name:          name,
 			ProjectSource: depDetails.Source,
 			ProjectPath:   depDetails.Path,
+			Labels:        depDetails.Labels,
+			Install:       depDetails.Install,
+			FileCount:     len(depDetails.Files),
+		}
+		if depDetails.ReviewDue(time.Now()) {
+			info.ReviewDue = depDetails.ReviewAfter
 		}
 
 		if lockEntry, ok := lf.Package[name]; ok {
@@ -116,29 +228,22 @@ func collectDependencyDisplayInfo(proj *project.Project, lf *lockfile.Lockfile)
 			} else {
 				info.FileStatusInfo = "error checking file"
 			}
-			// Accumulate error instead of printing directly
-			err := fmt.Errorf("could not check status of %s: %w", depDetails.Path, statErr)
-			if collectionErrors == nil {
-				collectionErrors = err
-			} else {
-				collectionErrors = fmt.Errorf("%v; %w", collectionErrors, err)
-			}
+			warnings.Printf("Could not check status of %s: %v", depDetails.Path, statErr)
 		}
 		displayDeps = append(displayDeps, info)
 	}
-	return displayDeps, collectionErrors
+	sort.Slice(displayDeps, func(i, j int) bool { return displayDeps[i].Name < displayDeps[j].Name })
+	return displayDeps
 }
 
 // printDefaultOutput formats and prints the dependencies to standard output.
-func printDefaultOutput(proj *project.Project, displayDeps []dependencyDisplayInfo, projectRootPath string) error {
-	// Colors chosen for consistency with common terminal themes and accessibility:
-	projectNameColor := color.New(color.FgMagenta, color.Bold, color.Underline).SprintFunc()
-	projectVersionColor := color.New(color.FgMagenta).SprintFunc()
-	projectPathColor := color.New(color.FgHiBlack, color.Bold, color.Underline).SprintFunc()
-	dependenciesHeaderColor := color.New(color.FgCyan, color.Bold).SprintFunc()
-	depNameColor := color.New(color.FgWhite).SprintFunc()
-	depHashColor := color.New(color.FgYellow).SprintFunc()
-	depPathColor := color.New(color.FgHiBlack).SprintFunc()
+// With outdated, each line starts with a freshness glyph and a summary line follows.
+// With groupBy, dependencies are listed under a header per group; see printGroupedDependencies.
+func printDefaultOutput(proj *project.Project, displayDeps []dependencyDisplayInfo, projectRootPath string, outdated bool, groupBy string) error {
+	projectNameColor := theme.SprintFunc(theme.ProjectName)
+	projectVersionColor := theme.SprintFunc(theme.ProjectVersion)
+	projectPathColor := theme.SprintFunc(theme.ProjectPath)
+	dependenciesHeaderColor := theme.SprintFunc(theme.Header)
 
 	fmt.Printf("%s@%s %s\n\n", projectNameColor(proj.Package.Name),
 		projectVersionColor(proj.Package.Version),
@@ -154,20 +259,104 @@ func printDefaultOutput(proj *project.Project, displayDeps []dependencyDisplayIn
 		return nil
 	}
 
-	for _, dep := range displayDeps {
-		lockedHash := "not locked"
-		if dep.IsLocked && dep.LockedHash != "" {
-			lockedHash = dep.LockedHash
-		} else if dep.IsLocked && dep.LockedHash == "" {
-			lockedHash = "locked (no hash)"
+	if groupBy != "" {
+		printGroupedDependencies(displayDeps, groupBy, outdated)
+	} else {
+		for _, dep := range displayDeps {
+			printDependencyLine(dep, outdated)
 		}
+	}
+	if outdated {
+		fmt.Printf("\n%s\n", freshnessSummary(displayDeps))
+		writeOutdatedJobSummary(displayDeps)
+	}
+	return nil
+}
 
-		// Potentially include dep.FileStatusInfo if it's relevant for default output
-		// For now, keeping it simple as per original output.
-		// If dep.FileStatusInfo is not empty, it could be appended or shown.
-		// Example: fmt.Printf("%s %s %s (%s)\n", ...)
+// printDependencyLine prints the "name hash path" line of dep, with its install mode unless it is
+// copied, led by its freshness glyph and followed by the newer upstream version and any overdue
+// review with outdated.
+func printDependencyLine(dep dependencyDisplayInfo, outdated bool) {
+	depNameColor := theme.SprintFunc(theme.DepName)
+	depHashColor := theme.SprintFunc(theme.DepHash)
+	depPathColor := theme.SprintFunc(theme.DepPath)
 
-		fmt.Printf("%s %s %s\n", depNameColor(dep.Name), depHashColor(lockedHash), depPathColor(dep.ProjectPath))
+	lockedHash := "not locked"
+	if dep.IsLocked && dep.LockedHash != "" {
+		lockedHash = dep.LockedHash
+	} else if dep.IsLocked && dep.LockedHash == "" {
+		lockedHash = "locked (no hash)"
+	}
+
+	depPath := depPathColor(dep.ProjectPath)
+	if mode := (project.Dependency{Install: dep.Install}).InstallMode(); mode != project.InstallCopy {
+		depPath += " (" + mode + ")"
+	}
+	if dep.FileCount > 0 {
+		depPath += fmt.Sprintf(" (%d files)", dep.FileCount)
+	}
+	if !outdated {
+		fmt.Printf("%s %s %s\n", depNameColor(dep.Name), depHashColor(lockedHash), depPath)
+		return
+	}
+	latest := ""
+	if dep.Freshness.Latest != "" {
+		latest = fmt.Sprintf(" (%s: %s)", dep.Freshness.Status, dep.Freshness.Latest)
+	}
+	if dep.ReviewDue != "" {
+		latest += fmt.Sprintf(" [review due since %s]", dep.ReviewDue)
+	}
+	fmt.Printf("%s %s %s %s%s\n", freshnessGlyph(dep.Freshness.Status), depNameColor(dep.Name), depHashColor(lockedHash), depPath, latest)
+}
+
+// projectRelativePath renders a dependency path relative to the project root with forward
+// slashes, so output is identical across machines and platforms. Absolute paths outside the
+// project are returned unchanged apart from slash normalization.
+func projectRelativePath(projectRoot, depPath string) string {
+	if filepath.IsAbs(depPath) {
+		if rel, err := filepath.Rel(projectRoot, depPath); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			depPath = rel
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(depPath))
+}
+
+// porcelainValue substitutes "-" for empty fields so every porcelain line has the same column count.
+func porcelainValue(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// printPorcelainOutput prints one line per dependency, sorted by name, with tab-separated columns:
+//
+//	<name> <locked|unlocked> <present|missing|error> <hash> <path> <source>
+//
+// Paths are relative to the project root and use forward slashes; empty fields are printed as
+// "-". There is no header and no color. This format is a stable interface for scripts: columns
+// may be appended in future releases, but existing columns will not change meaning or order.
+//
+// With --outdated two columns are appended: the freshness status (current, behind, ahead,
+// pinned or unknown) and the newest upstream version when behind or ahead.
+func printPorcelainOutput(displayDeps []dependencyDisplayInfo, outdated bool) {
+	for _, dep := range displayDeps {
+		lockState := "unlocked"
+		if dep.IsLocked {
+			lockState = "locked"
+		}
+		fileState := "present"
+		if !dep.FileExists {
+			fileState = "missing"
+			if strings.Contains(dep.FileStatusInfo, "error") {
+				fileState = "error"
+			}
+		}
+		line := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s",
+			dep.Name, lockState, fileState, porcelainValue(dep.LockedHash), porcelainValue(dep.ProjectPath), porcelainValue(dep.ProjectSource))
+		if outdated {
+			line += fmt.Sprintf("\t%s\t%s", dep.Freshness.Status, porcelainValue(dep.Freshness.Latest))
+		}
+		_, _ = fmt.Fprintln(os.Stdout, line)
 	}
-	return nil
 }
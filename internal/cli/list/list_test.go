@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/jobsummary"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/urfave/cli/v2"
@@ -20,6 +21,7 @@ import (
 func setupListTestEnvironment(t *testing.T, projectTomlContent string, lockfileContent string, depFiles map[string]string) string {
 	t.Helper()
 	tempDir := t.TempDir()
+	t.Setenv(jobsummary.Env, "") // Keep CI runs of the tests out of the real job summary
 
 	if projectTomlContent != "" {
 		projectTomlPath := filepath.Join(tempDir, config.ProjectTomlName)
@@ -75,6 +77,7 @@ func runListCommand(t *testing.T, testDir string, appArgs ...string) (string, er
 	app := &cli.App{
 		Commands: []*cli.Command{
 			ListCmd(),
+			OutdatedCmd(),
 		},
 		// ExitErrHandler prevents os.Exit during tests while still capturing errors
 		ExitErrHandler: func(context *cli.Context, err error) {
@@ -372,3 +375,116 @@ hash = "%s"
 	require.NoError(t, err)
 	assert.Equal(t, strings.TrimSpace(expectedOutput), strings.TrimSpace(output), "Output of 'almd ls' should match expected 'almd list' output")
 }
+
+// Tests the script-friendly --porcelain output
+func TestListCommand_Porcelain(t *testing.T) {
+	projectTomlContent := `
+[package]
+name = "porcelain-project"
+version = "1.0.0"
+
+[dependencies.zeta]
+source = "github:user/repo/zeta.lua@main"
+path = "./libs/zeta.lua"
+
+[dependencies.alpha]
+source = "github:user/repo/alpha.lua@v1"
+path = "libs/alpha.lua"
+`
+	lockfileContent := `
+api_version = "1"
+[package.alpha]
+source = "https://raw.githubusercontent.com/user/repo/v1/alpha.lua"
+path = "libs/alpha.lua"
+hash = "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+`
+	tempDir := setupListTestEnvironment(t, projectTomlContent, lockfileContent, map[string]string{
+		"libs/alpha.lua": "return {}",
+	})
+
+	output, err := runListCommand(t, tempDir, "list", "--porcelain")
+	require.NoError(t, err)
+
+	expected := "alpha\tlocked\tpresent\tsha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855\tlibs/alpha.lua\tgithub:user/repo/alpha.lua@v1\n" +
+		"zeta\tunlocked\tmissing\t-\tlibs/zeta.lua\tgithub:user/repo/zeta.lua@main\n"
+	assert.Equal(t, expected, output)
+	assert.NotContains(t, output, tempDir, "porcelain output must not include absolute project paths")
+}
+
+func TestListCommand_LabelFilter(t *testing.T) {
+	projectTomlContent := `
+[package]
+name = "label-project"
+version = "1.0.0"
+
+[dependencies.button]
+source = "github:user/repo/button.lua@main"
+path = "libs/button.lua"
+labels = ["ui"]
+
+[dependencies.json]
+source = "github:user/repo/json.lua@main"
+path = "libs/json.lua"
+labels = ["thirdparty"]
+`
+	tempDir := setupListTestEnvironment(t, projectTomlContent, "", nil)
+
+	output, err := runListCommand(t, tempDir, "list", "--porcelain", "--label", "ui")
+	require.NoError(t, err)
+	assert.Equal(t, "button\tunlocked\tmissing\t-\tlibs/button.lua\tgithub:user/repo/button.lua@main\n", output)
+
+	_, err = runListCommand(t, tempDir, "list", "--label", "docs")
+	assert.ErrorContains(t, err, "no dependencies are labeled 'docs'")
+}
+
+func TestProjectRelativePath(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator), "work", "proj")
+	assert.Equal(t, "libs/a.lua", projectRelativePath(root, filepath.Join("libs", "a.lua")))
+	assert.Equal(t, "libs/a.lua", projectRelativePath(root, "./libs/a.lua"))
+	assert.Equal(t, "libs/a.lua", projectRelativePath(root, filepath.Join(root, "libs", "a.lua")))
+	outside := filepath.Join(string(filepath.Separator), "elsewhere", "a.lua")
+	assert.Equal(t, filepath.ToSlash(outside), projectRelativePath(root, outside))
+}
+
+func TestListCommand_GroupBy(t *testing.T) {
+	projectTomlContent := `
+[package]
+name = "group-project"
+version = "1.0.0"
+
+[dependencies.button]
+source = "github:user/repo/button.lua@main"
+path = "libs/ui/button.lua"
+labels = ["ui", "thirdparty"]
+
+[dependencies.json]
+source = "github:user/repo/json.lua@main"
+path = "libs/json.lua"
+labels = ["thirdparty"]
+
+[dependencies.util]
+source = "https://example.com/util.lua"
+path = "libs/util.lua"
+`
+	tempDir := setupListTestEnvironment(t, projectTomlContent, "", nil)
+
+	output, err := runListCommand(t, tempDir, "list", "--group-by", "dir")
+	require.NoError(t, err)
+	assert.Contains(t, output, "libs (2)\n  json not locked libs/json.lua\n  util not locked libs/util.lua\n\nlibs/ui (1)\n  button not locked libs/ui/button.lua\n")
+
+	output, err = runListCommand(t, tempDir, "list", "--group-by", "group")
+	require.NoError(t, err)
+	assert.Contains(t, output, "(no label) (1)\n  util")
+	assert.Contains(t, output, "thirdparty (2)\n  button not locked libs/ui/button.lua\n  json")
+	assert.Contains(t, output, "ui (1)\n  button")
+
+	output, err = runListCommand(t, tempDir, "list", "--group-by", "provider")
+	require.NoError(t, err)
+	assert.Contains(t, output, "example.com (1)\n  util")
+	assert.Contains(t, output, "github (2)\n  button")
+
+	_, err = runListCommand(t, tempDir, "list", "--group-by", "host")
+	assert.ErrorContains(t, err, "unknown --group-by 'host'")
+	_, err = runListCommand(t, tempDir, "list", "--group-by", "dir", "--porcelain")
+	assert.ErrorContains(t, err, "--group-by cannot be combined")
+}
@@ -0,0 +1,272 @@
+package list
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nightconcept/almandine/internal/core/advisory"
+	"github.com/nightconcept/almandine/internal/core/jobsummary"
+	"github.com/nightconcept/almandine/internal/core/paths"
+	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/nightconcept/almandine/internal/core/theme"
+	"github.com/nightconcept/almandine/internal/core/warnings"
+)
+
+// Freshness statuses reported by 'list --outdated'.
+const (
+	freshCurrent = "current" // Locked content matches the newest upstream version of the ref
+	freshBehind  = "behind"  // Upstream has a newer tag or commit
+	freshAhead   = "ahead"   // The declared tag is newer than any tag upstream
+	freshPinned  = "pinned"  // Pinned to a commit, so there is nothing newer to follow
+	freshUnknown = "unknown" // Not locked, not from a provider, or the provider could not be reached
+)
+
+// freshnessOrder is the order statuses appear in the summary line.
+var freshnessOrder = []string{freshCurrent, freshBehind, freshAhead, freshPinned, freshUnknown}
+
+var freshnessGlyphs = map[string]string{
+	freshCurrent: "✓",
+	freshBehind:  "↓",
+	freshAhead:   "↑",
+	freshPinned:  "•",
+	freshUnknown: "?",
+}
+
+// freshnessTTL is how long a remote freshness check is reused before the provider is asked again.
+const freshnessTTL = time.Hour
+
+var commitSHARegex = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// freshness is the outcome of comparing a dependency with its upstream.
+type freshness struct {
+	Status  string    `json:"status"`
+	Latest  string    `json:"latest,omitempty"` // Newest tag, or commit for branches, when behind or ahead
+	Checked time.Time `json:"checked"`
+}
+
+// freshnessCache stores recent freshness checks in the cache directory, keyed by declared source
+// and locked hash, so listing repeatedly does not spend API requests.
+type freshnessCache struct {
+	path    string
+	entries map[string]freshness
+	dirty   bool
+}
+
+func loadFreshnessCache() *freshnessCache {
+	c := &freshnessCache{entries: make(map[string]freshness)}
+	dir, err := paths.CacheDir()
+	if err != nil {
+		return c
+	}
+	c.path = filepath.Join(dir, "freshness.json")
+	if data, err := os.ReadFile(c.path); err == nil {
+		if json.Unmarshal(data, &c.entries) != nil || c.entries == nil {
+			c.entries = make(map[string]freshness)
+		}
+	}
+	return c
+}
+
+func (c *freshnessCache) save() {
+	if !c.dirty || c.path == "" {
+		return
+	}
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return
+	}
+	if os.MkdirAll(filepath.Dir(c.path), 0755) == nil {
+		_ = os.WriteFile(c.path, data, 0644)
+	}
+}
+
+// annotateFreshness fills in the Freshness of each dependency, from the cache when a recent check
+// exists and refresh is not set. Checks that fail are reported as unknown and not cached. A nil
+// cache asks up about every dependency and caches nothing.
+func annotateFreshness(displayDeps []dependencyDisplayInfo, up upstream, cache *freshnessCache, refresh bool) {
+	for i := range displayDeps {
+		dep := &displayDeps[i]
+		key := dep.ProjectSource + "\x00" + dep.LockedHash
+		if cache != nil {
+			if cached, ok := cache.entries[key]; ok && !refresh && time.Since(cached.Checked) < freshnessTTL {
+				dep.Freshness = cached
+				continue
+			}
+		}
+		f, err := checkFreshness(*dep, up)
+		f.Checked = time.Now().UTC()
+		dep.Freshness = f
+		if err == nil && cache != nil {
+			cache.entries[key] = f
+			cache.dirty = true
+		}
+	}
+	if cache != nil {
+		cache.save()
+	}
+}
+
+// checkFreshness compares a locked dependency with what its declared ref points to upstream.
+// An error means the provider could not answer and the status is unknown.
+func checkFreshness(dep dependencyDisplayInfo, up upstream) (freshness, error) {
+	unknown := freshness{Status: freshUnknown}
+	if !dep.IsLocked {
+		return unknown, nil
+	}
+	parsed, err := source.ParseSourceURL(dep.ProjectSource)
+	if err != nil || !source.HasCommits(parsed.Provider) {
+		return unknown, nil
+	}
+	if parsed.RefType == source.RefTypeCommit || (parsed.RefType == "" && commitSHARegex.MatchString(parsed.Ref)) {
+		return freshness{Status: freshPinned}, nil
+	}
+
+	var tags []string
+	if parsed.IsTagPattern() || parsed.RefType != source.RefTypeBranch {
+		if tags, err = up.ListTags(parsed); err != nil {
+			return unknown, err
+		}
+	}
+	switch {
+	case parsed.IsTagPattern():
+		tag, err := source.HighestMatchingTag(tags, parsed.Ref)
+		if err != nil {
+			return unknown, err
+		}
+		resolved := *parsed
+		resolved.Ref, resolved.RefType = tag, source.RefTypeTag
+		return compareLockedCommit(up, &resolved, dep.LockedHash, tag)
+	case parsed.RefType == source.RefTypeTag || containsTag(tags, parsed.Ref):
+		return compareTag(parsed.Ref, tags)
+	default:
+		return compareLockedCommit(up, parsed, dep.LockedHash, "")
+	}
+}
+
+// compareTag compares a declared tag with the newest tag upstream.
+func compareTag(declared string, tags []string) (freshness, error) {
+	newest, err := source.HighestMatchingTag(tags, "*")
+	if err != nil {
+		return freshness{Status: freshUnknown}, err
+	}
+	switch c := source.CompareTags(declared, newest); {
+	case c < 0:
+		return freshness{Status: freshBehind, Latest: newest}, nil
+	case c > 0:
+		return freshness{Status: freshAhead, Latest: newest}, nil
+	default:
+		return freshness{Status: freshCurrent}, nil
+	}
+}
+
+// compareLockedCommit compares the locked commit with the commit the ref resolves to now. label
+// names the newest version when it is known by something more readable than its commit.
+func compareLockedCommit(up upstream, parsed *source.ParsedSourceInfo, lockedHash, label string) (freshness, error) {
+	locked, ok := strings.CutPrefix(lockedHash, "commit:")
+	if !ok {
+		return freshness{Status: freshUnknown}, nil
+	}
+	latest, err := up.ResolveRef(parsed)
+	if err != nil {
+		return freshness{Status: freshUnknown}, err
+	}
+	if latest == locked {
+		return freshness{Status: freshCurrent}, nil
+	}
+	if label == "" && len(latest) > 7 {
+		label = latest[:7]
+	}
+	return freshness{Status: freshBehind, Latest: label}, nil
+}
+
+func containsTag(tags []string, ref string) bool {
+	for _, tag := range tags {
+		if tag == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// freshnessGlyph renders the colored glyph for a status, or the status itself padded to a common
+// width in plain mode.
+func freshnessGlyph(status string) string {
+	if theme.Plain() {
+		return fmt.Sprintf("%-7s", status)
+	}
+	glyph := freshnessGlyphs[status]
+	switch status {
+	case freshCurrent:
+		return theme.New(theme.FreshCurrent).Sprint(glyph)
+	case freshBehind:
+		return theme.New(theme.FreshBehind).Sprint(glyph)
+	case freshAhead:
+		return theme.New(theme.FreshAhead).Sprint(glyph)
+	default:
+		return theme.New(theme.FreshOther).Sprint(glyph)
+	}
+}
+
+// freshnessSummary returns a line such as "3 current, 1 behind, 1 pinned, 1 due for review".
+func freshnessSummary(displayDeps []dependencyDisplayInfo) string {
+	counts := make(map[string]int)
+	reviews := 0
+	for _, dep := range displayDeps {
+		counts[dep.Freshness.Status]++
+		if dep.ReviewDue != "" {
+			reviews++
+		}
+	}
+	var parts []string
+	for _, status := range freshnessOrder {
+		if counts[status] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[status], status))
+		}
+	}
+	if reviews > 0 {
+		parts = append(parts, fmt.Sprintf("%d due for review", reviews))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// writeOutdatedJobSummary adds the dependencies that are behind or ahead of upstream, or due for
+// review, to the GitHub Actions job summary. Failing to write it is reported as a warning.
+func writeOutdatedJobSummary(displayDeps []dependencyDisplayInfo) {
+	if !jobsummary.Enabled() {
+		return
+	}
+	table := jobsummary.Table{
+		Title:  "almd outdated",
+		Header: []string{"Dependency", "Status", "Latest", "Review due since", "Source"},
+		Note:   freshnessSummary(displayDeps) + ".",
+	}
+	for _, dep := range displayDeps {
+		status := dep.Freshness.Status
+		if status != freshBehind && status != freshAhead && dep.ReviewDue == "" {
+			continue
+		}
+		table.Rows = append(table.Rows, []string{dep.Name, status, dep.Freshness.Latest, dep.ReviewDue, dep.ProjectSource})
+	}
+	if err := jobsummary.Write(table); err != nil {
+		warnings.Printf("could not write the job summary: %v", err)
+	}
+}
+
+// warnAdvisories warns about the dependencies a registry's advisories mark deprecated or yanked
+// at their locked version.
+func warnAdvisories(displayDeps []dependencyDisplayInfo) {
+	advisories, err := advisory.Load()
+	if err != nil {
+		warnings.Printf("Could not check for deprecated or yanked dependencies: %v", err)
+	}
+	for _, dep := range displayDeps {
+		if a := advisory.Match(advisories, dep.ProjectSource, strings.TrimPrefix(dep.LockedHash, "commit:")); a != nil {
+			warnings.Printf("%s", a.Describe(dep.Name))
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package list
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/jobsummary"
+	"github.com/nightconcept/almandine/internal/core/paths"
+	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/nightconcept/almandine/internal/core/theme"
+)
+
+const (
+	lockedBranchSHA = "1111111111111111111111111111111111111111"
+	latestBranchSHA = "2222222222222222222222222222222222222222"
+	pinnedSHA       = "3333333333333333333333333333333333333333"
+)
+
+// startFreshnessAPI serves the GitHub tag and commit endpoints used by --outdated and counts the
+// requests it receives.
+func startFreshnessAPI(t *testing.T) *atomic.Int32 {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/repos/owner/repo/tags":
+			_, _ = w.Write([]byte(`[{"name":"v1.0.0"},{"name":"v1.2.0"},{"name":"v0.9.0"}]`))
+		case "/repos/owner/repo/commits":
+			_, _ = fmt.Fprintf(w, `[{"sha":"%s"}]`, latestBranchSHA)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	original := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = server.URL
+	t.Cleanup(func() { source.GithubAPIBaseURL = original })
+	t.Setenv(paths.CacheDirEnv, t.TempDir())
+	return &requests
+}
+
+const outdatedProjectToml = `
+[package]
+name = "fresh"
+version = "0.1.0"
+
+[dependencies]
+tagged = { source = "github:owner/repo/tagged.lua@v1.0.0", path = "libs/tagged.lua", review_after = "2020-01-01" }
+newest = { source = "github:owner/repo/newest.lua@v1.2.0", path = "libs/newest.lua", review_after = "2999-01-01" }
+branch = { source = "github:owner/repo/branch.lua@main", path = "libs/branch.lua" }
+pinned = { source = "github:owner/repo/pinned.lua@` + pinnedSHA + `", path = "libs/pinned.lua" }
+plain = { source = "https://example.com/plain.lua", path = "libs/plain.lua" }
+`
+
+const outdatedLockfile = `
+api_version = "1"
+
+[package.tagged]
+source = "https://raw.githubusercontent.com/owner/repo/v1.0.0/tagged.lua"
+path = "libs/tagged.lua"
+hash = "commit:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+
+[package.newest]
+source = "https://raw.githubusercontent.com/owner/repo/v1.2.0/newest.lua"
+path = "libs/newest.lua"
+hash = "commit:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+
+[package.branch]
+source = "https://raw.githubusercontent.com/owner/repo/` + lockedBranchSHA + `/branch.lua"
+path = "libs/branch.lua"
+hash = "commit:` + lockedBranchSHA + `"
+
+[package.pinned]
+source = "https://raw.githubusercontent.com/owner/repo/` + pinnedSHA + `/pinned.lua"
+path = "libs/pinned.lua"
+hash = "commit:` + pinnedSHA + `"
+
+[package.plain]
+source = "https://example.com/plain.lua"
+path = "libs/plain.lua"
+hash = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+`
+
+func TestListCommand_Outdated(t *testing.T) {
+	requests := startFreshnessAPI(t)
+	tempDir := setupListTestEnvironment(t, outdatedProjectToml, outdatedLockfile, nil)
+	summaryPath := filepath.Join(t.TempDir(), "summary.md")
+	t.Setenv(jobsummary.Env, summaryPath)
+
+	output, err := runListCommand(t, tempDir, "list", "--outdated")
+	require.NoError(t, err)
+	assert.Contains(t, output, "↓ tagged ")
+	assert.Contains(t, output, "(behind: v1.2.0)")
+	assert.Contains(t, output, "✓ newest ")
+	assert.Contains(t, output, "↓ branch ")
+	assert.Contains(t, output, "(behind: 2222222)")
+	assert.Contains(t, output, "• pinned ")
+	assert.Contains(t, output, "? plain ")
+	assert.Contains(t, output, "(behind: v1.2.0) [review due since 2020-01-01]")
+	assert.NotContains(t, output, "2999-01-01")
+	assert.Contains(t, output, "1 current, 2 behind, 1 pinned, 1 unknown, 1 due for review")
+
+	summary, err := os.ReadFile(summaryPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(summary), "### almd outdated")
+	assert.Contains(t, string(summary), "| tagged | behind | v1.2.0 | 2020-01-01 | github:owner/repo/tagged.lua@v1.0.0 |")
+	assert.Contains(t, string(summary), "| branch | behind | "+latestBranchSHA[:7]+" |")
+	assert.NotContains(t, string(summary), "| newest |")
+	assert.Contains(t, string(summary), "1 current, 2 behind, 1 pinned, 1 unknown, 1 due for review.")
+	t.Setenv(jobsummary.Env, "")
+
+	// A second listing within the TTL is answered from the cache.
+	before := requests.Load()
+	_, err = runListCommand(t, tempDir, "list", "--outdated")
+	require.NoError(t, err)
+	assert.Equal(t, before, requests.Load())
+
+	_, err = runListCommand(t, tempDir, "list", "--outdated", "--refresh")
+	require.NoError(t, err)
+	assert.Greater(t, requests.Load(), before)
+
+	shorthand, err := runListCommand(t, tempDir, "outdated")
+	require.NoError(t, err)
+	assert.Equal(t, output, shorthand, "'outdated' is 'list --outdated'")
+}
+
+func TestListCommand_OutdatedPorcelain(t *testing.T) {
+	startFreshnessAPI(t)
+	tempDir := setupListTestEnvironment(t, outdatedProjectToml, outdatedLockfile, nil)
+
+	output, err := runListCommand(t, tempDir, "list", "--porcelain", "--outdated")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	require.Len(t, lines, 5)
+	fields := strings.Split(lines[4], "\t")
+	require.Len(t, fields, 8)
+	assert.Equal(t, []string{"tagged", "behind", "v1.2.0"}, []string{fields[0], fields[6], fields[7]})
+	assert.Equal(t, []string{"unknown", "-"}, strings.Split(lines[3], "\t")[6:])
+}
+
+func TestListCommand_OutdatedPlain(t *testing.T) {
+	startFreshnessAPI(t)
+	tempDir := setupListTestEnvironment(t, outdatedProjectToml, outdatedLockfile, nil)
+	theme.SetPlain(true)
+	t.Cleanup(func() { theme.SetPlain(false) })
+
+	output, err := runListCommand(t, tempDir, "list", "--outdated")
+	require.NoError(t, err)
+	assert.Contains(t, output, "behind  tagged ")
+	assert.Contains(t, output, "current newest ")
+	assert.Contains(t, output, "pinned  pinned ")
+	assert.NotContains(t, output, "↓")
+	assert.NotContains(t, output, "\x1b[")
+}
+
+func TestCheckFreshness_AheadAndUnreachable(t *testing.T) {
+	startFreshnessAPI(t)
+
+	f, err := checkFreshness(dependencyDisplayInfo{IsLocked: true, ProjectSource: "github:owner/repo/x.lua@tag:v2.0.0", LockedHash: "commit:abc"}, liveUpstream{})
+	require.NoError(t, err)
+	assert.Equal(t, freshness{Status: freshAhead, Latest: "v1.2.0"}, f)
+
+	f, err = checkFreshness(dependencyDisplayInfo{IsLocked: true, ProjectSource: "github:other/missing/x.lua@main", LockedHash: "commit:abc"}, liveUpstream{})
+	require.Error(t, err)
+	assert.Equal(t, freshUnknown, f.Status)
+
+	f, err = checkFreshness(dependencyDisplayInfo{IsLocked: false, ProjectSource: "github:owner/repo/x.lua@main"}, liveUpstream{})
+	require.NoError(t, err)
+	assert.Equal(t, freshUnknown, f.Status)
+}
+
+func TestListCommand_OutdatedSnapshot(t *testing.T) {
+	requests := startFreshnessAPI(t)
+	tempDir := setupListTestEnvironment(t, outdatedProjectToml, outdatedLockfile, nil)
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.json")
+
+	live, err := runListCommand(t, tempDir, "list", "--snapshot", snapshotPath)
+	require.NoError(t, err)
+	assert.Contains(t, live, "1 current, 2 behind, 1 pinned, 1 unknown")
+	require.FileExists(t, snapshotPath)
+
+	// Replaying the snapshot gives the same answers without a single request.
+	before := requests.Load()
+	replayed, err := runListCommand(t, tempDir, "list", "--from-snapshot", snapshotPath)
+	require.NoError(t, err)
+	assert.Equal(t, live, replayed)
+	assert.Equal(t, before, requests.Load())
+
+	_, err = runListCommand(t, tempDir, "list", "--snapshot", snapshotPath, "--from-snapshot", snapshotPath)
+	require.Error(t, err)
+}
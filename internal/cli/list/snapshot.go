@@ -0,0 +1,127 @@
+package list
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+// snapshotVersion is bumped whenever the snapshot format changes incompatibly.
+const snapshotVersion = 1
+
+// upstream answers the questions checkFreshness asks about a dependency's repository.
+type upstream interface {
+	ListTags(p *source.ParsedSourceInfo) ([]string, error)
+	ResolveRef(p *source.ParsedSourceInfo) (string, error)
+}
+
+// liveUpstream asks the dependency's provider.
+type liveUpstream struct{}
+
+func (liveUpstream) ListTags(p *source.ParsedSourceInfo) ([]string, error) {
+	provider, err := source.LookupProvider(p.Provider)
+	if err != nil {
+		return nil, err
+	}
+	return provider.ListTags(p)
+}
+
+func (liveUpstream) ResolveRef(p *source.ParsedSourceInfo) (string, error) {
+	return source.ResolveRef(p)
+}
+
+// snapshot holds the answers upstream gave during 'list --outdated --snapshot', so that
+// 'list --outdated --from-snapshot' can evaluate the same project offline, e.g. in a scheduled
+// CI job whose runners have no token.
+type snapshot struct {
+	Version int                 `json:"version"`
+	Created time.Time           `json:"created"`
+	Tags    map[string][]string `json:"tags"`    // Repository key -> tag names
+	Commits map[string]string   `json:"commits"` // Ref key -> the commit the ref resolved to
+}
+
+func newSnapshot() *snapshot {
+	return &snapshot{Version: snapshotVersion, Created: time.Now().UTC(), Tags: map[string][]string{}, Commits: map[string]string{}}
+}
+
+// repoKey identifies a repository, e.g. "github:owner/repo".
+func repoKey(p *source.ParsedSourceInfo) string {
+	return fmt.Sprintf("%s:%s/%s", p.Provider, p.Owner, p.Repo)
+}
+
+// refKey identifies a ref resolution. Providers may resolve a ref per file (the last commit that
+// touched it), so the path is part of the key.
+func refKey(p *source.ParsedSourceInfo) string {
+	return fmt.Sprintf("%s/%s@%s", repoKey(p), p.PathInRepo, p.Ref)
+}
+
+// recordingUpstream asks the provider and records every answer in snap.
+type recordingUpstream struct {
+	snap *snapshot
+}
+
+func (r recordingUpstream) ListTags(p *source.ParsedSourceInfo) ([]string, error) {
+	tags, err := liveUpstream{}.ListTags(p)
+	if err == nil {
+		r.snap.Tags[repoKey(p)] = tags
+	}
+	return tags, err
+}
+
+func (r recordingUpstream) ResolveRef(p *source.ParsedSourceInfo) (string, error) {
+	commit, err := liveUpstream{}.ResolveRef(p)
+	if err == nil {
+		r.snap.Commits[refKey(p)] = commit
+	}
+	return commit, err
+}
+
+// snapshotUpstream answers from a snapshot and never touches the network.
+type snapshotUpstream struct {
+	snap *snapshot
+}
+
+func (s snapshotUpstream) ListTags(p *source.ParsedSourceInfo) ([]string, error) {
+	tags, ok := s.snap.Tags[repoKey(p)]
+	if !ok {
+		return nil, fmt.Errorf("the snapshot has no tags for %s", repoKey(p))
+	}
+	return tags, nil
+}
+
+func (s snapshotUpstream) ResolveRef(p *source.ParsedSourceInfo) (string, error) {
+	commit, ok := s.snap.Commits[refKey(p)]
+	if !ok {
+		return "", fmt.Errorf("the snapshot has no commit for %s", refKey(p))
+	}
+	return commit, nil
+}
+
+func loadSnapshot(path string) (*snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading snapshot: %w", err)
+	}
+	snap := newSnapshot()
+	if err := json.Unmarshal(data, snap); err != nil {
+		return nil, fmt.Errorf("parsing snapshot %s: %w", path, err)
+	}
+	if snap.Version != snapshotVersion {
+		return nil, fmt.Errorf("snapshot %s has version %d; this almd reads version %d", path, snap.Version, snapshotVersion)
+	}
+	return snap, nil
+}
+
+func (s *snapshot) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding snapshot: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+	return nil
+}
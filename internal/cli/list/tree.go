@@ -0,0 +1,102 @@
+package list
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/theme"
+)
+
+// treeNode is one line of 'list --tree': a dependency, or a file or requirement below it.
+type treeNode struct {
+	Label    string
+	Detail   string // Hash or source, muted
+	Status   string // Per-node status such as "ok" or "missing"
+	Problem  bool   // Status is shown as a problem
+	Children []treeNode
+}
+
+// buildTree groups each dependency's files under it. Every dependency currently vendors a single
+// file; dependencies spanning several files or requiring others add further children here.
+func buildTree(displayDeps []dependencyDisplayInfo, outdated bool) []treeNode {
+	nodes := make([]treeNode, 0, len(displayDeps))
+	for _, dep := range displayDeps {
+		status, problem := "ok", false
+		if dep.FileStatusInfo != "" {
+			status, problem = dep.FileStatusInfo, true
+		}
+		if outdated && dep.Freshness.Status != "" {
+			status += ", " + dep.Freshness.Status
+			if dep.Freshness.Latest != "" {
+				status += " " + dep.Freshness.Latest
+			}
+		}
+
+		fileStatus, fileProblem := "present", false
+		if !dep.FileExists {
+			fileStatus, fileProblem = "missing", true
+		}
+		hash := dep.LockedHash
+		if !dep.IsLocked {
+			hash = "not locked"
+		}
+		nodes = append(nodes, treeNode{
+			Label:   dep.Name,
+			Detail:  dep.ProjectSource,
+			Status:  status,
+			Problem: problem,
+			Children: []treeNode{
+				{Label: dep.ProjectPath, Detail: hash, Status: fileStatus, Problem: fileProblem},
+			},
+		})
+	}
+	return nodes
+}
+
+// printTreeOutput prints the project header followed by the dependency tree.
+func printTreeOutput(w io.Writer, proj *project.Project, displayDeps []dependencyDisplayInfo, projectRootPath string, outdated bool) {
+	_, _ = fmt.Fprintf(w, "%s@%s %s\n\n", theme.SprintFunc(theme.ProjectName)(proj.Package.Name),
+		theme.SprintFunc(theme.ProjectVersion)(proj.Package.Version), theme.SprintFunc(theme.ProjectPath)(projectRootPath))
+	_, _ = fmt.Fprintln(w, theme.SprintFunc(theme.Header)("dependencies:"))
+	if len(displayDeps) == 0 {
+		_, _ = fmt.Fprintln(w, "No dependencies found in project.toml.")
+		return
+	}
+	renderTree(w, buildTree(displayDeps, outdated), "", true)
+}
+
+// renderTree prints nodes with box-drawing branches, or plain indentation in plain mode.
+// Top-level nodes are dependencies; deeper nodes are their files and requirements.
+func renderTree(w io.Writer, nodes []treeNode, prefix string, top bool) {
+	labelColor := theme.SprintFunc(theme.DepPath)
+	if top {
+		labelColor = theme.SprintFunc(theme.DepName)
+	}
+	detailColor := theme.SprintFunc(theme.DepHash)
+	okColor := theme.SprintFunc(theme.OK)
+	problemColor := theme.SprintFunc(theme.Problem)
+
+	for i, node := range nodes {
+		last := i == len(nodes)-1
+		branch, indent := "├── ", "│   "
+		if last {
+			branch, indent = "└── ", "    "
+		}
+		if theme.Plain() {
+			branch, indent = "- ", "  "
+		}
+
+		status := okColor("[" + node.Status + "]")
+		if node.Problem {
+			status = problemColor("[" + node.Status + "]")
+		}
+		line := []string{labelColor(node.Label), status}
+		if node.Detail != "" {
+			line = append(line, detailColor(node.Detail))
+		}
+		_, _ = fmt.Fprintf(w, "%s%s%s\n", prefix, branch, strings.Join(line, " "))
+		renderTree(w, node.Children, prefix+indent, false)
+	}
+}
@@ -0,0 +1,61 @@
+package list
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/theme"
+)
+
+func TestListCommand_Tree(t *testing.T) {
+	projectTomlContent := `
+[package]
+name = "tree-project"
+version = "1.0.0"
+
+[dependencies.zeta]
+source = "github:user/repo/zeta.lua@main"
+path = "libs/zeta.lua"
+
+[dependencies.alpha]
+source = "github:user/repo/alpha.lua@v1"
+path = "libs/alpha.lua"
+`
+	lockfileContent := `
+api_version = "1"
+[package.alpha]
+source = "https://raw.githubusercontent.com/user/repo/v1/alpha.lua"
+path = "libs/alpha.lua"
+hash = "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+`
+	tempDir := setupListTestEnvironment(t, projectTomlContent, lockfileContent, map[string]string{
+		"libs/alpha.lua": "return {}",
+	})
+
+	output, err := runListCommand(t, tempDir, "list", "--tree")
+	require.NoError(t, err)
+	expected := "dependencies:\n" +
+		"├── alpha [ok] github:user/repo/alpha.lua@v1\n" +
+		"│   └── libs/alpha.lua [present] sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855\n" +
+		"└── zeta [not locked, missing] github:user/repo/zeta.lua@main\n" +
+		"    └── libs/zeta.lua [missing] not locked\n"
+	assert.True(t, strings.HasSuffix(output, expected), "unexpected tree:\n%s", output)
+
+	_, err = runListCommand(t, tempDir, "list", "--tree", "--porcelain")
+	require.Error(t, err)
+}
+
+func TestRenderTree_Plain(t *testing.T) {
+	theme.SetPlain(true)
+	defer theme.SetPlain(false)
+
+	var buf bytes.Buffer
+	renderTree(&buf, []treeNode{
+		{Label: "lib", Status: "ok", Children: []treeNode{{Label: "lib.lua", Status: "present"}}},
+	}, "", true)
+	assert.Equal(t, "- lib [ok]\n  - lib.lua [present]\n", buf.String())
+}
@@ -0,0 +1,71 @@
+package lock
+
+import (
+	"fmt"
+
+	"github.com/nightconcept/almandine/internal/core/archive"
+	"github.com/nightconcept/almandine/internal/core/hasher"
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+// refreshArchive builds the lock entry of an archive dependency. Its entry locks the archive by
+// the hash of its content, so it needs resolve to download it, and every file on disk must match
+// the file extracted from it.
+func refreshArchive(projectRoot, name string, dep project.Dependency, parsed *source.ParsedSourceInfo, resolve bool) refreshed {
+	r := refreshed{Name: name}
+	if !resolve {
+		r.Detail = "archives can only be locked with --resolve, which downloads the archive"
+		return r
+	}
+	content, err := fetch(parsed.RawURL, dep.Headers)
+	if err != nil {
+		r.Detail = fmt.Sprintf("could not download the archive: %v", err)
+		return r
+	}
+	archiveHash, err := hasher.CalculateSHA256(content)
+	if err != nil {
+		r.Detail = err.Error()
+		return r
+	}
+	extracted, err := archive.Extract(parsed.ArchiveFormat(), content, dep.Extract)
+	if err != nil {
+		r.Detail = fmt.Sprintf("cannot extract the archive: %v", err)
+		return r
+	}
+
+	files := make(map[string]string, len(dep.Files))
+	for _, file := range dep.Files {
+		hash, detail := matchArchiveFile(projectRoot, dep, extracted, file)
+		if detail != "" {
+			r.Detail = fmt.Sprintf("'%s' %s; run 'almd install --force %s' to replace it", file, detail, name)
+			return r
+		}
+		files[file] = hash
+	}
+	r.Entry = lockfile.PackageEntry{Source: parsed.RawURL, Path: dep.Path, Hash: archiveHash, Files: files, Extract: dep.Extract}
+	r.Locked, r.Detail = true, fmt.Sprintf("%d files match the archive", len(files))
+	return r
+}
+
+// matchArchiveFile returns the hash of one file of an archive dependency when the copy on disk
+// matches the file extracted from the archive, and otherwise describes how it does not.
+func matchArchiveFile(projectRoot string, dep project.Dependency, extracted map[string][]byte, file string) (hash, detail string) {
+	diskHash, detail := diskFileHash(projectRoot, dep, file)
+	if detail != "" {
+		return "", detail
+	}
+	data, ok := extracted[file]
+	if !ok {
+		return "", "is not extracted from the archive"
+	}
+	archivedHash, err := hasher.CalculateSHA256(data)
+	if err != nil {
+		return "", err.Error()
+	}
+	if archivedHash != diskHash {
+		return "", "differs from the archive"
+	}
+	return diskHash, ""
+}
@@ -0,0 +1,94 @@
+package lock
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/nightconcept/almandine/internal/core/hasher"
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/nightconcept/almandine/internal/core/vendorheader"
+)
+
+// refreshDirectory builds the lock entry of a directory dependency. Its entry locks the whole
+// directory to one commit, so it needs resolve, and every file on disk must match the upstream
+// content at that commit.
+func refreshDirectory(projectRoot, name string, dep project.Dependency, resolve bool) refreshed {
+	r := refreshed{Name: name}
+	parsed, err := source.ParseSourceURL(dep.Source)
+	if err == nil && parsed.ArchiveFormat() != "" {
+		return refreshArchive(projectRoot, name, dep, parsed, resolve)
+	}
+	if !resolve {
+		r.Detail = "directories can only be locked with --resolve, which looks up the commit of their files"
+		return r
+	}
+	if err == nil && parsed.IsTagPattern() {
+		parsed, err = source.ResolveTagPattern(parsed)
+	}
+	if err != nil {
+		r.Detail = fmt.Sprintf("cannot resolve source '%s': %v", dep.Source, err)
+		return r
+	}
+	commit, err := source.ResolveRef(parsed)
+	if err != nil {
+		r.Detail = fmt.Sprintf("could not look up the upstream content: %v", err)
+		return r
+	}
+	pinned, err := parsed.AtCommit(commit)
+	if err != nil {
+		r.Detail = err.Error()
+		return r
+	}
+
+	files := make(map[string]string, len(dep.Files))
+	for _, file := range dep.Files {
+		hash, detail := matchUpstreamFile(projectRoot, dep, pinned, file)
+		if detail != "" {
+			r.Detail = fmt.Sprintf("'%s' %s at commit %s; run 'almd install --force %s' to replace it", file, detail, shortSHA(commit), name)
+			return r
+		}
+		files[file] = hash
+	}
+	r.Entry = lockfile.PackageEntry{Source: parsed.RawURL, Path: dep.Path, Hash: "commit:" + commit, Files: files}
+	r.Locked, r.Detail = true, fmt.Sprintf("%d files match upstream commit %s", len(files), shortSHA(commit))
+	return r
+}
+
+// matchUpstreamFile returns the hash of one file of a directory dependency when the copy on disk
+// matches the file at the pinned commit, and otherwise describes how it does not.
+func matchUpstreamFile(projectRoot string, dep project.Dependency, pinned *source.ParsedSourceInfo, file string) (hash, detail string) {
+	diskHash, detail := diskFileHash(projectRoot, dep, file)
+	if detail != "" {
+		return "", detail
+	}
+	upstream, err := fetch(pinned.FileRawURL(file), dep.Headers)
+	if err != nil {
+		return "", fmt.Sprintf("could not be downloaded (%v)", err)
+	}
+	upstreamHash, err := hasher.CalculateSHA256(upstream)
+	if err != nil {
+		return "", err.Error()
+	}
+	if upstreamHash != diskHash {
+		return "", "differs from upstream"
+	}
+	return diskHash, ""
+}
+
+// diskFileHash returns the hash of one file of a directory dependency as it is on disk, without
+// its vendor header, or describes why it could not be hashed.
+func diskFileHash(projectRoot string, dep project.Dependency, file string) (hash, detail string) {
+	content, err := os.ReadFile(filepath.Join(projectRoot, filepath.FromSlash(path.Join(dep.Path, file))))
+	if err != nil {
+		return "", fmt.Sprintf("cannot be read (%v)", err)
+	}
+	diskHash, err := hasher.CalculateSHA256(vendorheader.Strip(content))
+	if err != nil {
+		return "", err.Error()
+	}
+	return diskHash, ""
+}
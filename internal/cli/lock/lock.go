@@ -0,0 +1,275 @@
+// Package lock implements the 'lock' command group. 'lock refresh' rebuilds almd-lock.toml
+// from the dependency files already on disk, for projects that vendored files before they
+// adopted almd; 'lock merge' and 'lock show' are the git merge driver and diff textconv
+// registered by 'almd gitconfig install'; 'lock sign' stamps the lockfile with a checksum of
+// its content; 'lock repair' rebuilds entries whose hashes are malformed.
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/cli/completion"
+	"github.com/nightconcept/almandine/internal/core/cache"
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/downloader"
+	"github.com/nightconcept/almandine/internal/core/hasher"
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/nightconcept/almandine/internal/core/theme"
+	"github.com/nightconcept/almandine/internal/core/transform"
+	"github.com/nightconcept/almandine/internal/core/vendorheader"
+	"github.com/nightconcept/almandine/internal/core/warnings"
+)
+
+// refreshed is the outcome of rebuilding the lock entry of one dependency.
+type refreshed struct {
+	Name   string
+	Entry  lockfile.PackageEntry
+	Locked bool   // An entry was produced
+	Detail string // How the entry was locked, or why it was not
+}
+
+// LockCmd returns the 'lock' command with its refresh, merge, show, sign and repair subcommands.
+func LockCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "lock",
+		Usage: "Maintain almd-lock.toml",
+		Subcommands: []*cli.Command{
+			{
+				Name:         "refresh",
+				Usage:        "Rebuild the lockfile from the dependency files on disk",
+				ArgsUsage:    "[dependency...]",
+				BashComplete: completion.Dependencies,
+				Description: "Hashes the file at each dependency's path and records it in almd-lock.toml, replacing\n" +
+					"the existing entry. With --resolve, the source's ref is resolved to a commit and the file\n" +
+					"is locked to that commit when it matches the upstream content there; files that differ\n" +
+					"from upstream are locked by their content hash. Entries for dependencies no longer in\n" +
+					"project.toml are removed when no dependencies are named.",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "resolve", Usage: "Resolve each source's ref and lock matching files to the upstream commit"},
+					&cli.BoolFlag{Name: "dry-run", Usage: "Show the entries that would be written without changing the lockfile"},
+				},
+				Action: refreshAction,
+			},
+			mergeCmd(),
+			showCmd(),
+			signCmd(),
+			repairCmd(),
+		},
+	}
+}
+
+func refreshAction(c *cli.Context) error {
+	proj, lf, err := loadProjectAndLockfile()
+	if err != nil {
+		return err
+	}
+
+	names, err := selectDependencies(proj, c.Args().Slice())
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+
+	changed := false
+	var results []refreshed
+	for _, name := range names {
+		r := refreshDependency(".", name, proj.Dependencies[name], c.Bool("resolve"))
+		if r.Locked {
+			changed = changed || !lf.Package[name].Equal(r.Entry)
+			lf.Package[name] = r.Entry
+		} else {
+			warnings.Printf("not locking '%s': %s", name, r.Detail)
+		}
+		results = append(results, r)
+	}
+	var pruned []string
+	if c.NArg() == 0 {
+		pruned = lf.Prune(func(name string) bool {
+			_, ok := proj.Dependencies[name]
+			return ok
+		})
+		changed = changed || len(pruned) > 0
+	}
+
+	report(results, pruned)
+	switch {
+	case !changed:
+		_, _ = fmt.Fprintf(os.Stdout, "%s is already up to date.\n", lockfile.LockfileName)
+	case c.Bool("dry-run"):
+		_, _ = fmt.Fprintf(os.Stdout, "Dry run: %s was not changed.\n", lockfile.LockfileName)
+	default:
+		if err := lockfile.Save(".", lf); err != nil {
+			return cli.Exit(fmt.Sprintf("Error writing %s: %v", lockfile.LockfileName, err), 1)
+		}
+		_, _ = fmt.Fprintf(os.Stdout, "Wrote %s.\n", lockfile.LockfileName)
+	}
+	return nil
+}
+
+// loadProjectAndLockfile loads project.toml and almd-lock.toml from the current directory.
+func loadProjectAndLockfile() (*project.Project, *lockfile.Lockfile, error) {
+	proj, err := config.LoadProjectToml(".")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, cli.Exit(fmt.Sprintf("Error: %s not found in the current directory.", config.ManifestName()), 1)
+		}
+		return nil, nil, cli.Exit(fmt.Sprintf("Error loading %s: %v", config.ManifestName(), err), 1)
+	}
+	lf, err := lockfile.Load(".")
+	if err != nil {
+		return nil, nil, cli.Exit(fmt.Sprintf("Error loading %s: %v", lockfile.LockfileName, err), 1)
+	}
+	return proj, lf, nil
+}
+
+// selectDependencies returns the named dependencies, or all of them when none are named.
+func selectDependencies(proj *project.Project, args []string) ([]string, error) {
+	if len(args) == 0 {
+		names := make([]string, 0, len(proj.Dependencies))
+		for name := range proj.Dependencies {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names, nil
+	}
+	for _, name := range args {
+		if _, ok := proj.Dependencies[name]; !ok {
+			return nil, fmt.Errorf("dependency '%s' not found in %s", name, config.ManifestName())
+		}
+	}
+	return args, nil
+}
+
+// refreshDependency builds the lock entry for the file at dep.Path. The vendor header is not
+// part of the hash. Transformed files can only be locked with resolve, because the lockfile
+// must also record the hash of the upstream content.
+func refreshDependency(projectRoot, name string, dep project.Dependency, resolve bool) refreshed {
+	if dep.IsDirectory() {
+		return refreshDirectory(projectRoot, name, dep, resolve)
+	}
+	r := refreshed{Name: name}
+	parsed, err := source.ParseSourceURL(dep.Source)
+	if err != nil {
+		r.Detail = fmt.Sprintf("cannot parse source '%s': %v", dep.Source, err)
+		return r
+	}
+	content, err := os.ReadFile(filepath.Join(projectRoot, filepath.FromSlash(dep.Path)))
+	if errors.Is(err, fs.ErrNotExist) {
+		r.Detail = fmt.Sprintf("'%s' does not exist; run 'almd install' to download it", dep.Path)
+		return r
+	}
+	if err != nil {
+		r.Detail = err.Error()
+		return r
+	}
+	diskHash, err := hasher.CalculateSHA256(vendorheader.Strip(content))
+	if err != nil {
+		r.Detail = err.Error()
+		return r
+	}
+
+	r.Entry = lockfile.PackageEntry{Source: parsed.RawURL, Path: dep.Path, Hash: diskHash}
+	if dep.Transform != "" {
+		r.Entry.Transform, r.Entry.TransformedHash = dep.Transform, diskHash
+	}
+	if !resolve {
+		if dep.Transform != "" {
+			r.Detail = "transformed files can only be locked with --resolve, which looks up the upstream content"
+			return r
+		}
+		r.Locked, r.Detail = true, "content hash"
+		return r
+	}
+
+	commit, upstreamHash, err := resolveUpstream(parsed, dep)
+	switch {
+	case err != nil && dep.Transform != "":
+		r.Detail = fmt.Sprintf("could not look up the upstream content: %v", err)
+	case err != nil:
+		r.Locked, r.Detail = true, fmt.Sprintf("content hash (could not look up upstream: %v)", err)
+	case upstreamHash != diskHash && dep.Transform != "":
+		r.Detail = fmt.Sprintf("differs from upstream at commit %s; run 'almd install --force %s' to replace it", shortSHA(commit), name)
+	case upstreamHash != diskHash:
+		r.Locked, r.Detail = true, fmt.Sprintf("content hash (differs from upstream at commit %s)", shortSHA(commit))
+	default:
+		r.Entry.Hash = "commit:" + commit
+		r.Locked, r.Detail = true, "matches upstream commit "+shortSHA(commit)
+	}
+	return r
+}
+
+// resolveUpstream resolves the source's ref to a commit and returns it with the hash of the file
+// at that commit as it would be written, i.e. after the dependency's transform.
+func resolveUpstream(parsed *source.ParsedSourceInfo, dep project.Dependency) (commit, hash string, err error) {
+	if parsed.Ref == "" {
+		return "", "", fmt.Errorf("source has no ref to resolve")
+	}
+	if parsed.IsTagPattern() {
+		resolved, err := source.ResolveTagPattern(parsed)
+		if err != nil {
+			return "", "", err
+		}
+		parsed = resolved
+	}
+	commit, err = source.ResolveRef(parsed)
+	if err != nil {
+		return "", "", err
+	}
+	pinned, err := parsed.AtCommit(commit)
+	if err != nil {
+		return "", "", err
+	}
+	content, err := fetch(pinned.RawURL, dep.Headers)
+	if err != nil {
+		return "", "", fmt.Errorf("downloading %s: %w", pinned.RawURL, err)
+	}
+	content, err = transform.Apply(dep.Transform, dep.Path, content)
+	if err != nil {
+		return "", "", err
+	}
+	hash, err = hasher.CalculateSHA256(content)
+	return commit, hash, err
+}
+
+// fetch downloads url through the download cache, sending headers with the request; content at
+// a commit never changes.
+func fetch(url string, headers map[string]string) ([]byte, error) {
+	store, err := cache.Open()
+	if err != nil {
+		return downloader.WithHeaders(headers)(url)
+	}
+	content, _, err := store.Fetch(url, true, downloader.WithHeaders(headers))
+	return content, err
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// report prints one line per dependency and the entries that were pruned.
+func report(results []refreshed, pruned []string) {
+	okColor := theme.SprintFunc(theme.OK)
+	problemColor := theme.SprintFunc(theme.Problem)
+	detailColor := theme.SprintFunc(theme.Muted)
+	for _, r := range results {
+		if r.Locked {
+			_, _ = fmt.Fprintf(os.Stdout, "%s %s %s\n", okColor(fmt.Sprintf("%-9s", "locked")), r.Name, detailColor(r.Detail))
+		} else {
+			_, _ = fmt.Fprintf(os.Stdout, "%s %s\n", problemColor(fmt.Sprintf("%-9s", "skipped")), r.Name)
+		}
+	}
+	for _, name := range pruned {
+		_, _ = fmt.Fprintf(os.Stdout, "%s %s %s\n", problemColor(fmt.Sprintf("%-9s", "removed")), name, detailColor("not in "+config.ManifestName()))
+	}
+}
@@ -0,0 +1,286 @@
+package lock
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/hasher"
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+	"github.com/nightconcept/almandine/internal/core/paths"
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+// setupLockTestEnvironment writes project.toml, almd-lock.toml and dependency files into a
+// temporary directory and isolates the cache and config directories.
+func setupLockTestEnvironment(t *testing.T, projectToml, lockToml string, files map[string]string) string {
+	t.Helper()
+	tempDir := t.TempDir()
+	t.Setenv(paths.CacheDirEnv, t.TempDir())
+	t.Setenv(paths.ConfigDirEnv, t.TempDir())
+	t.Setenv(paths.StateDirEnv, t.TempDir())
+
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, config.ProjectTomlName), []byte(projectToml), 0644))
+	if lockToml != "" {
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, config.LockfileName), []byte(lockToml), 0644))
+	}
+	for rel, content := range files {
+		abs := filepath.Join(tempDir, rel)
+		require.NoError(t, os.MkdirAll(filepath.Dir(abs), 0755))
+		require.NoError(t, os.WriteFile(abs, []byte(content), 0644))
+	}
+	return tempDir
+}
+
+// runLockRefresh runs 'almd lock refresh' in dir and returns its captured stdout.
+func runLockRefresh(t *testing.T, dir string, args ...string) (string, error) {
+	t.Helper()
+	return runLock(t, dir, "refresh", args...)
+}
+
+// runLock runs an 'almd lock' subcommand in dir and returns its captured stdout.
+func runLock(t *testing.T, dir, subcommand string, args ...string) (string, error) {
+	t.Helper()
+	t.Setenv("NO_COLOR", "1")
+	originalWD, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	originalStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	defer func() {
+		os.Stdout = originalStdout
+		_ = os.Chdir(originalWD)
+	}()
+
+	app := &cli.App{
+		Commands:       []*cli.Command{LockCmd()},
+		ExitErrHandler: func(_ *cli.Context, _ error) {},
+	}
+	runErr := app.Run(append([]string{"almd", "lock", subcommand}, args...))
+	_ = w.Close()
+	var out bytes.Buffer
+	_, _ = out.ReadFrom(r)
+	return out.String(), runErr
+}
+
+func sha(t *testing.T, content string) string {
+	t.Helper()
+	h, err := hasher.CalculateSHA256([]byte(content))
+	require.NoError(t, err)
+	return h
+}
+
+func TestLockRefresh_HashesFilesOnDisk(t *testing.T) {
+	projectToml := `
+[package]
+name = "test"
+
+[dependencies]
+lib = { source = "github:owner/repo/lib.lua@main", path = "libs/lib.lua" }
+gone = { source = "github:owner/repo/gone.lua@main", path = "libs/gone.lua" }
+`
+	lockToml := `
+api_version = "1"
+
+[package.stale]
+source = "https://example.com/stale.lua"
+path = "libs/stale.lua"
+hash = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+`
+	dir := setupLockTestEnvironment(t, projectToml, lockToml, map[string]string{"libs/lib.lua": "return {}"})
+
+	out, err := runLockRefresh(t, dir, "--dry-run")
+	require.NoError(t, err)
+	assert.Contains(t, out, "locked    lib content hash")
+	assert.Contains(t, out, "skipped   gone")
+	assert.Contains(t, out, "removed   stale")
+	lf, err := lockfile.Load(dir)
+	require.NoError(t, err)
+	assert.Contains(t, lf.Package, "stale", "--dry-run must not write the lockfile")
+
+	_, err = runLockRefresh(t, dir)
+	require.NoError(t, err)
+	lf, err = lockfile.Load(dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]lockfile.PackageEntry{
+		"lib": {Source: "https://raw.githubusercontent.com/owner/repo/main/lib.lua", Path: "libs/lib.lua", Hash: sha(t, "return {}")},
+	}, lf.Package)
+
+	out, err = runLockRefresh(t, dir)
+	require.NoError(t, err)
+	assert.Contains(t, out, "almd-lock.toml is already up to date.")
+}
+
+func TestLockRefresh_ResolveLocksMatchingCommit(t *testing.T) {
+	const commit = "0123456789abcdef0123456789abcdef01234567"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/repo/commits":
+			_, _ = fmt.Fprintf(w, `[{"sha": "%s"}]`, commit)
+		case "/owner/repo/" + commit + "/lib.lua", "/owner/repo/" + commit + "/edited.lua":
+			_, _ = w.Write([]byte("upstream"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	source.SetTestModeBypassHostValidation(true)
+	defer source.SetTestModeBypassHostValidation(false)
+	originalBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = server.URL
+	defer func() { source.GithubAPIBaseURL = originalBaseURL }()
+
+	projectToml := fmt.Sprintf(`
+[package]
+name = "test"
+
+[dependencies]
+lib = { source = "%[1]s/owner/repo/main/lib.lua", path = "lib.lua" }
+edited = { source = "%[1]s/owner/repo/main/edited.lua", path = "edited.lua" }
+`, server.URL)
+	dir := setupLockTestEnvironment(t, projectToml, "", map[string]string{
+		"lib.lua":    "upstream",
+		"edited.lua": "patched locally",
+	})
+
+	out, err := runLockRefresh(t, dir, "--resolve")
+	require.NoError(t, err)
+	assert.Contains(t, out, "locked    lib matches upstream commit 0123456")
+	assert.Contains(t, out, "locked    edited content hash (differs from upstream at commit 0123456)")
+
+	lf, err := lockfile.Load(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "commit:"+commit, lf.Package["lib"].Hash)
+	assert.Equal(t, sha(t, "patched locally"), lf.Package["edited"].Hash)
+}
+
+func TestLockRepair(t *testing.T) {
+	projectToml := `
+[package]
+name = "test"
+
+[dependencies]
+lib = { source = "https://example.com/lib.lua", path = "libs/lib.lua" }
+ok = { source = "https://example.com/ok.lua", path = "libs/ok.lua" }
+`
+	lockToml := `
+api_version = "1"
+
+[package.lib]
+source = "https://example.com/lib.lua"
+path = "libs/lib.lua"
+hash = "d41d8cd98f00b204e9800998ecf8427e"
+
+[package.ok]
+source = "https://example.com/ok.lua"
+path = "libs/ok.lua"
+hash = "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+[package.orphan]
+source = "https://example.com/orphan.lua"
+path = "libs/orphan.lua"
+hash = "md5:123"
+`
+	dir := setupLockTestEnvironment(t, projectToml, lockToml, map[string]string{"libs/lib.lua": "return {}", "libs/ok.lua": "return 1"})
+
+	out, err := runLock(t, dir, "repair", "--dry-run")
+	require.NoError(t, err)
+	assert.Contains(t, out, "locked    lib content hash")
+	assert.Contains(t, out, "removed   orphan")
+	assert.NotContains(t, out, " ok ", "well-formed entries are left alone")
+	lf, err := lockfile.Load(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"lib", "orphan"}, lf.MalformedNames(), "--dry-run must not write the lockfile")
+
+	_, err = runLock(t, dir, "repair")
+	require.NoError(t, err)
+	lf, err = lockfile.Load(dir)
+	require.NoError(t, err)
+	assert.Empty(t, lf.Malformed)
+	assert.Equal(t, sha(t, "return {}"), lf.Package["lib"].Hash)
+	assert.Equal(t, "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", lf.Package["ok"].Hash)
+	assert.NotContains(t, lf.Package, "orphan")
+
+	out, err = runLock(t, dir, "repair")
+	require.NoError(t, err)
+	assert.Contains(t, out, "almd-lock.toml has no malformed entries.")
+
+	// A named entry is rebuilt even when it is well formed.
+	_, err = runLock(t, dir, "repair", "ok")
+	require.NoError(t, err)
+	lf, err = lockfile.Load(dir)
+	require.NoError(t, err)
+	assert.Equal(t, sha(t, "return 1"), lf.Package["ok"].Hash)
+
+	_, err = runLock(t, dir, "repair", "missing")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "dependency 'missing' is in neither")
+}
+
+func TestLockMerge(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte("api_version = \"1\"\n"+content), 0644))
+		return path
+	}
+	entry := func(name, hash string) string {
+		return fmt.Sprintf("\n[package.%s]\nsource = \"https://example.com/%[1]s.lua\"\npath = \"libs/%[1]s.lua\"\nhash = \"%s\"\n", name, hash)
+	}
+	hash := func(digit string) string { return "sha256:" + strings.Repeat(digit, 64) }
+	base := write("base.toml", entry("a", hash("1"))+entry("b", hash("1")))
+	ours := write("ours.toml", entry("a", hash("2"))+entry("b", hash("1")))
+	theirs := write("theirs.toml", entry("a", hash("1"))+entry("b", hash("3"))+entry("c", hash("1")))
+
+	app := &cli.App{Commands: []*cli.Command{LockCmd()}, ExitErrHandler: func(_ *cli.Context, _ error) {}}
+	require.NoError(t, app.Run([]string{"almd", "lock", "merge", base, ours, theirs}))
+	merged, err := lockfile.LoadFile(ours)
+	require.NoError(t, err)
+	assert.Equal(t, hash("2"), merged.Package["a"].Hash)
+	assert.Equal(t, hash("3"), merged.Package["b"].Hash)
+	assert.Contains(t, merged.Package, "c")
+	assert.Equal(t, "a "+hash("2")+" libs/a.lua https://example.com/a.lua\n", renderLockfile(&lockfile.Lockfile{
+		Package: map[string]lockfile.PackageEntry{"a": merged.Package["a"]},
+	}))
+
+	conflicting := write("conflict.toml", entry("a", hash("4")))
+	err = app.Run([]string{"almd", "lock", "merge", base, ours, conflicting})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "both sides changed a")
+}
+
+func TestLockSign(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	require.NoError(t, os.WriteFile(lockfile.LockfileName, []byte(`api_version = "1"
+
+[package.a]
+source = "https://example.com/a.lua"
+path = "libs/a.lua"
+hash = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+`), 0644))
+
+	app := &cli.App{Commands: []*cli.Command{LockCmd()}, ExitErrHandler: func(_ *cli.Context, _ error) {}}
+	require.NoError(t, app.Run([]string{"almd", "lock", "sign"}))
+	signed, err := lockfile.Load(".")
+	require.NoError(t, err)
+	assert.True(t, signed.Signed())
+	require.NoError(t, signed.VerifyChecksum())
+
+	require.NoError(t, app.Run([]string{"almd", "lock", "sign", "--remove"}))
+	unsigned, err := lockfile.Load(".")
+	require.NoError(t, err)
+	assert.False(t, unsigned.Signed())
+}
@@ -0,0 +1,100 @@
+package lock
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+)
+
+// mergeCmd is the git merge driver for almd-lock.toml registered by 'almd gitconfig install'.
+func mergeCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "merge",
+		Usage:     "Merge two versions of the lockfile (git merge driver)",
+		ArgsUsage: "BASE OURS THEIRS",
+		Description: "Merges almd-lock.toml package by package and writes the result to OURS, as git expects\n" +
+			"of a merge driver (%O %A %B). A package changed on only one side takes that side's entry.\n" +
+			"Packages changed differently on both sides keep our entry and make the merge fail, so git\n" +
+			"reports a conflict; run 'almd install' after resolving project.toml to re-lock them.",
+		Action: mergeAction,
+	}
+}
+
+// showCmd renders a lockfile as one line per package, for use as a git diff textconv.
+func showCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "show",
+		Usage:     "Print a lockfile as one line per package (git diff textconv)",
+		ArgsUsage: "[FILE]",
+		Action:    showAction,
+	}
+}
+
+func mergeAction(c *cli.Context) error {
+	if c.NArg() != 3 {
+		return cli.Exit("Error: expected BASE, OURS and THEIRS lockfile paths", 1)
+	}
+	var lockfiles [3]*lockfile.Lockfile
+	for i, path := range c.Args().Slice() {
+		lf, err := lockfile.LoadFile(path)
+		if err != nil {
+			return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+		}
+		lockfiles[i] = lf
+	}
+
+	merged, conflicts := lockfile.Merge(lockfiles[0], lockfiles[1], lockfiles[2])
+	if lockfiles[1].Signed() || lockfiles[2].Signed() {
+		if err := merged.Sign(); err != nil { // Keep the merge result signed for its own content
+			return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+		}
+	}
+	if err := lockfile.SaveFile(c.Args().Get(1), merged); err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	if len(conflicts) > 0 {
+		return cli.Exit(fmt.Sprintf("%s: both sides changed %s; kept our entries. Resolve %s and run 'almd install %s' to re-lock them.",
+			lockfile.LockfileName, strings.Join(conflicts, ", "), config.ManifestName(), strings.Join(conflicts, " ")), 1)
+	}
+	return nil
+}
+
+func showAction(c *cli.Context) error {
+	path := c.Args().First()
+	if path == "" {
+		path = lockfile.LockfileName
+	}
+	lf, err := lockfile.LoadFile(path)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	_, _ = fmt.Fprint(os.Stdout, renderLockfile(lf))
+	return nil
+}
+
+// renderLockfile lists the packages of lf in name order, one line each, so that a diff shows
+// exactly which packages changed.
+func renderLockfile(lf *lockfile.Lockfile) string {
+	names := make([]string, 0, len(lf.Package))
+	for name := range lf.Package {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		entry := lf.Package[name]
+		_, _ = fmt.Fprintf(&b, "%s %s %s %s", name, entry.Hash, entry.Path, entry.Source)
+		if entry.Transform != "" {
+			_, _ = fmt.Fprintf(&b, " transform=%s %s", entry.Transform, entry.TransformedHash)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,100 @@
+package lock
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/cli/completion"
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/warnings"
+)
+
+func repairCmd() *cli.Command {
+	return &cli.Command{
+		Name:         "repair",
+		Usage:        "Rebuild malformed lockfile entries from the dependency files on disk and upstream",
+		ArgsUsage:    "[dependency...]",
+		BashComplete: completion.Dependencies,
+		Description: "Rebuilds every entry of almd-lock.toml whose hashes are malformed, such as a hash without\n" +
+			"a commit: or sha256: prefix, or the entries of the named dependencies. Each is rebuilt as\n" +
+			"'almd lock refresh --resolve' would: locked to the upstream commit when the file on disk\n" +
+			"matches it, and otherwise by the hash of the file on disk. Entries of dependencies no\n" +
+			"longer in project.toml are removed.",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "dry-run", Usage: "Show the entries that would be written without changing the lockfile"},
+		},
+		Action: repairAction,
+	}
+}
+
+func repairAction(c *cli.Context) error {
+	proj, lf, err := loadProjectAndLockfile()
+	if err != nil {
+		return err
+	}
+	names, err := selectRepairs(proj, lf, c.Args().Slice())
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	if len(names) == 0 {
+		_, _ = fmt.Fprintf(os.Stdout, "%s has no malformed entries.\n", lockfile.LockfileName)
+		return nil
+	}
+
+	var results []refreshed
+	var removed []string
+	failed := 0
+	for _, name := range names {
+		dep, ok := proj.Dependencies[name]
+		if !ok {
+			delete(lf.Package, name)
+			removed = append(removed, name)
+			continue
+		}
+		r := refreshDependency(".", name, dep, true)
+		if r.Locked {
+			lf.Package[name] = r.Entry
+		} else {
+			warnings.Printf("could not repair '%s': %s", name, r.Detail)
+			failed++
+		}
+		results = append(results, r)
+	}
+
+	report(results, removed)
+	switch {
+	case failed == len(names):
+		// Nothing was rebuilt, so there is nothing to write.
+	case c.Bool("dry-run"):
+		_, _ = fmt.Fprintf(os.Stdout, "Dry run: %s was not changed.\n", lockfile.LockfileName)
+	default:
+		if err := lockfile.Save(".", lf); err != nil {
+			return cli.Exit(fmt.Sprintf("Error writing %s: %v", lockfile.LockfileName, err), 1)
+		}
+		_, _ = fmt.Fprintf(os.Stdout, "Wrote %s.\n", lockfile.LockfileName)
+	}
+	if failed > 0 {
+		return cli.Exit(fmt.Sprintf("Could not repair %d of %d entries; run 'almd install --force' on them to reinstall and lock them again.", failed, len(names)), exitcode.Integrity)
+	}
+	return nil
+}
+
+// selectRepairs returns the named dependencies, each of which must be in project.toml or the
+// lockfile, or the malformed entries of the lockfile when none are named.
+func selectRepairs(proj *project.Project, lf *lockfile.Lockfile, args []string) ([]string, error) {
+	if len(args) == 0 {
+		return lf.MalformedNames(), nil
+	}
+	for _, name := range args {
+		_, declared := proj.Dependencies[name]
+		if _, locked := lf.Package[name]; !declared && !locked {
+			return nil, fmt.Errorf("dependency '%s' is in neither %s nor %s", name, config.ManifestName(), lockfile.LockfileName)
+		}
+	}
+	return args, nil
+}
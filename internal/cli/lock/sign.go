@@ -0,0 +1,50 @@
+package lock
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+)
+
+// signCmd stamps almd-lock.toml with the checksum of its content.
+func signCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "sign",
+		Usage: "Stamp the lockfile with a checksum of its content so hand edits are detected",
+		Description: "Records the hash of almd-lock.toml's canonical content in its checksum field. Once a\n" +
+			"lockfile is signed, almd keeps the stamp up to date whenever it writes the lockfile, and\n" +
+			"'almd verify' and frozen installs fail when the lockfile was changed by other means. Run it\n" +
+			"again after reviewing such a change to accept it; --remove drops the stamp.",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "remove", Usage: "Remove the checksum instead of writing it"},
+		},
+		Action: signAction,
+	}
+}
+
+func signAction(c *cli.Context) error {
+	if _, err := os.Stat(lockfile.LockfileName); err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	lf, err := lockfile.Load(".")
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error loading %s: %v", lockfile.LockfileName, err), 1)
+	}
+	if c.Bool("remove") {
+		lf.Checksum = ""
+	} else if err := lf.Sign(); err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	if err := lockfile.Save(".", lf); err != nil {
+		return cli.Exit(fmt.Sprintf("Error writing %s: %v", lockfile.LockfileName, err), 1)
+	}
+	if lf.Signed() {
+		_, _ = fmt.Fprintf(os.Stdout, "Signed %s (%s).\n", lockfile.LockfileName, lf.Checksum)
+	} else {
+		_, _ = fmt.Fprintf(os.Stdout, "Removed the checksum from %s.\n", lockfile.LockfileName)
+	}
+	return nil
+}
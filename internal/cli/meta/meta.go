@@ -0,0 +1,95 @@
+// Package meta implements the 'meta' command, which reads and writes the [package] fields of the
+// project manifest (name, version, description, license) for scripts such as release pipelines.
+// Writes edit the manifest text in place, so its comments and layout are kept.
+package meta
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/tomledit"
+)
+
+// MetaCmd returns the 'meta' command with its get and set subcommands.
+func MetaCmd() *cli.Command {
+	fields := strings.Join(project.PackageFields, ", ")
+	return &cli.Command{
+		Name:  "meta",
+		Usage: "Read or change the project's [package] metadata",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "get",
+				Usage:     "Print a [package] field, or every field as tab-separated lines (" + fields + ")",
+				ArgsUsage: "[FIELD]",
+				Action:    getAction,
+			},
+			{
+				Name:      "set",
+				Usage:     "Validate and store a [package] field, keeping the manifest's comments (" + fields + ")",
+				ArgsUsage: "FIELD VALUE",
+				Action:    setAction,
+			},
+		},
+	}
+}
+
+func getAction(c *cli.Context) error {
+	if c.NArg() > 1 {
+		return cli.Exit("Error: expected at most one field", 1)
+	}
+	proj, err := config.LoadProjectToml(".")
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+
+	if field := c.Args().First(); field != "" {
+		value, err := proj.Package.Field(field)
+		if err != nil {
+			return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+		}
+		_, _ = fmt.Fprintln(os.Stdout, value)
+		return nil
+	}
+	for _, field := range project.PackageFields {
+		value, _ := proj.Package.Field(field)
+		_, _ = fmt.Fprintf(os.Stdout, "%s\t%s\n", field, value)
+	}
+	return nil
+}
+
+func setAction(c *cli.Context) error {
+	if c.NArg() != 2 {
+		return cli.Exit("Error: expected a field and a value, e.g. 'almd meta set version 1.2.0'", 1)
+	}
+	field, value := c.Args().Get(0), c.Args().Get(1)
+	if err := project.ValidatePackageField(field, value); err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+
+	if err := config.RequireWritable("."); err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), exitcode.Usage)
+	}
+	path := config.ManifestPath(".")
+	info, err := os.Stat(path)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	updated, err := tomledit.SetString(data, "package", field, value)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error updating %s: %v", config.ManifestName(), err), 1)
+	}
+	if err := os.WriteFile(path, updated, info.Mode().Perm()); err != nil {
+		return cli.Exit(fmt.Sprintf("Error writing %s: %v", config.ManifestName(), err), 1)
+	}
+	return nil
+}
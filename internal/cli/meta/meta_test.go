@@ -0,0 +1,89 @@
+package meta
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+const manifest = `# Release metadata
+[package]
+name = "game"
+version = "0.1.0" # bumped by CI
+
+[dependencies]
+`
+
+// runMetaCommand runs 'meta' in dir and returns captured stdout.
+func runMetaCommand(t *testing.T, dir string, args ...string) (string, error) {
+	t.Helper()
+	t.Chdir(dir)
+	stdoutR, stdoutW, err := os.Pipe()
+	require.NoError(t, err)
+	oldStdout := os.Stdout
+	os.Stdout = stdoutW
+	defer func() { os.Stdout = oldStdout }()
+
+	app := &cli.App{
+		Commands:       []*cli.Command{MetaCmd()},
+		ExitErrHandler: func(_ *cli.Context, _ error) {},
+	}
+	runErr := app.Run(append([]string{"almd", "meta"}, args...))
+	_ = stdoutW.Close()
+	var out bytes.Buffer
+	_, _ = out.ReadFrom(stdoutR)
+	_ = stdoutR.Close()
+	return out.String(), runErr
+}
+
+func TestMetaGet(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "project.toml"), []byte(manifest), 0644))
+
+	out, err := runMetaCommand(t, dir, "get", "version")
+	require.NoError(t, err)
+	assert.Equal(t, "0.1.0\n", out)
+
+	out, err = runMetaCommand(t, dir, "get")
+	require.NoError(t, err)
+	assert.Equal(t, "name\tgame\nversion\t0.1.0\ndescription\t\nlicense\t\n", out)
+
+	_, err = runMetaCommand(t, dir, "get", "homepage")
+	assert.ErrorContains(t, err, "unknown package field 'homepage'")
+}
+
+func TestMetaSet(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "project.toml")
+	require.NoError(t, os.WriteFile(path, []byte(manifest), 0644))
+
+	_, err := runMetaCommand(t, dir, "set", "version", "0.2.0")
+	require.NoError(t, err)
+	_, err = runMetaCommand(t, dir, "set", "license", "MIT OR Apache-2.0")
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `# Release metadata
+[package]
+name = "game"
+version = "0.2.0" # bumped by CI
+license = "MIT OR Apache-2.0"
+
+[dependencies]
+`, string(data))
+
+	_, err = runMetaCommand(t, dir, "set", "version", "two")
+	assert.ErrorContains(t, err, "not a semantic version")
+	_, err = runMetaCommand(t, dir, "set", "license", "GPL")
+	assert.ErrorContains(t, err, "unknown SPDX license identifier 'GPL'")
+
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, data, after, "rejected values must not touch the manifest")
+}
@@ -0,0 +1,83 @@
+// Package paths implements the 'paths' command, which prints where the project's dependencies
+// are installed in forms other tools consume: one directory per line, a Lua package.path
+// string, or a shell export of LUA_PATH.
+package paths
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+)
+
+// PathsCmd returns the 'paths' command.
+func PathsCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "paths",
+		Usage: "Print the directories dependencies are installed in, or them as a Lua package.path",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "lua", Usage: "Print a package.path string with ?.lua and ?/init.lua entries for each directory"},
+			&cli.BoolFlag{Name: "export", Usage: "Print a shell command that sets LUA_PATH, keeping Lua's default path after the project's"},
+			&cli.BoolFlag{Name: "absolute", Usage: "Print absolute paths instead of paths relative to the project root"},
+		},
+		Action: pathsAction,
+	}
+}
+
+func pathsAction(c *cli.Context) error {
+	proj, err := config.LoadProjectToml(".")
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error loading %s: %v", config.ManifestName(), err), exitcode.Usage)
+	}
+	dirs := proj.DependencyDirs()
+	if c.Bool("absolute") {
+		root, err := os.Getwd()
+		if err != nil {
+			return cli.Exit(fmt.Sprintf("Error: %v", err), exitcode.Usage)
+		}
+		for i, dir := range dirs {
+			dirs[i] = filepath.Join(root, filepath.FromSlash(dir))
+		}
+	}
+
+	switch {
+	case c.Bool("export"):
+		_, _ = fmt.Fprintf(os.Stdout, "export LUA_PATH=%s\n", shellQuote(luaPath(dirs)+";;"))
+	case c.Bool("lua"):
+		_, _ = fmt.Fprintln(os.Stdout, luaPath(dirs))
+	default:
+		printLines(os.Stdout, dirs)
+	}
+	return nil
+}
+
+// luaPath builds a package.path string that finds modules as dir/name.lua and dir/name/init.lua
+// in each of dirs.
+func luaPath(dirs []string) string {
+	entries := make([]string, 0, 2*len(dirs))
+	for _, dir := range dirs {
+		sep := "/"
+		if strings.Contains(dir, `\`) {
+			sep = `\`
+		}
+		entries = append(entries, dir+sep+"?.lua", dir+sep+"?"+sep+"init.lua")
+	}
+	return strings.Join(entries, ";")
+}
+
+// shellQuote quotes s for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func printLines(w io.Writer, lines []string) {
+	for _, line := range lines {
+		_, _ = fmt.Fprintln(w, line)
+	}
+}
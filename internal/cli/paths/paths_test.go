@@ -0,0 +1,61 @@
+package paths
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+const pathsProjectToml = `[package]
+name = "game"
+version = "0.1.0"
+
+[dependencies]
+json = { source = "https://example.com/json.lua", path = "src/lib/json.lua" }
+class = { source = "https://example.com/class.lua", path = "src/lib/class.lua" }
+inspect = { source = "https://example.com/inspect.lua", path = "vendor/inspect.lua" }
+`
+
+// runPathsCommand runs 'paths' with args in dir and returns captured stdout.
+func runPathsCommand(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	t.Chdir(dir)
+	stdoutR, stdoutW, err := os.Pipe()
+	require.NoError(t, err)
+	oldStdout := os.Stdout
+	os.Stdout = stdoutW
+	defer func() { os.Stdout = oldStdout }()
+
+	app := &cli.App{Commands: []*cli.Command{PathsCmd()}, ExitErrHandler: func(*cli.Context, error) {}}
+	runErr := app.Run(append([]string{"almd", "paths"}, args...))
+	_ = stdoutW.Close()
+	var out bytes.Buffer
+	_, _ = out.ReadFrom(stdoutR)
+	_ = stdoutR.Close()
+	require.NoError(t, runErr)
+	return out.String()
+}
+
+func TestPathsCommand(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "project.toml"), []byte(pathsProjectToml), 0644))
+
+	assert.Equal(t, "src/lib\nvendor\n", runPathsCommand(t, dir))
+	assert.Equal(t, "src/lib/?.lua;src/lib/?/init.lua;vendor/?.lua;vendor/?/init.lua\n", runPathsCommand(t, dir, "--lua"))
+	assert.Equal(t, "export LUA_PATH='src/lib/?.lua;src/lib/?/init.lua;vendor/?.lua;vendor/?/init.lua;;'\n", runPathsCommand(t, dir, "--export"))
+
+	absolute := strings.Split(strings.TrimSpace(runPathsCommand(t, dir, "--absolute")), "\n")
+	require.Len(t, absolute, 2)
+	assert.True(t, filepath.IsAbs(absolute[0]))
+	assert.Equal(t, "vendor", filepath.Base(absolute[1]))
+}
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, `'it'\''s/?.lua'`, shellQuote("it's/?.lua"))
+}
@@ -0,0 +1,216 @@
+// Package recursive runs a command in every project below the current directory ('almd -r').
+//
+// Projects are the directories containing a manifest, project.toml or almd.toml (see
+// config.HasManifest). Hidden directories and node_modules are never searched, and the patterns
+// in a .gitignore or .almdignore file at the top of the tree exclude further directories. Each project's output is prefixed with its path, and the command
+// exits with the most specific exit code of any project (see exitcode.MostSpecific).
+package recursive
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+	"github.com/nightconcept/almandine/internal/core/theme"
+)
+
+// FlagName is the global flag that enables recursive mode.
+const FlagName = "recursive"
+
+// ignoreFiles hold directory patterns to skip, one per line, read from the top of the tree.
+var ignoreFiles = []string{".gitignore", ".almdignore"}
+
+// Wrap makes cmd honor the global --recursive flag. Without the flag cmd runs as before.
+func Wrap(cmd *cli.Command) *cli.Command {
+	action := cmd.Action
+	cmd.Action = func(c *cli.Context) error {
+		if !c.Bool(FlagName) {
+			return action(c)
+		}
+		return runEach(c, cmd.Name, action)
+	}
+	return cmd
+}
+
+// Discover returns the directories below root that contain a project.toml, relative to root and
+// in sorted order. Root itself is reported as ".".
+func Discover(root string) ([]string, error) {
+	patterns := loadIgnorePatterns(root)
+	var projects []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		rel, relErr := filepath.Rel(root, p)
+		if relErr != nil {
+			return relErr
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "." && ignored(rel, d.Name(), patterns) {
+			return filepath.SkipDir
+		}
+		if config.HasManifest(p) {
+			projects = append(projects, rel)
+		}
+		return nil
+	})
+	sort.Strings(projects)
+	return projects, err
+}
+
+// loadIgnorePatterns reads the ignore files at root. Negated patterns are not supported and skipped.
+func loadIgnorePatterns(root string) []string {
+	var patterns []string
+	for _, name := range ignoreFiles {
+		data, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+				continue
+			}
+			if pattern := strings.Trim(line, "/"); pattern != "" {
+				patterns = append(patterns, pattern)
+			}
+		}
+	}
+	return patterns
+}
+
+// ignored reports whether the directory at rel (slash separated) is skipped. Patterns containing
+// a slash match the whole relative path, others match the directory name at any depth.
+func ignored(rel, name string, patterns []string) bool {
+	if strings.HasPrefix(name, ".") || name == "node_modules" {
+		return true
+	}
+	for _, pattern := range patterns {
+		target := name
+		if strings.Contains(pattern, "/") {
+			target = rel
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// runEach runs action once per discovered project, from inside the project's directory.
+func runEach(c *cli.Context, name string, action cli.ActionFunc) error {
+	projects, err := Discover(".")
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error searching for projects: %v", err), 1)
+	}
+	if len(projects) == 0 {
+		return cli.Exit(fmt.Sprintf("Error: no %s found in this directory or below it", config.ManifestName()), 1)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+
+	code := 0
+	var failed []string
+	for _, project := range projects {
+		if projectCode := runInProject(c, wd, project, action); projectCode != 0 {
+			code = exitcode.MostSpecific(code, projectCode)
+			failed = append(failed, project)
+		}
+	}
+
+	summary := fmt.Sprintf("Ran '%s' in %d project(s)", name, len(projects))
+	if len(failed) == 0 {
+		_, _ = fmt.Fprintln(os.Stderr, summary+".")
+		return nil
+	}
+	return cli.Exit(fmt.Sprintf("%s; %d failed: %s", summary, len(failed), strings.Join(failed, ", ")), code)
+}
+
+// runInProject runs action in project with its output prefixed, and returns its exit code.
+func runInProject(c *cli.Context, wd, project string, action cli.ActionFunc) int {
+	if err := os.Chdir(filepath.Join(wd, filepath.FromSlash(project))); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer func() { _ = os.Chdir(wd) }()
+
+	restore := prefixOutput(theme.SprintFunc(theme.ProjectPath)("["+project+"]") + " ")
+	err := action(c)
+	if err != nil && err.Error() != "" {
+		_, _ = fmt.Fprintln(os.Stderr, err.Error())
+	}
+	restore()
+
+	if err == nil {
+		return 0
+	}
+	var exitErr cli.ExitCoder
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
+// prefixOutput redirects stdout and stderr, including the writers used for colored output,
+// through pipes that prefix every line. The returned function restores them and flushes.
+func prefixOutput(prefix string) (restore func()) {
+	origStdout, origStderr := os.Stdout, os.Stderr
+	origColorOutput, origColorError := color.Output, color.Error
+	rOut, wOut, errOut := os.Pipe()
+	rErr, wErr, errErr := os.Pipe()
+	if errOut != nil || errErr != nil {
+		return func() {} // Run without prefixes rather than not at all
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go copyPrefixed(&wg, origStdout, rOut, prefix)
+	go copyPrefixed(&wg, origStderr, rErr, prefix)
+	os.Stdout, os.Stderr = wOut, wErr
+	color.Output, color.Error = wOut, wErr
+
+	return func() {
+		os.Stdout, os.Stderr = origStdout, origStderr
+		color.Output, color.Error = origColorOutput, origColorError
+		_ = wOut.Close()
+		_ = wErr.Close()
+		wg.Wait()
+		_ = rOut.Close()
+		_ = rErr.Close()
+	}
+}
+
+func copyPrefixed(wg *sync.WaitGroup, dst io.Writer, src io.Reader, prefix string) {
+	defer wg.Done()
+	reader := bufio.NewReader(src)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			if !strings.HasSuffix(line, "\n") {
+				line += "\n"
+			}
+			_, _ = io.WriteString(dst, prefix+line)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package recursive
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0644))
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"project.toml":                   "",
+		"games/a/project.toml":           "",
+		"games/c/almd.toml":              "",
+		"libs/b/project.toml":            "",
+		"libs/b/fixtures/project.toml":   "",
+		"build/out/project.toml":         "",
+		".cache/project.toml":            "",
+		"node_modules/pkg/project.toml":  "",
+		"games/a/not-a-project/main.lua": "",
+		".gitignore":                     "# build output\n/build/\n!keep\n",
+		".almdignore":                    "libs/*/fixtures\n",
+	})
+
+	projects, err := Discover(root)
+	require.NoError(t, err)
+	assert.Equal(t, []string{".", "games/a", "games/c", "libs/b"}, projects)
+}
+
+func TestWrap(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"ok/project.toml": "", "broken/project.toml": ""})
+	originalWd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(root))
+	defer func() { _ = os.Chdir(originalWd) }()
+
+	cmd := Wrap(&cli.Command{
+		Name: "check",
+		Action: func(c *cli.Context) error {
+			wd, _ := os.Getwd()
+			fmt.Printf("checking %s\n", filepath.Base(wd))
+			if filepath.Base(wd) == "broken" {
+				return cli.Exit("Error: broken", 4)
+			}
+			return nil
+		},
+	})
+	app := &cli.App{
+		Name:           "almd",
+		Flags:          []cli.Flag{&cli.BoolFlag{Name: FlagName, Aliases: []string{"r"}}},
+		Commands:       []*cli.Command{cmd},
+		ExitErrHandler: func(*cli.Context, error) {},
+	}
+
+	stdout := captureStdout(t, func() {
+		err = app.Run([]string{"almd", "-r", "check"})
+	})
+	require.Error(t, err)
+	assert.Equal(t, 4, err.(cli.ExitCoder).ExitCode(), "the most specific exit code of any project")
+	assert.Equal(t, "Ran 'check' in 2 project(s); 1 failed: broken", err.Error())
+	assert.Equal(t, "[broken] checking broken\n[ok] checking ok\n", stdout)
+
+	stdout = captureStdout(t, func() {
+		require.NoError(t, os.Chdir(filepath.Join(root, "ok")))
+		err = app.Run([]string{"almd", "check"})
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "checking ok\n", stdout, "without -r only the current project is used")
+}
+
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	original := os.Stdout
+	os.Stdout = w
+	fn()
+	os.Stdout = original
+	require.NoError(t, w.Close())
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, r)
+	require.NoError(t, err)
+	return buf.String()
+}
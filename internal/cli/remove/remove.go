@@ -9,11 +9,16 @@ import (
 	"strings"
 	"time"
 
-	"github.com/fatih/color"
+	"github.com/nightconcept/almandine/internal/cli/completion"
 	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+	"github.com/nightconcept/almandine/internal/core/filemode"
+	"github.com/nightconcept/almandine/internal/core/inventory"
 	"github.com/nightconcept/almandine/internal/core/lockfile"
 	"github.com/nightconcept/almandine/internal/core/project"
 	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/nightconcept/almandine/internal/core/theme"
+	"github.com/nightconcept/almandine/internal/core/warnings"
 	"github.com/urfave/cli/v2"
 )
 
@@ -28,16 +33,16 @@ func isDirEmpty(path string) (bool, error) {
 func loadProjectConfigAndValidate(depName string) (proj *project.Project, depDetails project.Dependency, err error) {
 	proj, err = config.LoadProjectToml(".")
 	if err != nil {
-		return nil, project.Dependency{}, fmt.Errorf("failed to load %s: %w", config.ProjectTomlName, err)
+		return nil, project.Dependency{}, fmt.Errorf("failed to load %s: %w", config.ManifestName(), err)
 	}
 
 	if len(proj.Dependencies) == 0 {
-		return proj, project.Dependency{}, fmt.Errorf("no dependencies found in %s", config.ProjectTomlName)
+		return proj, project.Dependency{}, fmt.Errorf("no dependencies found in %s", config.ManifestName())
 	}
 
 	depDetails, ok := proj.Dependencies[depName]
 	if !ok {
-		return proj, project.Dependency{}, fmt.Errorf("dependency '%s' not found in %s", depName, config.ProjectTomlName)
+		return proj, project.Dependency{}, fmt.Errorf("dependency '%s' not found in %s", depName, config.ManifestName())
 	}
 	return proj, depDetails, nil
 }
@@ -45,15 +50,15 @@ func loadProjectConfigAndValidate(depName string) (proj *project.Project, depDet
 func updateManifest(proj *project.Project, depName string) error {
 	delete(proj.Dependencies, depName)
 	if err := config.WriteProjectToml(".", proj); err != nil {
-		return fmt.Errorf("failed to update %s: %w", config.ProjectTomlName, err)
+		return fmt.Errorf("failed to update %s: %w", config.ManifestName(), err)
 	}
 	return nil
 }
 
 func deleteDependencyFileAndCleanup(errWriter io.Writer, dependencyPath string) (fileDeleted bool) {
-	if err := os.Remove(dependencyPath); err != nil {
+	if err := filemode.Remove(dependencyPath); err != nil {
 		if !os.IsNotExist(err) {
-			_, _ = fmt.Fprintf(errWriter, "Warning: Failed to delete dependency file '%s': %v. Manifest updated.\n", dependencyPath, err)
+			warnings.Fprintf(errWriter, "Failed to delete dependency file '%s': %v. Manifest updated.", dependencyPath, err)
 		}
 		return false
 	}
@@ -62,7 +67,7 @@ func deleteDependencyFileAndCleanup(errWriter io.Writer, dependencyPath string)
 	currentDir := filepath.Dir(dependencyPath)
 	projectRootAbs, errAbs := filepath.Abs(".")
 	if errAbs != nil {
-		_, _ = fmt.Fprintf(errWriter, "Warning: Could not determine project root absolute path: %v. Skipping directory cleanup.\n", errAbs)
+		warnings.Fprintf(errWriter, "Could not determine project root absolute path: %v. Skipping directory cleanup.", errAbs)
 		return fileDeleted
 	}
 
@@ -70,7 +75,7 @@ func deleteDependencyFileAndCleanup(errWriter io.Writer, dependencyPath string)
 	for {
 		absCurrentDir, errLoopAbs := filepath.Abs(currentDir)
 		if errLoopAbs != nil {
-			_, _ = fmt.Fprintf(errWriter, "Warning: Could not get absolute path for '%s': %v. Stopping directory cleanup.\n", currentDir, errLoopAbs)
+			warnings.Fprintf(errWriter, "Could not get absolute path for '%s': %v. Stopping directory cleanup.", currentDir, errLoopAbs)
 			break
 		}
 		// Stop if currentDir is project root, or if its parent is itself (e.g. "/" or "C:\"), or if it's "."
@@ -79,14 +84,14 @@ func deleteDependencyFileAndCleanup(errWriter io.Writer, dependencyPath string)
 		}
 		empty, errEmpty := isDirEmpty(currentDir)
 		if errEmpty != nil {
-			_, _ = fmt.Fprintf(errWriter, "Warning: Could not check if directory '%s' is empty: %v. Stopping directory cleanup.\n", currentDir, errEmpty)
+			warnings.Fprintf(errWriter, "Could not check if directory '%s' is empty: %v. Stopping directory cleanup.", currentDir, errEmpty)
 			break
 		}
 		if !empty {
 			break
 		}
 		if errRemoveDir := os.Remove(currentDir); errRemoveDir != nil {
-			_, _ = fmt.Fprintf(errWriter, "Warning: Failed to remove empty directory '%s': %v. Stopping directory cleanup.\n", currentDir, errRemoveDir)
+			warnings.Fprintf(errWriter, "Failed to remove empty directory '%s': %v. Stopping directory cleanup.", currentDir, errRemoveDir)
 			break
 		}
 		currentDir = filepath.Dir(currentDir)
@@ -97,7 +102,7 @@ func deleteDependencyFileAndCleanup(errWriter io.Writer, dependencyPath string)
 func updateLockfile(errWriter io.Writer, depName string) (lockfileUpdated bool, lockfileLoadErr error) {
 	lf, err := lockfile.Load(".")
 	if err != nil {
-		_, _ = fmt.Fprintf(errWriter, "Warning: Failed to load %s: %v. Manifest and file processed.\n", lockfile.LockfileName, err)
+		warnings.Fprintf(errWriter, "Failed to load %s: %v. Manifest and file processed.", lockfile.LockfileName, err)
 		return false, err
 	}
 
@@ -105,7 +110,7 @@ func updateLockfile(errWriter io.Writer, depName string) (lockfileUpdated bool,
 		if _, depInLock := lf.Package[depName]; depInLock {
 			delete(lf.Package, depName)
 			if errSaveLock := lockfile.Save(".", lf); errSaveLock != nil {
-				_, _ = fmt.Fprintf(errWriter, "Warning: Failed to update %s: %v. Manifest and file processed.\n", lockfile.LockfileName, errSaveLock)
+				warnings.Fprintf(errWriter, "Failed to update %s: %v. Manifest and file processed.", lockfile.LockfileName, errSaveLock)
 				return false, err // Return original load error for note consistency
 			}
 			return true, nil
@@ -114,10 +119,57 @@ func updateLockfile(errWriter io.Writer, depName string) (lockfileUpdated bool,
 	return false, nil // Dependency not in lockfile, or lockfile was empty/nil package map
 }
 
+// installedByAlmd reports whether the inventory lists the file at dependencyPath as installed by
+// almd, warning when it does not, so a file of the user's that the manifest happens to point at
+// is never deleted.
+func installedByAlmd(errWriter io.Writer, dependencyPath string) bool {
+	inv, err := inventory.Load(".")
+	if err != nil {
+		warnings.Fprintf(errWriter, "Could not read %s: %v. Keeping '%s'.", inventory.Path, err, dependencyPath)
+		return false
+	}
+	if !inv.Manages(dependencyPath) {
+		warnings.Fprintf(errWriter, "'%s' was not installed by almd; keeping it.", dependencyPath)
+		return false
+	}
+	return true
+}
+
+// keepDependencyFile leaves the file of a removed dependency in place for the user to maintain,
+// lifting the read-only protection almd may have applied to it.
+func keepDependencyFile(errWriter io.Writer, dependencyPath string) {
+	if err := filemode.Unprotect(dependencyPath); err != nil && !os.IsNotExist(err) {
+		warnings.Fprintf(errWriter, "Could not make '%s' writable: %v.", dependencyPath, err)
+	}
+}
+
+// removeDependencyFiles deletes the files of a removed dependency, the one file or every file of
+// a directory dependency, or with keep leaves them in place, and releases them from the
+// inventory. Files almd did not install are kept. It reports whether every file was deleted and
+// whether any was kept.
+func removeDependencyFiles(errWriter io.Writer, dependencyPaths []string, keep bool) (fileDeleted, fileKept bool) {
+	fileDeleted, fileKept = true, keep
+	for _, dependencyPath := range dependencyPaths {
+		switch {
+		case keep:
+			keepDependencyFile(errWriter, dependencyPath)
+			fileDeleted = false
+		case !installedByAlmd(errWriter, dependencyPath):
+			fileDeleted, fileKept = false, true
+		default:
+			fileDeleted = deleteDependencyFileAndCleanup(errWriter, dependencyPath) && fileDeleted
+		}
+	}
+	if err := inventory.Release(".", dependencyPaths...); err != nil {
+		warnings.Fprintf(errWriter, "Could not update %s: %v.", inventory.Path, err)
+	}
+	return fileDeleted, fileKept
+}
+
 func printSummaryAndNotes(
 	c *cli.Context,
 	depName, dependencySource string,
-	fileDeleted, lockfileUpdated bool,
+	fileDeleted, fileKept, lockfileUpdated bool,
 	lockfileLoadErr error,
 	dependencyPath string,
 	startTime time.Time,
@@ -125,7 +177,7 @@ func printSummaryAndNotes(
 ) {
 	fmt.Println("Progress: resolved 0, reused 0, downloaded 0, removed 1, done")
 	fmt.Println()
-	_, _ = color.New(color.FgWhite, color.Bold).Println("dependencies:")
+	_, _ = theme.New(theme.Section).Println("dependencies:")
 
 	versionStr := "unknown"
 	parsedInfo, parseErr := source.ParseSourceURL(dependencySource)
@@ -133,12 +185,14 @@ func printSummaryAndNotes(
 		versionStr = parsedInfo.Ref
 	}
 
-	_, _ = color.New(color.FgRed).Printf("- %s %s\n", depName, versionStr)
+	_, _ = theme.New(theme.Removed).Printf("- %s %s\n", depName, versionStr)
 	fmt.Println()
 	duration := time.Since(startTime)
 	fmt.Printf("Done in %.1fs\n", duration.Seconds())
 
-	if !fileDeleted {
+	if fileKept {
+		fmt.Printf("Kept '%s'; almd no longer manages it.\n", dependencyPath)
+	} else if !fileDeleted {
 		_, _ = fmt.Fprintf(errWriter, "Note: Dependency file '%s' was not deleted (either not found or error during deletion).\n", dependencyPath)
 	}
 	// Note: lockfileLoadErr being non-nil implies lockfile was not loaded, hence not updated.
@@ -154,10 +208,14 @@ func printSummaryAndNotes(
 // RemoveCmd handles the 'remove' subcommand
 func RemoveCmd() *cli.Command {
 	return &cli.Command{
-		Name:      "remove",
-		Aliases:   []string{"rm", "uninstall", "un"},
-		Usage:     "Remove a dependency from the project",
-		ArgsUsage: "DEPENDENCY",
+		Name:         "remove",
+		Aliases:      []string{"rm", "uninstall", "un"},
+		Usage:        "Remove a dependency from the project",
+		ArgsUsage:    "DEPENDENCY",
+		BashComplete: completion.Dependencies,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "keep-files", Usage: "Remove the dependency from project.toml and the lockfile but leave its file on disk"},
+		},
 		Action: func(c *cli.Context) error {
 			startTime := time.Now()
 
@@ -177,15 +235,18 @@ func RemoveCmd() *cli.Command {
 			}
 			dependencyPath := depDetails.Path
 			dependencySource := depDetails.Source
+			if err := config.RequireWritable("."); err != nil {
+				return cli.Exit(fmt.Sprintf("Error: %v", err), exitcode.Usage)
+			}
 
 			if err := updateManifest(proj, depName); err != nil {
 				return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
 			}
 
-			fileDeleted := deleteDependencyFileAndCleanup(errWriter, dependencyPath)
+			fileDeleted, fileKept := removeDependencyFiles(errWriter, depDetails.FilePaths(), c.Bool("keep-files"))
 			lockfileUpdated, lockfileLoadErr := updateLockfile(errWriter, depName)
 
-			printSummaryAndNotes(c, depName, dependencySource, fileDeleted, lockfileUpdated, lockfileLoadErr, dependencyPath, startTime, errWriter)
+			printSummaryAndNotes(c, depName, dependencySource, fileDeleted, fileKept, lockfileUpdated, lockfileLoadErr, dependencyPath, startTime, errWriter)
 
 			return nil
 		},
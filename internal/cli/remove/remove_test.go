@@ -8,6 +8,7 @@ import (
 
 	"github.com/BurntSushi/toml"
 	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/inventory"
 	"github.com/nightconcept/almandine/internal/core/lockfile"
 	"github.com/nightconcept/almandine/internal/core/project"
 	"github.com/stretchr/testify/assert"
@@ -181,12 +182,12 @@ api_version = "1"
 [package.missinglib]
 source = "https://raw.githubusercontent.com/user/repo/def456/missing.lua"
 path = "libs/missinglib.lua"
-hash = "sha256:123"
+hash = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
 
 [package.anotherlib]
 source = "https://raw.githubusercontent.com/user/repo/ghi789/another.lua"
 path = "libs/anotherlib.lua"
-hash = "sha256:456"
+hash = "sha256:4444444444444444444444444444444444444444444444444444444444444444"
 `
 	// Setup environment: Create project.toml and almd-lock.toml
 	// but DO NOT create the actual 'missinglib.lua' file.
@@ -231,6 +232,87 @@ hash = "sha256:456"
 	assert.True(t, os.IsNotExist(err), "missinglib.lua should not exist")
 }
 
+// TestRemoveCommand_KeepFiles verifies that --keep-files drops the dependency from both
+// manifests but leaves its file on disk, writable, for the user to maintain.
+func TestRemoveCommand_KeepFiles(t *testing.T) {
+	originalWd, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.Chdir(originalWd))
+	}()
+
+	projectTomlContent := `
+[package]
+name = "test-project-keep-files"
+version = "0.1.0"
+
+[dependencies]
+keptlib = { source = "github:user/repo/kept.lua@abc123", path = "libs/keptlib.lua" }
+`
+	lockTomlContent := `
+api_version = "1"
+
+[package.keptlib]
+source = "https://raw.githubusercontent.com/user/repo/abc123/kept.lua"
+path = "libs/keptlib.lua"
+hash = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+`
+	tempDir := setupRemoveTestEnvironment(t, projectTomlContent, lockTomlContent, map[string]string{
+		"libs/keptlib.lua": "-- hand-maintained from now on",
+	})
+	keptPath := filepath.Join(tempDir, "libs", "keptlib.lua")
+	require.NoError(t, os.Chmod(keptPath, 0444))
+
+	require.NoError(t, os.Chdir(tempDir))
+	require.NoError(t, runRemoveCommand(t, tempDir, "--keep-files", "keptlib"))
+
+	proj, err := config.LoadProjectToml(tempDir)
+	require.NoError(t, err)
+	assert.NotContains(t, proj.Dependencies, "keptlib")
+	lf, err := lockfile.Load(tempDir)
+	require.NoError(t, err)
+	assert.NotContains(t, lf.Package, "keptlib")
+
+	info, err := os.Stat(keptPath)
+	require.NoError(t, err, "the file must be kept")
+	assert.NotZero(t, info.Mode().Perm()&0200, "the kept file is writable again")
+}
+
+// TestRemoveCommand_KeepsFilesNotInstalledByAlmd verifies that a dependency whose file is missing
+// from the inventory of installed files is removed from the manifests but its file is kept.
+func TestRemoveCommand_KeepsFilesNotInstalledByAlmd(t *testing.T) {
+	originalWd, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.Chdir(originalWd))
+	}()
+
+	projectTomlContent := `
+[package]
+name = "test-project-inventory"
+version = "0.1.0"
+
+[dependencies]
+vendored = { source = "github:user/repo/vendored.lua@main", path = "libs/vendored.lua" }
+mine = { source = "github:user/repo/mine.lua@main", path = "libs/mine.lua" }
+`
+	tempDir := setupRemoveTestEnvironment(t, projectTomlContent, "", map[string]string{
+		"libs/vendored.lua":                "return {}",
+		"libs/mine.lua":                    "-- written by hand",
+		filepath.FromSlash(inventory.Path): `{"files": ["libs/vendored.lua"]}`,
+	})
+
+	require.NoError(t, os.Chdir(tempDir))
+	require.NoError(t, runRemoveCommand(t, tempDir, "mine"))
+	assert.FileExists(t, filepath.Join(tempDir, "libs", "mine.lua"), "a file almd did not install is kept")
+
+	require.NoError(t, runRemoveCommand(t, tempDir, "vendored"))
+	assert.NoFileExists(t, filepath.Join(tempDir, "libs", "vendored.lua"))
+	inv, err := inventory.Load(tempDir)
+	require.NoError(t, err)
+	assert.False(t, inv.Manages("libs/vendored.lua"), "removed files leave the inventory")
+}
+
 // TestRemoveCommand_ProjectTomlNotFound verifies the command fails appropriately
 // when project.toml is missing from the working directory.
 func TestRemoveCommand_ProjectTomlNotFound(t *testing.T) {
@@ -285,7 +367,7 @@ api_version = "1"
 [package.anotherlib]
 source = "https://raw.githubusercontent.com/user/repo/mno345/another.lua"
 path = "libs/anotherlib.lua"
-hash = "sha256:789"
+hash = "sha256:7777777777777777777777777777777777777777777777777777777777777777"
 `
 	depFilesToCreate := map[string]string{
 		"libs/manifestonlylib.lua": "-- manifest only lib content",
@@ -0,0 +1,222 @@
+// Package run implements the 'run' command, a small task runner for the [scripts] table of the
+// project manifest. A script runs after the scripts it lists in depends_on, and each script runs
+// once per invocation however many others depend on it. With --parallel, scripts whose
+// dependencies have finished run at the same time and every line they print is prefixed with
+// the script's name.
+package run
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/shell"
+	"github.com/nightconcept/almandine/internal/core/theme"
+)
+
+// RunCmd returns the 'run' command.
+func RunCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "run",
+		Usage:     "Run scripts from the manifest's [scripts] table after the scripts they depend on",
+		ArgsUsage: "[script...]",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "parallel", Aliases: []string{"p"}, Usage: "Run scripts whose dependencies have finished at the same time, prefixing their output"},
+		},
+		Action: runAction,
+	}
+}
+
+func runAction(c *cli.Context) error {
+	proj, err := config.LoadProjectToml(".")
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error loading %s: %v", config.ManifestName(), err), exitcode.Usage)
+	}
+	if c.NArg() == 0 {
+		printScripts(os.Stdout, proj.Scripts)
+		return nil
+	}
+	order, err := project.ScriptOrder(proj.Scripts, c.Args().Slice())
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), exitcode.Usage)
+	}
+
+	r := &runner{scripts: proj.Scripts, stdout: os.Stdout, stderr: os.Stderr}
+	if c.Bool("parallel") {
+		err = r.runParallel(order)
+	} else {
+		err = r.runSequential(order)
+	}
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), exitcode.Usage)
+	}
+	return nil
+}
+
+// printScripts lists the scripts by name with their command and dependencies.
+func printScripts(w io.Writer, scripts map[string]project.Script) {
+	if len(scripts) == 0 {
+		_, _ = fmt.Fprintf(w, "No scripts found in %s.\n", config.ManifestName())
+		return
+	}
+	names := make([]string, 0, len(scripts))
+	for name := range scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	nameColor := theme.SprintFunc(theme.DepName)
+	for _, name := range names {
+		line := fmt.Sprintf("%s: %s", nameColor(name), scripts[name].Cmd)
+		if deps := scripts[name].DependsOn; len(deps) > 0 {
+			line += fmt.Sprintf(" (after %s)", strings.Join(deps, ", "))
+		}
+		_, _ = fmt.Fprintln(w, line)
+	}
+}
+
+// runner runs scripts of one project. exec, when set, replaces running the command through the
+// shell; tests use it.
+type runner struct {
+	scripts map[string]project.Script
+	stdout  io.Writer
+	stderr  io.Writer
+	exec    func(cmd string, stdout, stderr io.Writer) error
+}
+
+// runSequential runs the scripts of order one after the other, stopping at the first failure.
+func (r *runner) runSequential(order []string) error {
+	for _, name := range order {
+		_, _ = fmt.Fprintf(r.stderr, "> %s: %s\n", name, r.scripts[name].Cmd)
+		if err := r.run(name, r.stdout, r.stderr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scriptResult is the outcome of one script started by runParallel.
+type scriptResult struct {
+	name string
+	err  error
+}
+
+// runParallel runs the scripts of order as soon as the scripts they depend on have succeeded.
+// After a failure no further script is started; those already running are waited for, and the
+// first failure is returned.
+func (r *runner) runParallel(order []string) error {
+	width := 0
+	for _, name := range order {
+		width = max(width, len(name))
+	}
+	var mu sync.Mutex
+	results := make(chan scriptResult)
+	succeeded := make(map[string]bool)
+	pending := slices.Clone(order)
+	running := 0
+	var firstErr error
+
+	for len(pending) > 0 || running > 0 {
+		for firstErr == nil {
+			i := slices.IndexFunc(pending, func(name string) bool { return r.ready(name, succeeded) })
+			if i == -1 {
+				break
+			}
+			name := pending[i]
+			pending = slices.Delete(pending, i, i+1)
+			running++
+			go func() {
+				prefix := theme.SprintFunc(theme.DepName)(fmt.Sprintf("[%-*s]", width, name)) + " "
+				stdout := &prefixWriter{mu: &mu, dst: r.stdout, prefix: prefix}
+				stderr := &prefixWriter{mu: &mu, dst: r.stderr, prefix: prefix}
+				err := r.run(name, stdout, stderr)
+				stdout.flush()
+				stderr.flush()
+				results <- scriptResult{name: name, err: err}
+			}()
+		}
+		if running == 0 {
+			break // Only scripts waiting on a failed one are left
+		}
+		result := <-results
+		running--
+		if result.err != nil && firstErr == nil {
+			firstErr = result.err
+		}
+		succeeded[result.name] = result.err == nil
+	}
+	return firstErr
+}
+
+// ready reports whether every script name depends on has succeeded.
+func (r *runner) ready(name string, succeeded map[string]bool) bool {
+	for _, dep := range r.scripts[name].DependsOn {
+		if !succeeded[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+// run runs one script with its output going to stdout and stderr.
+func (r *runner) run(name string, stdout, stderr io.Writer) error {
+	execute := r.exec
+	if execute == nil {
+		execute = shellExec
+	}
+	if err := execute(r.scripts[name].Cmd, stdout, stderr); err != nil {
+		return fmt.Errorf("script '%s' failed: %w", name, err)
+	}
+	return nil
+}
+
+// shellExec runs cmd through the platform's shell in the current directory.
+func shellExec(cmd string, stdout, stderr io.Writer) error {
+	command := shell.Command(cmd)
+	command.Stdin = os.Stdin
+	command.Stdout, command.Stderr = stdout, stderr
+	return command.Run()
+}
+
+// prefixWriter writes complete lines to dst with prefix in front, holding mu for each line so
+// lines of scripts running at the same time do not interleave. A final line without a newline
+// is written by flush.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	dst    io.Writer
+	prefix string
+	buf    bytes.Buffer
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i == -1 {
+			return len(p), nil
+		}
+		w.writeLine(string(w.buf.Next(i + 1)))
+	}
+}
+
+func (w *prefixWriter) flush() {
+	if w.buf.Len() > 0 {
+		w.writeLine(w.buf.String() + "\n")
+		w.buf.Reset()
+	}
+}
+
+func (w *prefixWriter) writeLine(line string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, _ = io.WriteString(w.dst, w.prefix+line)
+}
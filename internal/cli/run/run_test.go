@@ -0,0 +1,132 @@
+package run
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/project"
+)
+
+var testScripts = map[string]project.Script{
+	"build": {Cmd: "build"},
+	"lint":  {Cmd: "lint"},
+	"test":  {Cmd: "test", DependsOn: []string{"build"}},
+	"ci":    {Cmd: "ci", DependsOn: []string{"lint", "test"}},
+}
+
+// fakeRunner returns a runner whose scripts print their command and record the order they ran
+// in; commands named in fail exit with an error.
+func fakeRunner(stdout *bytes.Buffer, ran *[]string, fail ...string) *runner {
+	var mu sync.Mutex
+	return &runner{
+		scripts: testScripts,
+		stdout:  stdout,
+		stderr:  io.Discard,
+		exec: func(cmd string, out, _ io.Writer) error {
+			mu.Lock()
+			*ran = append(*ran, cmd)
+			mu.Unlock()
+			_, _ = io.WriteString(out, cmd+" output\n")
+			for _, f := range fail {
+				if cmd == f {
+					return errors.New("exit status 1")
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func TestRunner_Sequential(t *testing.T) {
+	order, err := project.ScriptOrder(testScripts, []string{"ci", "build"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"lint", "build", "test", "ci"}, order, "dependencies first, each once")
+
+	var stdout bytes.Buffer
+	var ran []string
+	require.NoError(t, fakeRunner(&stdout, &ran).runSequential(order))
+	assert.Equal(t, order, ran)
+	assert.Equal(t, "lint output\nbuild output\ntest output\nci output\n", stdout.String())
+
+	ran = nil
+	err = fakeRunner(&stdout, &ran, "build").runSequential(order)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "script 'build' failed")
+	assert.Equal(t, []string{"lint", "build"}, ran, "nothing runs after a failure")
+}
+
+func TestRunner_Parallel(t *testing.T) {
+	order, err := project.ScriptOrder(testScripts, []string{"ci"})
+	require.NoError(t, err)
+
+	var stdout bytes.Buffer
+	var ran []string
+	require.NoError(t, fakeRunner(&stdout, &ran).runParallel(order))
+	require.Len(t, ran, 4)
+	assert.Less(t, indexOf(ran, "build"), indexOf(ran, "test"))
+	assert.Equal(t, "ci", ran[3], "ci waits for lint and test")
+	assert.Contains(t, stdout.String(), "[lint ] lint output\n")
+	assert.Contains(t, stdout.String(), "[build] build output\n")
+
+	ran = nil
+	err = fakeRunner(&stdout, &ran, "build").runParallel(order)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "script 'build' failed")
+	assert.NotContains(t, ran, "test", "scripts depending on a failed one do not start")
+	assert.NotContains(t, ran, "ci")
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := &prefixWriter{mu: &sync.Mutex{}, dst: &out, prefix: "[a] "}
+	_, _ = w.Write([]byte("one\ntw"))
+	_, _ = w.Write([]byte("o\nthree"))
+	w.flush()
+	assert.Equal(t, "[a] one\n[a] two\n[a] three\n", out.String())
+}
+
+func TestRunCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("scripts in this test use sh syntax")
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "project.toml"), []byte(`[package]
+name = "tasks"
+version = "0.1.0"
+
+[scripts]
+build = "echo built > build.txt"
+test = { cmd = "cat build.txt > test.txt", depends_on = ["build"] }
+`), 0644))
+	t.Chdir(dir)
+
+	app := &cli.App{Commands: []*cli.Command{RunCmd()}, ExitErrHandler: func(*cli.Context, error) {}}
+	require.NoError(t, app.Run([]string{"almd", "run", "--parallel", "test"}))
+	content, err := os.ReadFile(filepath.Join(dir, "test.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "built", strings.TrimSpace(string(content)))
+
+	err = app.Run([]string{"almd", "run", "missing"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no script named 'missing'")
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,200 @@
+package self
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/nightconcept/almandine/internal/core/theme"
+)
+
+// Outcomes of a doctor check. Only failures make 'self doctor' exit non-zero.
+const (
+	doctorOK      = "ok"
+	doctorWarning = "warning"
+	doctorFailed  = "failed"
+)
+
+// doctorCheck is the result of one 'self doctor' check.
+type doctorCheck struct {
+	Name   string
+	Status string
+	Detail string
+}
+
+func doctorCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "doctor",
+		Usage: "Check that this installation can be updated in place",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "source", Usage: "GitHub repository as 'owner/repo' (default: nightconcept/almandine)"},
+		},
+		Action: doctorAction,
+	}
+}
+
+// doctorAction runs the installation checks that explain most "update succeeded but the old
+// version still runs" reports.
+func doctorAction(c *cli.Context) error {
+	repoSlug, err := getRepoSlug(c.String("source"), false)
+	if err != nil {
+		return err
+	}
+
+	checks := []doctorCheck{checkUpdateChannel(c.App.Version, repoSlug)}
+	if exe, err := currentExecutable(); err != nil {
+		checks = append(checks, doctorCheck{Name: "binary", Status: doctorFailed, Detail: err.Error()})
+	} else {
+		checks = append(checks,
+			checkWritable(filepath.Dir(exe)),
+			checkPathShadowing(exe, os.Getenv("PATH"), runtime.GOOS))
+	}
+	checks = append(checks, checkReleaseAsset(repoSlug, runtime.GOOS, runtime.GOARCH))
+	if check, ok := checkDependencyReviews(".", time.Now()); ok {
+		checks = append(checks, check)
+	}
+
+	if failed := printDoctorChecks(os.Stdout, checks); failed > 0 {
+		return cli.Exit(fmt.Sprintf("Error: %d installation check(s) failed", failed), 1)
+	}
+	return nil
+}
+
+// currentExecutable returns the path of the running binary with symlinks resolved, which is the
+// file 'self update' replaces.
+func currentExecutable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("could not determine the executable path: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	return exe, nil
+}
+
+// checkUpdateChannel reports which releases 'self update' follows for this build.
+func checkUpdateChannel(version, repoSlug string) doctorCheck {
+	check := doctorCheck{Name: "channel", Status: doctorOK}
+	v, err := semver.NewVersion(version)
+	switch {
+	case err != nil:
+		check.Status = doctorWarning
+		check.Detail = fmt.Sprintf("development build '%s'; 'self update' would replace it with the latest stable release of %s", version, repoSlug)
+	case v.Prerelease() != "":
+		check.Detail = fmt.Sprintf("prerelease v%s; 'self update' follows stable releases of %s", v, repoSlug)
+	default:
+		check.Detail = fmt.Sprintf("stable releases of %s (current: v%s)", repoSlug, v)
+	}
+	return check
+}
+
+// checkWritable verifies that dir accepts new files, which 'self update' needs to replace the binary.
+func checkWritable(dir string) doctorCheck {
+	check := doctorCheck{Name: "writable", Status: doctorOK, Detail: dir}
+	probe, err := os.CreateTemp(dir, ".almd-doctor-*")
+	if err != nil {
+		check.Status = doctorFailed
+		check.Detail = fmt.Sprintf("cannot write to %s (%v); rerun 'self update' with sufficient permissions or reinstall almd to a user-writable directory", dir, err)
+		return check
+	}
+	_ = probe.Close()
+	_ = os.Remove(probe.Name())
+	return check
+}
+
+// checkPathShadowing verifies that exe is the first almd found on pathEnv, so an updated binary
+// is the one that runs next.
+func checkPathShadowing(exe, pathEnv, goos string) doctorCheck {
+	name := "almd"
+	if goos == "windows" {
+		name += ".exe"
+	}
+	for _, dir := range filepath.SplitList(pathEnv) {
+		if dir == "" {
+			continue
+		}
+		candidate := filepath.Join(dir, name)
+		info, err := os.Stat(candidate)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if sameFile(candidate, exe) {
+			return doctorCheck{Name: "path", Status: doctorOK, Detail: "first almd on PATH is " + candidate}
+		}
+		return doctorCheck{Name: "path", Status: doctorFailed,
+			Detail: fmt.Sprintf("%s comes before %s on PATH, so it keeps running after an update; remove it or reorder PATH", candidate, exe)}
+	}
+	return doctorCheck{Name: "path", Status: doctorWarning, Detail: fmt.Sprintf("%s is not on PATH", filepath.Dir(exe))}
+}
+
+// sameFile reports whether a and b name the same file once symlinks are resolved.
+func sameFile(a, b string) bool {
+	ai, errA := os.Stat(a)
+	bi, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(ai, bi)
+}
+
+// checkReleaseAsset verifies that the latest release publishes an archive for this platform.
+func checkReleaseAsset(repoSlug, goos, goarch string) doctorCheck {
+	check := doctorCheck{Name: "release", Status: doctorOK}
+	owner, repo, _ := strings.Cut(repoSlug, "/")
+	release, err := source.GetRelease(owner, repo, "")
+	if err != nil {
+		check.Status = doctorWarning
+		check.Detail = fmt.Sprintf("could not fetch the latest release: %v", err)
+		return check
+	}
+	name := releaseArchiveName(strings.TrimPrefix(release.TagName, "v"), goos, goarch)
+	for _, asset := range release.Assets {
+		if asset.Name == name {
+			check.Detail = fmt.Sprintf("%s publishes %s", release.TagName, name)
+			return check
+		}
+	}
+	check.Status = doctorFailed
+	check.Detail = fmt.Sprintf("%s has no archive for %s/%s (expected %s)", release.TagName, goos, goarch, name)
+	return check
+}
+
+// checkDependencyReviews reports the dependencies of the project in dir whose review_after date
+// has passed. ok is false outside a project, where there is nothing to check.
+func checkDependencyReviews(dir string, now time.Time) (check doctorCheck, ok bool) {
+	proj, err := config.LoadProjectToml(dir)
+	if err != nil {
+		return doctorCheck{}, false
+	}
+	check = doctorCheck{Name: "reviews", Status: doctorOK, Detail: "no dependency is past its review_after date"}
+	if overdue := project.OverdueReviews(proj.Dependencies, now); len(overdue) > 0 {
+		check.Status = doctorWarning
+		check.Detail = fmt.Sprintf("past their review_after date: %s; review them and move the date forward", strings.Join(overdue, ", "))
+	}
+	return check, true
+}
+
+// printDoctorChecks prints one line per check and returns the number of failed checks.
+func printDoctorChecks(w io.Writer, checks []doctorCheck) int {
+	colors := map[string]func(a ...interface{}) string{
+		doctorOK:      theme.SprintFunc(theme.OK),
+		doctorWarning: theme.SprintFunc(theme.Warning),
+		doctorFailed:  theme.SprintFunc(theme.Problem),
+	}
+	failed := 0
+	for _, check := range checks {
+		if check.Status == doctorFailed {
+			failed++
+		}
+		_, _ = fmt.Fprintf(w, "%s %-9s %s\n", colors[check.Status](fmt.Sprintf("%-8s", check.Status)), check.Name, check.Detail)
+	}
+	return failed
+}
@@ -0,0 +1,116 @@
+package self
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+func writeFakeBinary(t *testing.T, dir string) string {
+	t.Helper()
+	path := filepath.Join(dir, "almd")
+	require.NoError(t, os.WriteFile(path, []byte("binary"), 0755))
+	return path
+}
+
+func TestCheckPathShadowing(t *testing.T) {
+	installed, shadowing, elsewhere := t.TempDir(), t.TempDir(), t.TempDir()
+	exe := writeFakeBinary(t, installed)
+	writeFakeBinary(t, shadowing)
+	sep := string(os.PathListSeparator)
+
+	check := checkPathShadowing(exe, elsewhere+sep+installed+sep+shadowing, "linux")
+	assert.Equal(t, doctorOK, check.Status)
+
+	check = checkPathShadowing(exe, shadowing+sep+installed, "linux")
+	assert.Equal(t, doctorFailed, check.Status)
+	assert.Contains(t, check.Detail, filepath.Join(shadowing, "almd")+" comes before")
+
+	check = checkPathShadowing(exe, elsewhere, "linux")
+	assert.Equal(t, doctorWarning, check.Status)
+	assert.Contains(t, check.Detail, "is not on PATH")
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, doctorOK, checkWritable(dir).Status)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the probe file is removed")
+
+	if runtime.GOOS != "windows" && os.Geteuid() != 0 {
+		require.NoError(t, os.Chmod(dir, 0555))
+		t.Cleanup(func() { _ = os.Chmod(dir, 0755) })
+		assert.Equal(t, doctorFailed, checkWritable(dir).Status)
+	}
+}
+
+func TestCheckDependencyReviews(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2025, 12, 2, 0, 0, 0, 0, time.UTC)
+	_, ok := checkDependencyReviews(dir, now)
+	assert.False(t, ok, "there is nothing to check outside a project")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "project.toml"), []byte(`
+[package]
+name = "reviewed"
+version = "0.1.0"
+
+[dependencies]
+json = { source = "https://example.com/json.lua", path = "libs/json.lua", review_after = "2025-12-01" }
+utf8 = { source = "https://example.com/utf8.lua", path = "libs/utf8.lua", review_after = "2026-06-01" }
+`), 0644))
+	check, ok := checkDependencyReviews(dir, now)
+	require.True(t, ok)
+	assert.Equal(t, doctorWarning, check.Status)
+	assert.Contains(t, check.Detail, "past their review_after date: json;")
+
+	check, _ = checkDependencyReviews(dir, now.AddDate(-1, 0, 0))
+	assert.Equal(t, doctorOK, check.Status)
+}
+
+func TestCheckUpdateChannel(t *testing.T) {
+	assert.Equal(t, doctorWarning, checkUpdateChannel("dev", "nightconcept/almandine").Status)
+	assert.Equal(t, "stable releases of nightconcept/almandine (current: v1.2.0)", checkUpdateChannel("v1.2.0", "nightconcept/almandine").Detail)
+	assert.Contains(t, checkUpdateChannel("1.3.0-rc.1", "nightconcept/almandine").Detail, "prerelease v1.3.0-rc.1")
+}
+
+func TestCheckReleaseAsset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/nightconcept/almandine/releases/latest" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"tag_name":"v1.2.0","assets":[{"name":"almd_1.2.0_linux_amd64.tar.gz"},{"name":"almd_1.2.0_windows_amd64.zip"}]}`))
+	}))
+	defer server.Close()
+	original := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = server.URL
+	defer func() { source.GithubAPIBaseURL = original }()
+
+	assert.Equal(t, doctorOK, checkReleaseAsset("nightconcept/almandine", "windows", "amd64").Status)
+	check := checkReleaseAsset("nightconcept/almandine", "linux", "arm64")
+	assert.Equal(t, doctorFailed, check.Status)
+	assert.Contains(t, check.Detail, "expected almd_1.2.0_linux_arm64.tar.gz")
+	assert.Equal(t, doctorWarning, checkReleaseAsset("other/missing", "linux", "amd64").Status)
+}
+
+func TestPrintDoctorChecks(t *testing.T) {
+	var buf bytes.Buffer
+	failed := printDoctorChecks(&buf, []doctorCheck{
+		{Name: "channel", Status: doctorOK, Detail: "stable"},
+		{Name: "path", Status: doctorFailed, Detail: "shadowed"},
+	})
+	assert.Equal(t, 1, failed)
+	assert.Equal(t, "ok       channel   stable\nfailed   path      shadowed\n", buf.String())
+}
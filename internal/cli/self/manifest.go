@@ -0,0 +1,318 @@
+package self
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/downloader"
+	"github.com/nightconcept/almandine/internal/core/hasher"
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+// Package metadata shared by every installer manifest.
+const (
+	manifestDescription = "Lua package manager for single-file dependencies"
+	manifestLicense     = "MIT"
+)
+
+// releasePlatforms lists the os/arch pairs published by the release workflow, in manifest order.
+var releasePlatforms = []struct{ OS, Arch string }{
+	{"darwin", "arm64"}, {"darwin", "amd64"},
+	{"linux", "arm64"}, {"linux", "amd64"},
+	{"windows", "arm64"}, {"windows", "amd64"},
+}
+
+// releaseAsset is a downloadable release archive with its checksum.
+type releaseAsset struct {
+	URL    string
+	SHA256 string // Hex digest without the "sha256:" prefix
+}
+
+// manifestData is the input to every manifest renderer.
+type manifestData struct {
+	Version  string // Without the leading "v"
+	Homepage string
+	Assets   map[string]releaseAsset // Keyed by "<os>_<arch>"
+}
+
+// Asset returns the archive for a platform, or nil when the release does not include it.
+func (d manifestData) Asset(goos, goarch string) *releaseAsset {
+	if a, ok := d.Assets[goos+"_"+goarch]; ok {
+		return &a
+	}
+	return nil
+}
+
+// manifestRenderers maps --format values to their renderers.
+var manifestRenderers = map[string]func(manifestData) (string, error){
+	"scoop": renderScoopManifest,
+	"brew":  renderBrewFormula,
+	"aur":   renderAURPKGBUILD,
+}
+
+func manifestCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "manifest",
+		Usage: "Render package manager manifests (scoop, brew, aur) for a release",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "format", Aliases: []string{"f"}, Usage: "Manifest format: scoop, brew or aur", Required: true},
+			&cli.StringFlag{Name: "tag", Usage: "Release tag to describe (default: this binary's version, or the latest release for dev builds)"},
+			&cli.StringFlag{Name: "source", Usage: "GitHub repository as 'owner/repo' (default: nightconcept/almandine)"},
+			&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "Write the manifest to a file instead of stdout"},
+		},
+		Action: manifestAction,
+	}
+}
+
+// manifestAction fetches a release from GitHub and renders an installer manifest for it.
+func manifestAction(c *cli.Context) error {
+	render, ok := manifestRenderers[c.String("format")]
+	if !ok {
+		return cli.Exit(fmt.Sprintf("Error: unsupported manifest format '%s' (use scoop, brew or aur)", c.String("format")), 1)
+	}
+	repoSlug, err := getRepoSlug(c.String("source"), false)
+	if err != nil {
+		return err
+	}
+
+	tag := c.String("tag")
+	if tag == "" && c.App.Version != "" && c.App.Version != "dev" {
+		tag = "v" + strings.TrimPrefix(c.App.Version, "v")
+	}
+
+	owner, repo, _ := strings.Cut(repoSlug, "/")
+	release, err := source.GetRelease(owner, repo, tag)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error fetching release: %v", err), 1)
+	}
+	data, err := buildManifestData(release, repoSlug)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+
+	out, err := render(data)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error rendering manifest: %v", err), 1)
+	}
+	if path := c.String("output"); path != "" {
+		if err := os.WriteFile(path, []byte(out), 0644); err != nil {
+			return cli.Exit(fmt.Sprintf("Error writing '%s': %v", path, err), 1)
+		}
+		return nil
+	}
+	_, _ = fmt.Fprint(os.Stdout, out)
+	return nil
+}
+
+// buildManifestData matches the release's archives to platforms and resolves their checksums.
+func buildManifestData(release *source.GitHubRelease, repoSlug string) (manifestData, error) {
+	version := strings.TrimPrefix(release.TagName, "v")
+	data := manifestData{
+		Version:  version,
+		Homepage: "https://github.com/" + repoSlug,
+		Assets:   make(map[string]releaseAsset),
+	}
+
+	byName := make(map[string]source.GitHubReleaseAsset, len(release.Assets))
+	for _, a := range release.Assets {
+		byName[a.Name] = a
+	}
+	for _, p := range releasePlatforms {
+		asset, ok := byName[releaseArchiveName(version, p.OS, p.Arch)]
+		if !ok {
+			continue
+		}
+		sum, err := assetSHA256(asset)
+		if err != nil {
+			return data, err
+		}
+		data.Assets[p.OS+"_"+p.Arch] = releaseAsset{URL: asset.BrowserDownloadURL, SHA256: sum}
+	}
+	if len(data.Assets) == 0 {
+		return data, fmt.Errorf("release %s has no almd archives", release.TagName)
+	}
+	return data, nil
+}
+
+// releaseArchiveName returns the name of the release archive for a platform, as published by
+// the release workflow. version has no leading "v".
+func releaseArchiveName(version, goos, goarch string) string {
+	ext := ".tar.gz"
+	if goos == "windows" {
+		ext = ".zip"
+	}
+	return fmt.Sprintf("almd_%s_%s_%s%s", version, goos, goarch, ext)
+}
+
+// assetSHA256 returns the hex SHA-256 of an asset, using the digest GitHub reports when present
+// and otherwise downloading the asset and hashing it.
+func assetSHA256(asset source.GitHubReleaseAsset) (string, error) {
+	if digest, ok := strings.CutPrefix(asset.Digest, "sha256:"); ok && digest != "" {
+		return digest, nil
+	}
+	content, err := downloader.DownloadFile(asset.BrowserDownloadURL)
+	if err != nil {
+		return "", fmt.Errorf("downloading %s to compute its checksum: %w", asset.Name, err)
+	}
+	sum, err := hasher.CalculateSHA256(content)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(sum, "sha256:"), nil
+}
+
+// scoopArch is one entry of a Scoop manifest's "architecture" object.
+type scoopArch struct {
+	URL  string `json:"url"`
+	Hash string `json:"hash"`
+}
+
+// renderScoopManifest renders a Scoop app manifest for the Windows archives.
+func renderScoopManifest(d manifestData) (string, error) {
+	arch := make(map[string]scoopArch)
+	if a := d.Asset("windows", "amd64"); a != nil {
+		arch["64bit"] = scoopArch{URL: a.URL, Hash: a.SHA256}
+	}
+	if a := d.Asset("windows", "arm64"); a != nil {
+		arch["arm64"] = scoopArch{URL: a.URL, Hash: a.SHA256}
+	}
+	if len(arch) == 0 {
+		return "", fmt.Errorf("release has no Windows archives")
+	}
+
+	manifest := struct {
+		Version      string               `json:"version"`
+		Description  string               `json:"description"`
+		Homepage     string               `json:"homepage"`
+		License      string               `json:"license"`
+		Architecture map[string]scoopArch `json:"architecture"`
+		Bin          string               `json:"bin"`
+		Checkver     map[string]string    `json:"checkver"`
+	}{
+		Version:      d.Version,
+		Description:  manifestDescription,
+		Homepage:     d.Homepage,
+		License:      manifestLicense,
+		Architecture: arch,
+		Bin:          "almd.exe",
+		Checkver:     map[string]string{"github": d.Homepage},
+	}
+	out, err := json.MarshalIndent(manifest, "", "    ")
+	if err != nil {
+		return "", err
+	}
+	return string(out) + "\n", nil
+}
+
+var brewTemplate = template.Must(template.New("brew").Parse(`class Almd < Formula
+  desc "{{.Description}}"
+  homepage "{{.Data.Homepage}}"
+  version "{{.Data.Version}}"
+  license "{{.License}}"
+{{range .Sections}}
+  on_{{.Name}} do
+{{- range .Arches}}
+    on_{{.Name}} do
+      url "{{.Asset.URL}}"
+      sha256 "{{.Asset.SHA256}}"
+    end
+{{- end}}
+  end
+{{end}}
+  def install
+    bin.install "almd"
+  end
+
+  test do
+    system "#{bin}/almd", "--version"
+  end
+end
+`))
+
+// renderBrewFormula renders a Homebrew formula covering the macOS and Linux archives.
+func renderBrewFormula(d manifestData) (string, error) {
+	type arch struct {
+		Name  string
+		Asset *releaseAsset
+	}
+	type section struct {
+		Name   string
+		Arches []arch
+	}
+	var sections []section
+	for _, osName := range []struct{ brew, goos string }{{"macos", "darwin"}, {"linux", "linux"}} {
+		s := section{Name: osName.brew}
+		for _, a := range []struct{ brew, goarch string }{{"arm", "arm64"}, {"intel", "amd64"}} {
+			if asset := d.Asset(osName.goos, a.goarch); asset != nil {
+				s.Arches = append(s.Arches, arch{Name: a.brew, Asset: asset})
+			}
+		}
+		if len(s.Arches) > 0 {
+			sections = append(sections, s)
+		}
+	}
+	if len(sections) == 0 {
+		return "", fmt.Errorf("release has no macOS or Linux archives")
+	}
+
+	var buf bytes.Buffer
+	err := brewTemplate.Execute(&buf, map[string]any{
+		"Description": manifestDescription,
+		"License":     manifestLicense,
+		"Data":        d,
+		"Sections":    sections,
+	})
+	return buf.String(), err
+}
+
+var aurTemplate = template.Must(template.New("aur").Parse(`pkgname=almd-bin
+pkgver={{.PkgVer}}
+pkgrel=1
+pkgdesc="{{.Description}}"
+arch=({{range $i, $a := .Arches}}{{if $i}} {{end}}'{{$a.Name}}'{{end}})
+url="{{.Data.Homepage}}"
+license=('{{.License}}')
+provides=('almd')
+conflicts=('almd')
+{{range .Arches}}
+source_{{.Name}}=("almd-{{$.Data.Version}}-{{.Name}}.tar.gz::{{.Asset.URL}}")
+sha256sums_{{.Name}}=('{{.Asset.SHA256}}')
+{{- end}}
+
+package() {
+  install -Dm755 almd "$pkgdir/usr/bin/almd"
+}
+`))
+
+// renderAURPKGBUILD renders a PKGBUILD for an almd-bin AUR package from the Linux archives.
+func renderAURPKGBUILD(d manifestData) (string, error) {
+	type arch struct {
+		Name  string
+		Asset *releaseAsset
+	}
+	var arches []arch
+	for _, a := range []struct{ aur, goarch string }{{"x86_64", "amd64"}, {"aarch64", "arm64"}} {
+		if asset := d.Asset("linux", a.goarch); asset != nil {
+			arches = append(arches, arch{Name: a.aur, Asset: asset})
+		}
+	}
+	if len(arches) == 0 {
+		return "", fmt.Errorf("release has no Linux archives")
+	}
+
+	var buf bytes.Buffer
+	err := aurTemplate.Execute(&buf, map[string]any{
+		"PkgVer":      strings.ReplaceAll(d.Version, "-", "_"), // pkgver may not contain hyphens
+		"Description": manifestDescription,
+		"License":     manifestLicense,
+		"Data":        d,
+		"Arches":      arches,
+	})
+	return buf.String(), err
+}
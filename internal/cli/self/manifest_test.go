@@ -0,0 +1,124 @@
+package self
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+// startReleaseServer serves a fake release whose Linux arm64 archive has no recorded digest,
+// so its checksum must be computed by downloading it.
+func startReleaseServer(t *testing.T) string {
+	t.Helper()
+	var serverURL string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/nightconcept/almandine/releases/tags/v1.2.0":
+			asset := func(name, digest string) string {
+				return fmt.Sprintf(`{"name":%q,"browser_download_url":"%s/dl/%s","digest":%q}`, name, serverURL, name, digest)
+			}
+			_, _ = fmt.Fprintf(w, `{"tag_name":"v1.2.0","assets":[%s,%s,%s,%s,%s]}`,
+				asset("almd_1.2.0_darwin_arm64.tar.gz", "sha256:aaaa"),
+				asset("almd_1.2.0_darwin_amd64.tar.gz", "sha256:bbbb"),
+				asset("almd_1.2.0_linux_amd64.tar.gz", "sha256:cccc"),
+				asset("almd_1.2.0_linux_arm64.tar.gz", ""),
+				asset("almd_1.2.0_windows_amd64.zip", "sha256:dddd"))
+		case "/dl/almd_1.2.0_linux_arm64.tar.gz":
+			_, _ = w.Write([]byte("archive"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	serverURL = server.URL
+
+	source.GithubAPIBaseURLMutex.Lock()
+	originalBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = server.URL
+	source.GithubAPIBaseURLMutex.Unlock()
+	t.Cleanup(func() {
+		source.GithubAPIBaseURLMutex.Lock()
+		source.GithubAPIBaseURL = originalBaseURL
+		source.GithubAPIBaseURLMutex.Unlock()
+	})
+	return server.URL
+}
+
+// runManifestCommand runs 'self manifest' and returns captured stdout.
+func runManifestCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	originalStdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = originalStdout }()
+
+	app := &cli.App{
+		Version:        "dev",
+		Commands:       []*cli.Command{SelfCmd()},
+		ExitErrHandler: func(_ *cli.Context, _ error) {},
+	}
+	runErr := app.Run(append([]string{"almd", "self", "manifest"}, args...))
+	_ = w.Close()
+	var out bytes.Buffer
+	_, _ = out.ReadFrom(r)
+	_ = r.Close()
+	return out.String(), runErr
+}
+
+// linuxArm64Hash is the SHA-256 of the served "archive" content.
+const linuxArm64Hash = "0eb3e36bfb24dcd9bb1d1bece1531216b59539a8fde17ee80224af0653c92aa3"
+
+func TestManifestCommand_Scoop(t *testing.T) {
+	serverURL := startReleaseServer(t)
+	out, err := runManifestCommand(t, "--format", "scoop", "--tag", "v1.2.0")
+	require.NoError(t, err)
+	assert.Contains(t, out, `"version": "1.2.0"`)
+	assert.Contains(t, out, `"url": "`+serverURL+`/dl/almd_1.2.0_windows_amd64.zip"`)
+	assert.Contains(t, out, `"hash": "dddd"`)
+	assert.Contains(t, out, `"bin": "almd.exe"`)
+	assert.NotContains(t, out, "arm64")
+}
+
+func TestManifestCommand_Brew(t *testing.T) {
+	startReleaseServer(t)
+	out, err := runManifestCommand(t, "--format", "brew", "--tag", "v1.2.0")
+	require.NoError(t, err)
+	assert.Contains(t, out, "class Almd < Formula")
+	assert.Contains(t, out, `version "1.2.0"`)
+	assert.Contains(t, out, "on_macos do")
+	assert.Contains(t, out, `sha256 "aaaa"`)
+	assert.Contains(t, out, `sha256 "cccc"`)
+	assert.Contains(t, out, `bin.install "almd"`)
+}
+
+func TestManifestCommand_AURComputesMissingDigest(t *testing.T) {
+	startReleaseServer(t)
+	out, err := runManifestCommand(t, "--format", "aur", "--tag", "v1.2.0")
+	require.NoError(t, err)
+	assert.Contains(t, out, "pkgver=1.2.0")
+	assert.Contains(t, out, "arch=('x86_64' 'aarch64')")
+	assert.Contains(t, out, "sha256sums_x86_64=('cccc')")
+	assert.Contains(t, out, "sha256sums_aarch64=('"+linuxArm64Hash+"')")
+}
+
+func TestManifestCommand_Errors(t *testing.T) {
+	startReleaseServer(t)
+
+	_, err := runManifestCommand(t, "--format", "nix", "--tag", "v1.2.0")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported manifest format")
+
+	_, err = runManifestCommand(t, "--format", "brew", "--tag", "v9.9.9")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Error fetching release")
+}
@@ -0,0 +1,180 @@
+package self
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/creativeprojects/go-selfupdate"
+
+	"github.com/nightconcept/almandine/internal/core/downloader"
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+// Limits for the changelog shown before updating.
+const (
+	maxChangelogReleases = 10 // Older releases are summarized in one line
+	maxChangelogLines    = 5  // Notes per release; the rest is left to the release page
+)
+
+// releaseSource is a selfupdate.Source that talks to GitHub through the source and downloader
+// packages, so self-update honors the same proxy, CA certificate, timeout and token settings as
+// every other request almd makes.
+type releaseSource struct {
+	mu       sync.Mutex
+	releases []source.GitHubRelease // From the last ListReleases call
+}
+
+// ListReleases implements selfupdate.Source.
+func (s *releaseSource) ListReleases(_ context.Context, repository selfupdate.Repository) ([]selfupdate.SourceRelease, error) {
+	owner, repo, err := repository.GetSlug()
+	if err != nil {
+		return nil, err
+	}
+	releases, err := source.ListReleases(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.releases = releases
+	s.mu.Unlock()
+
+	out := make([]selfupdate.SourceRelease, len(releases))
+	for i := range releases {
+		out[i] = sourceRelease{releases[i]}
+	}
+	return out, nil
+}
+
+// DownloadReleaseAsset implements selfupdate.Source for assets of previously listed releases.
+func (s *releaseSource) DownloadReleaseAsset(_ context.Context, _ *selfupdate.Release, assetID int64) (io.ReadCloser, error) {
+	for _, release := range s.Releases() {
+		for _, asset := range release.Assets {
+			if asset.ID == assetID {
+				return downloader.Open(asset.BrowserDownloadURL)
+			}
+		}
+	}
+	return nil, fmt.Errorf("release asset %d not found", assetID)
+}
+
+// Releases returns the releases seen by the last ListReleases call.
+func (s *releaseSource) Releases() []source.GitHubRelease {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.releases
+}
+
+// sourceRelease adapts a GitHub release to selfupdate.SourceRelease.
+type sourceRelease struct{ r source.GitHubRelease }
+
+func (r sourceRelease) GetID() int64              { return r.r.ID }
+func (r sourceRelease) GetTagName() string        { return r.r.TagName }
+func (r sourceRelease) GetDraft() bool            { return r.r.Draft }
+func (r sourceRelease) GetPrerelease() bool       { return r.r.Prerelease }
+func (r sourceRelease) GetPublishedAt() time.Time { return r.r.PublishedAt }
+func (r sourceRelease) GetReleaseNotes() string   { return r.r.Body }
+func (r sourceRelease) GetName() string           { return r.r.Name }
+func (r sourceRelease) GetURL() string            { return r.r.HTMLURL }
+
+func (r sourceRelease) GetAssets() []selfupdate.SourceAsset {
+	assets := make([]selfupdate.SourceAsset, len(r.r.Assets))
+	for i := range r.r.Assets {
+		assets[i] = sourceAsset{r.r.Assets[i]}
+	}
+	return assets
+}
+
+// sourceAsset adapts a GitHub release asset to selfupdate.SourceAsset.
+type sourceAsset struct{ a source.GitHubReleaseAsset }
+
+func (a sourceAsset) GetID() int64                  { return a.a.ID }
+func (a sourceAsset) GetName() string               { return a.a.Name }
+func (a sourceAsset) GetSize() int                  { return a.a.Size }
+func (a sourceAsset) GetBrowserDownloadURL() string { return a.a.BrowserDownloadURL }
+
+// releasesBetween returns the published releases newer than current and no newer than target,
+// newest first. Prereleases are only included when updating to a prerelease.
+func releasesBetween(releases []source.GitHubRelease, current *semver.Version, target string) []source.GitHubRelease {
+	targetVersion, err := semver.NewVersion(target)
+	if err != nil {
+		return nil
+	}
+	type versioned struct {
+		release source.GitHubRelease
+		version *semver.Version
+	}
+	var between []versioned
+	for _, r := range releases {
+		if r.Draft || (r.Prerelease && targetVersion.Prerelease() == "") {
+			continue
+		}
+		v, err := semver.NewVersion(r.TagName)
+		if err != nil || !v.GreaterThan(current) || v.GreaterThan(targetVersion) {
+			continue
+		}
+		between = append(between, versioned{r, v})
+	}
+	sort.Slice(between, func(i, j int) bool { return between[i].version.GreaterThan(between[j].version) })
+
+	out := make([]source.GitHubRelease, len(between))
+	for i, b := range between {
+		out[i] = b.release
+	}
+	return out
+}
+
+// printChangelog prints a condensed changelog of releases, newest first.
+func printChangelog(w io.Writer, current *semver.Version, releases []source.GitHubRelease) {
+	if len(releases) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "\nChanges since %s:\n", current.Original())
+	for i, r := range releases {
+		if i == maxChangelogReleases {
+			_, _ = fmt.Fprintf(w, "\n... and %d older release(s)\n", len(releases)-i)
+			break
+		}
+		heading := r.TagName
+		if !r.PublishedAt.IsZero() {
+			heading += " (" + r.PublishedAt.Format("2006-01-02") + ")"
+		}
+		_, _ = fmt.Fprintf(w, "\n%s\n", heading)
+		lines := condenseNotes(r.Body)
+		for j, line := range lines {
+			if j == maxChangelogLines {
+				more := fmt.Sprintf("  ... %d more", len(lines)-j)
+				if r.HTMLURL != "" {
+					more += ", see " + r.HTMLURL
+				}
+				_, _ = fmt.Fprintln(w, more)
+				break
+			}
+			_, _ = fmt.Fprintf(w, "  %s\n", line)
+		}
+	}
+	_, _ = fmt.Fprintln(w)
+}
+
+// condenseNotes reduces Markdown release notes to their content lines, dropping blank lines,
+// headings, HTML comments and the "Full Changelog" link GitHub appends.
+func condenseNotes(body string) []string {
+	var lines []string
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "", strings.HasPrefix(line, "#"), strings.HasPrefix(line, "<!--"),
+			strings.HasPrefix(line, "**Full Changelog**"):
+			continue
+		case strings.HasPrefix(line, "* "):
+			line = "- " + line[2:]
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,109 @@
+package self
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/creativeprojects/go-selfupdate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+func TestReleaseSource_ListAndDownload(t *testing.T) {
+	var serverURL string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/nightconcept/almandine/releases":
+			_, _ = fmt.Fprintf(w, `[{"id":1,"tag_name":"v1.2.0","body":"notes","html_url":"page","assets":[{"id":10,"name":"almd.tar.gz","size":7,"browser_download_url":"%s/dl/almd.tar.gz"}]}]`, serverURL)
+		case "/dl/almd.tar.gz":
+			_, _ = w.Write([]byte("archive"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	serverURL = server.URL
+	original := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = server.URL
+	defer func() { source.GithubAPIBaseURL = original }()
+
+	releases := &releaseSource{}
+	listed, err := releases.ListReleases(context.Background(), selfupdate.NewRepositorySlug("nightconcept", "almandine"))
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, "v1.2.0", listed[0].GetTagName())
+	assert.Equal(t, "notes", listed[0].GetReleaseNotes())
+	require.Len(t, listed[0].GetAssets(), 1)
+	assert.Equal(t, int64(10), listed[0].GetAssets()[0].GetID())
+
+	body, err := releases.DownloadReleaseAsset(context.Background(), nil, 10)
+	require.NoError(t, err)
+	content, err := io.ReadAll(body)
+	_ = body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "archive", string(content))
+
+	_, err = releases.DownloadReleaseAsset(context.Background(), nil, 99)
+	assert.ErrorContains(t, err, "release asset 99 not found")
+}
+
+func TestReleasesBetween(t *testing.T) {
+	releases := []source.GitHubRelease{
+		{TagName: "v1.3.0"},
+		{TagName: "v1.2.0"},
+		{TagName: "v1.2.1-rc.1", Prerelease: true},
+		{TagName: "v1.1.0"},
+		{TagName: "v1.1.5", Draft: true},
+		{TagName: "v1.0.0"},
+		{TagName: "nightly"},
+	}
+	current := semver.MustParse("v1.0.0")
+
+	tags := func(rs []source.GitHubRelease) []string {
+		var out []string
+		for _, r := range rs {
+			out = append(out, r.TagName)
+		}
+		return out
+	}
+	assert.Equal(t, []string{"v1.2.0", "v1.1.0"}, tags(releasesBetween(releases, current, "1.2.0")))
+	assert.Equal(t, []string{"v1.2.1-rc.1", "v1.2.0", "v1.1.0"}, tags(releasesBetween(releases, current, "1.2.1-rc.1")))
+	assert.Empty(t, releasesBetween(releases, current, "not-a-version"))
+}
+
+func TestPrintChangelog(t *testing.T) {
+	var buf bytes.Buffer
+	printChangelog(&buf, semver.MustParse("v1.0.0"), []source.GitHubRelease{
+		{
+			TagName:     "v1.1.0",
+			HTMLURL:     "https://example.com/v1.1.0",
+			PublishedAt: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+			Body:        "## What's Changed\n\n* One\n* Two\n* Three\n* Four\n* Five\n* Six\n<!-- hidden -->\n\n**Full Changelog**: https://example.com/compare",
+		},
+	})
+	assert.Equal(t, `
+Changes since v1.0.0:
+
+v1.1.0 (2026-03-01)
+  - One
+  - Two
+  - Three
+  - Four
+  - Five
+  ... 1 more, see https://example.com/v1.1.0
+
+`, buf.String())
+
+	buf.Reset()
+	printChangelog(&buf, semver.MustParse("v1.0.0"), nil)
+	assert.Empty(t, buf.String())
+}
@@ -10,10 +10,12 @@ import (
 	"github.com/Masterminds/semver/v3"
 	"github.com/creativeprojects/go-selfupdate"
 	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/logger"
 )
 
-// SelfCmd creates a command for managing the almd CLI application's lifecycle,
-// currently supporting self-update functionality.
+// SelfCmd creates a command for managing the almd CLI application's lifecycle:
+// self-update, checking the installation, and rendering installer manifests for releases.
 func SelfCmd() *cli.Command {
 	return &cli.Command{
 		Name:  "self",
@@ -43,6 +45,8 @@ func SelfCmd() *cli.Command {
 				},
 				Action: updateAction,
 			},
+			doctorCommand(),
+			manifestCommand(),
 		},
 	}
 }
@@ -52,7 +56,7 @@ func SelfCmd() *cli.Command {
 // The function handles version comparison, user confirmation (unless --yes is specified),
 // and supports custom GitHub repositories via the --source flag.
 func updateAction(c *cli.Context) error {
-	verbose := c.Bool("verbose")
+	verbose := c.Bool("verbose") || logger.Verbose()
 	currentVersionStr := c.App.Version // Retain for initial parsing
 
 	currentSemVer, err := parseVersion(currentVersionStr, verbose)
@@ -65,7 +69,8 @@ func updateAction(c *cli.Context) error {
 		return err // error is already a cli.Exit error
 	}
 
-	updater, err := newUpdater(verbose)
+	releases := &releaseSource{}
+	updater, err := newUpdater(releases, verbose)
 	if err != nil {
 		return err // error is already a cli.Exit error
 	}
@@ -80,6 +85,7 @@ func updateAction(c *cli.Context) error {
 	}
 
 	// latestRelease is guaranteed non-nil if proceed is true
+	printChangelog(os.Stdout, currentSemVer, releasesBetween(releases.Releases(), currentSemVer, latestRelease.Version()))
 	return executeUpdate(c, latestRelease, updater, verbose)
 }
 
@@ -211,15 +217,11 @@ func getRepoSlug(sourceFlag string, verbose bool) (string, error) {
 	return repoSlug, nil
 }
 
-// newUpdater creates and returns a new selfupdate.Updater instance.
-func newUpdater(verbose bool) (*selfupdate.Updater, error) {
-	ghSource, err := selfupdate.NewGitHubSource(selfupdate.GitHubConfig{})
-	if err != nil {
-		return nil, cli.Exit(fmt.Sprintf("Error creating GitHub source: %v", err), 1)
-	}
-
+// newUpdater creates and returns a new selfupdate.Updater instance that fetches releases through
+// releases, and therefore through almd's shared HTTP configuration.
+func newUpdater(releases *releaseSource, verbose bool) (*selfupdate.Updater, error) {
 	updater, err := selfupdate.NewUpdater(selfupdate.Config{
-		Source: ghSource,
+		Source: releases,
 	})
 	if err != nil {
 		return nil, cli.Exit(fmt.Sprintf("Failed to initialize updater: %v", err), 1)
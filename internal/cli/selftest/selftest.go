@@ -0,0 +1,255 @@
+// Package selftest implements the hidden '_selftest' command, an offline smoke test for packagers.
+//
+// The self-test starts a local HTTP server that imitates the GitHub API and raw content host,
+// then runs the real 'add' and 'install' commands against it inside a temporary project. It
+// exercises tag resolution, commit pinning, downloading, hashing and manifest/lockfile writes
+// without touching the network or the user's cache and configuration.
+package selftest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/cli/add"
+	"github.com/nightconcept/almandine/internal/cli/install"
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/globalconfig"
+	"github.com/nightconcept/almandine/internal/core/hasher"
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+	"github.com/nightconcept/almandine/internal/core/logger"
+	"github.com/nightconcept/almandine/internal/core/paths"
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+// Fixture repository served by the local server.
+const (
+	fixtureOwner   = "almd-selftest"
+	fixtureRepo    = "fixture"
+	fixturePath    = "src/fixture.lua"
+	fixtureSource  = "github:" + fixtureOwner + "/" + fixtureRepo + "/" + fixturePath + "@v1.*"
+	fixtureTag     = "v1.1.0"
+	fixtureCommit  = "5e1f7e57a1b2c3d4e5f60718293a4b5c6d7e8f90"
+	fixtureContent = "-- almd self-test fixture\nreturn { ok = true }\n"
+	fixtureDepName = "fixture"
+	fixtureDepPath = "src/lib/fixture.lua"
+)
+
+// report receives step results; it stays on the real stdout while command output may be silenced.
+var report = os.Stdout
+
+// SelftestCmd returns the hidden '_selftest' command.
+func SelftestCmd() *cli.Command {
+	return &cli.Command{
+		Name:   "_selftest",
+		Usage:  "Run an offline end-to-end smoke test against a built-in fixture server",
+		Hidden: true,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "verbose", Usage: "Show the output of the commands being exercised"},
+		},
+		Action: func(c *cli.Context) error {
+			if err := Run(c.Bool("verbose") || logger.Verbose()); err != nil {
+				return cli.Exit(fmt.Sprintf("Self-test FAILED: %v", err), 1)
+			}
+			_, _ = fmt.Fprintln(os.Stdout, "Self-test passed.")
+			return nil
+		},
+	}
+}
+
+// fixtureHandler serves the tags and commits API endpoints and the raw fixture file.
+func fixtureHandler() http.Handler {
+	repoAPI := "/repos/" + fixtureOwner + "/" + fixtureRepo
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == repoAPI+"/tags":
+			tags := []map[string]any{
+				{"name": "v1.0.0", "commit": map[string]string{"sha": strings.Repeat("1", 40)}},
+				{"name": fixtureTag, "commit": map[string]string{"sha": fixtureCommit}},
+				{"name": "v2.0.0", "commit": map[string]string{"sha": strings.Repeat("2", 40)}},
+			}
+			_ = json.NewEncoder(w).Encode(tags)
+		case r.URL.Path == repoAPI+"/commits":
+			_ = json.NewEncoder(w).Encode([]map[string]string{{"sha": fixtureCommit}})
+		case strings.HasPrefix(r.URL.Path, "/"+fixtureOwner+"/"+fixtureRepo+"/") && strings.HasSuffix(r.URL.Path, "/"+fixturePath):
+			_, _ = w.Write([]byte(fixtureContent))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// Run executes the self-test. Global settings it changes (working directory, source test mode,
+// API base URL and directory overrides) are restored before it returns.
+func Run(verbose bool) (err error) {
+	server := httptest.NewServer(fixtureHandler())
+	defer server.Close()
+
+	workDir, err := os.MkdirTemp("", "almd-selftest-")
+	if err != nil {
+		return fmt.Errorf("creating temporary directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(workDir) }()
+
+	restore, err := isolate(server.URL, workDir, verbose)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	for _, step := range []struct {
+		name string
+		run  func() error
+	}{
+		{"create project", createProject},
+		{"add resolves tag pattern and pins commit", runAdd},
+		{"downloaded file matches fixture hash", checkInstalledFile},
+		{"install restores a missing file", runInstall},
+	} {
+		if stepErr := step.run(); stepErr != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "FAIL %s\n", step.name)
+			return fmt.Errorf("%s: %w", step.name, stepErr)
+		}
+		_, _ = fmt.Fprintf(report, "ok   %s\n", step.name)
+	}
+	return nil
+}
+
+// isolate points almd at the fixture server and temporary directories and returns a function
+// that undoes every change.
+func isolate(serverURL, workDir string, verbose bool) (func(), error) {
+	originalWD, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("getting working directory: %w", err)
+	}
+	projectDir := filepath.Join(workDir, "project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		return nil, fmt.Errorf("creating project directory: %w", err)
+	}
+	if err := os.Chdir(projectDir); err != nil {
+		return nil, fmt.Errorf("changing to project directory: %w", err)
+	}
+
+	source.SetTestModeBypassHostValidation(true)
+	source.GithubAPIBaseURLMutex.Lock()
+	originalBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = serverURL
+	source.GithubAPIBaseURLMutex.Unlock()
+
+	paths.SetOverride(paths.Cache, filepath.Join(workDir, "cache"))
+	paths.SetOverride(paths.Config, filepath.Join(workDir, "config"))
+	paths.SetOverride(paths.State, filepath.Join(workDir, "state"))
+	originalToken, hadToken := os.LookupEnv(globalconfig.GitHubTokenEnv)
+	_ = os.Unsetenv(globalconfig.GitHubTokenEnv) // Never send a real token to the fixture server
+
+	originalStdout, originalColorOutput := os.Stdout, color.Output
+	report = originalStdout
+	if !verbose {
+		if devNull, openErr := os.OpenFile(os.DevNull, os.O_WRONLY, 0); openErr == nil {
+			os.Stdout, color.Output = devNull, devNull
+		}
+	}
+
+	return func() {
+		if os.Stdout != originalStdout {
+			_ = os.Stdout.Close()
+			os.Stdout, color.Output = originalStdout, originalColorOutput
+		}
+		if hadToken {
+			_ = os.Setenv(globalconfig.GitHubTokenEnv, originalToken)
+		}
+		paths.SetOverride(paths.Cache, "")
+		paths.SetOverride(paths.Config, "")
+		paths.SetOverride(paths.State, "")
+		source.GithubAPIBaseURLMutex.Lock()
+		source.GithubAPIBaseURL = originalBaseURL
+		source.GithubAPIBaseURLMutex.Unlock()
+		source.SetTestModeBypassHostValidation(false)
+		_ = os.Chdir(originalWD)
+	}, nil
+}
+
+// runCommand runs a single almd command in-process.
+func runCommand(cmd *cli.Command, args ...string) error {
+	app := &cli.App{
+		Name:           "almd",
+		Commands:       []*cli.Command{cmd},
+		ExitErrHandler: func(_ *cli.Context, _ error) {}, // Report errors instead of exiting
+	}
+	return app.Run(append([]string{"almd", cmd.Name}, args...))
+}
+
+func createProject() error {
+	content := "[package]\nname = \"almd-selftest\"\nversion = \"0.0.0\"\n"
+	return os.WriteFile(config.ManifestPath("."), []byte(content), 0644)
+}
+
+func runAdd() error {
+	if err := runCommand(add.AddCmd(), "-n", fixtureDepName, fixtureSource); err != nil {
+		return err
+	}
+
+	proj, err := config.LoadProjectToml(".")
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", config.ManifestName(), err)
+	}
+	dep, ok := proj.Dependencies[fixtureDepName]
+	if !ok {
+		return fmt.Errorf("%s has no '%s' dependency", config.ManifestName(), fixtureDepName)
+	}
+	if dep.Source != fixtureSource || dep.Path != fixtureDepPath {
+		return fmt.Errorf("unexpected manifest entry: source %q, path %q", dep.Source, dep.Path)
+	}
+
+	lf, err := lockfile.Load(".")
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", lockfile.LockfileName, err)
+	}
+	entry, ok := lf.Package[fixtureDepName]
+	if !ok {
+		return fmt.Errorf("%s has no '%s' entry", lockfile.LockfileName, fixtureDepName)
+	}
+	if entry.Hash != "commit:"+fixtureCommit {
+		return fmt.Errorf("expected locked hash commit:%s, got %s", fixtureCommit, entry.Hash)
+	}
+	if !strings.Contains(entry.Source, fixtureTag) {
+		return fmt.Errorf("expected locked source to reference tag %s, got %s", fixtureTag, entry.Source)
+	}
+	return nil
+}
+
+func checkInstalledFile() error {
+	content, err := os.ReadFile(filepath.FromSlash(fixtureDepPath))
+	if err != nil {
+		return err
+	}
+	got, err := hasher.CalculateSHA256(content)
+	if err != nil {
+		return err
+	}
+	want, err := hasher.CalculateSHA256([]byte(fixtureContent))
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("hash mismatch: got %s, want %s", got, want)
+	}
+	return nil
+}
+
+func runInstall() error {
+	if err := os.Remove(filepath.FromSlash(fixtureDepPath)); err != nil {
+		return err
+	}
+	if err := runCommand(install.InstallCmd()); err != nil {
+		return err
+	}
+	return checkInstalledFile()
+}
@@ -0,0 +1,20 @@
+package selftest
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	originalWD, err := os.Getwd()
+	require.NoError(t, err)
+
+	require.NoError(t, Run(false))
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	assert.Equal(t, originalWD, wd, "the working directory is restored")
+}
@@ -0,0 +1,206 @@
+// Package setup implements the 'setup' command, an interactive wizard that creates the global
+// configuration, together with the one-time hint that points new users at it.
+package setup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/globalconfig"
+	"github.com/nightconcept/almandine/internal/core/paths"
+	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/nightconcept/almandine/internal/core/theme"
+	"github.com/nightconcept/almandine/internal/core/warnings"
+)
+
+// defaultLibDir mirrors the default of 'add --directory'.
+const defaultLibDir = "src/lib/"
+
+// firstRunMarker is created in the state directory once the first-run hint has been shown.
+const firstRunMarker = "first-run-hint-shown"
+
+// SetupCmd returns the 'setup' command.
+func SetupCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "setup",
+		Usage: "Create or update the global configuration interactively",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "yes", Aliases: []string{"y"}, Usage: "Accept current values and defaults without prompting"},
+			&cli.BoolFlag{Name: "skip-check", Usage: "Do not test the connection to GitHub"},
+		},
+		Action: setupAction,
+	}
+}
+
+func setupAction(c *cli.Context) error {
+	cfg, err := globalconfig.Load()
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	configPath, err := globalconfig.Path()
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+
+	if !c.Bool("yes") {
+		_, _ = fmt.Fprintf(os.Stdout, "Configuring almd. Settings are saved to %s.\nPress Enter to keep the value shown in parentheses.\n\n", configPath)
+		if err := promptSettings(bufio.NewReader(os.Stdin), cfg); err != nil {
+			return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+		}
+	}
+	if cfg.LibDir == "" {
+		cfg.LibDir = defaultLibDir
+	}
+	if cfg.Color == "" {
+		cfg.Color = globalconfig.ColorAuto
+	}
+
+	if err := globalconfig.Save(cfg); err != nil {
+		return cli.Exit(fmt.Sprintf("Error saving configuration: %v", err), 1)
+	}
+	_, _ = fmt.Fprintf(os.Stdout, "\nSaved %s\n", configPath)
+
+	if !c.Bool("skip-check") {
+		checkGitHub()
+	}
+	printNextSteps()
+	return nil
+}
+
+// promptWithDefault asks the user for input and returns the entered value or a default if input is empty.
+func promptWithDefault(reader *bufio.Reader, promptText string, defaultValue string) (string, error) {
+	if defaultValue != "" {
+		_, _ = fmt.Fprintf(os.Stdout, "%s (default: %s): ", promptText, defaultValue)
+	} else {
+		_, _ = fmt.Fprintf(os.Stdout, "%s: ", promptText)
+	}
+
+	input, err := reader.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && input != "") {
+		return "", fmt.Errorf("failed to read input for '%s': %w", promptText, err)
+	}
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return defaultValue, nil
+	}
+	return input, nil
+}
+
+// promptSettings walks through every setting, updating cfg in place.
+func promptSettings(reader *bufio.Reader, cfg *globalconfig.Config) error {
+	tokenDefault := ""
+	if cfg.GitHubToken != "" {
+		tokenDefault = maskToken(cfg.GitHubToken) + ", '-' to clear"
+	}
+	token, err := promptWithDefault(reader, "GitHub token (optional, raises API rate limits)", tokenDefault)
+	if err != nil {
+		return err
+	}
+	switch token {
+	case tokenDefault:
+		// Keep the existing token.
+	case "-":
+		cfg.GitHubToken = ""
+	default:
+		cfg.GitHubToken = token
+	}
+
+	libDir := cfg.LibDir
+	if libDir == "" {
+		libDir = defaultLibDir
+	}
+	if cfg.LibDir, err = promptWithDefault(reader, "Default library directory for 'add'", libDir); err != nil {
+		return err
+	}
+
+	if cfg.Color, err = promptColor(reader, cfg.Color); err != nil {
+		return err
+	}
+
+	telemetryDefault := "n"
+	if cfg.Telemetry {
+		telemetryDefault = "y"
+	}
+	answer, err := promptWithDefault(reader, "Share anonymous usage statistics if almd adds them? [y/n]", telemetryDefault)
+	if err != nil {
+		return err
+	}
+	cfg.Telemetry = strings.HasPrefix(strings.ToLower(answer), "y")
+	return nil
+}
+
+// promptColor asks for the color mode until a valid one is entered.
+func promptColor(reader *bufio.Reader, current string) (string, error) {
+	if current == "" {
+		current = globalconfig.ColorAuto
+	}
+	for {
+		value, err := promptWithDefault(reader, "Color output (auto, always, never)", current)
+		if err != nil {
+			return "", err
+		}
+		value = strings.ToLower(value)
+		if validateErr := globalconfig.ValidateColor(value); validateErr == nil {
+			return value, nil
+		}
+		_, _ = fmt.Fprintf(os.Stdout, "Please enter auto, always or never.\n")
+	}
+}
+
+// maskToken hides all but the last four characters of a token.
+func maskToken(token string) string {
+	if len(token) <= 4 {
+		return "****"
+	}
+	return "****" + token[len(token)-4:]
+}
+
+// checkGitHub reports whether GitHub is reachable with the configured credentials. Failures are
+// printed as warnings; the configuration has already been saved.
+func checkGitHub() {
+	_, _ = fmt.Fprint(os.Stdout, "Checking connection to GitHub... ")
+	if err := source.CheckConnectivity(); err != nil {
+		_, _ = theme.New(theme.Warning).Fprintln(os.Stdout, "failed")
+		warnings.Printf("%v\nCheck your network connection or proxy settings, and that the token is valid.", err)
+		return
+	}
+	_, _ = theme.New(theme.OK).Fprintln(os.Stdout, "ok")
+}
+
+func printNextSteps() {
+	_, _ = fmt.Fprintln(os.Stdout, "\nNext steps:")
+	_, _ = fmt.Fprintln(os.Stdout, "  almd init                 Create a project.toml in the current directory")
+	_, _ = fmt.Fprintln(os.Stdout, "  almd add <source>         Add a dependency, e.g. github:kikito/inspect.lua/inspect.lua@master")
+	_, _ = fmt.Fprintln(os.Stdout, "  almd install              Install everything listed in project.toml")
+	_, _ = fmt.Fprintf(os.Stdout, "\n$%s overrides the stored token; run 'almd setup' again at any time to change settings.\n", globalconfig.GitHubTokenEnv)
+}
+
+// ShowFirstRunHint prints a one-time pointer to 'almd setup' when no global configuration exists.
+// The hint is recorded in the state directory so it is shown at most once.
+func ShowFirstRunHint(w io.Writer) {
+	if globalconfig.Exists() {
+		return
+	}
+	stateDir, err := paths.StateDir()
+	if err != nil {
+		return
+	}
+	marker := filepath.Join(stateDir, firstRunMarker)
+	if _, err := os.Stat(marker); err == nil {
+		return
+	}
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return
+	}
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		return
+	}
+	_, _ = fmt.Fprintln(w, "Tip: run 'almd setup' to configure a GitHub token and default settings. This message is shown once.")
+}
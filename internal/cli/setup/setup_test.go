@@ -0,0 +1,139 @@
+package setup
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/globalconfig"
+	"github.com/nightconcept/almandine/internal/core/paths"
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+// setupTestDirs points the config and state directories at temporary locations.
+func setupTestDirs(t *testing.T) {
+	t.Helper()
+	t.Setenv(paths.ConfigDirEnv, filepath.Join(t.TempDir(), "config"))
+	t.Setenv(paths.StateDirEnv, filepath.Join(t.TempDir(), "state"))
+	t.Setenv(globalconfig.GitHubTokenEnv, "")
+}
+
+// runSetupCommand runs 'setup' with the given lines on stdin and returns captured stdout.
+func runSetupCommand(t *testing.T, inputs []string, args ...string) (string, error) {
+	t.Helper()
+	stdinR, stdinW, err := os.Pipe()
+	require.NoError(t, err)
+	_, _ = stdinW.WriteString(strings.Join(inputs, "\n") + "\n")
+	_ = stdinW.Close()
+	stdoutR, stdoutW, err := os.Pipe()
+	require.NoError(t, err)
+
+	oldStdin, oldStdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = stdinR, stdoutW
+	defer func() { os.Stdin, os.Stdout = oldStdin, oldStdout }()
+
+	app := &cli.App{
+		Commands:       []*cli.Command{SetupCmd()},
+		ExitErrHandler: func(_ *cli.Context, _ error) {},
+	}
+	runErr := app.Run(append([]string{"almd", "setup"}, args...))
+
+	_ = stdoutW.Close()
+	var out bytes.Buffer
+	_, _ = out.ReadFrom(stdoutR)
+	_ = stdoutR.Close()
+	_ = stdinR.Close()
+	return out.String(), runErr
+}
+
+func TestSetupCommand_Interactive(t *testing.T) {
+	setupTestDirs(t)
+
+	out, err := runSetupCommand(t, []string{"ghp_secret1234", "vendor/", "purple", "never", "y"}, "--skip-check")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Please enter auto, always or never.")
+	assert.Contains(t, out, "Next steps:")
+
+	cfg, err := globalconfig.Load()
+	require.NoError(t, err)
+	assert.Equal(t, &globalconfig.Config{GitHubToken: "ghp_secret1234", LibDir: "vendor/", Color: globalconfig.ColorNever, Telemetry: true}, cfg)
+
+	// Re-running with empty answers keeps everything; the token is never echoed in full.
+	out, err = runSetupCommand(t, []string{"", "", "", ""}, "--skip-check")
+	require.NoError(t, err)
+	assert.Contains(t, out, "****1234")
+	assert.NotContains(t, out, "ghp_secret1234")
+	cfg, err = globalconfig.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "ghp_secret1234", cfg.GitHubToken)
+	assert.True(t, cfg.Telemetry)
+
+	// "-" clears the token.
+	_, err = runSetupCommand(t, []string{"-", "", "", "n"}, "--skip-check")
+	require.NoError(t, err)
+	cfg, err = globalconfig.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "", cfg.GitHubToken)
+	assert.False(t, cfg.Telemetry)
+}
+
+func TestSetupCommand_YesUsesDefaults(t *testing.T) {
+	setupTestDirs(t)
+
+	_, err := runSetupCommand(t, nil, "--yes", "--skip-check")
+	require.NoError(t, err)
+
+	cfg, err := globalconfig.Load()
+	require.NoError(t, err)
+	assert.Equal(t, &globalconfig.Config{LibDir: defaultLibDir, Color: globalconfig.ColorAuto}, cfg)
+}
+
+func TestSetupCommand_ChecksConnectivity(t *testing.T) {
+	setupTestDirs(t)
+	var gotAuth string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer mockServer.Close()
+
+	source.GithubAPIBaseURLMutex.Lock()
+	originalBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = mockServer.URL
+	source.GithubAPIBaseURLMutex.Unlock()
+	defer func() {
+		source.GithubAPIBaseURLMutex.Lock()
+		source.GithubAPIBaseURL = originalBaseURL
+		source.GithubAPIBaseURLMutex.Unlock()
+	}()
+
+	out, err := runSetupCommand(t, []string{"tok-abcd", "", "", ""})
+	require.NoError(t, err)
+	assert.Contains(t, out, "Checking connection to GitHub... ok")
+	assert.Equal(t, "Bearer tok-abcd", gotAuth)
+}
+
+func TestShowFirstRunHint(t *testing.T) {
+	setupTestDirs(t)
+
+	var buf bytes.Buffer
+	ShowFirstRunHint(&buf)
+	assert.Contains(t, buf.String(), "almd setup")
+
+	buf.Reset()
+	ShowFirstRunHint(&buf)
+	assert.Empty(t, buf.String(), "the hint is only shown once")
+
+	setupTestDirs(t)
+	require.NoError(t, globalconfig.Save(&globalconfig.Config{}))
+	ShowFirstRunHint(&buf)
+	assert.Empty(t, buf.String(), "no hint once a global config exists")
+}
@@ -0,0 +1,102 @@
+package token
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/credentials"
+	"github.com/nightconcept/almandine/internal/core/globalconfig"
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+// setPoolAction stores an extra token for the pool in the credential helper and records its
+// name in config.toml. Pool tokens are never written to the file.
+func setPoolAction(c *cli.Context) error {
+	name := c.String("pool")
+	if err := globalconfig.ValidatePoolName(name); err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	if c.Bool("config") {
+		return cli.Exit("Error: pool tokens are only kept in the git credential helper; drop --config", 1)
+	}
+	if !credentials.HelperConfigured() {
+		return cli.Exit("Error: git has no credential helper configured, which pool tokens are stored in. For CI, "+
+			"set $"+globalconfig.GitHubTokensEnv+" instead.", 1)
+	}
+
+	token, err := readToken(os.Stdin)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	cfg, err := globalconfig.Load()
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	if err := credentials.StoreAs(globalconfig.GitHubHost, globalconfig.PoolUsername(name), token); err != nil {
+		return cli.Exit(fmt.Sprintf("Error storing token: %v", err), 1)
+	}
+	if !slices.Contains(cfg.TokenPool, name) {
+		cfg.TokenPool = append(cfg.TokenPool, name)
+		if err := globalconfig.Save(cfg); err != nil {
+			return cli.Exit(fmt.Sprintf("Error saving configuration: %v", err), 1)
+		}
+	}
+	_, _ = fmt.Fprintf(os.Stdout, "Stored pool token '%s' for %s in the %s (%d in the pool). Run 'almd token test' to check it.\n",
+		name, globalconfig.GitHubHost, globalconfig.TokenFromHelper, len(cfg.TokenPool))
+	return nil
+}
+
+// removePoolAction erases a pool token and drops its name from config.toml.
+func removePoolAction(c *cli.Context) error {
+	name := c.String("pool")
+	cfg, err := globalconfig.Load()
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	i := slices.Index(cfg.TokenPool, name)
+	if i < 0 {
+		return cli.Exit(fmt.Sprintf("Error: no pool token named '%s'", name), 1)
+	}
+	if err := credentials.EraseAs(globalconfig.GitHubHost, globalconfig.PoolUsername(name)); err != nil {
+		return cli.Exit(fmt.Sprintf("Error removing token: %v", err), 1)
+	}
+	cfg.TokenPool = slices.Delete(cfg.TokenPool, i, i+1)
+	if err := globalconfig.Save(cfg); err != nil {
+		return cli.Exit(fmt.Sprintf("Error saving configuration: %v", err), 1)
+	}
+	_, _ = fmt.Fprintf(os.Stdout, "Removed pool token '%s'.\n", name)
+	return nil
+}
+
+// testPool checks each pool token named in config.toml. A token the credential helper no
+// longer has or GitHub rejects fails the command after all of them were checked.
+func testPool() error {
+	cfg, err := globalconfig.Load()
+	if err != nil || len(cfg.TokenPool) == 0 {
+		return nil
+	}
+	failed := 0
+	for _, name := range cfg.TokenPool {
+		_, _ = fmt.Fprintf(os.Stdout, "\nPool token '%s':\n", name)
+		token, err := credentials.LookupAs(globalconfig.GitHubHost, globalconfig.PoolUsername(name))
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stdout, "Not found in the %s. Store it again with 'almd token set --pool %s'.\n", globalconfig.TokenFromHelper, name)
+			failed++
+			continue
+		}
+		status, err := source.CheckTokenValue(token)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stdout, "GitHub did not accept the request: %v\n", err)
+			failed++
+			continue
+		}
+		printStatus(status)
+	}
+	if failed > 0 {
+		return cli.Exit(fmt.Sprintf("Error: %d of %d pool token(s) failed the check", failed, len(cfg.TokenPool)), 1)
+	}
+	return nil
+}
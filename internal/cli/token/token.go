@@ -0,0 +1,213 @@
+// Package token implements the 'token' command group, which stores, checks and removes the
+// GitHub token almd sends with API requests, and the extra tokens of the token pool that large
+// scheduled scans rotate through. Tokens are read from stdin or a prompt, never from the command
+// line, and kept in git's credential helper rather than a plaintext file.
+package token
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/credentials"
+	"github.com/nightconcept/almandine/internal/core/globalconfig"
+	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/nightconcept/almandine/internal/core/theme"
+	"github.com/nightconcept/almandine/internal/core/warnings"
+)
+
+// TokenCmd returns the 'token' command with its set, test and remove subcommands.
+func TokenCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "token",
+		Usage: "Store, check and remove the GitHub token",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "set",
+				Usage:     "Store a GitHub token read from stdin or a prompt",
+				ArgsUsage: "[host]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "config", Usage: "Store the token in config.toml instead of the git credential helper"},
+					poolFlag("Store an extra token for the token pool under `NAME`"),
+				},
+				Action: setAction,
+			},
+			{
+				Name:      "test",
+				Usage:     "Check that GitHub accepts the token and any pool tokens, and show their scopes and rate limits",
+				ArgsUsage: "[host]",
+				Action:    testAction,
+			},
+			{
+				Name:      "remove",
+				Usage:     "Remove the stored GitHub token",
+				ArgsUsage: "[host]",
+				Flags:     []cli.Flag{poolFlag("Remove the pool token stored under `NAME`")},
+				Action:    removeAction,
+			},
+		},
+	}
+}
+
+func poolFlag(usage string) cli.Flag {
+	return &cli.StringFlag{Name: "pool", Usage: usage}
+}
+
+// checkHost accepts an optional host argument. Only GitHub is supported for now.
+func checkHost(c *cli.Context) error {
+	if c.NArg() > 1 {
+		return cli.Exit("Error: expected at most one host", 1)
+	}
+	if host := c.Args().First(); host != "" && host != globalconfig.GitHubHost {
+		return cli.Exit(fmt.Sprintf("Error: tokens can only be stored for %s, not '%s'", globalconfig.GitHubHost, host), 1)
+	}
+	return nil
+}
+
+func setAction(c *cli.Context) error {
+	if err := checkHost(c); err != nil {
+		return err
+	}
+	if c.IsSet("pool") {
+		return setPoolAction(c)
+	}
+	useHelper := !c.Bool("config")
+	if useHelper && !credentials.HelperConfigured() {
+		return cli.Exit("Error: git has no credential helper configured. Configure one (for example "+
+			"'git config --global credential.helper osxkeychain', 'manager' or 'libsecret'), or pass --config "+
+			"to store the token in "+globalconfig.FileName+".", 1)
+	}
+
+	token, err := readToken(os.Stdin)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+	cfg, err := globalconfig.Load()
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+
+	where := globalconfig.FileName
+	if useHelper {
+		if err := credentials.Store(globalconfig.GitHubHost, token); err != nil {
+			return cli.Exit(fmt.Sprintf("Error storing token: %v", err), 1)
+		}
+		cfg.CredentialHelper, cfg.GitHubToken = true, "" // Drop any plaintext copy
+		where = "the " + globalconfig.TokenFromHelper
+	} else {
+		cfg.GitHubToken = token
+	}
+	if err := globalconfig.Save(cfg); err != nil {
+		return cli.Exit(fmt.Sprintf("Error saving configuration: %v", err), 1)
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "Stored token for %s in %s. Run 'almd token test' to check it.\n", globalconfig.GitHubHost, where)
+	if os.Getenv(globalconfig.GitHubTokenEnv) != "" {
+		warnings.Printf("$%s is set and takes precedence over the stored token.", globalconfig.GitHubTokenEnv)
+	}
+	return nil
+}
+
+// readToken reads a token from r: all of it when r is a pipe or file, otherwise one line after
+// a prompt.
+func readToken(r *os.File) (string, error) {
+	var input string
+	if info, err := r.Stat(); err == nil && info.Mode()&os.ModeCharDevice == 0 {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return "", fmt.Errorf("reading token from stdin: %w", err)
+		}
+		input = string(data)
+	} else {
+		_, _ = fmt.Fprintf(os.Stdout, "Paste a GitHub token for %s: ", globalconfig.GitHubHost)
+		line, err := bufio.NewReader(r).ReadString('\n')
+		if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+			return "", fmt.Errorf("reading token: %w", err)
+		}
+		input = line
+	}
+	token := strings.TrimSpace(input)
+	switch {
+	case token == "":
+		return "", fmt.Errorf("no token given")
+	case strings.ContainsAny(token, " \t\r\n"):
+		return "", fmt.Errorf("the token must be a single line without spaces")
+	}
+	return token, nil
+}
+
+func testAction(c *cli.Context) error {
+	if err := checkHost(c); err != nil {
+		return err
+	}
+	_, from := globalconfig.GitHubTokenSource()
+	if from == "" {
+		_, _ = fmt.Fprintln(os.Stdout, "No GitHub token is configured; requests are unauthenticated.")
+	} else {
+		_, _ = fmt.Fprintf(os.Stdout, "Token source: %s\n", from)
+	}
+
+	status, err := source.CheckToken()
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: GitHub did not accept the request: %v", err), 1)
+	}
+	printStatus(status)
+	return testPool()
+}
+
+// printStatus prints the scopes and rate limit GitHub reported for a token.
+func printStatus(status *source.TokenStatus) {
+	if status.Authenticated {
+		_, _ = theme.New(theme.OK).Fprintln(os.Stdout, "GitHub accepted the token.")
+		scopes := "none reported (fine-grained tokens do not list scopes)"
+		if len(status.Scopes) > 0 {
+			scopes = strings.Join(status.Scopes, ", ")
+		}
+		_, _ = fmt.Fprintf(os.Stdout, "Scopes:     %s\n", scopes)
+	}
+	_, _ = fmt.Fprintf(os.Stdout, "Rate limit: %d of %d requests left, resets at %s\n",
+		status.Remaining, status.Limit, status.Reset.Local().Format(time.Kitchen))
+}
+
+func removeAction(c *cli.Context) error {
+	if err := checkHost(c); err != nil {
+		return err
+	}
+	if c.IsSet("pool") {
+		return removePoolAction(c)
+	}
+	cfg, err := globalconfig.Load()
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+
+	var removed []string
+	if cfg.CredentialHelper {
+		if err := credentials.Erase(globalconfig.GitHubHost); err != nil {
+			return cli.Exit(fmt.Sprintf("Error removing token: %v", err), 1)
+		}
+		removed = append(removed, "the "+globalconfig.TokenFromHelper)
+	}
+	if cfg.GitHubToken != "" {
+		removed = append(removed, globalconfig.TokenFromConfigFile)
+	}
+	if len(removed) > 0 {
+		cfg.CredentialHelper, cfg.GitHubToken = false, ""
+		if err := globalconfig.Save(cfg); err != nil {
+			return cli.Exit(fmt.Sprintf("Error saving configuration: %v", err), 1)
+		}
+		_, _ = fmt.Fprintf(os.Stdout, "Removed the token for %s from %s.\n", globalconfig.GitHubHost, strings.Join(removed, " and "))
+	} else {
+		_, _ = fmt.Fprintln(os.Stdout, "No stored token to remove.")
+	}
+	if os.Getenv(globalconfig.GitHubTokenEnv) != "" {
+		warnings.Printf("$%s is still set in your environment.", globalconfig.GitHubTokenEnv)
+	}
+	return nil
+}
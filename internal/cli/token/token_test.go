@@ -0,0 +1,186 @@
+package token
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/globalconfig"
+	"github.com/nightconcept/almandine/internal/core/paths"
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+// setupTestEnv isolates the config directory and points git at a file-based credential helper.
+// It returns the helper's store file.
+func setupTestEnv(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv(paths.ConfigDirEnv, filepath.Join(t.TempDir(), "config"))
+	t.Setenv(globalconfig.GitHubTokenEnv, "")
+	t.Setenv(globalconfig.GitHubTokensEnv, "")
+	t.Setenv("NO_COLOR", "1")
+
+	dir := t.TempDir()
+	store := filepath.Join(dir, "credentials")
+	gitconfig := filepath.Join(dir, "gitconfig")
+	require.NoError(t, os.WriteFile(gitconfig, []byte("[credential]\n\thelper = store --file "+filepath.ToSlash(store)+"\n"), 0644))
+	t.Setenv("GIT_CONFIG_GLOBAL", gitconfig)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	return store
+}
+
+// runTokenCommand runs 'token' with stdin as input and returns captured stdout.
+func runTokenCommand(t *testing.T, stdin string, args ...string) (string, error) {
+	t.Helper()
+	stdinR, stdinW, err := os.Pipe()
+	require.NoError(t, err)
+	_, _ = stdinW.WriteString(stdin)
+	_ = stdinW.Close()
+	stdoutR, stdoutW, err := os.Pipe()
+	require.NoError(t, err)
+
+	oldStdin, oldStdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = stdinR, stdoutW
+	defer func() { os.Stdin, os.Stdout = oldStdin, oldStdout }()
+
+	app := &cli.App{
+		Commands:       []*cli.Command{TokenCmd()},
+		ExitErrHandler: func(_ *cli.Context, _ error) {},
+	}
+	runErr := app.Run(append([]string{"almd", "token"}, args...))
+
+	_ = stdoutW.Close()
+	var out bytes.Buffer
+	_, _ = out.ReadFrom(stdoutR)
+	_ = stdoutR.Close()
+	_ = stdinR.Close()
+	return out.String(), runErr
+}
+
+func TestTokenCommand_SetTestRemove(t *testing.T) {
+	store := setupTestEnv(t)
+
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("X-OAuth-Scopes", "repo, read:org")
+		_, _ = w.Write([]byte(`{"resources":{"core":{"limit":5000,"remaining":4990,"reset":1700000000}}}`))
+	}))
+	defer server.Close()
+	originalBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = server.URL
+	defer func() { source.GithubAPIBaseURL = originalBaseURL }()
+
+	out, err := runTokenCommand(t, "ghp_secret\n", "set", "github.com")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Stored token for github.com in the git credential helper")
+	data, err := os.ReadFile(store)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "ghp_secret")
+
+	cfg, err := globalconfig.Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.CredentialHelper)
+	assert.Empty(t, cfg.GitHubToken, "the token must not be written to config.toml")
+
+	out, err = runTokenCommand(t, "", "test")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer ghp_secret", gotAuth)
+	assert.Contains(t, out, "Token source: git credential helper")
+	assert.Contains(t, out, "Scopes:     repo, read:org")
+	assert.Contains(t, out, "Rate limit: 4990 of 5000 requests left")
+
+	out, err = runTokenCommand(t, "", "remove")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Removed the token for github.com from the git credential helper")
+	token, from := globalconfig.GitHubTokenSource()
+	assert.Empty(t, token)
+	assert.Empty(t, from)
+}
+
+func TestTokenCommand_SetToConfig(t *testing.T) {
+	setupTestEnv(t)
+
+	out, err := runTokenCommand(t, "ghp_plain\n", "set", "--config")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Stored token for github.com in config.toml")
+	token, from := globalconfig.GitHubTokenSource()
+	assert.Equal(t, "ghp_plain", token)
+	assert.Equal(t, globalconfig.TokenFromConfigFile, from)
+}
+
+func TestTokenCommand_SetErrors(t *testing.T) {
+	setupTestEnv(t)
+
+	_, err := runTokenCommand(t, "ghp_secret\n", "set", "gitlab.com")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "tokens can only be stored for github.com")
+
+	_, err = runTokenCommand(t, "\n", "set")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no token given")
+
+	gitconfig := filepath.Join(t.TempDir(), "gitconfig")
+	require.NoError(t, os.WriteFile(gitconfig, nil, 0644))
+	t.Setenv("GIT_CONFIG_GLOBAL", gitconfig)
+	_, err = runTokenCommand(t, "ghp_secret\n", "set")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "git has no credential helper configured")
+}
+
+func TestTokenCommand_Pool(t *testing.T) {
+	store := setupTestEnv(t)
+
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"resources":{"core":{"limit":5000,"remaining":4000,"reset":1700000000}}}`))
+	}))
+	defer server.Close()
+	originalBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = server.URL
+	defer func() { source.GithubAPIBaseURL = originalBaseURL }()
+
+	_, err := runTokenCommand(t, "ghp_main\n", "set")
+	require.NoError(t, err)
+	out, err := runTokenCommand(t, "ghp_scan1\n", "set", "--pool", "scan1")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Stored pool token 'scan1'")
+	_, err = runTokenCommand(t, "ghp_scan2\n", "set", "--pool", "scan2")
+	require.NoError(t, err)
+
+	cfg, err := globalconfig.Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"scan1", "scan2"}, cfg.TokenPool)
+	data, err := os.ReadFile(store)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "ghp_scan1")
+	assert.Equal(t, []string{"ghp_main", "ghp_scan1", "ghp_scan2"}, globalconfig.GitHubTokens())
+
+	out, err = runTokenCommand(t, "", "test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Bearer ghp_main", "Bearer ghp_scan1", "Bearer ghp_scan2"}, seen, "each token is checked by itself")
+	assert.Contains(t, out, "Pool token 'scan2':")
+
+	out, err = runTokenCommand(t, "", "remove", "--pool", "scan1")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Removed pool token 'scan1'")
+	assert.Equal(t, []string{"ghp_main", "ghp_scan2"}, globalconfig.GitHubTokens())
+
+	_, err = runTokenCommand(t, "ghp_x\n", "set", "--pool", "bad name")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "may only contain letters")
+	_, err = runTokenCommand(t, "ghp_x\n", "set", "--pool", "ci", "--config")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "only kept in the git credential helper")
+}
@@ -0,0 +1,45 @@
+package verify
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+
+	"github.com/nightconcept/almandine/internal/core/filestate"
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+	"github.com/nightconcept/almandine/internal/core/project"
+)
+
+// verifyDirectory compares each file of a directory dependency with the hash its lock entry
+// records for it, so no download is needed. It reports the first file with a problem.
+func verifyDirectory(states *filestate.Cache, name string, dep project.Dependency, entry lockfile.PackageEntry, locked bool) result {
+	r := result{Name: name, Path: dep.Path, Status: statusOK}
+	for _, file := range dep.Files {
+		filePath := path.Join(dep.Path, file)
+		actual, _, err := states.HashFile(filepath.FromSlash(filePath))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			r.Path, r.Status, r.Detail = filePath, statusMissing, "run 'almd install' to restore it"
+			return r
+		case errors.Is(err, fs.ErrPermission):
+			r.Path, r.Status, r.Detail = filePath, statusUnreadable, "almd cannot read the file; check its owner and permissions"
+			return r
+		case err != nil:
+			r.Path, r.Status, r.Detail = filePath, statusUnreadable, err.Error()
+			return r
+		}
+		expected, ok := entry.Files[file]
+		if !locked || !ok {
+			r.Path, r.Status, r.Detail = filePath, statusUnlocked, "run 'almd install' to record it in "+lockfile.LockfileName
+			return r
+		}
+		if actual != expected {
+			r.Path, r.Status = filePath, statusModified
+			r.Detail = fmt.Sprintf("differs from the locked content; run 'almd install --force %s' to restore it", name)
+			return r
+		}
+	}
+	return r
+}
@@ -0,0 +1,305 @@
+// Package verify implements the 'verify' command, which checks vendored dependency files
+// against the hashes recorded in almd-lock.toml.
+package verify
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/cache"
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/downloader"
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+	"github.com/nightconcept/almandine/internal/core/filemode"
+	"github.com/nightconcept/almandine/internal/core/filestate"
+	"github.com/nightconcept/almandine/internal/core/hasher"
+	"github.com/nightconcept/almandine/internal/core/jobsummary"
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/nightconcept/almandine/internal/core/theme"
+	"github.com/nightconcept/almandine/internal/core/warnings"
+)
+
+// Statuses reported for each dependency.
+const (
+	statusOK         = "ok"
+	statusMissing    = "missing"
+	statusUnlocked   = "unlocked"
+	statusUnreadable = "unreadable"
+	statusModified   = "modified"
+	statusUnchecked  = "unchecked"
+	statusWritable   = "writable"
+	statusRewritten  = "rewritten"
+	statusMalformed  = "malformed"
+)
+
+// result is the outcome of verifying one dependency.
+type result struct {
+	Name   string
+	Path   string
+	Status string
+	Detail string
+}
+
+// VerifyCmd returns the 'verify' command.
+func VerifyCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "verify",
+		Usage: "Check vendored dependency files against the lockfile",
+		Description: "Re-hashes every dependency file and compares it with almd-lock.toml. Files locked to a\n" +
+			"GitHub commit are compared with the content at that commit, and transformed files with\n" +
+			"the recorded transformed_hash. When the read_only setting is enabled, files that have\n" +
+			"become writable are reported as well. Files whose size and modification time are\n" +
+			"unchanged since they were last hashed are not read again unless --full is given.",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "fix-permissions", Usage: "Make writable dependency files read-only again (requires read_only)"},
+			&cli.BoolFlag{Name: "full", Usage: "Re-hash every file, even those unchanged since the last verify"},
+			&cli.StringSliceFlag{Name: "label", Usage: "Only verify dependencies with this label (repeat for any of several)"},
+		},
+		Action: verifyAction,
+	}
+}
+
+func verifyAction(c *cli.Context) error {
+	proj, lf, err := loadProject(".")
+	if err != nil {
+		return err
+	}
+	if err := lf.VerifyChecksum(); err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %s: %v", lockfile.LockfileName, err), exitcode.Integrity)
+	}
+
+	readOnly := filemode.GlobalSettings().ReadOnly
+	if c.Bool("fix-permissions") && !readOnly {
+		return cli.Exit("Error: --fix-permissions requires read_only to be enabled in the global config", 1)
+	}
+
+	names := make([]string, 0, len(proj.Dependencies))
+	for name := range proj.Dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if names, err = project.SelectByLabels(proj.Dependencies, names, c.StringSlice("label")); err != nil {
+		return cli.Exit(fmt.Sprintf("Error: %v", err), 1)
+	}
+
+	states := filestate.Load(".")
+	if c.Bool("full") {
+		states.Clear()
+	}
+	var results []result
+	for _, name := range names {
+		var r result
+		if problem := lf.Malformed[name]; problem != nil {
+			r = result{Name: name, Path: proj.Dependencies[name].Path, Status: statusMalformed,
+				Detail: fmt.Sprintf("the lock entry is malformed (%v); run 'almd lock repair %s' to rebuild it", problem, name)}
+		} else {
+			entry, locked := lf.Package[name]
+			r = verifyDependency(states, name, proj.Dependencies[name], entry, locked)
+		}
+		if readOnly && r.Status == statusOK {
+			r = checkProtection(r, proj.Dependencies[name].FilePaths(), c.Bool("fix-permissions"))
+		}
+		results = append(results, r)
+	}
+	if err := states.Save(); err != nil {
+		warnings.Printf("could not save file state: %v", err)
+	}
+	writeJobSummary(results)
+	return report(results, readOnly)
+}
+
+// writeJobSummary adds the dependencies with problems to the GitHub Actions job summary.
+// Failing to write it is reported as a warning.
+func writeJobSummary(results []result) {
+	if !jobsummary.Enabled() {
+		return
+	}
+	table := jobsummary.Table{Title: "almd verify", Header: []string{"Dependency", "Status", "Path", "Detail"}}
+	for _, r := range results {
+		if r.Status != statusOK {
+			table.Rows = append(table.Rows, []string{r.Name, r.Status, r.Path, r.Detail})
+		}
+	}
+	table.Note = fmt.Sprintf("Found problems with %d of %d dependencies.", len(table.Rows), len(results))
+	if len(table.Rows) == 0 {
+		table.Note = fmt.Sprintf("All %d dependencies match %s.", len(results), lockfile.LockfileName)
+	}
+	if err := jobsummary.Write(table); err != nil {
+		warnings.Printf("could not write the job summary: %v", err)
+	}
+}
+
+// loadProject loads project.toml and almd-lock.toml from projectRoot.
+func loadProject(projectRoot string) (*project.Project, *lockfile.Lockfile, error) {
+	proj, err := config.LoadProjectToml(projectRoot)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, cli.Exit(fmt.Sprintf("Error: %s not found in the current directory.", config.ManifestName()), 1)
+		}
+		return nil, nil, cli.Exit(fmt.Sprintf("Error loading %s: %v", config.ManifestName(), err), 1)
+	}
+	lf, err := lockfile.Load(projectRoot)
+	if err != nil {
+		return nil, nil, cli.Exit(fmt.Sprintf("Error loading %s: %v", lockfile.LockfileName, err), 1)
+	}
+	return proj, lf, nil
+}
+
+// verifyDependency compares the file on disk with its lockfile entry. The file is only read when
+// states has no up-to-date hash for it.
+func verifyDependency(states *filestate.Cache, name string, dep project.Dependency, entry lockfile.PackageEntry, locked bool) result {
+	if dep.IsDirectory() {
+		return verifyDirectory(states, name, dep, entry, locked)
+	}
+	r := result{Name: name, Path: dep.Path, Status: statusOK}
+	actual, _, err := states.HashFile(filepath.FromSlash(dep.Path))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		r.Status, r.Detail = statusMissing, "run 'almd install' to restore it"
+		return r
+	case errors.Is(err, fs.ErrPermission):
+		r.Status, r.Detail = statusUnreadable, "almd cannot read the file; check its owner and permissions"
+		return r
+	case err != nil:
+		r.Status, r.Detail = statusUnreadable, err.Error()
+		return r
+	}
+	if !locked {
+		r.Status, r.Detail = statusUnlocked, "run 'almd install' to record it in "+lockfile.LockfileName
+		return r
+	}
+
+	expected, err := expectedHash(entry, dep.Headers)
+	if errors.Is(err, source.ErrUpstreamRewritten) {
+		r.Status = statusRewritten
+		r.Detail = fmt.Sprintf("%v; the repository was probably force-pushed or the commit deleted. Change the ref in %s "+
+			"to a commit that still exists and run 'almd install %s'", err, config.ManifestName(), name)
+		return r
+	}
+	if err != nil {
+		r.Status, r.Detail = statusUnchecked, err.Error()
+		return r
+	}
+	if actual != expected {
+		r.Status = statusModified
+		r.Detail = fmt.Sprintf("differs from the locked content; run 'almd install --force %s' to restore it", name)
+	}
+	return r
+}
+
+// expectedHash returns the "sha256:<hex>" hash the file should have. Transformed and generated
+// entries record the hash of the file as written. Entries locked to a GitHub commit only record the commit, so
+// the content at that commit is fetched (through the download cache, sending the dependency's
+// headers) and hashed.
+func expectedHash(entry lockfile.PackageEntry, headers map[string]string) (string, error) {
+	switch {
+	case entry.WrittenDiffers():
+		if !strings.HasPrefix(entry.TransformedHash, "sha256:") {
+			return "", fmt.Errorf("lockfile entry is transformed or generated but has no transformed_hash")
+		}
+		return entry.TransformedHash, nil
+	case strings.HasPrefix(entry.Hash, "sha256:"):
+		return entry.Hash, nil
+	case strings.HasPrefix(entry.Hash, "commit:"):
+		url, err := pinnedURL(entry.Source, strings.TrimPrefix(entry.Hash, "commit:"))
+		if err != nil {
+			return "", err
+		}
+		content, err := fetch(url, headers)
+		if downloader.IsNotFound(err) {
+			return "", fmt.Errorf("%w: the locked commit no longer exists upstream (%v)", source.ErrUpstreamRewritten, err)
+		}
+		if err != nil {
+			return "", fmt.Errorf("fetching locked content: %w", err)
+		}
+		return hasher.CalculateSHA256(content)
+	default:
+		return "", fmt.Errorf("unsupported lockfile hash '%s'", entry.Hash)
+	}
+}
+
+// pinnedURL returns the raw URL of the locked file at commit sha. The locked source of a
+// dependency added from a branch still names the branch, so it cannot be fetched as is.
+func pinnedURL(lockedSource, sha string) (string, error) {
+	parsed, err := source.ParseSourceURL(lockedSource)
+	if err != nil {
+		return "", fmt.Errorf("parsing locked source '%s': %w", lockedSource, err)
+	}
+	pinned, err := parsed.AtCommit(sha)
+	if err != nil {
+		return "", err
+	}
+	return pinned.RawURL, nil
+}
+
+func fetch(url string, headers map[string]string) ([]byte, error) {
+	store, err := cache.Open()
+	if err != nil {
+		return downloader.WithHeaders(headers)(url)
+	}
+	content, _, err := store.Fetch(url, true, downloader.WithHeaders(headers))
+	return content, err
+}
+
+// checkProtection flags intact files, the dependency's paths, that are writable even though
+// read_only is enabled, optionally protecting them again.
+func checkProtection(r result, paths []string, fix bool) result {
+	for _, relPath := range paths {
+		path := filepath.FromSlash(relPath)
+		info, err := os.Stat(path)
+		if err != nil || !filemode.IsWritable(info) {
+			continue
+		}
+		if !fix {
+			r.Status, r.Detail = statusWritable, "read_only is enabled but the file is writable; run 'almd verify --fix-permissions'"
+			return r
+		}
+		if err := filemode.Protect(path); err != nil {
+			r.Status, r.Detail = statusWritable, fmt.Sprintf("could not make the file read-only: %v", err)
+			return r
+		}
+		r.Detail = "made read-only"
+	}
+	return r
+}
+
+// report prints one line per dependency and fails when any of them has a problem.
+func report(results []result, readOnly bool) error {
+	okColor := theme.SprintFunc(theme.OK)
+	problemColor := theme.SprintFunc(theme.Problem)
+	detailColor := theme.SprintFunc(theme.Muted)
+
+	problems, modified := 0, false
+	for _, r := range results {
+		status := okColor(fmt.Sprintf("%-10s", r.Status))
+		if r.Status != statusOK {
+			status = problemColor(fmt.Sprintf("%-10s", r.Status))
+			problems++
+			modified = modified || r.Status == statusModified || r.Status == statusWritable
+		}
+		_, _ = fmt.Fprintf(os.Stdout, "%s %s %s\n", status, r.Name, r.Path)
+		if r.Detail != "" {
+			_, _ = fmt.Fprintf(os.Stdout, "           %s\n", detailColor(r.Detail))
+		}
+	}
+
+	if problems == 0 {
+		_, _ = fmt.Fprintf(os.Stdout, "All %d dependencies match %s.\n", len(results), lockfile.LockfileName)
+		return nil
+	}
+	if modified && readOnly {
+		_, _ = fmt.Fprintln(os.Stdout, "\nread_only is enabled: vendored files are write-protected to discourage local edits. "+
+			"'almd install' and 'almd add' lift the protection while they replace files. To change a dependency, "+
+			"update its source instead of editing the file.")
+	}
+	return cli.Exit(fmt.Sprintf("Found problems with %d of %d dependencies.", problems, len(results)), exitcode.Integrity)
+}
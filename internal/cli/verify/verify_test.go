@@ -0,0 +1,391 @@
+package verify
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+
+	"github.com/nightconcept/almandine/internal/core/config"
+	"github.com/nightconcept/almandine/internal/core/exitcode"
+	"github.com/nightconcept/almandine/internal/core/globalconfig"
+	"github.com/nightconcept/almandine/internal/core/hasher"
+	"github.com/nightconcept/almandine/internal/core/jobsummary"
+	"github.com/nightconcept/almandine/internal/core/paths"
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+// setupVerifyTestEnvironment writes project.toml, almd-lock.toml and dependency files into a
+// temporary directory and isolates the cache and config directories.
+func setupVerifyTestEnvironment(t *testing.T, projectToml, lockToml string, files map[string]string) string {
+	t.Helper()
+	tempDir := t.TempDir()
+	t.Setenv(paths.CacheDirEnv, t.TempDir())
+	t.Setenv(paths.ConfigDirEnv, t.TempDir())
+	t.Setenv(paths.StateDirEnv, t.TempDir())
+	t.Setenv(jobsummary.Env, "") // Keep CI runs of the tests out of the real job summary
+
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, config.ProjectTomlName), []byte(projectToml), 0644))
+	if lockToml != "" {
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, config.LockfileName), []byte(lockToml), 0644))
+	}
+	for rel, content := range files {
+		abs := filepath.Join(tempDir, rel)
+		require.NoError(t, os.MkdirAll(filepath.Dir(abs), 0755))
+		require.NoError(t, os.WriteFile(abs, []byte(content), 0644))
+	}
+	return tempDir
+}
+
+// runVerifyCommand runs 'almd verify' in dir and returns its captured stdout.
+func runVerifyCommand(t *testing.T, dir string, args ...string) (string, error) {
+	t.Helper()
+	t.Setenv("NO_COLOR", "1")
+	originalWD, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	originalStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	defer func() {
+		os.Stdout = originalStdout
+		_ = os.Chdir(originalWD)
+	}()
+
+	app := &cli.App{
+		Commands:       []*cli.Command{VerifyCmd()},
+		ExitErrHandler: func(_ *cli.Context, _ error) {},
+	}
+	runErr := app.Run(append([]string{"almd", "verify"}, args...))
+	_ = w.Close()
+	var out bytes.Buffer
+	_, _ = out.ReadFrom(r)
+	return out.String(), runErr
+}
+
+func sha(t *testing.T, content string) string {
+	t.Helper()
+	h, err := hasher.CalculateSHA256([]byte(content))
+	require.NoError(t, err)
+	return h
+}
+
+func TestVerifyCommand_Statuses(t *testing.T) {
+	projectToml := `
+[package]
+name = "test"
+
+[dependencies]
+good = { source = "https://example.com/good.lua", path = "libs/good.lua" }
+edited = { source = "https://example.com/edited.lua", path = "libs/edited.lua" }
+gone = { source = "https://example.com/gone.lua", path = "libs/gone.lua" }
+fresh = { source = "https://example.com/fresh.lua", path = "libs/fresh.lua" }
+`
+	lockToml := fmt.Sprintf(`
+api_version = "1"
+
+[package.good]
+source = "https://example.com/good.lua"
+path = "libs/good.lua"
+hash = "%s"
+
+[package.edited]
+source = "https://example.com/edited.lua"
+path = "libs/edited.lua"
+hash = "%s"
+
+[package.gone]
+source = "https://example.com/gone.lua"
+path = "libs/gone.lua"
+hash = "%s"
+`, sha(t, "good"), sha(t, "original"), sha(t, "gone"))
+	dir := setupVerifyTestEnvironment(t, projectToml, lockToml, map[string]string{
+		"libs/good.lua":   "good",
+		"libs/edited.lua": "edited locally",
+		"libs/fresh.lua":  "fresh",
+	})
+
+	summaryPath := filepath.Join(t.TempDir(), "summary.md")
+	t.Setenv(jobsummary.Env, summaryPath)
+
+	out, err := runVerifyCommand(t, dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "3 of 4 dependencies")
+	assert.Contains(t, out, "ok         good libs/good.lua")
+	assert.Contains(t, out, "modified   edited libs/edited.lua")
+	assert.Contains(t, out, "almd install --force edited")
+	assert.Contains(t, out, "missing    gone libs/gone.lua")
+	assert.Contains(t, out, "unlocked   fresh libs/fresh.lua")
+
+	summary, err := os.ReadFile(summaryPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(summary), "### almd verify")
+	assert.Contains(t, string(summary), "| gone | missing | libs/gone.lua |")
+	assert.NotContains(t, string(summary), "| good |")
+	assert.Contains(t, string(summary), "Found problems with 3 of 4 dependencies.")
+}
+
+func TestVerifyCommand_CommitLock(t *testing.T) {
+	const commit = "0123456789abcdef0123456789abcdef01234567"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/owner/repo/"+commit+"/lib.lua" {
+			_, _ = w.Write([]byte("upstream"))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	source.SetTestModeBypassHostValidation(true)
+	defer source.SetTestModeBypassHostValidation(false)
+	originalBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = server.URL
+	defer func() { source.GithubAPIBaseURL = originalBaseURL }()
+
+	lockedSource := server.URL + "/owner/repo/main/lib.lua"
+	projectToml := `
+[package]
+name = "test"
+
+[dependencies]
+lib = { source = "github:owner/repo/lib.lua@main", path = "lib.lua" }
+`
+	lockToml := fmt.Sprintf(`
+api_version = "1"
+
+[package.lib]
+source = "%s"
+path = "lib.lua"
+hash = "commit:%s"
+`, lockedSource, commit)
+
+	dir := setupVerifyTestEnvironment(t, projectToml, lockToml, map[string]string{"lib.lua": "upstream"})
+	out, err := runVerifyCommand(t, dir)
+	require.NoError(t, err)
+	assert.Contains(t, out, "ok         lib lib.lua")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib.lua"), []byte("patched"), 0644))
+	out, err = runVerifyCommand(t, dir)
+	require.Error(t, err)
+	assert.Contains(t, out, "modified   lib lib.lua")
+}
+
+func TestVerifyCommand_LockedCommitGone(t *testing.T) {
+	const commit = "0123456789abcdef0123456789abcdef01234567"
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	source.SetTestModeBypassHostValidation(true)
+	defer source.SetTestModeBypassHostValidation(false)
+	originalBaseURL := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = server.URL
+	defer func() { source.GithubAPIBaseURL = originalBaseURL }()
+
+	projectToml := `
+[package]
+name = "test"
+
+[dependencies]
+lib = { source = "github:owner/repo/lib.lua@main", path = "lib.lua" }
+`
+	lockToml := fmt.Sprintf(`
+api_version = "1"
+
+[package.lib]
+source = "%s/owner/repo/main/lib.lua"
+path = "lib.lua"
+hash = "commit:%s"
+`, server.URL, commit)
+
+	dir := setupVerifyTestEnvironment(t, projectToml, lockToml, map[string]string{"lib.lua": "upstream"})
+	out, err := runVerifyCommand(t, dir)
+	require.Error(t, err)
+	assert.Contains(t, out, "rewritten  lib lib.lua")
+	assert.Contains(t, out, "upstream history rewritten or deleted")
+}
+
+func TestVerifyCommand_ReadOnly(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not meaningful on Windows")
+	}
+	projectToml := `
+[package]
+name = "test"
+
+[dependencies]
+lib = { source = "https://example.com/lib.lua", path = "lib.lua" }
+`
+	lockToml := fmt.Sprintf(`
+api_version = "1"
+
+[package.lib]
+source = "https://example.com/lib.lua"
+path = "lib.lua"
+hash = "%s"
+`, sha(t, "content"))
+	dir := setupVerifyTestEnvironment(t, projectToml, lockToml, map[string]string{"lib.lua": "content"})
+
+	_, err := runVerifyCommand(t, dir, "--fix-permissions")
+	require.Error(t, err, "--fix-permissions requires read_only")
+
+	require.NoError(t, globalconfig.Save(&globalconfig.Config{ReadOnly: true}))
+	out, err := runVerifyCommand(t, dir)
+	require.Error(t, err)
+	assert.Contains(t, out, "writable   lib lib.lua")
+	assert.Contains(t, out, "read_only is enabled")
+
+	out, err = runVerifyCommand(t, dir, "--fix-permissions")
+	require.NoError(t, err)
+	assert.Contains(t, out, "made read-only")
+	info, statErr := os.Stat(filepath.Join(dir, "lib.lua"))
+	require.NoError(t, statErr)
+	assert.Zero(t, info.Mode().Perm()&0222)
+
+	_, err = runVerifyCommand(t, dir)
+	require.NoError(t, err)
+}
+
+func TestVerifyCommand_IgnoresVendorHeader(t *testing.T) {
+	projectToml := `
+[package]
+name = "test"
+
+[vendor]
+header = true
+
+[dependencies]
+lib = { source = "https://example.com/lib.lua", path = "libs/lib.lua" }
+`
+	lockToml := fmt.Sprintf(`
+api_version = "1"
+
+[package.lib]
+source = "https://example.com/lib.lua"
+path = "libs/lib.lua"
+hash = "%s"
+`, sha(t, "return {}\n"))
+	dir := setupVerifyTestEnvironment(t, projectToml, lockToml, map[string]string{
+		"libs/lib.lua": "-- almd:vendor-header begin\n-- source: https://example.com/lib.lua\n-- almd:vendor-header end\nreturn {}\n",
+	})
+
+	out, err := runVerifyCommand(t, dir)
+	require.NoError(t, err)
+	assert.Contains(t, out, "ok         lib libs/lib.lua")
+}
+
+func TestVerifyCommand_TransformedFile(t *testing.T) {
+	projectToml := `
+[package]
+name = "test"
+
+[dependencies]
+lib = { source = "https://example.com/lib.lua", path = "libs/lib.lua", transform = "strip-comments" }
+edited = { source = "https://example.com/edited.lua", path = "libs/edited.lua", transform = "strip-comments" }
+`
+	lockToml := fmt.Sprintf(`
+api_version = "1"
+
+[package.lib]
+source = "https://example.com/lib.lua"
+path = "libs/lib.lua"
+hash = "%s"
+transform = "strip-comments"
+transformed_hash = "%s"
+
+[package.edited]
+source = "https://example.com/edited.lua"
+path = "libs/edited.lua"
+hash = "%s"
+transform = "strip-comments"
+transformed_hash = "%s"
+`, sha(t, "-- doc\nreturn {}\n"), sha(t, "return {}\n"), sha(t, "return 1\n"), sha(t, "return 1\n"))
+	dir := setupVerifyTestEnvironment(t, projectToml, lockToml, map[string]string{
+		"libs/lib.lua":    "return {}\n",
+		"libs/edited.lua": "return 2\n",
+	})
+
+	out, err := runVerifyCommand(t, dir)
+	require.Error(t, err)
+	assert.Contains(t, out, "ok         lib libs/lib.lua")
+	assert.Contains(t, out, "modified   edited libs/edited.lua")
+}
+
+func TestVerifyCommand_SkipsUnchangedFiles(t *testing.T) {
+	projectToml := `
+[package]
+name = "test"
+
+[dependencies]
+lib = { source = "https://example.com/lib.lua", path = "libs/lib.lua" }
+`
+	lockToml := fmt.Sprintf(`
+api_version = "1"
+
+[package.lib]
+source = "https://example.com/lib.lua"
+path = "libs/lib.lua"
+hash = "%s"
+`, sha(t, "return 1"))
+	dir := setupVerifyTestEnvironment(t, projectToml, lockToml, map[string]string{"libs/lib.lua": "return 1"})
+	libPath := filepath.Join(dir, "libs", "lib.lua")
+	past := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(libPath, past, past))
+
+	_, err := runVerifyCommand(t, dir)
+	require.NoError(t, err)
+
+	// An edit that keeps the size and modification time is not noticed from the recorded state...
+	require.NoError(t, os.WriteFile(libPath, []byte("return 2"), 0644))
+	require.NoError(t, os.Chtimes(libPath, past, past))
+	out, err := runVerifyCommand(t, dir)
+	require.NoError(t, err)
+	assert.Contains(t, out, "ok         lib libs/lib.lua")
+
+	// ...but --full hashes every file again.
+	out, err = runVerifyCommand(t, dir, "--full")
+	require.Error(t, err)
+	assert.Contains(t, out, "modified   lib libs/lib.lua")
+
+	// Any change to the modification time invalidates the recorded state.
+	require.NoError(t, os.WriteFile(libPath, []byte("return 1"), 0644))
+	require.NoError(t, os.Chtimes(libPath, past.Add(time.Second), past.Add(time.Second)))
+	out, err = runVerifyCommand(t, dir)
+	require.NoError(t, err)
+	assert.Contains(t, out, "ok         lib libs/lib.lua")
+}
+
+func TestVerifyCommand_LockfileChecksum(t *testing.T) {
+	projectToml := `
+[package]
+name = "test"
+
+[dependencies]
+good = { source = "https://example.com/good.lua", path = "libs/good.lua" }
+`
+	lockToml := fmt.Sprintf(`
+api_version = "1"
+checksum = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+
+[package.good]
+source = "https://example.com/good.lua"
+path = "libs/good.lua"
+hash = "%s"
+`, sha(t, "good"))
+	dir := setupVerifyTestEnvironment(t, projectToml, lockToml, map[string]string{"libs/good.lua": "good"})
+
+	_, err := runVerifyCommand(t, dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match its checksum")
+	var exitErr cli.ExitCoder
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, exitcode.Integrity, exitErr.ExitCode())
+}
@@ -0,0 +1,146 @@
+// Package advisory reads the deprecation and yank notices a registry publishes for the sources
+// it serves ([registries.<name>] advisories = "<URL>"), so add, install and outdated can warn
+// about them.
+//
+// An advisory index is a TOML document of [[advisory]] tables:
+//
+//	[[advisory]]
+//	source = "github:owner/repo/json.lua"
+//	versions = ["0123456789abcdef0123456789abcdef01234567"]
+//	status = "yanked"
+//	message = "Decodes numbers incorrectly."
+//	replacement = "github:owner/repo/json.lua@v2.0.0"
+package advisory
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/nightconcept/almandine/internal/core/downloader"
+)
+
+// Statuses an advisory may give a source.
+const (
+	StatusDeprecated = "deprecated" // Still usable, but should be replaced
+	StatusYanked     = "yanked"     // Must not be installed; 'install --strict' refuses it
+)
+
+// Advisory marks versions of a source deprecated or yanked.
+type Advisory struct {
+	// Source is the dependency source as written in project.toml. Without an "@ref" it applies
+	// to the source at any ref.
+	Source string `toml:"source"`
+	// Versions are the commits (full or abbreviated SHAs) or "sha256:<hex>" content hashes the
+	// advisory applies to; empty means every version.
+	Versions    []string `toml:"versions,omitempty"`
+	Status      string   `toml:"status"`
+	Message     string   `toml:"message,omitempty"`
+	Replacement string   `toml:"replacement,omitempty"` // Source to use instead
+}
+
+// Yanked reports whether the advisory yanks its versions.
+func (a *Advisory) Yanked() bool {
+	return a.Status == StatusYanked
+}
+
+// Describe is a one-line warning about dependency name, e.g. "'json' is yanked: Decodes numbers
+// incorrectly. Use github:owner/repo/json.lua@v2.0.0 instead."
+func (a *Advisory) Describe(name string) string {
+	msg := fmt.Sprintf("'%s' is %s", name, a.Status)
+	if a.Message != "" {
+		msg += ": " + strings.TrimSuffix(a.Message, ".") + "."
+	} else {
+		msg += "."
+	}
+	if a.Replacement != "" {
+		msg += " Use " + a.Replacement + " instead."
+	}
+	return msg
+}
+
+// Parse reads an advisory index downloaded from indexURL.
+func Parse(data []byte, indexURL string) ([]Advisory, error) {
+	var index struct {
+		Advisories []Advisory `toml:"advisory"`
+	}
+	if err := toml.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("advisory index %s: %w", indexURL, err)
+	}
+	for i, a := range index.Advisories {
+		if a.Source == "" {
+			return nil, fmt.Errorf("advisory index %s: advisory %d has no source", indexURL, i+1)
+		}
+		if a.Status != StatusDeprecated && a.Status != StatusYanked {
+			return nil, fmt.Errorf("advisory index %s: advisory for '%s' has status '%s' (expected %s or %s)", indexURL, a.Source, a.Status, StatusDeprecated, StatusYanked)
+		}
+	}
+	return index.Advisories, nil
+}
+
+// Match returns the first advisory for a dependency with source (as in project.toml) at version
+// (a commit SHA or "sha256:<hex>" content hash; empty if unknown), or nil. An advisory listing
+// versions never matches an unknown version.
+func Match(advisories []Advisory, source, version string) *Advisory {
+	for i := range advisories {
+		a := &advisories[i]
+		if a.Source != source && !strings.HasPrefix(source, a.Source+"@") {
+			continue
+		}
+		if len(a.Versions) == 0 {
+			return a
+		}
+		for _, v := range a.Versions {
+			if version != "" && v != "" && strings.HasPrefix(version, v) {
+				return a
+			}
+		}
+	}
+	return nil
+}
+
+// indexes holds the advisory index URLs of the current project and, once Load has fetched them,
+// their advisories.
+var indexes struct {
+	mu         sync.Mutex
+	urls       []string
+	loaded     bool
+	advisories []Advisory
+	err        error
+}
+
+// SetIndexes sets the advisory index URLs of the current project. Advisories already loaded are
+// dropped, so each project of a recursive run uses its own.
+func SetIndexes(urls []string) {
+	indexes.mu.Lock()
+	defer indexes.mu.Unlock()
+	indexes.urls = append([]string(nil), urls...)
+	indexes.loaded, indexes.advisories, indexes.err = false, nil, nil
+}
+
+// Load downloads the advisory indexes set with SetIndexes, once per project, and returns their
+// advisories. It returns nil without downloading anything when there are none.
+func Load() ([]Advisory, error) {
+	indexes.mu.Lock()
+	defer indexes.mu.Unlock()
+	if indexes.loaded {
+		return indexes.advisories, indexes.err
+	}
+	indexes.loaded = true
+	for _, indexURL := range indexes.urls {
+		data, err := downloader.DownloadFile(indexURL)
+		if err != nil {
+			indexes.err = fmt.Errorf("fetching advisory index: %w", err)
+			break
+		}
+		advisories, err := Parse(data, indexURL)
+		if err != nil {
+			indexes.err = err
+			break
+		}
+		indexes.advisories = append(indexes.advisories, advisories...)
+	}
+	return indexes.advisories, indexes.err
+}
@@ -0,0 +1,73 @@
+package advisory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testIndex = `
+[[advisory]]
+source = "github:owner/repo/json.lua"
+versions = ["0123456"]
+status = "yanked"
+message = "Decodes numbers incorrectly."
+replacement = "github:owner/repo/json.lua@v2.0.0"
+
+[[advisory]]
+source = "github:owner/repo/old.lua"
+status = "deprecated"
+`
+
+func TestParseAndMatch(t *testing.T) {
+	advisories, err := Parse([]byte(testIndex), "https://example.com/advisories.toml")
+	require.NoError(t, err)
+	require.Len(t, advisories, 2)
+
+	a := Match(advisories, "github:owner/repo/json.lua@main", "0123456789abcdef0123456789abcdef01234567")
+	require.NotNil(t, a)
+	assert.True(t, a.Yanked())
+	assert.Equal(t, "'json' is yanked: Decodes numbers incorrectly. Use github:owner/repo/json.lua@v2.0.0 instead.", a.Describe("json"))
+
+	assert.Nil(t, Match(advisories, "github:owner/repo/json.lua@main", "fedcba9876543210fedcba9876543210fedcba98"), "other versions are not yanked")
+	assert.Nil(t, Match(advisories, "github:owner/repo/json.lua@main", ""), "an unknown version matches no listed version")
+	assert.Nil(t, Match(advisories, "github:owner/repo/json.lua.bak@main", "0123456"), "sources match whole")
+
+	a = Match(advisories, "github:owner/repo/old.lua@v1.0.0", "sha256:abc")
+	require.NotNil(t, a)
+	assert.False(t, a.Yanked())
+	assert.Equal(t, "'old' is deprecated.", a.Describe("old"))
+
+	_, err = Parse([]byte("[[advisory]]\nsource = \"github:o/r/f.lua\"\nstatus = \"removed\"\n"), "index.toml")
+	assert.ErrorContains(t, err, "has status 'removed'")
+	_, err = Parse([]byte("[[advisory]]\nstatus = \"yanked\"\n"), "index.toml")
+	assert.ErrorContains(t, err, "advisory 1 has no source")
+}
+
+func TestLoad(t *testing.T) {
+	t.Cleanup(func() { SetIndexes(nil) })
+	path := filepath.Join(t.TempDir(), "advisories.toml")
+	require.NoError(t, os.WriteFile(path, []byte(testIndex), 0644))
+
+	SetIndexes(nil)
+	advisories, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, advisories)
+
+	SetIndexes([]string{"file://" + filepath.ToSlash(path)})
+	advisories, err = Load()
+	require.NoError(t, err)
+	assert.Len(t, advisories, 2)
+
+	require.NoError(t, os.Remove(path))
+	advisories, err = Load()
+	require.NoError(t, err, "the index is fetched once per project")
+	assert.Len(t, advisories, 2)
+
+	SetIndexes([]string{"file://" + filepath.ToSlash(path)})
+	_, err = Load()
+	assert.ErrorContains(t, err, "fetching advisory index")
+}
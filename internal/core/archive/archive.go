@@ -0,0 +1,161 @@
+// Package archive reads the .tar.gz and .zip archives of archive sources, whose selected files
+// are extracted into the project as one dependency, and maps the files of an archive to the
+// paths they are extracted to; see Spec.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Format is the format of an archive, told by its extension.
+type Format string
+
+// Archive formats.
+const (
+	TarGz Format = "tar.gz" // .tar.gz or .tgz
+	Zip   Format = "zip"
+)
+
+// MaxExtractedSize bounds the total size of the files read from one archive, so that a
+// decompression bomb cannot exhaust memory.
+const MaxExtractedSize = 256 << 20
+
+// FormatOf returns the format of the archive at name, a file name or URL path, or "" when name
+// does not name an archive.
+func FormatOf(name string) Format {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return TarGz
+	case strings.HasSuffix(lower, ".zip"):
+		return Zip
+	}
+	return ""
+}
+
+// TrimExt returns name without its archive extension, e.g. "pkg-1.0" for "pkg-1.0.tar.gz".
+func TrimExt(name string) string {
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".tar.gz", ".tgz", ".zip"} {
+		if strings.HasSuffix(lower, ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+	return name
+}
+
+// Read returns the regular files of an archive by their slash-separated paths inside it.
+// Directories, links and other entries are skipped. An entry whose path is absolute or leaves
+// the archive is an error, as is an archive without files or larger than MaxExtractedSize.
+func Read(format Format, content []byte) (map[string][]byte, error) {
+	var files map[string][]byte
+	var err error
+	switch format {
+	case TarGz:
+		files, err = readTarGz(content)
+	case Zip:
+		files, err = readZip(content)
+	default:
+		return nil, fmt.Errorf("unsupported archive format '%s'", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.New("archive has no files")
+	}
+	return files, nil
+}
+
+func readTarGz(content []byte) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("reading gzip stream: %w", err)
+	}
+	defer func() { _ = gz.Close() }()
+	tr := tar.NewReader(gz)
+	files := make(map[string][]byte)
+	budget := &sizeBudget{left: MaxExtractedSize}
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading tar archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name, err := entryPath(hdr.Name)
+		if err != nil {
+			return nil, err
+		}
+		if files[name], err = budget.read(tr, name); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func readZip(content []byte) (map[string][]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("reading zip archive: %w", err)
+	}
+	files := make(map[string][]byte)
+	budget := &sizeBudget{left: MaxExtractedSize}
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		name, err := entryPath(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("opening '%s' in zip archive: %w", name, err)
+		}
+		files[name], err = budget.read(rc, name)
+		_ = rc.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// entryPath returns the clean, slash-separated path of an archive entry, refusing paths that
+// would be extracted outside the dependency's directory.
+func entryPath(name string) (string, error) {
+	clean := path.Clean(strings.ReplaceAll(name, `\`, "/"))
+	if path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("archive entry '%s' has an unsafe path", name)
+	}
+	return clean, nil
+}
+
+// sizeBudget is what is left of MaxExtractedSize while an archive is read.
+type sizeBudget struct {
+	left int64
+}
+
+func (b *sizeBudget) read(r io.Reader, name string) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, b.left+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading '%s' in archive: %w", name, err)
+	}
+	b.left -= int64(len(data))
+	if b.left < 0 {
+		return nil, fmt.Errorf("archive holds more than %d MiB of files", MaxExtractedSize>>20)
+	}
+	return data, nil
+}
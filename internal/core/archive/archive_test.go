@@ -0,0 +1,120 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tarGz builds a .tar.gz archive of files, plus a directory entry and a symbolic link.
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "pkg-1.0/", Typeflag: tar.TypeDir, Mode: 0755}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "pkg-1.0/link.lua", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}))
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestFormatOf(t *testing.T) {
+	assert.Equal(t, TarGz, FormatOf("https://example.com/pkg-1.0.tar.gz"))
+	assert.Equal(t, TarGz, FormatOf("dist/PKG.TGZ"))
+	assert.Equal(t, Zip, FormatOf("pkg.zip"))
+	assert.Equal(t, Format(""), FormatOf("lib.lua"))
+	assert.Equal(t, "pkg-1.0", TrimExt("pkg-1.0.tar.gz"))
+	assert.Equal(t, "lib.lua", TrimExt("lib.lua"))
+}
+
+func TestRead(t *testing.T) {
+	files := map[string]string{"pkg-1.0/src/init.lua": "return {}", "pkg-1.0/README.md": "# pkg"}
+	for format, content := range map[Format][]byte{TarGz: tarGz(t, files), Zip: zipArchive(t, files)} {
+		read, err := Read(format, content)
+		require.NoError(t, err, format)
+		assert.Equal(t, map[string][]byte{"pkg-1.0/src/init.lua": []byte("return {}"), "pkg-1.0/README.md": []byte("# pkg")}, read, "%s: only regular files are read", format)
+	}
+
+	_, err := Read(Zip, zipArchive(t, map[string]string{"../evil.lua": "x"}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsafe path")
+
+	_, err = Read(TarGz, []byte("not an archive"))
+	require.Error(t, err)
+}
+
+func TestParseSpec(t *testing.T) {
+	spec, err := ParseSpec("pkg/src/=lib")
+	require.NoError(t, err)
+	assert.Equal(t, Spec{Source: "pkg/src/", Dest: "lib"}, spec)
+
+	spec, err = ParseSpec("./pkg/*.lua")
+	require.NoError(t, err)
+	assert.Equal(t, Spec{Source: "pkg/*.lua", Dest: "."}, spec)
+
+	for _, invalid := range []string{"", "=lib", "/etc/", "pkg/[", "pkg/=../outside", "pkg/=/abs"} {
+		_, err := ParseSpec(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestMap(t *testing.T) {
+	paths := []string{"pkg-1.0/README.md", "pkg-1.0/src/init.lua", "pkg-1.0/src/util/str.lua", "pkg-1.0/test/init.lua"}
+
+	mapped, err := Map(paths, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "pkg-1.0/src/util/str.lua", mapped["src/util/str.lua"], "the top-level directory is stripped")
+	assert.Len(t, mapped, 4)
+
+	mapped, err = Map(paths, []Spec{{Source: "pkg-1.0/src/", Dest: "."}, {Source: "pkg-1.0/*.md", Dest: "docs"}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"init.lua":       "pkg-1.0/src/init.lua",
+		"util/str.lua":   "pkg-1.0/src/util/str.lua",
+		"docs/README.md": "pkg-1.0/README.md",
+	}, mapped)
+
+	_, err = Map(paths, []Spec{{Source: "pkg-1.0/*/init.lua", Dest: "."}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "would both be extracted to 'init.lua'")
+
+	_, err = Map(paths, []Spec{{Source: "pkg-1.0/lib/", Dest: "."}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no file in the archive matches 'pkg-1.0/lib/'")
+
+	mapped, err = Map([]string{"a.lua", "lib/b.lua"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a.lua": "a.lua", "lib/b.lua": "lib/b.lua"}, mapped, "without a common top-level directory paths are kept")
+}
+
+func TestExtract(t *testing.T) {
+	content := tarGz(t, map[string]string{"pkg-1.0/src/init.lua": "return {}", "pkg-1.0/README.md": "# pkg"})
+	extracted, err := Extract(TarGz, content, []string{"pkg-1.0/src/=lib"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"lib/init.lua": []byte("return {}")}, extracted)
+}
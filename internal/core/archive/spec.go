@@ -0,0 +1,142 @@
+package archive
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Spec selects files of an archive and where they are extracted, relative to the dependency's
+// directory. It is written "SRC[=DEST]":
+//
+//   - SRC ending in "/" selects every file below that directory of the archive; each is
+//     extracted to DEST followed by its path below SRC.
+//   - Any other SRC is a path.Match pattern over the paths in the archive, such as
+//     "pkg-1.0/src/*.lua" or a plain path; each file it matches is extracted to DEST followed
+//     by its base name.
+//
+// DEST defaults to the dependency's directory itself.
+type Spec struct {
+	Source string
+	Dest   string
+}
+
+// ParseSpec parses a "SRC[=DEST]" extraction spec.
+func ParseSpec(s string) (Spec, error) {
+	src, dest, _ := strings.Cut(s, "=")
+	spec := Spec{Source: strings.TrimPrefix(src, "./"), Dest: path.Clean("./" + dest)}
+	if spec.Source == "" || strings.HasPrefix(spec.Source, "/") {
+		return Spec{}, fmt.Errorf("invalid extract spec '%s': SRC must be a path inside the archive", s)
+	}
+	if !strings.HasSuffix(spec.Source, "/") {
+		if _, err := path.Match(spec.Source, ""); err != nil {
+			return Spec{}, fmt.Errorf("invalid extract spec '%s': %w", s, err)
+		}
+	}
+	if spec.Dest == ".." || strings.HasPrefix(spec.Dest, "../") || path.IsAbs(dest) {
+		return Spec{}, fmt.Errorf("invalid extract spec '%s': DEST must stay inside the dependency's directory", s)
+	}
+	return spec, nil
+}
+
+// ParseSpecs parses each of the "SRC[=DEST]" extraction specs in specs.
+func ParseSpecs(specs []string) ([]Spec, error) {
+	parsed := make([]Spec, 0, len(specs))
+	for _, s := range specs {
+		spec, err := ParseSpec(s)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, spec)
+	}
+	return parsed, nil
+}
+
+// extract returns the path the archive file at p is extracted to, and whether s selects it. An
+// empty Source selects the whole archive.
+func (s Spec) extract(p string) (string, bool) {
+	if dir := s.Source; dir == "" || strings.HasSuffix(dir, "/") {
+		rel, ok := strings.CutPrefix(p, dir)
+		return path.Join(s.Dest, rel), ok
+	}
+	if matched, _ := path.Match(s.Source, p); matched {
+		return path.Join(s.Dest, path.Base(p)), true
+	}
+	return "", false
+}
+
+// Map returns the files of an archive with the given paths that specs select, keyed by the path
+// each is extracted to. Without specs every file is extracted, from below the top-level
+// directory of the archive when all of its files are in one. A spec that selects no file is an
+// error, as are two files extracted to the same path.
+func Map(paths []string, specs []Spec) (map[string]string, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("archive has no files")
+	}
+	sorted := append([]string(nil), paths...)
+	sort.Strings(sorted)
+	if len(specs) == 0 {
+		specs = []Spec{{Source: topLevelDir(sorted), Dest: "."}}
+	}
+	extracted := make(map[string]string)
+	for _, spec := range specs {
+		selected := false
+		for _, p := range sorted {
+			dest, ok := spec.extract(p)
+			if !ok {
+				continue
+			}
+			selected = true
+			if other, taken := extracted[dest]; taken && other != p {
+				return nil, fmt.Errorf("'%s' and '%s' would both be extracted to '%s'", other, p, dest)
+			}
+			extracted[dest] = p
+		}
+		if !selected {
+			return nil, fmt.Errorf("no file in the archive matches '%s'", spec.Source)
+		}
+	}
+	return extracted, nil
+}
+
+// topLevelDir returns the directory all paths are in, with a trailing slash, or "" with the
+// same meaning of the whole archive when they are not all in one directory.
+func topLevelDir(paths []string) string {
+	top, _, nested := strings.Cut(paths[0], "/")
+	if !nested {
+		return ""
+	}
+	for _, p := range paths[1:] {
+		if !strings.HasPrefix(p, top+"/") {
+			return ""
+		}
+	}
+	return top + "/"
+}
+
+// Extract reads an archive and returns the content of each file the "SRC[=DEST]" specs select,
+// keyed by the path it is extracted to; see Map.
+func Extract(format Format, content []byte, specs []string) (map[string][]byte, error) {
+	parsed, err := ParseSpecs(specs)
+	if err != nil {
+		return nil, err
+	}
+	files, err := Read(format, content)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	mapped, err := Map(paths, parsed)
+	if err != nil {
+		return nil, err
+	}
+	extracted := make(map[string][]byte, len(mapped))
+	for dest, p := range mapped {
+		extracted[dest] = files[p]
+	}
+	return extracted, nil
+}
@@ -0,0 +1,231 @@
+// Package attestation records how vendored files entered a project. Every install run that
+// writes files leaves a provenance statement in .almd/attestations, in the in-toto Statement
+// format with a SLSA provenance predicate, so release pipelines can check vendored code against
+// the sources and commits it came from.
+package attestation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nightconcept/almandine/internal/core/hasher"
+)
+
+// Dir is where statements are written, relative to the project root.
+const Dir = ".almd/attestations"
+
+// Identifiers of the statement format.
+const (
+	StatementType = "https://in-toto.io/Statement/v1"
+	PredicateType = "https://slsa.dev/provenance/v1"
+	BuildType     = "https://github.com/nightconcept/almandine/install/v1"
+	BuilderID     = "https://github.com/nightconcept/almandine"
+)
+
+// Material describes one dependency written by a run.
+type Material struct {
+	Name   string // Dependency name
+	Path   string // File in the project, slash separated
+	Source string // URL the content was downloaded from
+	Commit string // Resolved commit SHA, empty if the source is not pinned to one
+	// UpstreamSHA256 is the hex SHA-256 of the content as downloaded, before any transform or
+	// vendor header; empty if unknown.
+	UpstreamSHA256 string
+	// FileSHA256 is the hex SHA-256 of the file as written. When empty, Build hashes the file.
+	FileSHA256 string
+}
+
+// Run describes one install run.
+type Run struct {
+	ToolVersion string
+	StartedOn   time.Time
+	FinishedOn  time.Time
+	Materials   []Material
+}
+
+// Statement is an in-toto statement.
+type Statement struct {
+	Type          string               `json:"_type"`
+	Subject       []ResourceDescriptor `json:"subject"`
+	PredicateType string               `json:"predicateType"`
+	Predicate     Provenance           `json:"predicate"`
+}
+
+// ResourceDescriptor names an artifact and its digests.
+type ResourceDescriptor struct {
+	Name   string            `json:"name,omitempty"`
+	URI    string            `json:"uri,omitempty"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Provenance is the SLSA provenance predicate.
+type Provenance struct {
+	BuildDefinition BuildDefinition `json:"buildDefinition"`
+	RunDetails      RunDetails      `json:"runDetails"`
+}
+
+// BuildDefinition lists what the run was asked to do and what it fetched.
+type BuildDefinition struct {
+	BuildType            string               `json:"buildType"`
+	ExternalParameters   map[string]any       `json:"externalParameters"`
+	ResolvedDependencies []ResourceDescriptor `json:"resolvedDependencies"`
+}
+
+// RunDetails identifies the tool and the time of the run.
+type RunDetails struct {
+	Builder  Builder  `json:"builder"`
+	Metadata Metadata `json:"metadata"`
+}
+
+// Builder identifies almd and its version.
+type Builder struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version"`
+}
+
+// Metadata holds the start and end of the run.
+type Metadata struct {
+	InvocationID string `json:"invocationId"`
+	StartedOn    string `json:"startedOn"`
+	FinishedOn   string `json:"finishedOn"`
+}
+
+// Build assembles the statement for run. The subjects are the files as written, so their
+// digests include vendor headers and transforms; the resolved dependencies carry the digest of
+// the upstream content. Files without a FileSHA256 are hashed from projectRoot.
+func Build(projectRoot string, run Run) (*Statement, error) {
+	names := make([]string, 0, len(run.Materials))
+	subjects := make([]ResourceDescriptor, 0, len(run.Materials))
+	resolved := make([]ResourceDescriptor, 0, len(run.Materials))
+	for _, m := range run.Materials {
+		fileDigest := m.FileSHA256
+		if fileDigest == "" {
+			var err error
+			if fileDigest, err = hashFile(projectRoot, m.Path); err != nil {
+				return nil, err
+			}
+		}
+		subjects = append(subjects, ResourceDescriptor{
+			Name:   m.Path,
+			Digest: map[string]string{"sha256": fileDigest},
+		})
+
+		dependency := ResourceDescriptor{Name: m.Name, URI: m.Source, Digest: map[string]string{}}
+		if m.UpstreamSHA256 != "" {
+			dependency.Digest["sha256"] = m.UpstreamSHA256
+		}
+		if m.Commit != "" {
+			dependency.Digest["gitCommit"] = m.Commit
+		}
+		resolved = append(resolved, dependency)
+		names = append(names, m.Name)
+	}
+
+	version := run.ToolVersion
+	if version == "" {
+		version = "dev"
+	}
+	return &Statement{
+		Type:          StatementType,
+		Subject:       subjects,
+		PredicateType: PredicateType,
+		Predicate: Provenance{
+			BuildDefinition: BuildDefinition{
+				BuildType: BuildType,
+				ExternalParameters: map[string]any{
+					"command":      "install",
+					"dependencies": names,
+					"invokedBy":    invoker(),
+				},
+				ResolvedDependencies: resolved,
+			},
+			RunDetails: RunDetails{
+				Builder: Builder{ID: BuilderID, Version: map[string]string{"almd": version}},
+				Metadata: Metadata{
+					InvocationID: invocationID(run.StartedOn),
+					StartedOn:    run.StartedOn.UTC().Format(time.RFC3339),
+					FinishedOn:   run.FinishedOn.UTC().Format(time.RFC3339),
+				},
+			},
+		},
+	}, nil
+}
+
+// Write builds the statement for run and saves it in Dir below projectRoot. It returns the path
+// of the new file, relative to projectRoot.
+func Write(projectRoot string, run Run) (string, error) {
+	statement, err := Build(projectRoot, run)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(statement, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(projectRoot, filepath.FromSlash(Dir))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("creating %s: %w", Dir, err)
+	}
+
+	// Runs within the same second get a numeric suffix instead of replacing each other.
+	base := invocationID(run.StartedOn)
+	for i := 0; ; i++ {
+		name := base + ".intoto.json"
+		if i > 0 {
+			name = fmt.Sprintf("%s-%d.intoto.json", base, i)
+		}
+		f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("writing attestation: %w", err)
+		}
+		_, writeErr := f.Write(append(data, '\n'))
+		if closeErr := f.Close(); writeErr == nil {
+			writeErr = closeErr
+		}
+		if writeErr != nil {
+			return "", fmt.Errorf("writing attestation: %w", writeErr)
+		}
+		return Dir + "/" + name, nil
+	}
+}
+
+// hashFile returns the hex SHA-256 of the file at path below projectRoot.
+func hashFile(projectRoot, path string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(projectRoot, filepath.FromSlash(path)))
+	if err != nil {
+		return "", fmt.Errorf("hashing '%s': %w", path, err)
+	}
+	fileHash, err := hasher.CalculateSHA256(content)
+	if err != nil {
+		return "", fmt.Errorf("hashing '%s': %w", path, err)
+	}
+	return strings.TrimPrefix(fileHash, "sha256:"), nil
+}
+
+// invocationID names a run after the time it started.
+func invocationID(started time.Time) string {
+	return "install-" + started.UTC().Format("20060102T150405Z")
+}
+
+// invoker returns the name of the user running almd, or "unknown".
+func invoker() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	for _, env := range []string{"USER", "USERNAME"} {
+		if name := os.Getenv(env); name != "" {
+			return name
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,66 @@
+package attestation
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "libs"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "libs", "a.lua"), []byte("return 1"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "libs", "b.lua"), []byte("return 2"), 0644))
+
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	run := Run{
+		ToolVersion: "1.2.3",
+		StartedOn:   started,
+		FinishedOn:  started.Add(2 * time.Second),
+		Materials: []Material{
+			{Name: "a", Path: "libs/a.lua", Source: "https://raw.githubusercontent.com/o/r/abc1234/a.lua", Commit: "abc1234"},
+			{Name: "b", Path: "libs/b.lua", Source: "https://example.com/b.lua", UpstreamSHA256: "upstream", FileSHA256: "written"},
+		},
+	}
+
+	path, err := Write(root, run)
+	require.NoError(t, err)
+	assert.Equal(t, ".almd/attestations/install-20240501T120000Z.intoto.json", path)
+
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(path)))
+	require.NoError(t, err)
+	var statement Statement
+	require.NoError(t, json.Unmarshal(data, &statement))
+
+	assert.Equal(t, StatementType, statement.Type)
+	assert.Equal(t, PredicateType, statement.PredicateType)
+	require.Len(t, statement.Subject, 2)
+	assert.Equal(t, "libs/a.lua", statement.Subject[0].Name)
+	assert.Len(t, statement.Subject[0].Digest["sha256"], 64)
+	assert.Equal(t, "written", statement.Subject[1].Digest["sha256"], "a given file digest is used as is")
+
+	resolved := statement.Predicate.BuildDefinition.ResolvedDependencies
+	require.Len(t, resolved, 2)
+	assert.Equal(t, map[string]string{"gitCommit": "abc1234"}, resolved[0].Digest)
+	assert.Equal(t, map[string]string{"sha256": "upstream"}, resolved[1].Digest)
+	assert.Equal(t, "https://example.com/b.lua", resolved[1].URI)
+	assert.Equal(t, "1.2.3", statement.Predicate.RunDetails.Builder.Version["almd"])
+	assert.Equal(t, "2024-05-01T12:00:02Z", statement.Predicate.RunDetails.Metadata.FinishedOn)
+	assert.NotEmpty(t, statement.Predicate.BuildDefinition.ExternalParameters["invokedBy"])
+
+	// A second run in the same second does not replace the first statement.
+	second, err := Write(root, run)
+	require.NoError(t, err)
+	assert.Equal(t, ".almd/attestations/install-20240501T120000Z-1.intoto.json", second)
+}
+
+func TestBuild_MissingFile(t *testing.T) {
+	_, err := Build(t.TempDir(), Run{Materials: []Material{{Name: "a", Path: "a.lua"}}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "hashing 'a.lua'")
+}
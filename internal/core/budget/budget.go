@@ -0,0 +1,80 @@
+// Package budget checks the vendored dependency files against the project's [budget]: a maximum
+// size for all of them together and for each one.
+package budget
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nightconcept/almandine/internal/core/bytesize"
+	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/warnings"
+)
+
+// Check measures the files of deps under projectRoot and returns one message per exceeded limit
+// of b, files first in name order. Files that do not exist are not counted.
+func Check(projectRoot string, b *project.Budget, deps map[string]project.Dependency) ([]string, error) {
+	maxTotal, maxFile, err := b.Limits()
+	if err != nil || (maxTotal == 0 && maxFile == 0) {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var violations []string
+	var total int64
+	for _, name := range names {
+		dep := deps[name]
+		for _, file := range dep.FilePaths() {
+			info, err := os.Stat(filepath.Join(projectRoot, filepath.FromSlash(file)))
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			total += info.Size()
+			if maxFile > 0 && info.Size() > maxFile {
+				label := name
+				if dep.IsDirectory() {
+					label = fmt.Sprintf("%s (%s)", name, file)
+				}
+				violations = append(violations, fmt.Sprintf("'%s' is %s, over max_file_size %s",
+					label, bytesize.Format(info.Size()), b.MaxFileSize))
+			}
+		}
+	}
+	if maxTotal > 0 && total > maxTotal {
+		violations = append(violations, fmt.Sprintf("dependency files total %s, over max_total_size %s",
+			bytesize.Format(total), b.MaxTotalSize))
+	}
+	return violations, nil
+}
+
+// Enforce runs Check and reports what it finds: as warnings when b.WarnOnly is set, and as the
+// returned error otherwise.
+func Enforce(projectRoot string, b *project.Budget, deps map[string]project.Dependency) error {
+	violations, err := Check(projectRoot, b, deps)
+	if err != nil {
+		return fmt.Errorf("checking the size budget: %w", err)
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	if b.WarnOnly {
+		for _, v := range violations {
+			warnings.Printf("over budget: %s", v)
+		}
+		return nil
+	}
+	return fmt.Errorf("over budget: %s (set warn_only = true under [budget] to only warn)", strings.Join(violations, "; "))
+}
@@ -0,0 +1,42 @@
+package budget
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/project"
+)
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "small.lua"), []byte(strings.Repeat("x", 100)), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "big.lua"), []byte(strings.Repeat("x", 900)), 0644))
+	deps := map[string]project.Dependency{
+		"small":   {Path: "small.lua"},
+		"big":     {Path: "big.lua"},
+		"missing": {Path: "missing.lua"},
+	}
+
+	violations, err := Check(dir, nil, deps)
+	require.NoError(t, err)
+	assert.Empty(t, violations, "no budget, no limits")
+
+	violations, err = Check(dir, &project.Budget{MaxTotalSize: "2KB", MaxFileSize: "1KB"}, deps)
+	require.NoError(t, err)
+	assert.Empty(t, violations)
+
+	violations, err = Check(dir, &project.Budget{MaxTotalSize: "0.5KB", MaxFileSize: "500B"}, deps)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"'big' is 900 B, over max_file_size 500B",
+		"dependency files total 1000 B, over max_total_size 0.5KB",
+	}, violations)
+
+	_, err = Check(dir, &project.Budget{MaxFileSize: "lots"}, deps)
+	assert.Error(t, err)
+}
@@ -0,0 +1,49 @@
+// Package bytesize parses and formats human-readable byte counts such as "200KB" or "1.5 MiB".
+package bytesize
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse parses sizes such as "1024", "200KB", "50MiB" or "1GB". Decimal (KB, MB, GB) and
+// binary (KiB, MiB, GiB) suffixes are both accepted.
+func Parse(raw string) (int64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(raw))
+	multipliers := []struct {
+		suffix string
+		factor int64
+	}{
+		{"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10},
+		{"GB", 1000 * 1000 * 1000}, {"MB", 1000 * 1000}, {"KB", 1000},
+		{"B", 1},
+	}
+	factor := int64(1)
+	for _, m := range multipliers {
+		if strings.HasSuffix(upper, m.suffix) {
+			factor = m.factor
+			upper = strings.TrimSpace(strings.TrimSuffix(upper, m.suffix))
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(upper, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size '%s' (use e.g. 200MB or 1GiB)", raw)
+	}
+	return int64(n * float64(factor)), nil
+}
+
+// Format renders a byte count with a binary unit.
+func Format(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
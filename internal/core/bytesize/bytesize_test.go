@@ -0,0 +1,31 @@
+package bytesize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string]int64{
+		"1024":   1024,
+		"200KB":  200_000,
+		"1.5MiB": 1_572_864,
+		"1gb":    1_000_000_000,
+		"10 B":   10,
+	}
+	for raw, want := range cases {
+		got, err := Parse(raw)
+		require.NoError(t, err, raw)
+		assert.Equal(t, want, got, raw)
+	}
+	_, err := Parse("lots")
+	assert.Error(t, err)
+}
+
+func TestFormat(t *testing.T) {
+	assert.Equal(t, "512 B", Format(512))
+	assert.Equal(t, "1.5 KiB", Format(1536))
+	assert.Equal(t, "2.0 MiB", Format(2<<20))
+}
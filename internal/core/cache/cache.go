@@ -0,0 +1,375 @@
+// Package cache implements almd's global content-addressed download store.
+//
+// Downloaded files are stored once per content hash under the cache directory, together with
+// a small metadata file recording the URLs they were fetched from. Immutable sources (for
+// example raw URLs pinned to a commit SHA) are additionally indexed by URL so later installs
+// can skip the network. A blob's modification time doubles as its last-used time and is
+// refreshed whenever the blob is read.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nightconcept/almandine/internal/core/httpclient"
+	"github.com/nightconcept/almandine/internal/core/paths"
+)
+
+// storeVersion is bumped whenever the on-disk layout changes incompatibly.
+const storeVersion = "v1"
+
+// Store is a content-addressed file store rooted in a directory.
+type Store struct {
+	root string
+}
+
+// Entry describes one cached blob.
+type Entry struct {
+	Hash     string    // "sha256:<hex>"
+	Size     int64     // Size of the blob in bytes
+	LastUsed time.Time // Last time the blob was written or read
+	Sources  []string  // URLs the content was downloaded from, with credentials redacted
+}
+
+// metadata is the JSON sidecar stored next to each blob.
+type metadata struct {
+	Sources []string  `json:"sources"`
+	Added   time.Time `json:"added"`
+}
+
+// Open returns the store in the user's cache directory (see the paths package).
+func Open() (*Store, error) {
+	dir, err := paths.CacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("determining cache directory: %w", err)
+	}
+	return New(dir), nil
+}
+
+// New returns a store rooted at dir. Nothing is created until content is written.
+func New(dir string) *Store {
+	return &Store{root: filepath.Join(dir, "store", storeVersion)}
+}
+
+// Root returns the directory holding the store.
+func (s *Store) Root() string {
+	return s.root
+}
+
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// splitHash validates a "sha256:<hex>" (or bare hex) hash and returns the hex digest.
+func splitHash(hash string) (string, error) {
+	digest := strings.TrimPrefix(hash, "sha256:")
+	if len(digest) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid cache hash '%s'", hash)
+	}
+	if _, err := hex.DecodeString(digest); err != nil {
+		return "", fmt.Errorf("invalid cache hash '%s': %w", hash, err)
+	}
+	return digest, nil
+}
+
+func (s *Store) blobPath(digest string) string {
+	return filepath.Join(s.root, "objects", digest[:2], digest)
+}
+
+// BlobPath returns the path of the content with hash ("sha256:<hex>") in the store, which
+// dependencies installed in link mode point at. The content is not necessarily present.
+func (s *Store) BlobPath(hash string) (string, error) {
+	digest, err := splitHash(hash)
+	if err != nil {
+		return "", err
+	}
+	return s.blobPath(digest), nil
+}
+
+func (s *Store) metaPath(digest string) string {
+	return s.blobPath(digest) + ".json"
+}
+
+func (s *Store) urlIndexPath(url string) string {
+	return filepath.Join(s.root, "urls", hashContent([]byte(url)))
+}
+
+// writeFileAtomic writes data via a temporary file and rename so readers never observe partial content.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
+
+// Put stores content downloaded from sourceURL and returns its "sha256:<hex>" hash.
+// When immutable is true the URL is indexed so LookupURL can serve it later.
+func (s *Store) Put(sourceURL string, content []byte, immutable bool) (string, error) {
+	digest := hashContent(content)
+	blob := s.blobPath(digest)
+
+	if _, err := os.Stat(blob); errors.Is(err, os.ErrNotExist) {
+		if err := writeFileAtomic(blob, content); err != nil {
+			return "", fmt.Errorf("writing cache blob: %w", err)
+		}
+	} else if err != nil {
+		return "", fmt.Errorf("checking cache blob: %w", err)
+	} else {
+		s.touch(blob)
+	}
+
+	meta := s.readMeta(digest)
+	if meta.Added.IsZero() {
+		meta.Added = time.Now()
+	}
+	// Raw links to private repositories carry a token; it must not end up in 'almd cache ls'.
+	if listed := httpclient.RedactURL(sourceURL); sourceURL != "" && !containsString(meta.Sources, listed) {
+		meta.Sources = append(meta.Sources, listed)
+	}
+	metaBytes, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encoding cache metadata: %w", err)
+	}
+	if err := writeFileAtomic(s.metaPath(digest), metaBytes); err != nil {
+		return "", fmt.Errorf("writing cache metadata: %w", err)
+	}
+
+	if immutable && sourceURL != "" {
+		if err := writeFileAtomic(s.urlIndexPath(sourceURL), []byte("sha256:"+digest)); err != nil {
+			return "", fmt.Errorf("writing cache URL index: %w", err)
+		}
+	}
+	return "sha256:" + digest, nil
+}
+
+// Get returns the content for hash if it is cached and intact, refreshing its last-used time.
+func (s *Store) Get(hash string) ([]byte, bool) {
+	digest, err := splitHash(hash)
+	if err != nil {
+		return nil, false
+	}
+	blob := s.blobPath(digest)
+	content, err := os.ReadFile(blob)
+	if err != nil || hashContent(content) != digest {
+		return nil, false
+	}
+	s.touch(blob)
+	return content, true
+}
+
+// LookupURL returns cached content previously stored for an immutable URL.
+func (s *Store) LookupURL(sourceURL string) ([]byte, bool) {
+	hash, err := os.ReadFile(s.urlIndexPath(sourceURL))
+	if err != nil {
+		return nil, false
+	}
+	return s.Get(strings.TrimSpace(string(hash)))
+}
+
+func (s *Store) touch(path string) {
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+}
+
+func (s *Store) readMeta(digest string) metadata {
+	var meta metadata
+	data, err := os.ReadFile(s.metaPath(digest))
+	if err != nil {
+		return meta
+	}
+	_ = json.Unmarshal(data, &meta)
+	return meta
+}
+
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns every cached blob, most recently used first.
+func (s *Store) List() ([]Entry, error) {
+	objectsDir := filepath.Join(s.root, "objects")
+	var entries []Entry
+	err := filepath.WalkDir(objectsDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(d.Name(), ".json") || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entries = append(entries, Entry{
+			Hash:     "sha256:" + d.Name(),
+			Size:     info.Size(),
+			LastUsed: info.ModTime(),
+			Sources:  s.readMeta(d.Name()).Sources,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing cache: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].LastUsed.After(entries[j].LastUsed) })
+	return entries, nil
+}
+
+// Remove deletes a blob and its metadata. URL index entries pointing at it become stale and
+// are ignored by LookupURL until they are overwritten or cleaned.
+func (s *Store) Remove(hash string) error {
+	digest, err := splitHash(hash)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(s.blobPath(digest)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing cache blob %s: %w", hash, err)
+	}
+	if err := os.Remove(s.metaPath(digest)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing cache metadata %s: %w", hash, err)
+	}
+	return nil
+}
+
+// CleanOptions selects which entries Clean removes. Entries matching either criterion are removed.
+type CleanOptions struct {
+	All       bool          // Remove everything
+	OlderThan time.Duration // Remove entries unused for longer than this (0 disables)
+	MaxSize   int64         // Evict least recently used entries until the total fits (0 disables)
+	DryRun    bool          // Report what would be removed without deleting anything
+}
+
+// Clean garbage-collects the store and returns the removed entries and the bytes freed.
+func (s *Store) Clean(opts CleanOptions) ([]Entry, int64, error) {
+	entries, err := s.List()
+	if err != nil {
+		return nil, 0, err
+	}
+	remove := selectForRemoval(entries, opts)
+
+	var removed []Entry
+	var freed int64
+	for i, e := range entries {
+		if !remove[i] {
+			continue
+		}
+		if !opts.DryRun {
+			if err := s.Remove(e.Hash); err != nil {
+				return removed, freed, err
+			}
+		}
+		removed = append(removed, e)
+		freed += e.Size
+	}
+
+	if opts.All && !opts.DryRun {
+		if err := os.RemoveAll(filepath.Join(s.root, "urls")); err != nil {
+			return removed, freed, fmt.Errorf("removing cache URL index: %w", err)
+		}
+	}
+	return removed, freed, nil
+}
+
+// selectForRemoval marks the entries Clean should remove. Entries must be sorted most recently
+// used first, as returned by List.
+func selectForRemoval(entries []Entry, opts CleanOptions) []bool {
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+
+	cutoff := time.Now().Add(-opts.OlderThan)
+	remove := make([]bool, len(entries))
+	for i, e := range entries {
+		if opts.All || (opts.OlderThan > 0 && e.LastUsed.Before(cutoff)) {
+			remove[i] = true
+			total -= e.Size
+		}
+	}
+	// Evict from the least recently used end until the remaining entries fit the budget.
+	for i := len(entries) - 1; opts.MaxSize > 0 && total > opts.MaxSize && i >= 0; i-- {
+		if !remove[i] {
+			remove[i] = true
+			total -= entries[i].Size
+		}
+	}
+	return remove
+}
+
+// Verify re-hashes every blob and returns the entries whose content no longer matches their hash.
+// With fix set, corrupt entries are removed.
+func (s *Store) Verify(fix bool) ([]Entry, error) {
+	entries, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var corrupt []Entry
+	for _, e := range entries {
+		digest, _ := splitHash(e.Hash)
+		content, readErr := os.ReadFile(s.blobPath(digest))
+		if readErr == nil && hashContent(content) == digest {
+			continue
+		}
+		corrupt = append(corrupt, e)
+		if fix {
+			if err := s.Remove(e.Hash); err != nil {
+				return corrupt, err
+			}
+		}
+	}
+	return corrupt, nil
+}
+
+// Fetch returns content for sourceURL, serving immutable URLs from the store when possible and
+// otherwise calling download and recording the result. A store that cannot be written to does
+// not fail the fetch; the freshly downloaded content is returned regardless.
+func (s *Store) Fetch(sourceURL string, immutable bool, download func(string) ([]byte, error)) (content []byte, fromCache bool, err error) {
+	if immutable {
+		content, ok := s.LookupURL(sourceURL)
+		httpclient.TraceCache(sourceURL, ok)
+		if ok {
+			return content, true, nil
+		}
+	}
+	content, err = download(sourceURL)
+	if err != nil {
+		return nil, false, err
+	}
+	_, _ = s.Put(sourceURL, content, immutable)
+	return content, false, nil
+}
@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutGetAndLookup(t *testing.T) {
+	store := New(t.TempDir())
+
+	hash, err := store.Put("https://example.com/a.lua", []byte("return 1"), true)
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:"+hashContent([]byte("return 1")), hash)
+
+	content, ok := store.Get(hash)
+	require.True(t, ok)
+	assert.Equal(t, "return 1", string(content))
+
+	content, ok = store.LookupURL("https://example.com/a.lua")
+	require.True(t, ok)
+	assert.Equal(t, "return 1", string(content))
+
+	_, err = store.Put("https://example.com/mutable.lua", []byte("return 2"), false)
+	require.NoError(t, err)
+	_, ok = store.LookupURL("https://example.com/mutable.lua")
+	assert.False(t, ok, "mutable URLs must not be served from the URL index")
+
+	_, err = store.Put("https://mirror.example.com/a.lua", []byte("return 1"), false)
+	require.NoError(t, err)
+	entries, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	for _, e := range entries {
+		if e.Hash == hash {
+			assert.Equal(t, []string{"https://example.com/a.lua", "https://mirror.example.com/a.lua"}, e.Sources)
+		}
+	}
+}
+
+func TestPut_RedactsCredentialsInSources(t *testing.T) {
+	store := New(t.TempDir())
+	privateURL := "https://raw.githubusercontent.com/o/r/0123456789abcdef0123456789abcdef01234567/a.lua?token=GHSAT0SECRET"
+
+	_, err := store.Put(privateURL, []byte("return 1"), true)
+	require.NoError(t, err)
+	content, ok := store.LookupURL(privateURL)
+	require.True(t, ok, "the URL index still answers for the URL that was downloaded")
+	assert.Equal(t, "return 1", string(content))
+
+	entries, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, []string{"https://raw.githubusercontent.com/o/r/0123456789abcdef0123456789abcdef01234567/a.lua?token=REDACTED"}, entries[0].Sources)
+}
+
+func TestFetch(t *testing.T) {
+	store := New(t.TempDir())
+	calls := 0
+	download := func(string) ([]byte, error) {
+		calls++
+		return []byte("content"), nil
+	}
+
+	_, fromCache, err := store.Fetch("https://example.com/pinned.lua", true, download)
+	require.NoError(t, err)
+	assert.False(t, fromCache)
+	content, fromCache, err := store.Fetch("https://example.com/pinned.lua", true, download)
+	require.NoError(t, err)
+	assert.True(t, fromCache)
+	assert.Equal(t, "content", string(content))
+	assert.Equal(t, 1, calls, "immutable URLs should only be downloaded once")
+
+	_, _, err = store.Fetch("https://example.com/branch.lua", false, download)
+	require.NoError(t, err)
+	_, _, err = store.Fetch("https://example.com/branch.lua", false, download)
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls, "mutable URLs should always be downloaded")
+
+	_, _, err = store.Fetch("https://example.com/broken.lua", true, func(string) ([]byte, error) { return nil, errors.New("boom") })
+	assert.EqualError(t, err, "boom")
+}
+
+func TestClean(t *testing.T) {
+	store := New(t.TempDir())
+	oldHash, err := store.Put("old", []byte("old content"), false)
+	require.NoError(t, err)
+	newHash, err := store.Put("new", []byte("new content, a bit longer"), false)
+	require.NoError(t, err)
+
+	oldDigest, _ := splitHash(oldHash)
+	past := time.Now().Add(-60 * 24 * time.Hour)
+	require.NoError(t, os.Chtimes(store.blobPath(oldDigest), past, past))
+
+	removed, _, err := store.Clean(CleanOptions{OlderThan: 30 * 24 * time.Hour, DryRun: true})
+	require.NoError(t, err)
+	require.Len(t, removed, 1)
+	_, statErr := os.Stat(store.blobPath(oldDigest))
+	assert.NoError(t, statErr, "dry run must not delete anything")
+
+	removed, freed, err := store.Clean(CleanOptions{OlderThan: 30 * 24 * time.Hour})
+	require.NoError(t, err)
+	require.Len(t, removed, 1)
+	assert.Equal(t, oldHash, removed[0].Hash)
+	assert.Equal(t, int64(len("old content")), freed)
+
+	_, err = store.Put("third", []byte("third"), false)
+	require.NoError(t, err)
+	removed, _, err = store.Clean(CleanOptions{MaxSize: int64(len("third"))})
+	require.NoError(t, err)
+	require.Len(t, removed, 1, "least recently used entry should be evicted to fit the budget")
+	assert.Equal(t, newHash, removed[0].Hash)
+
+	removed, _, err = store.Clean(CleanOptions{All: true})
+	require.NoError(t, err)
+	assert.Len(t, removed, 1)
+	entries, err := store.List()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestVerify(t *testing.T) {
+	store := New(t.TempDir())
+	goodHash, err := store.Put("good", []byte("good"), false)
+	require.NoError(t, err)
+	badHash, err := store.Put("bad", []byte("bad"), true)
+	require.NoError(t, err)
+
+	badDigest, _ := splitHash(badHash)
+	require.NoError(t, os.WriteFile(store.blobPath(badDigest), []byte("tampered"), 0644))
+
+	_, ok := store.LookupURL("bad")
+	assert.False(t, ok, "corrupted content must never be served")
+
+	corrupt, err := store.Verify(false)
+	require.NoError(t, err)
+	require.Len(t, corrupt, 1)
+	assert.Equal(t, badHash, corrupt[0].Hash)
+
+	corrupt, err = store.Verify(true)
+	require.NoError(t, err)
+	assert.Len(t, corrupt, 1)
+	entries, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, goodHash, entries[0].Hash)
+}
+
+func TestEmptyStore(t *testing.T) {
+	store := New(t.TempDir())
+	entries, err := store.List()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	_, ok := store.Get("sha256:not-a-hash")
+	assert.False(t, ok)
+}
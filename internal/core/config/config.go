@@ -2,19 +2,70 @@ package config
 
 import (
 	"bytes"
+	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/BurntSushi/toml"
 	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/warnings"
 )
 
 const ProjectTomlName = "project.toml"
 const LockfileName = "almd-lock.toml"
 
-// LoadProjectToml reads the project.toml file from the given dirPath and unmarshals it.
+// AlmdTomlName is the alternative manifest name for repositories whose project.toml belongs to
+// another tool. A project that has one uses it instead of project.toml.
+const AlmdTomlName = "almd.toml"
+
+// ManifestEnv sets the manifest path like the global --manifest flag.
+const ManifestEnv = "ALMD_MANIFEST"
+
+// manifestOverride is the manifest path set with --manifest, "" to discover it.
+var manifestOverride string
+
+// SetManifest makes every command use path as the manifest, relative to the project directory
+// unless it is absolute. An empty path restores discovery.
+func SetManifest(path string) {
+	manifestOverride = path
+}
+
+// ManifestPath returns the manifest of the project in dir: the --manifest path if one is set,
+// otherwise almd.toml if it exists, otherwise project.toml.
+func ManifestPath(dir string) string {
+	if manifestOverride != "" {
+		if filepath.IsAbs(manifestOverride) {
+			return manifestOverride
+		}
+		return filepath.Join(dir, manifestOverride)
+	}
+	if info, err := os.Stat(filepath.Join(dir, AlmdTomlName)); err == nil && !info.IsDir() {
+		return filepath.Join(dir, AlmdTomlName)
+	}
+	return filepath.Join(dir, ProjectTomlName)
+}
+
+// ManifestName returns the file name of the current directory's manifest, for messages.
+func ManifestName() string {
+	return filepath.Base(ManifestPath("."))
+}
+
+// HasManifest reports whether dir contains a project manifest.
+func HasManifest(dir string) bool {
+	info, err := os.Stat(ManifestPath(dir))
+	return err == nil && !info.IsDir()
+}
+
+// LoadProjectToml reads the manifest (see ManifestPath) from the given dirPath and unmarshals it.
+// Dependency names that break project.ValidateDependencyName (case aside) are reported as
+// warnings with a suggested rename rather than failing the load, and ${VAR} references in
+// sources and header values are expanded from the environment (see project.ExpandSources and
+// project.ExpandHeaders). The project's [registries] and [providers] tables take effect for the
+// rest of the command.
 func LoadProjectToml(dirPath string) (*project.Project, error) {
-	fullPath := filepath.Join(dirPath, ProjectTomlName)
+	fullPath := ManifestPath(dirPath)
 	data, err := os.ReadFile(fullPath)
 	if err != nil {
 		return nil, err
@@ -24,18 +75,56 @@ func LoadProjectToml(dirPath string) (*project.Project, error) {
 	if err := toml.Unmarshal(data, &proj); err != nil {
 		return nil, err
 	}
+	warnDependencyNames(fullPath, &proj)
+	for name, dep := range proj.Dependencies {
+		if err := project.ValidateLabels(name, dep.Labels); err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(fullPath), err)
+		}
+		if err := project.ValidateReviewAfter(name, dep.ReviewAfter); err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(fullPath), err)
+		}
+		if err := project.ValidateDirectory(name, dep); err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(fullPath), err)
+		}
+	}
+	if err := project.ValidateScripts(proj.Scripts); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(fullPath), err)
+	}
+	for name, dep := range proj.Dependencies {
+		if err := project.ValidateInstall(name, dep, proj.Scripts); err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(fullPath), err)
+		}
+	}
+	if _, _, err := proj.Budget.Limits(); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(fullPath), err)
+	}
+	if err := proj.ExpandSources(os.LookupEnv); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(fullPath), err)
+	}
+	if err := proj.ExpandHeaders(os.LookupEnv); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(fullPath), err)
+	}
+	if err := useSourceSettings(&proj); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(fullPath), err)
+	}
 	return &proj, nil
 }
 
-// WriteProjectToml marshals the Project data and writes it to the specified dirPath.
-// It will overwrite the file if it already exists.
+// WriteProjectToml marshals the Project data and writes it to the manifest in dirPath.
+// It will overwrite the file if it already exists. Dependency names must follow
+// project.ValidateDependencyNames; names kept from a loaded manifest are written back as they
+// are. Sources and headers loaded from a ${VAR} template are written back as the template unless
+// they were changed since.
 func WriteProjectToml(dirPath string, data *project.Project) error {
+	if err := project.ValidateDependencyNames(data.Dependencies); err != nil {
+		return err
+	}
 	buf := new(bytes.Buffer)
-	if err := toml.NewEncoder(buf).Encode(data); err != nil {
+	if err := toml.NewEncoder(buf).Encode(withSourceTemplates(data)); err != nil {
 		return err
 	}
 
-	fullPath := filepath.Join(dirPath, ProjectTomlName)
+	fullPath := ManifestPath(dirPath)
 	file, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
 		return err
@@ -45,3 +134,53 @@ func WriteProjectToml(dirPath string, data *project.Project) error {
 	_, err = file.Write(buf.Bytes())
 	return err
 }
+
+// warnedNames remembers the manifest name problems already reported, so a command that loads
+// the manifest several times warns once.
+var (
+	warnedNamesMu sync.Mutex
+	warnedNames   = make(map[string]bool)
+)
+
+// warnDependencyNames reports the dependency names of proj that break the naming rules and marks
+// them LegacyName, so the manifest can still be written back.
+func warnDependencyNames(fullPath string, proj *project.Project) {
+	for _, problem := range project.CheckDependencyNames(proj.Dependencies) {
+		for _, name := range problem.Names {
+			dep := proj.Dependencies[name]
+			dep.LegacyName = true
+			proj.Dependencies[name] = dep
+		}
+		message := fmt.Sprintf("%s: %v", filepath.Base(fullPath), problem.Err)
+		warnedNamesMu.Lock()
+		seen := warnedNames[fullPath+"\x00"+message]
+		warnedNames[fullPath+"\x00"+message] = true
+		warnedNamesMu.Unlock()
+		if !seen {
+			warnings.Printf("%s", message)
+		}
+	}
+}
+
+// withSourceTemplates returns data with each expanded source replaced by its template again.
+func withSourceTemplates(data *project.Project) *project.Project {
+	restored := *data
+	restored.Dependencies = make(map[string]project.Dependency, len(data.Dependencies))
+	for name, dep := range data.Dependencies {
+		if dep.SourceTemplate != "" {
+			if expanded, err := data.ExpandSource(dep.SourceTemplate, os.LookupEnv); err == nil && expanded == dep.Source {
+				dep.Source = dep.SourceTemplate
+			}
+		}
+		if dep.HeaderTemplates != nil {
+			if expanded, err := project.ExpandHeaderValues(dep.HeaderTemplates, os.LookupEnv, false); err == nil && maps.Equal(expanded, dep.Headers) {
+				dep.Headers = dep.HeaderTemplates
+			}
+		}
+		restored.Dependencies[name] = dep
+	}
+	if data.Dependencies == nil {
+		restored.Dependencies = nil
+	}
+	return &restored
+}
@@ -1,14 +1,20 @@
 package config
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/nightconcept/almandine/internal/core/downloader"
 	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/source"
+	"github.com/nightconcept/almandine/internal/core/warnings"
 )
 
 func TestLoadProjectToml_Valid(t *testing.T) {
@@ -38,7 +44,7 @@ testdep = { source = "github.com/user/repo/file.lua", path = "libs/testdep.lua"
 	assert.Equal(t, "0.1.0", proj.Package.Version)
 	assert.Equal(t, "MIT", proj.Package.License)
 	assert.Equal(t, "A test project", proj.Package.Description)
-	assert.Equal(t, "go run main.go", proj.Scripts["start"])
+	assert.Equal(t, "go run main.go", proj.Scripts["start"].Cmd)
 	assert.NotNil(t, proj.Dependencies["testdep"])
 	assert.Equal(t, "github.com/user/repo/file.lua", proj.Dependencies["testdep"].Source)
 	assert.Equal(t, "libs/testdep.lua", proj.Dependencies["testdep"].Path)
@@ -66,6 +72,47 @@ version = "0.1.0"
 	assert.Error(t, err)
 }
 
+func TestLoadProjectToml_InvalidDependencyName(t *testing.T) {
+	for content, want := range map[string]string{
+		"[dependencies]\n\"json.min\" = { source = \"s\", path = \"p\" }\n":                                "must start with a letter or digit; rename it, for example to 'json-min'",
+		"[dependencies]\nLib = { source = \"s\", path = \"p\" }\nlib = { source = \"s\", path = \"p\" }\n": "'Lib' and 'lib' differ only in case",
+		"[dependencies]\nnul = { source = \"s\", path = \"p\" }\n":                                         "reserved Windows device name 'NUL'",
+	} {
+		warnings.Reset()
+		tempDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, ProjectTomlName), []byte(content), 0644))
+		proj, err := LoadProjectToml(tempDir)
+		require.NoError(t, err, content)
+		reported := warnings.Reported()
+		require.Len(t, reported, 1, content)
+		assert.Contains(t, reported[0], want)
+
+		// The manifest can still be written back, and loading it again does not warn twice.
+		require.NoError(t, WriteProjectToml(tempDir, proj), content)
+		_, err = LoadProjectToml(tempDir)
+		require.NoError(t, err)
+		assert.Len(t, warnings.Reported(), 1, content)
+	}
+	warnings.Reset()
+}
+
+func TestWriteProjectToml_RejectsNewInvalidDependencyName(t *testing.T) {
+	tempDir := t.TempDir()
+	err := WriteProjectToml(tempDir, &project.Project{Dependencies: map[string]project.Dependency{
+		"json.min": {Source: "s", Path: "p"},
+	}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rename it, for example to 'json-min'")
+
+	err = WriteProjectToml(tempDir, &project.Project{Dependencies: map[string]project.Dependency{
+		"Lib": {Source: "s", Path: "p", LegacyName: true},
+		"lib": {Source: "s", Path: "p"},
+	}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "differ only in case")
+	assert.NoFileExists(t, filepath.Join(tempDir, ProjectTomlName))
+}
+
 func TestWriteProjectToml_NewFile(t *testing.T) {
 	tempDir := t.TempDir()
 	projData := &project.Project{
@@ -75,8 +122,9 @@ func TestWriteProjectToml_NewFile(t *testing.T) {
 			License:     "Apache-2.0",
 			Description: "A brand new project",
 		},
-		Scripts: map[string]string{
-			"build": "go build .",
+		Scripts: map[string]project.Script{
+			"build": {Cmd: "go build ."},
+			"test":  {Cmd: "go test ./...", DependsOn: []string{"build"}},
 		},
 		Dependencies: map[string]project.Dependency{
 			"dep1": {Source: "github.com/org/dep1/mod.lua", Path: "vendor/dep1.lua"},
@@ -94,7 +142,7 @@ func TestWriteProjectToml_NewFile(t *testing.T) {
 	assert.Equal(t, "1.0.0", loadedProj.Package.Version)
 	assert.Equal(t, "Apache-2.0", loadedProj.Package.License)
 	assert.Equal(t, "A brand new project", loadedProj.Package.Description)
-	assert.Equal(t, "go build .", loadedProj.Scripts["build"])
+	assert.Equal(t, projData.Scripts, loadedProj.Scripts, "scripts with dependencies round-trip as inline tables")
 	assert.NotNil(t, loadedProj.Dependencies["dep1"])
 	assert.Equal(t, "github.com/org/dep1/mod.lua", loadedProj.Dependencies["dep1"].Source)
 	assert.Equal(t, "vendor/dep1.lua", loadedProj.Dependencies["dep1"].Path)
@@ -130,3 +178,170 @@ version = "0.0.1"
 	assert.Nil(t, loadedProj.Scripts)
 	assert.Nil(t, loadedProj.Dependencies)
 }
+
+func TestLoadProjectToml_ExpandsSourceEnv(t *testing.T) {
+	t.Setenv("ALMD_TEST_MIRROR", "git.example.com")
+	tempDir := t.TempDir()
+	content := "[dependencies]\nlib = { source = \"https://${ALMD_TEST_MIRROR}/lib.lua\", path = \"libs/lib.lua\" }\n"
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, ProjectTomlName), []byte(content), 0644))
+
+	proj, err := LoadProjectToml(tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, "https://git.example.com/lib.lua", proj.Dependencies["lib"].Source)
+
+	require.NoError(t, WriteProjectToml(tempDir, proj))
+	written, err := os.ReadFile(filepath.Join(tempDir, ProjectTomlName))
+	require.NoError(t, err)
+	assert.Contains(t, string(written), "https://${ALMD_TEST_MIRROR}/lib.lua", "the template is saved, not its expansion")
+}
+
+func TestLoadProjectToml_Defaults(t *testing.T) {
+	tempDir := t.TempDir()
+	content := "[defaults]\ngithub_owner = \"mystudio\"\nbranch = \"main\"\n\n" +
+		"[dependencies]\nutil = { source = \"github:/shared-lua/util.lua@v1\", path = \"libs/util.lua\" }\n" +
+		"log = { source = \"github:other/log/log.lua\", path = \"libs/log.lua\" }\n"
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, ProjectTomlName), []byte(content), 0644))
+
+	proj, err := LoadProjectToml(tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, "github:mystudio/shared-lua/util.lua@v1", proj.Dependencies["util"].Source)
+	assert.Equal(t, "github:other/log/log.lua@main", proj.Dependencies["log"].Source)
+
+	require.NoError(t, WriteProjectToml(tempDir, proj))
+	written, err := os.ReadFile(filepath.Join(tempDir, ProjectTomlName))
+	require.NoError(t, err)
+	assert.Contains(t, string(written), "github:/shared-lua/util.lua@v1", "the shorthand is saved, not its expansion")
+
+	noOwner := "[dependencies]\nutil = { source = \"github:/shared-lua/util.lua@v1\", path = \"libs/util.lua\" }\n"
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, ProjectTomlName), []byte(noOwner), 0644))
+	_, err = LoadProjectToml(tempDir)
+	assert.ErrorContains(t, err, "[defaults] github_owner is not set")
+}
+
+func TestLoadProjectToml_StrictEnv(t *testing.T) {
+	tempDir := t.TempDir()
+	content := "[dependencies]\nlib = { source = \"https://${ALMD_TEST_UNDEFINED}/lib.lua\", path = \"libs/lib.lua\" }\n"
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, ProjectTomlName), []byte(content), 0644))
+
+	proj, err := LoadProjectToml(tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, "https:///lib.lua", proj.Dependencies["lib"].Source)
+
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, ProjectTomlName), []byte("[vendor]\nstrict_env = true\n"+content), 0644))
+	_, err = LoadProjectToml(tempDir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "undefined environment variable ALMD_TEST_UNDEFINED")
+}
+
+func TestLoadProjectToml_ExpandsHeaderEnv(t *testing.T) {
+	t.Setenv("ALMD_TEST_KEY", "s3cret")
+	tempDir := t.TempDir()
+	content := "[dependencies.lib]\nsource = \"https://example.com/lib.lua\"\npath = \"libs/lib.lua\"\n" +
+		"headers = { \"X-Api-Key\" = \"${ALMD_TEST_KEY}\", \"Accept\" = \"text/plain\" }\n"
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, ProjectTomlName), []byte(content), 0644))
+
+	proj, err := LoadProjectToml(tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"X-Api-Key": "s3cret", "Accept": "text/plain"}, proj.Dependencies["lib"].Headers)
+
+	require.NoError(t, WriteProjectToml(tempDir, proj))
+	written, err := os.ReadFile(filepath.Join(tempDir, ProjectTomlName))
+	require.NoError(t, err)
+	assert.Contains(t, string(written), "${ALMD_TEST_KEY}")
+	assert.NotContains(t, string(written), "s3cret", "secrets are never written back")
+
+	invalid := "[dependencies.lib]\nsource = \"https://example.com/lib.lua\"\npath = \"libs/lib.lua\"\nheaders = { \"X Api\" = \"x\" }\n"
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, ProjectTomlName), []byte(invalid), 0644))
+	_, err = LoadProjectToml(tempDir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid header name 'X Api'")
+}
+
+func TestLoadProjectToml_SourceSettings(t *testing.T) {
+	t.Cleanup(func() {
+		downloader.SetMirrors(nil)
+		source.SetProviderSettings(nil)
+		source.SetGitHubEnterpriseHosts(nil)
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	manifest := `[package]
+name = "test-project"
+version = "0.1.0"
+
+[registries.internal]
+upstream = "https://raw.githubusercontent.com/"
+url = "` + server.URL + `/github/"
+
+[providers.github]
+api_url = "https://git.example.com/api/v3"
+`
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, ProjectTomlName), []byte(manifest), 0644))
+	proj, err := LoadProjectToml(tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, "https://git.example.com/api/v3", proj.Providers["github"].APIURL)
+
+	content, err := downloader.DownloadFile("https://raw.githubusercontent.com/owner/repo/main/lib.lua")
+	require.NoError(t, err)
+	assert.Equal(t, "/github/owner/repo/main/lib.lua", string(content), "downloads go through the project's mirror")
+
+	for _, tc := range []struct{ table, errContains string }{
+		{"[providers.sourcehut]\napi_url = \"https://git.example.com\"\n", "[providers.sourcehut]: unknown provider"},
+		{"[registries.broken]\nupstream = \"https://example.com/\"\nurl = \"mirror\"\n", "[registries.broken] url: 'mirror' is not an absolute URL"},
+		{"[providers.gitea]\nbase_url = \"git.example.com\"\n", "[providers.gitea] base_url: 'git.example.com' is not an absolute URL"},
+		{"[registries.notices]\nadvisories = \"advisories.toml\"\n", "[registries.notices] advisories: 'advisories.toml' is not an absolute URL"},
+		{"[registries.empty]\n", "[registries.empty] upstream: must not be empty"},
+		{"[github_enterprise.github]\nbase_url = \"https://github.mycorp.com\"\n", "[github_enterprise.github] alias is the name of a built-in provider"},
+		{"[github_enterprise.Corp]\nbase_url = \"https://github.mycorp.com\"\n", "[github_enterprise.Corp] alias may only contain"},
+		{"[github_enterprise.corp]\napi_url = \"https://github.mycorp.com/api/v3\"\n", "[github_enterprise.corp] base_url: must not be empty"},
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, ProjectTomlName), []byte("[package]\nname = \"p\"\nversion = \"0.1.0\"\n\n"+tc.table), 0644))
+		_, err := LoadProjectToml(tempDir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), tc.errContains)
+	}
+}
+
+func TestManifestPath(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, filepath.Join(dir, ProjectTomlName), ManifestPath(dir), "project.toml is the default")
+	assert.False(t, HasManifest(dir))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ProjectTomlName), []byte("[tool]\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, AlmdTomlName), []byte("[package]\nname = \"almd-project\"\n"), 0644))
+	assert.Equal(t, filepath.Join(dir, AlmdTomlName), ManifestPath(dir), "almd.toml wins over another tool's project.toml")
+	proj, err := LoadProjectToml(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "almd-project", proj.Package.Name)
+
+	SetManifest(filepath.Join("config", "deps.toml"))
+	t.Cleanup(func() { SetManifest("") })
+	assert.Equal(t, filepath.Join(dir, "config", "deps.toml"), ManifestPath(dir))
+	assert.False(t, HasManifest(dir))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "config"), 0755))
+	require.NoError(t, WriteProjectToml(dir, proj))
+	assert.True(t, HasManifest(dir))
+	assert.FileExists(t, filepath.Join(dir, "config", "deps.toml"))
+}
+
+func TestRequireWritable(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ProjectTomlName), []byte("[package]\nname = \"p\"\n"), 0644))
+	require.NoError(t, RequireWritable(dir))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "the probe file is removed")
+	data, err := os.ReadFile(filepath.Join(dir, ProjectTomlName))
+	require.NoError(t, err)
+	assert.Equal(t, "[package]\nname = \"p\"\n", string(data), "the manifest is not truncated")
+
+	if runtime.GOOS != "windows" && os.Geteuid() != 0 {
+		require.NoError(t, os.Chmod(dir, 0555))
+		t.Cleanup(func() { _ = os.Chmod(dir, 0755) })
+		assert.ErrorIs(t, RequireWritable(dir), ErrReadOnly)
+	}
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/nightconcept/almandine/internal/core/advisory"
+	"github.com/nightconcept/almandine/internal/core/downloader"
+	"github.com/nightconcept/almandine/internal/core/project"
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+// useSourceSettings validates the [registries], [providers] and [github_enterprise] tables of
+// proj and makes downloads, provider API calls and advisory lookups follow them. A manifest
+// without them restores the defaults, so each project of a recursive run uses its own settings.
+func useSourceSettings(proj *project.Project) error {
+	source.SetGitHubEnterpriseHosts(nil) // The previous project's aliases are not built-in providers
+	if err := proj.ValidateSources(source.ProviderNames()); err != nil {
+		return err
+	}
+	var mirrors []downloader.Mirror
+	var advisoryIndexes []string
+	for _, name := range slices.Sorted(maps.Keys(proj.Registries)) {
+		reg := proj.Registries[name]
+		if reg.Mirrors() {
+			mirrors = append(mirrors, downloader.Mirror{Upstream: reg.Upstream, URL: reg.URL})
+		}
+		if reg.Advisories != "" {
+			advisoryIndexes = append(advisoryIndexes, reg.Advisories)
+		}
+	}
+	downloader.SetMirrors(mirrors)
+	advisory.SetIndexes(advisoryIndexes)
+
+	settings := make(map[string]source.ProviderSettings, len(proj.Providers))
+	for name, cfg := range proj.Providers {
+		settings[name] = source.ProviderSettings{APIURL: cfg.APIURL, BaseURL: cfg.BaseURL}
+	}
+	source.SetProviderSettings(settings)
+
+	hosts := make(map[string]source.GitHubEnterpriseHost, len(proj.GitHubEnterprise))
+	for alias, cfg := range proj.GitHubEnterprise {
+		hosts[alias] = source.GitHubEnterpriseHost{BaseURL: cfg.BaseURL, APIURL: cfg.APIURL, RawURL: cfg.RawURL, TokenEnv: cfg.TokenEnv}
+	}
+	source.SetGitHubEnterpriseHosts(hosts)
+	return nil
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// ErrReadOnly is returned by RequireWritable when the project cannot be written to.
+var ErrReadOnly = errors.New("project is read-only")
+
+// RequireWritable checks that the manifest in dir can be rewritten and that files can be created
+// next to it, so a command that changes the project fails before it downloads anything on a
+// read-only filesystem (a container image, a CI workspace mounted read-only). The manifest is
+// opened for writing without truncating it and a probe file is created and removed again.
+func RequireWritable(dir string) error {
+	manifest := ManifestPath(dir)
+	if f, err := os.OpenFile(manifest, os.O_WRONLY, 0); err == nil {
+		_ = f.Close()
+	} else if !os.IsNotExist(err) {
+		return readOnlyError(manifest, err)
+	}
+	probe, err := os.CreateTemp(filepath.Dir(manifest), ".almd-write-check-*")
+	if err != nil {
+		return readOnlyError(filepath.Dir(manifest), err)
+	}
+	_ = probe.Close()
+	_ = os.Remove(probe.Name())
+	return nil
+}
+
+func readOnlyError(path string, err error) error {
+	if errors.Is(err, os.ErrPermission) || errors.Is(err, syscall.EROFS) {
+		return fmt.Errorf("%w: cannot write %s (%v); use --dry-run or another report mode to inspect it without changes", ErrReadOnly, path, err)
+	}
+	return fmt.Errorf("cannot write %s: %w", path, err)
+}
@@ -0,0 +1,126 @@
+// Package credentials stores tokens through git's credential helpers ('git credential'), so
+// they end up in whatever store the user configured for git: the macOS keychain, the Windows
+// credential manager, libsecret and so on. No token is ever passed on a command line.
+package credentials
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Username is stored with every token. GitHub ignores it for token authentication, but
+// credential helpers need one.
+const Username = "almd"
+
+// ErrNotFound is returned by Lookup when no helper has a token for the host.
+var ErrNotFound = errors.New("no stored token")
+
+var (
+	cacheMu sync.Mutex
+	cached  = map[string]string{} // Host and username -> token, so each process asks the helper only once
+)
+
+// HelperConfigured reports whether git is installed and has a credential helper configured.
+func HelperConfigured() bool {
+	out, err := exec.Command("git", "config", "--get-all", "credential.helper").Output()
+	return err == nil && strings.TrimSpace(string(out)) != ""
+}
+
+// Store saves token for host with the configured credential helpers.
+func Store(host, token string) error {
+	return StoreAs(host, Username, token)
+}
+
+// Lookup returns the token stored for host. It never prompts.
+func Lookup(host string) (string, error) {
+	return LookupAs(host, Username)
+}
+
+// Erase removes the token stored for host from the credential helpers.
+func Erase(host string) error {
+	return EraseAs(host, Username)
+}
+
+// StoreAs is Store for a token kept under its own username, so several tokens can be stored
+// for one host (see the GitHub token pool).
+func StoreAs(host, username, token string) error {
+	if _, err := run("approve", host, username, token); err != nil {
+		return err
+	}
+	cacheMu.Lock()
+	cached[host+"\x00"+username] = token
+	cacheMu.Unlock()
+	return nil
+}
+
+// LookupAs is Lookup for a token stored with StoreAs.
+func LookupAs(host, username string) (string, error) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	key := host + "\x00" + username
+	if token, ok := cached[key]; ok {
+		return token, nil
+	}
+	out, err := run("fill", host, username, "")
+	if err != nil {
+		return "", ErrNotFound // 'git credential fill' fails when it would have to prompt
+	}
+	fields := parse(out)
+	if fields["username"] != username || fields["password"] == "" {
+		return "", ErrNotFound
+	}
+	cached[key] = fields["password"]
+	return fields["password"], nil
+}
+
+// EraseAs is Erase for a token stored with StoreAs.
+func EraseAs(host, username string) error {
+	cacheMu.Lock()
+	delete(cached, host+"\x00"+username)
+	cacheMu.Unlock()
+	_, err := run("reject", host, username, "")
+	return err
+}
+
+// run calls 'git credential <action>' with the credential description on stdin. Prompts are
+// disabled so a missing credential is an error rather than a hanging terminal.
+func run(action, host, username, token string) ([]byte, error) {
+	var input bytes.Buffer
+	_, _ = fmt.Fprintf(&input, "protocol=https\nhost=%s\nusername=%s\n", host, username)
+	if token != "" {
+		_, _ = fmt.Fprintf(&input, "password=%s\n", token)
+	}
+	input.WriteString("\n")
+
+	cmd := exec.Command("git", "credential", action)
+	cmd.Stdin = &input
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("git is not installed; it is needed to reach the credential helper")
+		}
+		return nil, fmt.Errorf("git credential %s: %v %s", action, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// parse reads the key=value lines git credential prints.
+func parse(out []byte) map[string]string {
+	fields := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(scanner.Text(), "="); ok {
+			fields[key] = value
+		}
+	}
+	return fields
+}
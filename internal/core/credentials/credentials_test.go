@@ -0,0 +1,67 @@
+package credentials
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useStoreHelper points git at a gitconfig whose credential helper is a plain file in a
+// temporary directory.
+func useStoreHelper(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	store := filepath.Join(dir, "credentials")
+	gitconfig := filepath.Join(dir, "gitconfig")
+	require.NoError(t, os.WriteFile(gitconfig, []byte("[credential]\n\thelper = store --file "+filepath.ToSlash(store)+"\n"), 0644))
+	t.Setenv("GIT_CONFIG_GLOBAL", gitconfig)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Cleanup(func() {
+		cacheMu.Lock()
+		cached = map[string]string{}
+		cacheMu.Unlock()
+	})
+	return store
+}
+
+func TestStoreLookupErase(t *testing.T) {
+	store := useStoreHelper(t)
+	assert.True(t, HelperConfigured())
+
+	_, err := Lookup("github.com")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, Store("github.com", "ghp_secret"))
+	data, err := os.ReadFile(store)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "github.com")
+
+	cacheMu.Lock()
+	cached = map[string]string{} // Force a round trip through the helper
+	cacheMu.Unlock()
+	token, err := Lookup("github.com")
+	require.NoError(t, err)
+	assert.Equal(t, "ghp_secret", token)
+
+	require.NoError(t, Erase("github.com"))
+	_, err = Lookup("github.com")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestHelperConfigured_None(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	gitconfig := filepath.Join(t.TempDir(), "gitconfig")
+	require.NoError(t, os.WriteFile(gitconfig, nil, 0644))
+	t.Setenv("GIT_CONFIG_GLOBAL", gitconfig)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	assert.False(t, HelperConfigured())
+}
@@ -1,30 +1,245 @@
 // Package downloader provides functionality to download files from URLs.
+//
+// Downloads are dispatched on the URL scheme to a Backend. HTTP(S), file:// and git+<transport>
+// URLs are supported out of the box; other transports (S3, IPFS, ...) can be added with RegisterBackend
+// without changing the code that downloads dependencies.
 package downloader
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/nightconcept/almandine/internal/core/httpclient"
+	"github.com/nightconcept/almandine/internal/core/logger"
+)
+
+// Backend fetches content for the URL schemes it is registered for.
+type Backend interface {
+	// Open returns a reader for the content at u. Errors should name the URL.
+	Open(u *url.URL) (io.ReadCloser, error)
+}
+
+// HeaderBackend is a Backend that can send extra request headers, as HTTP(S) does.
+type HeaderBackend interface {
+	Backend
+	// OpenWithHeaders is Open with headers added to the request.
+	OpenWithHeaders(u *url.URL, headers map[string]string) (io.ReadCloser, error)
+}
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]Backend{
+		"http":      httpBackend{},
+		"https":     httpBackend{},
+		"file":      fileBackend{},
+		"git+https": gitBackend{},
+		"git+http":  gitBackend{},
+		"git+ssh":   gitBackend{},
+		"git+file":  gitBackend{},
+	}
 )
 
+// RegisterBackend makes b handle URLs with the given scheme and returns a function that restores
+// the previous registration.
+func RegisterBackend(scheme string, b Backend) (restore func()) {
+	scheme = strings.ToLower(scheme)
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	previous, had := backends[scheme]
+	backends[scheme] = b
+	return func() {
+		backendsMu.Lock()
+		defer backendsMu.Unlock()
+		if had {
+			backends[scheme] = previous
+		} else {
+			delete(backends, scheme)
+		}
+	}
+}
+
+// backendFor selects the backend for u's scheme.
+func backendFor(u *url.URL) (Backend, error) {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	if b, ok := backends[strings.ToLower(u.Scheme)]; ok {
+		return b, nil
+	}
+	schemes := make([]string, 0, len(backends))
+	for s := range backends {
+		schemes = append(schemes, s)
+	}
+	sort.Strings(schemes)
+	return nil, fmt.Errorf("unsupported URL scheme '%s' in %s (supported: %s)", u.Scheme, u.String(), strings.Join(schemes, ", "))
+}
+
+// Open returns a reader for the content at rawURL using the backend for its scheme. URLs under
+// a mirror's upstream are fetched from the mirror (see SetMirrors).
+func Open(rawURL string) (io.ReadCloser, error) {
+	u, err := parseDownloadURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	b, err := backendFor(u)
+	if err != nil {
+		return nil, err
+	}
+	return b.Open(u)
+}
+
 // DownloadFile fetches the content from the given URL.
 // It returns the content as a byte slice or an error if the download fails
-// or if the HTTP status code is not 200 OK.
+// or, for HTTP URLs, if the status code is not 200 OK. HTTP requests go through
+// the shared transport so consecutive downloads reuse pooled connections.
 func DownloadFile(url string) ([]byte, error) {
-	resp, err := http.Get(url)
+	body, err := Open(url)
 	if err != nil {
-		return nil, fmt.Errorf("failed to perform GET request to %s: %w", url, err)
+		return nil, err
 	}
-	defer func() { _ = resp.Body.Close() }()
+	return readBody(body, url)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to download from %s: received status code %d", url, resp.StatusCode)
+// WithHeaders returns a function that downloads like DownloadFile and sends headers with each
+// request. URLs whose backend cannot send headers fail rather than being fetched without them.
+func WithHeaders(headers map[string]string) func(string) ([]byte, error) {
+	if len(headers) == 0 {
+		return DownloadFile
+	}
+	return func(rawURL string) ([]byte, error) {
+		u, err := parseDownloadURL(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		b, err := backendFor(u)
+		if err != nil {
+			return nil, err
+		}
+		hb, ok := b.(HeaderBackend)
+		if !ok {
+			return nil, fmt.Errorf("cannot send headers with %s: '%s' URLs do not support them", rawURL, u.Scheme)
+		}
+		body, err := hb.OpenWithHeaders(u, headers)
+		if err != nil {
+			return nil, err
+		}
+		return readBody(body, rawURL)
+	}
+}
+
+// readBody reads and closes a download's body.
+func readBody(body io.ReadCloser, url string) ([]byte, error) {
+	defer func() { _ = body.Close() }()
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body from %s: %w", url, err)
+	}
+	logger.Debugf("downloaded %s (%d bytes)", url, len(content))
+	return content, nil
+}
+
+// DownloadVerified fetches url like DownloadFile, hashing the content while it streams in, and
+// fails unless it matches expectedHash ("sha256:<hex>").
+func DownloadVerified(url, expectedHash string) ([]byte, error) {
+	want, ok := strings.CutPrefix(expectedHash, "sha256:")
+	if !ok {
+		return nil, fmt.Errorf("unsupported integrity hash '%s' (expected sha256:<hex>)", expectedHash)
+	}
+	body, err := Open(url)
+	if err != nil {
+		return nil, err
 	}
+	defer func() { _ = body.Close() }()
 
-	body, err := io.ReadAll(resp.Body)
+	digest := sha256.New()
+	content, err := io.ReadAll(io.TeeReader(body, digest))
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response body from %s: %w", url, err)
 	}
+	if got := hex.EncodeToString(digest.Sum(nil)); got != strings.ToLower(want) {
+		return nil, fmt.Errorf("content downloaded from %s has hash sha256:%s, expected %s", url, got, expectedHash)
+	}
+	return content, nil
+}
+
+// httpBackend downloads http:// and https:// URLs with a plain GET.
+type httpBackend struct{}
+
+func (b httpBackend) Open(u *url.URL) (io.ReadCloser, error) {
+	return b.OpenWithHeaders(u, nil)
+}
 
-	return body, nil
+func (httpBackend) OpenWithHeaders(u *url.URL, headers map[string]string) (io.ReadCloser, error) {
+	target := u.String()
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GET request for %s: %w", target, err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := httpclient.Client(0).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to perform GET request to %s: %w", target, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, &StatusError{URL: target, StatusCode: resp.StatusCode}
+	}
+	return resp.Body, nil
+}
+
+// StatusError is returned when an HTTP download answers with a status other than 200 OK.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("failed to download from %s: received status code %d", e.URL, e.StatusCode)
+}
+
+// IsNotFound reports whether err is an HTTP 404 from a download.
+func IsNotFound(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+// fileBackend reads file:// URLs from the local filesystem, e.g. a mirror of vendored sources.
+// A URL without slashes after the scheme, such as "file:../shared/util.lua", names a path
+// relative to the working directory.
+type fileBackend struct{}
+
+func (fileBackend) Open(u *url.URL) (io.ReadCloser, error) {
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, fmt.Errorf("file URL %s names remote host '%s'; only local files are supported", u.String(), u.Host)
+	}
+	localPath := fileURLPath(runtime.GOOS, u.Path)
+	if u.Opaque != "" {
+		localPath = filepath.FromSlash(u.Opaque)
+	}
+	f, err := os.Open(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", u.String(), err)
+	}
+	return f, nil
+}
+
+// fileURLPath converts the path of a file:// URL to a local path. On Windows the leading slash
+// before a drive letter ("/C:/libs/x.lua") is dropped.
+func fileURLPath(goos, p string) string {
+	if goos == "windows" && len(p) >= 3 && p[0] == '/' && p[2] == ':' {
+		p = p[1:]
+	}
+	return filepath.FromSlash(p)
 }
@@ -3,8 +3,14 @@ package downloader_test
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -39,6 +45,7 @@ func TestDownloadFile_HTTPErrorNotFound(t *testing.T) {
 	require.Error(t, err, "DownloadFile should have returned an error for 404")
 	assert.Contains(t, err.Error(), "failed to download from", "Error message mismatch")
 	assert.Contains(t, err.Error(), "received status code 404", "Error message mismatch for status code")
+	assert.True(t, downloader.IsNotFound(err), "a 404 should be reported as not found")
 }
 
 func TestDownloadFile_HTTPErrorInternalServer(t *testing.T) {
@@ -52,6 +59,7 @@ func TestDownloadFile_HTTPErrorInternalServer(t *testing.T) {
 	require.Error(t, err, "DownloadFile should have returned an error for 500")
 	assert.Contains(t, err.Error(), "failed to download from", "Error message mismatch")
 	assert.Contains(t, err.Error(), "received status code 500", "Error message mismatch for status code")
+	assert.False(t, downloader.IsNotFound(err), "a 500 is not a missing file")
 }
 
 func TestDownloadFile_NetworkError_InvalidURL(t *testing.T) {
@@ -85,3 +93,126 @@ func TestDownloadFile_ReadBodyError(t *testing.T) {
 	require.Error(t, err, "DownloadFile should have returned an error when reading the body fails")
 	assert.Contains(t, err.Error(), fmt.Sprintf("failed to read response body from %s", server.URL), "Error message mismatch for read body error")
 }
+
+func TestDownloadFile_FileURL(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "lib.lua")
+	require.NoError(t, os.WriteFile(path, []byte("return {}"), 0644))
+
+	fileURL := (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+	if runtime.GOOS == "windows" {
+		fileURL = "file:///" + filepath.ToSlash(path)
+	}
+	content, err := downloader.DownloadFile(fileURL)
+	require.NoError(t, err)
+	assert.Equal(t, "return {}", string(content))
+
+	_, err = downloader.DownloadFile(fileURL + ".missing")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to open")
+
+	_, err = downloader.DownloadFile("file://fileserver/share/lib.lua")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "remote host")
+}
+
+func TestDownloadFile_RelativeFileURL(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "shared"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "shared", "util.lua"), []byte("return 'util'"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "game"), 0755))
+	t.Chdir(filepath.Join(dir, "game"))
+
+	content, err := downloader.DownloadFile("file:../shared/util.lua")
+	require.NoError(t, err)
+	assert.Equal(t, "return 'util'", string(content))
+}
+
+func TestWithHeaders(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("secret content"))
+	}))
+	defer server.Close()
+
+	content, err := downloader.WithHeaders(map[string]string{"X-Api-Key": "s3cret"})(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "secret content", string(content))
+
+	_, err = downloader.WithHeaders(nil)(server.URL)
+	require.Error(t, err, "headers are only sent when given")
+
+	_, err = downloader.WithHeaders(map[string]string{"X-Api-Key": "s3cret"})("file:///tmp/lib.lua")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "do not support them")
+}
+
+func TestDownloadFile_UnsupportedScheme(t *testing.T) {
+	t.Parallel()
+	_, err := downloader.DownloadFile("ipfs://bafy/lib.lua")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported URL scheme 'ipfs'")
+	assert.Contains(t, err.Error(), "file, git+file, git+http, git+https, git+ssh, http, https")
+}
+
+type staticBackend string
+
+func (b staticBackend) Open(u *url.URL) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(string(b) + u.Path)), nil
+}
+
+func TestRegisterBackend(t *testing.T) {
+	restore := downloader.RegisterBackend("s3", staticBackend("bucket"))
+	content, err := downloader.DownloadFile("s3://bucket/lib.lua")
+	require.NoError(t, err)
+	assert.Equal(t, "bucket/lib.lua", string(content))
+
+	restore()
+	_, err = downloader.DownloadFile("s3://bucket/lib.lua")
+	assert.Error(t, err, "restoring removes the backend again")
+}
+
+func TestDownloadVerified(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("archive"))
+	}))
+	defer server.Close()
+
+	const archiveHash = "sha256:0eb3e36bfb24dcd9bb1d1bece1531216b59539a8fde17ee80224af0653c92aa3"
+	content, err := downloader.DownloadVerified(server.URL, archiveHash)
+	require.NoError(t, err)
+	assert.Equal(t, "archive", string(content))
+
+	_, err = downloader.DownloadVerified(server.URL, "sha256:"+strings.Repeat("0", 64))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "expected sha256:000")
+
+	_, err = downloader.DownloadVerified(server.URL, "commit:abc")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported integrity hash")
+}
+
+func TestSetMirrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+	downloader.SetMirrors([]downloader.Mirror{
+		{Upstream: "https://upstream.invalid/", URL: server.URL + "/all/"},
+		{Upstream: "https://upstream.invalid/owner/", URL: server.URL + "/owner-mirror/"},
+	})
+	defer downloader.SetMirrors(nil)
+
+	content, err := downloader.DownloadFile("https://upstream.invalid/owner/repo/lib.lua")
+	require.NoError(t, err)
+	assert.Equal(t, "/owner-mirror/repo/lib.lua", string(content), "the longest upstream prefix wins")
+
+	content, err = downloader.WithHeaders(map[string]string{"X-Api-Key": "k"})("https://upstream.invalid/other/lib.lua")
+	require.NoError(t, err)
+	assert.Equal(t, "/all/other/lib.lua", string(content))
+}
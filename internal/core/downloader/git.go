@@ -0,0 +1,65 @@
+package downloader
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// gitBackend extracts one file from a git repository named by a git+<transport> URL such as
+// git+https://git.example.com/owner/repo.git#path=lib/util.lua&ref=main. It fetches only the
+// one commit into a temporary repository, without the other files' contents where the server
+// supports partial clone, and removes the repository afterwards.
+type gitBackend struct{}
+
+func (gitBackend) Open(u *url.URL) (io.ReadCloser, error) {
+	fragment, err := url.ParseQuery(u.Fragment)
+	if err != nil || fragment.Get("path") == "" {
+		return nil, fmt.Errorf("git URL %s must name a file as #path=<path>&ref=<ref>", u.String())
+	}
+	ref := fragment.Get("ref")
+	if ref == "" {
+		ref = "HEAD"
+	}
+	repo := *u
+	repo.Scheme, repo.Fragment, repo.RawFragment = strings.TrimPrefix(u.Scheme, "git+"), "", ""
+
+	dir, err := os.MkdirTemp("", "almd-git-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	steps := [][]string{
+		{"init", "--quiet"},
+		{"remote", "add", "--", "origin", repo.String()},
+		{"fetch", "--quiet", "--depth=1", "--filter=blob:none", "--no-tags", "--end-of-options", "origin", ref},
+	}
+	for _, args := range steps {
+		if _, err := runGit(dir, args...); err != nil {
+			return nil, fmt.Errorf("failed to fetch %s: %w", u.String(), err)
+		}
+	}
+	content, err := runGit(dir, "show", "FETCH_HEAD:"+strings.TrimPrefix(fragment.Get("path"), "/"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", u.String(), err)
+	}
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+// runGit runs git in dir and returns its standard output. Git never prompts for credentials;
+// private repositories need a credential helper or SSH key set up beforehand.
+func runGit(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
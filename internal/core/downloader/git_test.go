@@ -0,0 +1,54 @@
+package downloader_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/downloader"
+)
+
+func TestDownloadFile_GitURL(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		out, err := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "--quiet", "--initial-branch=main")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "lib"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib", "util.lua"), []byte("return 1"), 0644))
+	git("add", "-A")
+	git("commit", "--quiet", "-m", "first")
+	first := git("rev-parse", "HEAD")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib", "util.lua"), []byte("return 2"), 0644))
+	git("commit", "--quiet", "-am", "second")
+	repoURL := "git+file://" + filepath.ToSlash(dir)
+
+	content, err := downloader.DownloadFile(repoURL + "#path=lib/util.lua&ref=main")
+	require.NoError(t, err)
+	assert.Equal(t, "return 2", string(content))
+	content, err = downloader.DownloadFile(repoURL + "#path=lib/util.lua&ref=" + first)
+	require.NoError(t, err)
+	assert.Equal(t, "return 1", string(content))
+	content, err = downloader.DownloadFile(repoURL + "#path=lib/util.lua")
+	require.NoError(t, err)
+	assert.Equal(t, "return 2", string(content), "without a ref the default branch is used")
+
+	_, err = downloader.DownloadFile(repoURL + "#path=lib/missing.lua&ref=main")
+	assert.ErrorContains(t, err, "failed to read")
+	_, err = downloader.DownloadFile(repoURL)
+	assert.ErrorContains(t, err, "#path=<path>&ref=<ref>")
+
+	// A ref that looks like an option is passed to git as a ref, never run as a command.
+	marker := filepath.Join(t.TempDir(), "pwned")
+	_, err = downloader.DownloadFile(repoURL + "#path=lib/util.lua&ref=--upload-pack=touch%20" + filepath.ToSlash(marker))
+	assert.Error(t, err)
+	assert.NoFileExists(t, marker)
+}
@@ -0,0 +1,57 @@
+package downloader
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/nightconcept/almandine/internal/core/logger"
+)
+
+// Mirror redirects downloads whose URL starts with Upstream to the same path under URL, e.g. an
+// internal copy of raw.githubusercontent.com. Lockfiles keep recording the upstream URL.
+type Mirror struct {
+	Upstream string
+	URL      string
+}
+
+var (
+	mirrorsMu sync.RWMutex
+	mirrors   []Mirror
+)
+
+// SetMirrors replaces the mirrors downloads are redirected through; nil removes them all. When
+// several upstream prefixes match a URL, the longest one wins.
+func SetMirrors(m []Mirror) {
+	mirrorsMu.Lock()
+	defer mirrorsMu.Unlock()
+	mirrors = append([]Mirror(nil), m...)
+}
+
+// mirrored returns the URL rawURL is actually fetched from.
+func mirrored(rawURL string) string {
+	mirrorsMu.RLock()
+	defer mirrorsMu.RUnlock()
+	var best *Mirror
+	for i, m := range mirrors {
+		if strings.HasPrefix(rawURL, m.Upstream) && (best == nil || len(m.Upstream) > len(best.Upstream)) {
+			best = &mirrors[i]
+		}
+	}
+	if best == nil {
+		return rawURL
+	}
+	target := best.URL + strings.TrimPrefix(rawURL, best.Upstream)
+	logger.Debugf("fetching %s from mirror %s", rawURL, target)
+	return target
+}
+
+// parseDownloadURL parses rawURL after redirecting it through the configured mirrors.
+func parseDownloadURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(mirrored(rawURL))
+	if err != nil {
+		return nil, fmt.Errorf("invalid download URL '%s': %w", rawURL, err)
+	}
+	return u, nil
+}
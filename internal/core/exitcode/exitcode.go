@@ -0,0 +1,42 @@
+// Package exitcode defines the process exit codes almd commands use, so that scripts can tell
+// kinds of failure apart without parsing output.
+package exitcode
+
+// Exit codes. Commands return them through cli.Exit.
+const (
+	OK         = 0 // Everything succeeded
+	Usage      = 1 // Invalid arguments, configuration or project files, local write failures and other general errors
+	Resolution = 2 // A source, ref or tag pattern could not be resolved
+	Download   = 3 // Content could not be fetched
+	Integrity  = 4 // Content or the lockfile does not match what was expected
+	Partial    = 5 // Some targeted dependencies succeeded and others failed
+
+	Interrupted = 130 // The run was stopped by Ctrl-C or SIGTERM; running it again finishes it
+)
+
+// precedence ranks the failure codes from most to least specific. Integrity problems come
+// first because they may point to tampering, then failures to download and to resolve. Partial
+// success only says that something failed, and Usage is the catch-all.
+var precedence = []int{Integrity, Download, Resolution, Partial, Usage}
+
+// MostSpecific returns the most specific of codes by the order above, ignoring OK. Codes it
+// does not know rank below Usage. It returns OK when every code is OK.
+func MostSpecific(codes ...int) int {
+	best, bestRank := OK, len(precedence)+1
+	for _, code := range codes {
+		if code == OK {
+			continue
+		}
+		rank := len(precedence)
+		for i, ranked := range precedence {
+			if code == ranked {
+				rank = i
+				break
+			}
+		}
+		if rank < bestRank {
+			best, bestRank = code, rank
+		}
+	}
+	return best
+}
@@ -0,0 +1,18 @@
+package exitcode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMostSpecific(t *testing.T) {
+	assert.Equal(t, OK, MostSpecific())
+	assert.Equal(t, OK, MostSpecific(OK, OK))
+	assert.Equal(t, Download, MostSpecific(Usage, Download, Resolution))
+	assert.Equal(t, Integrity, MostSpecific(Usage, Integrity, Download))
+	assert.Equal(t, Integrity, MostSpecific(Partial, Integrity), "ranked by specificity, not by number")
+	assert.Equal(t, Resolution, MostSpecific(Partial, Resolution))
+	assert.Equal(t, Usage, MostSpecific(42, Usage, OK), "unknown codes rank below Usage")
+	assert.Equal(t, 42, MostSpecific(OK, 42))
+}
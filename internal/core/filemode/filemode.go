@@ -0,0 +1,220 @@
+// Package filemode decides the permissions dependency files are written with.
+//
+// A mode can be set per dependency (mode = "0755" in project.toml) or globally (file_mode in
+// the global config). Without either, files are created with Default, and re-installing over a
+// file that has its executable bits set keeps them on Unix platforms so vendored scripts stay
+// runnable. Modes are applied when the file is created, so the process umask still applies.
+//
+// With read_only enabled in the global config, dependency files are written without write
+// permission to discourage local edits. almd lifts the protection itself whenever it replaces
+// or removes a file.
+package filemode
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/nightconcept/almandine/internal/core/globalconfig"
+	"github.com/nightconcept/almandine/internal/core/staging"
+)
+
+// Default is the mode of newly written dependency files when nothing else is configured.
+const Default os.FileMode = 0644
+
+// writeBits are the permission bits removed from read-only dependency files.
+const writeBits os.FileMode = 0222
+
+// Parse parses an octal permission string such as "0755" or "644".
+func Parse(s string) (os.FileMode, error) {
+	trimmed := strings.TrimPrefix(strings.TrimSpace(s), "0o")
+	value, err := strconv.ParseUint(trimmed, 8, 32)
+	if err != nil || value > 0777 {
+		return 0, fmt.Errorf("invalid file mode '%s' (use octal permissions such as 0644 or 0755)", s)
+	}
+	return os.FileMode(value), nil
+}
+
+// Format renders a mode the way it is written in configuration files.
+func Format(mode os.FileMode) string {
+	return fmt.Sprintf("%04o", mode.Perm())
+}
+
+// Settings are the global config values that affect written files.
+type Settings struct {
+	FileMode string // Default mode, "" for Default
+	ReadOnly bool   // Write dependency files without write permission
+}
+
+// GlobalSettings returns the file settings from the global config. An unreadable config yields
+// the zero Settings. Commands load them once and pass them to every WriteFile.
+func GlobalSettings() Settings {
+	cfg, err := globalconfig.Load()
+	if err != nil {
+		return Settings{}
+	}
+	return Settings{FileMode: cfg.FileMode, ReadOnly: cfg.ReadOnly}
+}
+
+// Resolve picks the mode for writing path. The dependency's own mode wins over the global
+// default; otherwise an existing executable file keeps its mode (except on Windows, which has
+// no executable bit), made owner-writable again in case it was protected by read_only.
+func Resolve(depMode, globalMode, path string) (os.FileMode, error) {
+	for _, configured := range []string{depMode, globalMode} {
+		if configured != "" {
+			return Parse(configured)
+		}
+	}
+	if runtime.GOOS != "windows" {
+		if info, statErr := os.Stat(path); statErr == nil && info.Mode().Perm()&0111 != 0 {
+			return info.Mode().Perm() | 0200, nil
+		}
+	}
+	return Default, nil
+}
+
+// WriteFile writes content to path with the mode chosen by Resolve, honoring the read_only
+// setting. The content is written to a temporary file that replaces path, so an existing
+// read-only file is never left half-written.
+func WriteFile(path string, content []byte, depMode string, settings Settings) error {
+	mode, err := Resolve(depMode, settings.FileMode, path)
+	if err != nil {
+		return err
+	}
+	if err := writeReplacing(path, content, mode, settings.ReadOnly); err != nil {
+		return ExplainPermissionError(err, path)
+	}
+	return nil
+}
+
+// writeReplacing writes content to a temporary file with mode (subject to the umask),
+// optionally strips its write bits, and renames it over path. Inside a project the temporary
+// file lives in the staging directory; elsewhere, or if the rename from there fails (e.g. the
+// destination is on another file system), it is created next to path.
+func writeReplacing(path string, content []byte, mode os.FileMode, readOnly bool) error {
+	if root, ok := staging.ProjectRoot(path); ok {
+		if tmpName, err := staging.Write(root, content, mode); err == nil {
+			if err := replaceWith(tmpName, path, readOnly); err == nil {
+				return nil
+			}
+		}
+	}
+	tmpName, err := writeSibling(path, content, mode)
+	if err != nil {
+		return err
+	}
+	return replaceWith(tmpName, path, readOnly)
+}
+
+// writeSibling writes content to a new hidden temporary file next to path (see
+// staging.SiblingName).
+func writeSibling(path string, content []byte, mode os.FileMode) (string, error) {
+	var tmp *os.File
+	var err error
+	for attempt := 0; attempt < 10; attempt++ {
+		tmp, err = os.OpenFile(staging.SiblingName(path, attempt), os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+		if !errors.Is(err, fs.ErrExist) {
+			break
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// replaceWith renames the complete temporary file tmpName over path, removing it on failure.
+func replaceWith(tmpName, path string, readOnly bool) error {
+	cleanup := func() { _ = os.Remove(tmpName) }
+	if readOnly {
+		if err := protect(tmpName); err != nil {
+			cleanup()
+			return err
+		}
+	}
+	// Windows refuses to replace a read-only file.
+	_ = unprotect(path)
+	if err := os.Rename(tmpName, path); err != nil {
+		cleanup()
+		return err
+	}
+	return nil
+}
+
+// protect removes the write bits from a file.
+func protect(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.Chmod(path, info.Mode().Perm()&^writeBits)
+}
+
+// unprotect gives the owner write permission on a file that lacks it.
+func unprotect(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Perm()&0200 != 0 {
+		return nil
+	}
+	return os.Chmod(path, info.Mode().Perm()|0200)
+}
+
+// Protect makes an existing dependency file read-only.
+func Protect(path string) error {
+	return protect(path)
+}
+
+// Unprotect makes a dependency file writable again, e.g. when almd stops managing it.
+func Unprotect(path string) error {
+	return unprotect(path)
+}
+
+// IsWritable reports whether the owner may write to the file at path.
+func IsWritable(info os.FileInfo) bool {
+	return info.Mode().Perm()&0200 != 0
+}
+
+// Restore puts back an earlier version of a file with exactly the given permissions, bypassing
+// the configured mode and read_only setting.
+func Restore(path string, content []byte, mode os.FileMode) error {
+	if err := writeReplacing(path, content, mode.Perm()|0200, false); err != nil {
+		return err
+	}
+	return os.Chmod(path, mode.Perm())
+}
+
+// Remove deletes a dependency file, lifting read-only protection first where the platform
+// requires it.
+func Remove(path string) error {
+	err := os.Remove(path)
+	if errors.Is(err, fs.ErrPermission) && unprotect(path) == nil {
+		err = os.Remove(path)
+	}
+	return err
+}
+
+// ExplainPermissionError adds guidance to permission errors raised while writing path, which
+// otherwise surface as a bare "permission denied".
+func ExplainPermissionError(err error, path string) error {
+	if !errors.Is(err, fs.ErrPermission) {
+		return err
+	}
+	return fmt.Errorf("%w: almd could not replace '%s'. Files protected by the read_only setting are unlocked automatically, "+
+		"so check that you own the file and can write to its directory; 'almd verify' reports the state of every vendored file", err, path)
+}
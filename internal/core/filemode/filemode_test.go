@@ -0,0 +1,162 @@
+package filemode
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/globalconfig"
+	"github.com/nightconcept/almandine/internal/core/paths"
+	"github.com/nightconcept/almandine/internal/core/staging"
+)
+
+func TestParse(t *testing.T) {
+	for input, want := range map[string]os.FileMode{"0755": 0755, "644": 0644, "0o600": 0600} {
+		mode, err := Parse(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, mode, input)
+	}
+	for _, input := range []string{"", "rwx", "0999", "01777", "abc"} {
+		_, err := Parse(input)
+		assert.Error(t, err, input)
+	}
+	assert.Equal(t, "0755", Format(0755))
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+
+	mode, err := Resolve("0755", "0600", filepath.Join(dir, "missing.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), mode, "the dependency mode wins")
+
+	mode, err = Resolve("", "0600", filepath.Join(dir, "missing.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), mode)
+
+	mode, err = Resolve("", "", filepath.Join(dir, "missing.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, Default, mode)
+
+	_, err = Resolve("bogus", "", "")
+	assert.Error(t, err)
+}
+
+func TestWriteFile_PreservesExecutableBit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no executable bit")
+	}
+	t.Setenv(paths.ConfigDirEnv, t.TempDir())
+
+	path := filepath.Join(t.TempDir(), "tool.lua")
+	require.NoError(t, os.WriteFile(path, []byte("old"), 0644))
+	require.NoError(t, os.Chmod(path, 0750))
+
+	require.NoError(t, WriteFile(path, []byte("new"), "", GlobalSettings()))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0750), info.Mode().Perm())
+
+	require.NoError(t, WriteFile(path, []byte("newer"), "0640", GlobalSettings()))
+	info, err = os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+}
+
+func TestWriteFile_GlobalDefault(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no Unix permission bits")
+	}
+	t.Setenv(paths.ConfigDirEnv, t.TempDir())
+	require.NoError(t, globalconfig.Save(&globalconfig.Config{FileMode: "0600"}))
+
+	path := filepath.Join(t.TempDir(), "lib.lua")
+	require.NoError(t, WriteFile(path, []byte("x"), "", GlobalSettings()))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestWriteFile_StagesInProject(t *testing.T) {
+	t.Setenv(paths.ConfigDirEnv, t.TempDir())
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "project.toml"), nil, 0644))
+	dir := filepath.Join(root, "src", "lib")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+
+	path := filepath.Join(dir, "dep.lua")
+	require.NoError(t, WriteFile(path, []byte("content"), "", GlobalSettings()))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(data))
+
+	siblings, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, siblings, 1, "no temporary file is created next to the destination")
+	staged, err := os.ReadDir(filepath.Join(root, staging.Dir))
+	require.NoError(t, err)
+	for _, entry := range staged {
+		assert.Equal(t, ".gitignore", entry.Name(), "the staged file was moved into place")
+	}
+}
+
+func TestWriteFile_ReadOnly(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no Unix permission bits")
+	}
+	t.Setenv(paths.ConfigDirEnv, t.TempDir())
+	require.NoError(t, globalconfig.Save(&globalconfig.Config{ReadOnly: true}))
+
+	path := filepath.Join(t.TempDir(), "lib.lua")
+	require.NoError(t, WriteFile(path, []byte("v1"), "", GlobalSettings()))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.False(t, IsWritable(info), "files are written read-only")
+
+	require.NoError(t, WriteFile(path, []byte("v2"), "", GlobalSettings()), "reinstalling lifts the protection")
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", string(content))
+	info, err = os.Stat(path)
+	require.NoError(t, err)
+	assert.False(t, IsWritable(info))
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary files are left behind")
+
+	require.NoError(t, Remove(path))
+	assert.NoFileExists(t, path)
+}
+
+func TestRestore(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no Unix permission bits")
+	}
+	t.Setenv(paths.ConfigDirEnv, t.TempDir())
+	require.NoError(t, globalconfig.Save(&globalconfig.Config{ReadOnly: true}))
+
+	path := filepath.Join(t.TempDir(), "tool.sh")
+	require.NoError(t, WriteFile(path, []byte("new"), "0755", GlobalSettings()))
+	require.NoError(t, Restore(path, []byte("old"), 0640))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(content))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm(), "the saved mode wins over mode and read_only settings")
+}
+
+func TestExplainPermissionError(t *testing.T) {
+	err := ExplainPermissionError(&os.PathError{Op: "open", Path: "x", Err: os.ErrPermission}, "src/lib/x.lua")
+	assert.ErrorIs(t, err, os.ErrPermission)
+	assert.Contains(t, err.Error(), "almd verify")
+
+	plain := os.ErrNotExist
+	assert.Equal(t, plain, ExplainPermissionError(plain, "x"))
+}
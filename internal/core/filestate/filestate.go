@@ -0,0 +1,149 @@
+// Package filestate remembers the size, modification time and hash of installed dependency
+// files so that 'almd verify' and 'almd install' can skip re-hashing files that have not
+// changed since they were last hashed.
+//
+// Records are kept per project in the state directory (see the paths package). A record is
+// only trusted while the file's size and modification time still match, and never for files
+// modified within racyWindow of being recorded, since a later edit in the same clock tick would
+// go unnoticed. Hashes are computed over the file content without a vendor header, which is
+// what the lockfile describes.
+package filestate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/nightconcept/almandine/internal/core/hasher"
+	"github.com/nightconcept/almandine/internal/core/paths"
+	"github.com/nightconcept/almandine/internal/core/vendorheader"
+)
+
+// racyWindow is how long after a file's modification time a record must have been made to be
+// trusted. It covers filesystems with coarse timestamps.
+const racyWindow = 2 * time.Second
+
+// record is the remembered state of one file.
+type record struct {
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mtime"`
+	Hash     string    `json:"hash"`
+	Recorded time.Time `json:"recorded"`
+}
+
+// Cache holds the records for one project. Its methods are safe for concurrent use.
+type Cache struct {
+	path string // Empty when records cannot be persisted
+
+	mu      sync.Mutex
+	records map[string]record
+	dirty   bool
+}
+
+// Load returns the records for the project at projectRoot. Loading is best effort: when the
+// state directory is unavailable or the records are unreadable, an empty cache is returned.
+func Load(projectRoot string) *Cache {
+	c := &Cache{records: make(map[string]record)}
+	stateDir, err := paths.StateDir()
+	if err != nil {
+		return c
+	}
+	absRoot, err := filepath.Abs(projectRoot)
+	if err != nil {
+		return c
+	}
+	sum := sha256.Sum256([]byte(absRoot))
+	c.path = filepath.Join(stateDir, "filestate", hex.EncodeToString(sum[:8])+".json")
+
+	if data, readErr := os.ReadFile(c.path); readErr == nil {
+		if json.Unmarshal(data, &c.records) != nil || c.records == nil {
+			c.records = make(map[string]record)
+		}
+	}
+	return c
+}
+
+// Lookup returns the remembered hash of the file at path if info shows it is unchanged.
+func (c *Cache) Lookup(path string, info os.FileInfo) (string, bool) {
+	c.mu.Lock()
+	r, ok := c.records[filepath.ToSlash(path)]
+	c.mu.Unlock()
+	if !ok || r.Size != info.Size() || !r.ModTime.Equal(info.ModTime()) {
+		return "", false
+	}
+	if r.Recorded.Sub(r.ModTime) < racyWindow {
+		return "", false
+	}
+	return r.Hash, true
+}
+
+// Record remembers hash for the file at path as described by info.
+func (c *Cache) Record(path string, info os.FileInfo, hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.records[filepath.ToSlash(path)] = record{Size: info.Size(), ModTime: info.ModTime(), Hash: hash, Recorded: time.Now()}
+	c.dirty = true
+}
+
+// HashFile returns the "sha256:<hex>" hash of the file at path without its vendor header,
+// reading the file only when the remembered state is missing or out of date. fresh reports
+// whether the file was hashed. Errors from reading the file are returned unwrapped so callers
+// can test them with errors.Is.
+func (c *Cache) HashFile(path string) (hash string, fresh bool, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false, err
+	}
+	if hash, ok := c.Lookup(path, info); ok {
+		return hash, false, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, err
+	}
+	hash, err = hasher.CalculateSHA256(vendorheader.Strip(content))
+	if err != nil {
+		return "", false, err
+	}
+	c.Record(path, info, hash)
+	return hash, true, nil
+}
+
+// Clear drops every record, so each file is hashed again.
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.records = make(map[string]record)
+	c.dirty = true
+}
+
+// Save writes the records back to the state directory if they changed.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty || c.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(c.records)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("creating state directory: %w", err)
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("writing file state: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("writing file state: %w", err)
+	}
+	c.dirty = false
+	return nil
+}
@@ -0,0 +1,84 @@
+package filestate_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/filestate"
+	"github.com/nightconcept/almandine/internal/core/paths"
+)
+
+const helloHash = "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+func writeOld(t *testing.T, path, content string, age time.Duration) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	old := time.Now().Add(-age)
+	require.NoError(t, os.Chtimes(path, old, old))
+}
+
+func TestHashFile_ReusesRecordsAcrossLoads(t *testing.T) {
+	t.Setenv(paths.StateDirEnv, t.TempDir())
+	projectRoot := t.TempDir()
+	path := filepath.Join(projectRoot, "lib.lua")
+	writeOld(t, path, "hello", time.Hour)
+
+	states := filestate.Load(projectRoot)
+	hash, fresh, err := states.HashFile(path)
+	require.NoError(t, err)
+	assert.True(t, fresh)
+	assert.Equal(t, helloHash, hash)
+	require.NoError(t, states.Save())
+
+	reloaded := filestate.Load(projectRoot)
+	hash, fresh, err = reloaded.HashFile(path)
+	require.NoError(t, err)
+	assert.False(t, fresh, "an unchanged file is not read again")
+	assert.Equal(t, helloHash, hash)
+
+	writeOld(t, path, "hello, world", time.Hour)
+	_, fresh, err = reloaded.HashFile(path)
+	require.NoError(t, err)
+	assert.True(t, fresh, "a size change invalidates the record")
+
+	reloaded.Clear()
+	_, fresh, err = reloaded.HashFile(path)
+	require.NoError(t, err)
+	assert.True(t, fresh)
+}
+
+func TestHashFile_DoesNotTrustRacyRecords(t *testing.T) {
+	t.Setenv(paths.StateDirEnv, t.TempDir())
+	path := filepath.Join(t.TempDir(), "lib.lua")
+	require.NoError(t, os.WriteFile(path, []byte("hello"), 0644))
+
+	states := filestate.Load(filepath.Dir(path))
+	_, fresh, err := states.HashFile(path)
+	require.NoError(t, err)
+	assert.True(t, fresh)
+
+	_, fresh, err = states.HashFile(path)
+	require.NoError(t, err)
+	assert.True(t, fresh, "a file modified just before it was recorded is hashed again")
+}
+
+func TestHashFile_StripsVendorHeader(t *testing.T) {
+	t.Setenv(paths.StateDirEnv, t.TempDir())
+	path := filepath.Join(t.TempDir(), "lib.lua")
+	writeOld(t, path, "-- almd:vendor-header begin\n-- source: s\n-- almd:vendor-header end\nhello", time.Hour)
+
+	hash, _, err := filestate.Load(filepath.Dir(path)).HashFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, helloHash, hash)
+}
+
+func TestHashFile_MissingFile(t *testing.T) {
+	t.Setenv(paths.StateDirEnv, t.TempDir())
+	_, _, err := filestate.Load(t.TempDir()).HashFile(filepath.Join(t.TempDir(), "missing.lua"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,231 @@
+// Package globalconfig loads and saves the user-level almd configuration, a config.toml file in
+// the config directory (see the paths package) holding settings that apply to every project.
+package globalconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/nightconcept/almandine/internal/core/credentials"
+	"github.com/nightconcept/almandine/internal/core/httpclient"
+	"github.com/nightconcept/almandine/internal/core/paths"
+	"github.com/nightconcept/almandine/internal/core/theme"
+)
+
+// FileName is the name of the global configuration file inside the config directory.
+const FileName = "config.toml"
+
+// GitHubTokenEnv names the environment variable that supplies a GitHub token. It takes
+// precedence over the token stored in the configuration file.
+const GitHubTokenEnv = "GITHUB_TOKEN"
+
+// GitHubTokensEnv names the environment variable that supplies extra GitHub tokens for the
+// token pool, separated by commas or whitespace (e.g. from CI secrets).
+const GitHubTokensEnv = "ALMD_GITHUB_TOKENS"
+
+// GitHubHost is the host GitHub tokens are stored for in the credential helper.
+const GitHubHost = "github.com"
+
+// Where GitHubTokenSource found the token.
+const (
+	TokenFromEnv        = "$" + GitHubTokenEnv
+	TokenFromHelper     = "git credential helper"
+	TokenFromConfigFile = FileName
+)
+
+// Accepted values for Config.Color.
+const (
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+// Config is the content of the global configuration file. Zero values mean "use the default".
+type Config struct {
+	GitHubToken string `toml:"github_token,omitempty"` // Token sent with GitHub API requests
+	// CredentialHelper reads the GitHub token from git's credential helper ('almd token set').
+	CredentialHelper bool   `toml:"credential_helper,omitempty"`
+	LibDir           string `toml:"lib_dir,omitempty"`   // Default target directory for 'add'
+	Color            string `toml:"color,omitempty"`     // One of ColorAuto, ColorAlways or ColorNever
+	FileMode         string `toml:"file_mode,omitempty"` // Octal mode for written dependency files, e.g. "0644"
+	ReadOnly         bool   `toml:"read_only,omitempty"` // Write dependency files read-only to discourage local edits
+	Telemetry        bool   `toml:"telemetry"`           // Recorded consent; almd currently sends no telemetry
+	// TokenPool names extra GitHub tokens stored in git's credential helper ('almd token set
+	// --pool NAME'). API requests rotate through them; the tokens themselves never live here.
+	TokenPool []string `toml:"token_pool,omitempty"`
+	// DefaultExt is the extension 'add' gives a file whose source path has none ("none" for no extension).
+	DefaultExt string `toml:"default_ext,omitempty"`
+	// APICacheMinutes keeps GitHub API responses between runs for that many minutes.
+	APICacheMinutes int `toml:"api_cache_minutes,omitempty"`
+	// TLSPins pins the public keys a host's certificate must have, e.g. "mirror.example.com" =
+	// ["sha256/<base64>"]; see httpclient.SetPins.
+	TLSPins map[string][]string `toml:"tls_pins,omitempty"`
+
+	Theme  string            `toml:"theme,omitempty"`  // Color preset, see the theme package
+	Colors map[string]string `toml:"colors,omitempty"` // Per-element color overrides, e.g. "dep.hash" = "red bold"
+}
+
+// Path returns the location of the global configuration file.
+func Path() (string, error) {
+	dir, err := paths.ConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("determining config directory: %w", err)
+	}
+	return filepath.Join(dir, FileName), nil
+}
+
+// Exists reports whether the global configuration file has been created.
+func Exists() bool {
+	path, err := Path()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// Load reads the global configuration. A missing file yields an empty Config and no error.
+func Load() (*Config, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	var cfg Config
+	if err := toml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if err := ValidateColor(cfg.Color); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	if err := theme.Validate(cfg.Theme, cfg.Colors); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	if cfg.APICacheMinutes < 0 {
+		return nil, fmt.Errorf("invalid %s: api_cache_minutes must not be negative", path)
+	}
+	if err := httpclient.ValidatePins(cfg.TLSPins); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Save writes cfg to the global configuration file, creating the config directory if needed.
+// The file is only readable by the current user because it may contain a token.
+func Save(cfg *Config) error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating config directory: %w", err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(cfg); err != nil {
+		return fmt.Errorf("encoding global config: %w", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// ValidateColor checks that value is a supported color mode. The empty string is accepted as auto.
+func ValidateColor(value string) error {
+	switch value {
+	case "", ColorAuto, ColorAlways, ColorNever:
+		return nil
+	default:
+		return fmt.Errorf("color must be one of %s, %s or %s, got '%s'", ColorAuto, ColorAlways, ColorNever, value)
+	}
+}
+
+// GitHubToken returns the token to authenticate GitHub API requests with, preferring
+// $GITHUB_TOKEN over the configuration. It returns "" when none is set or the configuration
+// cannot be read.
+func GitHubToken() string {
+	token, _ := GitHubTokenSource()
+	return token
+}
+
+// GitHubTokenSource returns the GitHub token and where it came from: $GITHUB_TOKEN, the git
+// credential helper when credential_helper is set, or the github_token setting, in that order.
+func GitHubTokenSource() (token, from string) {
+	if token := os.Getenv(GitHubTokenEnv); token != "" {
+		return token, TokenFromEnv
+	}
+	cfg, err := Load()
+	if err != nil {
+		return "", ""
+	}
+	if cfg.CredentialHelper {
+		if token, err := credentials.Lookup(GitHubHost); err == nil {
+			return token, TokenFromHelper
+		}
+	}
+	if cfg.GitHubToken != "" {
+		return cfg.GitHubToken, TokenFromConfigFile
+	}
+	return "", ""
+}
+
+// PoolUsername is the credential helper username a pool token called name is stored under.
+func PoolUsername(name string) string {
+	return credentials.Username + "-pool-" + name
+}
+
+// ValidatePoolName checks that name can label a pool token.
+func ValidatePoolName(name string) error {
+	if name == "" {
+		return fmt.Errorf("pool token name is empty")
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return fmt.Errorf("pool token name '%s' may only contain letters, digits, '-' and '_'", name)
+		}
+	}
+	return nil
+}
+
+// GitHubTokens returns every token GitHub API requests may rotate through: the token from
+// GitHubToken first, then the tokens in $ALMD_GITHUB_TOKENS, then the pool tokens named in the
+// configuration. Duplicates and pool tokens the credential helper no longer has are left out.
+func GitHubTokens() []string {
+	var tokens []string
+	seen := map[string]bool{}
+	add := func(token string) {
+		if token != "" && !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+	add(GitHubToken())
+	for _, token := range strings.FieldsFunc(os.Getenv(GitHubTokensEnv), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}) {
+		add(token)
+	}
+	if cfg, err := Load(); err == nil {
+		for _, name := range cfg.TokenPool {
+			if token, err := credentials.LookupAs(GitHubHost, PoolUsername(name)); err == nil {
+				add(token)
+			}
+		}
+	}
+	return tokens
+}
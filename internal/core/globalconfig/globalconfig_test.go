@@ -0,0 +1,94 @@
+package globalconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/paths"
+)
+
+func setConfigDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv(paths.ConfigDirEnv, dir)
+	t.Setenv(GitHubTokenEnv, "")
+	return dir
+}
+
+func TestLoad_MissingFile(t *testing.T) {
+	setConfigDir(t)
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, &Config{}, cfg)
+	assert.False(t, Exists())
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	dir := setConfigDir(t)
+	want := &Config{GitHubToken: "ghp_example", LibDir: "vendor/lua", Color: ColorNever, Telemetry: true}
+	require.NoError(t, Save(want))
+	assert.True(t, Exists())
+
+	info, err := os.Stat(filepath.Join(dir, FileName))
+	require.NoError(t, err)
+	if filepath.Separator == '/' {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+
+	got, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestLoad_InvalidColor(t *testing.T) {
+	dir := setConfigDir(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(`color = "rainbow"`), 0600))
+	_, err := Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "color must be one of")
+}
+
+func TestLoad_TLSPins(t *testing.T) {
+	dir := setConfigDir(t)
+	pin := "sha256/" + strings.Repeat("A", 43) + "="
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte("[tls_pins]\n\"mirror.example.com\" = [\""+pin+"\"]\n"), 0600))
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"mirror.example.com": {pin}}, cfg.TLSPins)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte("[tls_pins]\n\"mirror.example.com\" = [\"md5/abc\"]\n"), 0600))
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not a sha256/<base64 SHA-256> public key pin")
+}
+
+func TestGitHubToken(t *testing.T) {
+	setConfigDir(t)
+	assert.Equal(t, "", GitHubToken())
+
+	require.NoError(t, Save(&Config{GitHubToken: "from-config"}))
+	assert.Equal(t, "from-config", GitHubToken())
+
+	t.Setenv(GitHubTokenEnv, "from-env")
+	assert.Equal(t, "from-env", GitHubToken())
+}
+
+func TestLoad_Theme(t *testing.T) {
+	dir := setConfigDir(t)
+	path := filepath.Join(dir, FileName)
+	require.NoError(t, os.WriteFile(path, []byte("theme = \"monochrome\"\n\n[colors]\n\"dep.hash\" = \"red bold\"\n"), 0600))
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "monochrome", cfg.Theme)
+	assert.Equal(t, map[string]string{"dep.hash": "red bold"}, cfg.Colors)
+
+	require.NoError(t, os.WriteFile(path, []byte("[colors]\n\"dep.hash\" = \"plaid\"\n"), 0600))
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown color or attribute 'plaid'")
+}
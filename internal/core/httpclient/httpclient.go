@@ -0,0 +1,135 @@
+// Package httpclient provides the HTTP transport shared by every network call almd makes.
+//
+// Using one transport lets bulk installs reuse pooled (and, over TLS, HTTP/2) connections to
+// hosts such as raw.githubusercontent.com instead of paying for a new TLS handshake per file,
+// while a per-host connection cap keeps parallel work from overwhelming a single host. Proxies
+// are taken from the usual HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables, and extra trusted CA
+// certificates (e.g. for a TLS-intercepting corporate proxy) from $ALMD_CA_CERTS. Public keys
+// can be pinned per host with SetPins.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nightconcept/almandine/internal/core/warnings"
+)
+
+// MaxConnsPerHostEnv overrides the number of concurrent connections opened to a single host.
+const MaxConnsPerHostEnv = "ALMD_MAX_CONNS_PER_HOST"
+
+// CACertsEnv names a PEM file of CA certificates to trust in addition to the system roots.
+const CACertsEnv = "ALMD_CA_CERTS"
+
+// DefaultMaxConnsPerHost is the per-host connection cap used when no override is configured.
+const DefaultMaxConnsPerHost = 8
+
+// APITimeout bounds calls to metadata APIs, which should answer quickly.
+const APITimeout = 10 * time.Second
+
+var (
+	transportOnce sync.Once
+	transport     *http.Transport
+)
+
+// newTransport builds a transport tuned for many small downloads from a handful of hosts.
+func newTransport(maxConnsPerHost int) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   maxConnsPerHost,
+		MaxConnsPerHost:       maxConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// rootCAsFromEnv returns the system roots plus the certificates in $ALMD_CA_CERTS, or nil (use
+// the system roots) when the variable is unset.
+func rootCAsFromEnv() (*x509.CertPool, error) {
+	path := os.Getenv(CACertsEnv)
+	if path == "" {
+		return nil, nil
+	}
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", CACertsEnv, err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s file '%s' contains no PEM certificates", CACertsEnv, path)
+	}
+	return pool, nil
+}
+
+// maxConnsPerHostFromEnv reads the per-host connection cap from the environment.
+func maxConnsPerHostFromEnv() (int, error) {
+	raw := os.Getenv(MaxConnsPerHostEnv)
+	if raw == "" {
+		return DefaultMaxConnsPerHost, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid %s value '%s': must be a positive integer", MaxConnsPerHostEnv, raw)
+	}
+	return n, nil
+}
+
+// Transport returns the process-wide shared transport. An invalid per-host override in the
+// environment is reported once and the default cap is used instead; unusable extra CA
+// certificates are reported and only the system roots are trusted. Connections to hosts with
+// pinned public keys (see SetPins) are checked against the pins.
+func Transport() *http.Transport {
+	transportOnce.Do(func() {
+		maxConns, err := maxConnsPerHostFromEnv()
+		if err != nil {
+			maxConns = DefaultMaxConnsPerHost
+			warnings.Printf("%v. Using %d.", err, DefaultMaxConnsPerHost)
+		}
+		transport = newTransport(maxConns)
+		roots, err := rootCAsFromEnv()
+		if err != nil {
+			warnings.Printf("%v. Using the system certificates only.", err)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots, VerifyConnection: verifyPins}
+	})
+	return transport
+}
+
+// Client returns a client using the shared transport with the given overall request timeout.
+// A zero timeout means no timeout, which suits downloads of arbitrary size. While a recording
+// or replay is active (see StartRecording and StartReplay) requests go through it instead, and
+// while tracing (see StartTrace) each request is logged, and while counting (see
+// StartCounting) it is counted.
+func Client(timeout time.Duration) *http.Client {
+	rt := currentRoundTripper()
+	if countingOn.Load() {
+		rt = &counter{next: rt}
+	}
+	if tracing() {
+		rt = &tracer{next: rt}
+	}
+	return &http.Client{Transport: rt, Timeout: timeout}
+}
+
+// CloseIdleConnections releases pooled connections, e.g. once a command has finished its network work.
+func CloseIdleConnections() {
+	Transport().CloseIdleConnections()
+}
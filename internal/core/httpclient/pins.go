@@ -0,0 +1,72 @@
+package httpclient
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// pinPrefix starts every pin: the base64 SHA-256 digest of a certificate's SubjectPublicKeyInfo,
+// as used by HPKP and curl's --pinnedpubkey.
+const pinPrefix = "sha256/"
+
+var (
+	pinsMu sync.RWMutex
+	pins   map[string][]string // Lowercase host -> accepted pins
+)
+
+// ValidatePins checks that every pin has the form "sha256/<base64 of 32 bytes>".
+func ValidatePins(hostPins map[string][]string) error {
+	for host, list := range hostPins {
+		if len(list) == 0 {
+			return fmt.Errorf("tls_pins for '%s' lists no pins", host)
+		}
+		for _, pin := range list {
+			digest, ok := strings.CutPrefix(pin, pinPrefix)
+			if decoded, err := base64.StdEncoding.DecodeString(digest); !ok || err != nil || len(decoded) != sha256.Size {
+				return fmt.Errorf("tls_pins for '%s': '%s' is not a %s<base64 SHA-256> public key pin", host, pin, pinPrefix)
+			}
+		}
+	}
+	return nil
+}
+
+// SetPins makes TLS connections to each host in hostPins fail unless a certificate the host
+// presents has one of the host's pinned public keys. Pinning an internal mirror's key protects
+// its downloads even from a compromised certificate authority. Hosts without pins are only
+// verified the usual way; nil removes every pin.
+func SetPins(hostPins map[string][]string) {
+	normalized := make(map[string][]string, len(hostPins))
+	for host, list := range hostPins {
+		normalized[strings.ToLower(host)] = list
+	}
+	pinsMu.Lock()
+	defer pinsMu.Unlock()
+	pins = normalized
+}
+
+// verifyPins is the shared transport's tls.Config.VerifyConnection. It runs after the chain has
+// been verified against the trusted roots.
+func verifyPins(cs tls.ConnectionState) error {
+	pinsMu.RLock()
+	want := pins[strings.ToLower(cs.ServerName)]
+	pinsMu.RUnlock()
+	if len(want) == 0 {
+		return nil
+	}
+	var got []string
+	for _, cert := range cs.PeerCertificates {
+		digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		pin := pinPrefix + base64.StdEncoding.EncodeToString(digest[:])
+		for _, accepted := range want {
+			if pin == accepted {
+				return nil
+			}
+		}
+		got = append(got, pin)
+	}
+	return fmt.Errorf("certificate of %s matches none of its pinned public keys (presented %s)", cs.ServerName, strings.Join(got, ", "))
+}
@@ -0,0 +1,90 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// redacted replaces credentials removed by RedactURL and RedactHeader.
+const redacted = "REDACTED"
+
+// sensitiveParams are query parameters that carry credentials, such as the token GitHub appends
+// to raw links of private repositories. Any parameter whose name contains "token" counts too.
+var sensitiveParams = map[string]bool{
+	"access_token": true,
+	"api_key":      true,
+	"apikey":       true,
+	"auth":         true,
+	"key":          true,
+	"password":     true,
+	"secret":       true,
+	"sig":          true,
+	"signature":    true,
+}
+
+// sensitiveHeaders are headers whose values are credentials. Any header whose name contains
+// "token" counts too.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Cookie":              true,
+	"Proxy-Authorization": true,
+	"Set-Cookie":          true,
+}
+
+// RedactURL returns rawURL with the values of credential query parameters and any password in
+// its user info replaced, so it can be logged or stored. URLs that do not parse are returned
+// without their query and user info.
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		rawURL, _, _ = strings.Cut(rawURL, "?")
+		if scheme, rest, ok := strings.Cut(rawURL, "://"); ok {
+			if _, host, ok := strings.Cut(rest, "@"); ok {
+				return scheme + "://" + host
+			}
+		}
+		return rawURL
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		changed := false
+		for name, values := range query {
+			if !isSensitive(name, sensitiveParams) {
+				continue
+			}
+			for i := range values {
+				values[i] = redacted
+			}
+			changed = true
+		}
+		if changed {
+			u.RawQuery = query.Encode()
+		}
+	}
+	return u.String()
+}
+
+// RedactHeader returns a copy of h with the values of credential headers replaced.
+func RedactHeader(h http.Header) http.Header {
+	if h == nil {
+		return nil
+	}
+	out := h.Clone()
+	for name, values := range out {
+		if !isSensitive(name, sensitiveHeaders) {
+			continue
+		}
+		for i := range values {
+			values[i] = redacted
+		}
+	}
+	return out
+}
+
+func isSensitive(name string, known map[string]bool) bool {
+	return known[name] || known[strings.ToLower(name)] || strings.Contains(strings.ToLower(name), "token")
+}
@@ -0,0 +1,200 @@
+package httpclient
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// interaction is one recorded request/response pair as stored on disk. Request headers are not
+// recorded, and credentials in the URL and response headers are redacted (see RedactURL), so
+// tokens sent to the GitHub API or embedded in private raw links never end up in a bundle.
+type interaction struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+var (
+	roundTripperMu sync.RWMutex
+	roundTripper   http.RoundTripper // nil means the shared Transport
+)
+
+// currentRoundTripper returns the round tripper used by new clients.
+func currentRoundTripper() http.RoundTripper {
+	roundTripperMu.RLock()
+	defer roundTripperMu.RUnlock()
+	if roundTripper != nil {
+		return roundTripper
+	}
+	return Transport()
+}
+
+func setRoundTripper(rt http.RoundTripper) {
+	roundTripperMu.Lock()
+	roundTripper = rt
+	roundTripperMu.Unlock()
+}
+
+// StartRecording saves every request made through Client, with its response, as a JSON file
+// in dir so the run can be replayed later with StartReplay. dir is created if needed and must
+// not already contain a recording.
+func StartRecording(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating recording directory: %w", err)
+	}
+	existing, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return fmt.Errorf("recording directory %s already contains a recording; choose an empty directory", dir)
+	}
+	setRoundTripper(&recorder{dir: dir, next: Transport(), seen: map[string]int{}})
+	return nil
+}
+
+// StartReplay answers every request made through Client from a recording made with
+// StartRecording instead of the network. Requests are matched by method and URL; repeated
+// requests are answered in recorded order, and the last answer is reused once they run out.
+func StartReplay(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("opening replay directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("replay path %s is not a directory", dir)
+	}
+	setRoundTripper(&replayer{dir: dir, served: map[string]int{}})
+	return nil
+}
+
+// StopRecordingOrReplay makes clients use the network directly again.
+func StopRecordingOrReplay() {
+	setRoundTripper(nil)
+}
+
+// interactionKey names the recordings of a request. url is the redacted URL, so recordings
+// and replays agree however credentials change between runs.
+func interactionKey(method, url string) string {
+	sum := sha256.Sum256([]byte(method + " " + url))
+	return hex.EncodeToString(sum[:8])
+}
+
+func interactionPath(dir, key string, n int) string {
+	return filepath.Join(dir, fmt.Sprintf("%s-%03d.json", key, n))
+}
+
+// recorder is a RoundTripper that stores each exchange before handing it back.
+type recorder struct {
+	dir  string
+	next http.RoundTripper
+
+	mu   sync.Mutex
+	seen map[string]int
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := interaction{Method: req.Method, URL: RedactURL(req.URL.String())}
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		rec.Error = err.Error()
+		return nil, r.save(rec, err)
+	}
+
+	body, readErr := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if readErr != nil {
+		rec.Error = readErr.Error()
+		return nil, r.save(rec, readErr)
+	}
+	rec.Status, rec.Header, rec.Body = resp.StatusCode, RedactHeader(resp.Header), body
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, r.save(rec, nil)
+}
+
+// save writes rec and returns cause, or the write error if the recording failed.
+func (r *recorder) save(rec interaction, cause error) error {
+	key := interactionKey(rec.Method, rec.URL)
+	r.mu.Lock()
+	r.seen[key]++
+	n := r.seen[key]
+	r.mu.Unlock()
+
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err == nil {
+		err = os.WriteFile(interactionPath(r.dir, key, n), data, 0644)
+	}
+	if err != nil {
+		return fmt.Errorf("recording %s %s: %w", rec.Method, rec.URL, err)
+	}
+	return cause
+}
+
+// replayer is a RoundTripper that answers from recorded exchanges.
+type replayer struct {
+	dir string
+
+	mu     sync.Mutex
+	served map[string]int
+}
+
+func (r *replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+	rec, err := r.next(req.Method, RedactURL(req.URL.String()))
+	if err != nil {
+		return nil, err
+	}
+	if rec.Error != "" {
+		return nil, errors.New(rec.Error + " (replayed)")
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.Status, http.StatusText(rec.Status)),
+		StatusCode:    rec.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rec.Header,
+		Body:          io.NopCloser(bytes.NewReader(rec.Body)),
+		ContentLength: int64(len(rec.Body)),
+		Request:       req,
+	}, nil
+}
+
+// next loads the recording for the next occurrence of a request.
+func (r *replayer) next(method, url string) (*interaction, error) {
+	key := interactionKey(method, url)
+	r.mu.Lock()
+	n := r.served[key] + 1
+	if _, err := os.Stat(interactionPath(r.dir, key, n)); err != nil && n > 1 {
+		n-- // Reuse the last recorded answer
+	}
+	r.served[key] = n
+	r.mu.Unlock()
+
+	data, err := os.ReadFile(interactionPath(r.dir, key, n))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no recorded response for %s %s in %s", method, url, r.dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading recorded response for %s %s: %w", method, url, err)
+	}
+	var rec interaction
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("parsing recorded response %s: %w", interactionPath(r.dir, key, n), err)
+	}
+	return &rec, nil
+}
@@ -0,0 +1,83 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// Usage counts the network work done through Client while counting (see StartCounting) and
+// the download cache lookups reported with TraceCache.
+type Usage struct {
+	Requests    int64 // HTTP requests sent, including failed ones
+	Bytes       int64 // response body bytes read
+	CacheHits   int64
+	CacheMisses int64
+}
+
+var (
+	countingOn atomic.Bool
+	usage      struct {
+		requests, bytes, cacheHits, cacheMisses atomic.Int64
+	}
+)
+
+// StartCounting makes clients returned by Client count their requests and response bytes in
+// CurrentUsage from now on. Clients are otherwise left unwrapped.
+func StartCounting() {
+	countingOn.Store(true)
+}
+
+// CurrentUsage returns the usage counted since the process started. A command that wants the
+// usage of one run takes a snapshot before it and subtracts it afterwards with Sub.
+func CurrentUsage() Usage {
+	return Usage{
+		Requests:    usage.requests.Load(),
+		Bytes:       usage.bytes.Load(),
+		CacheHits:   usage.cacheHits.Load(),
+		CacheMisses: usage.cacheMisses.Load(),
+	}
+}
+
+// Sub returns the usage counted between the snapshot before and u.
+func (u Usage) Sub(before Usage) Usage {
+	return Usage{
+		Requests:    u.Requests - before.Requests,
+		Bytes:       u.Bytes - before.Bytes,
+		CacheHits:   u.CacheHits - before.CacheHits,
+		CacheMisses: u.CacheMisses - before.CacheMisses,
+	}
+}
+
+func countCacheLookup(hit bool) {
+	if hit {
+		usage.cacheHits.Add(1)
+	} else {
+		usage.cacheMisses.Add(1)
+	}
+}
+
+// counter is a RoundTripper that counts requests and the response bytes read from them.
+type counter struct {
+	next http.RoundTripper
+}
+
+func (c *counter) RoundTrip(req *http.Request) (*http.Response, error) {
+	usage.requests.Add(1)
+	resp, err := c.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &countingBody{ReadCloser: resp.Body}
+	return resp, nil
+}
+
+type countingBody struct {
+	io.ReadCloser
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	usage.bytes.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,81 @@
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	traceMu  sync.Mutex
+	traceOut io.Writer // nil disables tracing
+)
+
+// StartTrace writes one line to w for every request made through Client (method, URL, status,
+// duration, and the GitHub rate limit left when the response reports it) and for every
+// download cache lookup reported with TraceCache. Request headers are never written and URLs
+// are passed through RedactURL, so tokens stay out of the trace.
+func StartTrace(w io.Writer) {
+	traceMu.Lock()
+	traceOut = w
+	traceMu.Unlock()
+}
+
+// StopTrace turns tracing off again.
+func StopTrace() {
+	StartTrace(nil)
+}
+
+// TraceCache records whether the download cache answered a lookup for url, in the trace and
+// in the cache counts of CurrentUsage.
+func TraceCache(url string, hit bool) {
+	countCacheLookup(hit)
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	tracef("cache %s %s", result, RedactURL(url))
+}
+
+func tracing() bool {
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	return traceOut != nil
+}
+
+func tracef(format string, args ...any) {
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	if traceOut != nil {
+		_, _ = fmt.Fprintf(traceOut, "[http] "+format+"\n", args...)
+	}
+}
+
+// tracer is a RoundTripper that logs each exchange once its response headers arrive.
+type tracer struct {
+	next http.RoundTripper
+}
+
+func (t *tracer) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := time.Since(start).Milliseconds()
+	target := RedactURL(req.URL.String())
+	if err != nil {
+		message := err.Error()
+		if raw := req.URL.String(); raw != target {
+			message = strings.ReplaceAll(message, raw, target) // Transport errors may quote the URL
+		}
+		tracef("%s %s error %dms: %s", req.Method, target, elapsed, message)
+		return nil, err
+	}
+	if remaining := resp.Header.Get("X-RateLimit-Remaining"); remaining != "" {
+		tracef("%s %s %d %dms (rate limit remaining %s)", req.Method, target, resp.StatusCode, elapsed, remaining)
+	} else {
+		tracef("%s %s %d %dms", req.Method, target, resp.StatusCode, elapsed)
+	}
+	return resp, nil
+}
@@ -0,0 +1,159 @@
+// Package inventory records which files in a project almd installed, in .almd/state/files.json,
+// so commands that delete dependency files leave alone files almd never wrote, even when a
+// dependency's path in project.toml points at one. Projects that have no inventory yet, because
+// they were set up by an older almd, are treated as before: every dependency path counts as
+// managed until a command records the first entry.
+package inventory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Path is where the inventory is kept, relative to the project root.
+const Path = ".almd/state/files.json"
+
+// document is the stored form of the inventory.
+type document struct {
+	Files []string `json:"files"` // Slash-separated paths relative to the project root, sorted
+}
+
+// Inventory is the set of files almd installed in one project.
+type Inventory struct {
+	root     string
+	files    map[string]bool
+	recorded bool // Whether the project has an inventory file
+}
+
+// Load reads the inventory of the project at projectRoot. A missing inventory is not an error.
+func Load(projectRoot string) (*Inventory, error) {
+	inv := &Inventory{root: projectRoot, files: make(map[string]bool)}
+	data, err := os.ReadFile(filepath.Join(projectRoot, filepath.FromSlash(Path)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return inv, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", Path, err)
+	}
+	for _, file := range doc.Files {
+		inv.files[normalize(file)] = true
+	}
+	inv.recorded = true
+	return inv, nil
+}
+
+// Manages reports whether almd installed the file at relPath. Without an inventory every file
+// counts as managed.
+func (inv *Inventory) Manages(relPath string) bool {
+	return !inv.recorded || inv.files[normalize(relPath)]
+}
+
+// Add records files as installed by almd.
+func (inv *Inventory) Add(relPaths ...string) {
+	for _, p := range relPaths {
+		inv.files[normalize(p)] = true
+	}
+}
+
+// Forget drops files almd no longer manages.
+func (inv *Inventory) Forget(relPaths ...string) {
+	for _, p := range relPaths {
+		delete(inv.files, normalize(p))
+	}
+}
+
+// Save writes the inventory.
+func (inv *Inventory) Save() error {
+	doc := document{Files: make([]string, 0, len(inv.files))}
+	for file := range inv.files {
+		doc.Files = append(doc.Files, file)
+	}
+	slices.Sort(doc.Files)
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	full := filepath.Join(inv.root, filepath.FromSlash(Path))
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return fmt.Errorf("creating %s: %w", path.Dir(Path), err)
+	}
+	if err := os.WriteFile(full, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", Path, err)
+	}
+	inv.recorded = true
+	return nil
+}
+
+// Record adds files to the inventory of the project at projectRoot and saves it. When the
+// project has no inventory yet, the files at dependencyPaths, the paths project.toml declares,
+// are recorded too: they were installed by an older almd that kept no inventory.
+func Record(projectRoot string, dependencyPaths []string, relPaths ...string) error {
+	inv, err := Load(projectRoot)
+	if err != nil {
+		return err
+	}
+	if !inv.recorded {
+		for _, p := range dependencyPaths {
+			if _, statErr := os.Stat(filepath.Join(projectRoot, filepath.FromSlash(normalize(p)))); statErr == nil {
+				inv.Add(p)
+			}
+		}
+	}
+	inv.Add(relPaths...)
+	return inv.Save()
+}
+
+// Recorded reports whether the project has an inventory. Without one, which files almd installed
+// is not known; see Manages.
+func (inv *Inventory) Recorded() bool {
+	return inv.recorded
+}
+
+// Unmanaged returns those of relPaths at which the project already has a file that almd did not
+// install: the inventory does not list it and it is none of dependencyPaths, the paths the
+// caller knows to belong to dependencies. Writing a dependency there would overwrite a file of
+// the project's own.
+func (inv *Inventory) Unmanaged(dependencyPaths []string, relPaths ...string) []string {
+	known := make(map[string]bool, len(dependencyPaths))
+	for _, p := range dependencyPaths {
+		known[normalize(p)] = true
+	}
+	var unmanaged []string
+	for _, p := range relPaths {
+		if inv.files[normalize(p)] || known[normalize(p)] {
+			continue
+		}
+		info, err := os.Lstat(filepath.Join(inv.root, filepath.FromSlash(normalize(p))))
+		if err == nil && !info.IsDir() {
+			unmanaged = append(unmanaged, p)
+		}
+	}
+	return unmanaged
+}
+
+// Release drops files from the inventory of the project at projectRoot and saves it. A project
+// without an inventory is left without one.
+func Release(projectRoot string, relPaths ...string) error {
+	inv, err := Load(projectRoot)
+	if err != nil || !inv.recorded {
+		return err
+	}
+	inv.Forget(relPaths...)
+	return inv.Save()
+}
+
+// normalize returns relPath as a clean, slash-separated path.
+func normalize(relPath string) string {
+	return path.Clean(strings.ReplaceAll(relPath, `\`, "/"))
+}
@@ -0,0 +1,58 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventory(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "libs"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "libs", "old.lua"), []byte("return {}"), 0644))
+
+	inv, err := Load(root)
+	require.NoError(t, err)
+	assert.True(t, inv.Manages("libs/anything.lua"), "without an inventory every file counts as managed")
+
+	// The first record adopts the dependency files an older almd installed.
+	require.NoError(t, Record(root, []string{"libs/old.lua", "libs/gone.lua"}, `libs\new.lua`))
+	inv, err = Load(root)
+	require.NoError(t, err)
+	assert.True(t, inv.Manages("libs/old.lua"))
+	assert.True(t, inv.Manages("libs/new.lua"))
+	assert.False(t, inv.Manages("libs/gone.lua"), "only existing files are adopted")
+	assert.False(t, inv.Manages("libs/notes.lua"))
+
+	require.NoError(t, Release(root, "libs/./old.lua"))
+	inv, err = Load(root)
+	require.NoError(t, err)
+	assert.False(t, inv.Manages("libs/old.lua"))
+
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(Path)))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"files": ["libs/new.lua"]}`, string(data))
+}
+
+func TestUnmanaged(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "src", "lib"), 0755))
+	for _, file := range []string{"main.lua", "lib/dep.lua", "lib/known.lua"} {
+		require.NoError(t, os.WriteFile(filepath.Join(root, "src", filepath.FromSlash(file)), []byte("return {}"), 0644))
+	}
+
+	inv, err := Load(root)
+	require.NoError(t, err)
+	assert.False(t, inv.Recorded())
+	unmanaged := inv.Unmanaged([]string{"src/lib/known.lua"}, "src/main.lua", "src/lib/dep.lua", "src/lib/known.lua", "src/new.lua", "src/lib")
+	assert.Equal(t, []string{"src/main.lua", "src/lib/dep.lua"}, unmanaged, "without an inventory only the given dependency paths count as managed")
+
+	require.NoError(t, Record(root, nil, "src/lib/dep.lua"))
+	inv, err = Load(root)
+	require.NoError(t, err)
+	assert.True(t, inv.Recorded())
+	assert.Equal(t, []string{"src/main.lua"}, inv.Unmanaged(nil, "src/main.lua", "src/lib/dep.lua"))
+}
@@ -0,0 +1,72 @@
+// Package jobsummary appends Markdown to the GitHub Actions job summary, the file named by
+// $GITHUB_STEP_SUMMARY, so that commands run in a workflow show what they changed or found on
+// the run's page without extra workflow steps. Outside GitHub Actions nothing is written.
+package jobsummary
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Env names the job summary file in GitHub Actions.
+const Env = "GITHUB_STEP_SUMMARY"
+
+// Table is a titled Markdown table. Note, if set, is written as a paragraph below it; a table
+// without rows is left out so that only the title and note remain.
+type Table struct {
+	Title  string
+	Header []string
+	Rows   [][]string
+	Note   string
+}
+
+// Markdown renders the table. Pipes and line breaks in cells are escaped so they cannot break
+// the table's layout.
+func (t Table) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s\n\n", t.Title)
+	if len(t.Rows) > 0 {
+		b.WriteString(row(t.Header))
+		b.WriteString("|" + strings.Repeat(" --- |", len(t.Header)) + "\n")
+		for _, r := range t.Rows {
+			b.WriteString(row(r))
+		}
+		b.WriteString("\n")
+	}
+	if t.Note != "" {
+		b.WriteString(t.Note + "\n\n")
+	}
+	return b.String()
+}
+
+func row(cells []string) string {
+	escape := strings.NewReplacer("|", `\|`, "\r\n", " ", "\n", " ")
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		escaped[i] = escape.Replace(cell)
+	}
+	return "| " + strings.Join(escaped, " | ") + " |\n"
+}
+
+// Enabled reports whether a job summary file is set.
+func Enabled() bool {
+	return os.Getenv(Env) != ""
+}
+
+// Write appends t to the job summary. It does nothing outside GitHub Actions.
+func Write(t Table) error {
+	path := os.Getenv(Env)
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening job summary: %w", err)
+	}
+	if _, err := f.WriteString(t.Markdown()); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing job summary: %w", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,36 @@
+package jobsummary
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "summary.md")
+	t.Setenv(Env, path)
+	require.NoError(t, os.WriteFile(path, []byte("earlier step\n\n"), 0644))
+
+	require.NoError(t, Write(Table{
+		Title:  "almd install",
+		Header: []string{"Dependency", "Reason"},
+		Rows:   [][]string{{"json", "a|b\nc"}},
+		Note:   "1 dependency installed.",
+	}))
+	require.NoError(t, Write(Table{Title: "almd verify", Header: []string{"Dependency"}, Note: "All match."}))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "earlier step\n\n"+
+		"### almd install\n\n| Dependency | Reason |\n| --- | --- |\n| json | a\\|b c |\n\n1 dependency installed.\n\n"+
+		"### almd verify\n\nAll match.\n\n", string(content))
+}
+
+func TestWrite_OutsideActions(t *testing.T) {
+	t.Setenv(Env, "")
+	assert.False(t, Enabled())
+	assert.NoError(t, Write(Table{Title: "almd install"}))
+}
@@ -0,0 +1,105 @@
+// Package journal keeps an append-only record of supply-chain relevant events, such as a
+// dependency being moved to an older commit. Entries are stored as JSON lines in journal.jsonl
+// in the state directory (see the paths package) and are shared by every project on the machine.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nightconcept/almandine/internal/core/paths"
+)
+
+// FileName is the name of the journal in the state directory.
+const FileName = "journal.jsonl"
+
+// Events recorded in the journal.
+const (
+	DowngradeBlocked = "downgrade-blocked" // Install refused to move a dependency to an older commit
+	DowngradeAllowed = "downgrade-allowed" // Install moved a dependency to an older commit with --allow-downgrade
+)
+
+// Entry is one journal record.
+type Entry struct {
+	Time       time.Time `json:"time"`
+	Event      string    `json:"event"`
+	Project    string    `json:"project"` // Absolute project directory
+	Dependency string    `json:"dependency"`
+	From       string    `json:"from,omitempty"`
+	To         string    `json:"to,omitempty"`
+	Detail     string    `json:"detail,omitempty"`
+}
+
+// Record appends e to the journal. Time defaults to now and Project to the working directory.
+func Record(e Entry) error {
+	path, err := journalPath()
+	if err != nil {
+		return err
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if e.Project == "" {
+		if wd, wdErr := os.Getwd(); wdErr == nil {
+			e.Project = wd
+		}
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating journal directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("opening journal: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing journal: %w", err)
+	}
+	return f.Close()
+}
+
+// Read returns every journal entry, oldest first. A missing journal has no entries; lines that
+// cannot be parsed are skipped.
+func Read() ([]Entry, error) {
+	path, err := journalPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+func journalPath() (string, error) {
+	stateDir, err := paths.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, FileName), nil
+}
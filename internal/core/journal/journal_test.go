@@ -0,0 +1,40 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/paths"
+)
+
+func TestRecordAndRead(t *testing.T) {
+	stateDir := t.TempDir()
+	t.Setenv(paths.StateDirEnv, stateDir)
+
+	entries, err := Read()
+	require.NoError(t, err)
+	assert.Empty(t, entries, "a missing journal has no entries")
+
+	at := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, Record(Entry{Time: at, Event: DowngradeBlocked, Project: "/p", Dependency: "lib", From: "bbb", To: "aaa"}))
+	require.NoError(t, Record(Entry{Event: DowngradeAllowed, Dependency: "lib"}))
+
+	f, err := os.OpenFile(filepath.Join(stateDir, FileName), os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString("not json\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	entries, err = Read()
+	require.NoError(t, err)
+	require.Len(t, entries, 2, "unparsable lines are skipped")
+	assert.Equal(t, Entry{Time: at, Event: DowngradeBlocked, Project: "/p", Dependency: "lib", From: "bbb", To: "aaa"}, entries[0])
+	assert.False(t, entries[1].Time.IsZero(), "the time defaults to now")
+	wd, _ := os.Getwd()
+	assert.Equal(t, wd, entries[1].Project, "the project defaults to the working directory")
+}
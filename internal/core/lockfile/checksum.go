@@ -0,0 +1,59 @@
+package lockfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/nightconcept/almandine/internal/core/hasher"
+)
+
+// ErrChecksumMismatch reports that a signed lockfile was changed without being signed again.
+var ErrChecksumMismatch = errors.New("lockfile does not match its checksum")
+
+// ComputeChecksum returns the "sha256:<hex>" hash of the lockfile's canonical content: its
+// entries encoded the way Save writes them, without the checksum itself. Formatting, comments
+// and key order in the file on disk do not affect it.
+func (lf *Lockfile) ComputeChecksum() (string, error) {
+	unsigned := *lf
+	unsigned.Checksum = ""
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(unsigned); err != nil {
+		return "", fmt.Errorf("failed to encode lockfile: %w", err)
+	}
+	return hasher.CalculateSHA256(buf.Bytes())
+}
+
+// Sign stamps the lockfile with the checksum of its current content. Save keeps the stamp of a
+// signed lockfile up to date, so only changes made outside almd break it.
+func (lf *Lockfile) Sign() error {
+	checksum, err := lf.ComputeChecksum()
+	if err != nil {
+		return err
+	}
+	lf.Checksum = checksum
+	return nil
+}
+
+// Signed reports whether the lockfile carries a checksum stamp.
+func (lf *Lockfile) Signed() bool {
+	return lf.Checksum != ""
+}
+
+// VerifyChecksum checks a signed lockfile against its stamp, returning ErrChecksumMismatch when
+// it was edited since it was signed. An unsigned lockfile always passes.
+func (lf *Lockfile) VerifyChecksum() error {
+	if !lf.Signed() {
+		return nil
+	}
+	checksum, err := lf.ComputeChecksum()
+	if err != nil {
+		return err
+	}
+	if checksum != lf.Checksum {
+		return fmt.Errorf("%w: it records %s but its content hashes to %s; review the changes and run 'almd lock sign'", ErrChecksumMismatch, lf.Checksum, checksum)
+	}
+	return nil
+}
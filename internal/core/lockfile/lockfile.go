@@ -2,10 +2,17 @@ package lockfile
 
 import (
 	"fmt"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 
 	"github.com/BurntSushi/toml"
+
+	"github.com/nightconcept/almandine/internal/core/logger"
 )
 
 const LockfileName = "almd-lock.toml"
@@ -16,12 +23,69 @@ type PackageEntry struct {
 	Source string `toml:"source"`
 	Path   string `toml:"path"`
 	Hash   string `toml:"hash"`
+	// Transform names the transform applied to the upstream content before it was written, if
+	// any. TransformedHash is then the "sha256:<hex>" hash of the file as written; Hash always
+	// describes the upstream content.
+	Transform       string `toml:"transform,omitempty"`
+	TransformedHash string `toml:"transformed_hash,omitempty"`
+	// Install is the dependency's install mode ("link" or "generate") when it is not copied. A
+	// generated file also records its hash as written in TransformedHash.
+	Install string `toml:"install,omitempty"`
+	// Files maps each file of a directory dependency, relative to Path, to the "sha256:<hex>"
+	// hash of its upstream content. Hash then locks the directory as a whole, to a commit.
+	Files map[string]string `toml:"files,omitempty"`
+	// Extract holds the extract specs of an archive dependency the Files were extracted with, so
+	// that changing them in project.toml installs the dependency again.
+	Extract []string `toml:"extract,omitempty"`
+}
+
+// Equal reports whether e and o lock the same content in the same way.
+func (e PackageEntry) Equal(o PackageEntry) bool {
+	return e.Source == o.Source && e.Path == o.Path && e.Hash == o.Hash && e.Transform == o.Transform &&
+		e.TransformedHash == o.TransformedHash && e.Install == o.Install && maps.Equal(e.Files, o.Files) &&
+		slices.Equal(e.Extract, o.Extract)
+}
+
+// FilePaths returns the paths of the files the entry locks: its Path, or for a directory entry
+// the path of each of its Files, sorted.
+func (e PackageEntry) FilePaths() []string {
+	if len(e.Files) == 0 {
+		return []string{e.Path}
+	}
+	paths := make([]string, 0, len(e.Files))
+	for file := range e.Files {
+		paths = append(paths, path.Join(e.Path, file))
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// filesKey returns the files of a directory entry in a stable form, for ordering entries.
+func (e PackageEntry) filesKey() string {
+	files := make([]string, 0, len(e.Files))
+	for file, hash := range e.Files {
+		files = append(files, file+"="+hash)
+	}
+	sort.Strings(files)
+	return strings.Join(files, "\n")
+}
+
+// WrittenDiffers reports whether the file as written differs from the upstream content, so that
+// TransformedHash rather than Hash describes it: a transform was applied or a script generated it.
+func (e PackageEntry) WrittenDiffers() bool {
+	return e.Transform != "" || e.Install == "generate"
 }
 
 // Lockfile represents the structure of the almd-lock.toml file.
 type Lockfile struct {
-	ApiVersion string                  `toml:"api_version"`
-	Package    map[string]PackageEntry `toml:"package"`
+	ApiVersion string `toml:"api_version"`
+	// Checksum is the stamp written by 'almd lock sign': the hash of the rest of the lockfile,
+	// so hand edits can be detected. Empty for an unsigned lockfile.
+	Checksum string                  `toml:"checksum,omitempty"`
+	Package  map[string]PackageEntry `toml:"package"`
+	// Malformed holds why each entry LoadFile read with a malformed hash is malformed, keyed by
+	// name. Commands treat those entries as unlocked; 'almd lock repair' rebuilds them.
+	Malformed map[string]error `toml:"-"`
 }
 
 // New creates a new Lockfile instance with default values.
@@ -35,7 +99,13 @@ func New() *Lockfile {
 // Load loads the lockfile from the given project root path.
 // If the lockfile doesn't exist, it returns a new Lockfile instance.
 func Load(projectRoot string) (*Lockfile, error) {
-	lockfilePath := filepath.Join(projectRoot, LockfileName)
+	return LoadFile(filepath.Join(projectRoot, LockfileName))
+}
+
+// LoadFile loads a lockfile from lockfilePath, which need not be named almd-lock.toml (git
+// hands merge drivers temporary copies). If the file doesn't exist, it returns a new Lockfile.
+// Entries whose hashes are malformed are kept as they are and flagged in Malformed.
+func LoadFile(lockfilePath string) (*Lockfile, error) {
 	lf := New()
 
 	if _, err := os.Stat(lockfilePath); os.IsNotExist(err) {
@@ -53,22 +123,47 @@ func Load(projectRoot string) (*Lockfile, error) {
 	if lf.Package == nil {
 		lf.Package = make(map[string]PackageEntry)
 	}
+	lf.Malformed = lf.Validate()
+	logger.Debugf("read %s (%d packages, %d malformed)", lockfilePath, len(lf.Package), len(lf.Malformed))
 	return lf, nil
 }
 
-// Save saves the lockfile to the given project root path.
+// Save saves the lockfile to the given project root path. The lockfile is written to a
+// temporary file first and renamed into place, so readers never see a partial lockfile.
 func Save(projectRoot string, lf *Lockfile) error {
-	lockfilePath := filepath.Join(projectRoot, LockfileName)
-	file, err := os.Create(lockfilePath)
+	return SaveFile(filepath.Join(projectRoot, LockfileName), lf)
+}
+
+// SaveFile saves the lockfile to lockfilePath the same way Save does. A signed lockfile is
+// signed again for its new content.
+func SaveFile(lockfilePath string, lf *Lockfile) error {
+	if lf.Signed() {
+		if err := lf.Sign(); err != nil {
+			return err
+		}
+	}
+	file, err := os.CreateTemp(filepath.Dir(lockfilePath), "."+LockfileName+".*.tmp")
 	if err != nil {
 		return fmt.Errorf("failed to create/truncate lockfile %s: %w", lockfilePath, err)
 	}
-	defer func() { _ = file.Close() }()
+	tmpPath := file.Name()
+	defer func() { _ = os.Remove(tmpPath) }() // No-op once renamed
 
 	encoder := toml.NewEncoder(file)
 	if err := encoder.Encode(lf); err != nil {
+		_ = file.Close()
 		return fmt.Errorf("failed to encode lockfile %s: %w", lockfilePath, err)
 	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write lockfile %s: %w", lockfilePath, err)
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return fmt.Errorf("failed to write lockfile %s: %w", lockfilePath, err)
+	}
+	if err := os.Rename(tmpPath, lockfilePath); err != nil {
+		return fmt.Errorf("failed to replace lockfile %s: %w", lockfilePath, err)
+	}
+	logger.Debugf("wrote %s (%d packages)", lockfilePath, len(lf.Package))
 	return nil
 }
 
@@ -83,3 +178,19 @@ func (lf *Lockfile) AddOrUpdatePackage(name, rawURL, relativePath, integrityHash
 		Hash:   integrityHash,
 	}
 }
+
+// Prune removes every package entry for which keep returns false and
+// returns the names of the removed entries in sorted order.
+func (lf *Lockfile) Prune(keep func(name string) bool) []string {
+	var removed []string
+	for name := range lf.Package {
+		if !keep(name) {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		delete(lf.Package, name)
+	}
+	return removed
+}
@@ -4,6 +4,7 @@ package lockfile_test
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -42,7 +43,7 @@ api_version = "1"
 [package.mylib]
   source = "http://example.com/mylib.lua"
   path = "libs/mylib.lua"
-  hash = "sha256:abcdef123456"
+  hash = "sha256:abcdef123456abcdef123456abcdef123456abcdef123456abcdef123456abcd"
 `
 	err := os.WriteFile(lockfilePath, []byte(content), 0600)
 	require.NoError(t, err, "Failed to write mock lockfile")
@@ -54,7 +55,7 @@ api_version = "1"
 	require.Contains(t, lf.Package, "mylib")
 	assert.Equal(t, "http://example.com/mylib.lua", lf.Package["mylib"].Source)
 	assert.Equal(t, "libs/mylib.lua", lf.Package["mylib"].Path)
-	assert.Equal(t, "sha256:abcdef123456", lf.Package["mylib"].Hash)
+	assert.Equal(t, "sha256:abcdef123456abcdef123456abcdef123456abcdef123456abcdef123456abcd", lf.Package["mylib"].Hash)
 }
 
 func TestLoadLockfile_InvalidToml(t *testing.T) {
@@ -71,6 +72,60 @@ func TestLoadLockfile_InvalidToml(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to decode lockfile", "Error message mismatch")
 }
 
+func TestLoadLockfile_MalformedHashes(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+	content := `api_version = "1"
+
+[package.good]
+source = "https://example.com/good.lua"
+path = "libs/good.lua"
+hash = "commit:0123456789abcdef0123456789abcdef01234567"
+
+[package.bare]
+source = "https://example.com/bare.lua"
+path = "libs/bare.lua"
+hash = "0123456789abcdef0123456789abcdef01234567"
+
+[package.transformed]
+source = "https://example.com/transformed.lua"
+path = "libs/transformed.lua"
+hash = "sha256:abcabcabcabcabcabcabcabcabcabcabcabcabcabcabcabcabcabcabcabcabca"
+transform = "strip-comments"
+transformed_hash = "sha256:not hex"
+
+[package.dir]
+source = "https://example.com/dir/"
+path = "libs/dir"
+hash = "commit:abc"
+files = { "a.lua" = "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", "b.lua" = "" }
+`
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, lockfile.LockfileName), []byte(content), 0600))
+
+	lf, err := lockfile.Load(tempDir)
+	require.NoError(t, err, "malformed entries are flagged, not an error")
+	assert.Len(t, lf.Package, 4, "malformed entries are kept as they are")
+	assert.Equal(t, []string{"bare", "dir", "transformed"}, lf.MalformedNames())
+	assert.ErrorContains(t, lf.Malformed["bare"], "has no known prefix")
+	assert.ErrorContains(t, lf.Malformed["transformed"], "transformed_hash")
+	assert.ErrorContains(t, lf.Malformed["dir"], "file 'b.lua'")
+
+	require.NoError(t, lockfile.Save(tempDir, lf))
+	saved, err := os.ReadFile(filepath.Join(tempDir, lockfile.LockfileName))
+	require.NoError(t, err)
+	assert.NotContains(t, string(saved), "alformed", "Malformed is not written")
+}
+
+func TestValidateHash(t *testing.T) {
+	t.Parallel()
+	for _, hash := range []string{"commit:0123456789abcdef0123456789abcdef01234567", "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"} {
+		assert.NoError(t, lockfile.ValidateHash(hash), hash)
+	}
+	for _, hash := range []string{"", "commit:", "sha256:", "sha256:ABC", "sha256:abc", "sha1:abc", "commit:abc def", "garbage"} {
+		assert.Error(t, lockfile.ValidateHash(hash), hash)
+	}
+}
+
 func TestLoadLockfile_EmptyFile(t *testing.T) {
 	t.Parallel()
 	tempDir := t.TempDir()
@@ -95,7 +150,7 @@ func TestLoadLockfile_MissingApiVersion(t *testing.T) {
 [package.mylib]
   source = "http://example.com/mylib.lua"
   path = "libs/mylib.lua"
-  hash = "sha256:abcdef123456"
+  hash = "sha256:abcdef123456abcdef123456abcdef123456abcdef123456abcdef123456abcd"
 `
 	err := os.WriteFile(lockfilePath, []byte(content), 0600)
 	require.NoError(t, err, "Failed to write mock lockfile without api_version")
@@ -113,7 +168,7 @@ func TestSaveLockfile_New(t *testing.T) {
 	lf.Package["dep1"] = lockfile.PackageEntry{
 		Source: "http://example.com/dep1.zip",
 		Path:   "vendor/dep1",
-		Hash:   "sha256:123",
+		Hash:   "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
 	}
 
 	err := lockfile.Save(tempDir, lf)
@@ -141,7 +196,7 @@ func TestSaveLockfile_Overwrite(t *testing.T) {
 	lfToSave.Package["newdep"] = lockfile.PackageEntry{
 		Source: "http://example.com/newdep.tar.gz",
 		Path:   "deps/newdep",
-		Hash:   "sha256:abc",
+		Hash:   "sha256:abcabcabcabcabcabcabcabcabcabcabcabcabcabcabcabcabcabcabcabcabca",
 	}
 
 	err = lockfile.Save(tempDir, lfToSave)
@@ -184,3 +239,49 @@ func TestAddOrUpdatePackage_NilMap(t *testing.T) {
 	require.Contains(t, lf.Package, "libC")
 	assert.Equal(t, "urlC", lf.Package["libC"].Source)
 }
+
+func TestPrune(t *testing.T) {
+	t.Parallel()
+	lf := lockfile.New()
+	lf.AddOrUpdatePackage("keep", "urlK", "pathK", "hashK")
+	lf.AddOrUpdatePackage("staleB", "urlB", "pathB", "hashB")
+	lf.AddOrUpdatePackage("staleA", "urlA", "pathA", "hashA")
+
+	removed := lf.Prune(func(name string) bool { return name == "keep" })
+	assert.Equal(t, []string{"staleA", "staleB"}, removed, "Removed names should be returned sorted")
+	assert.Len(t, lf.Package, 1)
+	assert.Contains(t, lf.Package, "keep")
+
+	assert.Empty(t, lf.Prune(func(string) bool { return true }), "Nothing should be removed when all entries are kept")
+}
+
+func TestChecksum(t *testing.T) {
+	tempDir := t.TempDir()
+	lf := lockfile.New()
+	lf.AddOrUpdatePackage("lib", "https://example.com/lib.lua", "libs/lib.lua", "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+	assert.False(t, lf.Signed())
+	require.NoError(t, lf.VerifyChecksum(), "an unsigned lockfile always passes")
+
+	require.NoError(t, lf.Sign())
+	assert.Regexp(t, `^sha256:[0-9a-f]{64}$`, lf.Checksum)
+	require.NoError(t, lockfile.Save(tempDir, lf))
+	loaded, err := lockfile.Load(tempDir)
+	require.NoError(t, err)
+	require.NoError(t, loaded.VerifyChecksum())
+
+	loaded.AddOrUpdatePackage("other", "https://example.com/other.lua", "libs/other.lua", "sha256:dddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddd")
+	require.NoError(t, lockfile.Save(tempDir, loaded), "saving a signed lockfile signs it again")
+	loaded, err = lockfile.Load(tempDir)
+	require.NoError(t, err)
+	require.NoError(t, loaded.VerifyChecksum())
+	assert.NotEqual(t, lf.Checksum, loaded.Checksum)
+
+	lockPath := filepath.Join(tempDir, lockfile.LockfileName)
+	data, err := os.ReadFile(lockPath)
+	require.NoError(t, err)
+	edited := []byte(strings.Replace(string(data), "sha256:dddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddd", "sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", 1))
+	require.NoError(t, os.WriteFile(lockPath, edited, 0644))
+	loaded, err = lockfile.Load(tempDir)
+	require.NoError(t, err)
+	assert.ErrorIs(t, loaded.VerifyChecksum(), lockfile.ErrChecksumMismatch)
+}
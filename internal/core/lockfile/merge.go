@@ -0,0 +1,46 @@
+package lockfile
+
+import "sort"
+
+// Merge combines two lockfiles that both descend from base, entry by entry: a package changed
+// (or removed) on only one side takes that side's version. Packages changed differently on both
+// sides are conflicts; they keep the ours entry and are returned in sorted order.
+func Merge(base, ours, theirs *Lockfile) (merged *Lockfile, conflicts []string) {
+	merged = New()
+	if ours.ApiVersion != "" {
+		merged.ApiVersion = ours.ApiVersion
+	}
+
+	names := make(map[string]bool)
+	for _, lf := range []*Lockfile{base, ours, theirs} {
+		for name := range lf.Package {
+			names[name] = true
+		}
+	}
+	for name := range names {
+		b, inBase := base.Package[name]
+		o, inOurs := ours.Package[name]
+		t, inTheirs := theirs.Package[name]
+		oursChanged := inOurs != inBase || !o.Equal(b)
+		theirsChanged := inTheirs != inBase || !t.Equal(b)
+		switch {
+		case !theirsChanged || (inOurs == inTheirs && o.Equal(t)):
+			if inOurs {
+				merged.Package[name] = o
+			}
+		case !oursChanged:
+			if inTheirs {
+				merged.Package[name] = t
+			}
+		default:
+			conflicts = append(conflicts, name)
+			if inOurs {
+				merged.Package[name] = o
+			} else {
+				merged.Package[name] = t // Removed on our side, changed on theirs: keep the entry
+			}
+		}
+	}
+	sort.Strings(conflicts)
+	return merged, conflicts
+}
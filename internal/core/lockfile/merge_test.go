@@ -0,0 +1,45 @@
+package lockfile_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+)
+
+func TestMerge(t *testing.T) {
+	entry := func(hash string) lockfile.PackageEntry {
+		return lockfile.PackageEntry{Source: "https://example.com/lib.lua", Path: "libs/lib.lua", Hash: hash}
+	}
+	lock := func(entries map[string]lockfile.PackageEntry) *lockfile.Lockfile {
+		lf := lockfile.New()
+		for name, e := range entries {
+			lf.Package[name] = e
+		}
+		return lf
+	}
+	base := lock(map[string]lockfile.PackageEntry{
+		"unchanged": entry("commit:a"), "ours": entry("commit:a"), "theirs": entry("commit:a"),
+		"both-same": entry("commit:a"), "conflict": entry("commit:a"), "removed": entry("commit:a"),
+	})
+	ours := lock(map[string]lockfile.PackageEntry{
+		"unchanged": entry("commit:a"), "ours": entry("commit:b"), "theirs": entry("commit:a"),
+		"both-same": entry("commit:c"), "conflict": entry("commit:b"), "added": entry("commit:a"),
+	})
+	theirs := lock(map[string]lockfile.PackageEntry{
+		"unchanged": entry("commit:a"), "ours": entry("commit:a"), "theirs": entry("commit:d"),
+		"both-same": entry("commit:c"), "conflict": entry("commit:d"), "removed": entry("commit:a"),
+	})
+
+	merged, conflicts := lockfile.Merge(base, ours, theirs)
+	assert.Equal(t, []string{"conflict"}, conflicts)
+	assert.Equal(t, map[string]lockfile.PackageEntry{
+		"unchanged": entry("commit:a"),
+		"ours":      entry("commit:b"),
+		"theirs":    entry("commit:d"),
+		"both-same": entry("commit:c"),
+		"conflict":  entry("commit:b"),
+		"added":     entry("commit:a"),
+	}, merged.Package)
+}
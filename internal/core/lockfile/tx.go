@@ -0,0 +1,116 @@
+package lockfile
+
+import (
+	"slices"
+	"sort"
+	"sync"
+)
+
+// Tx collects changes to a Lockfile so that they can be made by concurrent workers and written
+// once at the end of a run. Its methods are safe for concurrent use; the Lockfile itself is
+// only modified by Apply and Commit.
+type Tx struct {
+	lf *Lockfile
+
+	mu      sync.Mutex
+	sets    map[string][]PackageEntry
+	deletes map[string]bool
+}
+
+// Begin starts collecting changes to lf.
+func (lf *Lockfile) Begin() *Tx {
+	return &Tx{lf: lf, sets: make(map[string][]PackageEntry), deletes: make(map[string]bool)}
+}
+
+// Set records entry as the new lock state of name.
+func (tx *Tx) Set(name string, entry PackageEntry) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.sets[name] = append(tx.sets[name], entry)
+}
+
+// Delete records that name should be removed from the lockfile.
+func (tx *Tx) Delete(name string) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.deletes[name] = true
+}
+
+// Prune records the removal of every entry for which keep returns false, like Lockfile.Prune,
+// and returns the names of those entries in sorted order.
+func (tx *Tx) Prune(keep func(name string) bool) []string {
+	var removed []string
+	for name := range tx.lf.Package {
+		if !keep(name) {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		tx.Delete(name)
+	}
+	return removed
+}
+
+// Changed reports whether any change has been recorded.
+func (tx *Tx) Changed() bool {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	return len(tx.sets) > 0 || len(tx.deletes) > 0
+}
+
+// Apply writes the recorded changes into the Lockfile and returns, in sorted order, the names
+// that received conflicting updates. Conflicts are resolved the same way regardless of the order
+// in which workers finished: an update wins over a deletion, and among differing updates the
+// smallest entry (ordered by source, path, hash and transform) is kept.
+func (tx *Tx) Apply() (conflicts []string) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.lf.Package == nil {
+		tx.lf.Package = make(map[string]PackageEntry)
+	}
+	for name := range tx.deletes {
+		if _, updated := tx.sets[name]; !updated {
+			delete(tx.lf.Package, name)
+		}
+	}
+	for name, entries := range tx.sets {
+		sort.Slice(entries, func(i, j int) bool { return entryLess(entries[i], entries[j]) })
+		if !entries[0].Equal(entries[len(entries)-1]) {
+			conflicts = append(conflicts, name)
+		}
+		tx.lf.Package[name] = entries[0]
+	}
+	sort.Strings(conflicts)
+	tx.sets = make(map[string][]PackageEntry)
+	tx.deletes = make(map[string]bool)
+	return conflicts
+}
+
+// Commit applies the recorded changes and saves the Lockfile to projectRoot in a single write.
+func (tx *Tx) Commit(projectRoot string) (conflicts []string, err error) {
+	conflicts = tx.Apply()
+	tx.lf.ApiVersion = APIVersion
+	return conflicts, Save(projectRoot, tx.lf)
+}
+
+func entryLess(a, b PackageEntry) bool {
+	switch {
+	case a.Source != b.Source:
+		return a.Source < b.Source
+	case a.Path != b.Path:
+		return a.Path < b.Path
+	case a.Hash != b.Hash:
+		return a.Hash < b.Hash
+	case a.Transform != b.Transform:
+		return a.Transform < b.Transform
+	case a.Install != b.Install:
+		return a.Install < b.Install
+	case a.TransformedHash != b.TransformedHash:
+		return a.TransformedHash < b.TransformedHash
+	case a.filesKey() != b.filesKey():
+		return a.filesKey() < b.filesKey()
+	default:
+		return slices.Compare(a.Extract, b.Extract) < 0
+	}
+}
@@ -0,0 +1,101 @@
+package lockfile_test
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/lockfile"
+)
+
+func TestTx_ConcurrentSets(t *testing.T) {
+	t.Parallel()
+	lf := lockfile.New()
+	tx := lf.Begin()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tx.Set(fmt.Sprintf("lib%02d", i), lockfile.PackageEntry{Source: "url", Path: "path", Hash: fmt.Sprintf("sha256:%064d", i)})
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Empty(t, lf.Package, "changes are only applied on Apply")
+	assert.Empty(t, tx.Apply())
+	assert.Len(t, lf.Package, 50)
+	assert.Equal(t, "sha256:"+strings.Repeat("0", 63)+"7", lf.Package["lib07"].Hash)
+	assert.False(t, tx.Changed(), "applied changes are cleared")
+}
+
+func TestTx_ResolvesConflictsDeterministically(t *testing.T) {
+	t.Parallel()
+	a := lockfile.PackageEntry{Source: "url", Path: "path", Hash: "sha256:" + strings.Repeat("a", 64)}
+	b := lockfile.PackageEntry{Source: "url", Path: "path", Hash: "sha256:" + strings.Repeat("b", 64)}
+
+	for _, order := range [][]lockfile.PackageEntry{{a, b}, {b, a}} {
+		lf := lockfile.New()
+		lf.AddOrUpdatePackage("stale", "url", "old", "sha256:"+strings.Repeat("0", 64))
+		tx := lf.Begin()
+		for _, entry := range order {
+			tx.Set("lib", entry)
+		}
+		tx.Set("same", a)
+		tx.Set("same", a)
+		tx.Delete("same")
+		tx.Delete("stale")
+
+		assert.Equal(t, []string{"lib"}, tx.Apply())
+		assert.Equal(t, a, lf.Package["lib"])
+		assert.Equal(t, a, lf.Package["same"], "an update wins over a deletion")
+		assert.NotContains(t, lf.Package, "stale")
+	}
+}
+
+func TestTx_PruneAndCommit(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+	lf := lockfile.New()
+	lf.ApiVersion = ""
+	lf.AddOrUpdatePackage("keep", "urlK", "pathK", "hashK")
+	lf.AddOrUpdatePackage("staleB", "urlB", "pathB", "hashB")
+	lf.AddOrUpdatePackage("staleA", "urlA", "pathA", "hashA")
+
+	tx := lf.Begin()
+	assert.False(t, tx.Changed())
+	assert.Equal(t, []string{"staleA", "staleB"}, tx.Prune(func(name string) bool { return name == "keep" }))
+	assert.Len(t, lf.Package, 3, "pruning is recorded, not applied")
+	tx.Set("added", lockfile.PackageEntry{Source: "urlN", Path: "pathN", Hash: "hashN"})
+	assert.True(t, tx.Changed())
+
+	conflicts, err := tx.Commit(tempDir)
+	require.NoError(t, err)
+	assert.Empty(t, conflicts)
+
+	loaded, err := lockfile.Load(tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, lockfile.APIVersion, loaded.ApiVersion)
+	assert.Equal(t, []string{"added", "keep"}, sortedNames(loaded))
+
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "no temporary files are left behind")
+	assert.Equal(t, lockfile.LockfileName, entries[0].Name())
+}
+
+func sortedNames(lf *lockfile.Lockfile) []string {
+	var names []string
+	for name := range lf.Package {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
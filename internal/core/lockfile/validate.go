@@ -0,0 +1,94 @@
+package lockfile
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Prefixes of the hashes a lock entry records: the commit a source with commits is locked to,
+// or the SHA-256 of content.
+const (
+	CommitPrefix = "commit:"
+	SHA256Prefix = "sha256:"
+)
+
+var (
+	commitValue = regexp.MustCompile(`^[0-9A-Za-z_]+$`)
+	sha256Value = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// ValidateHash checks that hash is a commit or content hash: a known prefix followed by a
+// commit ID or a lowercase hex digest.
+func ValidateHash(hash string) error {
+	switch {
+	case strings.HasPrefix(hash, CommitPrefix):
+		if !commitValue.MatchString(strings.TrimPrefix(hash, CommitPrefix)) {
+			return fmt.Errorf("hash '%s' does not name a commit", hash)
+		}
+		return nil
+	case strings.HasPrefix(hash, SHA256Prefix):
+		return validateContentHash(hash)
+	case hash == "":
+		return fmt.Errorf("hash is empty")
+	}
+	return fmt.Errorf("hash '%s' has no known prefix (%s or %s)", hash, CommitPrefix, SHA256Prefix)
+}
+
+func validateContentHash(hash string) error {
+	digest, ok := strings.CutPrefix(hash, SHA256Prefix)
+	if !ok || !sha256Value.MatchString(digest) {
+		return fmt.Errorf("'%s' is not a %s<hex> hash", hash, SHA256Prefix)
+	}
+	return nil
+}
+
+// Validate checks the hashes the entry records: Hash, TransformedHash when set, and the hash of
+// each of its Files, which are always content hashes.
+func (e PackageEntry) Validate() error {
+	if err := ValidateHash(e.Hash); err != nil {
+		return err
+	}
+	if e.TransformedHash != "" {
+		if err := validateContentHash(e.TransformedHash); err != nil {
+			return fmt.Errorf("transformed_hash: %w", err)
+		}
+	}
+	files := make([]string, 0, len(e.Files))
+	for file := range e.Files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		if err := validateContentHash(e.Files[file]); err != nil {
+			return fmt.Errorf("file '%s': %w", file, err)
+		}
+	}
+	return nil
+}
+
+// Validate returns why each malformed entry of the lockfile is malformed, keyed by name, or nil
+// when every entry is well formed.
+func (lf *Lockfile) Validate() map[string]error {
+	var malformed map[string]error
+	for name, entry := range lf.Package {
+		if err := entry.Validate(); err != nil {
+			if malformed == nil {
+				malformed = make(map[string]error)
+			}
+			malformed[name] = err
+		}
+	}
+	return malformed
+}
+
+// MalformedNames returns the names of the entries Load found malformed, in sorted order.
+func (lf *Lockfile) MalformedNames() []string {
+	names := make([]string, 0, len(lf.Malformed))
+	for name := range lf.Malformed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
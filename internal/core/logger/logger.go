@@ -0,0 +1,70 @@
+// Package logger carries --verbose for the whole run. Commands write their verbose progress
+// through Progressf, and core packages (source, downloader, lockfile, ...) report debug detail
+// through Debugf, which writes only while verbose output is on. Both write to stderr, so stdout
+// stays reserved for command output.
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/nightconcept/almandine/internal/core/theme"
+)
+
+var (
+	mu      sync.Mutex
+	verbose bool
+	out     io.Writer // nil writes to os.Stderr as it is when the line is written
+)
+
+// SetVerbose turns verbose output on or off.
+func SetVerbose(on bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	verbose = on
+}
+
+// Verbose reports whether --verbose was given, globally or to the running command.
+func Verbose() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return verbose
+}
+
+// SetOutput redirects debug output to w and returns a function that restores the previous writer.
+// A nil w writes to whatever os.Stderr is at the time, so output follows redirections such as
+// the per-project prefixes of 'almd -r'.
+func SetOutput(w io.Writer) (restore func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	previous := out
+	out = w
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		out = previous
+	}
+}
+
+// Debugf writes a debug line while verbose output is on. The message gets a "debug:" prefix
+// and a trailing newline.
+func Debugf(format string, a ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+	if !verbose {
+		return
+	}
+	w := out
+	if w == nil {
+		w = os.Stderr
+	}
+	_, _ = fmt.Fprintf(w, "%s %s\n", theme.SprintFunc(theme.Muted)("debug:"), fmt.Sprintf(format, a...))
+}
+
+// Progressf writes a line of a command's --verbose progress output to stderr, as it is when the
+// line is written. Commands decide themselves when to call it; unlike Debugf it has no prefix.
+func Progressf(format string, a ...interface{}) {
+	_, _ = fmt.Fprintf(os.Stderr, format+"\n", a...)
+}
@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugf(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	var buf bytes.Buffer
+	restore := SetOutput(&buf)
+	defer restore()
+	defer SetVerbose(false)
+
+	Debugf("hidden %d", 1)
+	assert.Empty(t, buf.String(), "nothing is written unless verbose output is on")
+
+	SetVerbose(true)
+	assert.True(t, Verbose())
+	Debugf("resolved %s", "owner/repo@main")
+	assert.Contains(t, buf.String(), "debug: resolved owner/repo@main\n")
+}
+
+func TestDebugf_FollowsStderr(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	original := os.Stderr
+	os.Stderr = w // As 'almd -r' does to prefix each project's output
+	SetVerbose(true)
+	Debugf("after redirect")
+	SetVerbose(false)
+	os.Stderr = original
+	require.NoError(t, w.Close())
+
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, r)
+	require.NoError(t, err)
+	assert.Equal(t, "debug: after redirect\n", buf.String())
+}
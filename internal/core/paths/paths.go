@@ -0,0 +1,170 @@
+// Package paths resolves where almd keeps its cache, configuration and state files.
+//
+// Directories follow the XDG Base Directory specification on Linux and other Unix systems,
+// and the native conventions on macOS and Windows. Each location can be relocated with an
+// environment variable (ALMD_CACHE_DIR, ALMD_CONFIG_DIR, ALMD_STATE_DIR) or, taking
+// precedence over everything else, an explicit override set from a command-line flag.
+package paths
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// appDirName is the directory name used under each platform base directory.
+const appDirName = "almd"
+
+// Environment variables that relocate individual directories.
+const (
+	CacheDirEnv  = "ALMD_CACHE_DIR"
+	ConfigDirEnv = "ALMD_CONFIG_DIR"
+	StateDirEnv  = "ALMD_STATE_DIR"
+)
+
+// Kind identifies one of the directories managed by this package.
+type Kind int
+
+const (
+	Cache Kind = iota
+	Config
+	State
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Cache:
+		return "cache"
+	case Config:
+		return "config"
+	case State:
+		return "state"
+	default:
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+}
+
+func (k Kind) envVar() string {
+	switch k {
+	case Cache:
+		return CacheDirEnv
+	case Config:
+		return ConfigDirEnv
+	default:
+		return StateDirEnv
+	}
+}
+
+var (
+	overridesMu sync.Mutex
+	overrides   = map[Kind]string{}
+)
+
+// SetOverride forces the directory for kind to dir, taking precedence over environment
+// variables and platform defaults. An empty dir removes the override.
+func SetOverride(kind Kind, dir string) {
+	overridesMu.Lock()
+	defer overridesMu.Unlock()
+	if dir == "" {
+		delete(overrides, kind)
+		return
+	}
+	overrides[kind] = dir
+}
+
+// CacheDir returns the directory for downloaded artifacts that can be safely deleted.
+func CacheDir() (string, error) { return Dir(Cache) }
+
+// ConfigDir returns the directory for user-level configuration.
+func ConfigDir() (string, error) { return Dir(Config) }
+
+// StateDir returns the directory for persistent state such as logs and journals.
+func StateDir() (string, error) { return Dir(State) }
+
+// Dir returns the directory for kind, resolved from the override, the kind's
+// environment variable, or the platform default, in that order.
+// The directory is not created.
+func Dir(kind Kind) (string, error) {
+	overridesMu.Lock()
+	override := overrides[kind]
+	overridesMu.Unlock()
+	if override != "" {
+		return filepath.Abs(override)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = ""
+	}
+	return resolve(kind, runtime.GOOS, os.Getenv, home)
+}
+
+// resolve computes the directory for kind without consulting overrides. It is separated
+// from Dir so the platform rules can be tested for every OS.
+func resolve(kind Kind, goos string, getenv func(string) string, home string) (string, error) {
+	if dir := getenv(kind.envVar()); dir != "" {
+		return filepath.Abs(dir)
+	}
+
+	switch goos {
+	case "windows":
+		if kind == Config {
+			appData := getenv("AppData")
+			if appData == "" {
+				return "", fmt.Errorf("cannot determine %s directory: %%AppData%% is not set", kind)
+			}
+			return filepath.Join(appData, appDirName), nil
+		}
+		localAppData := getenv("LocalAppData")
+		if localAppData == "" {
+			return "", fmt.Errorf("cannot determine %s directory: %%LocalAppData%% is not set", kind)
+		}
+		// Cache and state both live under %LocalAppData%, so each gets its own subdirectory.
+		return filepath.Join(localAppData, appDirName, kind.String()), nil
+	case "darwin":
+		if xdg := getenv(xdgVar(kind)); filepath.IsAbs(xdg) {
+			return filepath.Join(xdg, appDirName), nil
+		}
+		if home == "" {
+			return "", fmt.Errorf("cannot determine %s directory: home directory is unknown", kind)
+		}
+		switch kind {
+		case Cache:
+			return filepath.Join(home, "Library", "Caches", appDirName), nil
+		case Config:
+			return filepath.Join(home, "Library", "Application Support", appDirName), nil
+		default:
+			return filepath.Join(home, "Library", "Application Support", appDirName, "state"), nil
+		}
+	default:
+		if xdg := getenv(xdgVar(kind)); filepath.IsAbs(xdg) {
+			return filepath.Join(xdg, appDirName), nil
+		}
+		if home == "" {
+			return "", fmt.Errorf("cannot determine %s directory: home directory is unknown and %s is not set", kind, xdgVar(kind))
+		}
+		switch kind {
+		case Cache:
+			return filepath.Join(home, ".cache", appDirName), nil
+		case Config:
+			return filepath.Join(home, ".config", appDirName), nil
+		default:
+			return filepath.Join(home, ".local", "state", appDirName), nil
+		}
+	}
+}
+
+// xdgVar returns the XDG Base Directory variable for kind. Relative values must be
+// ignored per the specification, which callers handle with filepath.IsAbs.
+func xdgVar(kind Kind) string {
+	switch kind {
+	case Cache:
+		return "XDG_CACHE_HOME"
+	case Config:
+		return "XDG_CONFIG_HOME"
+	default:
+		return "XDG_STATE_HOME"
+	}
+}
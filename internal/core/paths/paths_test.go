@@ -0,0 +1,70 @@
+package paths
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func envFrom(m map[string]string) func(string) string {
+	return func(key string) string { return m[key] }
+}
+
+func TestResolve_PlatformDefaults(t *testing.T) {
+	home := filepath.FromSlash("/home/user")
+	tests := []struct {
+		name string
+		kind Kind
+		goos string
+		env  map[string]string
+		want string
+	}{
+		{name: "linux cache default", kind: Cache, goos: "linux", want: filepath.Join(home, ".cache", "almd")},
+		{name: "linux config default", kind: Config, goos: "linux", want: filepath.Join(home, ".config", "almd")},
+		{name: "linux state default", kind: State, goos: "linux", want: filepath.Join(home, ".local", "state", "almd")},
+		{name: "linux XDG cache", kind: Cache, goos: "linux", env: map[string]string{"XDG_CACHE_HOME": filepath.FromSlash("/xdg/cache")}, want: filepath.FromSlash("/xdg/cache/almd")},
+		{name: "linux relative XDG ignored", kind: Config, goos: "linux", env: map[string]string{"XDG_CONFIG_HOME": "relative"}, want: filepath.Join(home, ".config", "almd")},
+		{name: "darwin cache default", kind: Cache, goos: "darwin", want: filepath.Join(home, "Library", "Caches", "almd")},
+		{name: "darwin config default", kind: Config, goos: "darwin", want: filepath.Join(home, "Library", "Application Support", "almd")},
+		{name: "windows config", kind: Config, goos: "windows", env: map[string]string{"AppData": filepath.FromSlash("/appdata/roaming")}, want: filepath.FromSlash("/appdata/roaming/almd")},
+		{name: "windows cache", kind: Cache, goos: "windows", env: map[string]string{"LocalAppData": filepath.FromSlash("/appdata/local")}, want: filepath.FromSlash("/appdata/local/almd/cache")},
+		{name: "windows state", kind: State, goos: "windows", env: map[string]string{"LocalAppData": filepath.FromSlash("/appdata/local")}, want: filepath.FromSlash("/appdata/local/almd/state")},
+		{name: "env var wins over XDG", kind: Cache, goos: "linux", env: map[string]string{CacheDirEnv: filepath.FromSlash("/custom/cache"), "XDG_CACHE_HOME": filepath.FromSlash("/xdg/cache")}, want: filepath.FromSlash("/custom/cache")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolve(tt.kind, tt.goos, envFrom(tt.env), home)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestResolve_MissingBase(t *testing.T) {
+	_, err := resolve(Cache, "windows", envFrom(nil), "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "LocalAppData")
+
+	_, err = resolve(State, "linux", envFrom(nil), "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "XDG_STATE_HOME")
+}
+
+func TestDir_OverrideTakesPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(CacheDirEnv, filepath.Join(dir, "from-env"))
+
+	got, err := CacheDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "from-env"), got)
+
+	SetOverride(Cache, filepath.Join(dir, "from-flag"))
+	defer SetOverride(Cache, "")
+
+	got, err = CacheDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "from-flag"), got)
+}
@@ -0,0 +1,34 @@
+package project
+
+import (
+	"fmt"
+
+	"github.com/nightconcept/almandine/internal/core/bytesize"
+)
+
+// Budget caps the size of the vendored dependency files ([budget] table), for targets such as
+// handhelds and microcontrollers whose flash holds only so much Lua.
+type Budget struct {
+	MaxTotalSize string `toml:"max_total_size,omitempty"` // All dependency files together, e.g. "512KB"
+	MaxFileSize  string `toml:"max_file_size,omitempty"`  // Any single dependency file, e.g. "64KiB"
+	// WarnOnly reports an exceeded budget as a warning instead of failing add and install.
+	WarnOnly bool `toml:"warn_only,omitempty"`
+}
+
+// Limits returns the budget's limits in bytes; zero means no limit.
+func (b *Budget) Limits() (maxTotal, maxFile int64, err error) {
+	if b == nil {
+		return 0, 0, nil
+	}
+	if b.MaxTotalSize != "" {
+		if maxTotal, err = bytesize.Parse(b.MaxTotalSize); err != nil {
+			return 0, 0, fmt.Errorf("[budget] max_total_size: %w", err)
+		}
+	}
+	if b.MaxFileSize != "" {
+		if maxFile, err = bytesize.Parse(b.MaxFileSize); err != nil {
+			return 0, 0, fmt.Errorf("[budget] max_file_size: %w", err)
+		}
+	}
+	return maxTotal, maxFile, nil
+}
@@ -0,0 +1,50 @@
+package project
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Defaults fills in the parts of "github:" shorthand sources that project.toml leaves out
+// ([defaults] table), for projects that vendor many files from one organization.
+type Defaults struct {
+	GitHubOwner string `toml:"github_owner,omitempty"` // Owner of "github:/repo/path@ref" sources
+	Branch      string `toml:"branch,omitempty"`       // Ref of "github:" sources written without "@ref"
+}
+
+// githubShorthand is the prefix of the sources Defaults applies to.
+const githubShorthand = "github:"
+
+// Apply returns source with the owner and ref from d filled in: "github:/repo/file.lua@main"
+// gets the github_owner and "github:owner/repo/file.lua" gets "@<branch>". Other sources are
+// returned unchanged. A source that leaves out the owner is an error without github_owner.
+func (d *Defaults) Apply(source string) (string, error) {
+	rest, ok := strings.CutPrefix(source, githubShorthand)
+	if !ok {
+		return source, nil
+	}
+	if strings.HasPrefix(rest, "/") {
+		if d == nil || d.GitHubOwner == "" {
+			return "", fmt.Errorf("source '%s' leaves out the owner, but [defaults] github_owner is not set", source)
+		}
+		rest = d.GitHubOwner + rest
+	}
+	if !strings.Contains(rest, "@") && d != nil && d.Branch != "" {
+		rest += "@" + d.Branch
+	}
+	return githubShorthand + rest, nil
+}
+
+// Validate checks the [defaults] table.
+func (d *Defaults) Validate() error {
+	if d == nil {
+		return nil
+	}
+	if strings.ContainsAny(d.GitHubOwner, "/@ ") {
+		return fmt.Errorf("[defaults] github_owner '%s' must be a bare owner name such as \"mystudio\"", d.GitHubOwner)
+	}
+	if strings.ContainsAny(d.Branch, "@ ") {
+		return fmt.Errorf("[defaults] branch '%s' must be a plain ref such as \"main\"", d.Branch)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package project
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/nightconcept/almandine/internal/core/archive"
+)
+
+// IsDirectory reports whether the dependency vendors the files of a directory (see Files).
+func (d Dependency) IsDirectory() bool {
+	return len(d.Files) > 0
+}
+
+// FilePaths returns the paths of the dependency's files: its Path, or for a directory
+// dependency the path of each of its Files.
+func (d Dependency) FilePaths() []string {
+	if !d.IsDirectory() {
+		return []string{d.Path}
+	}
+	paths := make([]string, 0, len(d.Files))
+	for _, file := range d.Files {
+		paths = append(paths, path.Join(d.Path, file))
+	}
+	return paths
+}
+
+// ValidateDirectory checks the files of a directory dependency: each is a distinct relative path
+// inside its directory, and the files are copied as they are, so no transform or other install
+// mode applies. The extract specs of an archive source must parse.
+func ValidateDirectory(name string, dep Dependency) error {
+	if !dep.IsDirectory() {
+		if len(dep.Extract) > 0 {
+			return fmt.Errorf("dependency '%s' lists files to extract but no files; add it again with 'almd add --extract'", name)
+		}
+		return nil
+	}
+	if _, err := archive.ParseSpecs(dep.Extract); err != nil {
+		return fmt.Errorf("dependency '%s': %w", name, err)
+	}
+	seen := make(map[string]bool, len(dep.Files))
+	for _, file := range dep.Files {
+		clean := path.Clean(file)
+		if file == "" || clean != file || path.IsAbs(file) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("dependency '%s' lists invalid file '%s'; files are relative paths inside its directory", name, file)
+		}
+		if seen[file] {
+			return fmt.Errorf("dependency '%s' lists file '%s' twice", name, file)
+		}
+		seen[file] = true
+	}
+	if dep.Transform != "" {
+		return fmt.Errorf("dependency '%s' is a directory, which cannot be transformed", name)
+	}
+	if dep.InstallMode() != InstallCopy {
+		return fmt.Errorf("dependency '%s' is a directory, which is always copied (install = \"%s\")", name, InstallCopy)
+	}
+	return nil
+}
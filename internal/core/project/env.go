@@ -0,0 +1,128 @@
+package project
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// envReference matches a ${NAME} reference in a dependency source.
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// ExpandSource replaces each ${NAME} in source with the value lookup returns for NAME, so one
+// project.toml can point at a mirror host that differs between machines. An undefined variable
+// expands to "" unless strict is set, in which case it is an error.
+func ExpandSource(source string, lookup func(string) (string, bool), strict bool) (string, error) {
+	expanded, undefined := expandEnv(source, lookup)
+	if strict && len(undefined) > 0 {
+		return "", fmt.Errorf("source '%s' uses undefined environment variable %s", source, strings.Join(undefined, ", "))
+	}
+	return expanded, nil
+}
+
+// expandEnv replaces each ${NAME} in s and returns the names lookup did not know.
+func expandEnv(s string, lookup func(string) (string, bool)) (string, []string) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	var undefined []string
+	expanded := envReference.ReplaceAllStringFunc(s, func(ref string) string {
+		name := envReference.FindStringSubmatch(ref)[1]
+		value, ok := lookup(name)
+		if !ok {
+			undefined = append(undefined, name)
+		}
+		return value
+	})
+	return expanded, undefined
+}
+
+// headerName matches a valid HTTP header field name (an RFC 9110 token).
+var headerName = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// ExpandHeaderValues validates a dependency's HTTP headers and expands the ${NAME} references
+// in their values, typically to keep API keys out of project.toml. Undefined variables are
+// handled as in ExpandSource.
+func ExpandHeaderValues(headers map[string]string, lookup func(string) (string, bool), strict bool) (map[string]string, error) {
+	if headers == nil {
+		return nil, nil
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	expanded := make(map[string]string, len(headers))
+	for _, name := range names {
+		if !headerName.MatchString(name) {
+			return nil, fmt.Errorf("invalid header name '%s'", name)
+		}
+		value, undefined := expandEnv(headers[name], lookup)
+		if strict && len(undefined) > 0 {
+			return nil, fmt.Errorf("header '%s' uses undefined environment variable %s", name, strings.Join(undefined, ", "))
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("header '%s' must not contain line breaks", name)
+		}
+		expanded[name] = value
+	}
+	return expanded, nil
+}
+
+// ExpandSources expands the environment references in every dependency source and fills in
+// the shorthand parts supplied by [defaults] (see Defaults.Apply), keeping the source as written
+// in SourceTemplate so it can be saved back unexpanded.
+func (p *Project) ExpandSources(lookup func(string) (string, bool)) error {
+	if err := p.Defaults.Validate(); err != nil {
+		return err
+	}
+	for name, dep := range p.Dependencies {
+		expanded, err := p.ExpandSource(dep.Source, lookup)
+		if err != nil {
+			return fmt.Errorf("dependency '%s': %w", name, err)
+		}
+		if expanded != dep.Source {
+			dep.SourceTemplate, dep.Source = dep.Source, expanded
+			p.Dependencies[name] = dep
+		}
+	}
+	return nil
+}
+
+// ExpandSource expands a source written in this project: its environment references, under
+// the project's strict_env setting, and the shorthand parts supplied by [defaults].
+func (p *Project) ExpandSource(source string, lookup func(string) (string, bool)) (string, error) {
+	expanded, err := ExpandSource(source, lookup, p.StrictEnvEnabled())
+	if err != nil {
+		return "", err
+	}
+	var defaults *Defaults
+	if p != nil {
+		defaults = p.Defaults
+	}
+	return defaults.Apply(expanded)
+}
+
+// ExpandHeaders expands the environment references in every dependency's headers, keeping the
+// headers as written in HeaderTemplates so secrets are never saved back to project.toml.
+func (p *Project) ExpandHeaders(lookup func(string) (string, bool)) error {
+	for name, dep := range p.Dependencies {
+		expanded, err := ExpandHeaderValues(dep.Headers, lookup, p.StrictEnvEnabled())
+		if err != nil {
+			return fmt.Errorf("dependency '%s': %w", name, err)
+		}
+		if !maps.Equal(expanded, dep.Headers) {
+			dep.HeaderTemplates, dep.Headers = dep.Headers, expanded
+			p.Dependencies[name] = dep
+		}
+	}
+	return nil
+}
+
+// StrictEnvEnabled reports whether undefined variables in sources are an error.
+func (p *Project) StrictEnvEnabled() bool {
+	return p != nil && p.Vendor != nil && p.Vendor.StrictEnv
+}
@@ -0,0 +1,43 @@
+package project
+
+import "fmt"
+
+// Install modes of a dependency (install = "..." in project.toml), which decide how install puts
+// its file in the project.
+const (
+	InstallCopy     = "copy"     // Write the downloaded content to the path (the default)
+	InstallLink     = "link"     // Link the path to the content in the global cache
+	InstallGenerate = "generate" // Run the script named by generate to build the path from the content
+)
+
+// InstallMode returns the dependency's install mode, InstallCopy when none is set.
+func (d Dependency) InstallMode() string {
+	if d.Install == "" {
+		return InstallCopy
+	}
+	return d.Install
+}
+
+// ValidateInstall checks the install mode of one dependency: a generated dependency names a
+// script of scripts to generate it, and only copied files take a mode.
+func ValidateInstall(name string, dep Dependency, scripts map[string]Script) error {
+	switch dep.InstallMode() {
+	case InstallCopy, InstallLink:
+		if dep.Generate != "" {
+			return fmt.Errorf("dependency '%s' sets generate but its install mode is '%s'; use install = \"%s\"", name, dep.InstallMode(), InstallGenerate)
+		}
+	case InstallGenerate:
+		if dep.Generate == "" {
+			return fmt.Errorf("dependency '%s' is generated but does not name the script that generates it (generate = \"<script>\")", name)
+		}
+		if _, ok := scripts[dep.Generate]; !ok {
+			return fmt.Errorf("dependency '%s' is generated by unknown script '%s'", name, dep.Generate)
+		}
+	default:
+		return fmt.Errorf("dependency '%s' has unknown install mode '%s' (expected %s, %s or %s)", name, dep.Install, InstallCopy, InstallLink, InstallGenerate)
+	}
+	if dep.Mode != "" && dep.InstallMode() != InstallCopy {
+		return fmt.Errorf("dependency '%s' sets mode, which only applies to copied files, but its install mode is '%s'", name, dep.InstallMode())
+	}
+	return nil
+}
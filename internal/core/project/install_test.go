@@ -0,0 +1,25 @@
+package project_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nightconcept/almandine/internal/core/project"
+)
+
+func TestValidateInstall(t *testing.T) {
+	scripts := map[string]project.Script{"build": {Cmd: "make json.lua"}}
+	assert.Equal(t, project.InstallCopy, project.Dependency{}.InstallMode())
+
+	assert.NoError(t, project.ValidateInstall("json", project.Dependency{}, scripts))
+	assert.NoError(t, project.ValidateInstall("json", project.Dependency{Install: "link"}, scripts))
+	assert.NoError(t, project.ValidateInstall("json", project.Dependency{Install: "generate", Generate: "build"}, scripts))
+	assert.NoError(t, project.ValidateInstall("json", project.Dependency{Mode: "0755"}, scripts))
+
+	assert.ErrorContains(t, project.ValidateInstall("json", project.Dependency{Install: "move"}, scripts), "unknown install mode 'move'")
+	assert.ErrorContains(t, project.ValidateInstall("json", project.Dependency{Install: "generate"}, scripts), "does not name the script")
+	assert.ErrorContains(t, project.ValidateInstall("json", project.Dependency{Install: "generate", Generate: "lint"}, scripts), "unknown script 'lint'")
+	assert.ErrorContains(t, project.ValidateInstall("json", project.Dependency{Generate: "build"}, scripts), "sets generate")
+	assert.ErrorContains(t, project.ValidateInstall("json", project.Dependency{Install: "link", Mode: "0755"}, scripts), "only applies to copied files")
+}
@@ -0,0 +1,70 @@
+package project
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// HasLabel reports whether the dependency carries label. Labels compare case-insensitively.
+func (d Dependency) HasLabel(label string) bool {
+	for _, l := range d.Labels {
+		if strings.EqualFold(l, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectByLabels narrows names to the dependencies carrying at least one of labels. An empty
+// names selects from every dependency of deps; without labels names is returned unchanged. The
+// result is sorted, and it is an error when no dependency matches.
+func SelectByLabels(deps map[string]Dependency, names, labels []string) ([]string, error) {
+	if len(labels) == 0 {
+		return names, nil
+	}
+	candidates := names
+	if len(candidates) == 0 {
+		for name := range deps {
+			candidates = append(candidates, name)
+		}
+	}
+
+	var selected []string
+	for _, name := range candidates {
+		dep, ok := deps[name]
+		if !ok {
+			selected = append(selected, name) // Left for the command to report as unknown
+			continue
+		}
+		for _, label := range labels {
+			if dep.HasLabel(label) {
+				selected = append(selected, name)
+				break
+			}
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no dependencies are labeled %s", strings.Join(quoteAll(labels), " or "))
+	}
+	sort.Strings(selected)
+	return selected, nil
+}
+
+// ValidateLabels checks the labels of one dependency: they must be non-empty and free of spaces.
+func ValidateLabels(name string, labels []string) error {
+	for _, label := range labels {
+		if strings.TrimSpace(label) == "" || strings.ContainsAny(label, " \t,") {
+			return fmt.Errorf("dependency '%s' has invalid label '%s'; labels must be non-empty and contain no spaces or commas", name, label)
+		}
+	}
+	return nil
+}
+
+func quoteAll(values []string) []string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = "'" + v + "'"
+	}
+	return quoted
+}
@@ -0,0 +1,40 @@
+package project_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/project"
+)
+
+func TestSelectByLabels(t *testing.T) {
+	deps := map[string]project.Dependency{
+		"button": {Labels: []string{"ui"}},
+		"json":   {Labels: []string{"thirdparty", "core"}},
+		"utils":  {},
+	}
+
+	names, err := project.SelectByLabels(deps, nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, names, "without labels the selection is left alone")
+
+	names, err = project.SelectByLabels(deps, nil, []string{"UI", "core"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"button", "json"}, names)
+
+	names, err = project.SelectByLabels(deps, []string{"json", "utils", "missing"}, []string{"thirdparty"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"json", "missing"}, names, "unknown names are kept for the caller to report")
+
+	_, err = project.SelectByLabels(deps, nil, []string{"docs"})
+	assert.ErrorContains(t, err, "no dependencies are labeled 'docs'")
+}
+
+func TestValidateLabels(t *testing.T) {
+	assert.NoError(t, project.ValidateLabels("lib", []string{"ui", "third-party"}))
+	for _, label := range []string{"", " ", "two words", "a,b"} {
+		assert.Error(t, project.ValidateLabels("lib", []string{label}), label)
+	}
+}
@@ -0,0 +1,156 @@
+package project
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// PackageFields are the [package] fields 'almd meta' reads and writes, in display order.
+var PackageFields = []string{"name", "version", "description", "license"}
+
+// Field returns the value of a [package] field by its TOML name.
+func (p *PackageInfo) Field(field string) (string, error) {
+	if p == nil {
+		p = &PackageInfo{}
+	}
+	switch field {
+	case "name":
+		return p.Name, nil
+	case "version":
+		return p.Version, nil
+	case "description":
+		return p.Description, nil
+	case "license":
+		return p.License, nil
+	}
+	return "", fmt.Errorf("unknown package field '%s' (expected one of %s)", field, strings.Join(PackageFields, ", "))
+}
+
+// ValidatePackageField reports why value cannot be stored in a [package] field, or returns nil.
+// Versions must be semantic versions and licenses SPDX license expressions.
+func ValidatePackageField(field, value string) error {
+	if _, err := (&PackageInfo{}).Field(field); err != nil {
+		return err
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("%s must be a single line", field)
+	}
+	switch field {
+	case "name":
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("name must not be empty")
+		}
+	case "version":
+		if _, err := semver.StrictNewVersion(value); err != nil {
+			return fmt.Errorf("version '%s' is not a semantic version such as 1.2.0: %w", value, err)
+		}
+	case "license":
+		return ValidateLicense(value)
+	}
+	return nil
+}
+
+// ValidateLicense reports why expr is not an SPDX license expression made of known license
+// identifiers (e.g. "MIT" or "Apache-2.0 OR MIT"), or returns nil. Custom licenses are written
+// as LicenseRef-<name>.
+func ValidateLicense(expr string) error {
+	tokens := strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expr))
+	if len(tokens) == 0 {
+		return fmt.Errorf("license must not be empty")
+	}
+	p := licenseParser{expr: expr, expectID: true}
+	for _, tok := range tokens {
+		if err := p.next(tok); err != nil {
+			return err
+		}
+	}
+	if p.expectID || p.depth != 0 {
+		return fmt.Errorf("license '%s' is not a valid SPDX expression", expr)
+	}
+	return nil
+}
+
+// licenseParser checks the tokens of an SPDX license expression one at a time.
+type licenseParser struct {
+	expr      string
+	depth     int  // Open parentheses
+	expectID  bool // The next token must be an identifier or '('
+	afterWith bool // The previous token was WITH, so an exception follows
+}
+
+func (p *licenseParser) next(tok string) error {
+	switch {
+	case tok == "(" && p.expectID:
+		p.depth++
+	case tok == ")" && !p.expectID && p.depth > 0:
+		p.depth--
+	case (tok == "AND" || tok == "OR") && !p.expectID:
+		p.expectID = true
+	case tok == "WITH" && !p.expectID && !p.afterWith:
+		p.expectID, p.afterWith = true, true
+		return nil
+	case p.expectID:
+		if err := p.operand(tok); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("license '%s' is not a valid SPDX expression near '%s'", p.expr, tok)
+	}
+	p.afterWith = false
+	return nil
+}
+
+// operand checks a license identifier, or a license exception right after WITH.
+func (p *licenseParser) operand(tok string) error {
+	if p.afterWith && !spdxExceptions[strings.ToLower(tok)] {
+		return fmt.Errorf("unknown SPDX license exception '%s' in '%s'", tok, p.expr)
+	}
+	if !p.afterWith && !isLicenseID(tok) {
+		return fmt.Errorf("unknown SPDX license identifier '%s' in '%s' (see https://spdx.org/licenses/; use LicenseRef-<name> for a custom license)", tok, p.expr)
+	}
+	p.expectID = false
+	return nil
+}
+
+// isLicenseID reports whether id is a known SPDX license identifier, optionally followed by "+"
+// ("or any later version"), or a LicenseRef. SPDX matches identifiers case-insensitively.
+func isLicenseID(id string) bool {
+	if strings.HasPrefix(id, "LicenseRef-") && len(id) > len("LicenseRef-") {
+		return true
+	}
+	return spdxLicenses[strings.ToLower(strings.TrimSuffix(id, "+"))]
+}
+
+func lowerSet(ids ...string) map[string]bool {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[strings.ToLower(id)] = true
+	}
+	return set
+}
+
+// spdxLicenses holds the SPDX license identifiers almd recognizes: the OSI-approved and
+// otherwise commonly used ones.
+var spdxLicenses = lowerSet(
+	"0BSD", "AFL-3.0", "AGPL-3.0-only", "AGPL-3.0-or-later", "Apache-1.1", "Apache-2.0", "APSL-2.0",
+	"Artistic-1.0", "Artistic-2.0", "BlueOak-1.0.0", "BSD-1-Clause", "BSD-2-Clause", "BSD-2-Clause-Patent",
+	"BSD-3-Clause", "BSD-3-Clause-Clear", "BSD-4-Clause", "BSL-1.0", "CAL-1.0", "CC-BY-3.0", "CC-BY-4.0",
+	"CC-BY-SA-3.0", "CC-BY-SA-4.0", "CC-BY-NC-4.0", "CC-BY-NC-SA-4.0", "CC-BY-ND-4.0", "CC0-1.0",
+	"CDDL-1.0", "CDDL-1.1", "CECILL-2.1", "CPAL-1.0", "CPL-1.0", "ECL-2.0", "EFL-2.0", "EPL-1.0", "EPL-2.0",
+	"EUPL-1.1", "EUPL-1.2", "GPL-2.0-only", "GPL-2.0-or-later", "GPL-3.0-only", "GPL-3.0-or-later",
+	"GPL-2.0", "GPL-3.0", "LGPL-2.0-only", "LGPL-2.0-or-later", "LGPL-2.1-only", "LGPL-2.1-or-later",
+	"LGPL-3.0-only", "LGPL-3.0-or-later", "LGPL-2.1", "LGPL-3.0", "ISC", "LPL-1.02", "LPPL-1.3c",
+	"MIT", "MIT-0", "MirOS", "MPL-1.1", "MPL-2.0", "MPL-2.0-no-copyleft-exception", "MS-PL", "MS-RL",
+	"MulanPSL-2.0", "NCSA", "ODbL-1.0", "OFL-1.1", "OSL-3.0", "PHP-3.01", "PostgreSQL", "Python-2.0",
+	"QPL-1.0", "RPL-1.5", "SSPL-1.0", "Unicode-3.0", "Unicode-DFS-2016", "Unlicense", "UPL-1.0",
+	"Vim", "W3C", "WTFPL", "X11", "Xnet", "Zlib", "zlib-acknowledgement", "ZPL-2.0", "ZPL-2.1",
+)
+
+// spdxExceptions holds the SPDX license exceptions recognized after WITH.
+var spdxExceptions = lowerSet(
+	"Autoconf-exception-3.0", "Bison-exception-2.2", "Classpath-exception-2.0", "GCC-exception-3.1",
+	"LLVM-exception", "OCaml-LGPL-linking-exception", "OpenJDK-assembly-exception-1.0",
+	"Qt-LGPL-exception-1.1", "Universal-FOSS-exception-1.0",
+)
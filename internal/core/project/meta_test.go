@@ -0,0 +1,30 @@
+package project
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateLicense(t *testing.T) {
+	for _, valid := range []string{
+		"MIT", "mit", "Apache-2.0 OR MIT", "GPL-2.0-or-later WITH Classpath-exception-2.0",
+		"(MIT OR Apache-2.0) AND BSD-3-Clause", "LGPL-2.1+", "LicenseRef-Proprietary",
+	} {
+		assert.NoError(t, ValidateLicense(valid), valid)
+	}
+	for _, invalid := range []string{
+		"", "MIT/X11", "Apache 2", "MIT OR", "(MIT", "MIT AND AND ISC", "MIT WITH Nope", "LicenseRef-",
+	} {
+		assert.Error(t, ValidateLicense(invalid), invalid)
+	}
+}
+
+func TestValidatePackageField(t *testing.T) {
+	assert.NoError(t, ValidatePackageField("version", "1.2.0-rc.1"))
+	assert.ErrorContains(t, ValidatePackageField("version", "v1.2"), "not a semantic version")
+	assert.ErrorContains(t, ValidatePackageField("name", " "), "must not be empty")
+	assert.ErrorContains(t, ValidatePackageField("description", "two\nlines"), "single line")
+	assert.NoError(t, ValidatePackageField("description", ""))
+	assert.ErrorContains(t, ValidatePackageField("homepage", "x"), "unknown package field")
+}
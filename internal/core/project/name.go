@@ -0,0 +1,187 @@
+package project
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/nightconcept/almandine/internal/core/safepath"
+)
+
+// MaxDependencyNameLength is the longest accepted dependency name.
+const MaxDependencyNameLength = 64
+
+// dependencyNamePattern is the documented character set for dependency names: lowercase ASCII
+// letters, digits, '-' and '_', starting with a letter or digit. Every such name is a bare TOML
+// key, so manifests never need quoted or dotted keys.
+var dependencyNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ValidateDependencyName reports why name cannot be used as a dependency name, or returns nil.
+// Names are also used as file names (see 'almd add -n'), so reserved Windows device names such
+// as "con" are rejected as well.
+func ValidateDependencyName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("dependency name is empty")
+	case len(name) > MaxDependencyNameLength:
+		return fmt.Errorf("dependency name '%s' is longer than %d characters", name, MaxDependencyNameLength)
+	case !dependencyNamePattern.MatchString(name):
+		return fmt.Errorf("dependency name '%s' may only contain lowercase letters, digits, '-' and '_', and must start with a letter or digit", name)
+	}
+	if err := safepath.ValidateFileName(name); err != nil {
+		return fmt.Errorf("dependency name '%s' cannot be used as a file name: %w", name, err)
+	}
+	return nil
+}
+
+// NormalizeDependencyName folds name to the canonical lowercase form.
+func NormalizeDependencyName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// SuggestDependencyName derives a valid dependency name from an arbitrary string such as a file
+// name: it is lowercased, accented Latin letters lose their accents, runs of other characters
+// become '-', and reserved names get a "-lib" suffix. It returns "" when nothing usable is left.
+func SuggestDependencyName(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range foldDiacritics(NormalizeDependencyName(name)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			b.WriteRune(r)
+			dash = false
+		case !dash:
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	suggestion := strings.TrimLeft(b.String(), "-_")
+	if len(suggestion) > MaxDependencyNameLength {
+		suggestion = suggestion[:MaxDependencyNameLength]
+	}
+	suggestion = strings.TrimRight(suggestion, "-")
+	if suggestion == "" {
+		return ""
+	}
+	if safepath.ValidateFileName(suggestion) != nil {
+		suggestion += "-lib"
+	}
+	return suggestion
+}
+
+// DependencyNameProblem is a manifest dependency name, or a set of names colliding in case,
+// that breaks the naming rules.
+type DependencyNameProblem struct {
+	Names []string
+	Err   error
+}
+
+// CheckDependencyNames reports every dependency name of a manifest that breaks the naming rules.
+// Names are compared in their normalized form, so two entries differing only in case are
+// reported as a collision. Entries marked LegacyName are checked like any other.
+func CheckDependencyNames(deps map[string]Dependency) []DependencyNameProblem {
+	var problems []DependencyNameProblem
+	seen := make(map[string]string, len(deps))
+	for _, name := range sortedDependencyNames(deps) {
+		normalized := NormalizeDependencyName(name)
+		if err := ValidateDependencyName(normalized); err != nil {
+			if suggestion := SuggestDependencyName(name); suggestion != "" {
+				err = fmt.Errorf("%w; rename it, for example to '%s'", err, suggestion)
+			}
+			problems = append(problems, DependencyNameProblem{Names: []string{name}, Err: err})
+			continue
+		}
+		if other, ok := seen[normalized]; ok {
+			problems = append(problems, DependencyNameProblem{
+				Names: []string{other, name},
+				Err:   fmt.Errorf("dependency names '%s' and '%s' differ only in case; rename one of them", other, name),
+			})
+			continue
+		}
+		seen[normalized] = name
+	}
+	return problems
+}
+
+// ValidateDependencyNames returns the first problem CheckDependencyNames finds, ignoring those
+// that only involve entries marked LegacyName: manifests written by older versions still load
+// and can be written back, but new names must follow the rules.
+func ValidateDependencyNames(deps map[string]Dependency) error {
+	for _, problem := range CheckDependencyNames(deps) {
+		for _, name := range problem.Names {
+			if !deps[name].LegacyName {
+				return problem.Err
+			}
+		}
+	}
+	return nil
+}
+
+// FindDependency looks up name in deps by its normalized form, so "MyLib" finds an entry
+// declared as "mylib" and the other way round. It returns the key as written in the manifest.
+func FindDependency(deps map[string]Dependency, name string) (key string, dep Dependency, ok bool) {
+	if dep, ok = deps[name]; ok {
+		return name, dep, true
+	}
+	normalized := NormalizeDependencyName(name)
+	for _, key := range sortedDependencyNames(deps) {
+		if NormalizeDependencyName(key) == normalized {
+			return key, deps[key], true
+		}
+	}
+	return "", Dependency{}, false
+}
+
+func sortedDependencyNames(deps map[string]Dependency) []string {
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// diacriticFolds maps accented lowercase Latin letters to their base letters, so a file named
+// "ünïcode.lua" suggests "unicode" rather than losing the letters.
+var diacriticFolds = func() map[rune]string {
+	folds := map[rune]string{'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ł': "l", 'þ': "th"}
+	for base, accented := range map[string]string{
+		"a": "àáâãäåāăą", "c": "çćĉċč", "d": "ď", "e": "èéêëēĕėęě", "g": "ĝğġģ", "h": "ĥħ",
+		"i": "ìíîïĩīĭįı", "j": "ĵ", "k": "ķ", "l": "ĺļľŀ", "n": "ñńņňŉ", "o": "òóôõöōŏő",
+		"r": "ŕŗř", "s": "śŝşš", "t": "ţťŧ", "u": "ùúûüũūŭůűų", "w": "ŵ", "y": "ýÿŷ", "z": "źżž",
+	} {
+		for _, r := range accented {
+			folds[r] = base
+		}
+	}
+	return folds
+}()
+
+func foldDiacritics(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if folded, ok := diacriticFolds[r]; ok {
+			b.WriteString(folded)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// extensionPattern is the accepted form of a default extension, without its leading dot.
+var extensionPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// NormalizeExtension turns a configured default extension ("lua", ".lua" or "none") into the
+// suffix to append: ".lua", or "" for none.
+func NormalizeExtension(ext string) (string, error) {
+	ext = strings.TrimSpace(ext)
+	if ext == "" || ext == "none" {
+		return "", nil
+	}
+	if !extensionPattern.MatchString(strings.TrimPrefix(ext, ".")) {
+		return "", fmt.Errorf("invalid default extension '%s': use letters, digits, '-' or '_', e.g. .lua, or \"none\"", ext)
+	}
+	return "." + strings.TrimPrefix(ext, "."), nil
+}
@@ -0,0 +1,50 @@
+package project_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nightconcept/almandine/internal/core/project"
+)
+
+func TestValidateDependencyName(t *testing.T) {
+	for _, name := range []string{"lib", "json-lua", "lua_utils", "3d", "a"} {
+		assert.NoError(t, project.ValidateDependencyName(name), name)
+	}
+	for _, name := range []string{"", "Lib", "json.min", "-lib", "_lib", "my lib", "a/b", "nul", "com1", string(make([]byte, 65))} {
+		assert.Error(t, project.ValidateDependencyName(name), name)
+	}
+}
+
+func TestSuggestDependencyName(t *testing.T) {
+	for input, want := range map[string]string{
+		"JSON.min":     "json-min",
+		"  My Lib!! ":  "my-lib",
+		"__private":    "private",
+		"lua_utils":    "lua_utils",
+		"con":          "con-lib",
+		"...":          "",
+		"ünïcode-name": "unicode-name",
+		"Straße":       "strasse",
+		"日本語":          "",
+	} {
+		suggestion := project.SuggestDependencyName(input)
+		assert.Equal(t, want, suggestion, input)
+		if suggestion != "" {
+			assert.NoError(t, project.ValidateDependencyName(suggestion), input)
+		}
+	}
+}
+
+func TestNormalizeExtension(t *testing.T) {
+	for in, want := range map[string]string{"": "", "none": "", "lua": ".lua", ".lua": ".lua", " .sh ": ".sh"} {
+		got, err := project.NormalizeExtension(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	for _, bad := range []string{".", "..lua", "l/ua", ".lua.bak"} {
+		_, err := project.NormalizeExtension(bad)
+		assert.Error(t, err, bad)
+	}
+}
@@ -1,10 +1,67 @@
 package project
 
+import (
+	"path"
+	"path/filepath"
+	"sort"
+)
+
 // Project represents the overall structure of the project.toml file.
 type Project struct {
-	Package      *PackageInfo          `toml:"package"`
-	Scripts      map[string]string     `toml:"scripts,omitempty"`
-	Dependencies map[string]Dependency `toml:"dependencies,omitempty"`
+	Package          *PackageInfo                `toml:"package"`
+	Scripts          map[string]Script           `toml:"scripts,omitempty"`
+	Profiles         map[string]Profile          `toml:"profiles,omitempty"`
+	Vendor           *VendorSettings             `toml:"vendor,omitempty"`
+	Budget           *Budget                     `toml:"budget,omitempty"`
+	Defaults         *Defaults                   `toml:"defaults,omitempty"`
+	Registries       map[string]Registry         `toml:"registries,omitempty"`
+	Providers        map[string]ProviderConfig   `toml:"providers,omitempty"`
+	GitHubEnterprise map[string]GitHubEnterprise `toml:"github_enterprise,omitempty"` // By source alias
+	Dependencies     map[string]Dependency       `toml:"dependencies,omitempty"`
+}
+
+// VendorSettings controls how dependency files are written into the project ([vendor] table).
+type VendorSettings struct {
+	Header bool   `toml:"header,omitempty"`  // Prepend a provenance comment; see the vendorheader package
+	LibDir string `toml:"lib_dir,omitempty"` // Default target directory for 'add', over the global lib_dir
+	// StrictEnv fails loading when a dependency source references an undefined ${VAR}.
+	StrictEnv bool `toml:"strict_env,omitempty"`
+	// DefaultExt is the extension 'add' gives a file whose source path has none, e.g. ".lua",
+	// over the global default_ext. "none" keeps such files extensionless.
+	DefaultExt string `toml:"default_ext,omitempty"`
+}
+
+// VendorLibDir returns the project's default directory for new dependencies, or "" if unset.
+func (p *Project) VendorLibDir() string {
+	if p == nil || p.Vendor == nil {
+		return ""
+	}
+	return p.Vendor.LibDir
+}
+
+// VendorDefaultExt returns the project's extension for extensionless sources, or "" if unset.
+func (p *Project) VendorDefaultExt() string {
+	if p == nil || p.Vendor == nil {
+		return ""
+	}
+	return p.Vendor.DefaultExt
+}
+
+// VendorHeaderEnabled reports whether dependency files get a provenance header.
+func (p *Project) VendorHeaderEnabled() bool {
+	return p != nil && p.Vendor != nil && p.Vendor.Header
+}
+
+// Profile bundles install settings under a name (e.g. [profiles.ci]) so they can be
+// selected with `almd install --profile <name>` instead of passing each flag.
+type Profile struct {
+	Force   bool `toml:"force,omitempty"`
+	Verbose bool `toml:"verbose,omitempty"`
+	NoPrune bool `toml:"no_prune,omitempty"`
+	Frozen  bool `toml:"frozen,omitempty"`
+	Strict  bool `toml:"strict,omitempty"`
+	// FailFast selects --fail-fast; leave it unset for the --keep-going default.
+	FailFast bool `toml:"fail_fast,omitempty"`
 }
 
 // PackageInfo holds metadata for the project.
@@ -17,8 +74,59 @@ type PackageInfo struct {
 
 // Dependency represents a single dependency in the project.toml file.
 type Dependency struct {
-	Source string `toml:"source"`
-	Path   string `toml:"path"`
+	Source    string   `toml:"source"`
+	Path      string   `toml:"path"`
+	Mode      string   `toml:"mode,omitempty"`      // Octal file mode such as "0755"; see the filemode package
+	Transform string   `toml:"transform,omitempty"` // Rewrite applied on download such as "strip-comments"; see the transform package
+	Labels    []string `toml:"labels,omitempty"`    // Free-form groups selected with --label, e.g. ["ui", "thirdparty"]
+	// ReviewAfter is a "YYYY-MM-DD" date after which 'outdated' and 'self doctor' flag the pin for review.
+	ReviewAfter string `toml:"review_after,omitempty"`
+	// Headers are extra HTTP headers sent with this dependency's downloads only, e.g. an API key.
+	Headers map[string]string `toml:"headers,omitempty"`
+	// Install is how the file is put in the project: one of the Install* modes, empty for copy.
+	Install string `toml:"install,omitempty"`
+	// Generate names the script of [scripts] that builds a generated dependency; see InstallGenerate.
+	Generate string `toml:"generate,omitempty"`
+	// Files lists the files of a directory dependency, whose source names a directory or an
+	// archive, relative to that directory and to Path, where they are installed.
+	Files []string `toml:"files,omitempty"`
+	// Extract holds the "SRC[=DEST]" specs that select the Files of an archive source and where
+	// they are extracted; see the archive package. Empty extracts the whole archive.
+	Extract []string `toml:"extract,omitempty"`
+
+	// SourceTemplate is Source as written when it contained ${VAR} references; see ExpandSources.
+	SourceTemplate string `toml:"-"`
+	// HeaderTemplates is Headers as written when a value contained ${VAR} references; see ExpandHeaders.
+	HeaderTemplates map[string]string `toml:"-"`
+	// LegacyName is set on load when the entry's name breaks the naming rules, so the manifest
+	// can still be written back; see ValidateDependencyNames.
+	LegacyName bool `toml:"-"`
+}
+
+// DependencyPaths returns the path of every dependency file, in no particular order.
+func (p *Project) DependencyPaths() []string {
+	paths := make([]string, 0, len(p.Dependencies))
+	for _, dep := range p.Dependencies {
+		paths = append(paths, dep.FilePaths()...)
+	}
+	return paths
+}
+
+// DependencyDirs returns the sorted, distinct directories of the dependency files, with forward
+// slashes. A directory dependency counts with the directory it is in, from which its modules are
+// required as "<directory>.<module>".
+func (p *Project) DependencyDirs() []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, dep := range p.Dependencies {
+		dir := path.Dir(filepath.ToSlash(filepath.Clean(dep.Path)))
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
 }
 
 // LockFile represents the structure of the almd-lock.toml file.
@@ -38,7 +146,7 @@ type LockPackageDetail struct {
 func NewProject() *Project {
 	return &Project{
 		Package:      &PackageInfo{},
-		Scripts:      make(map[string]string),
+		Scripts:      make(map[string]Script),
 		Dependencies: make(map[string]Dependency),
 	}
 }
@@ -0,0 +1,43 @@
+package project
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ReviewDateLayout is the format of a dependency's review_after date.
+const ReviewDateLayout = "2006-01-02"
+
+// ReviewDue reports whether the dependency has a review_after date that has passed by now. The
+// review is due from the day after the date. Dependencies without a valid date are never due.
+func (d Dependency) ReviewDue(now time.Time) bool {
+	date, err := time.Parse(ReviewDateLayout, d.ReviewAfter)
+	if err != nil {
+		return false
+	}
+	return now.Format(ReviewDateLayout) > date.Format(ReviewDateLayout)
+}
+
+// OverdueReviews returns the sorted names of the dependencies of deps whose review is due by now.
+func OverdueReviews(deps map[string]Dependency, now time.Time) []string {
+	var names []string
+	for name, dep := range deps {
+		if dep.ReviewDue(now) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateReviewAfter checks the review_after date of one dependency, which is optional.
+func ValidateReviewAfter(name, value string) error {
+	if value == "" {
+		return nil
+	}
+	if _, err := time.Parse(ReviewDateLayout, value); err != nil {
+		return fmt.Errorf("dependency '%s' has invalid review_after '%s'; expected a date such as \"2025-12-01\"", name, value)
+	}
+	return nil
+}
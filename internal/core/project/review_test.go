@@ -0,0 +1,30 @@
+package project_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nightconcept/almandine/internal/core/project"
+)
+
+func TestReviewDue(t *testing.T) {
+	now := time.Date(2025, 12, 2, 9, 0, 0, 0, time.UTC)
+	deps := map[string]project.Dependency{
+		"due":      {ReviewAfter: "2025-12-01"},
+		"today":    {ReviewAfter: "2025-12-02"},
+		"later":    {ReviewAfter: "2026-06-01"},
+		"unset":    {},
+		"also-due": {ReviewAfter: "2024-01-31"},
+	}
+	assert.True(t, deps["due"].ReviewDue(now))
+	assert.False(t, deps["today"].ReviewDue(now), "the review is due from the day after the date")
+	assert.False(t, deps["unset"].ReviewDue(now))
+	assert.Equal(t, []string{"also-due", "due"}, project.OverdueReviews(deps, now))
+
+	assert.NoError(t, project.ValidateReviewAfter("json", ""))
+	assert.NoError(t, project.ValidateReviewAfter("json", "2025-12-01"))
+	assert.Error(t, project.ValidateReviewAfter("json", "12/01/2025"))
+	assert.Error(t, project.ValidateReviewAfter("json", "2025-13-01"))
+}
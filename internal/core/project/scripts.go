@@ -0,0 +1,128 @@
+package project
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/nightconcept/almandine/internal/core/tomledit"
+)
+
+// Script is one entry of the [scripts] table. It is written as its command line, or as a table
+// when it needs other scripts to run first: test = { cmd = "busted", depends_on = ["build"] }.
+type Script struct {
+	Cmd       string
+	DependsOn []string
+}
+
+// UnmarshalTOML reads either form of a script.
+func (s *Script) UnmarshalTOML(data any) error {
+	switch v := data.(type) {
+	case string:
+		*s = Script{Cmd: v}
+		return nil
+	case map[string]any:
+		*s = Script{}
+		for key, value := range v {
+			switch key {
+			case "cmd":
+				cmd, ok := value.(string)
+				if !ok {
+					return fmt.Errorf("script cmd must be a string")
+				}
+				s.Cmd = cmd
+			case "depends_on":
+				deps, ok := value.([]any)
+				if !ok {
+					return fmt.Errorf("script depends_on must be a list of script names")
+				}
+				for _, dep := range deps {
+					name, ok := dep.(string)
+					if !ok {
+						return fmt.Errorf("script depends_on must be a list of script names")
+					}
+					s.DependsOn = append(s.DependsOn, name)
+				}
+			default:
+				return fmt.Errorf("unknown script key '%s' (expected cmd or depends_on)", key)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("a script must be a command string or a table with cmd and depends_on")
+	}
+}
+
+// MarshalTOML writes a script without dependencies as its command line and any other script
+// as an inline table.
+func (s Script) MarshalTOML() ([]byte, error) {
+	if len(s.DependsOn) == 0 {
+		return []byte(tomledit.Quote(s.Cmd)), nil
+	}
+	deps := make([]string, len(s.DependsOn))
+	for i, dep := range s.DependsOn {
+		deps[i] = tomledit.Quote(dep)
+	}
+	return []byte(fmt.Sprintf("{ cmd = %s, depends_on = [%s] }", tomledit.Quote(s.Cmd), strings.Join(deps, ", "))), nil
+}
+
+// ValidateScripts checks the [scripts] table: every script has a command, and depends_on only
+// names other scripts without forming a cycle.
+func ValidateScripts(scripts map[string]Script) error {
+	names := make([]string, 0, len(scripts))
+	for name := range scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		script := scripts[name]
+		if strings.TrimSpace(script.Cmd) == "" {
+			return fmt.Errorf("script '%s' has no command", name)
+		}
+		for _, dep := range script.DependsOn {
+			if _, ok := scripts[dep]; !ok {
+				return fmt.Errorf("script '%s' depends on unknown script '%s'", name, dep)
+			}
+		}
+	}
+	_, err := ScriptOrder(scripts, names)
+	return err
+}
+
+// ScriptOrder returns names together with every script they depend on, directly or not, each
+// once and after its dependencies. It is an error when a name is not a script or the
+// dependencies form a cycle.
+func ScriptOrder(scripts map[string]Script, names []string) ([]string, error) {
+	var order, visiting []string
+	done := make(map[string]bool)
+	var visit func(name string) error
+	visit = func(name string) error {
+		if done[name] {
+			return nil
+		}
+		if i := slices.Index(visiting, name); i != -1 {
+			return fmt.Errorf("scripts depend on each other in a cycle: %s", strings.Join(append(visiting[i:], name), " -> "))
+		}
+		script, ok := scripts[name]
+		if !ok {
+			return fmt.Errorf("no script named '%s' in [scripts]", name)
+		}
+		visiting = append(visiting, name)
+		for _, dep := range script.DependsOn {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		visiting = visiting[:len(visiting)-1]
+		done[name] = true
+		order = append(order, name)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,38 @@
+package project_test
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/project"
+)
+
+func TestScripts(t *testing.T) {
+	var proj project.Project
+	_, err := toml.Decode(`
+[scripts]
+build = "luac -p src/main.lua"
+test = { cmd = "busted", depends_on = ["build"] }
+`, &proj)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]project.Script{
+		"build": {Cmd: "luac -p src/main.lua"},
+		"test":  {Cmd: "busted", DependsOn: []string{"build"}},
+	}, proj.Scripts)
+	require.NoError(t, project.ValidateScripts(proj.Scripts))
+
+	_, err = toml.Decode("[scripts]\ntest = { run = \"busted\" }\n", &proj)
+	assert.ErrorContains(t, err, "unknown script key 'run'")
+
+	assert.ErrorContains(t, project.ValidateScripts(map[string]project.Script{
+		"test": {Cmd: "busted", DependsOn: []string{"build"}},
+	}), "script 'test' depends on unknown script 'build'")
+	assert.ErrorContains(t, project.ValidateScripts(map[string]project.Script{
+		"a": {Cmd: "a", DependsOn: []string{"b"}},
+		"b": {Cmd: "b", DependsOn: []string{"a"}},
+	}), "cycle: a -> b -> a")
+	assert.ErrorContains(t, project.ValidateScripts(map[string]project.Script{"empty": {}}), "script 'empty' has no command")
+}
@@ -0,0 +1,127 @@
+package project
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Registry redirects downloads to a mirror ([registries.<name>] table), so a repository can say
+// where its dependencies are fetched from without any global setup. Sources and the lockfile
+// keep the upstream URLs.
+type Registry struct {
+	Upstream string `toml:"upstream,omitempty"` // URL prefix that is replaced, e.g. "https://raw.githubusercontent.com/"
+	URL      string `toml:"url,omitempty"`      // Prefix downloads are fetched from instead
+	// Advisories is the URL of the registry's index of deprecated and yanked sources (see package
+	// advisory). A registry may publish only advisories, without upstream and url.
+	Advisories string `toml:"advisories,omitempty"`
+}
+
+// Mirrors reports whether the registry redirects downloads.
+func (r Registry) Mirrors() bool {
+	return r.Upstream != "" || r.URL != ""
+}
+
+// ProviderConfig configures a source provider for this project ([providers.<name>] table, named
+// after the provider, e.g. [providers.github]).
+type ProviderConfig struct {
+	APIURL  string `toml:"api_url,omitempty"`  // API endpoint used instead of the provider's default
+	BaseURL string `toml:"base_url,omitempty"` // Site of a self-hosted instance, e.g. for [providers.gitea]
+}
+
+// GitHubEnterprise is a GitHub Enterprise Server instance ([github_enterprise.<alias>] table).
+// Sources on it are written "<alias>:owner/repo/path@ref", or as URLs on the instance.
+type GitHubEnterprise struct {
+	BaseURL  string `toml:"base_url"`            // Site of the instance, e.g. "https://github.mycorp.com"
+	APIURL   string `toml:"api_url,omitempty"`   // REST API endpoint, by default base_url + "/api/v3"
+	RawURL   string `toml:"raw_url,omitempty"`   // Prefix of raw file URLs, by default base_url + "/raw"
+	TokenEnv string `toml:"token_env,omitempty"` // Environment variable holding the API token
+}
+
+// ValidateSources checks the [registries], [providers] and [github_enterprise] tables.
+// knownProviders lists the provider names a [providers] table may use; an enterprise alias must
+// not be one of them.
+func (p *Project) ValidateSources(knownProviders []string) error {
+	for _, name := range slices.Sorted(maps.Keys(p.Registries)) {
+		if err := p.Registries[name].validate(); err != nil {
+			return fmt.Errorf("[registries.%s] %w", name, err)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(p.Providers)) {
+		if !slices.Contains(knownProviders, name) {
+			return fmt.Errorf("[providers.%s]: unknown provider (known: %s)", name, strings.Join(knownProviders, ", "))
+		}
+		if api := p.Providers[name].APIURL; api != "" {
+			if err := checkSourceURL(api); err != nil {
+				return fmt.Errorf("[providers.%s] api_url: %w", name, err)
+			}
+		}
+		if base := p.Providers[name].BaseURL; base != "" {
+			if err := checkSourceURL(base); err != nil {
+				return fmt.Errorf("[providers.%s] base_url: %w", name, err)
+			}
+		}
+	}
+	for _, alias := range slices.Sorted(maps.Keys(p.GitHubEnterprise)) {
+		if err := p.GitHubEnterprise[alias].validate(alias, knownProviders); err != nil {
+			return fmt.Errorf("[github_enterprise.%s] %w", alias, err)
+		}
+	}
+	return nil
+}
+
+func (g GitHubEnterprise) validate(alias string, knownProviders []string) error {
+	if slices.Contains(knownProviders, alias) {
+		return fmt.Errorf("alias is the name of a built-in provider; choose another")
+	}
+	for _, r := range alias {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			return fmt.Errorf("alias may only contain lowercase letters, digits and '-'")
+		}
+	}
+	if err := checkSourceURL(g.BaseURL); err != nil {
+		return fmt.Errorf("base_url: %w", err)
+	}
+	if g.APIURL != "" {
+		if err := checkSourceURL(g.APIURL); err != nil {
+			return fmt.Errorf("api_url: %w", err)
+		}
+	}
+	if g.RawURL != "" {
+		if err := checkSourceURL(g.RawURL); err != nil {
+			return fmt.Errorf("raw_url: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r Registry) validate() error {
+	if r.Mirrors() || r.Advisories == "" {
+		if err := checkSourceURL(r.Upstream); err != nil {
+			return fmt.Errorf("upstream: %w", err)
+		}
+		if err := checkSourceURL(r.URL); err != nil {
+			return fmt.Errorf("url: %w", err)
+		}
+	}
+	if r.Advisories != "" {
+		if err := checkSourceURL(r.Advisories); err != nil {
+			return fmt.Errorf("advisories: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkSourceURL requires an absolute URL such as "https://mirror.example.com/raw/".
+func checkSourceURL(raw string) error {
+	if raw == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || (u.Host == "" && u.Scheme != "file") {
+		return fmt.Errorf("'%s' is not an absolute URL", raw)
+	}
+	return nil
+}
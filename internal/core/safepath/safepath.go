@@ -0,0 +1,102 @@
+// Package safepath checks dependency file names and paths before they are written to disk.
+//
+// Names are validated against the rules of every platform almd supports, not just the current
+// one, so a project created on Linux cannot end up with files that a Windows checkout is unable
+// to create (reserved device names such as CON or NUL, characters like ':' or '?', or trailing
+// periods). Long paths are adapted with the \\?\ prefix on Windows.
+package safepath
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// MaxPath is the classic Windows path length limit beyond which the \\?\ prefix is required.
+const MaxPath = 260
+
+// maxNameLength is the longest file name (in bytes) accepted by common file systems.
+const maxNameLength = 255
+
+// invalidChars are characters Windows does not allow in file names.
+const invalidChars = `<>:"/\|?*`
+
+// reservedNames are Windows device names that cannot be used as a file name with any extension.
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// ValidateFileName reports why name cannot be used as a file name on all supported platforms,
+// or returns nil if it can.
+func ValidateFileName(name string) error {
+	switch name {
+	case "", ".", "..":
+		return fmt.Errorf("'%s' is not a valid file name", name)
+	}
+	if len(name) > maxNameLength {
+		return fmt.Errorf("file name '%s' is longer than %d characters", name, maxNameLength)
+	}
+	for _, r := range name {
+		if r < 0x20 || strings.ContainsRune(invalidChars, r) {
+			return fmt.Errorf("file name '%s' contains the character %q, which is not allowed on Windows", name, r)
+		}
+	}
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		return fmt.Errorf("file name '%s' ends with a period or space, which Windows silently removes", name)
+	}
+	stem, _, _ := strings.Cut(name, ".")
+	if reservedNames[strings.ToUpper(strings.TrimRight(stem, " "))] {
+		return fmt.Errorf("file name '%s' uses the reserved Windows device name '%s'", name, strings.ToUpper(stem))
+	}
+	return nil
+}
+
+// ValidateRelPath validates every component of a project-relative path. Both '/' and the OS
+// separator are accepted; "." and ".." components are allowed, absolute paths are not.
+func ValidateRelPath(rel string) error {
+	if rel == "" {
+		return fmt.Errorf("path is empty")
+	}
+	slashed := filepath.ToSlash(rel)
+	if strings.HasPrefix(slashed, "/") || filepath.IsAbs(rel) || filepath.VolumeName(rel) != "" {
+		return fmt.Errorf("path '%s' must be relative to the project root", rel)
+	}
+	for _, part := range strings.Split(slashed, "/") {
+		if part == "" || part == "." || part == ".." {
+			continue
+		}
+		if err := ValidateFileName(part); err != nil {
+			return fmt.Errorf("path '%s': %w", rel, err)
+		}
+	}
+	return nil
+}
+
+// LongPath returns a form of p that can be passed to file system calls even when it exceeds
+// MaxPath. On Windows, long paths are made absolute and given the \\?\ prefix (Go only does
+// this itself for paths that are already absolute); elsewhere p is returned unchanged.
+func LongPath(p string) string {
+	if runtime.GOOS != "windows" || len(p) < MaxPath-12 {
+		return p
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return p
+	}
+	return longPath(runtime.GOOS, abs)
+}
+
+// longPath adds the extended-length prefix to an absolute, cleaned path on Windows. The margin
+// below MaxPath leaves room for the 8.3 file name Windows appends when creating directories.
+func longPath(goos, abs string) string {
+	if goos != "windows" || len(abs) < MaxPath-12 || strings.HasPrefix(abs, `\\?\`) {
+		return abs
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + abs[2:]
+	}
+	return `\\?\` + abs
+}
@@ -0,0 +1,64 @@
+package safepath
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateFileName(t *testing.T) {
+	valid := []string{"inspect.lua", "json-1.0.lua", "CONSOLE.lua", "con_utils.lua", ".luacheckrc", "a b.lua"}
+	for _, name := range valid {
+		assert.NoError(t, ValidateFileName(name), name)
+	}
+
+	invalid := map[string]string{
+		"":                       "not a valid file name",
+		"..":                     "not a valid file name",
+		"CON":                    "reserved Windows device name 'CON'",
+		"nul.lua":                "reserved Windows device name 'NUL'",
+		"Com1.tar.gz":            "reserved Windows device name 'COM1'",
+		"lib:v2.lua":             "contains the character ':'",
+		"what?.lua":              "contains the character '?'",
+		"tab\there.lua":          "contains the character '\\t'",
+		"trailing.":              "ends with a period or space",
+		"trailing ":              "ends with a period or space",
+		strings.Repeat("a", 256): "longer than 255 characters",
+	}
+	for name, want := range invalid {
+		err := ValidateFileName(name)
+		require.Error(t, err, name)
+		assert.Contains(t, err.Error(), want, name)
+	}
+}
+
+func TestValidateRelPath(t *testing.T) {
+	assert.NoError(t, ValidateRelPath("src/lib/inspect.lua"))
+	assert.NoError(t, ValidateRelPath("./vendor/../lib/x.lua"))
+
+	err := ValidateRelPath("src/aux/x.lua")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "reserved Windows device name 'AUX'")
+
+	err = ValidateRelPath("/etc/x.lua")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "must be relative")
+
+	assert.Error(t, ValidateRelPath(""))
+}
+
+func TestLongPath(t *testing.T) {
+	short := `C:\proj\src\lib\x.lua`
+	assert.Equal(t, short, longPath("windows", short))
+
+	long := `C:\proj\` + strings.Repeat(`deep\`, 60) + "x.lua"
+	assert.Equal(t, `\\?\`+long, longPath("windows", long))
+	assert.Equal(t, `\\?\`+long, longPath("windows", `\\?\`+long), "already prefixed paths are left alone")
+
+	unc := `\\server\share\` + strings.Repeat(`deep\`, 60) + "x.lua"
+	assert.Equal(t, `\\?\UNC\server\share\`+strings.Repeat(`deep\`, 60)+"x.lua", longPath("windows", unc))
+
+	assert.Equal(t, "/"+strings.Repeat("deep/", 60), longPath("linux", "/"+strings.Repeat("deep/", 60)))
+}
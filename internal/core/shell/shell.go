@@ -0,0 +1,16 @@
+// Package shell runs the commands of project scripts and generated dependencies through the
+// platform's shell: cmd /C on Windows and sh -c elsewhere.
+package shell
+
+import (
+	"os/exec"
+	"runtime"
+)
+
+// Command returns a command running cmd through the platform's shell in the current directory.
+func Command(cmd string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", cmd)
+	}
+	return exec.Command("sh", "-c", cmd)
+}
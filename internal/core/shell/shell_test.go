@@ -0,0 +1,17 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand(t *testing.T) {
+	out, err := Command("echo hello").Output()
+	require.NoError(t, err)
+	assert.Equal(t, "hello", strings.TrimSpace(string(out)))
+
+	assert.Error(t, Command("exit 3").Run(), "a failing command reports its status")
+}
@@ -0,0 +1,212 @@
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProviderBitbucket is the name of the built-in Bitbucket Cloud provider.
+const ProviderBitbucket = "bitbucket"
+
+// BitbucketBaseURL and BitbucketAPIBaseURL are the Bitbucket site and API that bitbucket:
+// sources and bitbucket.org URLs refer to. Tests override them with a mock server.
+var BitbucketBaseURL = "https://bitbucket.org"
+var BitbucketAPIBaseURL = "https://api.bitbucket.org/2.0"
+var BitbucketBaseURLMutex sync.Mutex // Mutex for BitbucketBaseURL and BitbucketAPIBaseURL (Exported)
+
+// bitbucketProvider is the built-in Provider for bitbucket.org and the
+// "bitbucket:workspace/repo/path@ref" shorthand.
+type bitbucketProvider struct{}
+
+func (bitbucketProvider) Name() string { return ProviderBitbucket }
+
+func (bitbucketProvider) Parse(sourceURL string) (*ParsedSourceInfo, bool, error) {
+	if strings.HasPrefix(sourceURL, "bitbucket:") {
+		info, err := parseBitbucketShorthandURL(sourceURL)
+		return info, true, err
+	}
+	u, err := url.Parse(sourceURL)
+	if err != nil || u.Host == "" {
+		return nil, false, nil
+	}
+	base, err := url.Parse(bitbucketBaseURL())
+	if err != nil || !strings.EqualFold(u.Host, base.Host) {
+		return nil, false, nil
+	}
+	info, err := parseBitbucketWebURL(u)
+	return info, true, err
+}
+
+// bitbucketCommit is the subset of a Bitbucket commit used by the provider.
+type bitbucketCommit struct {
+	Hash string    `json:"hash"`
+	Date time.Time `json:"date"`
+}
+
+func (bitbucketProvider) ResolveRef(info *ParsedSourceInfo) (string, error) {
+	apiURL := fmt.Sprintf("%s/commits/%s?path=%s&pagelen=1", bitbucketRepoAPIURL(info), url.PathEscape(info.Ref), url.QueryEscape(info.PathInRepo))
+	body, err := hostAPIGet("Bitbucket", apiURL)
+	if err != nil {
+		return "", err
+	}
+	var commits struct {
+		Values []bitbucketCommit `json:"values"`
+	}
+	if err := json.Unmarshal(body, &commits); err != nil {
+		return "", fmt.Errorf("failed to unmarshal Bitbucket API response (%s): %w. Body: %s", apiURL, err, string(body))
+	}
+	if len(commits.Values) == 0 {
+		return "", fmt.Errorf("no commits found for path '%s' at ref '%s' in Bitbucket repository '%s/%s'. The file might not exist at this path/ref", info.PathInRepo, info.Ref, info.Owner, info.Repo)
+	}
+	return commits.Values[0].Hash, nil
+}
+
+func (bitbucketProvider) CommitDate(info *ParsedSourceInfo, sha string) (time.Time, error) {
+	apiURL := fmt.Sprintf("%s/commit/%s", bitbucketRepoAPIURL(info), url.PathEscape(sha))
+	body, err := hostAPIGet("Bitbucket", apiURL)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var commit bitbucketCommit
+	if err := json.Unmarshal(body, &commit); err != nil {
+		return time.Time{}, fmt.Errorf("failed to unmarshal Bitbucket API response (%s): %w. Body: %s", apiURL, err, string(body))
+	}
+	if commit.Date.IsZero() {
+		return time.Time{}, fmt.Errorf("Bitbucket API response for commit %s in %s/%s has no date", sha, info.Owner, info.Repo)
+	}
+	return commit.Date, nil
+}
+
+func (bitbucketProvider) RawURL(info *ParsedSourceInfo, pathInRepo string) string {
+	return bitbucketRawURL(info.Owner, info.Repo, info.Ref, pathInRepo)
+}
+
+func (bitbucketProvider) ListTags(info *ParsedSourceInfo) ([]string, error) {
+	var names []string
+	apiURL := bitbucketRepoAPIURL(info) + "/refs/tags?pagelen=100"
+	for page := 1; apiURL != "" && page <= maxTagPages; page++ {
+		body, err := hostAPIGet("Bitbucket", apiURL)
+		if err != nil {
+			return nil, err
+		}
+		var tags struct {
+			Values []struct {
+				Name string `json:"name"`
+			} `json:"values"`
+			Next string `json:"next"`
+		}
+		if err := json.Unmarshal(body, &tags); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal Bitbucket API response (%s): %w. Body: %s", apiURL, err, string(body))
+		}
+		for _, t := range tags.Values {
+			names = append(names, t.Name)
+		}
+		apiURL = tags.Next
+	}
+	return names, nil
+}
+
+func (bitbucketProvider) FetchMetadata(info *ParsedSourceInfo) (*Metadata, error) {
+	apiURL := bitbucketRepoAPIURL(info)
+	body, err := hostAPIGet("Bitbucket", apiURL)
+	if err != nil {
+		return nil, err
+	}
+	var repo struct {
+		Description string `json:"description"`
+		Website     string `json:"website"`
+		Links       struct {
+			HTML struct {
+				Href string `json:"href"`
+			} `json:"html"`
+		} `json:"links"`
+		MainBranch struct {
+			Name string `json:"name"`
+		} `json:"mainbranch"`
+	}
+	if err := json.Unmarshal(body, &repo); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Bitbucket API response (%s): %w. Body: %s", apiURL, err, string(body))
+	}
+	meta := &Metadata{Description: repo.Description, HomepageURL: repo.Website, DefaultBranch: repo.MainBranch.Name}
+	if meta.HomepageURL == "" {
+		meta.HomepageURL = repo.Links.HTML.Href
+	}
+	return meta, nil
+}
+
+// bitbucketBaseURL returns the current Bitbucket site URL.
+func bitbucketBaseURL() string {
+	BitbucketBaseURLMutex.Lock()
+	defer BitbucketBaseURLMutex.Unlock()
+	return strings.TrimSuffix(BitbucketBaseURL, "/")
+}
+
+// bitbucketRepoAPIURL returns the API URL of the parsed source's repository: under the project's
+// [providers.bitbucket] api_url if set, otherwise under BitbucketAPIBaseURL.
+func bitbucketRepoAPIURL(info *ParsedSourceInfo) string {
+	apiURL := providerAPIURL(ProviderBitbucket)
+	if apiURL == "" {
+		BitbucketBaseURLMutex.Lock()
+		apiURL = strings.TrimSuffix(BitbucketAPIBaseURL, "/")
+		BitbucketBaseURLMutex.Unlock()
+	}
+	return fmt.Sprintf("%s/repositories/%s/%s", apiURL, info.Owner, info.Repo)
+}
+
+// bitbucketRawURL builds the raw content URL of a file at ref.
+func bitbucketRawURL(owner, repo, ref, pathInRepo string) string {
+	return fmt.Sprintf("%s/%s/%s/raw/%s/%s", bitbucketBaseURL(), owner, repo, url.PathEscape(ref), escapePath(pathInRepo))
+}
+
+// newBitbucketSourceInfo fills in a ParsedSourceInfo for a file of the repository owner/repo.
+func newBitbucketSourceInfo(owner, repo, pathInRepo, refType, ref string) (*ParsedSourceInfo, error) {
+	if owner == "" || repo == "" || pathInRepo == "" || ref == "" || strings.HasSuffix(pathInRepo, "/") {
+		return nil, fmt.Errorf("one or more components (workspace, repository, ref, path) are empty")
+	}
+	return &ParsedSourceInfo{
+		RawURL:            bitbucketRawURL(owner, repo, ref, pathInRepo),
+		CanonicalURL:      fmt.Sprintf("bitbucket:%s/%s/%s@%s", owner, repo, strings.ReplaceAll(pathInRepo, "%", "%25"), qualifyRef(refType, ref)),
+		Ref:               ref,
+		RefType:           refType,
+		Provider:          ProviderBitbucket,
+		Owner:             owner,
+		Repo:              repo,
+		PathInRepo:        pathInRepo,
+		SuggestedFilename: pathInRepo[strings.LastIndex(pathInRepo, "/")+1:],
+	}, nil
+}
+
+// parseBitbucketShorthandURL handles sources like "bitbucket:workspace/repo/path/to/file@ref".
+func parseBitbucketShorthandURL(sourceURL string) (*ParsedSourceInfo, error) {
+	repoAndPath, refType, ref, err := splitShorthand(sourceURL, ProviderBitbucket)
+	if err != nil {
+		return nil, err
+	}
+	var owner, repo, pathInRepo string
+	if parts := strings.SplitN(repoAndPath, "/", 3); len(parts) == 3 {
+		owner, repo, pathInRepo = parts[0], parts[1], parts[2]
+	}
+	info, err := newBitbucketSourceInfo(owner, repo, pathInRepo, refType, ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bitbucket shorthand source '%s': expected workspace/repo/path/to/file@ref: %w", sourceURL, err)
+	}
+	return info, nil
+}
+
+// parseBitbucketWebURL handles Bitbucket file URLs: /<workspace>/<repo>/src/<ref>/<path> as
+// shown in the browser and /<workspace>/<repo>/raw/<ref>/<path>.
+func parseBitbucketWebURL(u *url.URL) (*ParsedSourceInfo, error) {
+	parts := strings.SplitN(strings.Trim(u.Path, "/"), "/", 5)
+	if len(parts) < 5 || (parts[2] != "src" && parts[2] != "raw") {
+		return nil, fmt.Errorf("unsupported Bitbucket URL '%s'. Expected /<workspace>/<repo>/src/<ref>/<path> or /raw/<ref>/<path>, or bitbucket:workspace/repo/path@ref", u.String())
+	}
+	info, err := newBitbucketSourceInfo(parts[0], parts[1], parts[4], "", parts[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid Bitbucket URL '%s': %w", u.String(), err)
+	}
+	return info, nil
+}
@@ -0,0 +1,107 @@
+package source_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+// setupBitbucketTest points the Bitbucket provider's site and API at a mock server running handler.
+func setupBitbucketTest(t *testing.T, handler http.HandlerFunc) string {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	source.BitbucketBaseURLMutex.Lock()
+	originalBase, originalAPI := source.BitbucketBaseURL, source.BitbucketAPIBaseURL
+	source.BitbucketBaseURL, source.BitbucketAPIBaseURL = server.URL, server.URL+"/2.0"
+	source.BitbucketBaseURLMutex.Unlock()
+	t.Cleanup(func() {
+		server.Close()
+		source.BitbucketBaseURLMutex.Lock()
+		source.BitbucketBaseURL, source.BitbucketAPIBaseURL = originalBase, originalAPI
+		source.BitbucketBaseURLMutex.Unlock()
+	})
+	return server.URL
+}
+
+func TestParseSourceURL_Bitbucket(t *testing.T) {
+	sourceTestMutex.Lock()
+	defer sourceTestMutex.Unlock()
+
+	want := &source.ParsedSourceInfo{
+		RawURL:            "https://bitbucket.org/owner/repo/raw/main/src/lib.lua",
+		CanonicalURL:      "bitbucket:owner/repo/src/lib.lua@main",
+		Ref:               "main",
+		Provider:          source.ProviderBitbucket,
+		Owner:             "owner",
+		Repo:              "repo",
+		PathInRepo:        "src/lib.lua",
+		SuggestedFilename: "lib.lua",
+	}
+	for _, u := range []string{
+		"https://bitbucket.org/owner/repo/src/main/src/lib.lua",
+		"https://bitbucket.org/owner/repo/raw/main/src/lib.lua",
+		"bitbucket:owner/repo/src/lib.lua@main",
+	} {
+		got, err := source.ParseSourceURL(u)
+		require.NoError(t, err, u)
+		assert.Equal(t, want, got, u)
+	}
+
+	got, err := source.ParseSourceURL("bitbucket:owner/repo/lib.lua@tag:v1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, source.RefTypeTag, got.RefType)
+	assert.Equal(t, "bitbucket:owner/repo/lib.lua@tag:v1.0.0", got.CanonicalURL)
+
+	for _, bad := range []string{
+		"bitbucket:owner/repo/lib.lua",
+		"bitbucket:owner/lib.lua@main",
+		"https://bitbucket.org/owner/repo/commits/main",
+		"https://bitbucket.org/owner/repo/src/main/",
+		"https://bitbucket.org/owner/repo",
+	} {
+		_, err := source.ParseSourceURL(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestBitbucketProvider_ResolveRef(t *testing.T) {
+	sourceTestMutex.Lock()
+	defer sourceTestMutex.Unlock()
+
+	baseURL := setupBitbucketTest(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/2.0/repositories/owner/repo/commits/main":
+			assert.Equal(t, "src/lib.lua", r.URL.Query().Get("path"))
+			_, _ = w.Write([]byte(`{"values": [{"hash": "0123456789abcdef0123456789abcdef01234567"}]}`))
+		case "/2.0/repositories/owner/repo/refs/tags":
+			if r.URL.Query().Get("page") == "2" {
+				_, _ = w.Write([]byte(`{"values": [{"name": "v1.1.0"}]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"values": [{"name": "v1.0.0"}], "next": "http://` + r.Host + `/2.0/repositories/owner/repo/refs/tags?page=2"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	info, err := source.ParseSourceURL(baseURL + "/owner/repo/src/main/src/lib.lua")
+	require.NoError(t, err)
+	sha, err := source.ResolveRef(info)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef01234567", sha)
+
+	pinned, err := info.AtCommit(sha)
+	require.NoError(t, err)
+	assert.Equal(t, baseURL+"/owner/repo/raw/0123456789abcdef0123456789abcdef01234567/src/lib.lua", pinned.RawURL)
+
+	info, err = source.ParseSourceURL("bitbucket:owner/repo/src/lib.lua@v1.*")
+	require.NoError(t, err)
+	resolved, err := source.ResolveTagPattern(info)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.1.0", resolved.Ref)
+}
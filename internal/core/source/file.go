@@ -0,0 +1,60 @@
+package source
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ProviderFile is the name of the provider for "file:" sources, files elsewhere on the local
+// disk such as a sibling repository. Like plain URLs they cannot be pinned to a commit and are
+// locked by the SHA256 of their content.
+const ProviderFile = "file"
+
+// fileProvider is the built-in Provider for "file:path/to/file.lua" sources. Relative paths are
+// relative to the project root; "file:///abs/path" URLs name absolute paths.
+type fileProvider struct{}
+
+func (fileProvider) Name() string { return ProviderFile }
+
+func (fileProvider) Parse(sourceURL string) (*ParsedSourceInfo, bool, error) {
+	rest, ok := strings.CutPrefix(sourceURL, "file:")
+	if !ok {
+		return nil, false, nil
+	}
+	localPath := rest
+	if strings.HasPrefix(rest, "//") {
+		localPath = strings.TrimPrefix(rest, "//localhost")
+		localPath = strings.TrimPrefix(localPath, "//")
+		if !strings.HasPrefix(localPath, "/") {
+			return nil, true, fmt.Errorf("file source '%s' names a remote host; only local files are supported", sourceURL)
+		}
+	}
+	if localPath == "" || strings.HasSuffix(localPath, "/") {
+		return nil, true, fmt.Errorf("file source '%s' does not name a file", sourceURL)
+	}
+	return &ParsedSourceInfo{
+		RawURL:            sourceURL,
+		CanonicalURL:      sourceURL,
+		Provider:          ProviderFile,
+		PathInRepo:        localPath,
+		SuggestedFilename: path.Base(localPath),
+	}, true, nil
+}
+
+func (fileProvider) ResolveRef(info *ParsedSourceInfo) (string, error) {
+	return "", fmt.Errorf("'%s' is a local file and has no commits to resolve", info.RawURL)
+}
+
+// RawURL names pathInRepo next to the source file.
+func (fileProvider) RawURL(info *ParsedSourceInfo, pathInRepo string) string {
+	return "file:" + path.Join(path.Dir(info.PathInRepo), pathInRepo)
+}
+
+func (fileProvider) ListTags(info *ParsedSourceInfo) ([]string, error) {
+	return nil, fmt.Errorf("'%s' is a local file and has no tags", info.RawURL)
+}
+
+func (fileProvider) FetchMetadata(info *ParsedSourceInfo) (*Metadata, error) {
+	return nil, fmt.Errorf("'%s' is a local file and has no repository metadata", info.RawURL)
+}
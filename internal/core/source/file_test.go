@@ -0,0 +1,38 @@
+package source_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+func TestParseSourceURL_File(t *testing.T) {
+	sourceTestMutex.Lock()
+	defer sourceTestMutex.Unlock()
+
+	info, err := source.ParseSourceURL("file:../shared/lib/util.lua")
+	require.NoError(t, err)
+	assert.Equal(t, &source.ParsedSourceInfo{
+		RawURL:            "file:../shared/lib/util.lua",
+		CanonicalURL:      "file:../shared/lib/util.lua",
+		Provider:          source.ProviderFile,
+		PathInRepo:        "../shared/lib/util.lua",
+		SuggestedFilename: "util.lua",
+	}, info)
+	assert.False(t, source.HasCommits(info.Provider))
+	_, err = info.AtCommit("abcdef1234567890abcdef1234567890abcdef12")
+	assert.ErrorContains(t, err, "cannot be pinned to a commit")
+
+	info, err = source.ParseSourceURL("file:///opt/lua/inspect.lua")
+	require.NoError(t, err)
+	assert.Equal(t, "/opt/lua/inspect.lua", info.PathInRepo)
+	assert.Equal(t, "inspect.lua", info.SuggestedFilename)
+
+	_, err = source.ParseSourceURL("file://fileserver/share/lib.lua")
+	assert.ErrorContains(t, err, "remote host")
+	_, err = source.ParseSourceURL("file:../shared/")
+	assert.ErrorContains(t, err, "does not name a file")
+}
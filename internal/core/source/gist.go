@@ -0,0 +1,205 @@
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ProviderGist is the name of the provider for GitHub Gists, given as a gist page
+// (https://gist.github.com/user/id, optionally with a revision and #file=<name>) or as a raw
+// gist URL (https://gist.githubusercontent.com/user/id/raw/[revision/]file).
+const ProviderGist = "gist"
+
+// Hosts of gist pages and of raw gist content.
+const (
+	gistHost    = "gist.github.com"
+	gistRawHost = "gist.githubusercontent.com"
+)
+
+// gistProvider is the built-in Provider for GitHub Gists. A gist is a git repository whose
+// revisions are commits, so gist sources are pinned like any other. Owner is the gist's user,
+// Repo its ID and PathInRepo the file name; Ref is "HEAD" for the latest revision.
+type gistProvider struct{}
+
+func (gistProvider) Name() string { return ProviderGist }
+
+func (p gistProvider) Parse(sourceURL string) (*ParsedSourceInfo, bool, error) {
+	u, err := url.Parse(sourceURL)
+	if err != nil {
+		return nil, false, nil
+	}
+	switch strings.ToLower(u.Hostname()) {
+	case gistHost:
+		info, err := p.parsePageURL(u)
+		return info, true, err
+	case gistRawHost:
+		info, err := p.parseRawURL(u)
+		return info, true, err
+	default:
+		return nil, false, nil
+	}
+}
+
+// parsePageURL parses https://gist.github.com/user/id[/revision][#file=name]. Without a file
+// the source cannot be downloaded until ResolveGistFile names the gist's only file.
+func (p gistProvider) parsePageURL(u *url.URL) (*ParsedSourceInfo, error) {
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid gist URL '%s': expected https://%s/<user>/<id>[/<revision>]", u.String(), gistHost)
+	}
+	ref := "HEAD"
+	if len(parts) == 3 {
+		ref = parts[2]
+	}
+	fragment, err := url.ParseQuery(u.Fragment)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gist URL '%s': %w", u.String(), err)
+	}
+	return p.info(u.String(), parts[0], parts[1], ref, fragment.Get("file")), nil
+}
+
+// parseRawURL parses https://gist.githubusercontent.com/user/id/raw/[revision/]file.
+func (p gistProvider) parseRawURL(u *url.URL) (*ParsedSourceInfo, error) {
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 4 || len(parts) > 5 || parts[2] != "raw" {
+		return nil, fmt.Errorf("invalid raw gist URL '%s': expected https://%s/<user>/<id>/raw/[<revision>/]<file>", u.String(), gistRawHost)
+	}
+	ref := "HEAD"
+	if len(parts) == 5 {
+		ref = parts[3]
+	}
+	return p.info(u.String(), parts[0], parts[1], ref, parts[len(parts)-1]), nil
+}
+
+func (p gistProvider) info(canonicalURL, user, id, ref, file string) *ParsedSourceInfo {
+	info := &ParsedSourceInfo{
+		CanonicalURL:      canonicalURL,
+		Ref:               ref,
+		Provider:          ProviderGist,
+		Owner:             user,
+		Repo:              id,
+		PathInRepo:        file,
+		SuggestedFilename: file,
+	}
+	if file != "" {
+		info.RawURL = p.RawURL(info, file)
+	}
+	return info
+}
+
+// ResolveRef returns the gist's latest revision, or the revision the source names once the
+// gist is known to have the file at it. Unlike the repository providers this is not
+// necessarily the last revision that changed the file.
+func (gistProvider) ResolveRef(info *ParsedSourceInfo) (string, error) {
+	if info.PathInRepo == "" {
+		return "", fmt.Errorf("gist %s does not name a file; add #file=<name> to its URL", info.Repo)
+	}
+	gist, err := getGist(info.Repo, info.Ref)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := gist.Files[info.PathInRepo]; !ok {
+		return "", fmt.Errorf("gist %s has no file '%s' at revision %s (files: %s)", info.Repo, info.PathInRepo, info.Ref, strings.Join(gist.fileNames(), ", "))
+	}
+	if info.Ref != "HEAD" {
+		return info.Ref, nil
+	}
+	if len(gist.History) == 0 {
+		return "", fmt.Errorf("GitHub API response for gist %s has no revisions", info.Repo)
+	}
+	return gist.History[0].Version, nil
+}
+
+func (gistProvider) CommitDate(info *ParsedSourceInfo, sha string) (time.Time, error) {
+	gist, err := getGist(info.Repo, "HEAD")
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, revision := range gist.History {
+		if strings.HasPrefix(revision.Version, sha) {
+			return revision.CommittedAt, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("gist %s has no revision %s", info.Repo, sha)
+}
+
+// RawURL returns the raw URL of file in the gist, at the latest revision when the ref is "HEAD".
+func (gistProvider) RawURL(info *ParsedSourceInfo, file string) string {
+	rawURL := fmt.Sprintf("https://%s/%s/%s/raw/", gistRawHost, info.Owner, info.Repo)
+	if info.Ref != "HEAD" {
+		rawURL += info.Ref + "/"
+	}
+	return rawURL + url.PathEscape(file)
+}
+
+func (gistProvider) ListTags(info *ParsedSourceInfo) ([]string, error) {
+	return nil, fmt.Errorf("gist %s has no tags; pin a revision instead", info.Repo)
+}
+
+func (gistProvider) FetchMetadata(info *ParsedSourceInfo) (*Metadata, error) {
+	gist, err := getGist(info.Repo, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	return &Metadata{Description: gist.Description, HomepageURL: gist.HTMLURL}, nil
+}
+
+// gistInfo is the subset of the GitHub gist API response used by the provider.
+type gistInfo struct {
+	Description string              `json:"description"`
+	HTMLURL     string              `json:"html_url"`
+	Files       map[string]struct{} `json:"files"`
+	History     []struct {
+		Version     string    `json:"version"`
+		CommittedAt time.Time `json:"committed_at"`
+	} `json:"history"`
+}
+
+func (g *gistInfo) fileNames() []string {
+	names := make([]string, 0, len(g.Files))
+	for name := range g.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// getGist fetches gist id from the GitHub API, as of revision unless it is "HEAD".
+func getGist(id, revision string) (*gistInfo, error) {
+	apiURL := fmt.Sprintf("%s/gists/%s", githubAPIBaseURL(), url.PathEscape(id))
+	if revision != "HEAD" {
+		apiURL += "/" + url.PathEscape(revision)
+	}
+	body, err := githubAPIGet(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	var gist gistInfo
+	if err := json.Unmarshal(body, &gist); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal GitHub API response (%s): %w. Body: %s", apiURL, err, string(body))
+	}
+	return &gist, nil
+}
+
+// ResolveGistFile completes a gist source that does not name a file with the gist's only file,
+// recording it in the canonical URL as #file=<name>. Other sources are returned unchanged.
+func ResolveGistFile(p *ParsedSourceInfo) (*ParsedSourceInfo, error) {
+	if p.Provider != ProviderGist || p.PathInRepo != "" {
+		return p, nil
+	}
+	gist, err := getGist(p.Repo, p.Ref)
+	if err != nil {
+		return nil, err
+	}
+	names := gist.fileNames()
+	if len(names) != 1 {
+		return nil, fmt.Errorf("gist %s has %d files (%s); name one with #file=<name>", p.Repo, len(names), strings.Join(names, ", "))
+	}
+	base, _, _ := strings.Cut(p.CanonicalURL, "#")
+	resolved := gistProvider{}.info(base+"#file="+url.QueryEscape(names[0]), p.Owner, p.Repo, p.Ref, names[0])
+	return resolved, nil
+}
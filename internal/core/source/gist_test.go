@@ -0,0 +1,135 @@
+package source_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+const (
+	gistLatest   = "1111111111111111111111111111111111111111"
+	gistPrevious = "2222222222222222222222222222222222222222"
+)
+
+// setupGistTest points the GitHub API at a mock server that serves gist abc123 with the given files.
+func setupGistTest(t *testing.T, files string) {
+	t.Helper()
+	gist := `{"description": "Lua utilities", "html_url": "https://gist.github.com/abc123", "files": {` + files + `},
+		"history": [{"version": "` + gistLatest + `", "committed_at": "2024-05-02T10:00:00Z"},
+		{"version": "` + gistPrevious + `", "committed_at": "2024-05-01T10:00:00Z"}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gists/abc123", "/gists/abc123/" + gistPrevious:
+			_, _ = w.Write([]byte(gist))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	source.GithubAPIBaseURLMutex.Lock()
+	original := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = server.URL
+	source.GithubAPIBaseURLMutex.Unlock()
+	t.Cleanup(func() {
+		server.Close()
+		source.GithubAPIBaseURLMutex.Lock()
+		source.GithubAPIBaseURL = original
+		source.GithubAPIBaseURLMutex.Unlock()
+	})
+}
+
+func TestParseSourceURL_Gist(t *testing.T) {
+	sourceTestMutex.Lock()
+	defer sourceTestMutex.Unlock()
+
+	info, err := source.ParseSourceURL("https://gist.github.com/user/abc123#file=util.lua")
+	require.NoError(t, err)
+	assert.Equal(t, &source.ParsedSourceInfo{
+		RawURL:            "https://gist.githubusercontent.com/user/abc123/raw/util.lua",
+		CanonicalURL:      "https://gist.github.com/user/abc123#file=util.lua",
+		Ref:               "HEAD",
+		Provider:          source.ProviderGist,
+		Owner:             "user",
+		Repo:              "abc123",
+		PathInRepo:        "util.lua",
+		SuggestedFilename: "util.lua",
+	}, info)
+
+	info, err = source.ParseSourceURL("https://gist.githubusercontent.com/user/abc123/raw/" + gistPrevious + "/util.lua")
+	require.NoError(t, err)
+	assert.Equal(t, gistPrevious, info.Ref)
+	assert.Equal(t, "util.lua", info.PathInRepo)
+	assert.Equal(t, "https://gist.githubusercontent.com/user/abc123/raw/"+gistPrevious+"/util.lua", info.RawURL)
+
+	info, err = source.ParseSourceURL("https://gist.github.com/user/abc123")
+	require.NoError(t, err)
+	assert.Empty(t, info.PathInRepo)
+	assert.Empty(t, info.RawURL)
+
+	for _, bad := range []string{
+		"https://gist.github.com/user",
+		"https://gist.github.com/user/abc123/rev/extra",
+		"https://gist.githubusercontent.com/user/abc123/util.lua",
+	} {
+		_, err := source.ParseSourceURL(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestGistProvider_ResolveRef(t *testing.T) {
+	sourceTestMutex.Lock()
+	defer sourceTestMutex.Unlock()
+	setupGistTest(t, `"util.lua": {}, "README.md": {}`)
+
+	info, err := source.ParseSourceURL("https://gist.githubusercontent.com/user/abc123/raw/util.lua")
+	require.NoError(t, err)
+	sha, err := source.ResolveRef(info)
+	require.NoError(t, err)
+	assert.Equal(t, gistLatest, sha)
+
+	pinned, err := info.AtCommit(sha)
+	require.NoError(t, err)
+	assert.Equal(t, "https://gist.githubusercontent.com/user/abc123/raw/"+gistLatest+"/util.lua", pinned.RawURL)
+
+	date, err := source.CommitDate(info, gistPrevious[:7])
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), date)
+
+	info, err = source.ParseSourceURL("https://gist.github.com/user/abc123/" + gistPrevious + "#file=util.lua")
+	require.NoError(t, err)
+	sha, err = source.ResolveRef(info)
+	require.NoError(t, err)
+	assert.Equal(t, gistPrevious, sha)
+
+	info, err = source.ParseSourceURL("https://gist.github.com/user/abc123#file=missing.lua")
+	require.NoError(t, err)
+	_, err = source.ResolveRef(info)
+	assert.ErrorContains(t, err, "has no file 'missing.lua'")
+
+	meta, err := source.FetchMetadata(info)
+	require.NoError(t, err)
+	assert.Equal(t, "Lua utilities", meta.Description)
+}
+
+func TestResolveGistFile(t *testing.T) {
+	sourceTestMutex.Lock()
+	defer sourceTestMutex.Unlock()
+
+	setupGistTest(t, `"util.lua": {}`)
+	info, err := source.ParseSourceURL("https://gist.github.com/user/abc123")
+	require.NoError(t, err)
+	resolved, err := source.ResolveGistFile(info)
+	require.NoError(t, err)
+	assert.Equal(t, "https://gist.github.com/user/abc123#file=util.lua", resolved.CanonicalURL)
+	assert.Equal(t, "https://gist.githubusercontent.com/user/abc123/raw/util.lua", resolved.RawURL)
+	assert.Equal(t, "util.lua", resolved.SuggestedFilename)
+
+	setupGistTest(t, `"util.lua": {}, "README.md": {}`)
+	_, err = source.ResolveGistFile(info)
+	assert.ErrorContains(t, err, "has 2 files (README.md, util.lua)")
+}
@@ -0,0 +1,168 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ProviderGit is the name of the provider for git+<transport> sources, which reach any git host
+// through the git command instead of a host API:
+// git+https://git.example.com/owner/repo.git#path=lib/util.lua&ref=v1.2.0.
+const ProviderGit = "git"
+
+// gitTransports are the transports a git+ source may use.
+var gitTransports = []string{"https", "http", "ssh", "file"}
+
+// gitProvider is the built-in Provider for git+ sources. Their raw URLs are git+ URLs too,
+// which the downloader serves with a shallow fetch of the one commit.
+type gitProvider struct{}
+
+func (gitProvider) Name() string { return ProviderGit }
+
+func (p gitProvider) Parse(sourceURL string) (*ParsedSourceInfo, bool, error) {
+	if !strings.HasPrefix(sourceURL, "git+") {
+		return nil, false, nil
+	}
+	info, err := p.parse(sourceURL)
+	return info, true, err
+}
+
+func (p gitProvider) parse(sourceURL string) (*ParsedSourceInfo, error) {
+	u, err := url.Parse(sourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid git source '%s': %w", sourceURL, err)
+	}
+	if transport := strings.TrimPrefix(u.Scheme, "git+"); !slices.Contains(gitTransports, transport) {
+		return nil, fmt.Errorf("invalid git source '%s': unsupported transport '%s' (use git+https, git+http, git+ssh or git+file)", sourceURL, transport)
+	}
+	fragment, err := url.ParseQuery(u.Fragment)
+	if err != nil {
+		return nil, fmt.Errorf("invalid git source '%s': %w", sourceURL, err)
+	}
+	pathInRepo := strings.Trim(fragment.Get("path"), "/")
+	if pathInRepo == "" {
+		return nil, fmt.Errorf("invalid git source '%s': name the file as #path=<path>&ref=<ref>", sourceURL)
+	}
+	refType, ref, err := splitRefQualifier(fragment.Get("ref"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid git source '%s': %w", sourceURL, err)
+	}
+	// git would read a ref or path starting with "-" as an option, such as --upload-pack=<command>.
+	if strings.HasPrefix(ref, "-") || strings.HasPrefix(pathInRepo, "-") {
+		return nil, fmt.Errorf("invalid git source '%s': the path and ref must not start with '-'", sourceURL)
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	repoPath := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	info := &ParsedSourceInfo{
+		CanonicalURL:      sourceURL,
+		Ref:               ref,
+		RefType:           refType,
+		Provider:          ProviderGit,
+		Owner:             path.Dir(repoPath),
+		Repo:              path.Base(repoPath),
+		PathInRepo:        pathInRepo,
+		SuggestedFilename: path.Base(pathInRepo),
+	}
+	info.RawURL = gitRawURL(strings.SplitN(sourceURL, "#", 2)[0], info.RefSegment(), pathInRepo)
+	return info, nil
+}
+
+// ResolveRef returns the commit the ref points to. Unlike the API-backed providers this is not
+// necessarily the last commit that changed the file.
+func (gitProvider) ResolveRef(info *ParsedSourceInfo) (string, error) {
+	ref := info.RefSegment()
+	out, err := runGit("ls-remote", "--end-of-options", gitRepoURL(info), ref, ref+"^{}")
+	if err != nil {
+		return "", err
+	}
+	refs := parseLsRemote(out)
+	candidates := []string{ref + "^{}", ref}
+	if ref != "HEAD" && !strings.HasPrefix(ref, "refs/") {
+		candidates = []string{"refs/heads/" + ref, "refs/tags/" + ref + "^{}", "refs/tags/" + ref}
+	}
+	for _, candidate := range candidates {
+		if sha, ok := refs[candidate]; ok {
+			return sha, nil
+		}
+	}
+	return "", fmt.Errorf("ref '%s' not found in git repository %s", info.QualifiedRef(), gitRepoURL(info))
+}
+
+func (gitProvider) RawURL(info *ParsedSourceInfo, pathInRepo string) string {
+	return gitRawURL(gitRepoURL(info), info.RefSegment(), pathInRepo)
+}
+
+func (gitProvider) ListTags(info *ParsedSourceInfo) ([]string, error) {
+	out, err := runGit("ls-remote", "--tags", "--refs", "--end-of-options", gitRepoURL(info))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for ref := range parseLsRemote(out) {
+		names = append(names, strings.TrimPrefix(ref, "refs/tags/"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// FetchMetadata has nothing to ask the host for; it only names the repository's web address
+// when it is served over HTTP(S).
+func (gitProvider) FetchMetadata(info *ParsedSourceInfo) (*Metadata, error) {
+	repo := strings.TrimPrefix(gitRepoURL(info), "git+")
+	if !strings.HasPrefix(repo, "http") {
+		return &Metadata{}, nil
+	}
+	return &Metadata{HomepageURL: strings.TrimSuffix(repo, ".git")}, nil
+}
+
+// gitRepoURL returns the git+ URL of the parsed source's repository, without the file fragment.
+func gitRepoURL(info *ParsedSourceInfo) string {
+	repo, _, _ := strings.Cut(info.RawURL, "#")
+	return repo
+}
+
+// gitRawURL builds the git+ URL of the file at pathInRepo and ref in the repository repoURL.
+func gitRawURL(repoURL, ref, pathInRepo string) string {
+	escape := strings.NewReplacer("%", "%25", "&", "%26", "#", "%23", "+", "%2B", "=", "%3D").Replace
+	return fmt.Sprintf("%s#path=%s&ref=%s", repoURL, escape(pathInRepo), escape(ref))
+}
+
+// parseLsRemote maps ref names to commits in the output of git ls-remote.
+func parseLsRemote(out []byte) map[string]string {
+	refs := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if sha, ref, ok := strings.Cut(scanner.Text(), "\t"); ok {
+			refs[ref] = sha
+		}
+	}
+	return refs
+}
+
+// runGit runs git for a git+ URL argument, passing the URL without its "git+" prefix, and
+// returns the standard output. Git never prompts for credentials.
+func runGit(args ...string) ([]byte, error) {
+	for i, arg := range args {
+		args[i] = strings.TrimPrefix(arg, "git+")
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
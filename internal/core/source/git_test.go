@@ -0,0 +1,97 @@
+package source_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+// initGitRepo creates a repository with lib/util.lua on branch main, tagged v1.0.0 (annotated)
+// and v1.1.0, and returns its directory and the commit of each tag.
+func initGitRepo(t *testing.T) (dir, v100, v110 string) {
+	t.Helper()
+	dir = t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "--quiet", "--initial-branch=main")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "lib"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib", "util.lua"), []byte("return 'v1.0.0'"), 0644))
+	git("add", "-A")
+	git("commit", "--quiet", "-m", "v1.0.0")
+	git("tag", "-a", "v1.0.0", "-m", "v1.0.0")
+	v100 = git("rev-parse", "HEAD")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib", "util.lua"), []byte("return 'v1.1.0'"), 0644))
+	git("commit", "--quiet", "-am", "v1.1.0")
+	git("tag", "v1.1.0")
+	v110 = git("rev-parse", "HEAD")
+	return dir, v100, v110
+}
+
+func TestGitProvider(t *testing.T) {
+	sourceTestMutex.Lock()
+	defer sourceTestMutex.Unlock()
+	dir, v100, v110 := initGitRepo(t)
+	repoURL := "git+file://" + filepath.ToSlash(dir)
+
+	info, err := source.ParseSourceURL(repoURL + "#path=lib/util.lua&ref=tag:v1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, source.ProviderGit, info.Provider)
+	assert.Equal(t, "v1.0.0", info.Ref)
+	assert.Equal(t, source.RefTypeTag, info.RefType)
+	assert.Equal(t, "util.lua", info.SuggestedFilename)
+	assert.Equal(t, repoURL+"#path=lib/util.lua&ref=refs/tags/v1.0.0", info.RawURL)
+
+	sha, err := source.ResolveRef(info)
+	require.NoError(t, err)
+	assert.Equal(t, v100, sha, "annotated tags resolve to their commit")
+
+	pinned, err := info.AtCommit(sha)
+	require.NoError(t, err)
+	assert.Equal(t, repoURL+"#path=lib/util.lua&ref="+v100, pinned.RawURL)
+
+	for _, ref := range []string{"main", "branch:main", ""} {
+		info, err = source.ParseSourceURL(repoURL + "#path=lib/util.lua&ref=" + ref)
+		require.NoError(t, err)
+		sha, err = source.ResolveRef(info)
+		require.NoError(t, err, ref)
+		assert.Equal(t, v110, sha, ref)
+	}
+
+	info, err = source.ParseSourceURL(repoURL + "#path=lib/util.lua&ref=v1.*")
+	require.NoError(t, err)
+	resolved, err := source.ResolveTagPattern(info)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.1.0", resolved.Ref)
+
+	info, err = source.ParseSourceURL(repoURL + "#path=lib/util.lua&ref=nope")
+	require.NoError(t, err)
+	_, err = source.ResolveRef(info)
+	assert.ErrorContains(t, err, "ref 'nope' not found")
+}
+
+func TestParseSourceURL_GitErrors(t *testing.T) {
+	sourceTestMutex.Lock()
+	defer sourceTestMutex.Unlock()
+
+	_, err := source.ParseSourceURL("git+https://git.example.com/owner/repo.git")
+	assert.ErrorContains(t, err, "#path=<path>&ref=<ref>")
+	_, err = source.ParseSourceURL("git+ftp://git.example.com/owner/repo.git#path=a.lua")
+	assert.ErrorContains(t, err, "unsupported transport 'ftp'")
+	_, err = source.ParseSourceURL("git+https://git.example.com/owner/repo.git#path=a.lua&ref=wip:x")
+	assert.ErrorContains(t, err, "unknown ref qualifier")
+	_, err = source.ParseSourceURL("git+https://git.example.com/owner/repo.git#path=a.lua&ref=--upload-pack=touch%20/tmp/almd_pwned")
+	assert.ErrorContains(t, err, "must not start with '-'")
+	_, err = source.ParseSourceURL("git+https://git.example.com/owner/repo.git#path=-a.lua&ref=main")
+	assert.ErrorContains(t, err, "must not start with '-'")
+}
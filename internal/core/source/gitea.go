@@ -0,0 +1,240 @@
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Names of the built-in Gitea providers: "gitea" for a self-hosted instance set with base_url
+// under [providers.gitea], and "codeberg" for codeberg.org, which runs Gitea (Forgejo).
+const (
+	ProviderGitea    = "gitea"
+	ProviderCodeberg = "codeberg"
+)
+
+// CodebergBaseURL is the instance that codeberg: sources and codeberg.org URLs refer to. Tests
+// override it with a mock server.
+var CodebergBaseURL = "https://codeberg.org"
+var CodebergBaseURLMutex sync.Mutex // Mutex for CodebergBaseURL (Exported)
+
+// giteaProvider is the built-in Provider for a Gitea instance and the
+// "<name>:owner/repo/path@ref" shorthand, where name is the provider's name.
+type giteaProvider struct {
+	name string
+}
+
+func (p giteaProvider) Name() string { return p.name }
+
+func (p giteaProvider) Parse(sourceURL string) (*ParsedSourceInfo, bool, error) {
+	if strings.HasPrefix(sourceURL, p.name+":") {
+		info, err := p.parseShorthand(sourceURL)
+		return info, true, err
+	}
+	u, err := url.Parse(sourceURL)
+	if err != nil || u.Host == "" || p.baseURL() == "" {
+		return nil, false, nil
+	}
+	base, err := url.Parse(p.baseURL())
+	if err != nil || !strings.EqualFold(u.Host, base.Host) {
+		return nil, false, nil
+	}
+	info, err := p.parseWebURL(u)
+	return info, true, err
+}
+
+// giteaCommit is the subset of a Gitea commit used by the provider.
+type giteaCommit struct {
+	SHA    string `json:"sha"`
+	Commit struct {
+		Committer struct {
+			Date time.Time `json:"date"`
+		} `json:"committer"`
+	} `json:"commit"`
+}
+
+func (p giteaProvider) ResolveRef(info *ParsedSourceInfo) (string, error) {
+	apiURL := fmt.Sprintf("%s/commits?sha=%s&path=%s&limit=1&stat=false",
+		p.repoAPIURL(info), url.QueryEscape(info.Ref), url.QueryEscape(info.PathInRepo))
+	body, err := hostAPIGet("Gitea", apiURL)
+	if err != nil {
+		return "", err
+	}
+	var commits []giteaCommit
+	if err := json.Unmarshal(body, &commits); err != nil {
+		return "", fmt.Errorf("failed to unmarshal Gitea API response (%s): %w. Body: %s", apiURL, err, string(body))
+	}
+	if len(commits) == 0 {
+		return "", fmt.Errorf("no commits found for path '%s' at ref '%s' in %s repository '%s/%s'. The file might not exist at this path/ref", info.PathInRepo, info.Ref, p.name, info.Owner, info.Repo)
+	}
+	return commits[0].SHA, nil
+}
+
+func (p giteaProvider) CommitDate(info *ParsedSourceInfo, sha string) (time.Time, error) {
+	apiURL := fmt.Sprintf("%s/git/commits/%s?stat=false", p.repoAPIURL(info), url.PathEscape(sha))
+	body, err := hostAPIGet("Gitea", apiURL)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var commit giteaCommit
+	if err := json.Unmarshal(body, &commit); err != nil {
+		return time.Time{}, fmt.Errorf("failed to unmarshal Gitea API response (%s): %w. Body: %s", apiURL, err, string(body))
+	}
+	if commit.Commit.Committer.Date.IsZero() {
+		return time.Time{}, fmt.Errorf("Gitea API response for commit %s in %s/%s has no commit date", sha, info.Owner, info.Repo)
+	}
+	return commit.Commit.Committer.Date, nil
+}
+
+func (p giteaProvider) RawURL(info *ParsedSourceInfo, pathInRepo string) string {
+	return p.rawURL(info.Owner, info.Repo, info.RefType, info.Ref, pathInRepo)
+}
+
+func (p giteaProvider) ListTags(info *ParsedSourceInfo) ([]string, error) {
+	const perPage = 50
+	var names []string
+	for page := 1; page <= maxTagPages; page++ {
+		apiURL := fmt.Sprintf("%s/tags?limit=%d&page=%d", p.repoAPIURL(info), perPage, page)
+		body, err := hostAPIGet("Gitea", apiURL)
+		if err != nil {
+			return nil, err
+		}
+		var pageTags []struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(body, &pageTags); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal Gitea API response (%s): %w. Body: %s", apiURL, err, string(body))
+		}
+		for _, t := range pageTags {
+			names = append(names, t.Name)
+		}
+		if len(pageTags) < perPage {
+			break
+		}
+	}
+	return names, nil
+}
+
+func (p giteaProvider) FetchMetadata(info *ParsedSourceInfo) (*Metadata, error) {
+	apiURL := p.repoAPIURL(info)
+	body, err := hostAPIGet("Gitea", apiURL)
+	if err != nil {
+		return nil, err
+	}
+	var repo struct {
+		Description   string `json:"description"`
+		Website       string `json:"website"`
+		HTMLURL       string `json:"html_url"`
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := json.Unmarshal(body, &repo); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Gitea API response (%s): %w. Body: %s", apiURL, err, string(body))
+	}
+	meta := &Metadata{Description: repo.Description, HomepageURL: repo.Website, DefaultBranch: repo.DefaultBranch}
+	if meta.HomepageURL == "" {
+		meta.HomepageURL = repo.HTMLURL
+	}
+	return meta, nil
+}
+
+// baseURL returns the instance URL: base_url under the project's [providers.<name>] if set,
+// otherwise CodebergBaseURL for codeberg and "" (unconfigured) for gitea.
+func (p giteaProvider) baseURL() string {
+	if base := providerBaseURL(p.name); base != "" {
+		return base
+	}
+	if p.name != ProviderCodeberg {
+		return ""
+	}
+	CodebergBaseURLMutex.Lock()
+	defer CodebergBaseURLMutex.Unlock()
+	return strings.TrimSuffix(CodebergBaseURL, "/")
+}
+
+// repoAPIURL returns the API URL of the parsed source's repository: under the project's
+// [providers.<name>] api_url if set, otherwise under the v1 API of the instance.
+func (p giteaProvider) repoAPIURL(info *ParsedSourceInfo) string {
+	apiURL := providerAPIURL(p.name)
+	if apiURL == "" {
+		apiURL = p.baseURL() + "/api/v1"
+	}
+	return fmt.Sprintf("%s/repos/%s/%s", apiURL, info.Owner, info.Repo)
+}
+
+// rawURL builds the raw content URL of a file at ref. Gitea names the kind of ref in the URL;
+// an unqualified ref uses the older form, which Gitea resolves as a branch, tag or commit.
+func (p giteaProvider) rawURL(owner, repo, refType, ref, pathInRepo string) string {
+	kind := ""
+	if refType != "" {
+		kind = refType + "/"
+	}
+	return fmt.Sprintf("%s/%s/%s/raw/%s%s/%s", p.baseURL(), owner, repo, kind, url.PathEscape(ref), escapePath(pathInRepo))
+}
+
+// newSourceInfo fills in a ParsedSourceInfo for a file of the repository owner/repo.
+func (p giteaProvider) newSourceInfo(owner, repo, pathInRepo, refType, ref string) (*ParsedSourceInfo, error) {
+	if owner == "" || repo == "" || pathInRepo == "" || ref == "" || strings.HasSuffix(pathInRepo, "/") {
+		return nil, fmt.Errorf("one or more components (owner, repo, ref, path) are empty")
+	}
+	return &ParsedSourceInfo{
+		RawURL:            p.rawURL(owner, repo, refType, ref, pathInRepo),
+		CanonicalURL:      fmt.Sprintf("%s:%s/%s/%s@%s", p.name, owner, repo, strings.ReplaceAll(pathInRepo, "%", "%25"), qualifyRef(refType, ref)),
+		Ref:               ref,
+		RefType:           refType,
+		Provider:          p.name,
+		Owner:             owner,
+		Repo:              repo,
+		PathInRepo:        pathInRepo,
+		SuggestedFilename: pathInRepo[strings.LastIndex(pathInRepo, "/")+1:],
+	}, nil
+}
+
+// parseShorthand handles sources like "codeberg:owner/repo/path/to/file@ref".
+func (p giteaProvider) parseShorthand(sourceURL string) (*ParsedSourceInfo, error) {
+	if p.baseURL() == "" {
+		return nil, fmt.Errorf("%s source '%s' needs the instance URL: set base_url under [providers.%s] in project.toml", p.name, sourceURL, p.name)
+	}
+	repoAndPath, refType, ref, err := splitShorthand(sourceURL, p.name)
+	if err != nil {
+		return nil, err
+	}
+	var owner, repo, pathInRepo string
+	if parts := strings.SplitN(repoAndPath, "/", 3); len(parts) == 3 {
+		owner, repo, pathInRepo = parts[0], parts[1], parts[2]
+	}
+	info, err := p.newSourceInfo(owner, repo, pathInRepo, refType, ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s shorthand source '%s': expected owner/repo/path/to/file@ref: %w", p.name, sourceURL, err)
+	}
+	return info, nil
+}
+
+// parseWebURL handles Gitea file URLs: /<owner>/<repo>/src/<kind>/<ref>/<path> as shown in the
+// browser and /<owner>/<repo>/raw/<kind>/<ref>/<path>, where kind is branch, tag or commit. Raw
+// URLs may also leave out the kind.
+func (p giteaProvider) parseWebURL(u *url.URL) (*ParsedSourceInfo, error) {
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 5 || (parts[2] != "src" && parts[2] != "raw") {
+		return nil, fmt.Errorf("unsupported %s URL '%s'. Expected /<owner>/<repo>/src/branch/<ref>/<path> or /raw/..., or %s:owner/repo/path@ref", p.name, u.String(), p.name)
+	}
+	refType, rest := "", parts[3:]
+	switch parts[3] {
+	case RefTypeBranch, RefTypeTag, RefTypeCommit:
+		refType, rest = parts[3], parts[4:]
+	default:
+		if parts[2] == "src" {
+			return nil, fmt.Errorf("unsupported %s URL '%s'. Expected /src/branch/, /src/tag/ or /src/commit/ before the ref", p.name, u.String())
+		}
+	}
+	if len(rest) < 2 {
+		return nil, fmt.Errorf("incomplete %s URL '%s'. Expected a ref followed by the file path", p.name, u.String())
+	}
+	info, err := p.newSourceInfo(parts[0], parts[1], strings.Join(rest[1:], "/"), refType, rest[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s URL '%s': %w", p.name, u.String(), err)
+	}
+	return info, nil
+}
@@ -0,0 +1,129 @@
+package source_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+func TestParseSourceURL_Gitea(t *testing.T) {
+	sourceTestMutex.Lock()
+	defer sourceTestMutex.Unlock()
+
+	tests := []struct {
+		name string
+		url  string
+		want *source.ParsedSourceInfo
+	}{
+		{
+			name: "codeberg shorthand",
+			url:  "codeberg:owner/repo/src/lib.lua@tag:v1.0.0",
+			want: &source.ParsedSourceInfo{
+				RawURL:            "https://codeberg.org/owner/repo/raw/tag/v1.0.0/src/lib.lua",
+				CanonicalURL:      "codeberg:owner/repo/src/lib.lua@tag:v1.0.0",
+				Ref:               "v1.0.0",
+				RefType:           source.RefTypeTag,
+				Provider:          source.ProviderCodeberg,
+				Owner:             "owner",
+				Repo:              "repo",
+				PathInRepo:        "src/lib.lua",
+				SuggestedFilename: "lib.lua",
+			},
+		},
+		{
+			name: "codeberg web URL",
+			url:  "https://codeberg.org/owner/repo/src/branch/main/lib.lua",
+			want: &source.ParsedSourceInfo{
+				RawURL:            "https://codeberg.org/owner/repo/raw/branch/main/lib.lua",
+				CanonicalURL:      "codeberg:owner/repo/lib.lua@branch:main",
+				Ref:               "main",
+				RefType:           source.RefTypeBranch,
+				Provider:          source.ProviderCodeberg,
+				Owner:             "owner",
+				Repo:              "repo",
+				PathInRepo:        "lib.lua",
+				SuggestedFilename: "lib.lua",
+			},
+		},
+		{
+			name: "raw URL without the kind of ref",
+			url:  "https://codeberg.org/owner/repo/raw/main/lib.lua",
+			want: &source.ParsedSourceInfo{
+				RawURL:            "https://codeberg.org/owner/repo/raw/main/lib.lua",
+				CanonicalURL:      "codeberg:owner/repo/lib.lua@main",
+				Ref:               "main",
+				Provider:          source.ProviderCodeberg,
+				Owner:             "owner",
+				Repo:              "repo",
+				PathInRepo:        "lib.lua",
+				SuggestedFilename: "lib.lua",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := source.ParseSourceURL(tt.url)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	for _, bad := range []string{
+		"codeberg:owner/repo/lib.lua",
+		"codeberg:owner/lib.lua@main",
+		"https://codeberg.org/owner/repo/src/main/lib.lua",
+		"https://codeberg.org/owner/repo/src/branch/main",
+		"https://codeberg.org/owner/repo",
+	} {
+		_, err := source.ParseSourceURL(bad)
+		assert.Error(t, err, bad)
+	}
+
+	_, err := source.ParseSourceURL("gitea:owner/repo/lib.lua@main")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "set base_url under [providers.gitea]")
+}
+
+func TestGiteaProvider_SelfHosted(t *testing.T) {
+	sourceTestMutex.Lock()
+	defer sourceTestMutex.Unlock()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/repos/owner/repo/commits":
+			assert.Equal(t, "main", r.URL.Query().Get("sha"))
+			assert.Equal(t, "src/lib.lua", r.URL.Query().Get("path"))
+			_, _ = w.Write([]byte(`[{"sha": "0123456789abcdef0123456789abcdef01234567"}]`))
+		case "/api/v1/repos/owner/repo/tags":
+			_, _ = w.Write([]byte(`[{"name": "v1.0.0"}, {"name": "v1.1.0"}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	source.SetProviderSettings(map[string]source.ProviderSettings{source.ProviderGitea: {BaseURL: server.URL + "/"}})
+	defer source.SetProviderSettings(nil)
+
+	info, err := source.ParseSourceURL(server.URL + "/owner/repo/src/branch/main/src/lib.lua")
+	require.NoError(t, err)
+	assert.Equal(t, source.ProviderGitea, info.Provider)
+	sha, err := source.ResolveRef(info)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef01234567", sha)
+
+	pinned, err := info.AtCommit(sha)
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/owner/repo/raw/commit/0123456789abcdef0123456789abcdef01234567/src/lib.lua", pinned.RawURL)
+
+	info, err = source.ParseSourceURL("gitea:owner/repo/src/lib.lua@v1.*")
+	require.NoError(t, err)
+	resolved, err := source.ResolveTagPattern(info)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.1.0", resolved.Ref)
+	assert.Equal(t, server.URL+"/owner/repo/raw/tag/v1.1.0/src/lib.lua", resolved.RawURL)
+}
@@ -0,0 +1,115 @@
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// githubProvider is the built-in Provider for github.com, raw.githubusercontent.com and the
+// "github:owner/repo/path@ref" shorthand.
+type githubProvider struct{}
+
+func (githubProvider) Name() string { return ProviderGitHub }
+
+func (githubProvider) Parse(sourceURL string) (*ParsedSourceInfo, bool, error) {
+	if strings.HasPrefix(sourceURL, "github:") {
+		info, err := parseGitHubShorthandURL(sourceURL)
+		return info, true, err
+	}
+
+	u, err := url.Parse(sourceURL)
+	if err != nil {
+		return nil, false, nil
+	}
+
+	TestModeBypassHostValidationMutex.Lock()
+	currentTestModeBypass := testModeBypassHostValidation
+	TestModeBypassHostValidationMutex.Unlock()
+
+	if currentTestModeBypass {
+		// If test mode bypass is active, attempt to parse it as a test mode URL.
+		// This function will error if the path doesn't match the expected test structure.
+		info, err := parseTestModeURL(u)
+		return info, true, err
+	}
+
+	switch strings.ToLower(u.Hostname()) {
+	case "raw.githubusercontent.com":
+		info, err := parseRawGitHubUserContentURL(u)
+		return info, true, err
+	case "github.com":
+		info, err := parseGitHubFullURL(u)
+		return info, true, err
+	default:
+		return nil, false, nil
+	}
+}
+
+func (githubProvider) ResolveRef(info *ParsedSourceInfo) (string, error) {
+	return GetLatestCommitSHAForFile(info.Owner, info.Repo, info.PathInRepo, info.RefSegment())
+}
+
+func (githubProvider) CommitDate(info *ParsedSourceInfo, sha string) (time.Time, error) {
+	return GetCommitDate(info.Owner, info.Repo, sha)
+}
+
+func (githubProvider) RawURL(info *ParsedSourceInfo, pathInRepo string) string {
+	return githubRawURL(info.Owner, info.Repo, info.RefSegment(), pathInRepo)
+}
+
+func (githubProvider) ListTags(info *ParsedSourceInfo) ([]string, error) {
+	return defaultGitHubAPI().tagNames(info)
+}
+
+// tagNames returns the names of the tags of the parsed repository.
+func (api githubAPI) tagNames(info *ParsedSourceInfo) ([]string, error) {
+	tagInfos, err := api.listTags(info.Owner, info.Repo)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(tagInfos))
+	for _, t := range tagInfos {
+		names = append(names, t.Name)
+	}
+	return names, nil
+}
+
+// githubRepoInfo is the subset of the GitHub repository API response used for Metadata.
+type githubRepoInfo struct {
+	Description   string `json:"description"`
+	HTMLURL       string `json:"html_url"`
+	Homepage      string `json:"homepage"`
+	DefaultBranch string `json:"default_branch"`
+	License       *struct {
+		SPDXID string `json:"spdx_id"`
+	} `json:"license"`
+}
+
+func (githubProvider) FetchMetadata(info *ParsedSourceInfo) (*Metadata, error) {
+	return defaultGitHubAPI().metadata(info)
+}
+
+// metadata describes the parsed repository from its repository API response.
+func (api githubAPI) metadata(info *ParsedSourceInfo) (*Metadata, error) {
+	apiURL := fmt.Sprintf("%s/repos/%s/%s", api.base, info.Owner, info.Repo)
+	body, err := api.get(apiURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var repo githubRepoInfo
+	if err := json.Unmarshal(body, &repo); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal GitHub API response (%s): %w. Body: %s", apiURL, err, string(body))
+	}
+	meta := &Metadata{Description: repo.Description, HomepageURL: repo.Homepage, DefaultBranch: repo.DefaultBranch}
+	if meta.HomepageURL == "" {
+		meta.HomepageURL = repo.HTMLURL
+	}
+	if repo.License != nil && repo.License.SPDXID != "NOASSERTION" {
+		meta.License = repo.License.SPDXID
+	}
+	return meta, nil
+}
@@ -1,12 +1,20 @@
 package source
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"slices"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/nightconcept/almandine/internal/core/globalconfig"
+	"github.com/nightconcept/almandine/internal/core/httpclient"
+	"github.com/nightconcept/almandine/internal/core/logger"
 )
 
 // GithubAPIBaseURL allows overriding for tests. It is an exported variable.
@@ -21,7 +29,28 @@ type GitHubCommitInfo struct {
 			Date time.Time `json:"date"`
 		} `json:"committer"`
 	} `json:"commit"`
-	// We only need the SHA, but including date for potential future use/sorting.
+}
+
+// queryEscapePath escapes p for a query parameter, keeping its '/' separators readable. Without
+// it a '+' in a file name would reach GitHub as a space.
+func queryEscapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = url.QueryEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// githubAPI is a GitHub REST API endpoint: github.com's (see defaultGitHubAPI) or that of a
+// GitHub Enterprise Server instance, which serves the same API under its own base URL.
+type githubAPI struct {
+	base string                              // Base URL, e.g. "https://api.github.com"
+	get  func(apiURL string) ([]byte, error) // Performs an authenticated GET, like githubAPIGet
+}
+
+// defaultGitHubAPI returns the API of github.com, or of the project's [providers.github] api_url.
+func defaultGitHubAPI() githubAPI {
+	return githubAPI{base: githubAPIBaseURL(), get: githubAPIGet}
 }
 
 // GetLatestCommitSHAForFile fetches the latest commit SHA for a specific file on a given branch/ref from GitHub.
@@ -30,36 +59,17 @@ type GitHubCommitInfo struct {
 // pathInRepo: path to the file within the repository
 // ref: branch name, tag name, or commit SHA
 func GetLatestCommitSHAForFile(owner, repo, pathInRepo, ref string) (string, error) {
+	return defaultGitHubAPI().latestCommitSHAForFile(owner, repo, pathInRepo, ref)
+}
+
+func (api githubAPI) latestCommitSHAForFile(owner, repo, pathInRepo, ref string) (string, error) {
 	// See: https://docs.github.com/en/rest/commits/commits#list-commits
 	// We ask for commits for a specific file on a specific branch/ref. The first result is the latest.
-	GithubAPIBaseURLMutex.Lock()
-	currentGithubAPIBaseURL := GithubAPIBaseURL
-	GithubAPIBaseURLMutex.Unlock()
-	apiURL := fmt.Sprintf("%s/repos/%s/%s/commits?path=%s&sha=%s&per_page=1", currentGithubAPIBaseURL, owner, repo, pathInRepo, ref)
-
-	httpClient := &http.Client{Timeout: 10 * time.Second}
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create request to GitHub API: %w", err)
-	}
-	// GitHub API recommends setting an Accept header.
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	// TODO: Consider adding a User-Agent header (e.g., "almandine-cli") for more robust GitHub API requests.
-
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to call GitHub API (%s): %w", apiURL, err)
-	}
-	defer func() { _ = resp.Body.Close() }()
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/commits?path=%s&sha=%s&per_page=1", api.base, owner, repo, queryEscapePath(pathInRepo), queryEscapePath(ref))
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("GitHub API request failed with status %s (%s): %s", resp.Status, apiURL, string(bodyBytes))
-	}
-
-	body, err := io.ReadAll(resp.Body)
+	body, err := api.get(apiURL)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body from GitHub API (%s): %w", apiURL, err)
+		return "", err
 	}
 
 	var commits []GitHubCommitInfo
@@ -77,3 +87,333 @@ func GetLatestCommitSHAForFile(owner, repo, pathInRepo, ref string) (string, err
 
 	return commits[0].SHA, nil
 }
+
+// GetCommitDate returns the committer date of a commit, which may be given as an abbreviated SHA.
+func GetCommitDate(owner, repo, sha string) (time.Time, error) {
+	return defaultGitHubAPI().commitDate(owner, repo, sha)
+}
+
+func (api githubAPI) commitDate(owner, repo, sha string) (time.Time, error) {
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/commits/%s", api.base, owner, repo, sha)
+	body, err := api.get(apiURL)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var commit GitHubCommitInfo
+	if err := json.Unmarshal(body, &commit); err != nil {
+		return time.Time{}, fmt.Errorf("failed to unmarshal GitHub API response (%s): %w. Body: %s", apiURL, err, string(body))
+	}
+	if commit.Commit.Committer.Date.IsZero() {
+		return time.Time{}, fmt.Errorf("GitHub API response for commit %s in %s/%s has no committer date", sha, owner, repo)
+	}
+	return commit.Commit.Committer.Date, nil
+}
+
+// GitHubTagInfo is the subset of the GitHub tags API response used to resolve tag patterns.
+type GitHubTagInfo struct {
+	Name   string `json:"name"`
+	Commit struct {
+		SHA string `json:"sha"`
+	} `json:"commit"`
+}
+
+// maxTagPages bounds tag pagination so a repository with an enormous number of tags
+// cannot stall resolution indefinitely.
+const maxTagPages = 10
+
+// ListTags fetches the tags of a GitHub repository, following pagination up to maxTagPages pages.
+func ListTags(owner, repo string) ([]GitHubTagInfo, error) {
+	return defaultGitHubAPI().listTags(owner, repo)
+}
+
+func (api githubAPI) listTags(owner, repo string) ([]GitHubTagInfo, error) {
+	const perPage = 100
+	var tags []GitHubTagInfo
+	for page := 1; page <= maxTagPages; page++ {
+		apiURL := fmt.Sprintf("%s/repos/%s/%s/tags?per_page=%d&page=%d", api.base, owner, repo, perPage, page)
+		body, err := api.get(apiURL)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageTags []GitHubTagInfo
+		if err := json.Unmarshal(body, &pageTags); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal GitHub API response (%s): %w. Body: %s", apiURL, err, string(body))
+		}
+		tags = append(tags, pageTags...)
+		if len(pageTags) < perPage {
+			break
+		}
+	}
+	return tags, nil
+}
+
+// GitHubReleaseAsset is the subset of a release asset used to build installer manifests and
+// to self-update.
+type GitHubReleaseAsset struct {
+	ID                 int64  `json:"id"`
+	Name               string `json:"name"`
+	Size               int    `json:"size"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+	Digest             string `json:"digest"` // "sha256:<hex>"; empty for assets uploaded before GitHub recorded digests
+}
+
+// GitHubRelease is the subset of the GitHub releases API response used by almd.
+type GitHubRelease struct {
+	ID          int64                `json:"id"`
+	TagName     string               `json:"tag_name"`
+	Name        string               `json:"name"`
+	Body        string               `json:"body"` // Release notes in Markdown
+	HTMLURL     string               `json:"html_url"`
+	Draft       bool                 `json:"draft"`
+	Prerelease  bool                 `json:"prerelease"`
+	PublishedAt time.Time            `json:"published_at"`
+	Assets      []GitHubReleaseAsset `json:"assets"`
+}
+
+// maxReleasePages bounds release pagination; callers only look at recent releases.
+const maxReleasePages = 3
+
+// ListReleases fetches the releases of a repository, newest first, following pagination up to
+// maxReleasePages pages.
+func ListReleases(owner, repo string) ([]GitHubRelease, error) {
+	const perPage = 100
+	var releases []GitHubRelease
+	for page := 1; page <= maxReleasePages; page++ {
+		apiURL := fmt.Sprintf("%s/repos/%s/%s/releases?per_page=%d&page=%d", githubAPIBaseURL(), owner, repo, perPage, page)
+		body, err := githubAPIGet(apiURL)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageReleases []GitHubRelease
+		if err := json.Unmarshal(body, &pageReleases); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal GitHub API response (%s): %w. Body: %s", apiURL, err, string(body))
+		}
+		releases = append(releases, pageReleases...)
+		if len(pageReleases) < perPage {
+			break
+		}
+	}
+	return releases, nil
+}
+
+// GetRelease fetches a release by tag, or the latest non-prerelease release when tag is empty.
+func GetRelease(owner, repo, tag string) (*GitHubRelease, error) {
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/releases/latest", githubAPIBaseURL(), owner, repo)
+	if tag != "" {
+		apiURL = fmt.Sprintf("%s/repos/%s/%s/releases/tags/%s", githubAPIBaseURL(), owner, repo, tag)
+	}
+	body, err := githubAPIGet(apiURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var release GitHubRelease
+	if err := json.Unmarshal(body, &release); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal GitHub API response (%s): %w. Body: %s", apiURL, err, string(body))
+	}
+	return &release, nil
+}
+
+// NewPullRequest describes a pull request to open with CreatePullRequest.
+type NewPullRequest struct {
+	Title string `json:"title"`
+	Head  string `json:"head"` // Branch with the changes
+	Base  string `json:"base"` // Branch to merge into
+	Body  string `json:"body"` // Description in Markdown
+}
+
+// CreatePullRequest opens a pull request in owner/repo with the configured GitHub token and
+// returns its web URL.
+func CreatePullRequest(owner, repo string, pr NewPullRequest) (string, error) {
+	token := globalconfig.GitHubToken()
+	if token == "" {
+		return "", fmt.Errorf("opening a pull request needs a GitHub token; set $%s or run 'almd token set'", globalconfig.GitHubTokenEnv)
+	}
+	payload, err := json.Marshal(pr)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode pull request: %w", err)
+	}
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/pulls", githubAPIBaseURL(), owner, repo)
+	req, err := http.NewRequest(http.MethodPost, apiURL, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request to GitHub API: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := httpclient.Client(httpclient.APITimeout).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to call GitHub API (%s): %w", apiURL, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body from GitHub API (%s): %w", apiURL, err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("GitHub API request failed with status %s (%s): %s", resp.Status, apiURL, string(body))
+	}
+	var created struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.Unmarshal(body, &created); err != nil {
+		return "", fmt.Errorf("failed to unmarshal GitHub API response (%s): %w. Body: %s", apiURL, err, string(body))
+	}
+	return created.HTMLURL, nil
+}
+
+// CheckConnectivity verifies that the GitHub API is reachable and, when a token is configured,
+// that GitHub accepts it. It queries the rate limit endpoint, which does not count against the limit.
+func CheckConnectivity() error {
+	_, err := githubAPIGet(githubAPIBaseURL() + "/rate_limit")
+	return err
+}
+
+// githubAPIBaseURL returns the current GitHub API base URL: the project's [providers.github]
+// api_url if set, otherwise GithubAPIBaseURL, which tests override.
+func githubAPIBaseURL() string {
+	if apiURL := providerAPIURL(ProviderGitHub); apiURL != "" {
+		return apiURL
+	}
+	GithubAPIBaseURLMutex.Lock()
+	defer GithubAPIBaseURLMutex.Unlock()
+	return GithubAPIBaseURL
+}
+
+// TokenStatus is what GitHub reports about the configured token.
+type TokenStatus struct {
+	Authenticated bool      // A token was sent and accepted
+	Scopes        []string  // OAuth scopes of a classic token; empty for fine-grained tokens
+	Limit         int       // Core API requests allowed per hour
+	Remaining     int       // Core API requests left in the current window
+	Reset         time.Time // When the window resets
+}
+
+// CheckToken queries the rate limit endpoint with the configured token and reports its scopes
+// and limits. A rejected token is an error.
+func CheckToken() (*TokenStatus, error) {
+	return CheckTokenValue(globalconfig.GitHubToken())
+}
+
+// CheckTokenValue is CheckToken for a given token, such as one from the token pool. An empty
+// token checks unauthenticated access.
+func CheckTokenValue(token string) (*TokenStatus, error) {
+	var pool []string
+	if token != "" {
+		pool = []string{token}
+	}
+	body, header, err := githubAPIRequestWith(githubAPIBaseURL()+"/rate_limit", pool)
+	if err != nil {
+		return nil, err
+	}
+	var limits struct {
+		Resources struct {
+			Core struct {
+				Limit     int   `json:"limit"`
+				Remaining int   `json:"remaining"`
+				Reset     int64 `json:"reset"`
+			} `json:"core"`
+		} `json:"resources"`
+	}
+	if err := json.Unmarshal(body, &limits); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal GitHub rate limit response: %w", err)
+	}
+	status := &TokenStatus{
+		Authenticated: token != "",
+		Limit:         limits.Resources.Core.Limit,
+		Remaining:     limits.Resources.Core.Remaining,
+		Reset:         time.Unix(limits.Resources.Core.Reset, 0),
+	}
+	for _, scope := range strings.Split(header.Get("X-OAuth-Scopes"), ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			status.Scopes = append(status.Scopes, scope)
+		}
+	}
+	return status, nil
+}
+
+// githubAPIGet performs a GET request against the GitHub API and returns the response body.
+// Non-200 responses are returned as errors that include the response body for context.
+// Responses are memoized while StartAPIMemo is in effect.
+func githubAPIGet(apiURL string) ([]byte, error) {
+	return memoizedAPIGet(apiURL, func() ([]byte, error) {
+		body, _, err := githubAPIRequest(apiURL)
+		return body, err
+	})
+}
+
+// githubAPIRequest is githubAPIGet that also returns the response headers. Calls go through
+// the shared rate limiter, and a call GitHub asks to slow down is retried once after the pause.
+// With several tokens configured, calls rotate through them and move on to the next token when
+// one's quota is used up.
+func githubAPIRequest(apiURL string) ([]byte, http.Header, error) {
+	return githubAPIRequestWith(apiURL, githubTokenPool())
+}
+
+// tokenPool holds the GitHub tokens API calls rotate through. Resolving them reads the
+// configuration and asks the credential helper for each pool token, so it is done once per run;
+// an empty pool is remembered too. ResetRateLimit clears it.
+var tokenPool struct {
+	once   sync.Once
+	tokens []string
+}
+
+func githubTokenPool() []string {
+	tokenPool.once.Do(func() {
+		tokenPool.tokens = globalconfig.GitHubTokens()
+	})
+	return tokenPool.tokens
+}
+
+// githubAPIRequestWith is githubAPIRequest with the tokens to rotate through given explicitly.
+func githubAPIRequestWith(apiURL string, pool []string) ([]byte, http.Header, error) {
+	httpClient := httpclient.Client(httpclient.APITimeout)
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request to GitHub API: %w", err)
+	}
+	// GitHub API recommends setting an Accept header.
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	// TODO: Consider adding a User-Agent header (e.g., "almandine-cli") for more robust GitHub API requests.
+
+	for attempt := 0; ; attempt++ {
+		token, err := githubLimiter.pick(pool)
+		if err != nil {
+			return nil, nil, err
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if err := githubLimiter.wait(token); err != nil {
+			return nil, nil, err
+		}
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to call GitHub API (%s): %w", apiURL, err)
+		}
+		body, readErr := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+
+		if resp.StatusCode == http.StatusOK {
+			if readErr != nil {
+				return nil, nil, fmt.Errorf("failed to read response body from GitHub API (%s): %w", apiURL, readErr)
+			}
+			return body, resp.Header, nil
+		}
+		retry, limitErr := githubLimiter.observe(resp, token)
+		if limitErr != nil && len(pool) > 1 {
+			logger.Debugf("GitHub token %d of %d is rate limited; trying the next one", slices.Index(pool, token)+1, len(pool))
+			attempt-- // Another token starts over; pick fails once all are exhausted
+			continue
+		}
+		if limitErr != nil {
+			return nil, nil, fmt.Errorf("GitHub API request failed (%s): %w", apiURL, limitErr)
+		}
+		if !retry || attempt > 0 {
+			return nil, nil, fmt.Errorf("GitHub API request failed with status %s (%s): %s", resp.Status, apiURL, string(body))
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/nightconcept/almandine/internal/core/globalconfig"
 	"github.com/nightconcept/almandine/internal/core/source"
 )
 
@@ -179,3 +180,192 @@ func TestGetLatestCommitSHAForFile_UsesCorrectURLParameters(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, expectedSHA, sha)
 }
+
+func TestGetLatestCommitSHAForFile_EscapesPath(t *testing.T) {
+	githubAPITestMutex.Lock()
+	defer githubAPITestMutex.Unlock()
+
+	pathInRepo := "lib/c++ utils/ünï&co.lua"
+	responseBody, _ := json.Marshal([]source.GitHubCommitInfo{MockGitHubCommit("commitsha123", time.Now())})
+	_, cleanup := setupSourceTest(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, pathInRepo, r.URL.Query().Get("path"), "a '+' must not arrive as a space")
+		assert.Equal(t, "refs/tags/v1.0+build", r.URL.Query().Get("sha"))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(responseBody)
+	})
+	defer cleanup()
+
+	_, err := source.GetLatestCommitSHAForFile("owner", "repo", pathInRepo, "refs/tags/v1.0+build")
+	require.NoError(t, err)
+}
+
+func TestListTags_Paginates(t *testing.T) {
+	githubAPITestMutex.Lock()
+	defer githubAPITestMutex.Unlock()
+
+	firstPage := make([]source.GitHubTagInfo, 100)
+	for i := range firstPage {
+		firstPage[i] = source.GitHubTagInfo{Name: fmt.Sprintf("v0.0.%d", i)}
+	}
+	secondPage := []source.GitHubTagInfo{{Name: "v1.0.0"}}
+
+	_, cleanup := setupSourceTest(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/owner/repo/tags", r.URL.Path)
+		assert.Equal(t, "100", r.URL.Query().Get("per_page"))
+		var body []source.GitHubTagInfo
+		switch r.URL.Query().Get("page") {
+		case "1":
+			body = firstPage
+		case "2":
+			body = secondPage
+		default:
+			body = []source.GitHubTagInfo{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(body)
+	})
+	defer cleanup()
+
+	tags, err := source.ListTags("owner", "repo")
+	require.NoError(t, err)
+	assert.Len(t, tags, 101)
+	assert.Equal(t, "v1.0.0", tags[100].Name)
+}
+
+func TestListTags_APIError(t *testing.T) {
+	githubAPITestMutex.Lock()
+	defer githubAPITestMutex.Unlock()
+
+	_, cleanup := setupSourceTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"message":"Not Found"}`))
+	})
+	defer cleanup()
+
+	_, err := source.ListTags("owner", "missing")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "404")
+}
+
+func TestCheckConnectivity_SendsToken(t *testing.T) {
+	githubAPITestMutex.Lock()
+	defer githubAPITestMutex.Unlock()
+	source.ResetRateLimit()
+	defer source.ResetRateLimit()
+	t.Setenv(globalconfig.GitHubTokenEnv, "secret-token")
+
+	_, cleanup := setupSourceTest(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rate_limit", r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer secret-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"resources":{}}`))
+	})
+	defer cleanup()
+
+	require.NoError(t, source.CheckConnectivity())
+
+	t.Setenv(globalconfig.GitHubTokenEnv, "wrong")
+	source.ResetRateLimit() // The token pool is resolved once per run
+	err := source.CheckConnectivity()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+}
+
+func TestProviderSettings_APIURL(t *testing.T) {
+	githubAPITestMutex.Lock()
+	defer githubAPITestMutex.Unlock()
+	_, cleanup := setupSourceTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	defer cleanup()
+
+	project := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v3/rate_limit", r.URL.Path)
+		_, _ = w.Write([]byte(`{"resources":{}}`))
+	}))
+	defer project.Close()
+	source.SetProviderSettings(map[string]source.ProviderSettings{source.ProviderGitHub: {APIURL: project.URL + "/api/v3/"}})
+	defer source.SetProviderSettings(nil)
+
+	require.NoError(t, source.CheckConnectivity(), "API calls go to the project's api_url")
+}
+
+func TestGetRelease(t *testing.T) {
+	githubAPITestMutex.Lock()
+	defer githubAPITestMutex.Unlock()
+
+	_, cleanup := setupSourceTest(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/repo/releases/latest":
+			_, _ = w.Write([]byte(`{"tag_name":"v1.0.0","assets":[{"name":"a.tar.gz","browser_download_url":"https://example.com/a.tar.gz","digest":"sha256:abc"}]}`))
+		case "/repos/owner/repo/releases/tags/v0.9.0":
+			_, _ = w.Write([]byte(`{"tag_name":"v0.9.0","prerelease":true,"assets":[]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer cleanup()
+
+	latest, err := source.GetRelease("owner", "repo", "")
+	require.NoError(t, err)
+	assert.Equal(t, "v1.0.0", latest.TagName)
+	require.Len(t, latest.Assets, 1)
+	assert.Equal(t, "sha256:abc", latest.Assets[0].Digest)
+
+	tagged, err := source.GetRelease("owner", "repo", "v0.9.0")
+	require.NoError(t, err)
+	assert.True(t, tagged.Prerelease)
+
+	_, err = source.GetRelease("owner", "repo", "v404")
+	require.Error(t, err)
+}
+
+func TestListReleases(t *testing.T) {
+	githubAPITestMutex.Lock()
+	defer githubAPITestMutex.Unlock()
+
+	_, cleanup := setupSourceTest(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/owner/repo/releases", r.URL.Path)
+		_, _ = w.Write([]byte(`[{"id":2,"tag_name":"v1.1.0","body":"- Fix","published_at":"2026-02-01T00:00:00Z","assets":[{"id":20,"name":"a.tar.gz","size":7}]},{"id":1,"tag_name":"v1.0.0","draft":true}]`))
+	})
+	defer cleanup()
+
+	releases, err := source.ListReleases("owner", "repo")
+	require.NoError(t, err)
+	require.Len(t, releases, 2)
+	assert.Equal(t, "- Fix", releases[0].Body)
+	assert.Equal(t, 2026, releases[0].PublishedAt.Year())
+	assert.Equal(t, source.GitHubReleaseAsset{ID: 20, Name: "a.tar.gz", Size: 7}, releases[0].Assets[0])
+	assert.True(t, releases[1].Draft)
+}
+
+func TestGetCommitDate(t *testing.T) {
+	githubAPITestMutex.Lock()
+	defer githubAPITestMutex.Unlock()
+
+	_, cleanup := setupSourceTest(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/repo/commits/abc1234":
+			_, _ = w.Write([]byte(`{"sha":"abc1234def","commit":{"committer":{"date":"2024-05-01T10:00:00Z"}}}`))
+		case "/repos/owner/repo/commits/undated":
+			_, _ = w.Write([]byte(`{"sha":"undated"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message": "No commit found for SHA"}`))
+		}
+	})
+	defer cleanup()
+
+	date, err := source.GetCommitDate("owner", "repo", "abc1234")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), date.UTC())
+
+	_, err = source.GetCommitDate("owner", "repo", "undated")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no committer date")
+
+	_, err = source.GetCommitDate("owner", "repo", "missing")
+	require.Error(t, err)
+}
@@ -0,0 +1,67 @@
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// githubContentInfo is the subset of a GitHub contents API directory entry used to list the
+// files of a directory source.
+type githubContentInfo struct {
+	Type string `json:"type"` // "file", "dir", "symlink" or "submodule"
+	Path string `json:"path"`
+}
+
+func (githubProvider) ListDirectory(info *ParsedSourceInfo) ([]string, error) {
+	return defaultGitHubAPI().listDirectory(info)
+}
+
+// listDirectory returns the files below the directory info names, relative to it and sorted,
+// walking its subdirectories through the contents API. Symbolic links and submodules are skipped.
+func (api githubAPI) listDirectory(info *ParsedSourceInfo) ([]string, error) {
+	var files []string
+	pending := []string{info.PathInRepo}
+	for len(pending) > 0 {
+		dir := pending[0]
+		pending = pending[1:]
+		entries, err := api.listContents(info.Owner, info.Repo, dir, info.RefSegment())
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			switch entry.Type {
+			case "file":
+				files = append(files, strings.TrimPrefix(entry.Path, info.PathInRepo+"/"))
+			case "dir":
+				pending = append(pending, entry.Path)
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("directory '%s' of %s/%s has no files", info.PathInRepo, info.Owner, info.Repo)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// listContents returns the entries of one directory of a repository at ref.
+func (api githubAPI) listContents(owner, repo, dir, ref string) ([]githubContentInfo, error) {
+	// See: https://docs.github.com/en/rest/repos/contents#get-repository-content
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/contents/%s?ref=%s", api.base, owner, repo, escapePath(dir), url.QueryEscape(ref))
+	body, err := api.get(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	var entries []githubContentInfo
+	if err := json.Unmarshal(body, &entries); err != nil {
+		var single githubContentInfo
+		if json.Unmarshal(body, &single) == nil && single.Type != "" {
+			return nil, fmt.Errorf("'%s' in %s/%s is a %s, not a directory", dir, owner, repo, single.Type)
+		}
+		return nil, fmt.Errorf("failed to unmarshal GitHub API response (%s): %w. Body: %s", apiURL, err, string(body))
+	}
+	return entries, nil
+}
@@ -0,0 +1,64 @@
+package source_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+func TestListDirectory(t *testing.T) {
+	sourceTestMutex.Lock()
+	defer sourceTestMutex.Unlock()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "v1.0", r.URL.Query().Get("ref"))
+		switch r.URL.Path {
+		case "/repos/owner/repo/contents/lib":
+			_, _ = w.Write([]byte(`[{"type": "file", "path": "lib/b.lua"}, {"type": "dir", "path": "lib/sub"}, {"type": "submodule", "path": "lib/ext"}, {"type": "file", "path": "lib/a.lua"}]`))
+		case "/repos/owner/repo/contents/lib/sub":
+			_, _ = w.Write([]byte(`[{"type": "file", "path": "lib/sub/c.lua"}]`))
+		case "/repos/owner/repo/contents/lib/a.lua":
+			_, _ = w.Write([]byte(`{"type": "file", "path": "lib/a.lua"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	source.GithubAPIBaseURLMutex.Lock()
+	original := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = server.URL
+	source.GithubAPIBaseURLMutex.Unlock()
+	defer func() {
+		source.GithubAPIBaseURLMutex.Lock()
+		source.GithubAPIBaseURL = original
+		source.GithubAPIBaseURLMutex.Unlock()
+	}()
+
+	info, err := source.ParseSourceURL("github:owner/repo/lib/@v1.0")
+	require.NoError(t, err)
+	assert.True(t, info.Dir)
+	assert.Equal(t, "lib", info.PathInRepo)
+	assert.Equal(t, "github:owner/repo/lib/@v1.0", info.CanonicalURL)
+	assert.Equal(t, "https://raw.githubusercontent.com/owner/repo/v1.0/lib/", info.RawURL)
+	assert.Equal(t, "https://raw.githubusercontent.com/owner/repo/v1.0/lib/sub/c.lua", info.FileRawURL("sub/c.lua"))
+
+	files, err := source.ListDirectory(info)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.lua", "b.lua", "sub/c.lua"}, files)
+
+	fileInfo, err := source.ParseSourceURL("https://github.com/owner/repo/tree/v1.0/lib/a.lua")
+	require.NoError(t, err)
+	_, err = source.ListDirectory(fileInfo)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is a file, not a directory")
+
+	fileInfo, err = source.ParseSourceURL("github:owner/repo/lib/a.lua@v1.0")
+	require.NoError(t, err)
+	_, err = source.ListDirectory(fileInfo)
+	require.Error(t, err, "a source naming a file cannot be listed")
+}
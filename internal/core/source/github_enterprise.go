@@ -0,0 +1,154 @@
+package source
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GitHubEnterpriseHost is a GitHub Enterprise Server instance configured for the current project
+// ([github_enterprise.<alias>] in project.toml). Its sources are written as
+// "<alias>:owner/repo/path@ref" or as blob and raw URLs on the instance, and resolve and lock to
+// commits like github.com sources.
+type GitHubEnterpriseHost struct {
+	BaseURL  string // Site of the instance, e.g. "https://github.mycorp.com"
+	APIURL   string // REST API endpoint; BaseURL + "/api/v3" when empty
+	RawURL   string // Prefix of raw file URLs; BaseURL + "/raw" when empty
+	TokenEnv string // Environment variable holding the token sent with API requests, if any
+}
+
+func (h GitHubEnterpriseHost) apiURL() string {
+	if h.APIURL != "" {
+		return strings.TrimSuffix(h.APIURL, "/")
+	}
+	return strings.TrimSuffix(h.BaseURL, "/") + "/api/v3"
+}
+
+func (h GitHubEnterpriseHost) rawURL() string {
+	if h.RawURL != "" {
+		return strings.TrimSuffix(h.RawURL, "/")
+	}
+	return strings.TrimSuffix(h.BaseURL, "/") + "/raw"
+}
+
+// api returns the instance's REST API, authenticated with the token in TokenEnv when it is set.
+func (h GitHubEnterpriseHost) api() githubAPI {
+	var pool []string
+	if token := os.Getenv(h.TokenEnv); h.TokenEnv != "" && token != "" {
+		pool = []string{token}
+	}
+	get := func(apiURL string) ([]byte, error) {
+		return memoizedAPIGet(apiURL, func() ([]byte, error) {
+			body, _, err := githubAPIRequestWith(apiURL, pool)
+			return body, err
+		})
+	}
+	return githubAPI{base: h.apiURL(), get: get}
+}
+
+var enterpriseHosts map[string]GitHubEnterpriseHost // Guarded by settingsMu
+
+// SetGitHubEnterpriseHosts replaces the GitHub Enterprise instances, keyed by the alias their
+// shorthand sources use; nil removes them. Each becomes a provider named after its alias.
+func SetGitHubEnterpriseHosts(hosts map[string]GitHubEnterpriseHost) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	enterpriseHosts = hosts
+}
+
+// enterpriseProviders returns a provider for each configured GitHub Enterprise instance, in
+// alias order.
+func enterpriseProviders() []Provider {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	aliases := make([]string, 0, len(enterpriseHosts))
+	for alias := range enterpriseHosts {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	list := make([]Provider, 0, len(aliases))
+	for _, alias := range aliases {
+		list = append(list, enterpriseProvider{alias: alias, host: enterpriseHosts[alias]})
+	}
+	return list
+}
+
+// enterpriseProvider is the Provider for one GitHub Enterprise Server instance. Its URLs have
+// the same shape as github.com's, so it parses them as GitHub sources and moves them to its host.
+type enterpriseProvider struct {
+	alias string
+	host  GitHubEnterpriseHost
+}
+
+func (p enterpriseProvider) Name() string { return p.alias }
+
+func (p enterpriseProvider) Parse(sourceURL string) (*ParsedSourceInfo, bool, error) {
+	if rest, ok := strings.CutPrefix(sourceURL, p.alias+":"); ok {
+		info, err := parseGitHubShorthandURL("github:" + rest)
+		if err != nil {
+			return nil, true, fmt.Errorf("invalid %s source '%s': %w", p.alias, sourceURL, err)
+		}
+		return p.rehost(info, ""), true, nil
+	}
+	u, err := url.Parse(sourceURL)
+	if err != nil || u.Host == "" {
+		return nil, false, nil
+	}
+	if raw, err := url.Parse(p.host.rawURL()); err == nil && strings.EqualFold(u.Host, raw.Host) && strings.HasPrefix(u.Path, raw.Path+"/") {
+		rawPath := *u
+		rawPath.Path = strings.TrimPrefix(u.Path, raw.Path)
+		rawPath.RawPath = ""
+		info, err := parseRawGitHubUserContentURL(&rawPath)
+		if err != nil {
+			return nil, true, err
+		}
+		return p.rehost(info, u.RawQuery), true, nil
+	}
+	if base, err := url.Parse(p.host.BaseURL); err == nil && strings.EqualFold(u.Host, base.Host) {
+		info, err := parseGitHubFullURL(u)
+		if err != nil {
+			return nil, true, err
+		}
+		return p.rehost(info, ""), true, nil
+	}
+	return nil, false, nil
+}
+
+// rehost turns a source parsed as a github.com one into one of this instance, keeping query
+// (e.g. the token of a raw link) on its raw URL.
+func (p enterpriseProvider) rehost(info *ParsedSourceInfo, query string) *ParsedSourceInfo {
+	info.Provider = p.alias
+	info.CanonicalURL = p.alias + ":" + strings.TrimPrefix(info.CanonicalURL, ProviderGitHub+":")
+	info.RawURL = sourceRawURL(p, info)
+	if query != "" {
+		info.RawURL += "?" + query
+	}
+	return info
+}
+
+func (p enterpriseProvider) ResolveRef(info *ParsedSourceInfo) (string, error) {
+	return p.host.api().latestCommitSHAForFile(info.Owner, info.Repo, info.PathInRepo, info.RefSegment())
+}
+
+func (p enterpriseProvider) CommitDate(info *ParsedSourceInfo, sha string) (time.Time, error) {
+	return p.host.api().commitDate(info.Owner, info.Repo, sha)
+}
+
+func (p enterpriseProvider) RawURL(info *ParsedSourceInfo, pathInRepo string) string {
+	return fmt.Sprintf("%s/%s/%s/%s/%s", p.host.rawURL(), info.Owner, info.Repo, escapePath(info.RefSegment()), escapePath(pathInRepo))
+}
+
+func (p enterpriseProvider) ListTags(info *ParsedSourceInfo) ([]string, error) {
+	return p.host.api().tagNames(info)
+}
+
+func (p enterpriseProvider) FetchMetadata(info *ParsedSourceInfo) (*Metadata, error) {
+	return p.host.api().metadata(info)
+}
+
+func (p enterpriseProvider) ListDirectory(info *ParsedSourceInfo) ([]string, error) {
+	return p.host.api().listDirectory(info)
+}
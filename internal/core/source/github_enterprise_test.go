@@ -0,0 +1,84 @@
+package source_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+func TestParseSourceURL_GitHubEnterprise(t *testing.T) {
+	sourceTestMutex.Lock()
+	defer sourceTestMutex.Unlock()
+	source.SetGitHubEnterpriseHosts(map[string]source.GitHubEnterpriseHost{
+		"corp": {BaseURL: "https://github.mycorp.com"},
+		"lab":  {BaseURL: "https://git.lab.example", RawURL: "https://raw.git.lab.example"},
+	})
+	defer source.SetGitHubEnterpriseHosts(nil)
+
+	const raw = "https://github.mycorp.com/raw/team/lua-utils/v1.2.0/src/util.lua"
+	for _, sourceURL := range []string{
+		"corp:team/lua-utils/src/util.lua@v1.2.0",
+		"https://github.mycorp.com/team/lua-utils/blob/v1.2.0/src/util.lua",
+		raw,
+	} {
+		t.Run(sourceURL, func(t *testing.T) {
+			info, err := source.ParseSourceURL(sourceURL)
+			require.NoError(t, err)
+			assert.Equal(t, "corp", info.Provider)
+			assert.Equal(t, "team", info.Owner)
+			assert.Equal(t, "lua-utils", info.Repo)
+			assert.Equal(t, "src/util.lua", info.PathInRepo)
+			assert.Equal(t, "v1.2.0", info.Ref)
+			assert.Equal(t, "corp:team/lua-utils/src/util.lua@v1.2.0", info.CanonicalURL)
+			assert.Equal(t, raw, info.RawURL)
+		})
+	}
+
+	info, err := source.ParseSourceURL("https://raw.git.lab.example/team/repo/refs/tags/v1/lib.lua?token=abc")
+	require.NoError(t, err)
+	assert.Equal(t, "lab", info.Provider)
+	assert.Equal(t, "lab:team/repo/lib.lua@tag:v1", info.CanonicalURL)
+	assert.Equal(t, "https://raw.git.lab.example/team/repo/refs/tags/v1/lib.lua?token=abc", info.RawURL)
+	assert.True(t, source.HasCommits("lab"))
+
+	info, err = source.ParseSourceURL("https://github.com/owner/repo/blob/main/lib.lua")
+	require.NoError(t, err)
+	assert.Equal(t, source.ProviderGitHub, info.Provider, "github.com sources are unaffected")
+}
+
+func TestGitHubEnterprise_ResolveRef(t *testing.T) {
+	sourceTestMutex.Lock()
+	defer sourceTestMutex.Unlock()
+	const sha = "0123456789abcdef0123456789abcdef01234567"
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		if r.URL.Path != "/api/v3/repos/team/lua-utils/commits" || r.URL.Query().Get("sha") != "main" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"sha": "` + sha + `"}]`))
+	}))
+	defer server.Close()
+	t.Setenv("CORP_GITHUB_TOKEN", "corp-secret")
+	source.SetGitHubEnterpriseHosts(map[string]source.GitHubEnterpriseHost{
+		"corp": {BaseURL: server.URL, TokenEnv: "CORP_GITHUB_TOKEN"},
+	})
+	defer source.SetGitHubEnterpriseHosts(nil)
+
+	info, err := source.ParseSourceURL("corp:team/lua-utils/src/util.lua@main")
+	require.NoError(t, err)
+	got, err := source.ResolveRef(info)
+	require.NoError(t, err)
+	assert.Equal(t, sha, got)
+	assert.Equal(t, "Bearer corp-secret", authorization)
+
+	pinned, err := info.AtCommit(sha)
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/raw/team/lua-utils/"+sha+"/src/util.lua", pinned.RawURL)
+}
@@ -0,0 +1,87 @@
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// githubRefInfo is the subset of the GitHub matching-refs API response used to find branches
+// and tags whose names contain a slash.
+type githubRefInfo struct {
+	Ref string `json:"ref"`
+}
+
+// listMatchingRefs returns the names of the branches (kind "heads") or tags (kind "tags") of a
+// GitHub repository that start with prefix, without their refs/<kind>/ prefix.
+func listMatchingRefs(owner, repo, kind, prefix string) ([]string, error) {
+	// See: https://docs.github.com/en/rest/git/refs#list-matching-references
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/git/matching-refs/%s/%s", githubAPIBaseURL(), owner, repo, kind, escapePath(prefix))
+	body, err := githubAPIGet(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	var refs []githubRefInfo
+	if err := json.Unmarshal(body, &refs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal GitHub API response (%s): %w. Body: %s", apiURL, err, string(body))
+	}
+	names := make([]string, 0, len(refs))
+	for _, r := range refs {
+		names = append(names, strings.TrimPrefix(r.Ref, "refs/"+kind+"/"))
+	}
+	return names, nil
+}
+
+// DisambiguateRef moves leading path segments of a GitHub source into its ref when they are
+// part of a branch or tag name containing a slash. A blob URL such as
+// https://github.com/owner/repo/blob/release/1.x/lib/file.lua cannot say where the ref ends,
+// so it parses as ref "release" and path "1.x/lib/file.lua"; this asks GitHub which refs
+// start with "release/" and picks the longest one the path continues. Shorthand sources
+// (github:owner/repo/path@release/1.x), whose ref is everything after the '@', commits and
+// sources of other providers are returned unchanged.
+func DisambiguateRef(p *ParsedSourceInfo, sourceURL string) (*ParsedSourceInfo, error) {
+	if p.Provider != ProviderGitHub || strings.HasPrefix(sourceURL, "github:") || p.RefType == RefTypeCommit || (len(p.Ref) == 40 && isHexCommitSHA(p.Ref)) || !strings.Contains(p.PathInRepo, "/") {
+		return p, nil
+	}
+
+	kinds := map[string]string{"heads": RefTypeBranch, "tags": RefTypeTag}
+	candidates := make(map[string]bool)
+	for kind, refType := range kinds {
+		if p.RefType != "" && p.RefType != refType {
+			continue
+		}
+		names, err := listMatchingRefs(p.Owner, p.Repo, kind, p.Ref+"/")
+		if err != nil {
+			return nil, fmt.Errorf("looking up refs starting with '%s/' in %s/%s: %w", p.Ref, p.Owner, p.Repo, err)
+		}
+		for _, name := range names {
+			candidates[name] = true
+		}
+	}
+
+	segments := strings.Split(p.PathInRepo, "/")
+	for n := len(segments) - 1; n > 0; n-- {
+		ref := p.Ref + "/" + strings.Join(segments[:n], "/")
+		if candidates[ref] {
+			return p.withRef(ref, strings.Join(segments[n:], "/")), nil
+		}
+	}
+	return p, nil
+}
+
+// withRef returns a copy of the GitHub source p at ref (of the same type) with path pathInRepo,
+// keeping the query of its raw URL.
+func (p *ParsedSourceInfo) withRef(ref, pathInRepo string) *ParsedSourceInfo {
+	resolved := *p
+	resolved.Ref = ref
+	resolved.PathInRepo = pathInRepo
+	resolved.CanonicalURL = canonicalSource(p.Owner, p.Repo, pathInRepo, resolved.QualifiedRef())
+	resolved.RawURL = githubRawURL(p.Owner, p.Repo, resolved.RefSegment(), pathInRepo)
+	if _, query, ok := strings.Cut(p.RawURL, "?"); ok {
+		resolved.RawURL += "?" + query
+	}
+	if p.Dir {
+		resolved.asDirectory()
+	}
+	return &resolved
+}
@@ -0,0 +1,102 @@
+package source_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+func TestParseSourceURL_RefsWithSlashes(t *testing.T) {
+	sourceTestMutex.Lock()
+	defer sourceTestMutex.Unlock()
+
+	const raw = "https://raw.githubusercontent.com/owner/repo/release/1.x/lib/file.lua"
+	for _, sourceURL := range []string{
+		"github:owner/repo/lib/file.lua@release/1.x",
+		"https://github.com/owner/repo/blob/release%2F1.x/lib/file.lua",
+		"https://github.com/owner/repo/lib/file.lua@release/1.x",
+		"https://raw.githubusercontent.com/owner/repo/release%2F1.x/lib/file.lua",
+	} {
+		t.Run(sourceURL, func(t *testing.T) {
+			got, err := source.ParseSourceURL(sourceURL)
+			require.NoError(t, err)
+			assert.Equal(t, "release/1.x", got.Ref)
+			assert.Equal(t, "lib/file.lua", got.PathInRepo)
+			assert.Equal(t, raw, got.RawURL)
+			assert.Equal(t, "github:owner/repo/lib/file.lua@release/1.x", got.CanonicalURL)
+		})
+	}
+
+	got, err := source.ParseSourceURL("https://raw.githubusercontent.com/owner/repo/refs/heads/release%2F1.x/lib/file.lua")
+	require.NoError(t, err)
+	assert.Equal(t, "release/1.x", got.Ref)
+	assert.Equal(t, source.RefTypeBranch, got.RefType)
+	assert.Equal(t, "github:owner/repo/lib/file.lua@branch:release/1.x", got.CanonicalURL)
+}
+
+func TestDisambiguateRef(t *testing.T) {
+	sourceTestMutex.Lock()
+	defer sourceTestMutex.Unlock()
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		switch r.URL.Path {
+		case "/repos/owner/repo/git/matching-refs/heads/release/":
+			_, _ = w.Write([]byte(`[{"ref": "refs/heads/release/1.x"}, {"ref": "refs/heads/release/1.x/rc"}]`))
+		case "/repos/owner/repo/git/matching-refs/tags/release/":
+			_, _ = w.Write([]byte(`[]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	source.GithubAPIBaseURLMutex.Lock()
+	original := source.GithubAPIBaseURL
+	source.GithubAPIBaseURL = server.URL
+	source.GithubAPIBaseURLMutex.Unlock()
+	defer func() {
+		source.GithubAPIBaseURLMutex.Lock()
+		source.GithubAPIBaseURL = original
+		source.GithubAPIBaseURLMutex.Unlock()
+	}()
+
+	for sourceURL, wantRef := range map[string]string{
+		"https://github.com/owner/repo/blob/release/1.x/lib/file.lua":    "release/1.x",
+		"https://github.com/owner/repo/blob/release/1.x/rc/lib/file.lua": "release/1.x/rc",
+		"https://github.com/owner/repo/blob/release/2.x/lib/file.lua":    "release",
+	} {
+		info, err := source.ParseSourceURL(sourceURL)
+		require.NoError(t, err)
+		got, err := source.DisambiguateRef(info, sourceURL)
+		require.NoError(t, err, sourceURL)
+		assert.Equal(t, wantRef, got.Ref, sourceURL)
+		assert.True(t, strings.HasSuffix(got.PathInRepo, "lib/file.lua"), sourceURL)
+	}
+
+	info, err := source.ParseSourceURL("https://raw.githubusercontent.com/owner/repo/refs/heads/release/1.x/lib/file.lua?token=abc")
+	require.NoError(t, err)
+	requests = nil
+	got, err := source.DisambiguateRef(info, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/repos/owner/repo/git/matching-refs/heads/release/"}, requests, "a qualified branch only looks up branches")
+	assert.Equal(t, "release/1.x", got.Ref)
+	assert.Equal(t, "lib/file.lua", got.PathInRepo)
+	assert.Equal(t, "github:owner/repo/lib/file.lua@branch:release/1.x", got.CanonicalURL)
+	assert.Equal(t, "https://raw.githubusercontent.com/owner/repo/refs/heads/release/1.x/lib/file.lua?token=abc", got.RawURL)
+
+	requests = nil
+	shorthand := "github:owner/repo/lib/file.lua@release"
+	info, err = source.ParseSourceURL(shorthand)
+	require.NoError(t, err)
+	got, err = source.DisambiguateRef(info, shorthand)
+	require.NoError(t, err)
+	assert.Same(t, info, got)
+	assert.Empty(t, requests, "a shorthand ref is never split")
+}
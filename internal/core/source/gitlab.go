@@ -0,0 +1,254 @@
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProviderGitLab is the name of the built-in GitLab provider.
+const ProviderGitLab = "gitlab"
+
+// GitLabBaseURL is the GitLab instance that gitlab: sources and gitlab.com URLs refer to. Tests
+// override it with a mock server.
+var GitLabBaseURL = "https://gitlab.com"
+var GitLabBaseURLMutex sync.Mutex // Mutex for GitLabBaseURL (Exported)
+
+// gitlabProvider is the built-in Provider for gitlab.com and the
+// "gitlab:group/project/path@ref" shorthand. Projects in subgroups are written with GitLab's
+// "/-/" separator: "gitlab:group/subgroup/project/-/path@ref".
+type gitlabProvider struct{}
+
+func (gitlabProvider) Name() string { return ProviderGitLab }
+
+func (gitlabProvider) Parse(sourceURL string) (*ParsedSourceInfo, bool, error) {
+	if strings.HasPrefix(sourceURL, "gitlab:") {
+		info, err := parseGitLabShorthandURL(sourceURL)
+		return info, true, err
+	}
+	u, err := url.Parse(sourceURL)
+	if err != nil || u.Host == "" {
+		return nil, false, nil
+	}
+	base, err := url.Parse(gitlabBaseURL())
+	if err != nil || !strings.EqualFold(u.Host, base.Host) {
+		return nil, false, nil
+	}
+	info, err := parseGitLabWebURL(u)
+	return info, true, err
+}
+
+func (gitlabProvider) ResolveRef(info *ParsedSourceInfo) (string, error) {
+	apiURL := fmt.Sprintf("%s/repository/commits?ref_name=%s&path=%s&per_page=1",
+		gitlabProjectAPIURL(info), url.QueryEscape(info.Ref), url.QueryEscape(info.PathInRepo))
+	body, err := hostAPIGet("GitLab", apiURL)
+	if err != nil {
+		return "", err
+	}
+	var commits []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &commits); err != nil {
+		return "", fmt.Errorf("failed to unmarshal GitLab API response (%s): %w. Body: %s", apiURL, err, string(body))
+	}
+	if len(commits) == 0 {
+		return "", fmt.Errorf("no commits found for path '%s' at ref '%s' in GitLab project '%s/%s'. The file might not exist at this path/ref", info.PathInRepo, info.Ref, info.Owner, info.Repo)
+	}
+	return commits[0].ID, nil
+}
+
+func (gitlabProvider) CommitDate(info *ParsedSourceInfo, sha string) (time.Time, error) {
+	apiURL := fmt.Sprintf("%s/repository/commits/%s", gitlabProjectAPIURL(info), url.PathEscape(sha))
+	body, err := hostAPIGet("GitLab", apiURL)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var commit struct {
+		CommittedDate time.Time `json:"committed_date"`
+	}
+	if err := json.Unmarshal(body, &commit); err != nil {
+		return time.Time{}, fmt.Errorf("failed to unmarshal GitLab API response (%s): %w. Body: %s", apiURL, err, string(body))
+	}
+	if commit.CommittedDate.IsZero() {
+		return time.Time{}, fmt.Errorf("GitLab API response for commit %s in %s/%s has no commit date", sha, info.Owner, info.Repo)
+	}
+	return commit.CommittedDate, nil
+}
+
+func (gitlabProvider) RawURL(info *ParsedSourceInfo, pathInRepo string) string {
+	return gitlabRawURL(info.Owner, info.Repo, info.Ref, pathInRepo)
+}
+
+func (gitlabProvider) ListTags(info *ParsedSourceInfo) ([]string, error) {
+	const perPage = 100
+	var names []string
+	for page := 1; page <= maxTagPages; page++ {
+		apiURL := fmt.Sprintf("%s/repository/tags?per_page=%d&page=%d", gitlabProjectAPIURL(info), perPage, page)
+		body, err := hostAPIGet("GitLab", apiURL)
+		if err != nil {
+			return nil, err
+		}
+		var pageTags []struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(body, &pageTags); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal GitLab API response (%s): %w. Body: %s", apiURL, err, string(body))
+		}
+		for _, t := range pageTags {
+			names = append(names, t.Name)
+		}
+		if len(pageTags) < perPage {
+			break
+		}
+	}
+	return names, nil
+}
+
+func (gitlabProvider) FetchMetadata(info *ParsedSourceInfo) (*Metadata, error) {
+	apiURL := gitlabProjectAPIURL(info)
+	body, err := hostAPIGet("GitLab", apiURL)
+	if err != nil {
+		return nil, err
+	}
+	var project struct {
+		Description   string `json:"description"`
+		WebURL        string `json:"web_url"`
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := json.Unmarshal(body, &project); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal GitLab API response (%s): %w. Body: %s", apiURL, err, string(body))
+	}
+	return &Metadata{Description: project.Description, HomepageURL: project.WebURL, DefaultBranch: project.DefaultBranch}, nil
+}
+
+// gitlabBaseURL returns the current GitLab instance URL.
+func gitlabBaseURL() string {
+	GitLabBaseURLMutex.Lock()
+	defer GitLabBaseURLMutex.Unlock()
+	return strings.TrimSuffix(GitLabBaseURL, "/")
+}
+
+// gitlabAPIBaseURL returns the GitLab API base URL: the project's [providers.gitlab] api_url if
+// set, otherwise the v4 API of the GitLab instance.
+func gitlabAPIBaseURL() string {
+	if apiURL := providerAPIURL(ProviderGitLab); apiURL != "" {
+		return apiURL
+	}
+	return gitlabBaseURL() + "/api/v4"
+}
+
+// gitlabProjectAPIURL returns the API URL of the parsed source's project, which GitLab
+// identifies by its URL-encoded full path.
+func gitlabProjectAPIURL(info *ParsedSourceInfo) string {
+	return fmt.Sprintf("%s/projects/%s", gitlabAPIBaseURL(), url.QueryEscape(info.Owner+"/"+info.Repo))
+}
+
+// gitlabRawURL builds the raw content URL of a file at ref.
+func gitlabRawURL(owner, repo, ref, pathInRepo string) string {
+	return fmt.Sprintf("%s/%s/%s/-/raw/%s/%s", gitlabBaseURL(), owner, repo, url.PathEscape(ref), escapePath(pathInRepo))
+}
+
+// gitlabCanonicalSource builds the "gitlab:group/project/path@ref" form of a parsed source,
+// with the "/-/" separator when the project is in a subgroup.
+func gitlabCanonicalSource(owner, repo, pathInRepo, qualifiedRef string) string {
+	separator := "/"
+	if strings.Contains(owner, "/") {
+		separator = "/-/"
+	}
+	return fmt.Sprintf("gitlab:%s/%s%s%s@%s", owner, repo, separator, strings.ReplaceAll(pathInRepo, "%", "%25"), qualifiedRef)
+}
+
+// newGitLabSourceInfo fills in a ParsedSourceInfo for a file of the GitLab project owner/repo.
+func newGitLabSourceInfo(owner, repo, pathInRepo, refType, ref string) (*ParsedSourceInfo, error) {
+	if owner == "" || repo == "" || pathInRepo == "" || ref == "" || strings.HasSuffix(pathInRepo, "/") {
+		return nil, fmt.Errorf("one or more components (group, project, ref, path) are empty")
+	}
+	return &ParsedSourceInfo{
+		RawURL:            gitlabRawURL(owner, repo, ref, pathInRepo),
+		CanonicalURL:      gitlabCanonicalSource(owner, repo, pathInRepo, qualifyRef(refType, ref)),
+		Ref:               ref,
+		RefType:           refType,
+		Provider:          ProviderGitLab,
+		Owner:             owner,
+		Repo:              repo,
+		PathInRepo:        pathInRepo,
+		SuggestedFilename: pathInRepo[strings.LastIndex(pathInRepo, "/")+1:],
+	}, nil
+}
+
+// splitGitLabProject splits "group/subgroup/project" into the owner "group/subgroup" and the
+// project name.
+func splitGitLabProject(projectPath string) (owner, repo string) {
+	idx := strings.LastIndex(projectPath, "/")
+	if idx == -1 {
+		return "", projectPath
+	}
+	return projectPath[:idx], projectPath[idx+1:]
+}
+
+// cutLegacyGitLabPath splits a file path without the "/-/" separator before its first blob or
+// raw segment that follows at least a group and a project.
+func cutLegacyGitLabPath(p string) (projectPath, rest string, ok bool) {
+	parts := strings.Split(p, "/")
+	for i := 2; i < len(parts); i++ {
+		if parts[i] == "blob" || parts[i] == "raw" {
+			return strings.Join(parts[:i], "/"), strings.Join(parts[i:], "/"), true
+		}
+	}
+	return "", "", false
+}
+
+// parseGitLabShorthandURL handles sources like "gitlab:group/project/path/to/file@ref" and
+// "gitlab:group/subgroup/project/-/path/to/file@ref".
+func parseGitLabShorthandURL(sourceURL string) (*ParsedSourceInfo, error) {
+	repoAndPath, refType, ref, err := splitShorthand(sourceURL, ProviderGitLab)
+	if err != nil {
+		return nil, err
+	}
+
+	var owner, repo, pathInRepo string
+	if projectPath, rest, ok := strings.Cut(repoAndPath, "/-/"); ok {
+		owner, repo = splitGitLabProject(projectPath)
+		pathInRepo = rest
+	} else if parts := strings.SplitN(repoAndPath, "/", 3); len(parts) == 3 {
+		owner, repo, pathInRepo = parts[0], parts[1], parts[2]
+	}
+	info, err := newGitLabSourceInfo(owner, repo, pathInRepo, refType, ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gitlab shorthand source '%s': expected group/project/path/to/file@ref: %w", sourceURL, err)
+	}
+	return info, nil
+}
+
+// parseGitLabWebURL handles GitLab file URLs: /<project>/-/blob/<ref>/<path> and
+// /<project>/-/raw/<ref>/<path>, where the project may be in subgroups, and the older form
+// without the "/-/" separator, which GitLab still redirects.
+func parseGitLabWebURL(u *url.URL) (*ParsedSourceInfo, error) {
+	projectPath, rest, ok := strings.Cut(strings.Trim(u.Path, "/"), "/-/")
+	if !ok {
+		projectPath, rest, ok = cutLegacyGitLabPath(strings.Trim(u.Path, "/"))
+	}
+	if !ok {
+		return nil, fmt.Errorf("unsupported GitLab URL '%s'. Expected a /-/blob/<ref>/<path> or /-/raw/<ref>/<path> file URL, or gitlab:group/project/path@ref", u.String())
+	}
+	parts := strings.SplitN(rest, "/", 3)
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("incomplete GitLab URL '%s'. Expected /<project>/-/blob/<ref>/<path_to_file>", u.String())
+	}
+	switch parts[0] {
+	case "blob", "raw":
+	case "tree":
+		return nil, fmt.Errorf("direct links to GitLab trees are not supported for adding single files: %s", u.String())
+	default:
+		return nil, fmt.Errorf("unsupported GitLab URL '%s'. Expected a /-/blob/ or /-/raw/ file URL", u.String())
+	}
+	owner, repo := splitGitLabProject(projectPath)
+	info, err := newGitLabSourceInfo(owner, repo, parts[2], "", parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid GitLab URL '%s': %w", u.String(), err)
+	}
+	return info, nil
+}
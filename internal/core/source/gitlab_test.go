@@ -0,0 +1,149 @@
+package source_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+// setupGitLabTest points the GitLab provider at a mock server running handler.
+func setupGitLabTest(t *testing.T, handler http.HandlerFunc) string {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	source.GitLabBaseURLMutex.Lock()
+	original := source.GitLabBaseURL
+	source.GitLabBaseURL = server.URL
+	source.GitLabBaseURLMutex.Unlock()
+	t.Cleanup(func() {
+		server.Close()
+		source.GitLabBaseURLMutex.Lock()
+		source.GitLabBaseURL = original
+		source.GitLabBaseURLMutex.Unlock()
+	})
+	return server.URL
+}
+
+func TestParseSourceURL_GitLab(t *testing.T) {
+	sourceTestMutex.Lock()
+	defer sourceTestMutex.Unlock()
+
+	tests := []struct {
+		name string
+		url  string
+		want *source.ParsedSourceInfo
+	}{
+		{
+			name: "shorthand",
+			url:  "gitlab:owner/repo/src/lib.lua@tag:v1.0.0",
+			want: &source.ParsedSourceInfo{
+				RawURL:            "https://gitlab.com/owner/repo/-/raw/v1.0.0/src/lib.lua",
+				CanonicalURL:      "gitlab:owner/repo/src/lib.lua@tag:v1.0.0",
+				Ref:               "v1.0.0",
+				RefType:           source.RefTypeTag,
+				Provider:          source.ProviderGitLab,
+				Owner:             "owner",
+				Repo:              "repo",
+				PathInRepo:        "src/lib.lua",
+				SuggestedFilename: "lib.lua",
+			},
+		},
+		{
+			name: "shorthand in a subgroup",
+			url:  "gitlab:group/sub/repo/-/lib.lua@main",
+			want: &source.ParsedSourceInfo{
+				RawURL:            "https://gitlab.com/group/sub/repo/-/raw/main/lib.lua",
+				CanonicalURL:      "gitlab:group/sub/repo/-/lib.lua@main",
+				Ref:               "main",
+				Provider:          source.ProviderGitLab,
+				Owner:             "group/sub",
+				Repo:              "repo",
+				PathInRepo:        "lib.lua",
+				SuggestedFilename: "lib.lua",
+			},
+		},
+		{
+			name: "blob URL",
+			url:  "https://gitlab.com/group/sub/repo/-/blob/main/src/lib.lua",
+			want: &source.ParsedSourceInfo{
+				RawURL:            "https://gitlab.com/group/sub/repo/-/raw/main/src/lib.lua",
+				CanonicalURL:      "gitlab:group/sub/repo/-/src/lib.lua@main",
+				Ref:               "main",
+				Provider:          source.ProviderGitLab,
+				Owner:             "group/sub",
+				Repo:              "repo",
+				PathInRepo:        "src/lib.lua",
+				SuggestedFilename: "lib.lua",
+			},
+		},
+		{
+			name: "raw URL without the separator",
+			url:  "https://gitlab.com/owner/repo/raw/main/lib.lua",
+			want: &source.ParsedSourceInfo{
+				RawURL:            "https://gitlab.com/owner/repo/-/raw/main/lib.lua",
+				CanonicalURL:      "gitlab:owner/repo/lib.lua@main",
+				Ref:               "main",
+				Provider:          source.ProviderGitLab,
+				Owner:             "owner",
+				Repo:              "repo",
+				PathInRepo:        "lib.lua",
+				SuggestedFilename: "lib.lua",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := source.ParseSourceURL(tt.url)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	for _, bad := range []string{
+		"gitlab:owner/repo/lib.lua",
+		"gitlab:owner/lib.lua@main",
+		"https://gitlab.com/owner/repo/-/tree/main/src",
+		"https://gitlab.com/owner/repo",
+	} {
+		_, err := source.ParseSourceURL(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestGitLabProvider_ResolveRef(t *testing.T) {
+	sourceTestMutex.Lock()
+	defer sourceTestMutex.Unlock()
+
+	baseURL := setupGitLabTest(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/group%2Fsub%2Frepo/repository/commits":
+			assert.Equal(t, "main", r.URL.Query().Get("ref_name"))
+			assert.Equal(t, "src/lib.lua", r.URL.Query().Get("path"))
+			_, _ = w.Write([]byte(`[{"id": "0123456789abcdef0123456789abcdef01234567"}]`))
+		case "/api/v4/projects/group%2Fsub%2Frepo/repository/tags":
+			_, _ = w.Write([]byte(`[{"name": "v1.0.0"}, {"name": "v1.1.0"}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	info, err := source.ParseSourceURL(baseURL + "/group/sub/repo/-/blob/main/src/lib.lua")
+	require.NoError(t, err)
+	sha, err := source.ResolveRef(info)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef01234567", sha)
+
+	pinned, err := info.AtCommit(sha)
+	require.NoError(t, err)
+	assert.Equal(t, baseURL+"/group/sub/repo/-/raw/0123456789abcdef0123456789abcdef01234567/src/lib.lua", pinned.RawURL)
+
+	info, err = source.ParseSourceURL("gitlab:group/sub/repo/-/src/lib.lua@v1.*")
+	require.NoError(t, err)
+	resolved, err := source.ResolveTagPattern(info)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.1.0", resolved.Ref)
+}
@@ -0,0 +1,31 @@
+package source
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/nightconcept/almandine/internal/core/httpclient"
+)
+
+// hostAPIGet performs a GET request against the API of a provider other than GitHub and returns
+// the response body; host names the provider in errors. Non-200 responses are returned as
+// errors that include the response body. Responses are memoized like GitHub's while
+// StartAPIMemo is in effect.
+func hostAPIGet(host, apiURL string) ([]byte, error) {
+	return memoizedAPIGet(apiURL, func() ([]byte, error) {
+		resp, err := httpclient.Client(httpclient.APITimeout).Get(apiURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to call %s API (%s): %w", host, apiURL, err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response body from %s API (%s): %w", host, apiURL, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s API request failed with status %s (%s): %s", host, resp.Status, apiURL, string(body))
+		}
+		return body, nil
+	})
+}
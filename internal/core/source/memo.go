@@ -0,0 +1,154 @@
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nightconcept/almandine/internal/core/logger"
+	"github.com/nightconcept/almandine/internal/core/paths"
+)
+
+// apiMemoFile is where StopAPIMemo keeps responses between runs, inside the cache directory.
+const apiMemoFile = "api-responses.json"
+
+// memoCall is one provider API GET; callers asking for the same URL while it is in flight wait
+// for its result instead of sending their own request.
+type memoCall struct {
+	done chan struct{}
+	body []byte
+	err  error
+}
+
+// memoResponse is a response kept between runs.
+type memoResponse struct {
+	Body    []byte    `json:"body"`
+	Fetched time.Time `json:"fetched"`
+}
+
+var (
+	memoMu    sync.Mutex
+	memoOn    bool
+	memoTTL   time.Duration
+	memoCalls map[string]*memoCall
+	memoSaved map[string]memoResponse // Responses loaded from and written to apiMemoFile
+
+	memoNow = time.Now // Replaced in tests
+)
+
+// StartAPIMemo makes repeated provider API lookups of the same URL (ref resolution, tag lists,
+// commit dates) answer from the first response for the rest of the run, so code paths that
+// resolve the same ref share one request. With a positive ttl, responses are also kept in the
+// cache directory and reused by later runs until they are ttl old.
+func StartAPIMemo(ttl time.Duration) {
+	memoMu.Lock()
+	defer memoMu.Unlock()
+	memoOn, memoTTL = true, ttl
+	memoCalls = map[string]*memoCall{}
+	memoSaved = map[string]memoResponse{}
+	if ttl <= 0 {
+		return
+	}
+	path, err := apiMemoPath()
+	if err != nil {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	_ = json.Unmarshal(data, &memoSaved) // A damaged file only costs the requests it would have saved
+}
+
+// StopAPIMemo ends memoization and, when StartAPIMemo was given a ttl, writes the responses that
+// are still fresh to the cache directory for later runs.
+func StopAPIMemo() error {
+	memoMu.Lock()
+	defer memoMu.Unlock()
+	if !memoOn {
+		return nil
+	}
+	saved, ttl := memoSaved, memoTTL
+	memoOn, memoCalls, memoSaved = false, nil, nil
+	if ttl <= 0 {
+		return nil
+	}
+
+	for url, resp := range saved {
+		if memoNow().Sub(resp.Fetched) >= ttl {
+			delete(saved, url)
+		}
+	}
+	path, err := apiMemoPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return fmt.Errorf("encoding API responses: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating cache directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func apiMemoPath() (string, error) {
+	dir, err := paths.CacheDir()
+	if err != nil {
+		return "", fmt.Errorf("determining cache directory: %w", err)
+	}
+	return filepath.Join(dir, apiMemoFile), nil
+}
+
+// repoEndpoints are the path segments of the repository endpoints of each provider's API.
+var repoEndpoints = []string{"/repos/", "/projects/", "/repositories/"}
+
+// memoizedAPIGet returns the memoized response for apiURL, calling get for it the first time.
+// Failed calls are not remembered, so a later lookup tries again. Only repository endpoints are
+// memoized; the rate limit endpoint must always report the current state.
+func memoizedAPIGet(apiURL string, get func() ([]byte, error)) ([]byte, error) {
+	memoMu.Lock()
+	if !memoOn || !slices.ContainsFunc(repoEndpoints, func(segment string) bool { return strings.Contains(apiURL, segment) }) {
+		memoMu.Unlock()
+		return get()
+	}
+	if call, ok := memoCalls[apiURL]; ok {
+		memoMu.Unlock()
+		logger.Debugf("reusing API response for %s", apiURL)
+		<-call.done
+		return call.body, call.err
+	}
+	if resp, ok := memoSaved[apiURL]; ok && memoNow().Sub(resp.Fetched) < memoTTL {
+		memoMu.Unlock()
+		logger.Debugf("reusing API response for %s from %s", apiURL, resp.Fetched.Local().Format(time.Kitchen))
+		return resp.Body, nil
+	}
+	call := &memoCall{done: make(chan struct{})}
+	memoCalls[apiURL] = call
+	memoMu.Unlock()
+
+	call.body, call.err = get()
+	close(call.done)
+
+	memoMu.Lock()
+	defer memoMu.Unlock()
+	if memoCalls == nil {
+		return call.body, call.err // StopAPIMemo ran meanwhile
+	}
+	if call.err != nil {
+		delete(memoCalls, apiURL)
+	} else if memoTTL > 0 {
+		memoSaved[apiURL] = memoResponse{Body: call.body, Fetched: memoNow()}
+	}
+	return call.body, call.err
+}
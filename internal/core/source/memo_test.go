@@ -0,0 +1,67 @@
+package source
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/paths"
+)
+
+func TestAPIMemo(t *testing.T) {
+	t.Setenv(paths.CacheDirEnv, t.TempDir())
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	memoNow = func() time.Time { return now }
+	defer func() { memoNow = time.Now }()
+
+	const url = "https://api.github.com/repos/owner/repo/tags?per_page=100&page=1"
+	calls := 0
+	get := func() ([]byte, error) {
+		calls++
+		return []byte("tags"), nil
+	}
+	lookup := func() {
+		t.Helper()
+		body, err := memoizedAPIGet(url, get)
+		require.NoError(t, err)
+		assert.Equal(t, "tags", string(body))
+	}
+
+	// Without StartAPIMemo every lookup is a request.
+	lookup()
+	lookup()
+	assert.Equal(t, 2, calls)
+
+	// Within a run the first response answers later lookups; failures are retried.
+	StartAPIMemo(0)
+	_, err := memoizedAPIGet("https://api.github.com/repos/owner/repo/commits", func() ([]byte, error) { return nil, errors.New("boom") })
+	require.Error(t, err)
+	body, err := memoizedAPIGet("https://api.github.com/repos/owner/repo/commits", get)
+	require.NoError(t, err)
+	assert.Equal(t, "tags", string(body))
+	lookup()
+	lookup()
+	assert.Equal(t, 4, calls)
+	require.NoError(t, StopAPIMemo())
+
+	// With a ttl the responses carry over to the next run until they expire.
+	StartAPIMemo(10 * time.Minute)
+	lookup()
+	assert.Equal(t, 5, calls)
+	require.NoError(t, StopAPIMemo())
+
+	now = now.Add(5 * time.Minute)
+	StartAPIMemo(10 * time.Minute)
+	lookup()
+	assert.Equal(t, 5, calls, "a fresh persisted response is reused")
+	require.NoError(t, StopAPIMemo())
+
+	now = now.Add(10 * time.Minute)
+	StartAPIMemo(10 * time.Minute)
+	lookup()
+	assert.Equal(t, 6, calls, "an expired response is fetched again")
+	require.NoError(t, StopAPIMemo())
+}
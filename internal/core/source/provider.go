@@ -0,0 +1,257 @@
+package source
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nightconcept/almandine/internal/core/archive"
+	"github.com/nightconcept/almandine/internal/core/logger"
+)
+
+// ProviderGitHub is the name of the built-in GitHub provider.
+const ProviderGitHub = "github"
+
+// Provider knows how to interpret and fetch sources from one kind of host. Commands work with
+// ParsedSourceInfo and reach the host only through the provider named in its Provider field, so
+// a new host can be supported by registering another implementation.
+type Provider interface {
+	// Name identifies the provider and is stored in ParsedSourceInfo.Provider.
+	Name() string
+	// Parse interprets sourceURL. It returns handled=false, without an error, when the URL
+	// belongs to a different provider.
+	Parse(sourceURL string) (info *ParsedSourceInfo, handled bool, err error)
+	// ResolveRef returns the commit that last changed the file at the parsed ref.
+	ResolveRef(info *ParsedSourceInfo) (string, error)
+	// RawURL returns the download URL of pathInRepo at the parsed ref.
+	RawURL(info *ParsedSourceInfo, pathInRepo string) string
+	// ListTags returns the tag names of the parsed repository.
+	ListTags(info *ParsedSourceInfo) ([]string, error)
+	// FetchMetadata returns descriptive information about the parsed repository.
+	FetchMetadata(info *ParsedSourceInfo) (*Metadata, error)
+}
+
+// CommitDater is implemented by providers that can tell when a commit was made, which install
+// uses to detect downgrades.
+type CommitDater interface {
+	CommitDate(info *ParsedSourceInfo, sha string) (time.Time, error)
+}
+
+// DirectoryLister is implemented by providers whose sources can name a directory (see
+// ParsedSourceInfo.Dir), which add lists to vendor all its files as one dependency.
+type DirectoryLister interface {
+	ListDirectory(info *ParsedSourceInfo) ([]string, error)
+}
+
+// ErrUpstreamRewritten reports that content pinned to a commit that could be fetched before is
+// gone: the commit, file or repository was deleted, or the branch history was rewritten.
+var ErrUpstreamRewritten = errors.New("upstream history rewritten or deleted")
+
+// Metadata describes the repository a dependency comes from.
+type Metadata struct {
+	Description   string
+	HomepageURL   string
+	DefaultBranch string
+	License       string // SPDX identifier, empty if unknown
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = []Provider{gitlabProvider{}, bitbucketProvider{}, giteaProvider{ProviderGitea}, giteaProvider{ProviderCodeberg}, fileProvider{}, gitProvider{}, gistProvider{}, githubProvider{}, urlProvider{}}
+)
+
+// Register adds p to the provider registry, replacing any provider with the same name, and
+// returns a function that restores the previous registration. Providers registered later are
+// consulted first when parsing, so tests can inject fakes that shadow the built-in ones.
+func Register(p Provider) (restore func()) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	previous := append([]Provider(nil), providers...)
+	updated := []Provider{p}
+	for _, existing := range providers {
+		if existing.Name() != p.Name() {
+			updated = append(updated, existing)
+		}
+	}
+	providers = updated
+
+	return func() {
+		providersMu.Lock()
+		providers = previous
+		providersMu.Unlock()
+	}
+}
+
+// registeredProviders returns a snapshot of the registry in lookup order, led by the project's
+// GitHub Enterprise instances (see SetGitHubEnterpriseHosts).
+func registeredProviders() []Provider {
+	list := enterpriseProviders()
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	return append(list, providers...)
+}
+
+// HasCommits reports whether sources of the named provider live in a git repository, so their
+// refs can be resolved to commits and their raw URLs pinned to one. Every registered provider
+// hosts git repositories except those for plain URLs and local files.
+func HasCommits(providerName string) bool {
+	_, err := LookupProvider(providerName)
+	return err == nil && providerName != ProviderURL && providerName != ProviderFile
+}
+
+// ProviderNames returns the names of all registered providers, sorted.
+func ProviderNames() []string {
+	var names []string
+	for _, p := range registeredProviders() {
+		names = append(names, p.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupProvider returns the registered provider called name.
+func LookupProvider(name string) (Provider, error) {
+	for _, p := range registeredProviders() {
+		if p.Name() == name {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("no source provider named '%s' is registered", name)
+}
+
+// ParseSourceURL analyzes the input source URL string and returns structured information,
+// using the first registered provider that recognizes it.
+func ParseSourceURL(sourceURL string) (*ParsedSourceInfo, error) {
+	u, err := url.Parse(sourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse source URL '%s': %w", sourceURL, err)
+	}
+
+	for _, p := range registeredProviders() {
+		info, handled, err := p.Parse(sourceURL)
+		if handled {
+			return info, err
+		}
+	}
+
+	host := u.Hostname()
+	if host == "" {
+		host = u.Scheme
+	}
+	return nil, fmt.Errorf("unsupported source URL host: %s. Supported providers: %s", host, strings.Join(ProviderNames(), ", "))
+}
+
+// ResolveRef resolves the parsed ref to the commit that last changed the file, using the
+// source's provider.
+func ResolveRef(info *ParsedSourceInfo) (string, error) {
+	p, err := LookupProvider(info.Provider)
+	if err != nil {
+		return "", err
+	}
+	commit, err := p.ResolveRef(info)
+	if err == nil {
+		logger.Debugf("resolved %s/%s@%s to commit %s", info.Owner, info.Repo, info.Ref, commit)
+	}
+	return commit, err
+}
+
+// FetchMetadata returns repository information for the source from its provider.
+func FetchMetadata(info *ParsedSourceInfo) (*Metadata, error) {
+	p, err := LookupProvider(info.Provider)
+	if err != nil {
+		return nil, err
+	}
+	return p.FetchMetadata(info)
+}
+
+// CommitDate returns when commit sha of the source's repository was made, if its provider
+// implements CommitDater.
+func CommitDate(info *ParsedSourceInfo, sha string) (time.Time, error) {
+	p, err := LookupProvider(info.Provider)
+	if err != nil {
+		return time.Time{}, err
+	}
+	dater, ok := p.(CommitDater)
+	if !ok {
+		return time.Time{}, fmt.Errorf("provider '%s' cannot look up commit dates", info.Provider)
+	}
+	return dater.CommitDate(info, sha)
+}
+
+// AtCommit returns a copy of the parsed source pinned to commit sha, with its raw URL rebuilt
+// by the provider. The canonical URL is left unchanged.
+func (p *ParsedSourceInfo) AtCommit(sha string) (*ParsedSourceInfo, error) {
+	provider, err := LookupProvider(p.Provider)
+	if err != nil {
+		return nil, err
+	}
+	if !HasCommits(p.Provider) {
+		return nil, fmt.Errorf("'%s' cannot be pinned to a commit", p.RawURL)
+	}
+	pinned := *p
+	pinned.Ref, pinned.RefType = sha, RefTypeCommit
+	pinned.RawURL = sourceRawURL(provider, &pinned)
+	return &pinned, nil
+}
+
+// sourceRawURL returns the raw URL of the file p names at its ref, or for a directory source the
+// prefix of its files' raw URLs, ending in '/'.
+func sourceRawURL(provider Provider, p *ParsedSourceInfo) string {
+	raw := provider.RawURL(p, p.PathInRepo)
+	if p.Dir {
+		raw += "/"
+	}
+	return raw
+}
+
+// ListDirectory returns the paths of the files below the directory a source names, relative to
+// it and sorted, at the source's ref.
+func ListDirectory(info *ParsedSourceInfo) ([]string, error) {
+	if !info.Dir {
+		return nil, fmt.Errorf("'%s' does not name a directory", info.CanonicalURL)
+	}
+	p, err := LookupProvider(info.Provider)
+	if err != nil {
+		return nil, err
+	}
+	lister, ok := p.(DirectoryLister)
+	if !ok {
+		return nil, fmt.Errorf("provider '%s' does not support directory sources", info.Provider)
+	}
+	return lister.ListDirectory(info)
+}
+
+// ArchiveFormat returns the format of the archive a plain URL or local file source names, whose
+// files add extracts as one dependency, or "" for any other source. An archive stored in a git
+// repository is vendored as the single file it is.
+func (p *ParsedSourceInfo) ArchiveFormat() archive.Format {
+	if p.Dir || HasCommits(p.Provider) {
+		return ""
+	}
+	return archive.FormatOf(p.PathInRepo)
+}
+
+// FileRawURL returns the raw URL of the file at rel, a path relative to the directory the source
+// names, at the source's ref.
+func (p *ParsedSourceInfo) FileRawURL(rel string) string {
+	return p.RepoFileRawURL(path.Join(p.PathInRepo, rel))
+}
+
+// RepoFileRawURL returns the raw content URL of another file in the same repository at the same
+// ref, e.g. the README next to a dependency. It returns "" for sources without owner/repo info.
+func (p *ParsedSourceInfo) RepoFileRawURL(pathInRepo string) string {
+	if p.Owner == "" || p.Repo == "" || p.Ref == "" {
+		return ""
+	}
+	provider, err := LookupProvider(p.Provider)
+	if err != nil {
+		return ""
+	}
+	return provider.RawURL(p, strings.TrimPrefix(pathInRepo, "/"))
+}
@@ -0,0 +1,121 @@
+package source_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+// fakeProvider serves "fake:<repo>/<path>@<ref>" sources without any network access.
+type fakeProvider struct {
+	tags []string
+}
+
+func (fakeProvider) Name() string { return "fake" }
+
+func (fakeProvider) Parse(sourceURL string) (*source.ParsedSourceInfo, bool, error) {
+	rest, ok := strings.CutPrefix(sourceURL, "fake:")
+	if !ok {
+		return nil, false, nil
+	}
+	repoAndPath, ref, found := strings.Cut(rest, "@")
+	repo, path, _ := strings.Cut(repoAndPath, "/")
+	if !found || path == "" {
+		return nil, true, fmt.Errorf("invalid fake source '%s'", sourceURL)
+	}
+	info := &source.ParsedSourceInfo{
+		CanonicalURL:      sourceURL,
+		Ref:               ref,
+		Provider:          "fake",
+		Owner:             "fake",
+		Repo:              repo,
+		PathInRepo:        path,
+		SuggestedFilename: path,
+	}
+	info.RawURL = fakeProvider{}.RawURL(info, path)
+	return info, true, nil
+}
+
+func (fakeProvider) ResolveRef(info *source.ParsedSourceInfo) (string, error) {
+	return "c0ffee" + info.Ref, nil
+}
+
+func (fakeProvider) RawURL(info *source.ParsedSourceInfo, pathInRepo string) string {
+	return fmt.Sprintf("https://fake.example/%s/%s/%s", info.Repo, info.RefSegment(), pathInRepo)
+}
+
+func (f fakeProvider) ListTags(*source.ParsedSourceInfo) ([]string, error) {
+	return f.tags, nil
+}
+
+func (fakeProvider) FetchMetadata(info *source.ParsedSourceInfo) (*source.Metadata, error) {
+	return &source.Metadata{Description: "fake " + info.Repo}, nil
+}
+
+func TestRegister_FakeProvider(t *testing.T) {
+	restore := source.Register(fakeProvider{tags: []string{"v1.0.0", "v1.2.0", "v2.0.0"}})
+
+	assert.Equal(t, []string{"bitbucket", "codeberg", "fake", "file", "gist", "git", "gitea", "github", "gitlab", "url"}, source.ProviderNames())
+
+	info, err := source.ParseSourceURL("fake:lib/init.lua@v1.*")
+	require.NoError(t, err)
+	assert.Equal(t, "fake", info.Provider)
+
+	resolved, err := source.ResolveTagPattern(info)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.2.0", resolved.Ref)
+	assert.Equal(t, "https://fake.example/lib/refs/tags/v1.2.0/init.lua", resolved.RawURL)
+
+	sha, err := source.ResolveRef(resolved)
+	require.NoError(t, err)
+	assert.Equal(t, "c0ffeev1.2.0", sha)
+
+	pinned, err := resolved.AtCommit("abcdef1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://fake.example/lib/abcdef1/init.lua", pinned.RawURL)
+	assert.Equal(t, "fake:lib/init.lua@v1.*", pinned.CanonicalURL, "pinning keeps the canonical source")
+
+	meta, err := source.FetchMetadata(info)
+	require.NoError(t, err)
+	assert.Equal(t, "fake lib", meta.Description)
+
+	restore()
+	assert.Equal(t, []string{"bitbucket", "codeberg", "file", "gist", "git", "gitea", "github", "gitlab", "url"}, source.ProviderNames())
+	_, err = source.ParseSourceURL("fake:lib/init.lua@main")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Supported providers: bitbucket, codeberg, file, gist, git, gitea, github, gitlab, url")
+}
+
+func TestLookupProvider_Unknown(t *testing.T) {
+	_, err := source.LookupProvider("s3")
+	require.Error(t, err)
+	_, err = source.ResolveRef(&source.ParsedSourceInfo{Provider: "s3"})
+	require.Error(t, err)
+	assert.Empty(t, (&source.ParsedSourceInfo{Provider: "s3", Owner: "o", Repo: "r", Ref: "main"}).RepoFileRawURL("README.md"))
+}
+
+func TestGitHubProvider_FetchMetadata(t *testing.T) {
+	githubAPITestMutex.Lock()
+	defer githubAPITestMutex.Unlock()
+
+	_, cleanup := setupSourceTest(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/owner/repo", r.URL.Path)
+		_, _ = w.Write([]byte(`{"description":"A library","html_url":"https://github.com/owner/repo","homepage":"","default_branch":"main","license":{"spdx_id":"MIT"}}`))
+	})
+	defer cleanup()
+
+	meta, err := source.FetchMetadata(&source.ParsedSourceInfo{Provider: source.ProviderGitHub, Owner: "owner", Repo: "repo"})
+	require.NoError(t, err)
+	assert.Equal(t, &source.Metadata{
+		Description:   "A library",
+		HomepageURL:   "https://github.com/owner/repo",
+		DefaultBranch: "main",
+		License:       "MIT",
+	}, meta)
+}
@@ -0,0 +1,158 @@
+package source
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nightconcept/almandine/internal/core/warnings"
+)
+
+// GitHub's secondary rate limits punish bursts of API calls even when the hourly quota is far
+// from used up (900 points per minute for REST calls). Every API call goes through one shared
+// limiter, so parallel workers together stay within a small burst and that steady rate.
+const (
+	APIBurst             = 20 // Calls that may start at once
+	APIRequestsPerSecond = 15 // Sustained rate once the burst is spent
+)
+
+// MaxRateLimitWait is the longest almd waits for a rate limit to lift before failing the call.
+const MaxRateLimitWait = 90 * time.Second
+
+// ErrRateLimited is returned for API calls made while GitHub's hourly quota is exhausted.
+var ErrRateLimited = errors.New("GitHub API rate limit exceeded")
+
+// apiLimiter is a token bucket shared by every GitHub API call, plus a pause that GitHub can
+// impose through Retry-After or an exhausted quota. The hourly quota belongs to a GitHub token,
+// so it is tracked per token ("" for unauthenticated calls) and calls rotate through a pool of
+// tokens (see globalconfig.GitHubTokens), skipping those whose quota is used up.
+type apiLimiter struct {
+	mu          sync.Mutex
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time            // Secondary limit: every call waits until then
+	exhausted   map[string]time.Time // Primary quota per GitHub token: calls fail until then
+	next        int                  // Round-robin position in the token pool
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+var githubLimiter = newAPILimiter()
+
+func newAPILimiter() *apiLimiter {
+	return &apiLimiter{tokens: APIBurst, exhausted: map[string]time.Time{}, now: time.Now, sleep: time.Sleep}
+}
+
+// pick returns the GitHub token the next call should use: the next one in the pool, in turn,
+// whose quota is not exhausted. It returns "" for an empty pool, and an error wrapping
+// ErrRateLimited when every token is exhausted.
+func (l *apiLimiter) pick(pool []string) (string, error) {
+	if len(pool) == 0 {
+		return "", nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	var earliest time.Time
+	for i := range pool {
+		token := pool[(l.next+i)%len(pool)]
+		reset := l.exhausted[token]
+		if !now.Before(reset) {
+			l.next = (l.next + i + 1) % len(pool)
+			return token, nil
+		}
+		if earliest.IsZero() || reset.Before(earliest) {
+			earliest = reset
+		}
+	}
+	if len(pool) == 1 {
+		return "", fmt.Errorf("%w; it resets at %s", ErrRateLimited, earliest.Local().Format(time.Kitchen))
+	}
+	return "", fmt.Errorf("%w for all %d tokens in the pool; the first resets at %s", ErrRateLimited, len(pool), earliest.Local().Format(time.Kitchen))
+}
+
+// wait blocks until a call with token may start. It fails without waiting while the token's
+// hourly quota is exhausted, so parallel workers do not each make a doomed call.
+func (l *apiLimiter) wait(token string) error {
+	for {
+		l.mu.Lock()
+		now := l.now()
+		if reset := l.exhausted[token]; now.Before(reset) {
+			l.mu.Unlock()
+			return fmt.Errorf("%w; it resets at %s", ErrRateLimited, reset.Local().Format(time.Kitchen))
+		}
+		var delay time.Duration
+		if now.Before(l.pausedUntil) {
+			delay = l.pausedUntil.Sub(now)
+		} else {
+			if !l.last.IsZero() {
+				l.tokens = min(APIBurst, l.tokens+now.Sub(l.last).Seconds()*APIRequestsPerSecond)
+			}
+			l.last = now
+			if l.tokens >= 1 {
+				l.tokens--
+				l.mu.Unlock()
+				return nil
+			}
+			delay = time.Duration(math.Ceil((1 - l.tokens) / APIRequestsPerSecond * float64(time.Second)))
+		}
+		l.mu.Unlock()
+		l.sleep(delay)
+	}
+}
+
+// observe inspects the response to a call made with token for rate limiting. It reports whether
+// the call should be retried once the pause it recorded has passed, or an error wrapping
+// ErrRateLimited when the token's quota is exhausted for longer than almd waits.
+func (l *apiLimiter) observe(resp *http.Response, token string) (retry bool, err error) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return false, nil
+	}
+	now := l.now()
+	if seconds, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil {
+		return l.pause(now.Add(time.Duration(seconds)*time.Second), token)
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return false, nil // A plain permission error
+	}
+	reset, convErr := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if convErr != nil {
+		return false, nil
+	}
+	return l.pause(time.Unix(reset, 0), token)
+}
+
+// pause makes every call wait until until. A pause longer than MaxRateLimitWait marks the
+// quota of token as exhausted instead. Only the call that extends the pause reports a warning, so
+// a run with many workers shows one message per pause.
+func (l *apiLimiter) pause(until time.Time, token string) (retry bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if until.Sub(now) > MaxRateLimitWait {
+		l.exhausted[token] = until
+		return false, fmt.Errorf("%w; it resets at %s", ErrRateLimited, until.Local().Format(time.Kitchen))
+	}
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+		if wait := until.Sub(now).Round(time.Second); wait > 0 {
+			warnings.Printf("GitHub asked almd to slow down; pausing API calls for %s.", wait)
+		}
+	}
+	return true, nil
+}
+
+// ResetRateLimit forgets any pause or exhausted quota recorded so far, refills the burst and
+// resolves the token pool again on the next call.
+func ResetRateLimit() {
+	githubLimiter.mu.Lock()
+	defer githubLimiter.mu.Unlock()
+	githubLimiter.tokens, githubLimiter.last = APIBurst, time.Time{}
+	githubLimiter.pausedUntil, githubLimiter.exhausted, githubLimiter.next = time.Time{}, map[string]time.Time{}, 0
+	tokenPool.once, tokenPool.tokens = sync.Once{}, nil
+}
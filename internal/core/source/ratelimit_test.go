@@ -0,0 +1,170 @@
+package source
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/globalconfig"
+	"github.com/nightconcept/almandine/internal/core/paths"
+	"github.com/nightconcept/almandine/internal/core/warnings"
+)
+
+// fakeClockLimiter returns a limiter whose sleeps advance a fake clock.
+func fakeClockLimiter() (*apiLimiter, *[]time.Duration) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var slept []time.Duration
+	l := newAPILimiter()
+	l.now = func() time.Time { return now }
+	l.sleep = func(d time.Duration) {
+		slept = append(slept, d)
+		now = now.Add(d)
+	}
+	return l, &slept
+}
+
+func TestAPILimiter_BurstThenRate(t *testing.T) {
+	l, slept := fakeClockLimiter()
+	for range APIBurst {
+		require.NoError(t, l.wait(""))
+	}
+	assert.Empty(t, *slept, "the burst starts without waiting")
+
+	require.NoError(t, l.wait(""))
+	require.Len(t, *slept, 1)
+	assert.Equal(t, (time.Second / APIRequestsPerSecond).Round(time.Millisecond), (*slept)[0].Round(time.Millisecond))
+}
+
+func TestAPILimiter_RetryAfterPausesEveryCall(t *testing.T) {
+	l, slept := fakeClockLimiter()
+	resp := &http.Response{StatusCode: http.StatusForbidden, Header: http.Header{"Retry-After": {"30"}}}
+
+	warnings.Reset()
+	defer warnings.Reset()
+	retry, err := l.observe(resp, "")
+	require.NoError(t, err)
+	assert.True(t, retry)
+	require.NoError(t, l.wait(""))
+	assert.Equal(t, []time.Duration{30 * time.Second}, *slept)
+	assert.Equal(t, []string{"GitHub asked almd to slow down; pausing API calls for 30s."}, warnings.Reported(), "the pause is reported as a warning")
+
+	forbidden := &http.Response{StatusCode: http.StatusForbidden, Header: http.Header{}}
+	retry, err = l.observe(forbidden, "")
+	require.NoError(t, err)
+	assert.False(t, retry, "a 403 without rate limit headers is a permission error")
+}
+
+func TestAPILimiter_ExhaustedQuota(t *testing.T) {
+	l, slept := fakeClockLimiter()
+	reset := l.now().Add(time.Hour).Unix()
+	resp := &http.Response{StatusCode: http.StatusForbidden, Header: http.Header{
+		"X-Ratelimit-Remaining": {"0"},
+		"X-Ratelimit-Reset":     {strconv.FormatInt(reset, 10)},
+	}}
+
+	retry, err := l.observe(resp, "")
+	require.ErrorIs(t, err, ErrRateLimited)
+	assert.False(t, retry)
+	assert.ErrorIs(t, l.wait(""), ErrRateLimited, "later calls fail without reaching GitHub")
+	assert.Empty(t, *slept)
+}
+
+func TestGithubAPIGet_RetriesAfterSecondaryLimit(t *testing.T) {
+	ResetRateLimit()
+	defer ResetRateLimit()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	body, err := githubAPIGet(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(body))
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestAPILimiter_PickRotatesAndSkipsExhaustedTokens(t *testing.T) {
+	l, _ := fakeClockLimiter()
+	pool := []string{"a", "b", "c"}
+	var picked []string
+	for range 4 {
+		token, err := l.pick(pool)
+		require.NoError(t, err)
+		picked = append(picked, token)
+	}
+	assert.Equal(t, []string{"a", "b", "c", "a"}, picked)
+
+	exhausted := &http.Response{StatusCode: http.StatusForbidden, Header: http.Header{
+		"X-Ratelimit-Remaining": {"0"},
+		"X-Ratelimit-Reset":     {strconv.FormatInt(l.now().Add(time.Hour).Unix(), 10)},
+	}}
+	_, err := l.observe(exhausted, "b")
+	require.ErrorIs(t, err, ErrRateLimited)
+	for range 2 {
+		token, err := l.pick(pool)
+		require.NoError(t, err)
+		assert.NotEqual(t, "b", token, "an exhausted token is skipped")
+	}
+	require.NoError(t, l.wait("a"), "other tokens keep their own quota")
+
+	_, _ = l.observe(exhausted, "a")
+	_, _ = l.observe(exhausted, "c")
+	_, err = l.pick(pool)
+	require.ErrorIs(t, err, ErrRateLimited)
+	assert.Contains(t, err.Error(), "all 3 tokens")
+}
+
+func TestGithubAPIGet_SwitchesTokenOnExhaustedQuota(t *testing.T) {
+	ResetRateLimit()
+	defer ResetRateLimit()
+	t.Setenv(paths.ConfigDirEnv, t.TempDir())
+	t.Setenv(globalconfig.GitHubTokenEnv, "first")
+	t.Setenv(globalconfig.GitHubTokensEnv, "second, first")
+
+	var auth []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") == "Bearer first" {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	for range 2 {
+		body, err := githubAPIGet(server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, "ok", string(body))
+	}
+	assert.Equal(t, []string{"Bearer first", "Bearer second", "Bearer second"}, auth, "the exhausted token is not tried again")
+}
+
+func TestGithubTokenPool_ResolvedOncePerRun(t *testing.T) {
+	ResetRateLimit()
+	defer ResetRateLimit()
+	t.Setenv(paths.ConfigDirEnv, t.TempDir())
+	t.Setenv(globalconfig.GitHubTokenEnv, "")
+	t.Setenv(globalconfig.GitHubTokensEnv, "")
+
+	assert.Empty(t, githubTokenPool())
+	t.Setenv(globalconfig.GitHubTokensEnv, "later")
+	assert.Empty(t, githubTokenPool(), "an empty pool is not looked up again")
+
+	ResetRateLimit()
+	assert.Equal(t, []string{"later"}, githubTokenPool())
+}
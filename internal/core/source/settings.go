@@ -0,0 +1,40 @@
+package source
+
+import (
+	"strings"
+	"sync"
+)
+
+// ProviderSettings overrides a provider's defaults for the current project ([providers.<name>]
+// in project.toml).
+type ProviderSettings struct {
+	APIURL  string // API endpoint used instead of the provider's default
+	BaseURL string // Site of a self-hosted instance, for providers that support one
+}
+
+var (
+	settingsMu       sync.RWMutex
+	providerSettings map[string]ProviderSettings
+)
+
+// SetProviderSettings replaces the per-provider overrides, keyed by provider name; nil restores
+// the defaults.
+func SetProviderSettings(settings map[string]ProviderSettings) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	providerSettings = settings
+}
+
+// providerAPIURL returns the API endpoint configured for the provider, or "" for its default.
+func providerAPIURL(name string) string {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return strings.TrimSuffix(providerSettings[name].APIURL, "/")
+}
+
+// providerBaseURL returns the instance URL configured for the provider, or "" for its default.
+func providerBaseURL(name string) string {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return strings.TrimSuffix(providerSettings[name].BaseURL, "/")
+}
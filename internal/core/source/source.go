@@ -20,50 +20,136 @@ func SetTestModeBypassHostValidation(enable bool) {
 	TestModeBypassHostValidationMutex.Unlock()
 }
 
+// Ref qualifiers that may prefix a ref (e.g. "@tag:v1.2.3") to make its kind explicit.
+// An unqualified ref leaves RefType empty and is resolved however the host resolves it.
+const (
+	RefTypeTag    = "tag"
+	RefTypeBranch = "branch"
+	RefTypeCommit = "commit"
+)
+
 // ParsedSourceInfo holds the details extracted from a source URL.
 type ParsedSourceInfo struct {
 	RawURL            string
 	CanonicalURL      string
 	Ref               string
+	RefType           string // One of the RefType* constants, or empty if the ref was not qualified
 	Provider          string
 	Owner             string
 	Repo              string
 	PathInRepo        string
 	SuggestedFilename string
+	// Dir is set when the source names a directory, whose files then form one dependency (see
+	// ListDirectory). Its canonical source and raw URL end in '/'; the raw URL is the prefix of
+	// its files' raw URLs.
+	Dir bool
+}
+
+// RefSegment returns the ref as it appears in raw content URLs and API queries.
+// Qualified tags and branches are expanded to their full ref names (refs/tags/..., refs/heads/...)
+// so that a tag and a branch sharing a name cannot be confused.
+func (p *ParsedSourceInfo) RefSegment() string {
+	return refSegment(p.RefType, p.Ref)
+}
+
+// QualifiedRef returns the ref including its qualifier (e.g. "tag:v1.2.3"), as stored in canonical sources.
+func (p *ParsedSourceInfo) QualifiedRef() string {
+	return qualifyRef(p.RefType, p.Ref)
 }
 
-// ParseSourceURL analyzes the input source URL string and returns structured information.
-// It currently prioritizes GitHub URLs.
-func ParseSourceURL(sourceURL string) (*ParsedSourceInfo, error) {
-	if strings.HasPrefix(sourceURL, "github:") {
-		return parseGitHubShorthandURL(sourceURL)
+func refSegment(refType, ref string) string {
+	switch refType {
+	case RefTypeTag:
+		return "refs/tags/" + ref
+	case RefTypeBranch:
+		return "refs/heads/" + ref
+	default:
+		return ref
 	}
+}
 
-	u, err := url.Parse(sourceURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse source URL '%s': %w", sourceURL, err)
+func qualifyRef(refType, ref string) string {
+	if refType == "" {
+		return ref
 	}
+	return refType + ":" + ref
+}
 
-	TestModeBypassHostValidationMutex.Lock()
-	currentTestModeBypass := testModeBypassHostValidation
-	TestModeBypassHostValidationMutex.Unlock()
+// splitRefQualifier separates an optional "tag:", "branch:" or "commit:" qualifier from a ref.
+// Git ref names cannot contain ':', so any other prefix before a colon is rejected.
+func splitRefQualifier(ref string) (refType, name string, err error) {
+	idx := strings.Index(ref, ":")
+	if idx == -1 {
+		return "", ref, nil
+	}
+	refType, name = ref[:idx], ref[idx+1:]
+	switch refType {
+	case RefTypeTag:
+	case RefTypeBranch:
+		if isTagPattern(name) {
+			return "", "", fmt.Errorf("ref '%s' is a pattern, but patterns can only match tags", ref)
+		}
+	case RefTypeCommit:
+		if !isHexCommitSHA(name) {
+			return "", "", fmt.Errorf("ref '%s' is qualified as a commit but '%s' is not a 7-40 character hex SHA", ref, name)
+		}
+	default:
+		return "", "", fmt.Errorf("unknown ref qualifier '%s' in '%s' (expected tag:, branch: or commit:)", refType, ref)
+	}
+	if name == "" {
+		return "", "", fmt.Errorf("ref '%s' has an empty name after its qualifier", ref)
+	}
+	return refType, name, nil
+}
 
-	if currentTestModeBypass {
-		// If test mode bypass is active, attempt to parse it as a test mode URL.
-		// This function will error if the path doesn't match the expected test structure.
-		return parseTestModeURL(u)
+// isHexCommitSHA reports whether s looks like an abbreviated or full git commit SHA.
+func isHexCommitSHA(s string) bool {
+	if len(s) < 7 || len(s) > 40 {
+		return false
+	}
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
 	}
+	return true
+}
 
-	// Standard URL parsing
-	hostname := strings.ToLower(u.Hostname())
-	switch hostname {
-	case "raw.githubusercontent.com":
-		return parseRawGitHubUserContentURL(u)
-	case "github.com":
-		return parseGitHubFullURL(u)
-	default:
-		return nil, fmt.Errorf("unsupported source URL host: %s. Only GitHub URLs are currently supported", u.Hostname())
+// splitRawRefPath splits the path parts following owner/repo in a raw content URL into the ref and the
+// file path. Raw URLs may spell out a full ref name (refs/heads/<branch> or refs/tags/<tag>), which is
+// mapped back to a qualified ref.
+func splitRawRefPath(parts []string) (refType, ref string, rest []string) {
+	if len(parts) >= 3 && parts[0] == "refs" {
+		switch parts[1] {
+		case "heads":
+			return RefTypeBranch, parts[2], parts[3:]
+		case "tags":
+			return RefTypeTag, parts[2], parts[3:]
+		}
+	}
+	if len(parts) == 0 {
+		return "", "", nil
+	}
+	return "", parts[0], parts[1:]
+}
+
+// splitShorthand separates a "<scheme>:<repository and path>@<ref>" source into its
+// percent-decoded repository and path part and its possibly qualified ref.
+func splitShorthand(sourceURL, scheme string) (repoAndPath, refType, ref string, err error) {
+	content := strings.TrimPrefix(sourceURL, scheme+":")
+	lastAt := strings.LastIndex(content, "@")
+	if lastAt == -1 || lastAt == len(content)-1 {
+		return "", "", "", fmt.Errorf("invalid %s shorthand source '%s': missing @ref (e.g., @main or @commitsha)", scheme, sourceURL)
+	}
+	refType, ref, err = splitRefQualifier(content[lastAt+1:])
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid %s shorthand source '%s': %w", scheme, sourceURL, err)
 	}
+	repoAndPath, err = url.PathUnescape(content[:lastAt])
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid %s shorthand source '%s': bad percent-encoding (write a literal %% as %%25)", scheme, sourceURL)
+	}
+	return repoAndPath, refType, ref, nil
 }
 
 // parseGitHubShorthandURL handles URLs like "github:owner/repo/path/to/file@ref"
@@ -78,13 +164,24 @@ func parseGitHubShorthandURL(sourceURL string) (*ParsedSourceInfo, error) {
 		return nil, fmt.Errorf("invalid github shorthand source '%s': ref part is empty after @", sourceURL)
 	}
 
-	repoAndPathPart := content[:lastAt]
-	ref := content[lastAt+1:]
+	repoAndPathPart, dir := strings.CutSuffix(content[:lastAt], "/")
+	refType, ref, err := splitRefQualifier(content[lastAt+1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid github shorthand source '%s': %w", sourceURL, err)
+	}
 
 	pathComponents := strings.Split(repoAndPathPart, "/")
 	if len(pathComponents) < 3 {
 		return nil, fmt.Errorf("invalid github shorthand source '%s': expected format owner/repo/path/to/file, got '%s'", sourceURL, repoAndPathPart)
 	}
+	// The path may be percent-encoded (e.g. "my%20file.lua"), as in a copied URL.
+	for i := 2; i < len(pathComponents); i++ {
+		decoded, unescapeErr := url.PathUnescape(pathComponents[i])
+		if unescapeErr != nil {
+			return nil, fmt.Errorf("invalid github shorthand source '%s': bad percent-encoding in '%s' (write a literal %% as %%25)", sourceURL, pathComponents[i])
+		}
+		pathComponents[i] = decoded
+	}
 
 	owner := pathComponents[0]
 	repo := pathComponents[1]
@@ -95,45 +192,99 @@ func parseGitHubShorthandURL(sourceURL string) (*ParsedSourceInfo, error) {
 		return nil, fmt.Errorf("invalid github shorthand source '%s': owner, repo, or path/filename cannot be empty", sourceURL)
 	}
 
-	var rawURL string
+	info := &ParsedSourceInfo{
+		CanonicalURL:      canonicalSource(owner, repo, pathInRepo, qualifyRef(refType, ref)),
+		Ref:               ref,
+		RefType:           refType,
+		Provider:          ProviderGitHub,
+		Owner:             owner,
+		Repo:              repo,
+		PathInRepo:        pathInRepo,
+		SuggestedFilename: suggestedFilename,
+	}
+
+	info.RawURL = githubRawURL(owner, repo, info.RefSegment(), pathInRepo)
+	if dir {
+		info.asDirectory()
+	}
+	return info, nil
+}
+
+// asDirectory marks the GitHub source p as naming a directory (see ParsedSourceInfo.Dir).
+func (p *ParsedSourceInfo) asDirectory() {
+	p.Dir = true
+	p.CanonicalURL = canonicalSource(p.Owner, p.Repo, p.PathInRepo+"/", p.QualifiedRef())
+	raw, query, hasQuery := strings.Cut(p.RawURL, "?")
+	p.RawURL = raw + "/"
+	if hasQuery {
+		p.RawURL += "?" + query
+	}
+}
+
+// githubRawURL builds the raw content URL for a file at the given ref segment.
+// In test mode the mock server configured as GithubAPIBaseURL stands in for raw.githubusercontent.com.
+func githubRawURL(owner, repo, refSegment, pathInRepo string) string {
 	TestModeBypassHostValidationMutex.Lock()
 	currentTestModeBypassLocal := testModeBypassHostValidation // Use a local var to avoid holding lock too long
 	TestModeBypassHostValidationMutex.Unlock()
 
 	if currentTestModeBypassLocal {
-		GithubAPIBaseURLMutex.Lock()
-		currentGithubAPIBaseURL := GithubAPIBaseURL
-		GithubAPIBaseURLMutex.Unlock()
-		rawURL = fmt.Sprintf("%s/%s/%s/%s/%s", currentGithubAPIBaseURL, owner, repo, ref, pathInRepo)
-	} else {
-		rawURL = fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s/%s", owner, repo, ref, pathInRepo)
+		return fmt.Sprintf("%s/%s/%s/%s/%s", githubAPIBaseURL(), owner, repo, escapePath(refSegment), escapePath(pathInRepo))
 	}
+	return rawGitHubUserContentURL(owner, repo, refSegment, pathInRepo)
+}
 
-	return &ParsedSourceInfo{
-		RawURL:            rawURL,
-		CanonicalURL:      sourceURL, // For shorthand, the sourceURL is the canonical form
-		Ref:               ref,
-		Provider:          "github",
-		Owner:             owner,
-		Repo:              repo,
-		PathInRepo:        pathInRepo,
-		SuggestedFilename: suggestedFilename,
-	}, nil
+// rawGitHubUserContentURL builds a raw.githubusercontent.com URL. The ref and path are
+// percent-encoded per segment, so spaces, '#', '?' and non-ASCII names survive and every
+// spelling of a source maps to the same URL (and lockfile entry).
+func rawGitHubUserContentURL(owner, repo, refSegment, pathInRepo string) string {
+	return fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s/%s", owner, repo, escapePath(refSegment), escapePath(pathInRepo))
+}
+
+// escapePath percent-encodes each '/'-separated segment of p.
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalSource builds the "github:owner/repo/path@ref" form of a parsed URL. A literal '%'
+// in the path is encoded so that parsing the shorthand yields the same path again.
+func canonicalSource(owner, repo, pathInRepo, qualifiedRef string) string {
+	return fmt.Sprintf("github:%s/%s/%s@%s", owner, repo, strings.ReplaceAll(pathInRepo, "%", "%25"), qualifiedRef)
+}
+
+// pathSegments splits the path of u into its percent-decoded segments. A slash encoded as %2F
+// stays within its segment, so a ref such as "release/1.x" can be written as one segment
+// ("release%2F1.x") in blob and raw URLs.
+func pathSegments(u *url.URL) []string {
+	segments := strings.Split(strings.Trim(u.EscapedPath(), "/"), "/")
+	for i, segment := range segments {
+		if decoded, err := url.PathUnescape(segment); err == nil {
+			segments[i] = decoded
+		}
+	}
+	return segments
 }
 
 // parseTestModeURL handles generic URLs when testModeBypassHostValidation is true,
 // attempting to parse them with a GitHub-like raw content path structure.
 func parseTestModeURL(u *url.URL) (*ParsedSourceInfo, error) {
 	// Path structure expected: /<owner>/<repo>/<ref>/<path_to_file...>
-	pathParts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	pathParts := pathSegments(u)
 	if len(pathParts) < 4 {
 		return nil, fmt.Errorf("test mode URL path '%s' not in expected format /<owner>/<repo>/<ref>/<file...> PpathParts was: %v", u.Path, pathParts)
 	}
 
 	owner := pathParts[0]
 	repo := pathParts[1]
-	ref := pathParts[2]
-	filePathInRepo := strings.Join(pathParts[3:], "/")
+	refType, ref, rest := splitRawRefPath(pathParts[2:])
+	if len(rest) == 0 {
+		return nil, fmt.Errorf("test mode URL path '%s' not in expected format /<owner>/<repo>/<ref>/<file...> PpathParts was: %v", u.Path, pathParts)
+	}
+	filePathInRepo := strings.Join(rest, "/")
 	filename := pathParts[len(pathParts)-1]
 
 	if filename == "" && filePathInRepo == "" {
@@ -145,9 +296,10 @@ func parseTestModeURL(u *url.URL) (*ParsedSourceInfo, error) {
 
 	return &ParsedSourceInfo{
 		RawURL:            u.String(), // The original URL is the raw URL in this test mode context
-		CanonicalURL:      fmt.Sprintf("github:%s/%s/%s@%s", owner, repo, filePathInRepo, ref),
+		CanonicalURL:      canonicalSource(owner, repo, filePathInRepo, qualifyRef(refType, ref)),
 		Ref:               ref,
-		Provider:          "github", // Assumed GitHub provider in test mode parsing
+		RefType:           refType,
+		Provider:          ProviderGitHub, // Assumed GitHub provider in test mode parsing
 		Owner:             owner,
 		Repo:              repo,
 		PathInRepo:        filePathInRepo,
@@ -157,26 +309,31 @@ func parseTestModeURL(u *url.URL) (*ParsedSourceInfo, error) {
 
 // parseRawGitHubUserContentURL handles URLs from "raw.githubusercontent.com".
 func parseRawGitHubUserContentURL(u *url.URL) (*ParsedSourceInfo, error) {
-	pathParts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	pathParts := pathSegments(u)
 	if len(pathParts) < 4 {
 		return nil, fmt.Errorf("invalid GitHub raw content URL path: %s. Expected format: /<owner>/<repo>/<ref>/<path_to_file>", u.Path)
 	}
 	owner := pathParts[0]
 	repo := pathParts[1]
-	ref := pathParts[2]
-	filePathInRepo := strings.Join(pathParts[3:], "/")
+	refType, ref, rest := splitRawRefPath(pathParts[2:])
+	filePathInRepo := strings.Join(rest, "/")
 	filename := pathParts[len(pathParts)-1]
 
 	if owner == "" || repo == "" || ref == "" || filePathInRepo == "" || filename == "" {
 		return nil, fmt.Errorf("invalid GitHub raw content URL '%s': one or more components (owner, repo, ref, path, filename) are empty", u.String())
 	}
 
-	canonicalURL := fmt.Sprintf("github:%s/%s/%s@%s", owner, repo, filePathInRepo, ref)
+	canonicalURL := canonicalSource(owner, repo, filePathInRepo, qualifyRef(refType, ref))
+	rawURL := rawGitHubUserContentURL(owner, repo, refSegment(refType, ref), filePathInRepo)
+	if u.RawQuery != "" {
+		rawURL += "?" + u.RawQuery // e.g. the token of a private repository's raw link
+	}
 	return &ParsedSourceInfo{
-		RawURL:            u.String(),
+		RawURL:            rawURL,
 		CanonicalURL:      canonicalURL,
 		Ref:               ref,
-		Provider:          "github",
+		RefType:           refType,
+		Provider:          ProviderGitHub,
 		Owner:             owner,
 		Repo:              repo,
 		PathInRepo:        filePathInRepo,
@@ -186,23 +343,25 @@ func parseRawGitHubUserContentURL(u *url.URL) (*ParsedSourceInfo, error) {
 
 // parseGitHubFullURL handles standard "github.com" URLs (blob, tree, raw, or path with @ref).
 func parseGitHubFullURL(u *url.URL) (*ParsedSourceInfo, error) {
-	pathParts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	pathParts := pathSegments(u)
 	if len(pathParts) < 2 {
 		return nil, fmt.Errorf("invalid GitHub URL path: %s. Expected at least /<owner>/<repo>", u.Path)
 	}
 
 	owner := pathParts[0]
 	repo := pathParts[1]
-	var ref, filePathInRepo, rawURL, filename string
+	var ref, refType, filePathInRepo, rawURL, filename string
 	var err error
+	isTree := false // A tree URL names a directory
 
 	if len(pathParts) >= 4 && (pathParts[2] == "blob" || pathParts[2] == "tree" || pathParts[2] == "raw") {
 		ref, filePathInRepo, filename, rawURL, err = parseGitHubURLWithType(u, owner, repo, pathParts)
 		if err != nil {
 			return nil, err
 		}
+		isTree = pathParts[2] == "tree"
 	} else {
-		ref, filePathInRepo, filename, rawURL, err = parseGitHubURLWithAtRef(u, owner, repo, pathParts)
+		ref, refType, filePathInRepo, filename, rawURL, err = parseGitHubURLWithAtRef(u, owner, repo, pathParts)
 		if err != nil {
 			return nil, err
 		}
@@ -228,18 +387,23 @@ func parseGitHubFullURL(u *url.URL) (*ParsedSourceInfo, error) {
 		return nil, fmt.Errorf("raw download URL could not be constructed for URL: %s", u.String())
 	}
 
-	canonicalURL := fmt.Sprintf("github:%s/%s/%s@%s", owner, repo, filePathInRepo, ref)
+	canonicalURL := canonicalSource(owner, repo, filePathInRepo, qualifyRef(refType, ref))
 
-	return &ParsedSourceInfo{
+	info := &ParsedSourceInfo{
 		RawURL:            rawURL,
 		CanonicalURL:      canonicalURL,
 		Ref:               ref,
-		Provider:          "github",
+		RefType:           refType,
+		Provider:          ProviderGitHub,
 		Owner:             owner,
 		Repo:              repo,
 		PathInRepo:        filePathInRepo,
 		SuggestedFilename: filename,
-	}, nil
+	}
+	if isTree {
+		info.asDirectory()
+	}
+	return info, nil
 }
 
 // parseGitHubURLWithType handles URLs like /<owner>/<repo>/<type>/<ref>/<path_to_file>
@@ -253,20 +417,17 @@ func parseGitHubURLWithType(u *url.URL, owner, repo string, pathParts []string)
 	filePathInRepo = strings.Join(pathParts[4:], "/")
 	filename = pathParts[len(pathParts)-1]
 
-	if refType == "tree" {
-		err = fmt.Errorf("direct links to GitHub trees are not supported for adding single files: %s", u.String())
-		return
-	}
 	if owner == "" || repo == "" || ref == "" || filePathInRepo == "" || filename == "" {
 		err = fmt.Errorf("invalid GitHub '%s' URL '%s': one or more components (owner, repo, ref, path, filename) are empty", refType, u.String())
 		return
 	}
-	rawURL = fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s/%s", owner, repo, ref, filePathInRepo)
+	rawURL = rawGitHubUserContentURL(owner, repo, ref, filePathInRepo)
 	return
 }
 
 // parseGitHubURLWithAtRef handles URLs like /<owner>/<repo>/<path_to_file>@<ref>
-func parseGitHubURLWithAtRef(u *url.URL, owner, repo string, pathParts []string) (ref, filePathInRepo, filename, rawURL string, err error) {
+// The ref may carry a tag:, branch: or commit: qualifier.
+func parseGitHubURLWithAtRef(u *url.URL, owner, repo string, pathParts []string) (ref, refType, filePathInRepo, filename, rawURL string, err error) {
 	if len(pathParts) < 3 { // Need at least owner/repo/fileish@ref
 		err = fmt.Errorf("ambiguous GitHub URL path: %s. Expected /owner/repo/path@ref or a full /blob/ or /raw/ URL", u.Path)
 		return
@@ -276,7 +437,11 @@ func parseGitHubURLWithAtRef(u *url.URL, owner, repo string, pathParts []string)
 
 	if atSymbolIndex != -1 && atSymbolIndex < len(potentialPathWithRef)-1 && atSymbolIndex > 0 {
 		filePathInRepo = potentialPathWithRef[:atSymbolIndex]
-		ref = potentialPathWithRef[atSymbolIndex+1:]
+		refType, ref, err = splitRefQualifier(potentialPathWithRef[atSymbolIndex+1:])
+		if err != nil {
+			err = fmt.Errorf("invalid GitHub URL '%s': %w", u.String(), err)
+			return
+		}
 		pathElements := strings.Split(filePathInRepo, "/")
 		if len(pathElements) > 0 {
 			filename = pathElements[len(pathElements)-1]
@@ -293,6 +458,6 @@ func parseGitHubURLWithAtRef(u *url.URL, owner, repo string, pathParts []string)
 		err = fmt.Errorf("invalid GitHub URL with '@ref' syntax '%s': one or more components (owner, repo, ref, path, filename) are empty", u.String())
 		return
 	}
-	rawURL = fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s/%s", owner, repo, ref, filePathInRepo)
+	rawURL = rawGitHubUserContentURL(owner, repo, refSegment(refType, ref), filePathInRepo)
 	return
 }
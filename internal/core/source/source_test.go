@@ -217,10 +217,19 @@ func TestParseSourceURL_FullGitHubURLs(t *testing.T) {
 			},
 		},
 		{
-			name:        "github.com tree url",
-			url:         "https://github.com/owner/repo/tree/main/path/to/dir",
-			wantErr:     true,
-			errContains: "direct links to GitHub trees are not supported",
+			name: "github.com tree url",
+			url:  "https://github.com/owner/repo/tree/main/path/to/dir",
+			want: &source.ParsedSourceInfo{
+				RawURL:            "https://raw.githubusercontent.com/owner/repo/main/path/to/dir/",
+				CanonicalURL:      "github:owner/repo/path/to/dir/@main",
+				Ref:               "main",
+				Provider:          "github",
+				Owner:             "owner",
+				Repo:              "repo",
+				PathInRepo:        "path/to/dir",
+				SuggestedFilename: "dir",
+				Dir:               true,
+			},
 		},
 		{
 			name:        "invalid raw.githubusercontent.com url short path",
@@ -345,16 +354,16 @@ func TestParseSourceURL_NonGitHubURLs(t *testing.T) {
 		errContains string
 	}{
 		{
-			name:        "unsupported http url",
-			url:         "http://example.com/somefile.txt",
+			name:        "unsupported scheme",
+			url:         "ftp://example.com/somefile.txt",
 			wantErr:     true,
 			errContains: "unsupported source URL host: example.com",
 		},
 		{
-			name:        "unsupported gitlab url",
-			url:         "https://gitlab.com/user/project/raw/main/file.lua",
+			name:        "plain URL naming a directory",
+			url:         "https://git.sr.ht/~user/project/",
 			wantErr:     true,
-			errContains: "unsupported source URL host: gitlab.com",
+			errContains: "does not name a file",
 		},
 		{
 			name:        "invalid url format",
@@ -375,3 +384,171 @@ func TestParseSourceURL_NonGitHubURLs(t *testing.T) {
 		})
 	}
 }
+
+func TestParseSourceURL_RefQualifiers(t *testing.T) {
+	sourceTestMutex.Lock()
+	defer sourceTestMutex.Unlock()
+
+	tests := []struct {
+		name        string
+		url         string
+		want        *source.ParsedSourceInfo
+		wantErr     bool
+		errContains string
+	}{
+		{
+			name: "shorthand tag qualifier",
+			url:  "github:owner/repo/lib/file.lua@tag:v1.2.3",
+			want: &source.ParsedSourceInfo{
+				RawURL:            "https://raw.githubusercontent.com/owner/repo/refs/tags/v1.2.3/lib/file.lua",
+				CanonicalURL:      "github:owner/repo/lib/file.lua@tag:v1.2.3",
+				Ref:               "v1.2.3",
+				RefType:           source.RefTypeTag,
+				Provider:          "github",
+				Owner:             "owner",
+				Repo:              "repo",
+				PathInRepo:        "lib/file.lua",
+				SuggestedFilename: "file.lua",
+			},
+		},
+		{
+			name: "shorthand branch qualifier",
+			url:  "github:owner/repo/file.lua@branch:dev",
+			want: &source.ParsedSourceInfo{
+				RawURL:            "https://raw.githubusercontent.com/owner/repo/refs/heads/dev/file.lua",
+				CanonicalURL:      "github:owner/repo/file.lua@branch:dev",
+				Ref:               "dev",
+				RefType:           source.RefTypeBranch,
+				Provider:          "github",
+				Owner:             "owner",
+				Repo:              "repo",
+				PathInRepo:        "file.lua",
+				SuggestedFilename: "file.lua",
+			},
+		},
+		{
+			name: "shorthand commit qualifier",
+			url:  "github:owner/repo/file.lua@commit:abc1234",
+			want: &source.ParsedSourceInfo{
+				RawURL:            "https://raw.githubusercontent.com/owner/repo/abc1234/file.lua",
+				CanonicalURL:      "github:owner/repo/file.lua@commit:abc1234",
+				Ref:               "abc1234",
+				RefType:           source.RefTypeCommit,
+				Provider:          "github",
+				Owner:             "owner",
+				Repo:              "repo",
+				PathInRepo:        "file.lua",
+				SuggestedFilename: "file.lua",
+			},
+		},
+		{
+			name: "github.com url with qualified @ref",
+			url:  "https://github.com/owner/repo/file.lua@tag:v2",
+			want: &source.ParsedSourceInfo{
+				RawURL:            "https://raw.githubusercontent.com/owner/repo/refs/tags/v2/file.lua",
+				CanonicalURL:      "github:owner/repo/file.lua@tag:v2",
+				Ref:               "v2",
+				RefType:           source.RefTypeTag,
+				Provider:          "github",
+				Owner:             "owner",
+				Repo:              "repo",
+				PathInRepo:        "file.lua",
+				SuggestedFilename: "file.lua",
+			},
+		},
+		{
+			name: "raw url with full tag ref name",
+			url:  "https://raw.githubusercontent.com/owner/repo/refs/tags/v2/src/file.lua",
+			want: &source.ParsedSourceInfo{
+				RawURL:            "https://raw.githubusercontent.com/owner/repo/refs/tags/v2/src/file.lua",
+				CanonicalURL:      "github:owner/repo/src/file.lua@tag:v2",
+				Ref:               "v2",
+				RefType:           source.RefTypeTag,
+				Provider:          "github",
+				Owner:             "owner",
+				Repo:              "repo",
+				PathInRepo:        "src/file.lua",
+				SuggestedFilename: "file.lua",
+			},
+		},
+		{
+			name:        "commit qualifier with non-hex sha",
+			url:         "github:owner/repo/file.lua@commit:main",
+			wantErr:     true,
+			errContains: "not a 7-40 character hex SHA",
+		},
+		{
+			name:        "unknown qualifier",
+			url:         "github:owner/repo/file.lua@release:v1",
+			wantErr:     true,
+			errContains: "unknown ref qualifier 'release'",
+		},
+		{
+			name:        "empty name after qualifier",
+			url:         "github:owner/repo/file.lua@tag:",
+			wantErr:     true,
+			errContains: "empty name after its qualifier",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture range variable
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := source.ParseSourceURL(tt.url)
+			if tt.wantErr {
+				require.Error(t, err)
+				if tt.errContains != "" {
+					assert.Contains(t, err.Error(), tt.errContains)
+				}
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseSourceURL_EncodedAndUnicodePaths(t *testing.T) {
+	sourceTestMutex.Lock()
+	defer sourceTestMutex.Unlock()
+
+	const raw = "https://raw.githubusercontent.com/owner/repo/main/my%20lib/c++%23%C3%BCn%C3%AF.lua"
+	for _, sourceURL := range []string{
+		"github:owner/repo/my lib/c++#ünï.lua@main",
+		"github:owner/repo/my%20lib/c++%23%C3%BCn%C3%AF.lua@main",
+		"https://github.com/owner/repo/blob/main/my%20lib/c++%23ünï.lua",
+		"https://github.com/owner/repo/my%20lib/c++%23%C3%BCn%C3%AF.lua@main",
+		raw,
+		"https://raw.githubusercontent.com/owner/repo/main/my lib/c%2B%2B%23ünï.lua",
+	} {
+		t.Run(sourceURL, func(t *testing.T) {
+			got, err := source.ParseSourceURL(sourceURL)
+			require.NoError(t, err)
+			assert.Equal(t, "my lib/c++#ünï.lua", got.PathInRepo)
+			assert.Equal(t, "c++#ünï.lua", got.SuggestedFilename)
+			assert.Equal(t, raw, got.RawURL, "every spelling maps to one raw URL and lockfile entry")
+			assert.Equal(t, "github:owner/repo/my lib/c++#ünï.lua@main", got.CanonicalURL, "every spelling maps to one canonical source")
+
+			again, err := source.ParseSourceURL(got.CanonicalURL)
+			require.NoError(t, err)
+			assert.Equal(t, got.PathInRepo, again.PathInRepo, "the canonical source parses back to the same path")
+		})
+	}
+
+	got, err := source.ParseSourceURL("https://github.com/owner/repo/blob/main/100%25.lua")
+	require.NoError(t, err)
+	assert.Equal(t, "100%.lua", got.PathInRepo)
+	assert.Equal(t, "github:owner/repo/100%25.lua@main", got.CanonicalURL)
+
+	_, err = source.ParseSourceURL("github:owner/repo/100%.lua@main")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bad percent-encoding")
+}
+
+func TestRepoFileRawURL(t *testing.T) {
+	info, err := source.ParseSourceURL("github:owner/repo/src/lib.lua@tag:v1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "https://raw.githubusercontent.com/owner/repo/refs/tags/v1.0.0/README.md", info.RepoFileRawURL("README.md"))
+
+	assert.Equal(t, "", (&source.ParsedSourceInfo{Provider: "gitlab"}).RepoFileRawURL("README.md"))
+}
@@ -0,0 +1,234 @@
+package source
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+
+	"github.com/nightconcept/almandine/internal/core/logger"
+)
+
+// isTagPattern reports whether a ref contains glob metacharacters and must be matched
+// against the repository's tag list (e.g. "v1.*") rather than used directly. Semantic version
+// ranges such as "^1.2" or ">=1.0 <2" are patterns too.
+func isTagPattern(ref string) bool {
+	return strings.ContainsAny(ref, "*?[") || isVersionRange(ref)
+}
+
+// isVersionRange reports whether ref is a semantic version range: it starts with a comparison
+// operator, '^' or '~', or combines ranges with "||", and parses as a constraint. Git ref names
+// cannot contain '^', '~', '<', '>' or spaces, so no branch or tag is taken for a range.
+func isVersionRange(ref string) bool {
+	if !strings.ContainsAny(ref[:min(len(ref), 1)], "^~<>=!") && !strings.Contains(ref, "||") {
+		return false
+	}
+	_, err := semver.NewConstraint(ref)
+	return err == nil
+}
+
+// IsTagPattern reports whether the parsed ref is a tag glob such as "v1.*", or a version range
+// such as "^1.2", that has to be resolved to a concrete tag before anything can be downloaded.
+func (p *ParsedSourceInfo) IsTagPattern() bool {
+	return (p.RefType == "" || p.RefType == RefTypeTag) && isTagPattern(p.Ref)
+}
+
+// tagMatcher returns a function reporting whether a tag matches pattern, a glob or a version
+// range. Tags match a range when they parse as semantic versions, with or without a "v" prefix,
+// that satisfy it; pre-releases only match ranges that name a pre-release.
+func tagMatcher(pattern string) (func(tag string) bool, error) {
+	if isVersionRange(pattern) {
+		constraint, _ := semver.NewConstraint(pattern) // Parsed by isVersionRange
+		return func(tag string) bool {
+			v, err := semver.NewVersion(tag)
+			return err == nil && constraint.Check(v)
+		}, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid tag pattern '%s': %w", pattern, err)
+	}
+	return func(tag string) bool {
+		ok, _ := path.Match(pattern, tag)
+		return ok
+	}, nil
+}
+
+// HighestMatchingTag returns the highest tag in tags matching the glob pattern or version range.
+// Tags that parse as semantic versions are ordered by version and rank above tags that do not;
+// the remaining tags are ordered with a natural (digit-aware) comparison so that "build-10"
+// sorts after "build-9".
+func HighestMatchingTag(tags []string, pattern string) (string, error) {
+	matches, err := tagMatcher(pattern)
+	if err != nil {
+		return "", err
+	}
+
+	best := ""
+	found := false
+	for _, tag := range tags {
+		if !matches(tag) {
+			continue
+		}
+		if !found || CompareTags(tag, best) > 0 {
+			best = tag
+			found = true
+		}
+	}
+	if !found {
+		return "", fmt.Errorf("no tags match pattern '%s'", pattern)
+	}
+	return best, nil
+}
+
+// CompareTags orders two tag names the way HighestMatchingTag ranks them, returning -1, 0 or 1.
+func CompareTags(a, b string) int {
+	va, errA := semver.NewVersion(a)
+	vb, errB := semver.NewVersion(b)
+	switch {
+	case errA == nil && errB == nil:
+		if c := va.Compare(vb); c != 0 {
+			return c
+		}
+		return naturalCompare(a, b)
+	case errA == nil:
+		return 1
+	case errB == nil:
+		return -1
+	default:
+		return naturalCompare(a, b)
+	}
+}
+
+// naturalCompare compares strings treating runs of digits as numbers.
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		da, restA := leadingDigits(a)
+		db, restB := leadingDigits(b)
+		if da != "" && db != "" {
+			na, nb := strings.TrimLeft(da, "0"), strings.TrimLeft(db, "0")
+			if len(na) != len(nb) {
+				return compareInts(len(na), len(nb))
+			}
+			if c := strings.Compare(na, nb); c != 0 {
+				return c
+			}
+			a, b = restA, restB
+			continue
+		}
+		if a[0] != b[0] {
+			return compareInts(int(a[0]), int(b[0]))
+		}
+		a, b = a[1:], b[1:]
+	}
+	return compareInts(len(a), len(b))
+}
+
+func leadingDigits(s string) (digits, rest string) {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// ResolveTagPattern resolves a tag-pattern ref against the repository's tags and returns a copy of
+// the parsed source pinned to the highest matching tag. The canonical URL keeps the pattern so the
+// manifest continues to express the constraint; only the ref and raw URL become concrete.
+func ResolveTagPattern(p *ParsedSourceInfo) (*ParsedSourceInfo, error) {
+	if !p.IsTagPattern() {
+		return p, nil
+	}
+	provider, err := LookupProvider(p.Provider)
+	if err != nil {
+		return nil, err
+	}
+	names, err := provider.ListTags(p)
+	if err != nil {
+		return nil, fmt.Errorf("listing tags for %s/%s: %w", p.Owner, p.Repo, err)
+	}
+
+	tag, err := HighestMatchingTag(names, p.Ref)
+	if err != nil {
+		return nil, fmt.Errorf("resolving '%s' in %s/%s: %w", p.Ref, p.Owner, p.Repo, err)
+	}
+
+	logger.Debugf("tag pattern '%s' in %s/%s matched %s", p.Ref, p.Owner, p.Repo, tag)
+	resolved := *p
+	resolved.Ref = tag
+	resolved.RefType = RefTypeTag
+	resolved.RawURL = sourceRawURL(provider, &resolved)
+	return &resolved, nil
+}
+
+// maxNearestTags bounds how many neighbouring tags TagAlternatives suggests on each side.
+const maxNearestTags = 2
+
+// TagAlternatives looks for replacements for a tag ref that could not be resolved, e.g. because
+// it was deleted or renamed upstream. same is an existing tag naming the same version ("1.2.0"
+// or "release-1.2.0" for a missing "v1.2.0"), or "" if there is none; nearest lists the tags
+// ranked just below and above the missing one. missing is false when the tag still exists, in
+// which case resolution failed for another reason and no alternatives are returned.
+func TagAlternatives(p *ParsedSourceInfo) (same string, nearest []string, missing bool, err error) {
+	provider, err := LookupProvider(p.Provider)
+	if err != nil {
+		return "", nil, false, err
+	}
+	tags, err := provider.ListTags(p)
+	if err != nil {
+		return "", nil, false, fmt.Errorf("listing tags for %s/%s: %w", p.Owner, p.Repo, err)
+	}
+	for _, tag := range tags {
+		if tag == p.Ref {
+			return "", nil, false, nil
+		}
+	}
+
+	if want, ok := tagVersion(p.Ref); ok {
+		for _, tag := range tags {
+			if v, ok := tagVersion(tag); ok && v.Equal(want) && (same == "" || CompareTags(tag, same) > 0) {
+				same = tag
+			}
+		}
+	}
+
+	sorted := append([]string(nil), tags...)
+	sort.Slice(sorted, func(i, j int) bool { return CompareTags(sorted[i], sorted[j]) < 0 })
+	at := sort.Search(len(sorted), func(i int) bool { return CompareTags(sorted[i], p.Ref) > 0 })
+	nearest = append(nearest, sorted[max(0, at-maxNearestTags):at]...)
+	nearest = append(nearest, sorted[at:min(len(sorted), at+maxNearestTags)]...)
+	return same, nearest, true, nil
+}
+
+// IsVersionTag reports whether ref names a version, such as "v1.2.0" or "release-1.2", and so is
+// most likely a tag rather than a branch.
+func IsVersionTag(ref string) bool {
+	_, ok := tagVersion(ref)
+	return ok
+}
+
+// tagVersion parses the version in a tag name, ignoring a "v" or separated prefix such as
+// "release-" before its first digit.
+func tagVersion(tag string) (*semver.Version, bool) {
+	i := strings.IndexAny(tag, "0123456789")
+	if i < 0 {
+		return nil, false
+	}
+	if prefix := tag[:i]; prefix != "" && !strings.EqualFold(prefix, "v") && !strings.ContainsAny(prefix[len(prefix)-1:], "-_/.") {
+		return nil, false
+	}
+	v, err := semver.NewVersion(tag[i:])
+	return v, err == nil
+}
@@ -0,0 +1,186 @@
+package source_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+func TestHighestMatchingTag(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    []string
+		pattern string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "semver ordering beats lexical ordering",
+			tags:    []string{"v1.2.0", "v1.10.0", "v1.9.3", "v2.0.0"},
+			pattern: "v1.*",
+			want:    "v1.10.0",
+		},
+		{
+			name:    "release beats prerelease",
+			tags:    []string{"v1.3.0-rc.1", "v1.3.0", "v1.2.9"},
+			pattern: "v1.*",
+			want:    "v1.3.0",
+		},
+		{
+			name:    "semver tags rank above non-semver tags",
+			tags:    []string{"v1.0.0", "v1-latest"},
+			pattern: "v1*",
+			want:    "v1.0.0",
+		},
+		{
+			name:    "natural ordering for non-semver tags",
+			tags:    []string{"build-9", "build-10", "build-2"},
+			pattern: "build-*",
+			want:    "build-10",
+		},
+		{
+			name:    "caret range",
+			tags:    []string{"v1.1.0", "v1.2.0", "v1.9.3", "v2.0.0", "latest"},
+			pattern: "^1.2",
+			want:    "v1.9.3",
+		},
+		{
+			name:    "range excludes prereleases and allows tags without v",
+			tags:    []string{"1.4.0", "1.5.0-beta.1", "v2.1.0"},
+			pattern: ">=1.0 <2",
+			want:    "1.4.0",
+		},
+		{
+			name:    "range without match",
+			tags:    []string{"v1.0.0", "v3.0.0"},
+			pattern: "~2.1",
+			wantErr: "no tags match pattern '~2.1'",
+		},
+		{
+			name:    "no match",
+			tags:    []string{"v1.0.0"},
+			pattern: "v2.*",
+			wantErr: "no tags match pattern 'v2.*'",
+		},
+		{
+			name:    "malformed pattern",
+			tags:    []string{"v1.0.0"},
+			pattern: "v[1",
+			wantErr: "invalid tag pattern",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := source.HighestMatchingTag(tt.tags, tt.pattern)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseSourceURL_TagPatterns(t *testing.T) {
+	sourceTestMutex.Lock()
+	defer sourceTestMutex.Unlock()
+
+	info, err := source.ParseSourceURL("github:owner/repo/lib/file.lua@v1.*")
+	require.NoError(t, err)
+	assert.True(t, info.IsTagPattern())
+
+	info, err = source.ParseSourceURL("github:owner/repo/lib/file.lua@tag:v1.?")
+	require.NoError(t, err)
+	assert.True(t, info.IsTagPattern())
+
+	info, err = source.ParseSourceURL("github:owner/repo/lib/file.lua@^1.2")
+	require.NoError(t, err)
+	assert.True(t, info.IsTagPattern())
+	assert.Equal(t, "github:owner/repo/lib/file.lua@^1.2", info.CanonicalURL)
+
+	info, err = source.ParseSourceURL("github:owner/repo/lib/file.lua@v1.2.0")
+	require.NoError(t, err)
+	assert.False(t, info.IsTagPattern())
+
+	_, err = source.ParseSourceURL("github:owner/repo/lib/file.lua@branch:release/*")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "patterns can only match tags")
+}
+
+func TestResolveTagPattern(t *testing.T) {
+	sourceTestMutex.Lock()
+	defer sourceTestMutex.Unlock()
+
+	serverURL, cleanup := setupSourceTest(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/owner/repo/tags", r.URL.Path)
+		var body []source.GitHubTagInfo
+		if r.URL.Query().Get("page") == "1" {
+			body = []source.GitHubTagInfo{{Name: "v1.0.0"}, {Name: "v1.4.2"}, {Name: "v2.0.0"}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(body)
+	})
+	defer cleanup()
+
+	info, err := source.ParseSourceURL("github:owner/repo/lib/file.lua@v1.*")
+	require.NoError(t, err)
+
+	resolved, err := source.ResolveTagPattern(info)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.4.2", resolved.Ref)
+	assert.Equal(t, source.RefTypeTag, resolved.RefType)
+	assert.Equal(t, serverURL+"/owner/repo/refs/tags/v1.4.2/lib/file.lua", resolved.RawURL)
+	assert.Equal(t, info.CanonicalURL, resolved.CanonicalURL, "canonical URL should keep the pattern")
+	assert.Equal(t, "v1.*", info.Ref, "original info should not be modified")
+
+	info, err = source.ParseSourceURL("github:owner/repo/lib/file.lua@~1.0")
+	require.NoError(t, err)
+	resolved, err = source.ResolveTagPattern(info)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.0.0", resolved.Ref)
+}
+
+func TestTagAlternatives(t *testing.T) {
+	sourceTestMutex.Lock()
+	defer sourceTestMutex.Unlock()
+
+	_, cleanup := setupSourceTest(t, func(w http.ResponseWriter, r *http.Request) {
+		var body []source.GitHubTagInfo
+		if r.URL.Query().Get("page") == "1" {
+			body = []source.GitHubTagInfo{{Name: "v1.0.0"}, {Name: "v1.1.0"}, {Name: "1.2.0"}, {Name: "v1.3.0"}, {Name: "v2.0.0"}, {Name: "v2.1.0"}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(body)
+	})
+	defer cleanup()
+
+	info, err := source.ParseSourceURL("github:owner/repo/lib/file.lua@v1.2.0")
+	require.NoError(t, err)
+	same, nearest, missing, err := source.TagAlternatives(info)
+	require.NoError(t, err)
+	assert.True(t, missing)
+	assert.Equal(t, "1.2.0", same, "a tag with the same version but another prefix is a replacement")
+	assert.Equal(t, []string{"v1.1.0", "1.2.0", "v1.3.0", "v2.0.0"}, nearest)
+
+	info, err = source.ParseSourceURL("github:owner/repo/lib/file.lua@v1.5.0")
+	require.NoError(t, err)
+	same, nearest, missing, err = source.TagAlternatives(info)
+	require.NoError(t, err)
+	assert.True(t, missing)
+	assert.Empty(t, same)
+	assert.Equal(t, []string{"1.2.0", "v1.3.0", "v2.0.0", "v2.1.0"}, nearest)
+
+	info, err = source.ParseSourceURL("github:owner/repo/lib/file.lua@v2.0.0")
+	require.NoError(t, err)
+	_, _, missing, err = source.TagAlternatives(info)
+	require.NoError(t, err)
+	assert.False(t, missing, "an existing tag has no alternatives")
+}
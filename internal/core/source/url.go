@@ -0,0 +1,58 @@
+package source
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ProviderURL is the name of the provider for plain http(s) URLs on hosts no other provider
+// recognizes. Such sources are not in a repository the provider can query: they have no ref,
+// cannot be pinned to a commit and are locked by the SHA256 of their content.
+const ProviderURL = "url"
+
+// urlProvider is the built-in Provider of last resort, registered after all others so it only
+// sees URLs they leave unhandled.
+type urlProvider struct{}
+
+func (urlProvider) Name() string { return ProviderURL }
+
+func (urlProvider) Parse(sourceURL string) (*ParsedSourceInfo, bool, error) {
+	u, err := url.Parse(sourceURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, false, nil
+	}
+	filename := path.Base(u.Path)
+	if filename == "." || filename == "/" || strings.HasSuffix(u.Path, "/") {
+		return nil, true, fmt.Errorf("URL '%s' does not name a file", sourceURL)
+	}
+	return &ParsedSourceInfo{
+		RawURL:            sourceURL,
+		CanonicalURL:      sourceURL,
+		Provider:          ProviderURL,
+		PathInRepo:        strings.TrimPrefix(u.Path, "/"),
+		SuggestedFilename: filename,
+	}, true, nil
+}
+
+func (urlProvider) ResolveRef(info *ParsedSourceInfo) (string, error) {
+	return "", fmt.Errorf("'%s' is a plain URL and has no commits to resolve", info.RawURL)
+}
+
+// RawURL resolves pathInRepo against the directory of the source URL.
+func (urlProvider) RawURL(info *ParsedSourceInfo, pathInRepo string) string {
+	base, err := url.Parse(info.RawURL)
+	if err != nil {
+		return ""
+	}
+	return base.ResolveReference(&url.URL{Path: pathInRepo}).String()
+}
+
+func (urlProvider) ListTags(info *ParsedSourceInfo) ([]string, error) {
+	return nil, fmt.Errorf("'%s' is a plain URL and has no tags", info.RawURL)
+}
+
+func (urlProvider) FetchMetadata(info *ParsedSourceInfo) (*Metadata, error) {
+	return nil, fmt.Errorf("'%s' is a plain URL and has no repository metadata", info.RawURL)
+}
@@ -0,0 +1,39 @@
+package source_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+func TestParseSourceURL_PlainURL(t *testing.T) {
+	sourceTestMutex.Lock()
+	defer sourceTestMutex.Unlock()
+
+	info, err := source.ParseSourceURL("https://example.com/any/file.lua?v=2")
+	require.NoError(t, err)
+	assert.Equal(t, &source.ParsedSourceInfo{
+		RawURL:            "https://example.com/any/file.lua?v=2",
+		CanonicalURL:      "https://example.com/any/file.lua?v=2",
+		Provider:          source.ProviderURL,
+		PathInRepo:        "any/file.lua",
+		SuggestedFilename: "file.lua",
+	}, info)
+	assert.False(t, source.HasCommits(info.Provider))
+	assert.Empty(t, info.RepoFileRawURL("README.md"), "a plain URL is not in a repository")
+
+	_, err = info.AtCommit("abcdef1234567890abcdef1234567890abcdef12")
+	assert.ErrorContains(t, err, "cannot be pinned to a commit")
+	_, err = source.ResolveRef(info)
+	assert.Error(t, err)
+	_, err = source.FetchMetadata(info)
+	assert.Error(t, err)
+
+	// Hosts with a provider of their own are not plain URLs.
+	info, err = source.ParseSourceURL("https://github.com/owner/repo/blob/main/lib.lua")
+	require.NoError(t, err)
+	assert.Equal(t, source.ProviderGitHub, info.Provider)
+}
@@ -0,0 +1,199 @@
+// Package staging holds the temporary files almd writes before moving them into place. Each
+// file is written in full to .almd/tmp in the project and then renamed over its destination, so
+// an interrupted run never leaves a truncated dependency file behind. Temporary files are named
+// after a prefix of their content's hash plus the writing process. Where a file cannot be
+// staged, it is written to a hidden sibling of its destination instead (see SiblingName). The
+// files an interrupted run left behind are removed the next time a command that writes
+// dependency files runs in the project (see CleanProject).
+package staging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nightconcept/almandine/internal/core/config"
+)
+
+// Dir is where temporary files are written, relative to the project root.
+const Dir = ".almd/tmp"
+
+// suffix marks the temporary files Clean may remove.
+const suffix = ".tmp"
+
+// orphanAge is how old a temporary file must be before Clean removes it. Files are written in
+// one go and renamed right away, so anything older belongs to a run that was interrupted.
+const orphanAge = 10 * time.Minute
+
+// ignoreFile keeps the directory out of version control.
+const ignoreFile = ".gitignore"
+
+// siblingPattern matches the names SiblingName creates: ".<name>.almd-<pid>-<nanoseconds>".
+var siblingPattern = regexp.MustCompile(`^\..+\.almd-\d+-\d+$`)
+
+// Write writes content to a new temporary file in projectRoot's staging directory, created
+// with mode (subject to the umask), and returns its path.
+func Write(projectRoot string, content []byte, mode os.FileMode) (string, error) {
+	dir := filepath.Join(projectRoot, Dir)
+	if err := ensureDir(dir); err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(content)
+	prefix := hex.EncodeToString(digest[:8])
+
+	var tmp *os.File
+	var err error
+	for attempt := 0; attempt < 10; attempt++ {
+		name := fmt.Sprintf("%s-%d-%d%s", prefix, os.Getpid(), time.Now().UnixNano()+int64(attempt), suffix)
+		tmp, err = os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+		if !errors.Is(err, fs.ErrExist) {
+			break
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// Mkdir creates projectRoot's staging directory, ignored by version control, and returns its
+// path. Files other than temporary ones kept there are left alone by Clean.
+func Mkdir(projectRoot string) (string, error) {
+	dir := filepath.Join(projectRoot, Dir)
+	return dir, ensureDir(dir)
+}
+
+// ensureDir creates the staging directory with a .gitignore that ignores everything in it.
+func ensureDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating %s: %w", Dir, err)
+	}
+	ignore := filepath.Join(dir, ignoreFile)
+	if _, err := os.Stat(ignore); errors.Is(err, fs.ErrNotExist) {
+		_ = os.WriteFile(ignore, []byte("*\n"), 0644)
+	}
+	return nil
+}
+
+// Clean removes the temporary files interrupted runs left in projectRoot's staging directory
+// and returns how many it removed. A project without the directory has nothing to clean.
+func Clean(projectRoot string) (int, error) {
+	dir := filepath.Join(projectRoot, Dir)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return removeOrphans(dir, entries, func(name string) bool { return strings.HasSuffix(name, suffix) }), nil
+}
+
+// removeOrphans removes the files in dir whose names match and that are older than orphanAge,
+// and returns how many it removed.
+func removeOrphans(dir string, entries []fs.DirEntry, match func(name string) bool) int {
+	removed := 0
+	cutoff := time.Now().Add(-orphanAge)
+	for _, entry := range entries {
+		if entry.IsDir() || !match(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue // Possibly still being written by another almd process
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err == nil {
+			removed++
+		}
+	}
+	return removed
+}
+
+// SiblingName returns the name of a hidden temporary file next to path, for writes that cannot
+// be staged. attempt makes the name unique when an earlier one was taken.
+func SiblingName(path string, attempt int) string {
+	dir, base := filepath.Split(path)
+	return filepath.Join(dir, fmt.Sprintf(".%s.almd-%d-%d", base, os.Getpid(), time.Now().UnixNano()+int64(attempt)))
+}
+
+// CleanSiblings removes the temporary files interrupted runs left next to their destinations in
+// dir (see SiblingName) and returns how many it removed.
+func CleanSiblings(dir string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return removeOrphans(dir, entries, siblingPattern.MatchString), nil
+}
+
+// CleanProject removes what interrupted runs left in the project at dir: temporary files in the
+// staging directory of dir and of any other root its dependencies are staged under (see
+// ProjectRoot), and hidden siblings next to its dependency files. It returns how many files it
+// removed. Problems are ignored; the files are only clutter.
+func CleanProject(dir string) int {
+	removed, _ := Clean(dir)
+	proj, err := config.LoadProjectToml(dir)
+	if err != nil {
+		return removed
+	}
+	roots := map[string]bool{}
+	if abs, err := filepath.Abs(dir); err == nil {
+		roots[abs] = true
+	}
+	siblingDirs := map[string]bool{}
+	for _, dep := range proj.Dependencies {
+		if dep.Path == "" {
+			continue
+		}
+		path := filepath.Join(dir, filepath.FromSlash(dep.Path))
+		if root, ok := ProjectRoot(path); ok && !roots[root] {
+			roots[root] = true
+			n, _ := Clean(root)
+			removed += n
+		}
+		if parent := filepath.Dir(path); !siblingDirs[parent] {
+			siblingDirs[parent] = true
+			n, _ := CleanSiblings(parent)
+			removed += n
+		}
+	}
+	return removed
+}
+
+// ProjectRoot returns the nearest directory at or above path's directory that contains a
+// project.toml, so files written anywhere in a project are staged in that project.
+func ProjectRoot(path string) (string, bool) {
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return "", false
+	}
+	for {
+		if config.HasManifest(dir) {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
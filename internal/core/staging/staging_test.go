@@ -0,0 +1,91 @@
+package staging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	root := t.TempDir()
+	a, err := Write(root, []byte("content"), 0644)
+	require.NoError(t, err)
+	b, err := Write(root, []byte("content"), 0644)
+	require.NoError(t, err)
+
+	assert.Equal(t, filepath.Join(root, Dir), filepath.Dir(a))
+	assert.NotEqual(t, a, b, "every write gets its own file")
+	assert.Equal(t, strings.SplitN(filepath.Base(a), "-", 2)[0], strings.SplitN(filepath.Base(b), "-", 2)[0],
+		"names start with the content hash")
+	data, err := os.ReadFile(a)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(data))
+
+	ignore, err := os.ReadFile(filepath.Join(root, Dir, ".gitignore"))
+	require.NoError(t, err)
+	assert.Equal(t, "*\n", string(ignore))
+}
+
+func TestClean(t *testing.T) {
+	root := t.TempDir()
+	removed, err := Clean(root)
+	require.NoError(t, err)
+	assert.Zero(t, removed, "a project without a staging directory has nothing to clean")
+
+	orphan, err := Write(root, []byte("left behind"), 0644)
+	require.NoError(t, err)
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(orphan, old, old))
+	recent, err := Write(root, []byte("in progress"), 0644)
+	require.NoError(t, err)
+
+	removed, err = Clean(root)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.NoFileExists(t, orphan)
+	assert.FileExists(t, recent, "files another run may still be writing are kept")
+	assert.FileExists(t, filepath.Join(root, Dir, ".gitignore"))
+}
+
+func TestProjectRoot(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "project.toml"), nil, 0644))
+
+	got, ok := ProjectRoot(filepath.Join(root, "src", "lib", "dep.lua"))
+	require.True(t, ok)
+	assert.Equal(t, root, got)
+
+	_, ok = ProjectRoot(filepath.Join(t.TempDir(), "dep.lua"))
+	assert.False(t, ok)
+}
+
+func TestCleanProject(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "project.toml"),
+		[]byte("[dependencies]\nlib = { source = \"s\", path = \"libs/lib.lua\" }\n"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "libs"), 0755))
+	old := time.Now().Add(-time.Hour)
+
+	staged, err := Write(root, []byte("left behind"), 0644)
+	require.NoError(t, err)
+	require.NoError(t, os.Chtimes(staged, old, old))
+	sibling := SiblingName(filepath.Join(root, "libs", "lib.lua"), 0)
+	require.NoError(t, os.WriteFile(sibling, []byte("left behind"), 0644))
+	require.NoError(t, os.Chtimes(sibling, old, old))
+	recent := SiblingName(filepath.Join(root, "libs", "lib.lua"), 1)
+	require.NoError(t, os.WriteFile(recent, []byte("in progress"), 0644))
+	unrelated := filepath.Join(root, "libs", ".lib.lua.swp")
+	require.NoError(t, os.WriteFile(unrelated, nil, 0644))
+	require.NoError(t, os.Chtimes(unrelated, old, old))
+
+	assert.Equal(t, 2, CleanProject(root))
+	assert.NoFileExists(t, staged)
+	assert.NoFileExists(t, sibling)
+	assert.FileExists(t, recent, "files another run may still be writing are kept")
+	assert.FileExists(t, unrelated, "only almd's own temporary files are removed")
+}
@@ -0,0 +1,271 @@
+// Package theme maps the elements almd colors in its output to terminal colors. A theme is a
+// preset, optionally adjusted per element from the global configuration:
+//
+//	theme = "high-contrast"
+//
+//	[colors]
+//	"dep.hash" = "red bold"
+//
+// A color spec is a space separated list of color names ("red", "hiblack", ...) and attributes
+// ("bold", "underline", ...). An empty spec or "none" prints the element without styling.
+//
+// In plain mode (see SetPlain) nothing is styled, and commands drop decorations such as glyphs and
+// rules in favor of simple line-oriented text for screen readers and dumb terminals.
+package theme
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// Elements that can be styled.
+const (
+	ProjectName    = "project.name"    // Project name in 'list'
+	ProjectVersion = "project.version" // Project version in 'list'
+	ProjectPath    = "project.path"    // Project path in 'list'
+	Header         = "header"          // Section heading in 'list'
+	Section        = "section"         // "dependencies:" heading after 'add' and 'remove'
+	DepName        = "dep.name"        // Dependency name
+	DepHash        = "dep.hash"        // Locked hash or version
+	DepPath        = "dep.path"        // Path of a dependency file
+	Text           = "text"            // Plain values such as sizes
+	Summary        = "summary"         // Counts such as "Packages: +1"
+	Added          = "added"           // Added dependencies
+	Removed        = "removed"         // Removed dependencies
+	OK             = "ok"              // Successful checks
+	Warning        = "warning"         // Checks that did not complete
+	Problem        = "problem"         // Failed checks
+	Muted          = "muted"           // Secondary details
+	FreshCurrent   = "fresh.current"   // Up-to-date glyph in 'list --outdated'
+	FreshBehind    = "fresh.behind"    // Behind glyph in 'list --outdated'
+	FreshAhead     = "fresh.ahead"     // Ahead glyph in 'list --outdated'
+	FreshOther     = "fresh.other"     // Pinned and unknown glyphs in 'list --outdated'
+)
+
+// Names of the built-in presets.
+const (
+	Default      = "default"
+	HighContrast = "high-contrast"
+	Monochrome   = "monochrome"
+)
+
+// presets hold a spec for every element. High-contrast avoids dim grey and uses bright, bold
+// colors; monochrome uses attributes only.
+var presets = map[string]map[string]string{
+	Default: {
+		ProjectName:    "magenta bold underline",
+		ProjectVersion: "magenta",
+		ProjectPath:    "hiblack bold underline",
+		Header:         "cyan bold",
+		Section:        "white bold",
+		DepName:        "white",
+		DepHash:        "yellow",
+		DepPath:        "hiblack",
+		Text:           "white",
+		Summary:        "white",
+		Added:          "green",
+		Removed:        "red",
+		OK:             "green",
+		Warning:        "yellow",
+		Problem:        "red",
+		Muted:          "hiblack",
+		FreshCurrent:   "green",
+		FreshBehind:    "yellow bold",
+		FreshAhead:     "cyan",
+		FreshOther:     "hiblack",
+	},
+	HighContrast: {
+		ProjectName:    "himagenta bold underline",
+		ProjectVersion: "himagenta",
+		ProjectPath:    "hiwhite underline",
+		Header:         "hicyan bold",
+		Section:        "hiwhite bold",
+		DepName:        "hiwhite bold",
+		DepHash:        "hiyellow",
+		DepPath:        "hiwhite",
+		Text:           "hiwhite",
+		Summary:        "hiwhite bold",
+		Added:          "higreen bold",
+		Removed:        "hired bold",
+		OK:             "higreen bold",
+		Warning:        "hiyellow bold",
+		Problem:        "hired bold",
+		Muted:          "white",
+		FreshCurrent:   "higreen bold",
+		FreshBehind:    "hiyellow bold",
+		FreshAhead:     "hicyan bold",
+		FreshOther:     "hiwhite",
+	},
+	Monochrome: {
+		ProjectName:    "bold underline",
+		ProjectVersion: "none",
+		ProjectPath:    "underline",
+		Header:         "bold",
+		Section:        "bold",
+		DepName:        "none",
+		DepHash:        "none",
+		DepPath:        "none",
+		Text:           "none",
+		Summary:        "none",
+		Added:          "none",
+		Removed:        "none",
+		OK:             "none",
+		Warning:        "bold",
+		Problem:        "bold",
+		Muted:          "none",
+		FreshCurrent:   "none",
+		FreshBehind:    "bold",
+		FreshAhead:     "none",
+		FreshOther:     "none",
+	},
+}
+
+var attributes = map[string]color.Attribute{
+	"black":     color.FgBlack,
+	"red":       color.FgRed,
+	"green":     color.FgGreen,
+	"yellow":    color.FgYellow,
+	"blue":      color.FgBlue,
+	"magenta":   color.FgMagenta,
+	"cyan":      color.FgCyan,
+	"white":     color.FgWhite,
+	"hiblack":   color.FgHiBlack,
+	"hired":     color.FgHiRed,
+	"higreen":   color.FgHiGreen,
+	"hiyellow":  color.FgHiYellow,
+	"hiblue":    color.FgHiBlue,
+	"himagenta": color.FgHiMagenta,
+	"hicyan":    color.FgHiCyan,
+	"hiwhite":   color.FgHiWhite,
+	"bold":      color.Bold,
+	"faint":     color.Faint,
+	"italic":    color.Italic,
+	"underline": color.Underline,
+	"reverse":   color.ReverseVideo,
+}
+
+var (
+	mu      sync.RWMutex
+	current = presets[Default]
+	plain   bool
+)
+
+// SetPlain turns plain output on or off. Turning it on also disables color for every writer.
+func SetPlain(on bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	plain = on
+	if on {
+		color.NoColor = true
+	}
+}
+
+// Plain reports whether plain output is on.
+func Plain() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return plain
+}
+
+// Names returns the preset names in sorted order.
+func Names() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Elements returns the names of the elements that can be styled, in sorted order.
+func Elements() []string {
+	elements := make([]string, 0, len(presets[Default]))
+	for element := range presets[Default] {
+		elements = append(elements, element)
+	}
+	sort.Strings(elements)
+	return elements
+}
+
+// Validate checks that preset names a built-in theme (the empty string selects the default) and
+// that every override names a known element with a valid spec.
+func Validate(preset string, overrides map[string]string) error {
+	_, err := resolve(preset, overrides)
+	return err
+}
+
+// Use makes preset, adjusted by overrides, the theme used by New. On error the current theme is
+// left unchanged.
+func Use(preset string, overrides map[string]string) error {
+	specs, err := resolve(preset, overrides)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	current = specs
+	return nil
+}
+
+// New returns the color for element in the current theme. Unknown elements, and every element in
+// plain mode, are not styled. Otherwise whether escape codes are printed is decided by
+// color.NoColor.
+func New(element string) *color.Color {
+	mu.RLock()
+	spec, isPlain := current[element], plain
+	mu.RUnlock()
+	attrs, _ := parseSpec(spec)
+	c := color.New(attrs...)
+	if len(attrs) == 0 || isPlain {
+		c.DisableColor() // Print no escape sequences, not even empty ones
+	}
+	return c
+}
+
+// SprintFunc returns a function that styles its arguments as element.
+func SprintFunc(element string) func(a ...interface{}) string {
+	return New(element).SprintFunc()
+}
+
+func resolve(preset string, overrides map[string]string) (map[string]string, error) {
+	if preset == "" {
+		preset = Default
+	}
+	base, ok := presets[preset]
+	if !ok {
+		return nil, fmt.Errorf("theme must be one of %s, got '%s'", strings.Join(Names(), ", "), preset)
+	}
+	specs := make(map[string]string, len(base))
+	for element, spec := range base {
+		specs[element] = spec
+	}
+	for element, spec := range overrides {
+		if _, known := base[element]; !known {
+			return nil, fmt.Errorf("unknown color element '%s' (known: %s)", element, strings.Join(Elements(), ", "))
+		}
+		if _, err := parseSpec(spec); err != nil {
+			return nil, fmt.Errorf("color for '%s': %w", element, err)
+		}
+		specs[element] = spec
+	}
+	return specs, nil
+}
+
+func parseSpec(spec string) ([]color.Attribute, error) {
+	var attrs []color.Attribute
+	for _, word := range strings.Fields(strings.ToLower(spec)) {
+		if word == "none" {
+			continue
+		}
+		attr, ok := attributes[word]
+		if !ok {
+			return nil, fmt.Errorf("unknown color or attribute '%s'", word)
+		}
+		attrs = append(attrs, attr)
+	}
+	return attrs, nil
+}
@@ -0,0 +1,91 @@
+package theme
+
+import (
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useTheme(t *testing.T, preset string, overrides map[string]string) {
+	t.Helper()
+	noColor := color.NoColor
+	color.NoColor = false
+	t.Cleanup(func() {
+		color.NoColor = noColor
+		require.NoError(t, Use(Default, nil))
+	})
+	require.NoError(t, Use(preset, overrides))
+}
+
+func TestPresetsCoverEveryElement(t *testing.T) {
+	for _, name := range Names() {
+		assert.ElementsMatch(t, Elements(), keys(presets[name]), name)
+		for element, spec := range presets[name] {
+			_, err := parseSpec(spec)
+			assert.NoError(t, err, "%s: %s", name, element)
+		}
+	}
+}
+
+func TestNew_DefaultAndOverrides(t *testing.T) {
+	useTheme(t, "", nil)
+	assert.Equal(t, color.New(color.FgYellow).Sprint("x"), New(DepHash).Sprint("x"))
+
+	useTheme(t, Default, map[string]string{DepHash: "Red Bold"})
+	assert.Equal(t, color.New(color.FgRed, color.Bold).Sprint("x"), New(DepHash).Sprint("x"))
+	assert.Equal(t, color.New(color.FgWhite).Sprint("x"), New(DepName).Sprint("x"), "other elements keep the preset")
+}
+
+func TestNew_Monochrome(t *testing.T) {
+	useTheme(t, Monochrome, nil)
+	assert.Equal(t, "x", New(DepHash).Sprint("x"))
+	assert.Equal(t, color.New(color.Bold).Sprint("x"), New(Problem).Sprint("x"))
+	assert.Equal(t, "x", SprintFunc("no.such.element")("x"))
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(HighContrast, map[string]string{Added: "none"}))
+
+	err := Validate("rainbow", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "theme must be one of default, high-contrast, monochrome")
+
+	err = Validate("", map[string]string{"dep.colour": "red"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown color element 'dep.colour'")
+
+	err = Validate("", map[string]string{DepHash: "red blinking"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown color or attribute 'blinking'")
+}
+
+func TestUse_InvalidKeepsCurrentTheme(t *testing.T) {
+	useTheme(t, Monochrome, nil)
+	require.Error(t, Use(Default, map[string]string{DepHash: "plaid"}))
+	assert.Equal(t, "x", New(DepHash).Sprint("x"))
+}
+
+func keys(m map[string]string) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
+
+func TestSetPlain(t *testing.T) {
+	useTheme(t, Default, nil)
+	t.Cleanup(func() { SetPlain(false) })
+
+	SetPlain(true)
+	assert.True(t, Plain())
+	assert.True(t, color.NoColor)
+	color.NoColor = false // Plain output stays unstyled even if color is forced back on
+	assert.Equal(t, "x", New(Problem).Sprint("x"))
+
+	SetPlain(false)
+	assert.False(t, Plain())
+	assert.Equal(t, color.New(color.FgRed).Sprint("x"), New(Problem).Sprint("x"))
+}
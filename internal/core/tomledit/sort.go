@@ -0,0 +1,151 @@
+package tomledit
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// tableUnit is a run of lines that moves as one when tables are sorted: a child table of the
+// parent with the comment lines right above its header and any tables nested in it.
+type tableUnit struct {
+	child string   // Name of the child table under the parent, "" for lines that stay in place
+	lines []string // Content without trailing blank lines
+	gap   []string // Trailing blank lines, which stay at the unit's position
+}
+
+// SortTables orders the child tables of parent ([parent.a], [parent.b] ...) by name. Comments
+// directly above a header and tables nested in a child ([parent.a.headers]) move with it; every
+// other line stays where it was, and blank lines between tables are kept in place. The sorted
+// document is decoded and compared with the original, so a layout the line-based sort would
+// change the meaning of is rejected instead.
+func SortTables(doc []byte, parent string) ([]byte, error) {
+	newline := "\n"
+	if bytes.Contains(doc, []byte("\r\n")) {
+		newline = "\r\n"
+	}
+	lines := strings.Split(strings.ReplaceAll(string(doc), "\r\n", "\n"), "\n")
+	units := splitUnits(lines, parent)
+
+	var slots []int
+	var children []tableUnit
+	for i, u := range units {
+		if u.child != "" {
+			slots = append(slots, i)
+			children = append(children, u)
+		}
+	}
+	sort.SliceStable(children, func(i, j int) bool { return children[i].child < children[j].child })
+	for i, slot := range slots {
+		children[i].gap = units[slot].gap
+		units[slot] = children[i]
+	}
+
+	var out []string
+	for _, u := range units {
+		out = append(out, u.lines...)
+		out = append(out, u.gap...)
+	}
+	sorted := []byte(strings.Join(out, newline))
+	if err := sameDocument(doc, sorted); err != nil {
+		return nil, fmt.Errorf("cannot sort [%s.*] tables: %w", parent, err)
+	}
+	return sorted, nil
+}
+
+// splitUnits cuts lines into the units SortTables moves around.
+func splitUnits(lines []string, parent string) []tableUnit {
+	starts := tableStarts(lines)
+	units := []tableUnit{{}}
+	if len(starts) == 0 || starts[0] > 0 {
+		end := len(lines)
+		if len(starts) > 0 {
+			end = starts[0]
+		}
+		units[0].lines = lines[:end]
+	}
+	for n, start := range starts {
+		end := len(lines)
+		if n+1 < len(starts) {
+			end = starts[n+1]
+		}
+		child := childTable(lines[start:end], parent)
+		last := &units[len(units)-1]
+		if child != "" && last.child == child {
+			// A nested table stays with its parent's unit, blank lines and all.
+			last.lines = append(append(last.lines, last.gap...), lines[start:end]...)
+			last.gap = nil
+		} else {
+			units = append(units, tableUnit{child: child, lines: slices.Clone(lines[start:end])})
+			last = &units[len(units)-1]
+		}
+		last.trimGap()
+	}
+	return units
+}
+
+// tableStarts returns the line each table starts at: its header, or the comment lines right
+// above it.
+func tableStarts(lines []string) []int {
+	var starts []int
+	for i, line := range lines {
+		if _, ok := tableHeader(line); !ok {
+			continue
+		}
+		start := i
+		for start > 0 && strings.HasPrefix(strings.TrimSpace(lines[start-1]), "#") {
+			start--
+		}
+		if len(starts) > 0 && start <= starts[len(starts)-1] {
+			start = i // Comments belong to one table only
+		}
+		starts = append(starts, start)
+	}
+	return starts
+}
+
+// trimGap moves the unit's trailing blank lines into its gap.
+func (u *tableUnit) trimGap() {
+	for len(u.lines) > 0 && strings.TrimSpace(u.lines[len(u.lines)-1]) == "" {
+		u.gap = append([]string{u.lines[len(u.lines)-1]}, u.gap...)
+		u.lines = u.lines[:len(u.lines)-1]
+	}
+}
+
+// childTable returns the name of parent's child table that the table in lines belongs to, or ""
+// if it is not under parent.
+func childTable(lines []string, parent string) string {
+	for _, line := range lines {
+		name, ok := tableHeader(line)
+		if !ok {
+			continue
+		}
+		rest, ok := strings.CutPrefix(name, parent+".")
+		if !ok {
+			return ""
+		}
+		child, _, _ := strings.Cut(rest, ".")
+		return strings.TrimSpace(child)
+	}
+	return ""
+}
+
+// sameDocument reports an error unless a and b decode to the same data.
+func sameDocument(a, b []byte) error {
+	var before, after map[string]any
+	if err := toml.Unmarshal(a, &before); err != nil {
+		return err
+	}
+	if err := toml.Unmarshal(b, &after); err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(before, after) {
+		return fmt.Errorf("the document lays them out in a way that cannot be reordered line by line")
+	}
+	return nil
+}
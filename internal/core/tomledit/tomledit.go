@@ -0,0 +1,173 @@
+// Package tomledit changes single values in a TOML document by editing its text, so comments,
+// key order and formatting survive. Re-encoding a decoded document would drop all of them.
+package tomledit
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// SetString sets key in [table] to the string value. The key's line is rewritten in place,
+// keeping its indentation and trailing comment; a missing key is added after the last key of the
+// table, and a missing table is appended to the document. Documents that define the key some
+// other way (dotted keys, inline tables, multi-line strings) are rejected rather than guessed at.
+func SetString(doc []byte, table, key, value string) ([]byte, error) {
+	newline := "\n"
+	if bytes.Contains(doc, []byte("\r\n")) {
+		newline = "\r\n"
+	}
+	lines := strings.Split(strings.ReplaceAll(string(doc), "\r\n", "\n"), "\n")
+	keyLine := regexp.MustCompile(`^(\s*)(` + regexp.QuoteMeta(key) + `|"` + regexp.QuoteMeta(key) + `")(\s*=\s*)(.*)$`)
+
+	inTable, found := false, false
+	insertAt := -1 // Line after which a missing key is added
+	for i, line := range lines {
+		if name, ok := tableHeader(line); ok {
+			inTable = name == table
+			if inTable {
+				insertAt = i
+			}
+			continue
+		}
+		if !inTable {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			insertAt = i
+		}
+		m := keyLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		tail, err := afterValue(m[4])
+		if err != nil {
+			return nil, fmt.Errorf("cannot edit %s.%s in place: %w", table, key, err)
+		}
+		lines[i] = m[1] + m[2] + m[3] + Quote(value) + tail
+		found = true
+		break
+	}
+
+	switch {
+	case found:
+	case insertAt >= 0:
+		lines = append(lines[:insertAt+1], append([]string{key + " = " + Quote(value)}, lines[insertAt+1:]...)...)
+	default:
+		lines = appendTable(lines, table, key+" = "+Quote(value))
+	}
+
+	out := []byte(strings.Join(lines, newline))
+	if err := check(out, table, key, value); err != nil {
+		return nil, fmt.Errorf("cannot edit %s.%s in place: %w", table, key, err)
+	}
+	return out, nil
+}
+
+// appendTable adds [table] holding the single line entry at the end of the document, separated
+// from what comes before by one blank line.
+func appendTable(lines []string, table, entry string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > 0 {
+		lines = append(lines, "")
+	}
+	return append(lines, "["+table+"]", entry, "")
+}
+
+// tableHeader returns the name of the standard table a line opens, if it opens one. Array
+// tables ([[name]]) are reported under a name no caller can ask for.
+func tableHeader(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "[") {
+		return "", false
+	}
+	if strings.HasPrefix(trimmed, "[[") {
+		return "[[array]]", true
+	}
+	end := strings.Index(trimmed, "]")
+	if end < 0 {
+		return "", false
+	}
+	return strings.TrimSpace(trimmed[1:end]), true
+}
+
+// afterValue returns what follows the value at the start of s: whitespace and a comment.
+func afterValue(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"""`), strings.HasPrefix(s, "'''"):
+		return "", fmt.Errorf("the current value is a multi-line string")
+	case strings.HasPrefix(s, `"`):
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				return s[i+1:], nil
+			}
+		}
+		return "", fmt.Errorf("unterminated string")
+	case strings.HasPrefix(s, "'"):
+		end := strings.Index(s[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string")
+		}
+		return s[end+2:], nil
+	case strings.HasPrefix(s, "{"), strings.HasPrefix(s, "["):
+		return "", fmt.Errorf("the current value is not a string")
+	}
+	if i := strings.Index(s, "#"); i >= 0 {
+		value := strings.TrimRight(s[:i], " \t")
+		return s[len(value):], nil
+	}
+	return "", nil
+}
+
+// check decodes the edited document and confirms the key now holds value.
+func check(doc []byte, table, key, value string) error {
+	var decoded map[string]any
+	if err := toml.Unmarshal(doc, &decoded); err != nil {
+		return err
+	}
+	section := decoded
+	for _, name := range strings.Split(table, ".") { // A nested table such as dependencies.json
+		section, _ = section[name].(map[string]any)
+	}
+	if got, _ := section[key].(string); got != value || section == nil {
+		return fmt.Errorf("the document defines it in a way that cannot be edited line by line")
+	}
+	return nil
+}
+
+// Quote renders s as a TOML basic string.
+func Quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,124 @@
+package tomledit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetString(t *testing.T) {
+	doc := `# My game
+[package]
+name = "game" # shown in the launcher
+version = '0.1.0'
+
+# Libraries
+[dependencies]
+json = { source = "github:rxi/json.lua/json.lua@master", path = "src/lib/json.lua" }
+`
+
+	t.Run("replaces a value and keeps its comment", func(t *testing.T) {
+		out, err := SetString([]byte(doc), "package", "name", `new "name"`)
+		require.NoError(t, err)
+		assert.Contains(t, string(out), `name = "new \"name\"" # shown in the launcher`)
+		assert.Contains(t, string(out), "# My game\n")
+		assert.Contains(t, string(out), "# Libraries\n")
+	})
+
+	t.Run("replaces a literal string", func(t *testing.T) {
+		out, err := SetString([]byte(doc), "package", "version", "0.2.0")
+		require.NoError(t, err)
+		assert.Contains(t, string(out), "version = \"0.2.0\"\n\n# Libraries")
+	})
+
+	t.Run("adds a missing key after the table's last key", func(t *testing.T) {
+		out, err := SetString([]byte(doc), "package", "license", "MIT")
+		require.NoError(t, err)
+		assert.Contains(t, string(out), "version = '0.1.0'\nlicense = \"MIT\"\n\n# Libraries")
+	})
+
+	t.Run("adds a missing table", func(t *testing.T) {
+		out, err := SetString([]byte("[dependencies]\n"), "package", "name", "game")
+		require.NoError(t, err)
+		assert.Equal(t, "[dependencies]\n\n[package]\nname = \"game\"\n", string(out))
+	})
+
+	t.Run("edits a nested table", func(t *testing.T) {
+		out, err := SetString([]byte("[dependencies.json]\npath = 'libs\\json.lua'\n"), "dependencies.json", "path", "libs/json.lua")
+		require.NoError(t, err)
+		assert.Equal(t, "[dependencies.json]\npath = \"libs/json.lua\"\n", string(out))
+	})
+
+	t.Run("keeps CRLF line endings", func(t *testing.T) {
+		out, err := SetString([]byte("[package]\r\nname = \"a\"\r\n"), "package", "name", "b")
+		require.NoError(t, err)
+		assert.Equal(t, "[package]\r\nname = \"b\"\r\n", string(out))
+	})
+
+	t.Run("rejects what it cannot edit line by line", func(t *testing.T) {
+		_, err := SetString([]byte("[package]\ndescription = \"\"\"\nlong\n\"\"\"\n"), "package", "description", "x")
+		assert.ErrorContains(t, err, "multi-line")
+
+		_, err = SetString([]byte("package = { name = \"a\" }\n"), "package", "name", "b")
+		assert.ErrorContains(t, err, "cannot edit package.name in place")
+	})
+}
+
+func TestSortTables(t *testing.T) {
+	doc := `[package]
+name = "game"
+
+# UI widgets
+[dependencies.button]
+source = "github:a/ui/button.lua@main"
+path = "libs/button.lua"
+
+[dependencies.button.headers]
+Accept = "text/plain"
+
+[scripts]
+test = "busted"
+
+[dependencies.json] # rxi
+source = "github:rxi/json.lua/json.lua@master"
+path = "libs/json.lua"
+
+[dependencies.class]
+source = "github:k/middleclass/middleclass.lua@v4"
+path = "libs/middleclass.lua"`
+
+	out, err := SortTables([]byte(doc), "dependencies")
+	require.NoError(t, err)
+	assert.Equal(t, `[package]
+name = "game"
+
+# UI widgets
+[dependencies.button]
+source = "github:a/ui/button.lua@main"
+path = "libs/button.lua"
+
+[dependencies.button.headers]
+Accept = "text/plain"
+
+[scripts]
+test = "busted"
+
+[dependencies.class]
+source = "github:k/middleclass/middleclass.lua@v4"
+path = "libs/middleclass.lua"
+
+[dependencies.json] # rxi
+source = "github:rxi/json.lua/json.lua@master"
+path = "libs/json.lua"`, string(out))
+
+	again, err := SortTables(out, "dependencies")
+	require.NoError(t, err)
+	assert.Equal(t, string(out), string(again), "sorting is idempotent")
+
+	t.Run("moves comments with their table", func(t *testing.T) {
+		out, err := SortTables([]byte("# zeta\n[dependencies.zeta]\npath = \"z\"\n\n# alpha\n[dependencies.alpha]\npath = \"a\"\n"), "dependencies")
+		require.NoError(t, err)
+		assert.Equal(t, "# alpha\n[dependencies.alpha]\npath = \"a\"\n\n# zeta\n[dependencies.zeta]\npath = \"z\"\n", string(out))
+	})
+}
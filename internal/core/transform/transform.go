@@ -0,0 +1,216 @@
+// Package transform rewrites dependency files as they are vendored, e.g. to shrink Lua sources
+// shipped to embedded targets.
+//
+// A transform is opted into per dependency (transform = "strip-comments" in project.toml). The
+// lockfile keeps the hash of the upstream content and additionally records the transform and the
+// hash of the transformed file, so 'almd verify' can check transformed files without refetching.
+package transform
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// StripComments removes comments, trailing whitespace and blank lines from Lua sources.
+const StripComments = "strip-comments"
+
+// transforms maps transform names to their implementation.
+var transforms = map[string]func(filename string, content []byte) ([]byte, error){
+	StripComments: stripComments,
+}
+
+// Names returns the supported transform names in sorted order.
+func Names() []string {
+	names := make([]string, 0, len(transforms))
+	for name := range transforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate reports an error if name is not a supported transform. The empty name means no
+// transform and is valid.
+func Validate(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, ok := transforms[name]; !ok {
+		return fmt.Errorf("unknown transform '%s' (supported: %s)", name, strings.Join(Names(), ", "))
+	}
+	return nil
+}
+
+// Apply runs the named transform on the content of filename. The empty name returns content
+// unchanged.
+func Apply(name, filename string, content []byte) ([]byte, error) {
+	if name == "" {
+		return content, nil
+	}
+	fn, ok := transforms[name]
+	if !ok {
+		return nil, Validate(name)
+	}
+	return fn(filename, content)
+}
+
+func stripComments(filename string, content []byte) ([]byte, error) {
+	if ext := strings.ToLower(filepath.Ext(filename)); ext != ".lua" {
+		return nil, fmt.Errorf("transform '%s' only supports .lua files, not '%s'", StripComments, filepath.Base(filename))
+	}
+	return stripLuaComments(content)
+}
+
+// luaStripper walks Lua source, copying code and string literals and dropping comments.
+type luaStripper struct {
+	src       []byte
+	pos       int
+	out       []byte
+	lineStart int // Index in out where the current output line starts
+}
+
+// stripLuaComments removes line and long comments, trailing whitespace and lines left blank.
+// String literals, including long strings, are copied unchanged, and a leading shebang line
+// is kept.
+func stripLuaComments(src []byte) ([]byte, error) {
+	s := &luaStripper{src: src, out: make([]byte, 0, len(src))}
+	if bytes.HasPrefix(src, []byte("#!")) {
+		end := bytes.IndexByte(src, '\n')
+		if end == -1 {
+			return append([]byte(nil), src...), nil
+		}
+		s.out = append(s.out, src[:end+1]...)
+		s.pos, s.lineStart = end+1, end+1
+	}
+
+	for s.pos < len(src) {
+		c := src[s.pos]
+		switch {
+		case c == '\n':
+			s.endLine()
+			s.pos++
+		case c == '"' || c == '\'':
+			if err := s.copyQuoted(c); err != nil {
+				return nil, err
+			}
+		case c == '[' && longBracketLevel(src[s.pos:]) >= 0:
+			if err := s.copyLong(); err != nil {
+				return nil, err
+			}
+		case c == '-' && bytes.HasPrefix(src[s.pos:], []byte("--")):
+			if err := s.skipComment(); err != nil {
+				return nil, err
+			}
+		default:
+			s.out = append(s.out, c)
+			s.pos++
+		}
+	}
+	s.trimLine()
+	return s.out, nil
+}
+
+// endLine finishes the current output line, dropping it when only whitespace is left.
+func (s *luaStripper) endLine() {
+	s.trimLine()
+	if len(s.out) == s.lineStart {
+		return
+	}
+	s.out = append(s.out, '\n')
+	s.lineStart = len(s.out)
+}
+
+// trimLine removes trailing spaces and tabs from the current output line.
+func (s *luaStripper) trimLine() {
+	end := len(s.out)
+	for end > s.lineStart && (s.out[end-1] == ' ' || s.out[end-1] == '\t' || s.out[end-1] == '\r') {
+		end--
+	}
+	s.out = s.out[:end]
+}
+
+// copyQuoted copies a quoted string literal, honouring backslash escapes.
+func (s *luaStripper) copyQuoted(quote byte) error {
+	start := s.pos
+	for s.pos++; s.pos < len(s.src); s.pos++ {
+		switch s.src[s.pos] {
+		case '\\':
+			s.pos++
+		case '\n':
+			return fmt.Errorf("unfinished string starting at byte %d", start)
+		case quote:
+			s.pos++
+			s.out = append(s.out, s.src[start:s.pos]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("unfinished string starting at byte %d", start)
+}
+
+// copyLong copies a long string such as [[...]] or [==[...]==].
+func (s *luaStripper) copyLong() error {
+	end, err := longBracketEnd(s.src, s.pos)
+	if err != nil {
+		return err
+	}
+	s.out = append(s.out, s.src[s.pos:end]...)
+	if nl := bytes.LastIndexByte(s.src[s.pos:end], '\n'); nl != -1 {
+		// The rest of the closing line holds the closing bracket, so it is never blank.
+		s.lineStart = len(s.out) - (end - s.pos - nl - 1)
+	}
+	s.pos = end
+	return nil
+}
+
+// skipComment skips a line comment up to (not including) its newline, or a whole long comment.
+// A long comment between two tokens is replaced with a space so that they stay apart.
+func (s *luaStripper) skipComment() error {
+	s.pos += 2
+	if s.pos < len(s.src) && s.src[s.pos] == '[' && longBracketLevel(s.src[s.pos:]) >= 0 {
+		end, err := longBracketEnd(s.src, s.pos)
+		if err != nil {
+			return err
+		}
+		s.pos = end
+		if len(s.out) > s.lineStart && !isSpace(s.out[len(s.out)-1]) && s.pos < len(s.src) && !isSpace(s.src[s.pos]) {
+			s.out = append(s.out, ' ')
+		}
+		return nil
+	}
+	for s.pos < len(s.src) && s.src[s.pos] != '\n' {
+		s.pos++
+	}
+	return nil
+}
+
+// isSpace reports whether c is whitespace between Lua tokens.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// longBracketLevel returns the number of '=' in an opening long bracket at the start of b, or
+// -1 if b does not start with one.
+func longBracketLevel(b []byte) int {
+	level := 1
+	for level < len(b) && b[level] == '=' {
+		level++
+	}
+	if level < len(b) && b[level] == '[' {
+		return level - 1
+	}
+	return -1
+}
+
+// longBracketEnd returns the index just past the long bracket closing the one opened at start.
+func longBracketEnd(src []byte, start int) (int, error) {
+	level := longBracketLevel(src[start:])
+	closing := "]" + strings.Repeat("=", level) + "]"
+	idx := bytes.Index(src[start+level+2:], []byte(closing))
+	if idx == -1 {
+		return 0, fmt.Errorf("unfinished long bracket starting at byte %d", start)
+	}
+	return start + level + 2 + idx + len(closing), nil
+}
@@ -0,0 +1,86 @@
+package transform_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nightconcept/almandine/internal/core/transform"
+)
+
+func TestApply_StripComments(t *testing.T) {
+	src := `#!/usr/bin/env lua
+-- Module header
+--[[ A long
+comment ]]
+local M = {}   -- trailing comment
+
+--[==[ level two ]] still comment ]==]
+M.url = "http://example.com/--not-a-comment" -- real comment
+M.escaped = 'it\'s -- still a string'
+M.doc = [[
+-- inside a long string
+
+  keeps   spacing ]]
+M.nested = [=[ ]] -- ]=]
+M.joined = function() return--[[x]]M end
+M.spaced = 1 --[[ inline ]] + 2
+
+return M
+`
+	want := `#!/usr/bin/env lua
+local M = {}
+M.url = "http://example.com/--not-a-comment"
+M.escaped = 'it\'s -- still a string'
+M.doc = [[
+-- inside a long string
+
+  keeps   spacing ]]
+M.nested = [=[ ]] -- ]=]
+M.joined = function() return M end
+M.spaced = 1  + 2
+return M
+`
+	got, err := transform.Apply(transform.StripComments, "lib.lua", []byte(src))
+	require.NoError(t, err)
+	assert.Equal(t, want, string(got))
+}
+
+func TestApply_StripCommentsIsIdempotent(t *testing.T) {
+	once, err := transform.Apply(transform.StripComments, "lib.lua", []byte("local a = 1 -- one\n\n\nreturn a"))
+	require.NoError(t, err)
+	assert.Equal(t, "local a = 1\nreturn a", string(once))
+
+	twice, err := transform.Apply(transform.StripComments, "lib.lua", once)
+	require.NoError(t, err)
+	assert.Equal(t, once, twice)
+}
+
+func TestApply_StripCommentsErrors(t *testing.T) {
+	_, err := transform.Apply(transform.StripComments, "data.json", []byte("{}"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "only supports .lua files")
+
+	_, err = transform.Apply(transform.StripComments, "lib.lua", []byte("local s = [[ never closed"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unfinished long bracket")
+
+	_, err = transform.Apply(transform.StripComments, "lib.lua", []byte("local s = 'never closed\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unfinished string")
+}
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, transform.Validate(""))
+	require.NoError(t, transform.Validate(transform.StripComments))
+
+	err := transform.Validate("minify")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "supported: strip-comments")
+
+	content := []byte("-- kept as is\n")
+	got, err := transform.Apply("", "lib.lua", content)
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+}
@@ -0,0 +1,122 @@
+// Package vendorheader adds and removes the provenance comment almd can prepend to vendored
+// files when [vendor] header = true is set in project.toml.
+//
+// The header records where a file came from (source, commit, retrieval date and license) for
+// auditors. It is metadata, not content: lockfile hashes are always computed over the upstream
+// bytes, and Strip removes the header again before a file is hashed or inspected.
+package vendorheader
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nightconcept/almandine/internal/core/source"
+)
+
+const (
+	beginMarker = "almd:vendor-header begin"
+	endMarker   = "almd:vendor-header end"
+)
+
+// commentPrefixes maps file extensions to their line comment syntax. Files of other types are
+// written without a header, since a header could not be added without changing their meaning.
+var commentPrefixes = map[string]string{
+	".lua": "--", ".tl": "--", ".moon": "--", ".fnl": ";;",
+	".sh": "#", ".bash": "#", ".py": "#", ".rb": "#", ".toml": "#", ".yaml": "#", ".yml": "#",
+	".js": "//", ".ts": "//", ".c": "//", ".h": "//", ".go": "//",
+}
+
+// Info is the provenance recorded in a header.
+type Info struct {
+	Source    string    // Source as declared in project.toml
+	Commit    string    // Commit the file was taken from, empty if unknown
+	Retrieved time.Time // When the file was downloaded
+	License   string    // SPDX license of the upstream repository, empty if unknown
+}
+
+// Describe collects the provenance of a dependency, asking its provider for the repository
+// license. Metadata lookups are best effort; a failure leaves the license empty.
+func Describe(parsed *source.ParsedSourceInfo, declaredSource, commit string) Info {
+	info := Info{Source: declaredSource, Commit: commit, Retrieved: time.Now().UTC()}
+	if parsed != nil && parsed.Owner != "" && parsed.Repo != "" {
+		if meta, err := source.FetchMetadata(parsed); err == nil {
+			info.License = meta.License
+		}
+	}
+	return info
+}
+
+// Apply returns content with a header describing info, placed after a leading shebang line if
+// there is one. Any existing header is replaced. Unsupported file types are returned unchanged.
+func Apply(content []byte, filename string, info Info) []byte {
+	prefix, ok := commentPrefixes[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return content
+	}
+	content = Strip(content)
+
+	var header bytes.Buffer
+	line := func(format string, args ...any) {
+		_, _ = fmt.Fprintf(&header, prefix+" "+format+"\n", args...)
+	}
+	line(beginMarker)
+	line("source: %s", info.Source)
+	if info.Commit != "" {
+		line("commit: %s", info.Commit)
+	}
+	line("retrieved: %s", info.Retrieved.UTC().Format(time.RFC3339))
+	license := info.License
+	if license == "" {
+		license = "unknown"
+	}
+	line("license: %s", license)
+	line(endMarker)
+
+	shebang, rest := splitShebang(content)
+	if len(shebang) > 0 && rest == nil {
+		shebang = append(shebang[:len(shebang):len(shebang)], '\n')
+	}
+	out := make([]byte, 0, len(shebang)+header.Len()+len(rest))
+	out = append(out, shebang...)
+	out = append(out, header.Bytes()...)
+	return append(out, rest...)
+}
+
+// Strip removes a header added by Apply and returns the upstream content. Content without a
+// header is returned unchanged.
+func Strip(content []byte) []byte {
+	shebang, rest := splitShebang(content)
+	firstLine, _, _ := bytes.Cut(rest, []byte("\n"))
+	if !bytes.HasSuffix(bytes.TrimRight(firstLine, "\r"), []byte(beginMarker)) {
+		return content
+	}
+	endIdx := bytes.Index(rest, []byte(endMarker))
+	if endIdx == -1 {
+		return content
+	}
+	afterEnd := rest[endIdx+len(endMarker):]
+	if nl := bytes.IndexByte(afterEnd, '\n'); nl != -1 {
+		afterEnd = afterEnd[nl+1:]
+	} else {
+		afterEnd = nil
+	}
+
+	out := make([]byte, 0, len(shebang)+len(afterEnd))
+	out = append(out, shebang...)
+	return append(out, afterEnd...)
+}
+
+// splitShebang separates a leading "#!" line, including its newline, from the rest of content.
+func splitShebang(content []byte) (shebang, rest []byte) {
+	if !bytes.HasPrefix(content, []byte("#!")) {
+		return nil, content
+	}
+	nl := bytes.IndexByte(content, '\n')
+	if nl == -1 {
+		return content, nil
+	}
+	return content[:nl+1], content[nl+1:]
+}
@@ -0,0 +1,69 @@
+package vendorheader_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nightconcept/almandine/internal/core/vendorheader"
+)
+
+var testInfo = vendorheader.Info{
+	Source:    "github:owner/repo/lib.lua@v1.0.0",
+	Commit:    "abcdef1234567890abcdef1234567890abcdef12",
+	Retrieved: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	License:   "MIT",
+}
+
+func TestApply_LuaHeader(t *testing.T) {
+	upstream := []byte("local M = {}\nreturn M\n")
+
+	got := vendorheader.Apply(upstream, "src/lib/lib.lua", testInfo)
+
+	assert.Equal(t, "-- almd:vendor-header begin\n"+
+		"-- source: github:owner/repo/lib.lua@v1.0.0\n"+
+		"-- commit: abcdef1234567890abcdef1234567890abcdef12\n"+
+		"-- retrieved: 2026-01-02T03:04:05Z\n"+
+		"-- license: MIT\n"+
+		"-- almd:vendor-header end\n"+
+		"local M = {}\nreturn M\n", string(got))
+	assert.Equal(t, upstream, vendorheader.Strip(got))
+}
+
+func TestApply_KeepsShebangFirst(t *testing.T) {
+	upstream := []byte("#!/bin/sh\necho hi\n")
+
+	got := vendorheader.Apply(upstream, "tools/run.sh", vendorheader.Info{Source: "s", Retrieved: testInfo.Retrieved})
+
+	assert.Equal(t, "#!/bin/sh\n"+
+		"# almd:vendor-header begin\n"+
+		"# source: s\n"+
+		"# retrieved: 2026-01-02T03:04:05Z\n"+
+		"# license: unknown\n"+
+		"# almd:vendor-header end\n"+
+		"echo hi\n", string(got))
+	assert.Equal(t, upstream, vendorheader.Strip(got))
+}
+
+func TestApply_ReplacesExistingHeader(t *testing.T) {
+	upstream := []byte("return 1\n")
+	first := vendorheader.Apply(upstream, "a.lua", testInfo)
+
+	updated := testInfo
+	updated.License = "Apache-2.0"
+	second := vendorheader.Apply(first, "a.lua", updated)
+
+	assert.Equal(t, vendorheader.Apply(upstream, "a.lua", updated), second)
+	assert.NotContains(t, string(second), "license: MIT")
+}
+
+func TestApply_UnsupportedExtension(t *testing.T) {
+	upstream := []byte(`{"a": 1}`)
+	assert.Equal(t, upstream, vendorheader.Apply(upstream, "data.json", testInfo))
+}
+
+func TestStrip_WithoutHeader(t *testing.T) {
+	content := []byte("-- a regular comment\nreturn {}\n")
+	assert.Equal(t, content, vendorheader.Strip(content))
+}
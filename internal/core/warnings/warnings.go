@@ -0,0 +1,65 @@
+// Package warnings is the single channel for non-fatal problems. Warnings are always written to
+// stderr (or the writer a command uses for errors), never to stdout, so stdout stays reserved
+// for data in porcelain and JSON output. With --warnings-as-errors, a run that reported any
+// warning fails once the command has finished.
+package warnings
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/nightconcept/almandine/internal/core/theme"
+)
+
+var (
+	mu       sync.Mutex
+	reported []string
+	asErrors bool
+)
+
+// Printf reports a warning on stderr. The message gets a "Warning:" prefix and a trailing newline.
+func Printf(format string, a ...interface{}) {
+	Fprintf(os.Stderr, format, a...)
+}
+
+// Fprintf reports a warning on w, for commands that write their errors somewhere other than stderr.
+func Fprintf(w io.Writer, format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...)
+	mu.Lock()
+	reported = append(reported, message)
+	mu.Unlock()
+	_, _ = fmt.Fprintf(w, "%s %s\n", theme.SprintFunc(theme.Warning)("Warning:"), message)
+}
+
+// Reported returns the messages of every warning reported so far.
+func Reported() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]string(nil), reported...)
+}
+
+// SetAsErrors turns --warnings-as-errors on or off.
+func SetAsErrors(on bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	asErrors = on
+}
+
+// Reset forgets the reported warnings and turns --warnings-as-errors off.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	reported, asErrors = nil, false
+}
+
+// Err returns an error when --warnings-as-errors is on and at least one warning was reported.
+func Err() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if !asErrors || len(reported) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d warning(s) reported and --warnings-as-errors is set", len(reported))
+}
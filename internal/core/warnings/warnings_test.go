@@ -0,0 +1,35 @@
+package warnings
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFprintf(t *testing.T) {
+	t.Cleanup(Reset)
+	var buf bytes.Buffer
+	Fprintf(&buf, "could not check %s", "lib.lua")
+	Fprintf(&buf, "second")
+
+	assert.Equal(t, "Warning: could not check lib.lua\nWarning: second\n", buf.String())
+	assert.Equal(t, []string{"could not check lib.lua", "second"}, Reported())
+}
+
+func TestErr(t *testing.T) {
+	t.Cleanup(Reset)
+	var buf bytes.Buffer
+	Fprintf(&buf, "something odd")
+	assert.NoError(t, Err(), "warnings only fail the run with --warnings-as-errors")
+
+	SetAsErrors(true)
+	err := Err()
+	require.Error(t, err)
+	assert.Equal(t, "1 warning(s) reported and --warnings-as-errors is set", err.Error())
+
+	Reset()
+	SetAsErrors(true)
+	assert.NoError(t, Err(), "a run without warnings passes")
+}